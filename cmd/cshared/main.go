@@ -0,0 +1,97 @@
+//go:build cgo
+
+// C-shared library build of the Jimi VL103M decoder
+//
+// Lets existing C, C#, Python (or any FFI-capable) platforms reuse the Go
+// parser. Every decode call takes a hex string and returns a JSON
+// export.Result allocated with malloc; release it with JimiFree.
+//
+// Build (produces libjimi.so/.dylib/.dll and libjimi.h):
+//
+//	make cshared
+//
+// C usage:
+//
+//	char *json = JimiDecode("787808132404020001870D0D0A", 0);
+//	printf("%s\n", json);
+//	JimiFree(json);
+//
+// Flags (bitwise OR):
+//
+//	1 = skip CRC validation
+//	2 = lenient mode (unknown protocols, no IMEI checksum)
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"unsafe"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+// Decode flags
+const (
+	flagSkipCRC = 1 << iota
+	flagLenient
+)
+
+// decoders holds one decoder per flag combination (decoders are safe for concurrent use)
+var decoders [4]*jimi.Decoder
+
+func init() {
+	for flags := range decoders {
+		var opts []jimi.Option
+		if flags&flagSkipCRC != 0 {
+			opts = append(opts, jimi.WithSkipCRC())
+		}
+		if flags&flagLenient != 0 {
+			opts = append(opts, jimi.WithLenientMode())
+		}
+		decoders[flags] = jimi.NewDecoder(opts...)
+	}
+}
+
+// JimiDecode decodes a hex-encoded packet (or concatenated packets) to JSON.
+// The returned string must be released with JimiFree.
+//
+//export JimiDecode
+func JimiDecode(hexInput *C.char, flags C.int) *C.char {
+	if hexInput == nil {
+		return marshal(export.Result{Error: "hex input is NULL", Version: jimi.Version})
+	}
+
+	decoder := decoders[int(flags)&(len(decoders)-1)]
+	return marshal(export.DecodeHex(decoder, C.GoString(hexInput)))
+}
+
+// JimiVersion returns the library version.
+// The returned string must be released with JimiFree.
+//
+//export JimiVersion
+func JimiVersion() *C.char {
+	return C.CString(jimi.Version)
+}
+
+// JimiFree releases a string returned by this library
+//
+//export JimiFree
+func JimiFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func marshal(r export.Result) *C.char {
+	out, err := json.Marshal(r)
+	if err != nil {
+		return C.CString(`{"ok":false,"error":"failed to encode result"}`)
+	}
+	return C.CString(string(out))
+}
+
+// main is required for -buildmode=c-shared
+func main() {}
@@ -0,0 +1,60 @@
+"""Python bindings for the Jimi VL103M decoder C-shared library.
+
+Build the library first with ``make cshared`` and point ``JIMI_LIB`` at it
+(defaults to ``bin/libjimi.so`` relative to the repository root).
+
+Usage::
+
+    import jimi
+
+    result = jimi.decode("787808132404020001870D0D0A")
+    if result["ok"]:
+        print(result["record"])
+"""
+
+import ctypes
+import json
+import os
+
+SKIP_CRC = 1
+LENIENT = 2
+
+_default_path = os.path.join(os.path.dirname(__file__), "..", "..", "..", "bin", "libjimi.so")
+_lib = ctypes.CDLL(os.environ.get("JIMI_LIB", _default_path))
+
+_lib.JimiDecode.argtypes = [ctypes.c_char_p, ctypes.c_int]
+_lib.JimiDecode.restype = ctypes.c_void_p
+_lib.JimiVersion.argtypes = []
+_lib.JimiVersion.restype = ctypes.c_void_p
+_lib.JimiFree.argtypes = [ctypes.c_void_p]
+_lib.JimiFree.restype = None
+
+
+def _take_string(ptr):
+    """Copy a library-owned C string and release it."""
+    try:
+        return ctypes.string_at(ptr).decode("utf-8")
+    finally:
+        _lib.JimiFree(ptr)
+
+
+def version():
+    """Return the Go library version."""
+    return _take_string(_lib.JimiVersion())
+
+
+def decode(hex_packet, flags=0):
+    """Decode a hex packet and return the result as a dict.
+
+    flags is a bitwise OR of SKIP_CRC and LENIENT.
+    """
+    return json.loads(_take_string(_lib.JimiDecode(hex_packet.encode("ascii"), flags)))
+
+
+if __name__ == "__main__":
+    import sys
+
+    for line in sys.stdin:
+        line = line.strip()
+        if line:
+            print(json.dumps(decode(line)))
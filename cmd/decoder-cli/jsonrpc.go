@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcRequest is a JSON-RPC 2.0 request (one per line)
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC 2.0 error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// decodeParams are the parameters of "decode" and "decodeBatch"
+//
+// Positional form is also accepted: ["<hex>"] for decode, ["<hex>", ...] for decodeBatch.
+type decodeParams struct {
+	Hex     string   `json:"hex"`
+	Packets []string `json:"packets"`
+	SkipCRC bool     `json:"skip_crc"`
+	Lenient bool     `json:"lenient"`
+}
+
+// serveJSONRPC reads newline-delimited JSON-RPC 2.0 requests from r and writes
+// one response line per request to w until r is exhausted.
+//
+// Methods:
+//   - decode      {"hex": "7878...", "skip_crc": false, "lenient": false} -> export.Result
+//   - decodeBatch {"packets": ["7878...", ...]}                            -> []export.Result
+//   - version                                                              -> {"version": "..."}
+func serveJSONRPC(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		resp, notify := handleRPC(line)
+		if notify {
+			continue
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		// Flush per response so pipeline clients see results immediately
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handleRPC processes one request line.
+// Returns true as second value for notifications (no response expected).
+func handleRPC(line []byte) (rpcResponse, bool) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		return resp, false
+	}
+	if len(req.ID) > 0 {
+		resp.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "expected jsonrpc 2.0 request with method"}
+		return resp, false
+	}
+
+	notify := len(req.ID) == 0
+
+	switch req.Method {
+	case "version":
+		resp.Result = map[string]any{"version": jimi.Version, "protocol": jimi.ProtocolVersion, "schema": export.SchemaVersion}
+
+	case "decode", "decodeBatch":
+		params, err := parseDecodeParams(req.Method, req.Params)
+		if err != nil {
+			resp.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			break
+		}
+
+		decoder := jimi.NewDecoder(rpcDecoderOptions(params)...)
+		if req.Method == "decode" {
+			resp.Result = export.DecodeHex(decoder, params.Hex)
+			break
+		}
+
+		results := make([]export.Result, len(params.Packets))
+		for i, h := range params.Packets {
+			results[i] = export.DecodeHex(decoder, h)
+		}
+		resp.Result = results
+
+	default:
+		resp.Error = &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	return resp, notify
+}
+
+// parseDecodeParams accepts named or positional parameters
+func parseDecodeParams(method string, raw json.RawMessage) (decodeParams, error) {
+	var params decodeParams
+	if len(raw) == 0 {
+		return params, errMissingParams(method)
+	}
+
+	if raw[0] == '[' {
+		var positional []string
+		if err := json.Unmarshal(raw, &positional); err != nil {
+			return params, err
+		}
+		if method == "decode" {
+			if len(positional) != 1 {
+				return params, errMissingParams(method)
+			}
+			params.Hex = positional[0]
+		} else {
+			params.Packets = positional
+		}
+		return params, nil
+	}
+
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return params, err
+	}
+	if method == "decode" && params.Hex == "" {
+		return params, errMissingParams(method)
+	}
+	return params, nil
+}
+
+// errMissingParams describes the expected parameters of a method
+func errMissingParams(method string) error {
+	if method == "decode" {
+		return errors.New(`decode requires {"hex": "..."} or ["<hex>"]`)
+	}
+	return errors.New(`decodeBatch requires {"packets": [...]} or ["<hex>", ...]`)
+}
+
+// rpcDecoderOptions combines CLI flags with per-request options
+func rpcDecoderOptions(p decodeParams) []jimi.Option {
+	opts := decoderOptions()
+	if p.SkipCRC {
+		opts = append(opts, jimi.WithSkipCRC())
+	}
+	if p.Lenient {
+		opts = append(opts, jimi.WithLenientMode())
+	}
+	return opts
+}
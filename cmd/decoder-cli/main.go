@@ -0,0 +1,247 @@
+// Decoder CLI for Jimi VL103M GPS Tracker packets
+//
+// Decodes hex-encoded packets from a file (or stdin), one packet per line.
+// Lines are decoded concurrently and reported in input order with
+// per-line errors.
+//
+// Accepted line formats:
+//   - Plain hex:           787811010359339073930523044D01F4000168DB0D0A
+//   - Spaced hex:          78 78 11 01 03 59 ...
+//   - tcp-server raw logs: [2024-06-15 14:30:45.000] RX 787811...
+//
+// Blank lines and lines starting with '#' are ignored.
+//
+// With -ndjson each decoded packet is written to stdout as one JSON object per
+// line (see pkg/jimi/export) for piping into jq, Vector or Fluent Bit; decode
+// errors go to stderr.
+//
+// With -geojson the positioned fixes are written to stdout as one GeoJSON
+// FeatureCollection (a track and points per IMEI, see pkg/jimi/geojson) for
+// Kepler.gl or QGIS. Fixes are attributed to the IMEI of the last login
+// packet before them.
+//
+// With -protocol only the packets of the listed protocols are printed, by
+// name or number (e.g. -protocol login,gps_location,0xA0); decode errors are
+// always reported.
+//
+// With -serve-jsonrpc the CLI instead runs as a JSON-RPC 2.0 sidecar: one
+// request per line on stdin, one response per line on stdout. Scripting
+// languages can keep a single process open and stream packets through it
+// (see python/jimi_rpc.py).
+//
+// Usage:
+//
+//	decoder-cli -file capture.txt
+//	cat capture.txt | decoder-cli -skip-crc
+//	decoder-cli -file capture.txt -ndjson | jq 'select(.type == "Alarm")'
+//	decoder-cli -file capture.txt -geojson > track.geojson
+//	decoder-cli -file capture.txt -protocol heartbeat,alarm
+//	echo '{"jsonrpc":"2.0","id":1,"method":"decode","params":{"hex":"7878..."}}' | decoder-cli -serve-jsonrpc
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geojson"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Configuration flags
+var (
+	inputFile  = flag.String("file", "", "File with one hex packet per line (default: stdin)")
+	workers    = flag.Int("workers", runtime.GOMAXPROCS(0), "Number of concurrent decode workers")
+	skipCRC    = flag.Bool("skip-crc", false, "Skip CRC validation")
+	lenient    = flag.Bool("lenient", false, "Enable lenient decoding (unknown protocols, no IMEI checksum)")
+	errorsOnly = flag.Bool("errors-only", false, "Only print lines that failed to decode")
+	ndjson     = flag.Bool("ndjson", false, "Write decoded packets as NDJSON records to stdout")
+	geoJSON    = flag.Bool("geojson", false, "Write positioned fixes as a GeoJSON FeatureCollection to stdout")
+	protoList  = flag.String("protocol", "", "Only print packets of these protocols, comma-separated names or numbers (e.g. login,0x22)")
+	serveRPC   = flag.Bool("serve-jsonrpc", false, "Serve JSON-RPC 2.0 requests on stdin/stdout")
+)
+
+// inputLine is a hex packet with its position in the input
+type inputLine struct {
+	number int
+	hex    string
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	if *serveRPC {
+		if err := serveJSONRPC(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("JSON-RPC: %v", err)
+		}
+		return
+	}
+
+	in := io.Reader(os.Stdin)
+	if *inputFile != "" {
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	lines, err := readLines(in)
+	if err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+
+	protocols, err := parseProtocols(*protoList)
+	if err != nil {
+		log.Fatalf("Invalid -protocol: %v", err)
+	}
+
+	failed := runBatch(os.Stdout, lines, protocols)
+
+	log.Printf("Decoded %d/%d packets (%d errors)", len(lines)-failed, len(lines), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// decoderOptions builds decoder options from flags
+func decoderOptions() []jimi.Option {
+	var opts []jimi.Option
+	if *skipCRC {
+		opts = append(opts, jimi.WithSkipCRC())
+	}
+	if *lenient {
+		opts = append(opts, jimi.WithLenientMode())
+	}
+	return opts
+}
+
+// parseProtocols parses a comma-separated list of protocol names or numbers
+// (nil for an empty list, which matches every protocol)
+func parseProtocols(s string) (map[protocol.Number]bool, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	out := make(map[protocol.Number]bool)
+	for _, v := range strings.Split(s, ",") {
+		n, err := protocol.ParseNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		out[n] = true
+	}
+	return out, nil
+}
+
+// runBatch decodes all lines and prints one result per line, skipping the
+// packets of protocols not in protocols (if not nil).
+// Returns the number of lines that failed to decode.
+func runBatch(w io.Writer, lines []inputLine, protocols map[protocol.Number]bool) int {
+	inputs := make([]string, len(lines))
+	for i, l := range lines {
+		inputs[i] = l.hex
+	}
+
+	decoder := jimi.NewDecoder(decoderOptions()...)
+	results := decoder.DecodeBatchWorkers(inputs, *workers)
+
+	var records *export.NDJSONWriter
+	if *ndjson {
+		records = export.NewNDJSONWriter(w)
+	}
+	var collection *geojson.FeatureCollectionWriter
+	if *geoJSON {
+		collection = geojson.NewFeatureCollectionWriter()
+	}
+
+	failed := 0
+	imei := ""
+	for _, r := range results {
+		line := lines[r.Index].number
+		if r.Err != nil {
+			failed++
+			if records != nil || collection != nil {
+				// Keep stdout machine-readable
+				log.Printf("line %d: ERROR: %v", line, r.Err)
+			} else {
+				fmt.Fprintf(w, "line %d: ERROR: %v\n", line, r.Err)
+			}
+			continue
+		}
+		if *errorsOnly {
+			continue
+		}
+		if login, ok := r.Packet.(*packet.LoginPacket); ok {
+			imei = login.GetIMEI()
+		}
+		if protocols != nil && !protocols[protocol.Number(r.Packet.ProtocolNumber())] {
+			continue
+		}
+		if collection != nil {
+			collection.Add(imei, r.Packet)
+			continue
+		}
+		if records != nil {
+			if err := records.Write(export.FromPacket(r.Packet)); err != nil {
+				log.Fatalf("Failed to write output: %v", err)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "line %d: %s (0x%02X) serial=%d %s\n",
+			line, r.Packet.Type(), r.Packet.ProtocolNumber(), r.Packet.SerialNumber(), r.Packet)
+	}
+	if collection != nil {
+		if _, err := collection.WriteTo(w); err != nil {
+			log.Fatalf("Failed to write output: %v", err)
+		}
+	}
+	return failed
+}
+
+// readLines reads hex packets from r, skipping blank and comment lines
+func readLines(r io.Reader) ([]inputLine, error) {
+	var lines []inputLine
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	number := 0
+	for scanner.Scan() {
+		number++
+		if h := extractHex(scanner.Text()); h != "" {
+			lines = append(lines, inputLine{number: number, hex: h})
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// extractHex returns the hex payload of a line ("" for blank/comment lines)
+func extractHex(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	// tcp-server raw log format: [timestamp] DIRECTION hex
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end >= 0 {
+			fields := strings.Fields(line[end+1:])
+			if len(fields) == 0 {
+				return ""
+			}
+			return fields[len(fields)-1]
+		}
+	}
+
+	return line
+}
@@ -0,0 +1,96 @@
+"""Python client for the decoder-cli JSON-RPC sidecar.
+
+Unlike the ctypes bindings in cmd/cshared/python, this client needs no cgo
+build: it starts ``decoder-cli -serve-jsonrpc`` once and streams requests to
+it over stdin/stdout. Build the CLI with ``make build`` and point
+``JIMI_DECODER_CLI`` at it (defaults to ``bin/decoder-cli`` relative to the
+repository root).
+
+Usage::
+
+    from jimi_rpc import Decoder
+
+    with Decoder() as d:
+        result = d.decode("787808132404020001870D0D0A")
+        if result["ok"]:
+            print(result["record"])
+"""
+
+import itertools
+import json
+import os
+import subprocess
+
+_default_path = os.path.join(os.path.dirname(__file__), "..", "..", "..", "bin", "decoder-cli")
+
+
+class RPCError(Exception):
+    """Error returned by the sidecar."""
+
+    def __init__(self, code, message):
+        super().__init__("%d: %s" % (code, message))
+        self.code = code
+        self.message = message
+
+
+class Decoder:
+    """A long-running decoder-cli JSON-RPC process."""
+
+    def __init__(self, path=None, args=()):
+        cmd = [path or os.environ.get("JIMI_DECODER_CLI", _default_path), "-serve-jsonrpc", *args]
+        self._proc = subprocess.Popen(
+            cmd, stdin=subprocess.PIPE, stdout=subprocess.PIPE, text=True, bufsize=1
+        )
+        self._ids = itertools.count(1)
+
+    def call(self, method, params=None):
+        """Send a request and wait for its response."""
+        req = {"jsonrpc": "2.0", "id": next(self._ids), "method": method}
+        if params is not None:
+            req["params"] = params
+        self._proc.stdin.write(json.dumps(req) + "\n")
+        self._proc.stdin.flush()
+
+        line = self._proc.stdout.readline()
+        if not line:
+            raise RPCError(-32000, "decoder-cli exited")
+        resp = json.loads(line)
+        if "error" in resp:
+            raise RPCError(resp["error"]["code"], resp["error"]["message"])
+        return resp["result"]
+
+    def version(self):
+        """Return the Go library version."""
+        return self.call("version")["version"]
+
+    def decode(self, hex_packet, skip_crc=False, lenient=False):
+        """Decode a hex packet and return the result as a dict."""
+        return self.call("decode", {"hex": hex_packet, "skip_crc": skip_crc, "lenient": lenient})
+
+    def decode_batch(self, hex_packets, skip_crc=False, lenient=False):
+        """Decode a list of hex packets and return a list of result dicts."""
+        return self.call(
+            "decodeBatch", {"packets": list(hex_packets), "skip_crc": skip_crc, "lenient": lenient}
+        )
+
+    def close(self):
+        """Stop the sidecar process."""
+        if self._proc.poll() is None:
+            self._proc.stdin.close()
+            self._proc.wait()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+
+if __name__ == "__main__":
+    import sys
+
+    with Decoder() as d:
+        for line in sys.stdin:
+            line = line.strip()
+            if line:
+                print(json.dumps(d.decode(line)))
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// Network impairment flags
+var (
+	latency   = flag.Duration("latency", 0, "Delay added before every packet sent")
+	jitter    = flag.Duration("jitter", 0, "Random extra delay of up to this much per packet")
+	fragment  = flag.Bool("fragment", false, "Write packets in random fragments")
+	sockets   = flag.Int("sockets", 1, "Connections per device; packets go out on a random one")
+	dropRate  = flag.Float64("drop", 0, "Probability (0-1) of disconnecting in the middle of a packet")
+	reconnect = flag.Duration("reconnect", 5*time.Second, "Delay before reconnecting after a simulated disconnect")
+)
+
+// impairment is the network impairment of the connections of a device, set
+// by the flags or by a failure mode of the scenario
+type impairment struct {
+	latency   time.Duration
+	jitter    time.Duration
+	fragment  bool
+	sockets   int
+	drop      float64
+	reconnect time.Duration
+}
+
+// flagImpairment returns the impairment set by the flags
+func flagImpairment() impairment {
+	return impairment{
+		latency:   *latency,
+		jitter:    *jitter,
+		fragment:  *fragment,
+		sockets:   *sockets,
+		drop:      *dropRate,
+		reconnect: *reconnect,
+	}
+}
+
+// validate checks the values a failure mode or the flags may get wrong
+func (m impairment) validate() error {
+	if m.sockets < 1 {
+		return errors.New("sockets must be at least 1")
+	}
+	if m.drop < 0 || m.drop >= 1 {
+		return errors.New("drop must be in [0, 1)")
+	}
+	return nil
+}
+
+// active reports whether the connections are impaired at all
+func (m impairment) active() bool {
+	return m.latency > 0 || m.jitter > 0 || m.fragment || m.sockets > 1 || m.drop > 0
+}
+
+// String returns a summary for the logs
+func (m impairment) String() string {
+	return fmt.Sprintf("latency %v, jitter %v, fragment %v, %d socket(s), drop rate %g",
+		m.latency, m.jitter, m.fragment, m.sockets, m.drop)
+}
+
+// fragmentPause separates fragments so they reach the server in separate reads
+const fragmentPause = 20 * time.Millisecond
+
+// dropError reports a simulated disconnect and the packet it cut short
+type dropError struct {
+	packet []byte
+}
+
+func (e *dropError) Error() string { return "simulated disconnect" }
+
+// link is one device connection. Packets are queued and written in order by
+// writeLoop, which applies latency, jitter, fragmentation and drops; links
+// are independent, so with several sockets packets can arrive reordered.
+type link struct {
+	imei       string
+	conn       net.Conn
+	queue      chan []byte
+	done       <-chan struct{}
+	errc       chan<- error
+	impairment impairment
+}
+
+// delay waits the configured latency plus a random jitter
+func (l *link) delay() {
+	d := l.impairment.latency
+	if l.impairment.jitter > 0 {
+		d += rand.N(l.impairment.jitter)
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// write sends data after the configured delay, in random fragments with -fragment
+func (l *link) write(data []byte) error {
+	l.delay()
+	if *verbose {
+		log.Printf("[%s] TX %X", l.imei, data)
+	}
+	if !l.impairment.fragment {
+		_, err := l.conn.Write(data)
+		return err
+	}
+	for len(data) > 0 {
+		n := 1 + rand.IntN(len(data))
+		if _, err := l.conn.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) > 0 {
+			time.Sleep(fragmentPause)
+		}
+	}
+	return nil
+}
+
+// writeLoop writes queued packets until done is closed. A drawn simulated
+// disconnect writes part of the packet, reports it and closes the connection.
+func (l *link) writeLoop() {
+	for {
+		select {
+		case <-l.done:
+			return
+		case data := <-l.queue:
+			if l.impairment.drop > 0 && rand.Float64() < l.impairment.drop {
+				l.delay()
+				l.conn.Write(data[:1+rand.IntN(len(data)-1)])
+				l.errc <- &dropError{packet: data}
+				l.conn.Close()
+				return
+			}
+			if err := l.write(data); err != nil {
+				l.errc <- err
+				return
+			}
+			sentPackets.Add(1)
+		}
+	}
+}
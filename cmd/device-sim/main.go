@@ -0,0 +1,917 @@
+// Device simulator for Jimi VL103M servers
+//
+// Connects to a server as one or more simulated trackers: logs in, sends
+// heartbeats, plays back a route as GPS location packets (0x22, or 0xA0 with
+// -4g) and answers online commands (0x80) with command responses (0x21).
+// Useful for load and integration testing without hardware.
+//
+// Routes are CSV files (lat,lon[,speed_kmh[,course]] per line; a header line
+// and '#' comments are ignored), GPX files (trkpt, rtept or wpt points) or
+// GeoJSON files (LineString and MultiLineString geometries, or else Point
+// features with optional speed and course properties). Missing speeds and
+// courses are derived from consecutive points. Without a route every device
+// reports the fixed -lat/-lon position until stopped.
+//
+// With -devices N the simulator runs N devices with consecutive IMEIs (valid
+// Luhn check digits) starting at -imei, each on its own connection.
+//
+// With -scenario the devices come from a JSON or YAML file scripting a
+// fleet: each vehicle has its own route, range of IMEIs, speed profile,
+// stops and alarms injected along the way, and may use a firmware profile
+// (model, timezone, 4G, intervals, CRC variant...) and a network failure
+// mode defined in the file (see scenario.go).
+//
+// Network impairment flags exercise the server's stream handling: -latency
+// and -jitter delay every packet, -fragment writes packets in random pieces
+// (residue buffering), -sockets N logs each device in on N connections and
+// sends every packet on a random one (reordering, with -jitter), and -drop P
+// cuts a packet short and disconnects with probability P; the device then
+// reconnects after -reconnect, logs in again and resends the whole packet.
+// -login-retries resends unacknowledged logins.
+//
+// With -tls the devices connect over TLS, e.g. to a tcp-server started with
+// -tls-cert/-tls-key: -tls-ca verifies the server certificate against a
+// private CA (-tls-insecure skips the verification), and -tls-cert/-tls-key
+// present a client certificate for servers requiring one (-tls-client-ca,
+// -device-auth).
+//
+// Usage:
+//
+//	device-sim -addr localhost:5023 -route trip.gpx -interval 5s
+//	device-sim -devices 200 -interval 10s -4g -loop -route route.csv
+//	device-sim -interval 1s -jitter 500ms -sockets 3 -fragment -drop 0.05
+//	device-sim -scenario fleet.json -interval 2s
+//	device-sim -scenario fleet.yaml
+//	device-sim -addr gps.example.com:5023 -tls-ca ca.pem -tls-cert gateway.pem -tls-key gateway.key
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Configuration flags
+var (
+	addr      = flag.String("addr", "localhost:5023", "Server address")
+	baseIMEI  = flag.String("imei", "359339073930520", "IMEI of the (first) device")
+	devices   = flag.Int("devices", 1, "Number of simulated devices")
+	routeFile = flag.String("route", "", "CSV or GPX route to play back (default: fixed position)")
+	interval  = flag.Duration("interval", 10*time.Second, "Interval between location packets")
+	heartbeat = flag.Duration("heartbeat", 3*time.Minute, "Interval between heartbeats (0 disables)")
+	use4G     = flag.Bool("4g", false, "Send 4G location packets (0xA0) instead of 0x22")
+	loop      = flag.Bool("loop", false, "Restart the route when it ends (default: disconnect)")
+	fixedLat  = flag.Float64("lat", 23.111668, "Latitude reported without a route")
+	fixedLon  = flag.Float64("lon", 114.409285, "Longitude reported without a route")
+	modelID   = flag.Uint("model", 0x4D01, "Model identification code sent at login")
+	tzOffset  = flag.Int("tz", 0, "Timezone offset in minutes sent at login")
+	verbose   = flag.Bool("verbose", false, "Log every packet sent and received")
+
+	loginRetries = flag.Int("login-retries", 0, "Times the login is resent when not acknowledged")
+)
+
+// loginTimeout is how long a device waits for the login response
+const loginTimeout = 10 * time.Second
+
+// point is one route position
+type point struct {
+	lat, lon float64
+	speed    float64              // km/h, negative when unknown
+	course   float64              // degrees, negative when unknown
+	alarms   []protocol.AlarmType // alarms sent at this position
+}
+
+// Counters reported at exit
+var (
+	sentPackets      atomic.Int64
+	receivedPackets  atomic.Int64
+	commandsAnswered atomic.Int64
+	loggedIn         atomic.Int64
+	droppedConns     atomic.Int64
+)
+
+func main() {
+	flag.Parse()
+
+	if *devices < 1 {
+		log.Fatal("-devices must be at least 1")
+	}
+	if *interval <= 0 {
+		log.Fatal("-interval must be positive")
+	}
+	if err := flagImpairment().validate(); err != nil {
+		log.Fatalf("Invalid flags: -%v", err)
+	}
+	var err error
+	if clientTLS, err = loadClientTLS(); err != nil {
+		log.Fatalf("Invalid TLS flags: %v", err)
+	}
+
+	var specs []deviceSpec
+	if *scenarioFile != "" {
+		specs, err = loadScenario(*scenarioFile)
+		if err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+		log.Printf("Loaded %d device(s) from %s", len(specs), *scenarioFile)
+	} else {
+		route := []point{fixedPoint()}
+		if *routeFile != "" {
+			route, err = loadRoute(*routeFile)
+			if err != nil {
+				log.Fatalf("Failed to load route: %v", err)
+			}
+			log.Printf("Loaded %d route points from %s", len(route), *routeFile)
+		}
+
+		imeis, err := deviceIMEIs(*baseIMEI, *devices)
+		if err != nil {
+			log.Fatalf("Invalid -imei: %v", err)
+		}
+		for i, imei := range imeis {
+			// Spread connections over one interval
+			start := *interval * time.Duration(i) / time.Duration(len(imeis))
+			specs = append(specs, deviceSpec{
+				imei: imei, route: route, loop: *loop, hold: *routeFile == "", start: start,
+				firmware: flagFirmware(), impairment: flagImpairment(),
+			})
+		}
+	}
+
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("Stopping devices...")
+		close(stop)
+	}()
+
+	if *scenarioFile != "" {
+		log.Printf("Simulating %d device(s) against %s", len(specs), *addr)
+	} else {
+		log.Printf("Simulating %d device(s) against %s (interval %v, 4G: %v)", len(specs), *addr, *interval, *use4G)
+	}
+	if clientTLS != nil {
+		log.Printf("TLS: server name %q, CA %s, client certificate %s", clientTLS.ServerName, cmp.Or(*tlsCA, "system roots"), cmp.Or(*tlsCert, "none"))
+	}
+	if m := flagImpairment(); m.active() {
+		log.Printf("Network impairment: %s", m)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if spec.start > 0 {
+				select {
+				case <-time.After(spec.start):
+				case <-stop:
+					return
+				}
+			}
+
+			d := &device{
+				imei: spec.imei, route: spec.route, loop: spec.loop, hold: spec.hold,
+				firmware: spec.firmware, impairment: spec.impairment, enc: encoder.New(),
+			}
+			d.enc.CRC = spec.firmware.crc
+			if err := d.run(stop); err != nil {
+				log.Printf("[%s] %v", spec.imei, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("Done in %v: %d logins, %d packets sent, %d received, %d commands answered, %d simulated disconnects",
+		time.Since(start).Round(time.Millisecond), loggedIn.Load(), sentPackets.Load(),
+		receivedPackets.Load(), commandsAnswered.Load(), droppedConns.Load())
+}
+
+// deviceSpec is a device to simulate
+type deviceSpec struct {
+	imei  types.IMEI
+	route []point
+	loop  bool          // restart the route when it ends
+	hold  bool          // keep reporting the last position when the route ends
+	start time.Duration // delay before connecting
+
+	firmware   firmware
+	impairment impairment
+}
+
+// firmware is how a device behaves, set by the flags or by a firmware
+// profile of the scenario
+type firmware struct {
+	model        uint16
+	timezone     int // minutes, sent at login
+	use4G        bool
+	interval     time.Duration
+	heartbeat    time.Duration
+	loginRetries int
+	crc          jimi.CRC // nil for the protocol default
+	version      string   // reply to VERSION#
+}
+
+// flagFirmware returns the firmware set by the flags
+func flagFirmware() firmware {
+	return firmware{
+		model:        uint16(*modelID),
+		timezone:     *tzOffset,
+		use4G:        *use4G,
+		interval:     *interval,
+		heartbeat:    *heartbeat,
+		loginRetries: *loginRetries,
+		version:      "device-sim",
+	}
+}
+
+// decoderOptions returns the decoder options for the packets of the server
+func (fw firmware) decoderOptions() []jimi.Option {
+	if fw.crc == nil {
+		return nil
+	}
+	return []jimi.Option{jimi.WithCRCVariant(fw.crc)}
+}
+
+// fixedPoint returns the -lat/-lon position reported without a route
+func fixedPoint() point {
+	return point{lat: *fixedLat, lon: *fixedLon, speed: 0, course: 0}
+}
+
+// device is one simulated tracker
+type device struct {
+	imei  types.IMEI
+	route []point
+	loop  bool
+	hold  bool
+	enc   *encoder.Encoder
+
+	firmware   firmware
+	impairment impairment
+
+	linkMu sync.Mutex
+	links  []*link
+
+	serialMu sync.Mutex
+	serial   uint16
+
+	posMu sync.Mutex
+	pos   point
+
+	next    int    // route point of the next location packet
+	started bool   // whether the first location was sent
+	holding bool   // whether the route ended and the last point is repeated
+	pending []byte // packet cut short by a simulated disconnect
+}
+
+// run plays the route until it ends or stop is closed, reconnecting after
+// simulated disconnects
+func (d *device) run(stop <-chan struct{}) error {
+	for {
+		err := d.session(stop)
+		var drop *dropError
+		if !errors.As(err, &drop) {
+			return err
+		}
+		droppedConns.Add(1)
+		d.pending = drop.packet
+		log.Printf("[%s] Simulated disconnect, reconnecting in %v", d.imei, d.impairment.reconnect)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(d.impairment.reconnect):
+		}
+	}
+}
+
+// session connects and logs in every socket, then sends packets until the
+// route ends, a connection fails or stop is closed
+func (d *device) session(stop <-chan struct{}) error {
+	done := make(chan struct{})
+	errc := make(chan error, 2*d.impairment.sockets)
+	defer func() {
+		close(done)
+		d.closeLinks()
+	}()
+
+	// Closing the connections unblocks the readers
+	go func() {
+		select {
+		case <-stop:
+			d.closeLinks()
+		case <-done:
+		}
+	}()
+
+	d.linkMu.Lock()
+	d.links = nil
+	d.linkMu.Unlock()
+	for range d.impairment.sockets {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		l := &link{imei: d.imei.String(), conn: conn, queue: make(chan []byte, 64), done: done, errc: errc, impairment: d.impairment}
+		d.linkMu.Lock()
+		d.links = append(d.links, l)
+		d.linkMu.Unlock()
+
+		if err := d.login(l); err != nil {
+			return err
+		}
+		loggedIn.Add(1)
+	}
+	for _, l := range d.links {
+		go l.writeLoop()
+		go func() { errc <- d.readLoop(l) }()
+	}
+
+	var heartbeats <-chan time.Time
+	if d.firmware.heartbeat > 0 {
+		t := time.NewTicker(d.firmware.heartbeat)
+		defer t.Stop()
+		heartbeats = t.C
+	}
+	locations := time.NewTicker(d.firmware.interval)
+	defer locations.Stop()
+
+	if d.pending != nil {
+		d.send(d.pending)
+		d.pending = nil
+	}
+	if !d.started {
+		d.started = true
+		if err := d.sendLocation(d.next); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-errc:
+			return err
+		case <-heartbeats:
+			if err := d.sendHeartbeat(); err != nil {
+				return err
+			}
+		case <-locations.C:
+			d.next++
+			if d.next >= len(d.route) {
+				switch {
+				case d.loop:
+					d.next = 0
+				case d.hold:
+					d.next = len(d.route) - 1
+					d.holding = true
+				default:
+					return nil
+				}
+			}
+			if err := d.sendLocation(d.next); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// closeLinks closes the connections of the current session
+func (d *device) closeLinks() {
+	d.linkMu.Lock()
+	defer d.linkMu.Unlock()
+	for _, l := range d.links {
+		l.conn.Close()
+	}
+}
+
+// login sends the login packet on l and waits for the server response,
+// resending it up to the login retries of the firmware
+func (d *device) login(l *link) error {
+	login := packet.NewLoginPacket(d.imei, d.firmware.model, types.Timezone{
+		OffsetMinutes: d.firmware.timezone,
+		Language:      protocol.LanguageEnglish,
+	})
+	login.SerialNum = d.nextSerial()
+	data, err := d.enc.Login(login)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := l.write(data); err != nil {
+			return err
+		}
+		sentPackets.Add(1)
+
+		err := d.awaitLogin(l.conn)
+		if err == nil {
+			return nil
+		}
+		if attempt == d.firmware.loginRetries || !errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("no login response: %w", err)
+		}
+		log.Printf("[%s] No login response, retrying", d.imei)
+	}
+}
+
+// awaitLogin reads from conn until the login response arrives
+func (d *device) awaitLogin(conn net.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(loginTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	decoder := jimi.NewDecoder(d.firmware.decoderOptions()...)
+	buf := make([]byte, 1024)
+	var stream []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		stream = append(stream, buf[:n]...)
+		frames, residue, _ := decoder.SplitPackets(stream)
+		stream = residue
+		for _, f := range frames {
+			receivedPackets.Add(1)
+			if proto, _ := decoder.GetProtocolNumber(f); proto == protocol.ProtocolLogin {
+				if *verbose {
+					log.Printf("[%s] Logged in", d.imei)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// readLoop handles server packets on l until the connection closes
+func (d *device) readLoop(l *link) error {
+	decoder := jimi.NewDecoder(d.firmware.decoderOptions()...)
+	buf := make([]byte, 4096)
+	var stream []byte
+	for {
+		n, err := l.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("server closed the connection")
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		stream = append(stream, buf[:n]...)
+		frames, residue, _ := decoder.SplitPackets(stream)
+		stream = residue
+
+		for _, f := range frames {
+			receivedPackets.Add(1)
+			proto, _ := decoder.GetProtocolNumber(f)
+			if *verbose {
+				log.Printf("[%s] RX %X", d.imei, f)
+			}
+			if proto != protocol.ProtocolOnlineCommand {
+				continue
+			}
+			pkt, err := decoder.Decode(f)
+			if err != nil {
+				log.Printf("[%s] Invalid command: %v", d.imei, err)
+				continue
+			}
+			if err := d.answer(pkt.(*packet.OnlineCommandPacket)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// answer replies to an online command
+func (d *device) answer(cmd *packet.OnlineCommandPacket) error {
+	d.posMu.Lock()
+	pos := d.pos
+	d.posMu.Unlock()
+
+	resp := packet.NewCommandResponsePacket(cmd.ServerFlag, d.firmware.reply(cmd.Command, pos))
+	resp.SerialNum = d.nextSerial()
+	log.Printf("[%s] Command %q -> %q", d.imei, cmd.Command, resp.Response)
+	commandsAnswered.Add(1)
+	return d.send(d.enc.CommandResponse(resp))
+}
+
+// reply returns a plausible device reply to an SMS-style command
+func (fw firmware) reply(command string, pos point) string {
+	name, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimSpace(command), "#"), ",")
+	switch strings.ToUpper(name) {
+	case "WHERE", "URL":
+		return fmt.Sprintf("Lat:%.6f,Lon:%.6f,Speed:%.0f", pos.lat, pos.lon, math.Max(pos.speed, 0))
+	case "STATUS":
+		return "Battery:100%,GPRS:Link Up,GSM Signal Level:Strong,GPS:Successful positioning,ACC:ON"
+	case "VERSION":
+		return "[VERSION]" + fw.version
+	case "PARAM", "GPRSSET":
+		return fmt.Sprintf("TIMER:%d;HBT:%d", int(fw.interval.Seconds()), int(fw.heartbeat.Minutes()))
+	default:
+		return "OK!"
+	}
+}
+
+// sendLocation sends route point i as a location packet
+func (d *device) sendLocation(i int) error {
+	p := d.route[i]
+	if p.speed < 0 || p.course < 0 {
+		var prev point
+		if i > 0 {
+			prev = d.route[i-1]
+		} else {
+			prev = p
+		}
+		if p.speed < 0 {
+			p.speed = distance(prev, p) / d.firmware.interval.Seconds() * 3.6
+		}
+		if p.course < 0 {
+			p.course = bearing(prev, p)
+		}
+	}
+
+	d.posMu.Lock()
+	d.pos = p
+	d.posMu.Unlock()
+
+	coords, err := types.NewCoordinates(p.lat, p.lon)
+	if err != nil {
+		return err
+	}
+	course := types.NewCourseStatus(uint16(math.Round(p.course))%360, true, true, coords.IsEast, coords.IsNorth)
+	loc := packet.NewLocationPacket(types.NewDateTime(time.Now()), coords, uint16(math.Min(math.Round(p.speed), 255)), course)
+	loc.SerialNum = d.nextSerial()
+	loc.Satellites = 9
+	loc.ACC = true
+	loc.UploadMode = protocol.UploadModeInterval
+
+	if d.firmware.use4G {
+		loc.ProtocolNum = protocol.ProtocolGPSLocation4G
+		err = d.send(d.enc.Location4G(&packet.Location4GPacket{LocationPacket: *loc}))
+	} else {
+		err = d.send(d.enc.Location(loc))
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.holding {
+		return nil // alarms were sent when the point was reached
+	}
+	for _, a := range p.alarms {
+		if err := d.sendAlarm(loc, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendAlarm sends an alarm at the fix of loc (0x26, or 0xA4 with -4g)
+func (d *device) sendAlarm(loc *packet.LocationPacket, a protocol.AlarmType) error {
+	alarm := packet.NewAlarmPacket(loc.DateTime, loc.Coordinates, a)
+	alarm.SerialNum = d.nextSerial()
+	alarm.Satellites = loc.Satellites
+	alarm.Speed = uint8(loc.Speed)
+	alarm.CourseStatus = loc.CourseStatus
+	alarm.TerminalInfo = types.NewTerminalInfoBuilder().SetACCOn(true).SetGPSTracking(true).Build()
+	alarm.VoltageLevel = protocol.VoltageHigh
+	alarm.GSMSignal = protocol.SignalStrong
+	log.Printf("[%s] Alarm %s", d.imei, a)
+
+	if d.firmware.use4G {
+		alarm.ProtocolNum = protocol.ProtocolAlarmMultiFence4G
+		return d.send(d.enc.Alarm4G(&packet.Alarm4GPacket{AlarmPacket: *alarm}))
+	}
+	return d.send(d.enc.Alarm(alarm))
+}
+
+// sendHeartbeat sends a heartbeat with ACC on and GPS tracking enabled
+func (d *device) sendHeartbeat() error {
+	info := types.NewTerminalInfoBuilder().SetACCOn(true).SetGPSTracking(true).Build()
+	hb := packet.NewHeartbeatPacket(info, protocol.VoltageHigh, protocol.SignalStrong)
+	hb.SerialNum = d.nextSerial()
+	return d.send(d.enc.Heartbeat(hb))
+}
+
+// send queues one packet on a random connection
+func (d *device) send(data []byte) error {
+	d.linkMu.Lock()
+	l := d.links[rand.IntN(len(d.links))]
+	d.linkMu.Unlock()
+
+	select {
+	case l.queue <- data:
+		return nil
+	case <-l.done:
+		return net.ErrClosed
+	}
+}
+
+// nextSerial returns the next packet serial number
+func (d *device) nextSerial() uint16 {
+	d.serialMu.Lock()
+	defer d.serialMu.Unlock()
+
+	d.serial++
+	return d.serial
+}
+
+// deviceIMEIs returns n IMEIs counting up from base, with valid check digits
+func deviceIMEIs(base string, n int) ([]types.IMEI, error) {
+	if _, err := types.NewIMEIUnchecked(base); err != nil {
+		return nil, err
+	}
+	body, _ := strconv.ParseUint(base[:14], 10, 64)
+
+	imeis := make([]types.IMEI, n)
+	for i := range imeis {
+		digits := fmt.Sprintf("%014d", body+uint64(i))
+		if len(digits) > 14 {
+			return nil, fmt.Errorf("%d devices overflow IMEI %s", n, base)
+		}
+		imei, err := types.NewIMEI(digits + strconv.Itoa(luhnDigit(digits)))
+		if err != nil {
+			return nil, err
+		}
+		imeis[i] = imei
+	}
+	return imeis, nil
+}
+
+// luhnDigit returns the Luhn check digit for digits
+func luhnDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// loadRoute reads a CSV, GPX or GeoJSON route
+func loadRoute(path string) ([]point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var route []point
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpx":
+		route, err = readGPX(f)
+	case ".geojson", ".json":
+		route, err = readGeoJSON(f)
+	default:
+		route, err = readCSV(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(route) == 0 {
+		return nil, errors.New("route has no points")
+	}
+	return route, nil
+}
+
+// readCSV reads lat,lon[,speed[,course]] lines
+func readCSV(r io.Reader) ([]point, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var route []point
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return route, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("line %d: expected lat,lon[,speed[,course]]", line)
+		}
+
+		p := point{speed: -1, course: -1}
+		values := []*float64{&p.lat, &p.lon, &p.speed, &p.course}
+		var parseErr error
+		for i, field := range rec {
+			if i == len(values) || field == "" {
+				break
+			}
+			if *values[i], parseErr = strconv.ParseFloat(field, 64); parseErr != nil {
+				break
+			}
+		}
+		if parseErr != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: %w", line, parseErr)
+		}
+		route = append(route, p)
+	}
+}
+
+// gpxPoint is a GPX trkpt, rtept or wpt element
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// gpxFile holds the points of a GPX document
+type gpxFile struct {
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// readGPX reads track points, falling back to route points and waypoints
+func readGPX(r io.Reader) ([]point, error) {
+	var doc gpxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var pts []gpxPoint
+	for _, t := range doc.Tracks {
+		for _, s := range t.Segments {
+			pts = append(pts, s.Points...)
+		}
+	}
+	if len(pts) == 0 {
+		for _, rt := range doc.Routes {
+			pts = append(pts, rt.Points...)
+		}
+	}
+	if len(pts) == 0 {
+		pts = doc.Waypoints
+	}
+
+	route := make([]point, len(pts))
+	for i, p := range pts {
+		route[i] = point{lat: p.Lat, lon: p.Lon, speed: -1, course: -1}
+	}
+	return route, nil
+}
+
+// geoJSONObject is a GeoJSON FeatureCollection, Feature or geometry
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Features    []geoJSONObject `json:"features"`
+	Geometry    *geoJSONObject  `json:"geometry"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Properties  struct {
+		Speed  *float64 `json:"speed"`
+		Course *float64 `json:"course"`
+	} `json:"properties"`
+}
+
+// readGeoJSON reads the positions of LineString and MultiLineString
+// geometries, falling back to Point features with their speed and course
+// properties (as written by package geojson)
+func readGeoJSON(r io.Reader) ([]point, error) {
+	var doc geoJSONObject
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var lines, points []point
+	var walk func(obj geoJSONObject, props geoJSONObject) error
+	walk = func(obj geoJSONObject, props geoJSONObject) error {
+		switch obj.Type {
+		case "FeatureCollection":
+			for _, f := range obj.Features {
+				if err := walk(f, f); err != nil {
+					return err
+				}
+			}
+		case "Feature":
+			if obj.Geometry != nil {
+				return walk(*obj.Geometry, obj)
+			}
+		case "LineString":
+			var coords [][]float64
+			if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+				return fmt.Errorf("LineString: %w", err)
+			}
+			for _, c := range coords {
+				p, err := geoJSONPoint(c)
+				if err != nil {
+					return err
+				}
+				lines = append(lines, p)
+			}
+		case "MultiLineString":
+			var coords [][][]float64
+			if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+				return fmt.Errorf("MultiLineString: %w", err)
+			}
+			for _, line := range coords {
+				for _, c := range line {
+					p, err := geoJSONPoint(c)
+					if err != nil {
+						return err
+					}
+					lines = append(lines, p)
+				}
+			}
+		case "Point":
+			var c []float64
+			if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+				return fmt.Errorf("Point: %w", err)
+			}
+			p, err := geoJSONPoint(c)
+			if err != nil {
+				return err
+			}
+			if v := props.Properties.Speed; v != nil {
+				p.speed = *v
+			}
+			if v := props.Properties.Course; v != nil {
+				p.course = *v
+			}
+			points = append(points, p)
+		}
+		return nil
+	}
+	if err := walk(doc, doc); err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		return lines, nil
+	}
+	return points, nil
+}
+
+// geoJSONPoint converts a [longitude, latitude] position
+func geoJSONPoint(c []float64) (point, error) {
+	if len(c) < 2 {
+		return point{}, fmt.Errorf("invalid position %v", c)
+	}
+	return point{lat: c[1], lon: c[0], speed: -1, course: -1}, nil
+}
+
+// distance returns the distance between two points in meters
+func distance(a, b point) float64 {
+	ca, _ := types.NewCoordinates(a.lat, a.lon)
+	cb, _ := types.NewCoordinates(b.lat, b.lon)
+	return ca.DistanceTo(cb)
+}
+
+// bearing returns the initial course from a to b in degrees (0 = north)
+func bearing(a, b point) float64 {
+	lat1, lat2 := a.lat*math.Pi/180, b.lat*math.Pi/180
+	dlon := (b.lon - a.lon) * math.Pi / 180
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Scenario flags
+var (
+	scenarioFile = flag.String("scenario", "", "JSON or YAML scenario of vehicles with routes, speeds, stops, alarms, firmware profiles and failure modes (replaces -route and -devices)")
+)
+
+// scenario scripts a fleet of simulated vehicles, e.g.:
+//
+//	{"vehicles": [{
+//	    "name": "van", "devices": 5, "route": "depot.geojson", "loop": true,
+//	    "speeds": [{"from": 0, "speed": 40}, {"from": 12, "speed": 90}],
+//	    "stops": [{"at": 12, "for": "5m"}],
+//	    "alarms": [{"at": 20, "alarm": "harsh braking"}]
+//	}]}
+//
+// or the same in YAML (.yaml or .yml, see yaml.go). Route paths are relative
+// to the scenario file. Point indexes ("from", "at") count route points from
+// 0. Vehicles may name a firmware profile and a failure mode defined in the
+// scenario; the settings they leave out keep the value of the flags.
+type scenario struct {
+	// Profiles are the firmware profiles by name
+	Profiles map[string]profile `json:"profiles"`
+
+	// Failures are the network failure modes by name
+	Failures map[string]failure `json:"failures"`
+
+	Vehicles []vehicle `json:"vehicles"`
+}
+
+// profile is a firmware profile
+type profile struct {
+	Model        *scalar   `json:"model"`    // model identification code, e.g. 0x4D01
+	Timezone     *int      `json:"timezone"` // minutes, sent at login
+	FourG        *bool     `json:"4g"`       // 4G location and alarm packets
+	Interval     *duration `json:"interval"`
+	Heartbeat    *duration `json:"heartbeat"` // "0s" disables heartbeats
+	LoginRetries *int      `json:"login_retries"`
+	CRC          string    `json:"crc"`     // x25 (default) or itu
+	Version      string    `json:"version"` // reply to VERSION#
+}
+
+// apply returns fw with the settings of the profile
+func (p profile) apply(fw firmware) (firmware, error) {
+	if p.Model != nil {
+		v, err := strconv.ParseUint(string(*p.Model), 0, 16)
+		if err != nil {
+			return fw, fmt.Errorf("invalid model %q", *p.Model)
+		}
+		fw.model = uint16(v)
+	}
+	if p.Timezone != nil {
+		fw.timezone = *p.Timezone
+	}
+	if p.FourG != nil {
+		fw.use4G = *p.FourG
+	}
+	if p.Interval != nil {
+		if *p.Interval <= 0 {
+			return fw, errors.New("interval must be positive")
+		}
+		fw.interval = time.Duration(*p.Interval)
+	}
+	if p.Heartbeat != nil {
+		fw.heartbeat = time.Duration(*p.Heartbeat)
+	}
+	if p.LoginRetries != nil {
+		fw.loginRetries = *p.LoginRetries
+	}
+	switch strings.ToLower(p.CRC) {
+	case "":
+	case "x25":
+		fw.crc = nil
+	case "itu":
+		fw.crc = jimi.CRCITU
+	default:
+		return fw, fmt.Errorf("unknown crc %q (x25 or itu)", p.CRC)
+	}
+	if p.Version != "" {
+		fw.version = p.Version
+	}
+	return fw, nil
+}
+
+// failure is a network failure mode (see the impairment flags)
+type failure struct {
+	Latency   *duration `json:"latency"`
+	Jitter    *duration `json:"jitter"`
+	Fragment  *bool     `json:"fragment"`
+	Sockets   *int      `json:"sockets"`
+	Drop      *float64  `json:"drop"`
+	Reconnect *duration `json:"reconnect"`
+}
+
+// apply returns m with the settings of the failure mode
+func (f failure) apply(m impairment) (impairment, error) {
+	if f.Latency != nil {
+		m.latency = time.Duration(*f.Latency)
+	}
+	if f.Jitter != nil {
+		m.jitter = time.Duration(*f.Jitter)
+	}
+	if f.Fragment != nil {
+		m.fragment = *f.Fragment
+	}
+	if f.Sockets != nil {
+		m.sockets = *f.Sockets
+	}
+	if f.Drop != nil {
+		m.drop = *f.Drop
+	}
+	if f.Reconnect != nil {
+		m.reconnect = time.Duration(*f.Reconnect)
+	}
+	return m, m.validate()
+}
+
+// vehicle is one scripted vehicle, simulated by one or more devices
+type vehicle struct {
+	// Name identifies the vehicle in errors and logs
+	Name string `json:"name"`
+
+	// IMEI of the first device, the others counting up from it; by default
+	// the devices continue from -imei
+	IMEI scalar `json:"imei"`
+
+	// Devices is the number of devices driving the vehicle (default 1)
+	Devices int `json:"devices"`
+
+	// Route is a CSV, GPX or GeoJSON route (default the -lat/-lon position)
+	Route string `json:"route"`
+
+	// Loop restarts the route when it ends. A vehicle without route keeps
+	// reporting its position.
+	Loop bool `json:"loop"`
+
+	// Start delays the connection of the devices
+	Start duration `json:"start"`
+
+	// Speeds is the speed profile. Without it every route point is one fix;
+	// with it the vehicle drives along the route and reports a fix every
+	// -interval.
+	Speeds []speedStep `json:"speeds"`
+
+	// Stops hold the vehicle at route points with speed 0
+	Stops []stopStep `json:"stops"`
+
+	// Alarms are sent when the vehicle reaches route points
+	Alarms []alarmStep `json:"alarms"`
+
+	// Profile names the firmware profile of the devices
+	Profile string `json:"profile"`
+
+	// Failure names the failure mode of the connections of the devices
+	Failure string `json:"failure"`
+}
+
+// speedStep sets the speed from a route point on
+type speedStep struct {
+	From  int     `json:"from"`
+	Speed float64 `json:"speed"` // km/h
+}
+
+// stopStep holds the vehicle at a route point
+type stopStep struct {
+	At  int      `json:"at"`
+	For duration `json:"for"`
+}
+
+// alarmStep sends an alarm at a route point
+type alarmStep struct {
+	At    int    `json:"at"`
+	Alarm string `json:"alarm"` // name (e.g. "SOS", "harsh_braking") or value (e.g. "0x30")
+}
+
+// duration is a time.Duration read from a JSON string such as "5m"
+type duration time.Duration
+
+// scalar is a string that may also be written as a number, so YAML files
+// may leave IMEIs and model codes unquoted
+type scalar string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *scalar) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		*s = scalar(n)
+		return nil
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = scalar(v)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// loadScenario reads a JSON or YAML scenario and returns the devices it
+// simulates
+func loadScenario(path string) ([]deviceSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	var sc scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	if len(sc.Vehicles) == 0 {
+		return nil, errors.New("scenario has no vehicles")
+	}
+
+	// Vehicles without an IMEI share the IMEIs counting up from -imei
+	auto := 0
+	for i := range sc.Vehicles {
+		v := &sc.Vehicles[i]
+		if v.Name == "" {
+			v.Name = fmt.Sprintf("vehicle %d", i+1)
+		}
+		if v.Devices == 0 {
+			v.Devices = 1
+		}
+		if v.Devices < 0 {
+			return nil, fmt.Errorf("%s: devices must be at least 1", v.Name)
+		}
+		if v.IMEI == "" {
+			auto += v.Devices
+		}
+	}
+	autoIMEIs, err := deviceIMEIs(*baseIMEI, auto)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -imei: %w", err)
+	}
+
+	var specs []deviceSpec
+	seen := make(map[string]bool)
+	for _, v := range sc.Vehicles {
+		fw, imp := flagFirmware(), flagImpairment()
+		if v.Profile != "" {
+			p, ok := sc.Profiles[v.Profile]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown profile %q", v.Name, v.Profile)
+			}
+			if fw, err = p.apply(fw); err != nil {
+				return nil, fmt.Errorf("%s: profile %s: %w", v.Name, v.Profile, err)
+			}
+		}
+		if v.Failure != "" {
+			f, ok := sc.Failures[v.Failure]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown failure mode %q", v.Name, v.Failure)
+			}
+			if imp, err = f.apply(imp); err != nil {
+				return nil, fmt.Errorf("%s: failure mode %s: %w", v.Name, v.Failure, err)
+			}
+		}
+
+		route := []point{fixedPoint()}
+		if v.Route != "" {
+			file := v.Route
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			if route, err = loadRoute(file); err != nil {
+				return nil, fmt.Errorf("%s: %w", v.Name, err)
+			}
+		}
+		timeline, err := v.timeline(route, fw.interval)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+
+		var imeis []types.IMEI
+		if v.IMEI != "" {
+			if imeis, err = deviceIMEIs(string(v.IMEI), v.Devices); err != nil {
+				return nil, fmt.Errorf("%s: invalid IMEI: %w", v.Name, err)
+			}
+		} else {
+			imeis, autoIMEIs = autoIMEIs[:v.Devices], autoIMEIs[v.Devices:]
+		}
+		for i, imei := range imeis {
+			if seen[imei.String()] {
+				return nil, fmt.Errorf("%s: IMEI %s is already simulated", v.Name, imei)
+			}
+			seen[imei.String()] = true
+
+			// Spread the connections of the vehicle over one interval
+			start := time.Duration(v.Start) + fw.interval*time.Duration(i)/time.Duration(len(imeis))
+			specs = append(specs, deviceSpec{
+				imei: imei, route: timeline, loop: v.Loop, hold: v.Route == "", start: start,
+				firmware: fw, impairment: imp,
+			})
+		}
+		if *verbose {
+			log.Printf("%s: %d route points, %d fixes, %d device(s)", v.Name, len(route), len(timeline), len(imeis))
+			if imp.active() {
+				log.Printf("%s: network impairment: %s", v.Name, imp)
+			}
+		}
+	}
+	return specs, nil
+}
+
+// timeline returns the fixes the vehicle reports along route, one every
+// step, with the stops and alarms of the scenario
+func (v vehicle) timeline(route []point, step time.Duration) ([]point, error) {
+	stops := make(map[int]time.Duration)
+	for _, s := range v.Stops {
+		if s.At < 0 || s.At >= len(route) {
+			return nil, fmt.Errorf("stop at point %d: route has %d points", s.At, len(route))
+		}
+		stops[s.At] += time.Duration(s.For)
+	}
+	alarms := make(map[int][]protocol.AlarmType)
+	for _, a := range v.Alarms {
+		if a.At < 0 || a.At >= len(route) {
+			return nil, fmt.Errorf("alarm at point %d: route has %d points", a.At, len(route))
+		}
+		t, err := parseAlarm(a.Alarm)
+		if err != nil {
+			return nil, err
+		}
+		alarms[a.At] = append(alarms[a.At], t)
+	}
+	for _, s := range v.Speeds {
+		if s.From < 0 || s.From >= len(route) {
+			return nil, fmt.Errorf("speed from point %d: route has %d points", s.From, len(route))
+		}
+		if s.Speed <= 0 {
+			return nil, fmt.Errorf("speed from point %d must be positive", s.From)
+		}
+	}
+
+	var out []point
+	// arrive adds the fix at route point i with its alarms, then its stop
+	arrive := func(p point, i int) {
+		p.alarms = alarms[i]
+		out = append(out, p)
+		if d := stops[i]; d > 0 {
+			stopped := point{lat: p.lat, lon: p.lon, speed: 0, course: p.course}
+			if stopped.course < 0 && i > 0 {
+				stopped.course = bearing(route[i-1], route[i])
+			}
+			for range int((d + step - 1) / step) {
+				out = append(out, stopped)
+			}
+		}
+	}
+
+	if len(v.Speeds) == 0 {
+		for i, p := range route {
+			arrive(p, i)
+		}
+		return out, nil
+	}
+
+	// Drive along the route at the speed of the current segment, reporting
+	// the position every step and every stop or alarm point reached
+	// speedAt returns the speed of the last step starting at or before point
+	// i (the first step applies before it starts)
+	speedAt := func(i int) float64 {
+		first, last := v.Speeds[0], speedStep{From: -1}
+		for _, s := range v.Speeds {
+			if s.From < first.From {
+				first = s
+			}
+			if s.From <= i && s.From > last.From {
+				last = s
+			}
+		}
+		if last.From < 0 {
+			return first.Speed
+		}
+		return last.Speed
+	}
+	course := func(i int) float64 {
+		if i+1 < len(route) {
+			return bearing(route[i], route[i+1])
+		}
+		if i > 0 {
+			return bearing(route[i-1], route[i])
+		}
+		return 0
+	}
+
+	pos, seg := route[0], 0
+	arrive(point{lat: pos.lat, lon: pos.lon, speed: speedAt(0), course: course(0)}, 0)
+	for seg < len(route)-1 {
+		remaining := step.Seconds()
+		reached := -1
+		for remaining > 0 && seg < len(route)-1 {
+			mps := speedAt(seg) / 3.6
+			next := route[seg+1]
+			d := distance(pos, next)
+			if d > mps*remaining {
+				f := mps * remaining / d
+				pos = point{lat: pos.lat + (next.lat-pos.lat)*f, lon: pos.lon + (next.lon-pos.lon)*f}
+				break
+			}
+			remaining -= d / mps
+			pos, seg = next, seg+1
+			if stops[seg] > 0 || len(alarms[seg]) > 0 {
+				reached = seg
+				break
+			}
+		}
+
+		fix := point{lat: pos.lat, lon: pos.lon, speed: speedAt(seg), course: course(seg)}
+		if seg == len(route)-1 {
+			fix.course = course(seg - 1)
+		}
+		if reached >= 0 {
+			arrive(fix, reached)
+		} else {
+			out = append(out, fix)
+		}
+	}
+	return out, nil
+}
+
+// parseAlarm parses an alarm type from its name (case-insensitive, with
+// spaces, '_' or '-' separators, e.g. "SOS" or "harsh_braking") or its value
+// (e.g. "0x30")
+func parseAlarm(s string) (protocol.AlarmType, error) {
+	name := strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSpace(s))
+	for v := range math.MaxUint8 + 1 {
+		a := protocol.AlarmType(v)
+		if strings.EqualFold(a.String(), name) {
+			return a, nil
+		}
+	}
+	if v, err := strconv.ParseUint(strings.TrimSpace(s), 0, 8); err == nil {
+		return protocol.AlarmType(v), nil
+	}
+	return 0, fmt.Errorf("unknown alarm %q", s)
+}
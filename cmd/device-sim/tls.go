@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLS flags
+var (
+	useTLS        = flag.Bool("tls", false, "Connect over TLS (implied by the other -tls flags)")
+	tlsCA         = flag.String("tls-ca", "", "Verify the server certificate against this PEM CA (default: system roots)")
+	tlsCert       = flag.String("tls-cert", "", "Present this PEM client certificate (requires -tls-key)")
+	tlsKey        = flag.String("tls-key", "", "PEM private key of -tls-cert")
+	tlsServerName = flag.String("tls-server-name", "", "Server name to verify (default: the host of -addr)")
+	tlsInsecure   = flag.Bool("tls-insecure", false, "Do not verify the server certificate")
+)
+
+// clientTLS is the TLS configuration of the connections, nil for plain TCP
+var clientTLS *tls.Config
+
+// loadClientTLS builds the TLS configuration from the TLS flags, or returns
+// nil when none is set
+func loadClientTLS() (*tls.Config, error) {
+	if !*useTLS && *tlsCA == "" && *tlsCert == "" && *tlsServerName == "" && !*tlsInsecure {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: *tlsServerName, InsecureSkipVerify: *tlsInsecure}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(*addr)
+		if err != nil {
+			return nil, fmt.Errorf("-addr: %w", err)
+		}
+		config.ServerName = host
+	}
+
+	if *tlsCA != "" {
+		pem, err := os.ReadFile(*tlsCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", *tlsCA)
+		}
+		config.RootCAs = pool
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return nil, errors.New("-tls-cert and -tls-key go together")
+	}
+	if *tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// dial connects to the server, over TLS when configured
+func dial() (net.Conn, error) {
+	if clientTLS == nil {
+		return net.Dial("tcp", *addr)
+	}
+	return tls.Dial("tcp", *addr, clientTLS)
+}
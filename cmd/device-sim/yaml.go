@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON converts a YAML scenario to JSON, so both go through the same
+// decoding. It reads the subset of YAML configuration files use: block
+// mappings and sequences (including "- key: value" items), flow sequences
+// and mappings on one line ("[a, b]", "{at: 3, alarm: SOS}"), plain, single-
+// and double-quoted scalars, and comments. Anchors, tags and multi-line
+// scalars are rejected.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed in indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	p := &yamlParser{lines: lines}
+	var v any
+	if len(lines) > 0 {
+		var err error
+		if v, err = p.block(lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.pos < len(lines) {
+			return nil, p.errorf("unexpected indentation")
+		}
+	}
+	return json.Marshal(v)
+}
+
+// yamlLine is a non-empty line without its comment
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlParser parses block structures line by line
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// errorf returns an error at the current line
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("yaml line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parses the mapping or sequence starting at the current line
+func (p *yamlParser) block(indent int) (any, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses "- item" lines at indent
+func (p *yamlParser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent || !isSequenceItem(line.text) {
+			return nil, p.errorf("expected a sequence item")
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		switch {
+		case rest == "":
+			// Nested block on the next lines
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		case isSequenceItem(rest) || mappingKey(rest) >= 0:
+			// "- key: value" opens a mapping (or "- - x" a sequence) indented
+			// at the position of its first entry
+			p.lines[p.pos] = yamlLine{num: line.num, indent: line.indent + len(line.text) - len(rest), text: rest}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		default:
+			v, err := p.value(rest)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+// mapping parses "key: value" lines at indent
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		colon := mappingKey(line.text)
+		if colon < 0 {
+			return nil, p.errorf("expected \"key: value\"")
+		}
+		key, err := p.key(line.text[:colon])
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		rest := strings.TrimSpace(line.text[colon+1:])
+		if rest != "" {
+			if m[key], err = p.value(rest); err != nil {
+				return nil, err
+			}
+			p.pos++
+			continue
+		}
+		p.pos++
+
+		// Nested block, or a sequence at the indentation of the key
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			m[key], err = p.block(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text):
+			m[key], err = p.sequence(indent)
+		default:
+			m[key] = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// key returns a mapping key, unquoting it
+func (p *yamlParser) key(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		v, rest, err := quoted(s)
+		if err != nil || strings.TrimSpace(rest) != "" {
+			return "", p.errorf("invalid key %s", s)
+		}
+		return v, nil
+	}
+	return s, nil
+}
+
+// value parses the value after "key:" or "- "
+func (p *yamlParser) value(s string) (any, error) {
+	switch s[0] {
+	case '|', '>':
+		return nil, p.errorf("multi-line scalars are not supported")
+	case '&', '*', '!':
+		return nil, p.errorf("anchors, aliases and tags are not supported")
+	case '[', '{':
+		v, rest, err := flow(s)
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected %q", rest)
+		}
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return v, nil
+	case '"', '\'':
+		v, rest, err := quoted(s)
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected %q after the string", rest)
+		}
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return v, nil
+	}
+	return plain(s), nil
+}
+
+// flow parses a flow sequence or mapping at the start of s and returns the
+// rest of s
+func flow(s string) (any, string, error) {
+	closing := byte(']')
+	if s[0] == '{' {
+		closing = '}'
+	}
+	var items []any
+	m := map[string]any{}
+	s = strings.TrimLeft(s[1:], " ")
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("missing %q", closing)
+		}
+		if s[0] == closing {
+			if closing == ']' {
+				if items == nil {
+					items = []any{}
+				}
+				return items, s[1:], nil
+			}
+			return m, s[1:], nil
+		}
+
+		var v any
+		var err error
+		if closing == '}' {
+			var key any
+			if key, s, err = flowScalar(s, ":"); err != nil {
+				return nil, "", err
+			}
+			if !strings.HasPrefix(s, ":") {
+				return nil, "", fmt.Errorf("expected \":\" after %v", key)
+			}
+			s = strings.TrimLeft(s[1:], " ")
+			if v, s, err = flowValue(s, ",}"); err != nil {
+				return nil, "", err
+			}
+			m[fmt.Sprint(key)] = v
+		} else {
+			if v, s, err = flowValue(s, ",]"); err != nil {
+				return nil, "", err
+			}
+			items = append(items, v)
+		}
+
+		s = strings.TrimLeft(s, " ")
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimLeft(s[1:], " ")
+		} else if s == "" || s[0] != closing {
+			return nil, "", fmt.Errorf("expected \",\" or %q", closing)
+		}
+	}
+}
+
+// flowValue parses a nested flow collection or a scalar ending at one of
+// stops
+func flowValue(s, stops string) (any, string, error) {
+	if s != "" && (s[0] == '[' || s[0] == '{') {
+		return flow(s)
+	}
+	return flowScalar(s, stops)
+}
+
+// flowScalar parses a quoted scalar, or a plain one ending at one of stops
+func flowScalar(s, stops string) (any, string, error) {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		v, rest, err := quoted(s)
+		return v, strings.TrimLeft(rest, " "), err
+	}
+	end := strings.IndexAny(s, stops)
+	if end < 0 {
+		end = len(s)
+	}
+	return plain(strings.TrimSpace(s[:end])), s[end:], nil
+}
+
+// quoted parses the single- or double-quoted string at the start of s and
+// returns the rest of s
+func quoted(s string) (string, string, error) {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			if q == '\'' {
+				return strings.ReplaceAll(s[1:i], "''", "'"), s[i+1:], nil
+			}
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+// plain resolves a plain scalar: null, a boolean, a number or a string.
+// Numbers stay exact (json.Number), so long IMEIs are not rounded.
+func plain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		base = 0
+	}
+	if v, err := strconv.ParseInt(s, base, 64); err == nil {
+		return json.Number(strconv.FormatInt(v, 10))
+	}
+	if strings.Trim(s, "+-.0123456789eE") == "" && strings.ContainsAny(s, "0123456789") {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	return s
+}
+
+// isSequenceItem reports whether a line is a "- item"
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// mappingKey returns the index of the colon ending the key of a "key: value"
+// line, or -1
+func mappingKey(text string) int {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return -1
+	}
+	i := 0
+	if text[0] == '"' || text[0] == '\'' {
+		_, rest, err := quoted(text)
+		if err != nil {
+			return -1
+		}
+		i = len(text) - len(rest)
+	}
+	for ; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes a "#" comment outside quoted strings
+func stripComment(line string) string {
+	var q byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case q != 0:
+			if c == '\\' && q == '"' {
+				i++
+			} else if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(line[i-1])) {
+				q = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
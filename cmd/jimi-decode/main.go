@@ -0,0 +1,451 @@
+// jimi-decode inspects Jimi VL103M GPS Tracker packets
+//
+// A protocol debugger for support engineers: every argument is a hex string
+// or a file, and each frame found in them is printed decoded, with the
+// reason it failed otherwise. With no argument the input is read from stdin.
+//
+// Accepted inputs:
+//   - Hex strings:         787811010359339073930523044D01F4000168DB0D0A
+//   - Binary captures:     raw bytes as received, e.g. a tcpdump payload
+//   - Text files:          one hex packet per line, '#' comments allowed
+//   - tcp-server raw logs: [2024-06-15 14:30:45.000] RX 787811...
+//
+// Binary input is detected by its non-text bytes; bytes between frames are
+// reported as discarded. Several frames on one line are split. From raw logs
+// only the received (RX) frames are decoded, unless -tx is set.
+//
+// -format selects the output: human (default), json (one object per frame
+// and line, with the packet in its JSON form) or table (one row per frame).
+//
+// With -annotate each frame is followed by its fields, byte by byte (offset,
+// raw bytes, name and value, see jimi.Decoder.Explain), even when it does
+// not decode; in table format the rows are the fields instead of the frames.
+//
+// With -protocol the protocol number of every frame is replaced before
+// decoding, e.g. to read an undocumented firmware packet with the parser of
+// a known one. The checksum of a frame that had a valid one is recomputed.
+//
+// Usage:
+//
+//	jimi-decode 787811010359339073930523044D01F4000168DB0D0A
+//	jimi-decode -annotate capture.bin
+//	jimi-decode -format table logs/raw_2024-06-15.log
+//	jimi-decode -format json -skip-crc capture.txt | jq .packet
+//	jimi-decode -protocol gps_location_4g 7878...0D0A
+//	cat capture.txt | jimi-decode -tx
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Configuration flags
+var (
+	format    = flag.String("format", "human", "Output format: human, json or table")
+	annotate  = flag.Bool("annotate", false, "Show the fields of each frame byte by byte")
+	skipCRC   = flag.Bool("skip-crc", false, "Skip CRC validation")
+	lenient   = flag.Bool("lenient", false, "Enable lenient decoding (unknown protocols, no IMEI checksum)")
+	forceProt = flag.String("protocol", "", "Decode every frame as this protocol, by name or number (e.g. gps_location, 0x22)")
+	withTX    = flag.Bool("tx", false, "Also decode the frames sent to devices (TX lines of raw logs)")
+)
+
+// frame is a packet candidate found in the input
+type frame struct {
+	source    string // where it was found, e.g. "capture.txt:12"
+	direction string // RX or TX for raw logs, "" otherwise
+	data      []byte
+	packet    packet.Packet
+	err       error
+	fields    []jimi.Field
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [hex|file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+
+	switch *format {
+	case "human", "json", "table":
+	default:
+		log.Fatalf("Invalid -format %q (human, json or table)", *format)
+	}
+
+	var force *byte
+	if *forceProt != "" {
+		n, err := protocol.ParseNumber(*forceProt)
+		if err != nil {
+			log.Fatalf("Invalid -protocol: %v", err)
+		}
+		b := byte(n)
+		force = &b
+	}
+
+	var frames []*frame
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Failed to read stdin: %v", err)
+		}
+		frames = readInput("stdin", data)
+	}
+	for i, arg := range flag.Args() {
+		data, err := os.ReadFile(arg)
+		switch {
+		case err == nil:
+			frames = append(frames, readInput(arg, data)...)
+		case os.IsNotExist(err):
+			frames = append(frames, hexFrames(fmt.Sprintf("arg %d", i+1), "", arg)...)
+		default:
+			log.Fatalf("Failed to read %s: %v", arg, err)
+		}
+	}
+
+	decoder := jimi.NewDecoder(decoderOptions()...)
+	failed := 0
+	for _, f := range frames {
+		if f.err == nil && force != nil {
+			f.data = forceProtocol(f.data, *force)
+		}
+		if len(f.data) > 0 {
+			exp, err := decoder.Explain(f.data)
+			if f.err == nil {
+				f.packet, f.err = exp.Packet, err
+			}
+			if *annotate {
+				f.fields = exp.Fields
+			}
+		}
+		if f.err != nil {
+			failed++
+		}
+	}
+
+	var err error
+	switch *format {
+	case "json":
+		err = printJSON(os.Stdout, frames)
+	case "table":
+		err = printTable(os.Stdout, frames)
+	default:
+		err = printHuman(os.Stdout, frames)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+
+	log.Printf("Decoded %d/%d frames (%d errors)", len(frames)-failed, len(frames), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// decoderOptions builds decoder options from flags
+func decoderOptions() []jimi.Option {
+	var opts []jimi.Option
+	if *skipCRC {
+		opts = append(opts, jimi.WithSkipCRC())
+	}
+	if *lenient {
+		opts = append(opts, jimi.WithLenientMode())
+	}
+	return opts
+}
+
+// readInput finds the frames of a file or of stdin, binary or text
+func readInput(name string, data []byte) []*frame {
+	if isBinary(data) {
+		return binaryFrames(name, data)
+	}
+
+	var frames []*frame
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		direction, h := extractHex(scanner.Text())
+		if h == "" || direction == "TX" && !*withTX {
+			continue
+		}
+		frames = append(frames, hexFrames(fmt.Sprintf("%s:%d", name, number), direction, h)...)
+	}
+	if err := scanner.Err(); err != nil {
+		frames = append(frames, &frame{source: name, err: err})
+	}
+	return frames
+}
+
+// isBinary reports whether data is a raw capture rather than text
+func isBinary(data []byte) bool {
+	if !utf8.Valid(data) {
+		return true
+	}
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			return true
+		}
+	}
+	return false
+}
+
+// extractHex returns the direction ("" if none) and the hex payload of a
+// line ("" for blank and comment lines)
+func extractHex(line string) (direction, h string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+
+	// tcp-server raw log format: [timestamp] DIRECTION hex
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end >= 0 {
+			fields := strings.Fields(line[end+1:])
+			if len(fields) < 2 {
+				return "", ""
+			}
+			return fields[0], fields[len(fields)-1]
+		}
+	}
+	return "", line
+}
+
+// hexFrames parses a hex packet, split into frames if it holds several
+func hexFrames(source, direction, h string) []*frame {
+	data, err := jimi.ParseHex(h)
+	if err != nil {
+		return []*frame{{source: source, direction: direction, err: err}}
+	}
+	packets, residue, err := jimi.NewDecoder().SplitPackets(data)
+	if err != nil || len(packets) < 2 {
+		// One frame, decoded as is so a damaged one gets its error
+		return []*frame{{source: source, direction: direction, data: data}}
+	}
+
+	frames := make([]*frame, 0, len(packets)+1)
+	for i, p := range packets {
+		frames = append(frames, &frame{source: fmt.Sprintf("%s#%d", source, i+1), direction: direction, data: p})
+	}
+	if len(residue) > 0 {
+		frames = append(frames, &frame{source: source, direction: direction, data: residue,
+			err: fmt.Errorf("%d trailing bytes are not a complete packet", len(residue))})
+	}
+	return frames
+}
+
+// binaryFrames splits a raw capture into frames, reporting the bytes
+// discarded between them
+func binaryFrames(name string, data []byte) []*frame {
+	// The splitter returns subslices of data, so their offset is known
+	type found struct {
+		offset int
+		frame  *frame
+	}
+	var all []found
+	add := func(b []byte, err error) {
+		offset := cap(data) - cap(b)
+		all = append(all, found{offset, &frame{source: fmt.Sprintf("%s@0x%X", name, offset), data: b, err: err}})
+	}
+
+	splitter := jimi.NewDecoder(jimi.WithResyncPolicy(jimi.ResyncPolicy{
+		OnDiscard: func(discarded []byte, reason string) {
+			add(discarded, fmt.Errorf("%d bytes discarded: %s", len(discarded), reason))
+		},
+	}))
+	packets, residue, err := splitter.SplitPackets(data)
+	for _, p := range packets {
+		add(p, nil)
+	}
+	if len(residue) > 0 {
+		add(residue, fmt.Errorf("%d trailing bytes are not a complete packet", len(residue)))
+	}
+	slices.SortStableFunc(all, func(a, b found) int { return cmp.Compare(a.offset, b.offset) })
+
+	frames := make([]*frame, 0, len(all)+1)
+	for _, f := range all {
+		frames = append(frames, f.frame)
+	}
+	if err != nil {
+		frames = append(frames, &frame{source: name, err: err})
+	}
+	return frames
+}
+
+// forceProtocol returns a copy of a frame with its protocol number replaced,
+// and its checksum recomputed if it was valid
+func forceProtocol(data []byte, num byte) []byte {
+	lengthSize := lengthFieldSize(data)
+	if lengthSize == 0 || len(data) < protocol.MinPacketSize {
+		return data
+	}
+	out := bytes.Clone(data)
+	out[2+lengthSize] = num
+
+	crc := jimi.CRCX25
+	body := func(b []byte) []byte { return b[2 : len(b)-4] }
+	received := uint16(data[len(data)-4])<<8 | uint16(data[len(data)-3])
+	if crc.Checksum(body(data)) == received {
+		sum := crc.Checksum(body(out))
+		out[len(out)-4], out[len(out)-3] = byte(sum>>8), byte(sum)
+	}
+	return out
+}
+
+// lengthFieldSize returns the size of the length field of a frame, or 0 if
+// it has no valid start bit
+func lengthFieldSize(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	switch uint16(data[0])<<8 | uint16(data[1]) {
+	case protocol.StartBitShort:
+		return protocol.LengthFieldSizeShort
+	case protocol.StartBitLong:
+		return protocol.LengthFieldSizeLong
+	}
+	return 0
+}
+
+// printHuman writes the frames as indented text
+func printHuman(w io.Writer, frames []*frame) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range frames {
+		fmt.Fprintf(bw, "%s", f.source)
+		if f.direction != "" {
+			fmt.Fprintf(bw, " %s", f.direction)
+		}
+		if len(f.data) > 0 {
+			fmt.Fprintf(bw, " (%d bytes) %X", len(f.data), f.data)
+		}
+		fmt.Fprintln(bw)
+
+		if f.err != nil {
+			fmt.Fprintf(bw, "  ERROR: %v\n", f.err)
+		} else {
+			fmt.Fprintf(bw, "  %s (0x%02X) serial=%d\n", f.packet.Type(), f.packet.ProtocolNumber(), f.packet.SerialNumber())
+			fmt.Fprintf(bw, "  %s\n", f.packet)
+		}
+		if len(f.fields) > 0 {
+			tw := tabwriter.NewWriter(bw, 0, 0, 2, ' ', 0)
+			for _, fl := range f.fields {
+				fmt.Fprintf(tw, "    %s\t%s\t%s\t%s\n", byteRange(fl), abbreviate(fl.Raw), fl.Name, fl.Value)
+			}
+			tw.Flush()
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// printTable writes one row per frame, or per field with -annotate
+func printTable(w io.Writer, frames []*frame) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if *annotate {
+		fmt.Fprintln(tw, "SOURCE\tBYTES\tHEX\tFIELD\tVALUE")
+		for _, f := range frames {
+			if len(f.fields) == 0 {
+				fmt.Fprintf(tw, "%s\t\t\terror\t%v\n", f.source, f.err)
+			}
+			for _, fl := range f.fields {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.source, byteRange(fl), abbreviate(fl.Raw), fl.Name, fl.Value)
+			}
+		}
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw, "SOURCE\tDIR\tLEN\tPROTOCOL\tSERIAL\tRESULT")
+	for _, f := range frames {
+		proto, serial, result := "-", "-", ""
+		if f.err != nil {
+			result = "ERROR: " + f.err.Error()
+		} else {
+			proto = fmt.Sprintf("%s (0x%02X)", f.packet.Type(), f.packet.ProtocolNumber())
+			serial = fmt.Sprintf("%d", f.packet.SerialNumber())
+			result = fmt.Sprint(f.packet)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", f.source, cmp.Or(f.direction, "-"), len(f.data), proto, serial, result)
+	}
+	return tw.Flush()
+}
+
+// printJSON writes one JSON object per frame and line
+func printJSON(w io.Writer, frames []*frame) error {
+	type jsonField struct {
+		Name   string `json:"name"`
+		Offset int    `json:"offset"`
+		Length int    `json:"length"`
+		Hex    string `json:"hex"`
+		Value  string `json:"value,omitempty"`
+	}
+	type jsonFrame struct {
+		Source    string          `json:"source"`
+		Direction string          `json:"direction,omitempty"`
+		Hex       string          `json:"hex,omitempty"`
+		Length    int             `json:"length"`
+		Protocol  string          `json:"protocol,omitempty"`
+		Packet    json.RawMessage `json:"packet,omitempty"`
+		Error     string          `json:"error,omitempty"`
+		Fields    []jsonField     `json:"fields,omitempty"`
+	}
+
+	enc := json.NewEncoder(w)
+	for _, f := range frames {
+		out := jsonFrame{
+			Source:    f.source,
+			Direction: f.direction,
+			Hex:       strings.ToUpper(hex.EncodeToString(f.data)),
+			Length:    len(f.data),
+		}
+		for _, fl := range f.fields {
+			out.Fields = append(out.Fields, jsonField{fl.Name, fl.Offset, len(fl.Raw), fmt.Sprintf("%X", fl.Raw), fl.Value})
+		}
+		if f.err != nil {
+			out.Error = f.err.Error()
+		} else {
+			out.Protocol = protocol.Number(f.packet.ProtocolNumber()).String()
+			data, err := json.Marshal(f.packet)
+			if err != nil {
+				return err
+			}
+			out.Packet = data
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byteRange formats the byte offsets of a field
+func byteRange(f jimi.Field) string {
+	if len(f.Raw) == 1 {
+		return fmt.Sprintf("%d", f.Offset)
+	}
+	return fmt.Sprintf("%d-%d", f.Offset, f.End()-1)
+}
+
+// abbreviate formats raw bytes as hex, shortened for aligned output
+func abbreviate(raw []byte) string {
+	h := fmt.Sprintf("%X", raw)
+	if len(h) <= 24 {
+		return h
+	}
+	return h[:20] + "…"
+}
@@ -0,0 +1,274 @@
+// jimi-proxy relays Jimi VL103M GPS Tracker connections to several servers
+//
+// A transparent forwarder: devices connect to the proxy, and the raw bytes
+// they send are relayed unchanged to every upstream server, e.g. the Jimi
+// cloud and a private platform. The first upstream is the primary one: its
+// responses (login and alarm acknowledgments, commands) are relayed back to
+// the device; the responses of the others are read and discarded.
+//
+// Each device connection has its own connection to every upstream. When an
+// upstream is down, the device data is buffered for it (up to -buffer bytes
+// per device, dropping the oldest data beyond) and the proxy reconnects with
+// an exponential backoff between -reconnect-min and -reconnect-max. As
+// upstreams only accept data after a login, the last login of the device is
+// sent again first after a reconnect or dropped data. When the device
+// disconnects, what is buffered is still delivered, for at most -flush.
+//
+// The relayed data is also decoded, for observability only: logins,
+// alarms and disconnects are logged, and every packet in both directions
+// with -v. Data that does not decode is relayed all the same.
+//
+// Usage:
+//
+//	jimi-proxy -listen :5023 -upstream jimi-cloud.example.com:21100 -upstream platform.internal:5023
+//	jimi-proxy -listen :5023 -upstream localhost:5024 -buffer 4194304 -v
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// readBufferSize is the size of the reads from devices and upstreams
+const readBufferSize = 4096
+
+// upstreamList collects the repeated -upstream flags
+type upstreamList []string
+
+func (l *upstreamList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *upstreamList) Set(v string) error {
+	for addr := range strings.SplitSeq(v, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			*l = append(*l, addr)
+		}
+	}
+	return nil
+}
+
+// Configuration flags
+var (
+	listen       = flag.String("listen", ":5023", "Address devices connect to")
+	bufferSize   = flag.Int("buffer", 1<<20, "Bytes buffered per device and upstream while the upstream is down")
+	reconnectMin = flag.Duration("reconnect-min", time.Second, "First delay before reconnecting to an upstream")
+	reconnectMax = flag.Duration("reconnect-max", 30*time.Second, "Longest delay between reconnects to an upstream")
+	dialTimeout  = flag.Duration("dial-timeout", 10*time.Second, "Timeout of a connection to an upstream")
+	writeTimeout = flag.Duration("write-timeout", 10*time.Second, "Timeout of a write to an upstream or a device")
+	flushTimeout = flag.Duration("flush", 30*time.Second, "How long data buffered for an upstream is kept after the device disconnects")
+	idleTimeout  = flag.Duration("idle-timeout", 10*time.Minute, "Close device connections silent for this long (0 disables)")
+	skipCRC      = flag.Bool("skip-crc", false, "Skip CRC validation when decoding")
+	lenient      = flag.Bool("lenient", false, "Enable lenient decoding (unknown protocols, no IMEI checksum)")
+	verbose      = flag.Bool("v", false, "Log every packet relayed, in both directions")
+	upstreams    upstreamList
+)
+
+func main() {
+	flag.Var(&upstreams, "upstream", "Upstream server address (repeatable or comma-separated; the first one answers the devices)")
+	flag.Parse()
+
+	if len(upstreams) == 0 {
+		log.Fatal("At least one -upstream is required")
+	}
+	if *bufferSize <= 0 {
+		log.Fatal("-buffer must be positive")
+	}
+	if *reconnectMin <= 0 || *reconnectMax < *reconnectMin {
+		log.Fatal("-reconnect-min must be positive and at most -reconnect-max")
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	log.Printf("Relaying %s to %s (primary %s)", l.Addr(), strings.Join(upstreams, ", "), upstreams[0])
+
+	// Open device connections, closed on shutdown so their data is flushed
+	var conns sync.Map
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Println("Shutting down")
+		l.Close()
+		conns.Range(func(conn, _ any) bool {
+			conn.(net.Conn).Close()
+			return true
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
+			}
+			log.Printf("Accept failed: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		conns.Store(conn, struct{}{})
+		wg.Go(func() {
+			newDevice(conn).serve()
+			conns.Delete(conn)
+		})
+	}
+	wg.Wait()
+}
+
+// decoderOptions returns the options of the observability decoders
+func decoderOptions() []jimi.Option {
+	var opts []jimi.Option
+	if *skipCRC {
+		opts = append(opts, jimi.WithSkipCRC())
+	}
+	if *lenient {
+		opts = append(opts, jimi.WithLenientMode())
+	}
+	return opts
+}
+
+// device is one device connection and its upstreams
+type device struct {
+	conn      net.Conn
+	upstreams []*upstream
+
+	// writeMu serializes the responses relayed to the device
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	imei  string
+	login []byte // last login frame, sent again after reconnects
+}
+
+// newDevice creates the upstreams of a device connection
+func newDevice(conn net.Conn) *device {
+	d := &device{conn: conn}
+	for i, addr := range upstreams {
+		d.upstreams = append(d.upstreams, newUpstream(d, addr, i == 0))
+	}
+	return d
+}
+
+// name identifies the device in logs: its IMEI, or its address before login
+func (d *device) name() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.imei != "" {
+		return d.imei
+	}
+	return d.conn.RemoteAddr().String()
+}
+
+// loginFrame returns the last login frame of the device, nil before login
+func (d *device) loginFrame() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.login
+}
+
+// serve relays the device data until the device disconnects and the data
+// buffered for the upstreams is flushed
+func (d *device) serve() {
+	log.Printf("[%s] Connected", d.name())
+	var wg sync.WaitGroup
+	for _, u := range d.upstreams {
+		wg.Go(u.run)
+	}
+
+	decoder := jimi.NewDecoder(decoderOptions()...)
+	var pending []byte
+	buf := make([]byte, readBufferSize)
+	frames := 0
+	for {
+		if *idleTimeout > 0 {
+			d.conn.SetReadDeadline(time.Now().Add(*idleTimeout))
+		}
+		n, err := d.conn.Read(buf)
+		if n > 0 {
+			// Relay first, decode after: observability never delays the data
+			chunk := append([]byte(nil), buf[:n]...)
+			for _, u := range d.upstreams {
+				u.enqueue(chunk)
+			}
+
+			pending = append(pending, chunk...)
+			packets, residue, decodeErr := decoder.DecodeStream(pending)
+			if decodeErr != nil && *verbose {
+				log.Printf("[%s] RX undecoded: %v", d.name(), decodeErr)
+			}
+			pending = residue
+			for _, p := range packets {
+				frames++
+				d.observe(p)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	d.conn.Close()
+	log.Printf("[%s] Disconnected after %d packets", d.name(), frames)
+	for _, u := range d.upstreams {
+		u.close()
+	}
+	wg.Wait()
+
+	var stats []string
+	for _, u := range d.upstreams {
+		stats = append(stats, u.stats())
+	}
+	log.Printf("[%s] Flushed | %s", d.name(), strings.Join(stats, " | "))
+}
+
+// observe logs a packet received from the device, remembering its login
+func (d *device) observe(p packet.Packet) {
+	switch v := p.(type) {
+	case *packet.LoginPacket:
+		d.mu.Lock()
+		d.imei = v.GetIMEI()
+		d.login = v.RawData
+		d.mu.Unlock()
+		log.Printf("[%s] Login from %s", v.GetIMEI(), d.conn.RemoteAddr())
+		return
+	case packet.PacketWithAlarm:
+		log.Printf("[%s] ALARM: %s", d.name(), v.GetAlarmType())
+		return
+	}
+	if *verbose {
+		log.Printf("[%s] RX %s", d.name(), describe(p))
+	}
+}
+
+// relay writes a response of the primary upstream to the device
+func (d *device) relay(data []byte) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	d.conn.SetWriteDeadline(time.Now().Add(*writeTimeout))
+	_, err := d.conn.Write(data)
+	return err
+}
+
+// describe returns a one-line description of a packet
+func describe(p packet.Packet) string {
+	if s, ok := p.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return p.Type()
+}
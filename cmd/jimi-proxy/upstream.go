@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// upstream relays the data of one device to one upstream server, buffering
+// it while the server is unreachable
+type upstream struct {
+	dev     *device
+	addr    string
+	primary bool
+	done    chan struct{}
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     [][]byte // device reads not yet written, oldest first
+	queued    int      // bytes in queue
+	sent      int      // bytes written
+	dropped   int      // bytes dropped, buffer full or flush timed out
+	dropping  bool     // dropped since the last connection, logged once
+	conn      net.Conn
+	lostAt    time.Time // when conn was last lost, paces reconnects
+	needLogin bool      // the server has not seen the login of the device
+	closed    bool      // the device disconnected
+	flushBy   time.Time // deadline of the data left once closed
+}
+
+// newUpstream creates the relay of d to addr; the primary one answers d
+func newUpstream(d *device, addr string, primary bool) *upstream {
+	u := &upstream{dev: d, addr: addr, primary: primary, done: make(chan struct{})}
+	u.cond = sync.NewCond(&u.mu)
+	return u
+}
+
+// enqueue buffers a chunk of device data, dropping the oldest data beyond
+// the buffer size
+func (u *upstream) enqueue(chunk []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return
+	}
+	u.queue = append(u.queue, chunk)
+	u.queued += len(chunk)
+	for u.queued > *bufferSize && len(u.queue) > 1 {
+		u.queued -= len(u.queue[0])
+		u.dropped += len(u.queue[0])
+		u.queue[0] = nil
+		u.queue = u.queue[1:]
+		u.needLogin = true
+		if !u.dropping {
+			u.dropping = true
+			log.Printf("[%s] Buffer for %s full, dropping the oldest data", u.dev.name(), u.addr)
+		}
+	}
+	u.cond.Signal()
+}
+
+// run writes the buffered data to the server, (re)connecting as needed,
+// until the device disconnects and the buffer is flushed or times out
+func (u *upstream) run() {
+	backoff := *reconnectMin
+	for {
+		u.mu.Lock()
+		for u.conn != nil && len(u.queue) == 0 && !u.closed {
+			u.cond.Wait()
+		}
+		if u.closed && (len(u.queue) == 0 || !time.Now().Before(u.flushBy)) {
+			u.shutdown()
+			u.mu.Unlock()
+			return
+		}
+		conn, lostAt := u.conn, u.lostAt
+		var chunk []byte
+		if conn != nil {
+			chunk = u.queue[0]
+			u.queue[0] = nil
+			u.queue = u.queue[1:]
+			u.queued -= len(chunk)
+		}
+		u.mu.Unlock()
+
+		if conn == nil {
+			// A server dropping connections right away is not hammered
+			if wait := *reconnectMin - time.Since(lostAt); wait > 0 {
+				u.wait(wait)
+				continue
+			}
+			if err := u.connect(); err != nil {
+				log.Printf("[%s] Upstream %s unreachable, retrying in %v: %v", u.dev.name(), u.addr, backoff, err)
+				u.wait(backoff)
+				backoff = min(2*backoff, *reconnectMax)
+				continue
+			}
+			backoff = *reconnectMin
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(*writeTimeout))
+		if _, err := conn.Write(chunk); err != nil {
+			u.requeue(chunk)
+			u.disconnect(conn, err)
+			continue
+		}
+		u.mu.Lock()
+		u.sent += len(chunk)
+		u.mu.Unlock()
+	}
+}
+
+// connect dials the server, sending the device login first if the server
+// has not seen it
+func (u *upstream) connect() error {
+	conn, err := net.DialTimeout("tcp", u.addr, *dialTimeout)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.conn = conn
+	login, reconnect := u.needLogin, !u.lostAt.IsZero()
+	u.needLogin = false
+	u.dropping = false
+	u.mu.Unlock()
+
+	if reconnect {
+		log.Printf("[%s] Upstream %s reconnected", u.dev.name(), u.addr)
+	}
+	go u.readResponses(conn)
+	if frame := u.dev.loginFrame(); login && frame != nil {
+		conn.SetWriteDeadline(time.Now().Add(*writeTimeout))
+		if _, err := conn.Write(frame); err != nil {
+			u.disconnect(conn, err)
+		}
+	}
+	return nil
+}
+
+// requeue puts back a chunk whose write failed, to be sent first
+func (u *upstream) requeue(chunk []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.queue = append([][]byte{chunk}, u.queue...)
+	u.queued += len(chunk)
+}
+
+// disconnect drops a failed server connection, unless already replaced
+func (u *upstream) disconnect(conn net.Conn, err error) {
+	u.mu.Lock()
+	if u.conn != conn {
+		u.mu.Unlock()
+		return
+	}
+	u.conn = nil
+	u.lostAt = time.Now()
+	u.needLogin = true
+	closed := u.closed
+	u.cond.Signal()
+	u.mu.Unlock()
+
+	conn.Close()
+	if !closed {
+		log.Printf("[%s] Upstream %s disconnected: %v", u.dev.name(), u.addr, err)
+	}
+}
+
+// wait pauses before a reconnect. The device disconnecting cuts the pause
+// short, to try to flush at once; after that, it ends by the flush deadline.
+func (u *upstream) wait(d time.Duration) {
+	done := u.done
+	u.mu.Lock()
+	if u.closed {
+		d = min(d, time.Until(u.flushBy))
+		done = nil
+	}
+	u.mu.Unlock()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-done:
+	}
+}
+
+// shutdown drops the data left and the connection. Caller must hold u.mu.
+func (u *upstream) shutdown() {
+	for _, chunk := range u.queue {
+		u.dropped += len(chunk)
+	}
+	u.queue, u.queued = nil, 0
+	if u.conn != nil {
+		u.conn.Close()
+		u.conn = nil
+	}
+}
+
+// close stops the relay once the data buffered is written, for at most the
+// flush timeout
+func (u *upstream) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return
+	}
+	u.closed = true
+	u.flushBy = time.Now().Add(*flushTimeout)
+	close(u.done)
+	u.cond.Signal()
+}
+
+// stats summarizes the data relayed to the server
+func (u *upstream) stats() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.dropped > 0 {
+		return fmt.Sprintf("%s: %d bytes relayed, %d dropped", u.addr, u.sent, u.dropped)
+	}
+	return fmt.Sprintf("%s: %d bytes relayed", u.addr, u.sent)
+}
+
+// readResponses reads the server responses until the connection fails,
+// relaying them to the device for the primary upstream
+func (u *upstream) readResponses(conn net.Conn) {
+	var decoder *jimi.Decoder
+	var stream []byte
+	if *verbose {
+		decoder = jimi.NewDecoder(decoderOptions()...)
+	}
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if u.primary {
+				if err := u.dev.relay(buf[:n]); err != nil {
+					// The device is gone: its read loop ends the relay
+					u.dev.conn.Close()
+				}
+			}
+			if decoder != nil {
+				stream = append(stream, buf[:n]...)
+				var frames [][]byte
+				frames, stream, _ = decoder.SplitPackets(stream)
+				for _, f := range frames {
+					u.observe(decoder, f)
+				}
+			}
+		}
+		if err != nil {
+			u.disconnect(conn, err)
+			return
+		}
+	}
+}
+
+// observe logs a server response; only online commands are decoded, the
+// acknowledgments have nothing to decode beyond their protocol number
+func (u *upstream) observe(decoder *jimi.Decoder, frame []byte) {
+	role := "secondary"
+	if u.primary {
+		role = "primary"
+	}
+	proto, _ := decoder.GetProtocolNumber(frame)
+	if proto == protocol.ProtocolOnlineCommand {
+		if p, err := decoder.Decode(frame); err == nil {
+			log.Printf("[%s] TX from %s (%s) %s", u.dev.name(), u.addr, role, describe(p))
+			return
+		}
+	}
+	log.Printf("[%s] TX from %s (%s) 0x%02X %X", u.dev.name(), u.addr, role, proto, frame)
+}
@@ -0,0 +1,324 @@
+// Field-level diff of decoded packet output
+//
+// Compares two NDJSON files of decoded records (decoder-cli -ndjson,
+// tcp-server -ndjson or -events-file) produced from the same capture by two
+// library versions, and reports every field that changed, appeared or
+// disappeared, followed by a summary per field and protocol. Maintainers use
+// it to assess the blast radius of parser changes before a release:
+//
+//	git stash && decoder-cli -file capture.txt -ndjson > old.jsonl
+//	git stash pop && decoder-cli -file capture.txt -ndjson > new.jsonl
+//	jimidiff old.jsonl new.jsonl
+//
+// Records are paired by their "raw" hex when every record has one (so
+// packets that only one version decodes show up as added or removed), and by
+// line number otherwise. The exit status is 1 when the files differ.
+//
+// Usage:
+//
+//	jimidiff [-summary] [-ignore received_at,schema] old.jsonl new.jsonl
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Configuration flags
+var (
+	summaryOnly = flag.Bool("summary", false, "Only print the per-field summary")
+	ignoreList  = flag.String("ignore", "received_at", "Comma-separated field paths to ignore (prefixes match nested fields)")
+	maxRecords  = flag.Int("max", 100, "Maximum number of differing records to print (0 = all)")
+)
+
+// record is one decoded record flattened to path -> JSON value
+type record struct {
+	line     int
+	key      string
+	protocol string
+	typ      string
+	fields   map[string]string
+}
+
+// change is one field difference
+type change struct {
+	path     string
+	old, new string // empty when the field is missing on that side
+}
+
+// stat counts the changes of one field path for one protocol
+type stat struct {
+	path, protocol string
+	changed        int
+	added, removed int
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	if flag.NArg() != 2 {
+		log.Fatal("Usage: jimidiff [flags] old.jsonl new.jsonl")
+	}
+	ignore := splitList(*ignoreList)
+
+	oldRecs, err := readRecords(flag.Arg(0), ignore)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+	newRecs, err := readRecords(flag.Arg(1), ignore)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(1), err)
+	}
+
+	byRaw := hasKeys(oldRecs) && hasKeys(newRecs)
+	if !byRaw {
+		for i := range oldRecs {
+			oldRecs[i].key = strconv.Itoa(i)
+		}
+		for i := range newRecs {
+			newRecs[i].key = strconv.Itoa(i)
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	stats := make(map[[2]string]*stat)
+	var differing, onlyOld, onlyNew, printed int
+
+	newByKey := make(map[string][]*record)
+	for i := range newRecs {
+		r := &newRecs[i]
+		newByKey[r.key] = append(newByKey[r.key], r)
+	}
+
+	for i := range oldRecs {
+		o := &oldRecs[i]
+		matches := newByKey[o.key]
+		if len(matches) == 0 {
+			onlyOld++
+			if !*summaryOnly && show(&printed) {
+				fmt.Fprintf(w, "- old line %d (%s %s): no matching record\n", o.line, o.protocol, o.typ)
+			}
+			continue
+		}
+		n := matches[0]
+		newByKey[o.key] = matches[1:]
+
+		changes := diff(o.fields, n.fields)
+		if len(changes) == 0 {
+			continue
+		}
+		differing++
+		for _, c := range changes {
+			count(stats, c, o.protocol)
+		}
+		if *summaryOnly || !show(&printed) {
+			continue
+		}
+		fmt.Fprintf(w, "~ old line %d / new line %d (%s %s)\n", o.line, n.line, o.protocol, o.typ)
+		for _, c := range changes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", c.path, orMissing(c.old), orMissing(c.new))
+		}
+	}
+
+	for i := range newRecs {
+		n := &newRecs[i]
+		for _, m := range newByKey[n.key] {
+			if m == n {
+				onlyNew++
+				if !*summaryOnly && show(&printed) {
+					fmt.Fprintf(w, "+ new line %d (%s %s): no matching record\n", n.line, n.protocol, n.typ)
+				}
+			}
+		}
+	}
+
+	if !*summaryOnly && *maxRecords > 0 && printed > *maxRecords {
+		fmt.Fprintf(w, "... %d more records (use -max 0 to print all)\n", printed-*maxRecords)
+	}
+	writeSummary(w, stats, len(oldRecs), len(newRecs), differing, onlyOld, onlyNew, byRaw)
+
+	if differing+onlyOld+onlyNew > 0 {
+		w.Flush()
+		os.Exit(1)
+	}
+}
+
+// show counts a differing record and reports whether it is within -max
+func show(printed *int) bool {
+	*printed++
+	return *maxRecords == 0 || *printed <= *maxRecords
+}
+
+// writeSummary prints the totals and the changes per field and protocol
+func writeSummary(w io.Writer, stats map[[2]string]*stat, oldN, newN, differing, onlyOld, onlyNew int, byRaw bool) {
+	pairing := "line number"
+	if byRaw {
+		pairing = "raw packet"
+	}
+	fmt.Fprintf(w, "\n%d old records, %d new records (paired by %s)\n", oldN, newN, pairing)
+	fmt.Fprintf(w, "%d records differ, %d only in old, %d only in new\n", differing, onlyOld, onlyNew)
+	if len(stats) == 0 {
+		return
+	}
+
+	list := make([]*stat, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		ti, tj := list[i].changed+list[i].added+list[i].removed, list[j].changed+list[j].added+list[j].removed
+		if ti != tj {
+			return ti > tj
+		}
+		if list[i].path != list[j].path {
+			return list[i].path < list[j].path
+		}
+		return list[i].protocol < list[j].protocol
+	})
+
+	fmt.Fprintf(w, "\n%-40s %-10s %8s %8s %8s\n", "FIELD", "PROTOCOL", "CHANGED", "ADDED", "REMOVED")
+	for _, s := range list {
+		fmt.Fprintf(w, "%-40s %-10s %8d %8d %8d\n", s.path, s.protocol, s.changed, s.added, s.removed)
+	}
+}
+
+// count adds a change to the per-field statistics
+func count(stats map[[2]string]*stat, c change, protocol string) {
+	k := [2]string{c.path, protocol}
+	s, ok := stats[k]
+	if !ok {
+		s = &stat{path: c.path, protocol: protocol}
+		stats[k] = s
+	}
+	switch {
+	case c.old == "":
+		s.added++
+	case c.new == "":
+		s.removed++
+	default:
+		s.changed++
+	}
+}
+
+// diff returns the changed, added and removed fields sorted by path
+func diff(old, new map[string]string) []change {
+	var changes []change
+	for path, ov := range old {
+		if nv, ok := new[path]; !ok {
+			changes = append(changes, change{path: path, old: ov})
+		} else if nv != ov {
+			changes = append(changes, change{path: path, old: ov, new: nv})
+		}
+	}
+	for path, nv := range new {
+		if _, ok := old[path]; !ok {
+			changes = append(changes, change{path: path, new: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes
+}
+
+func orMissing(v string) string {
+	if v == "" {
+		return "(missing)"
+	}
+	return v
+}
+
+// readRecords reads and flattens every JSON line of a file
+func readRecords(path string, ignore []string) ([]record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		r := record{line: n, fields: make(map[string]string)}
+		r.key, _ = obj["raw"].(string)
+		r.protocol, _ = obj["protocol"].(string)
+		r.typ, _ = obj["type"].(string)
+		flatten("", obj, r.fields, ignore)
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// flatten stores every leaf value of v under its dotted path
+func flatten(path string, v any, out map[string]string, ignore []string) {
+	for _, prefix := range ignore {
+		if path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[") {
+			return
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, child, out, ignore)
+		}
+	case []any:
+		if len(v) == 0 {
+			out[path] = "[]"
+		}
+		for i, child := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), child, out, ignore)
+		}
+	default:
+		b, _ := json.Marshal(v)
+		out[path] = string(b)
+	}
+}
+
+// hasKeys reports whether every record has a raw packet to pair on
+func hasKeys(records []record) bool {
+	for _, r := range records {
+		if r.key == "" {
+			return false
+		}
+	}
+	return len(records) > 0
+}
+
+// splitList splits a comma-separated flag value
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+// Layout inference assistant for unknown Jimi VL103M protocol numbers
+//
+// Reads captured packets, groups them by protocol number and prints a
+// suggested field layout (date-time, latitude/longitude, counters, constants,
+// serial number) for each protocol without a parser. See pkg/jimi/infer.
+//
+// Accepted inputs:
+//   - One hex packet (or several concatenated) per line, as for decoder-cli
+//   - tcp-server raw logs ([timestamp] RX hex); TX lines are ignored
+//   - A tcp-server -quarantine report (-quarantine flag)
+//
+// Usage:
+//
+//	layout-infer -file logs/raw_359339073930520_20240615_143000.log
+//	layout-infer -quarantine quarantine.json
+//	cat capture.txt | layout-infer -protocol 0x99
+//	layout-infer -all -protocol gps_location_4g -file capture.txt
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/infer"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Configuration flags
+var (
+	inputFile  = flag.String("file", "", "File with hex packets or a tcp-server raw log (default: stdin)")
+	quarFile   = flag.String("quarantine", "", "Read samples from a tcp-server quarantine report instead")
+	protoFlag  = flag.String("protocol", "", "Only analyze this protocol, by number or name (e.g. 0x99 or gps_location)")
+	includeAll = flag.Bool("all", false, "Also analyze protocols that already have a parser")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	var only *byte
+	if *protoFlag != "" {
+		n, err := protocol.ParseNumber(*protoFlag)
+		if err != nil {
+			log.Fatalf("Invalid -protocol: %v", err)
+		}
+		b := byte(n)
+		only = &b
+	}
+
+	var groups map[byte][][]byte
+	var err error
+	if *quarFile != "" {
+		groups, err = readQuarantine(*quarFile)
+	} else {
+		in := io.Reader(os.Stdin)
+		if *inputFile != "" {
+			f, ferr := os.Open(*inputFile)
+			if ferr != nil {
+				log.Fatalf("Failed to open input: %v", ferr)
+			}
+			defer f.Close()
+			in = f
+		}
+		groups, err = readCapture(in)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read samples: %v", err)
+	}
+
+	decoder := jimi.NewDecoder()
+	protocols := make([]byte, 0, len(groups))
+	for proto := range groups {
+		if only != nil && proto != *only {
+			continue
+		}
+		if only == nil && !*includeAll && decoder.HasParser(proto) {
+			continue
+		}
+		protocols = append(protocols, proto)
+	}
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i] < protocols[j] })
+
+	if len(protocols) == 0 {
+		log.Println("No samples of unknown protocols found (use -all to analyze known ones)")
+		return
+	}
+
+	for i, proto := range protocols {
+		if i > 0 {
+			fmt.Println()
+		}
+		layout, err := infer.Infer(groups[proto])
+		if err != nil {
+			log.Printf("Protocol 0x%02X: %v", proto, err)
+			continue
+		}
+		fmt.Print(layout)
+	}
+}
+
+// readCapture reads frames from hex lines or a raw log, grouped by protocol
+func readCapture(r io.Reader) (map[byte][][]byte, error) {
+	groups := make(map[byte][][]byte)
+	decoder := jimi.NewDecoder()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		h := extractHex(scanner.Text())
+		if h == "" {
+			continue
+		}
+		data, err := jimi.ParseHex(h)
+		if err != nil {
+			log.Printf("line %d: %v", number, err)
+			continue
+		}
+		frames, _, _ := decoder.SplitPackets(data)
+		for _, f := range frames {
+			proto, err := decoder.GetProtocolNumber(f)
+			if err != nil {
+				continue
+			}
+			groups[proto] = append(groups[proto], f)
+		}
+	}
+	return groups, scanner.Err()
+}
+
+// extractHex returns the hex payload of a line ("" for blank, comment and TX lines)
+func extractHex(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	// tcp-server raw log format: [timestamp] DIRECTION hex
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end >= 0 {
+			fields := strings.Fields(line[end+1:])
+			if len(fields) == 0 || fields[0] == "TX" {
+				return ""
+			}
+			return fields[len(fields)-1]
+		}
+	}
+
+	return line
+}
+
+// readQuarantine reads the samples of a quarantine report
+func readQuarantine(path string) (map[byte][][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var report jimi.QuarantineReport
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[byte][][]byte)
+	for _, e := range report.Entries {
+		for _, s := range e.Samples {
+			data, err := jimi.ParseHex(s)
+			if err != nil {
+				return nil, fmt.Errorf("protocol 0x%02X: %w", e.Protocol, err)
+			}
+			groups[e.Protocol] = append(groups[e.Protocol], data)
+		}
+	}
+	return groups, nil
+}
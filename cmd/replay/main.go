@@ -0,0 +1,205 @@
+// Replay tool for Jimi VL103M raw packet logs
+//
+// Re-decodes the raw_*.log files written by tcp-server (-save-raw) with the
+// current decoder, e.g. to check a parser fix against captured traffic.
+// Packets are replayed with their original inter-packet timing, scaled by
+// -speed (0 replays as fast as possible).
+//
+// With -send the RX data of each log is also re-sent to a live server, one
+// connection per log, for regression testing; server responses are read and
+// discarded (see session-replay to check them). With -ndjson decoded packets
+// are written as NDJSON records (see pkg/jimi/export) instead of text.
+//
+// The exit status is 1 when any packet fails to decode.
+//
+// Usage:
+//
+//	replay -speed 0 logs/raw_359339073930520_*.log
+//	replay -speed 10 -send localhost:5023 logs/raw_359339073930520_20240615_143045.log
+//	replay -speed 0 -ndjson logs/*.log | jq 'select(.type == "Alarm")'
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/rawlog"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Configuration flags
+var (
+	speed   = flag.Float64("speed", 1, "Replay speed factor (2 = twice as fast, 0 = no delays)")
+	send    = flag.String("send", "", "Also send the RX data to the server at this address")
+	skipCRC = flag.Bool("skip-crc", false, "Skip CRC validation")
+	lenient = flag.Bool("lenient", false, "Enable lenient decoding (unknown protocols, no IMEI checksum)")
+	ndjson  = flag.Bool("ndjson", false, "Write decoded packets as NDJSON records to stdout")
+)
+
+// stats counts the outcome of a replay
+type stats struct {
+	packets int
+	failed  int
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	if flag.NArg() == 0 {
+		log.Fatal("Usage: replay [flags] raw.log...")
+	}
+	if *speed < 0 {
+		log.Fatal("-speed must not be negative")
+	}
+
+	var records *export.NDJSONWriter
+	if *ndjson {
+		records = export.NewNDJSONWriter(os.Stdout)
+	}
+
+	var total stats
+	for _, path := range flag.Args() {
+		st, err := replayFile(path, records)
+		total.packets += st.packets
+		total.failed += st.failed
+		if err != nil {
+			total.failed++
+			log.Printf("%s: %v", path, err)
+		}
+	}
+
+	log.Printf("Replayed %d packets from %d logs (%d errors)", total.packets, flag.NArg(), total.failed)
+	if total.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// replayFile decodes (and sends) the records of one raw log
+func replayFile(path string, records *export.NDJSONWriter) (stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return stats{}, err
+	}
+	lines, err := rawlog.Read(f)
+	f.Close()
+	if err != nil {
+		return stats{}, err
+	}
+	if len(lines) == 0 {
+		return stats{}, errors.New("no RX or TX lines")
+	}
+
+	var conn net.Conn
+	if *send != "" {
+		conn, err = net.Dial("tcp", *send)
+		if err != nil {
+			return stats{}, err
+		}
+		defer conn.Close()
+		// Responses are not checked, but must be read so the server never blocks
+		go io.Copy(io.Discard, conn)
+	}
+
+	r := &replayer{
+		name:    filepath.Base(path),
+		decoder: jimi.NewDecoder(decoderOptions()...),
+		records: records,
+	}
+	start := time.Now()
+	first := lines[0].At
+	for _, line := range lines {
+		if !line.RX {
+			// Server responses, see session-replay to check them
+			continue
+		}
+		if *speed > 0 {
+			due := start.Add(time.Duration(float64(line.At.Sub(first)) / *speed))
+			time.Sleep(time.Until(due))
+		}
+		if conn != nil {
+			if _, err := conn.Write(line.Data); err != nil {
+				return r.stats, fmt.Errorf("line %d: %w", line.Line, err)
+			}
+		}
+		r.decode(line)
+	}
+	if len(r.stream) > 0 {
+		return r.stats, fmt.Errorf("incomplete packet at the end of the log: %X", r.stream)
+	}
+	return r.stats, nil
+}
+
+// decoderOptions builds decoder options from flags
+func decoderOptions() []jimi.Option {
+	var opts []jimi.Option
+	if *skipCRC {
+		opts = append(opts, jimi.WithSkipCRC())
+	}
+	if *lenient {
+		opts = append(opts, jimi.WithLenientMode())
+	}
+	return opts
+}
+
+// replayer decodes the RX records of one raw log. A read may hold several
+// or partial packets, so the RX data is reassembled as a stream.
+type replayer struct {
+	name    string
+	decoder *jimi.Decoder
+	records *export.NDJSONWriter
+	imei    string
+	stream  []byte // incomplete packet of the previous reads
+	stats
+}
+
+// decode splits and decodes the data of one RX record
+func (r *replayer) decode(line rawlog.Record) {
+	frames, residue, err := r.decoder.SplitPackets(append(r.stream, line.Data...))
+	r.stream = residue
+	if err != nil {
+		r.failed++
+		r.report(line, "ERROR: %v", err)
+		r.stream = nil
+	}
+
+	for _, frame := range frames {
+		r.packets++
+		p, err := r.decoder.Decode(frame)
+		if err != nil {
+			r.failed++
+			r.report(line, "ERROR: %v (%X)", err, frame)
+			continue
+		}
+		if login, ok := p.(*packet.LoginPacket); ok {
+			r.imei = login.GetIMEI()
+		}
+		if r.records != nil {
+			if err := r.records.Write(export.NewRecord(r.imei, p, line.At)); err != nil {
+				log.Fatalf("Failed to write output: %v", err)
+			}
+			continue
+		}
+		r.report(line, "%s (0x%02X) serial=%d %s", p.Type(), p.ProtocolNumber(), p.SerialNumber(), p)
+	}
+}
+
+// report prints one result; with -ndjson results go to stderr to keep
+// stdout machine-readable
+func (r *replayer) report(line rawlog.Record, format string, args ...any) {
+	msg := fmt.Sprintf("%s:%d [%s] %s", r.name, line.Line, line.At.Format(rawlog.TimestampLayout), fmt.Sprintf(format, args...))
+	if r.records != nil {
+		log.Print(msg)
+		return
+	}
+	fmt.Println(msg)
+}
@@ -0,0 +1,241 @@
+// Session replay for Jimi VL103M servers
+//
+// Replays tcp-server raw logs (-save-raw) against a running server as golden
+// sessions: RX lines are sent with their recorded timing, and every TX line
+// is an expectation the server's next response must meet. A directory of
+// recorded sessions becomes a regression suite for server changes.
+//
+// Responses are compared semantically by default: same protocol number,
+// serial number and content, except time calibration responses (0x8A) whose
+// content is the current time. -exact requires byte-identical responses.
+//
+// The exit status is 1 when any session fails.
+//
+// Usage:
+//
+//	session-replay -addr localhost:5023 logs/raw_359339073930520_*.log
+//	session-replay -speed 0 -exact golden/*.log
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/rawlog"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Configuration flags
+var (
+	addr    = flag.String("addr", "localhost:5023", "Server address")
+	speed   = flag.Float64("speed", 1, "Replay speed factor (2 = twice as fast, 0 = no delays)")
+	exact   = flag.Bool("exact", false, "Require byte-identical responses")
+	timeout = flag.Duration("timeout", 5*time.Second, "How long to wait for each expected response")
+	verbose = flag.Bool("verbose", false, "Print every packet sent and checked")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	if flag.NArg() == 0 {
+		log.Fatal("Usage: session-replay [flags] raw.log...")
+	}
+	if *speed < 0 {
+		log.Fatal("-speed must not be negative")
+	}
+
+	failed := 0
+	for _, path := range flag.Args() {
+		records, err := readLog(path)
+		if err == nil {
+			err = replay(records)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", path, err)
+			continue
+		}
+		rx, tx := count(records)
+		fmt.Printf("PASS %s (%d sent, %d responses checked)\n", path, rx, tx)
+	}
+
+	fmt.Printf("%d of %d sessions passed\n", flag.NArg()-failed, flag.NArg())
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readLog parses the RX and TX lines of a raw log
+func readLog(path string) ([]rawlog.Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := rawlog.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no RX or TX lines")
+	}
+	return records, nil
+}
+
+// count returns the number of RX and TX records
+func count(records []rawlog.Record) (rx, tx int) {
+	for _, r := range records {
+		if r.RX {
+			rx++
+		} else {
+			tx++
+		}
+	}
+	return rx, tx
+}
+
+// replay runs one session and checks the server responses
+func replay(records []rawlog.Record) error {
+	conn, err := net.Dial("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	frames := &frameReader{conn: conn, decoder: jimi.NewDecoder()}
+	start := time.Now()
+	first := records[0].At
+
+	for _, r := range records {
+		if !r.RX {
+			got, err := frames.next(*timeout)
+			if err != nil {
+				return fmt.Errorf("line %d: expected %X: %w", r.Line, r.Data, err)
+			}
+			if err := compare(r.Data, got); err != nil {
+				return fmt.Errorf("line %d: %w\n  expected %X\n  got      %X", r.Line, err, r.Data, got)
+			}
+			if *verbose {
+				fmt.Printf("  ok %X\n", got)
+			}
+			continue
+		}
+
+		if *speed > 0 {
+			due := start.Add(time.Duration(float64(r.At.Sub(first)) / *speed))
+			time.Sleep(time.Until(due))
+		}
+		if *verbose {
+			fmt.Printf("  TX %X\n", r.Data)
+		}
+		if _, err := conn.Write(r.Data); err != nil {
+			return fmt.Errorf("line %d: %w", r.Line, err)
+		}
+	}
+
+	// Responses nobody recorded are regressions too
+	if got, err := frames.next(200 * time.Millisecond); err == nil {
+		return fmt.Errorf("unexpected response after the session: %X", got)
+	}
+	return nil
+}
+
+// compare checks a server response against the recorded one
+func compare(want, got []byte) error {
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	if *exact {
+		return errors.New("response differs")
+	}
+
+	dec := jimi.NewDecoder()
+	wantProto, err1 := dec.GetProtocolNumber(want)
+	gotProto, err2 := dec.GetProtocolNumber(got)
+	if err := errors.Join(err1, err2); err != nil {
+		return err
+	}
+	if wantProto != gotProto {
+		return fmt.Errorf("protocol 0x%02X, want 0x%02X", gotProto, wantProto)
+	}
+	if wantSerial, gotSerial := serial(want), serial(got); wantSerial != gotSerial {
+		return fmt.Errorf("serial %d, want %d", gotSerial, wantSerial)
+	}
+	if wantProto == protocol.ProtocolTimeCalibration {
+		return nil
+	}
+	if !bytes.Equal(content(want), content(got)) {
+		return fmt.Errorf("content %X, want %X", content(got), content(want))
+	}
+	return nil
+}
+
+// header returns the length of the start bits and length field of a frame
+func header(frame []byte) int {
+	if frame[0] == 0x79 {
+		return 4
+	}
+	return 3
+}
+
+// content returns the bytes between the protocol number and the serial number
+func content(frame []byte) []byte {
+	if len(frame) < header(frame)+7 {
+		return nil
+	}
+	return frame[header(frame)+1 : len(frame)-6]
+}
+
+// serial returns the serial number of a frame
+func serial(frame []byte) uint16 {
+	if len(frame) < header(frame)+7 {
+		return 0
+	}
+	return uint16(frame[len(frame)-6])<<8 | uint16(frame[len(frame)-5])
+}
+
+// frameReader splits the server's byte stream into packets
+type frameReader struct {
+	conn    net.Conn
+	decoder *jimi.Decoder
+	stream  []byte
+	queued  [][]byte
+}
+
+// next returns the next packet sent by the server, waiting up to wait
+func (f *frameReader) next(wait time.Duration) ([]byte, error) {
+	f.conn.SetReadDeadline(time.Now().Add(wait))
+	defer f.conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 1024)
+	for len(f.queued) == 0 {
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, errors.New("no response")
+			}
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("server closed the connection")
+			}
+			return nil, err
+		}
+		f.stream = append(f.stream, buf[:n]...)
+		frames, residue, _ := f.decoder.SplitPackets(f.stream)
+		f.stream = residue
+		f.queued = append(f.queued, frames...)
+	}
+
+	frame := f.queued[0]
+	f.queued = f.queued[1:]
+	return frame, nil
+}
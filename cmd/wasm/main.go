@@ -0,0 +1,59 @@
+//go:build js && wasm
+
+// WebAssembly build of the Jimi VL103M decoder
+//
+// Exposes a global JavaScript function:
+//
+//	jimiDecode(hex: string, options?: {skipCRC?: boolean, lenient?: boolean}) => string
+//
+// The returned string is a JSON export.Result: {"ok": true, "record": {...}}
+// on success or {"ok": false, "error": "..."} on failure. Multiple
+// concatenated packets in the input are returned in "records".
+//
+// Build:
+//
+//	make wasm
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+func main() {
+	js.Global().Set("jimiDecode", js.FuncOf(decode))
+	js.Global().Set("jimiVersion", js.ValueOf(jimi.Version))
+
+	// Keep the Go runtime alive for callbacks
+	select {}
+}
+
+// decode implements jimiDecode(hex, options)
+func decode(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return marshal(export.Result{Error: "expected hex string argument", Version: jimi.Version})
+	}
+
+	var opts []jimi.Option
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		if v := args[1].Get("skipCRC"); v.Truthy() {
+			opts = append(opts, jimi.WithSkipCRC())
+		}
+		if v := args[1].Get("lenient"); v.Truthy() {
+			opts = append(opts, jimi.WithLenientMode())
+		}
+	}
+
+	return marshal(export.DecodeHex(jimi.NewDecoder(opts...), args[0].String()))
+}
+
+func marshal(r export.Result) string {
+	out, err := json.Marshal(r)
+	if err != nil {
+		return `{"ok":false,"error":"failed to encode result"}`
+	}
+	return string(out)
+}
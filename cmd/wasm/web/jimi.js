@@ -0,0 +1,51 @@
+// JavaScript wrapper for the Jimi VL103M WebAssembly decoder.
+//
+// Requires wasm_exec.js (shipped with Go) to be loaded first.
+//
+// Usage:
+//
+//   const jimi = await JimiDecoder.load("jimi.wasm");
+//   const result = jimi.decode("787808132404020001870D0D0A");
+//   if (result.ok) console.log(result.record);
+(function (global) {
+  "use strict";
+
+  class JimiDecoder {
+    // load fetches and starts the WebAssembly module
+    static async load(url) {
+      if (typeof global.Go !== "function") {
+        throw new Error("wasm_exec.js must be loaded before jimi.js");
+      }
+
+      const go = new global.Go();
+      let instance;
+      if (WebAssembly.instantiateStreaming) {
+        ({ instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject));
+      } else {
+        const bytes = await (await fetch(url)).arrayBuffer();
+        ({ instance } = await WebAssembly.instantiate(bytes, go.importObject));
+      }
+
+      // go.run resolves only when the Go program exits, which it never does
+      go.run(instance);
+
+      if (typeof global.jimiDecode !== "function") {
+        throw new Error("jimi.wasm did not register jimiDecode");
+      }
+      return new JimiDecoder();
+    }
+
+    // version returns the Go library version
+    get version() {
+      return global.jimiVersion;
+    }
+
+    // decode decodes a hex string and returns the parsed response object
+    // options: {skipCRC: boolean, lenient: boolean}
+    decode(hex, options) {
+      return JSON.parse(global.jimiDecode(String(hex), options || {}));
+    }
+  }
+
+  global.JimiDecoder = JimiDecoder;
+})(typeof globalThis !== "undefined" ? globalThis : window);
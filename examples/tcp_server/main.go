@@ -39,6 +39,7 @@ type DeviceSession struct {
 	conn        net.Conn
 	decoder     *jimi.Decoder
 	encoder     *encoder.Encoder
+	serials     encoder.SerialCounter
 	imei        string
 	lastSeen    time.Time
 	mu          sync.Mutex
@@ -281,7 +282,7 @@ func (s *DeviceSession) handlePacket(pkt packet.Packet) {
 }
 
 func (s *DeviceSession) handleLogin(p *packet.LoginPacket) {
-	s.imei = p.IMEI.String()
+	s.imei = p.GetIMEI()
 
 	// Register session
 	sessionsMu.Lock()
@@ -483,8 +484,8 @@ func (s *DeviceSession) sendCommand(serverFlag uint32, command string) {
 
 	log.Printf("[%s] Sending command: %s (flag: 0x%08X)", s.imei, command, serverFlag)
 
-	// Use serial number 1 for commands (or track proper serial number)
-	response := s.encoder.OnlineCommand(1, serverFlag, command)
+	// Devices may reject repeated serial numbers
+	response := s.encoder.OnlineCommand(s.serials.Next(), serverFlag, command)
 	s.sendResponse(response)
 }
 
@@ -76,6 +76,9 @@ func (p *ChineseAddressParser) Parse(data []byte, ctx Context) (packet.Packet, e
 
 	// Parse Address Content (UNICODE - UTF-16 Big Endian)
 	addressBytes := remainingData[:separatorIdx]
+	if err := checkLimit("address", len(addressBytes), ctx.MaxStringLength); err != nil {
+		return nil, fmt.Errorf("chinese_address: %w", err)
+	}
 	address, err := decodeUTF16BE(addressBytes)
 	if err != nil {
 		return nil, fmt.Errorf("chinese_address: failed to decode address: %w", err)
@@ -193,6 +196,9 @@ func (p *EnglishAddressParser) Parse(data []byte, ctx Context) (packet.Packet, e
 	}
 
 	// Parse Address Content (ASCII/UTF-8)
+	if err := checkLimit("address", separatorIdx, ctx.MaxStringLength); err != nil {
+		return nil, fmt.Errorf("english_address: %w", err)
+	}
 	address := string(remainingData[:separatorIdx])
 	offset += separatorIdx
 
@@ -39,7 +39,7 @@ func TestChineseAddressParser_Parse(t *testing.T) {
 
 				// Build full packet: Start(2) + Length(1) + Protocol(1) + Content + Serial(2) + CRC(2) + Stop(2)
 				pkt := []byte{0x78, 0x78}               // Start bits
-				pkt = append(pkt, byte(len(content)+1)) // Length (protocol + content)
+				pkt = append(pkt, byte(len(content)+5)) // Length (protocol to CRC)
 				pkt = append(pkt, 0x17)                 // Protocol
 				pkt = append(pkt, content...)           // Content
 				pkt = append(pkt, 0x00, 0x01)           // Serial
@@ -112,8 +112,8 @@ func TestEnglishAddressParser_Parse(t *testing.T) {
 
 				// Build full packet: Start(2) + Length(2) + Protocol(1) + Content + Serial(2) + CRC(2) + Stop(2)
 				pkt := []byte{0x79, 0x79}                    // Start bits (long packet)
-				pkt = append(pkt, byte((len(content)+1)>>8)) // Length high byte
-				pkt = append(pkt, byte(len(content)+1))      // Length low byte
+				pkt = append(pkt, byte((len(content)+5)>>8)) // Length high byte (protocol to CRC)
+				pkt = append(pkt, byte(len(content)+5))      // Length low byte
 				pkt = append(pkt, 0x97)                      // Protocol
 				pkt = append(pkt, content...)                // Content
 				pkt = append(pkt, 0x00, 0x02)                // Serial
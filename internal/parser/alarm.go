@@ -54,7 +54,7 @@ func (p *AlarmParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	offset := 0
 
 	// Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("alarm: failed to parse datetime: %w", err)
 	}
@@ -64,7 +64,7 @@ func (p *AlarmParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	// High nibble (bits 7-4): GPS Info Length indicator
 	// Low nibble (bits 3-0): Number of satellites
 	gpsInfoByte := content[offset]
-	satellites := gpsInfoByte & 0x0F // Low nibble = satellites
+	satellites := satelliteCount(gpsInfoByte, ctx)
 	offset++
 
 	// Parse Latitude (4 bytes)
@@ -106,11 +106,20 @@ func (p *AlarmParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	if lbsLength > 0 && offset+8 <= len(content) {
 		lbsInfo, _, err = types.NewLBSInfoFromBytes(content[offset:offset+8], false)
 		if err != nil {
+			if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+				return nil, fmt.Errorf("alarm: %w", err)
+			}
 			lbsInfo = types.LBSInfo{}
 		}
 		offset += 8
 	} else {
-		// Skip LBS bytes if present but length is unexpected
+		// A length of 0 is an alarm without cell info; the LBS bytes are
+		// still there and skipped
+		if lbsLength > 0 {
+			if err := deviation(ctx, p.ProtocolNumber(), "LBS length", "%d", lbsLength); err != nil {
+				return nil, fmt.Errorf("alarm: %w", err)
+			}
+		}
 		offset += 8
 	}
 
@@ -125,6 +134,9 @@ func (p *AlarmParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	// Parse GSM Signal (1 byte)
 	gsmSignal := protocol.GSMSignalStrength(content[offset])
 	offset++
+	if err := checkStatus(ctx, p.ProtocolNumber(), voltageLevel, gsmSignal); err != nil {
+		return nil, fmt.Errorf("alarm: %w", err)
+	}
 
 	// Parse Alert and Language (2 bytes)
 	alarmType := protocol.AlarmType(content[offset])
@@ -194,14 +206,14 @@ func (p *AlarmMultiFenceParser) Parse(data []byte, ctx Context) (packet.Packet,
 	offset := 0
 
 	// DateTime
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("alarm_multi_fence: %w", err)
 	}
 	offset += 6
 
 	// GPS Info
-	satellites := (content[offset] >> 4) & 0x0F
+	satellites := satelliteCount(content[offset], ctx)
 	offset++
 
 	// Coordinates
@@ -231,7 +243,11 @@ func (p *AlarmMultiFenceParser) Parse(data []byte, ctx Context) (packet.Packet,
 	lbsLength := content[offset]
 	offset++
 	if lbsLength > 0 && offset+8 <= len(content) {
-		lbsInfo, _, _ = types.NewLBSInfoFromBytes(content[offset:offset+8], false)
+		if lbsInfo, _, err = types.NewLBSInfoFromBytes(content[offset:offset+8], false); err != nil {
+			if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+				return nil, fmt.Errorf("alarm_multi_fence: %w", err)
+			}
+		}
 		offset += 8
 	}
 
@@ -242,6 +258,9 @@ func (p *AlarmMultiFenceParser) Parse(data []byte, ctx Context) (packet.Packet,
 	offset++
 	gsmSignal := protocol.GSMSignalStrength(content[offset])
 	offset++
+	if err := checkStatus(ctx, p.ProtocolNumber(), voltageLevel, gsmSignal); err != nil {
+		return nil, fmt.Errorf("alarm_multi_fence: %w", err)
+	}
 
 	// Alert and Language
 	alarmType := protocol.AlarmType(content[offset])
@@ -302,6 +321,9 @@ func NewAlarm4GParser() *Alarm4GParser {
 	}
 }
 
+// Parse implements Parser interface
+// 4G alarm packets have the 0x27 structure with a variable-length 4G LBS
+// block; the status fields after it may be truncated by some firmware
 func (p *Alarm4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	content, err := ExtractContent(data)
 	if err != nil {
@@ -315,7 +337,7 @@ func (p *Alarm4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	offset := 0
 
 	// === Parse fixed-size fields from the start ===
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("alarm_4g: failed to parse datetime: %w", err)
 	}
@@ -325,7 +347,7 @@ func (p *Alarm4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	// High nibble (bits 7-4): GPS Info Length indicator
 	// Low nibble (bits 3-0): Number of satellites
 	gpsInfoByte := content[offset]
-	satellites := gpsInfoByte & 0x0F // Low nibble = satellites
+	satellites := satelliteCount(gpsInfoByte, ctx)
 	offset++
 
 	latBytes := content[offset : offset+4]
@@ -369,17 +391,28 @@ func (p *Alarm4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		if lbsLength > 1 {
 			// lbsLength includes the length byte itself.
 			// We need to parse lbsLength-1 bytes of LBS data.
+			if offset+int(lbsLength)-1 > len(content) {
+				return nil, fmt.Errorf("alarm_4g: LBS length %d exceeds content", lbsLength)
+			}
 			lbsData := content[offset : offset+int(lbsLength)-1]
 			var errLbs error
 			lbsInfo, _, errLbs = types.NewLBSInfoFromBytes(lbsData, true) // 4G
 			if errLbs == nil {
 				mccmnc = uint32(lbsInfo.MCC)*1000 + uint32(lbsInfo.MNC)
+			} else if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", errLbs); err != nil {
+				return nil, fmt.Errorf("alarm_4g: %w", err)
 			}
 			offset += int(lbsLength) - 1
 		}
 	}
 
-	// The remaining fields should be the status block
+	// The remaining fields should be the status block: terminal info,
+	// voltage, GSM signal, alarm and language
+	if offset+5 > len(content) {
+		if err := deviation(ctx, p.ProtocolNumber(), "status", "truncated to %d bytes", max(len(content)-offset, 0)); err != nil {
+			return nil, fmt.Errorf("alarm_4g: %w", err)
+		}
+	}
 	if offset < len(content) {
 		terminalInfo = types.NewTerminalInfo(content[offset])
 		offset++
@@ -402,6 +435,9 @@ func (p *Alarm4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		language = protocol.Language(content[offset])
 		offset++
 	}
+	if err := checkStatus(ctx, p.ProtocolNumber(), voltageLevel, gsmSignal); err != nil {
+		return nil, fmt.Errorf("alarm_4g: %w", err)
+	}
 
 	// Fence ID (for 0xA4, similar to 0x27)
 	var fenceID uint8
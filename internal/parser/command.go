@@ -48,11 +48,17 @@ func (p *OnlineCommandParser) Parse(data []byte, ctx Context) (packet.Packet, er
 	var command string
 	if int(cmdLength) > 4 && len(content) > 5 {
 		commandBytes := content[5:]
+		if err := checkLimit("command", min(int(cmdLength)-4, len(commandBytes)), ctx.MaxStringLength); err != nil {
+			return nil, fmt.Errorf("online_command: %w", err)
+		}
 		// Command length includes the server flag (4 bytes)
 		actualCmdLen := int(cmdLength) - 4
 		if actualCmdLen > 0 && actualCmdLen <= len(commandBytes) {
 			command = string(commandBytes[:actualCmdLen])
 		} else if len(commandBytes) > 0 {
+			if err := deviation(ctx, p.ProtocolNumber(), "command length", "%d exceeds the %d command bytes", actualCmdLen, len(commandBytes)); err != nil {
+				return nil, fmt.Errorf("online_command: %w", err)
+			}
 			command = string(commandBytes)
 		}
 	}
@@ -113,10 +119,16 @@ func (p *CommandResponseParser) Parse(data []byte, ctx Context) (packet.Packet,
 	var response string
 	if int(respLength) > 4 && len(content) > 5 {
 		responseBytes := content[5:]
+		if err := checkLimit("response", min(int(respLength)-4, len(responseBytes)), ctx.MaxStringLength); err != nil {
+			return nil, fmt.Errorf("command_response: %w", err)
+		}
 		actualRespLen := int(respLength) - 4
 		if actualRespLen > 0 && actualRespLen <= len(responseBytes) {
 			response = string(responseBytes[:actualRespLen])
 		} else if len(responseBytes) > 0 {
+			if err := deviation(ctx, protocol.ProtocolCommandResponse, "response length", "%d exceeds the %d response bytes", actualRespLen, len(responseBytes)); err != nil {
+				return nil, fmt.Errorf("command_response: %w", err)
+			}
 			response = string(responseBytes)
 		}
 	}
@@ -204,13 +216,16 @@ func (p *GPSAddressRequestParser) Parse(data []byte, ctx Context) (packet.Packet
 
 	// If more than 41 bytes, use first 41 and ignore extras
 	if len(content) > 41 {
+		if err := deviation(ctx, p.ProtocolNumber(), "trailing bytes", "%d bytes", len(content)-41); err != nil {
+			return nil, fmt.Errorf("gps_address_request: %w", err)
+		}
 		content = content[:41]
 	}
 
 	offset := 0
 
 	// 1. Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("gps_address_request: failed to parse datetime: %w", err)
 	}
@@ -219,7 +234,7 @@ func (p *GPSAddressRequestParser) Parse(data []byte, ctx Context) (packet.Packet
 	// 2. Parse GPS Info byte (1 byte)
 	// Low nibble (bits 3-0): Number of satellites
 	gpsInfoByte := content[offset]
-	satellites := gpsInfoByte & 0x0F
+	satellites := satelliteCount(gpsInfoByte, ctx)
 	offset++
 
 	// 3. Parse Latitude (4 bytes)
@@ -52,7 +52,7 @@ func TestGPSAddressRequestParser_Parse(t *testing.T) {
 			// - Course: 18 00 (2 bytes)
 			// - Phone: 21 bytes
 			// - Alarm/Language: 03 00 (2 bytes)
-			hex: "78782B2A" + // header + length (43) + protocol
+			hex: "78782E2A" + // header + length (46) + protocol
 				"1A02010E0D35" + // DateTime (6 bytes)
 				"8A" + // GPS Info (1 byte)
 				"01C3BF03" + // Latitude (4 bytes)
@@ -97,7 +97,7 @@ func TestGPSAddressRequestParser_Parse(t *testing.T) {
 		{
 			name: "packet with SOS alarm",
 			// Same structure but with SOS alarm (0x01)
-			hex: "78782B2A" + // header + length (43) + protocol
+			hex: "78782E2A" + // header + length (46) + protocol
 				"1A02010E0D35" + // DateTime (6 bytes)
 				"8A" + // GPS Info (1 byte)
 				"01C3BF03" + // Latitude (4 bytes)
@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// GPSLBSStatusParser parses combined GPS, LBS and status packets
+// (Protocols 0x16, 0x32 and 0x33)
+type GPSLBSStatusParser struct {
+	BaseParser
+}
+
+// NewGPSLBSStatusParser creates a new GPS LBS status parser for one of
+// protocol.ProtocolGPSLBSStatus, ProtocolGPSLBSStatus4G or ProtocolGPSLBSStatus4GAlt
+func NewGPSLBSStatusParser(protocolNum byte) *GPSLBSStatusParser {
+	name := "GPS LBS Status"
+	if is4GStatus(protocolNum) {
+		name = "GPS LBS Status 4G"
+	}
+	return &GPSLBSStatusParser{
+		BaseParser: NewBaseParser(protocolNum, name),
+	}
+}
+
+// is4GStatus returns true for the GPS LBS status variants with 4G cell data
+func is4GStatus(protocolNum byte) bool {
+	return protocolNum == protocol.ProtocolGPSLBSStatus4G || protocolNum == protocol.ProtocolGPSLBSStatus4GAlt
+}
+
+// Parse implements Parser interface
+// GPS LBS status packet content structure:
+// - DateTime: 6 bytes (YY MM DD HH MM SS)
+// - GPS Info Length: 1 byte
+// - Latitude: 4 bytes
+// - Longitude: 4 bytes
+// - Speed: 1 byte
+// - Course/Status: 2 bytes
+// - LBS Length: 1 byte
+// - LBS Info: 8 bytes for 0x16, LBS Length - 1 bytes of 4G cell data for 0x32/0x33
+// - Terminal Info: 1 byte
+// - Voltage Level: 1 byte
+// - GSM Signal: 1 byte
+// - Alert: 1 byte
+// - Language: 1 byte
+// - Mileage: 4 bytes (optional)
+func (p *GPSLBSStatusParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
+	content, err := ExtractContent(data)
+	if err != nil {
+		return nil, fmt.Errorf("gps_lbs_status: %w", err)
+	}
+
+	if len(content) < 19 {
+		return nil, fmt.Errorf("gps_lbs_status: content too short: %d bytes (need at least 19)", len(content))
+	}
+
+	offset := 0
+
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gps_lbs_status: failed to parse datetime: %w", err)
+	}
+	offset += 6
+
+	// Low nibble of the GPS info byte is the number of satellites
+	satellites := satelliteCount(content[offset], ctx)
+	offset++
+
+	latBytes := content[offset : offset+4]
+	offset += 4
+	lonBytes := content[offset : offset+4]
+	offset += 4
+
+	speed := content[offset]
+	offset++
+
+	courseStatus, err := types.NewCourseStatusFromBytes(content[offset : offset+2])
+	if err != nil {
+		return nil, fmt.Errorf("gps_lbs_status: failed to parse course status: %w", err)
+	}
+	offset += 2
+
+	coords, err := types.NewCoordinatesFromBytes(
+		latBytes, lonBytes,
+		courseStatus.IsNorthLatitude,
+		courseStatus.IsEastLongitude,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("gps_lbs_status: failed to parse coordinates: %w", err)
+	}
+
+	// LBS Length and Info
+	lbsLength := int(content[offset])
+	offset++
+
+	var lbsInfo types.LBSInfo
+	var mccmnc uint32
+	if is4GStatus(p.ProtocolNumber()) {
+		// The 4G length includes the length byte itself
+		if lbsLength > 1 {
+			end := offset + lbsLength - 1
+			if end > len(content) {
+				return nil, fmt.Errorf("gps_lbs_status: LBS length %d exceeds content", lbsLength)
+			}
+			if info, _, err := types.NewLBSInfoFromBytes(content[offset:end], true); err == nil {
+				lbsInfo = info
+				mccmnc = uint32(info.MCC)*1000 + uint32(info.MNC)
+			} else if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+				return nil, fmt.Errorf("gps_lbs_status: %w", err)
+			}
+			offset = end
+		}
+	} else {
+		if offset+8 > len(content) {
+			return nil, fmt.Errorf("gps_lbs_status: content too short for LBS info")
+		}
+		if lbsLength > 0 {
+			if lbsInfo, _, err = types.NewLBSInfoFromBytes(content[offset:offset+8], false); err != nil {
+				if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+					return nil, fmt.Errorf("gps_lbs_status: %w", err)
+				}
+			}
+		}
+		offset += 8
+	}
+
+	// Status block
+	if offset+5 > len(content) {
+		return nil, fmt.Errorf("gps_lbs_status: content too short for status: %d bytes", len(content))
+	}
+	terminalInfo := types.NewTerminalInfo(content[offset])
+	offset++
+	voltageLevel := protocol.VoltageLevel(content[offset])
+	offset++
+	gsmSignal := protocol.GSMSignalStrength(content[offset])
+	offset++
+	if err := checkStatus(ctx, p.ProtocolNumber(), voltageLevel, gsmSignal); err != nil {
+		return nil, fmt.Errorf("gps_lbs_status: %w", err)
+	}
+	alarmType := protocol.AlarmType(content[offset])
+	offset++
+	language := protocol.Language(content[offset])
+	offset++
+
+	// Optional Mileage
+	var mileage uint32
+	if offset+4 <= len(content) {
+		mileage = uint32(content[offset])<<24 | uint32(content[offset+1])<<16 |
+			uint32(content[offset+2])<<8 | uint32(content[offset+3])
+	}
+
+	serialNum, _ := ExtractSerialNumber(data)
+
+	pkt := &packet.GPSLBSStatusPacket{
+		AlarmPacket: packet.AlarmPacket{
+			BasePacket: packet.BasePacket{
+				ProtocolNum: p.ProtocolNumber(),
+				SerialNum:   serialNum,
+				RawData:     data,
+				ParsedAt:    time.Now(),
+			},
+			DateTime:     dt,
+			Satellites:   satellites,
+			Coordinates:  coords,
+			Speed:        speed,
+			CourseStatus: courseStatus,
+			LBSInfo:      lbsInfo,
+			TerminalInfo: terminalInfo,
+			VoltageLevel: voltageLevel,
+			GSMSignal:    gsmSignal,
+			AlarmType:    alarmType,
+			Language:     language,
+			Mileage:      mileage,
+		},
+		MCCMNC: mccmnc,
+	}
+
+	return pkt, nil
+}
+
+// init registers the GPS LBS status parsers with the default registry
+func init() {
+	MustRegister(NewGPSLBSStatusParser(protocol.ProtocolGPSLBSStatus))
+	MustRegister(NewGPSLBSStatusParser(protocol.ProtocolGPSLBSStatus4G))
+	MustRegister(NewGPSLBSStatusParser(protocol.ProtocolGPSLBSStatus4GAlt))
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+func TestGPSLBSStatusParser_Name(t *testing.T) {
+	tests := []struct {
+		protocolNum byte
+		name        string
+	}{
+		{protocol.ProtocolGPSLBSStatus, "GPS LBS Status"},
+		{protocol.ProtocolGPSLBSStatus4G, "GPS LBS Status 4G"},
+		{protocol.ProtocolGPSLBSStatus4GAlt, "GPS LBS Status 4G"},
+	}
+
+	for _, tt := range tests {
+		p := NewGPSLBSStatusParser(tt.protocolNum)
+		if p.ProtocolNumber() != tt.protocolNum {
+			t.Errorf("Expected protocol 0x%02X, got 0x%02X", tt.protocolNum, p.ProtocolNumber())
+		}
+		if p.Name() != tt.name {
+			t.Errorf("Expected name '%s', got '%s'", tt.name, p.Name())
+		}
+	}
+}
+
+func TestGPSLBSStatusParser_Parse(t *testing.T) {
+	tests := []struct {
+		name        string
+		hex         string
+		wantProto   byte
+		wantSats    uint8
+		wantLat     float64
+		wantMCC     uint16
+		wantCellID  uint64
+		wantMCCMNC  uint32
+		wantVoltage protocol.VoltageLevel
+		wantMileage uint32
+		wantSerial  uint16
+		wantErr     bool
+	}{
+		{
+			name:        "2G cell",
+			hex:         "787825160F0C1D030B26C9027AC8180C4658600004000901CC00287D001F7146040300000021BBB20D0A",
+			wantProto:   protocol.ProtocolGPSLBSStatus,
+			wantSats:    9,
+			wantLat:     23.111668,
+			wantMCC:     460,
+			wantCellID:  0x001F71,
+			wantVoltage: protocol.VoltageLevel(0x04),
+			wantSerial:  0x21,
+		},
+		{
+			name:        "2G cell with mileage",
+			hex:         "787829160F0C1D030B26C9027AC8180C4658600004000901CC00287D001F7146040300000001E2400022A7E30D0A",
+			wantProto:   protocol.ProtocolGPSLBSStatus,
+			wantSats:    9,
+			wantLat:     23.111668,
+			wantMCC:     460,
+			wantCellID:  0x001F71,
+			wantVoltage: protocol.VoltageLevel(0x04),
+			wantMileage: 123456,
+			wantSerial:  0x22,
+		},
+		{
+			name:        "4G cell",
+			hex:         "78782C321A011A03121FCA01C3AF5407AC8D200519191002CC100000C60D0000000002CE68EA410604000100738ED50D0A",
+			wantProto:   protocol.ProtocolGPSLBSStatus4G,
+			wantSats:    10,
+			wantMCC:     716,
+			wantMCCMNC:  716016,
+			wantVoltage: protocol.VoltageLevel(0x06),
+			wantSerial:  0x73,
+		},
+		{
+			name:        "4G cell, 0x33 with mileage",
+			hex:         "787830331A011A03121FCA01C3AF5407AC8D200519191002CC100000C60D0000000002CE68EA41060400010000162E00741B130D0A",
+			wantProto:   protocol.ProtocolGPSLBSStatus4GAlt,
+			wantSats:    10,
+			wantMCC:     716,
+			wantMCCMNC:  716016,
+			wantVoltage: protocol.VoltageLevel(0x06),
+			wantMileage: 0x162E,
+			wantSerial:  0x74,
+		},
+		{
+			name:    "packet too short",
+			hex:     "787805160001ABCD0D0A",
+			wantErr: true,
+		},
+	}
+
+	ctx := DefaultContext()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatalf("Failed to decode hex: %v", err)
+			}
+
+			p := NewGPSLBSStatusParser(data[3])
+			pkt, err := p.Parse(data, ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			status, ok := pkt.(*packet.GPSLBSStatusPacket)
+			if !ok {
+				t.Fatalf("Expected *GPSLBSStatusPacket, got %T", pkt)
+			}
+
+			if status.ProtocolNumber() != tt.wantProto {
+				t.Errorf("Protocol: expected 0x%02X, got 0x%02X", tt.wantProto, status.ProtocolNumber())
+			}
+			if status.Satellites != tt.wantSats {
+				t.Errorf("Satellites: expected %d, got %d", tt.wantSats, status.Satellites)
+			}
+			if tt.wantLat != 0 && (status.Latitude() < tt.wantLat-0.0001 || status.Latitude() > tt.wantLat+0.0001) {
+				t.Errorf("Latitude: expected %f, got %f", tt.wantLat, status.Latitude())
+			}
+			if status.LBSInfo.MCC != tt.wantMCC {
+				t.Errorf("MCC: expected %d, got %d", tt.wantMCC, status.LBSInfo.MCC)
+			}
+			if tt.wantCellID != 0 && uint64(status.LBSInfo.CellID) != tt.wantCellID {
+				t.Errorf("CellID: expected %d, got %d", tt.wantCellID, status.LBSInfo.CellID)
+			}
+			if status.MCCMNC != tt.wantMCCMNC {
+				t.Errorf("MCCMNC: expected %d, got %d", tt.wantMCCMNC, status.MCCMNC)
+			}
+			if status.VoltageLevel != tt.wantVoltage {
+				t.Errorf("VoltageLevel: expected %d, got %d", tt.wantVoltage, status.VoltageLevel)
+			}
+			if status.AlarmType != protocol.AlarmNormal {
+				t.Errorf("AlarmType: expected %s, got %s", protocol.AlarmNormal, status.AlarmType)
+			}
+			if status.Mileage != tt.wantMileage {
+				t.Errorf("Mileage: expected %d, got %d", tt.wantMileage, status.Mileage)
+			}
+			if status.SerialNumber() != tt.wantSerial {
+				t.Errorf("Serial: expected %d, got %d", tt.wantSerial, status.SerialNumber())
+			}
+		})
+	}
+}
@@ -46,6 +46,9 @@ func (p *HeartbeatParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 
 	// Parse GSM Signal (1 byte)
 	gsmSignal := protocol.GSMSignalStrength(content[2])
+	if err := checkStatus(ctx, p.ProtocolNumber(), voltageLevel, gsmSignal); err != nil {
+		return nil, fmt.Errorf("heartbeat: %w", err)
+	}
 
 	// Parse Extended Info (2 bytes, optional)
 	var extendedInfo uint16
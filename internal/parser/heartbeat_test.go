@@ -34,7 +34,7 @@ func TestHeartbeatParser_Parse(t *testing.T) {
 	}{
 		{
 			name:        "normal heartbeat - ACC off",
-			hex:         "78780513040300010006950D0A",
+			hex:         "78780813040300010006950D0A",
 			wantVoltage: protocol.VoltageLow,
 			wantSignal:  protocol.SignalNone,
 			wantACCOn:   false,
@@ -42,7 +42,7 @@ func TestHeartbeatParser_Parse(t *testing.T) {
 		},
 		{
 			name:        "heartbeat with ACC on",
-			hex:         "78780513240402010007A50D0A",
+			hex:         "78780813240402010007A50D0A",
 			wantVoltage: protocol.VoltageMedium,
 			wantSignal:  protocol.SignalWeak,
 			wantACCOn:   true,
@@ -50,7 +50,7 @@ func TestHeartbeatParser_Parse(t *testing.T) {
 		},
 		{
 			name:        "heartbeat while charging",
-			hex:         "78780513140504010008B50D0A",
+			hex:         "78780813140504010008B50D0A",
 			wantVoltage: protocol.VoltageHigh,
 			wantSignal:  protocol.SignalStrong,
 			wantACCOn:   false,
@@ -58,7 +58,7 @@ func TestHeartbeatParser_Parse(t *testing.T) {
 		},
 		{
 			name:           "heartbeat with extended info",
-			hex:            "787807130403011234000100C5D50D0A",
+			hex:            "78780B130403011234000100C5D50D0A",
 			wantVoltage:    protocol.VoltageLow,
 			wantSignal:     protocol.SignalExtremelyWeak,
 			wantACCOn:      false,
@@ -67,7 +67,7 @@ func TestHeartbeatParser_Parse(t *testing.T) {
 		},
 		{
 			name:    "packet too short",
-			hex:     "78780313000001ABCD0D0A",
+			hex:     "78780613000001ABCD0D0A",
 			wantErr: true,
 		},
 	}
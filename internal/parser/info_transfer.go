@@ -7,6 +7,7 @@ import (
 	"github.com/fcode09/jimi-vl103m/internal/codec"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 // InfoTransferParser parses information transfer packets (Protocol 0x94)
@@ -38,6 +39,9 @@ func (p *InfoTransferParser) Parse(data []byte, ctx Context) (packet.Packet, err
 	// Parse sub-protocol
 	subProtocol := protocol.InfoType(content[0])
 	infoData := content[1:]
+	if err := checkLimit("info data", len(infoData), ctx.MaxInfoDataLength); err != nil {
+		return nil, fmt.Errorf("info_transfer: %w", err)
+	}
 
 	// Extract serial number
 	serialNum, _ := ExtractSerialNumber(data)
@@ -59,7 +63,9 @@ func (p *InfoTransferParser) Parse(data []byte, ctx Context) (packet.Packet, err
 		p.parseExternalVoltage(pkt, infoData)
 
 	case protocol.InfoTypeICCID:
-		p.parseICCIDInfo(pkt, infoData)
+		if err := p.parseICCIDInfo(pkt, infoData, ctx); err != nil {
+			return nil, fmt.Errorf("info_transfer: %w", err)
+		}
 
 	case protocol.InfoTypeGPSStatus:
 		p.parseGPSStatus(pkt, infoData)
@@ -92,7 +98,8 @@ func (p *InfoTransferParser) parseExternalVoltage(pkt *packet.InfoTransferPacket
 // - IMEI: 8 bytes BCD
 // - IMSI: 8 bytes BCD
 // - ICCID: 10 bytes BCD
-func (p *InfoTransferParser) parseICCIDInfo(pkt *packet.InfoTransferPacket, data []byte) {
+// With ctx.ValidateIMEI an IMEI failing its checksum is a deviation
+func (p *InfoTransferParser) parseICCIDInfo(pkt *packet.InfoTransferPacket, data []byte, ctx Context) error {
 	offset := 0
 
 	// IMEI: 8 bytes
@@ -101,6 +108,13 @@ func (p *InfoTransferParser) parseICCIDInfo(pkt *packet.InfoTransferPacket, data
 		if err == nil {
 			pkt.IMEI = imei
 		}
+		if id, err := types.DeviceIDFromBCD(data[offset : offset+8]); ctx.ValidateIMEI && err == nil && id.IsIMEI() {
+			if _, err := types.NewIMEI(id.String()); err != nil {
+				if err := deviation(ctx, p.ProtocolNumber(), "IMEI", "%v", err); err != nil {
+					return err
+				}
+			}
+		}
 		offset += 8
 	}
 
@@ -120,6 +134,7 @@ func (p *InfoTransferParser) parseICCIDInfo(pkt *packet.InfoTransferPacket, data
 			pkt.ICCID = iccid
 		}
 	}
+	return nil
 }
 
 // parseGPSStatus parses GPS module status
@@ -9,6 +9,9 @@ import (
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
+// lbsNeighborCells is the number of neighbor cells in an LBS packet
+const lbsNeighborCells = 6
+
 // LBSParser parses LBS packets (Protocol 0x28)
 type LBSParser struct {
 	BaseParser
@@ -47,7 +50,7 @@ func (p *LBSParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	offset := 0
 
 	// Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("lbs: failed to parse datetime: %w", err)
 	}
@@ -67,9 +70,13 @@ func (p *LBSParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 
 	mainCell := types.NewLBSInfo(mcc, mnc, lac, uint64(ci))
 
-	// Neighbor Cells
+	// Neighbor Cells (cells beyond ctx.MaxNeighborCells are skipped, not kept)
+	maxCells := lbsNeighborCells
+	if ctx.MaxNeighborCells > 0 && ctx.MaxNeighborCells < maxCells {
+		maxCells = ctx.MaxNeighborCells
+	}
 	var neighborCells []types.LBSInfo
-	for i := 0; i < 6 && offset+6 <= len(content); i++ {
+	for i := 0; i < lbsNeighborCells && offset+6 <= len(content); i++ {
 		nlac := uint32(content[offset])<<8 | uint32(content[offset+1])
 		offset += 2
 		nci := uint32(content[offset])<<16 | uint32(content[offset+1])<<8 | uint32(content[offset+2])
@@ -77,7 +84,11 @@ func (p *LBSParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		//nrssi := content[offset]
 		offset++
 		// The neighbor cells in the doc share MCC and MNC with the main cell.
-		neighborCells = append(neighborCells, types.NewLBSInfo(mcc, mnc, nlac, uint64(nci)))
+		if len(neighborCells) < maxCells {
+			neighborCells = append(neighborCells, types.NewLBSInfo(mcc, mnc, nlac, uint64(nci)))
+		} else if ctx.Logger != nil {
+			ctx.Logger.Debug("neighbor cell dropped", "protocol", "0x28", "index", i, "max_cells", maxCells)
+		}
 	}
 
 	// Timing Advance
@@ -94,6 +105,11 @@ func (p *LBSParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		language = protocol.Language(langCode) // This might be wrong, doc says 2 bytes but language is 1 byte
 		offset += 2
 	}
+	if offset < len(content) {
+		if err := deviation(ctx, p.ProtocolNumber(), "trailing bytes", "%d bytes", len(content)-offset); err != nil {
+			return nil, fmt.Errorf("lbs: %w", err)
+		}
+	}
 
 	// Extract serial number
 	serialNum, _ := ExtractSerialNumber(data)
@@ -142,7 +158,7 @@ func (p *LBS4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	offset := 0
 
 	// Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("lbs_4g: failed to parse datetime: %w", err)
 	}
@@ -170,6 +186,8 @@ func (p *LBS4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		lbsInfo, _, err := types.NewLBSInfoFromBytes(content[offset:], true)
 		if err == nil {
 			pkt.LBSInfo = lbsInfo
+		} else if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+			return nil, fmt.Errorf("lbs_4g: %w", err)
 		}
 	}
 
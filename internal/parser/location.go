@@ -24,10 +24,10 @@ func NewLocationParser() *LocationParser {
 // Parse implements Parser interface
 // Location packet content structure (Protocol 0x22):
 // - DateTime: 6 bytes (YY MM DD HH MM SS)
-// - GPS Info Length: 1 byte (high nibble: satellites, low nibble: GPS data length / 2)
+// - GPS Info: 1 byte (high nibble: GPS info length, low nibble: satellites; see Context.SatelliteNibble)
 // - Latitude: 4 bytes (raw value / 1800000 = decimal degrees)
 // - Longitude: 4 bytes (raw value / 1800000 = decimal degrees)
-// - Speed: 1 byte (km/h)
+// - Speed: 1 byte (km/h; 2 bytes or knots with Context.SpeedFormat)
 // - Course/Status: 2 bytes
 // - MCC: 2 bytes (Mobile Country Code)
 // - MNC: 1 byte (Mobile Network Code)
@@ -37,6 +37,7 @@ func NewLocationParser() *LocationParser {
 // - Data Upload Mode: 1 byte
 // - GPS Data Re-upload: 1 byte (0x00=Real-time, 0x01=Re-upload)
 // - Mileage Statistics: 4 bytes
+// - Voltage Level, GSM Signal: 1 byte each (optional status tail, some firmwares)
 // Total content: 33 bytes minimum (all fields are MANDATORY per protocol spec)
 func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	content, err := ExtractContent(data)
@@ -44,14 +45,14 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 		return nil, fmt.Errorf("location: %w", err)
 	}
 
-	if len(content) < 29 {
-		return nil, fmt.Errorf("location: content too short: %d bytes (need at least 29)", len(content))
+	if minimum := 28 + ctx.SpeedFormat.Size(); len(content) < minimum {
+		return nil, fmt.Errorf("location: content too short: %d bytes (need at least %d)", len(content), minimum)
 	}
 
 	offset := 0
 
 	// Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("location: failed to parse datetime: %w", err)
 	}
@@ -61,7 +62,7 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 	// High nibble (bits 7-4): GPS Info Length indicator
 	// Low nibble (bits 3-0): Number of satellites
 	gpsInfoByte := content[offset]
-	satellites := gpsInfoByte & 0x0F // Low nibble = satellites
+	satellites := satelliteCount(gpsInfoByte, ctx)
 	// gpsInfoLength := (gpsInfoByte >> 4) & 0x0F // High nibble = GPS data length indicator (not used)
 	offset++
 
@@ -73,9 +74,9 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 	lonBytes := content[offset : offset+4]
 	offset += 4
 
-	// Parse Speed (1 byte)
-	speed := content[offset]
-	offset++
+	// Parse Speed (1 byte, or 2 with SpeedKmh16)
+	speed := parseSpeed(content[offset:], ctx)
+	offset += ctx.SpeedFormat.Size()
 
 	// Parse Course/Status (2 bytes)
 	courseStatus, err := types.NewCourseStatusFromBytes(content[offset : offset+2])
@@ -97,7 +98,10 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 	// Parse LBS Info (8 bytes: MCC(2) + MNC(1) + LAC(2) + CellID(3))
 	lbsInfo, lbsConsumed, err := types.NewLBSInfoFromBytes(content[offset:offset+8], false) // false = 2G/3G
 	if err != nil {
-		// LBS parse error is non-fatal, continue with empty LBS
+		// LBS parse error is non-fatal in lenient mode, continue with empty LBS
+		if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+			return nil, fmt.Errorf("location: %w", err)
+		}
 		lbsInfo = types.LBSInfo{}
 		lbsConsumed = 8 // Still consume 8 bytes to maintain offset alignment
 	}
@@ -107,6 +111,9 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 	// According to doc: 0x00=ACC off, 0x01=ACC on
 	// Note: This is different from heartbeat/alarm packets where ACC is bit 1 of status byte
 	accByte := content[offset]
+	if err := checkFlag(ctx, p.ProtocolNumber(), "ACC", accByte); err != nil {
+		return nil, fmt.Errorf("location: %w", err)
+	}
 	accOn := accByte == 0x01
 	// Create empty TerminalInfo since GPS location doesn't have the full status byte
 	// that TerminalInfo expects (which is designed for heartbeat/alarm packets)
@@ -118,15 +125,25 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 	offset++
 
 	// Parse GPS Data Re-upload (1 byte)
+	if err := checkFlag(ctx, p.ProtocolNumber(), "re-upload", content[offset]); err != nil {
+		return nil, fmt.Errorf("location: %w", err)
+	}
 	isReupload := content[offset] == 0x01
 	offset++
 
-	// Parse Mileage Statistics (4 bytes) - Optional
-	var mileage uint32
+	// Parse Mileage Statistics (4 bytes) - Optional, followed by the optional
+	// status tail (Voltage Level + GSM Signal, 2 bytes)
+	var (
+		mileage uint32
+		voltage protocol.VoltageLevel
+		gsm     protocol.GSMSignalStrength
+		hasTail bool
+	)
 	if offset+4 <= len(content) {
 		mileage = uint32(content[offset])<<24 | uint32(content[offset+1])<<16 |
 			uint32(content[offset+2])<<8 | uint32(content[offset+3])
 		offset += 4
+		voltage, gsm, hasTail = parseStatusTail(content[offset:], ctx)
 	}
 
 	// Extract serial number
@@ -139,23 +156,45 @@ func (p *LocationParser) Parse(data []byte, ctx Context) (packet.Packet, error)
 			RawData:     data,
 			ParsedAt:    time.Now(),
 		},
-		DateTime:     dt,
-		Satellites:   satellites,
-		Coordinates:  coords,
-		Speed:        speed,
-		CourseStatus: courseStatus,
-		LBSInfo:      lbsInfo,
-		HasStatus:    true,
-		TerminalInfo: terminalInfo,
-		ACC:          accOn, // GPS Location uses dedicated ACC byte (0x00/0x01)
-		UploadMode:   uploadMode,
-		IsReupload:   isReupload,
-		Mileage:      mileage,
+		DateTime:      dt,
+		Satellites:    satellites,
+		Coordinates:   coords,
+		Speed:         speed,
+		CourseStatus:  courseStatus,
+		LBSInfo:       lbsInfo,
+		HasStatus:     true,
+		TerminalInfo:  terminalInfo,
+		ACC:           accOn, // GPS Location uses dedicated ACC byte (0x00/0x01)
+		UploadMode:    uploadMode,
+		IsReupload:    isReupload,
+		Mileage:       mileage,
+		VoltageLevel:  voltage,
+		GSMSignal:     gsm,
+		HasStatusTail: hasTail,
 	}
 
 	return pkt, nil
 }
 
+// parseStatusTail parses the voltage level and GSM signal some firmwares
+// append to 0x22 after the mileage. The tail is only taken when both bytes
+// are in range; further bytes are ignored.
+func parseStatusTail(tail []byte, ctx Context) (protocol.VoltageLevel, protocol.GSMSignalStrength, bool) {
+	if len(tail) == 0 {
+		return 0, 0, false
+	}
+	if len(tail) < 2 || tail[0] > byte(protocol.VoltageExtremelyHigh) || tail[1] > byte(protocol.SignalStrong) {
+		if ctx.Logger != nil {
+			ctx.Logger.Debug("unknown location tail ignored", "protocol", "0x22", "bytes", len(tail))
+		}
+		return 0, 0, false
+	}
+	if len(tail) > 2 && ctx.Logger != nil {
+		ctx.Logger.Debug("location tail bytes ignored", "protocol", "0x22", "bytes", len(tail)-2)
+	}
+	return protocol.VoltageLevel(tail[0]), protocol.GSMSignalStrength(tail[1]), true
+}
+
 // Location4GParser parses 4G GPS location packets (Protocol 0xA0)
 type Location4GParser struct {
 	BaseParser
@@ -168,6 +207,9 @@ func NewLocation4GParser() *Location4GParser {
 	}
 }
 
+// Parse implements Parser interface
+// 4G location packets have the 0x22 structure with the 4G LBS block
+// (MCC(2) + MNC(1-2) + LAC(4) + CellID(8)) and without the status tail
 func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	content, err := ExtractContent(data)
 	if err != nil {
@@ -177,14 +219,14 @@ func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error
 	// Minimum: DateTime(6) + GPSInfo(1) + Lat(4) + Lon(4) + Speed(1) + Course(2) +
 	// 4G LBS (variable, min 15) + ACC(1) + UploadMode(1) + Reupload(1) + Mileage(4)
 	// = approx 40 bytes minimum
-	if len(content) < 30 {
-		return nil, fmt.Errorf("location_4g: content too short: %d bytes (need at least 30)", len(content))
+	if minimum := 29 + ctx.SpeedFormat.Size(); len(content) < minimum {
+		return nil, fmt.Errorf("location_4g: content too short: %d bytes (need at least %d)", len(content), minimum)
 	}
 
 	offset := 0
 
 	// Parse DateTime (6 bytes)
-	dt, err := types.DateTimeFromBytes(content[offset : offset+6])
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
 	if err != nil {
 		return nil, fmt.Errorf("location_4g: failed to parse datetime: %w", err)
 	}
@@ -194,7 +236,7 @@ func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error
 	// High nibble (bits 7-4): GPS Info Length indicator
 	// Low nibble (bits 3-0): Number of satellites
 	gpsInfoByte := content[offset]
-	satellites := gpsInfoByte & 0x0F // Low nibble = satellites
+	satellites := satelliteCount(gpsInfoByte, ctx)
 	offset++
 
 	// Parse Latitude (4 bytes)
@@ -205,9 +247,9 @@ func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error
 	lonBytes := content[offset : offset+4]
 	offset += 4
 
-	// Parse Speed (1 byte)
-	speed := content[offset]
-	offset++
+	// Parse Speed (1 byte, or 2 with SpeedKmh16)
+	speed := parseSpeed(content[offset:], ctx)
+	offset += ctx.SpeedFormat.Size()
 
 	// Parse Course/Status (2 bytes)
 	courseStatus, err := types.NewCourseStatusFromBytes(content[offset : offset+2])
@@ -231,15 +273,24 @@ func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error
 	lbsInfo, lbsConsumed, err := types.NewLBSInfoFromBytes(content[offset:], true) // true = 4G
 	if err != nil {
 		// If LBS parsing fails, try to estimate consumed bytes
+		if err := deviation(ctx, p.ProtocolNumber(), "LBS", "%v", err); err != nil {
+			return nil, fmt.Errorf("location_4g: %w", err)
+		}
 		lbsInfo = types.LBSInfo{}
 		lbsConsumed = 15 // Minimum 4G LBS size
 	}
 	mccmnc := uint32(lbsInfo.MCC)*1000 + uint32(lbsInfo.MNC)
 	offset += lbsConsumed
+	if offset+3 > len(content) {
+		return nil, fmt.Errorf("location_4g: content too short for status: %d bytes", len(content))
+	}
 
 	// Parse ACC (1 byte) - GPS Location 4G packets use dedicated byte, not bit field
 	// According to doc: 0x00=ACC off, 0x01=ACC on
 	accByte := content[offset]
+	if err := checkFlag(ctx, p.ProtocolNumber(), "ACC", accByte); err != nil {
+		return nil, fmt.Errorf("location_4g: %w", err)
+	}
 	accOn := accByte == 0x01
 	// Create empty TerminalInfo since GPS location doesn't have the full status byte
 	terminalInfo := types.NewTerminalInfo(0)
@@ -250,6 +301,9 @@ func (p *Location4GParser) Parse(data []byte, ctx Context) (packet.Packet, error
 	offset++
 
 	// Parse GPS Data Re-upload (1 byte)
+	if err := checkFlag(ctx, p.ProtocolNumber(), "re-upload", content[offset]); err != nil {
+		return nil, fmt.Errorf("location_4g: %w", err)
+	}
 	isReupload := content[offset] == 0x01
 	offset++
 
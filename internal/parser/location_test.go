@@ -2,6 +2,7 @@ package parser
 
 import (
 	"encoding/hex"
+	"fmt"
 	"testing"
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
@@ -43,19 +44,19 @@ func TestLocationParser_Parse(t *testing.T) {
 	}{
 		{
 			name:           "basic GPS location",
-			hex:            "787822220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a",
+			hex:            "787826220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a",
 			wantPositioned: true,
 			wantErr:        false,
 		},
 		{
 			name:           "location with status",
-			hex:            "78782222180101120000cc026e953b0c3cb40010b401cc0128f8006563010000000003e800022e030d0a",
+			hex:            "78782522180101120000cc026e953b0c3cb40010b401cc0128f8006563010000000003e800022e030d0a",
 			wantPositioned: true,
 			wantErr:        false,
 		},
 		{
 			name:           "location without GPS fix",
-			hex:            "787822220f0c1d02330500000000000000000000000001cc00000100287d000000000000000003442c0d0a",
+			hex:            "787826220f0c1d02330500000000000000000000000001cc00000100287d000000000000000003442c0d0a",
 			wantPositioned: false,
 			wantErr:        false,
 		},
@@ -114,7 +115,7 @@ func TestLocationParser_Coordinates(t *testing.T) {
 	ctx := DefaultContext()
 
 	// Parse a known location packet
-	data, _ := hex.DecodeString("787822220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
+	data, _ := hex.DecodeString("787826220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
 
 	pkt, err := p.Parse(data, ctx)
 	if err != nil {
@@ -142,7 +143,7 @@ func TestLocationParser_DateTime(t *testing.T) {
 	p := NewLocationParser()
 	ctx := DefaultContext()
 
-	data, _ := hex.DecodeString("787822220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
+	data, _ := hex.DecodeString("787826220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
 
 	pkt, err := p.Parse(data, ctx)
 	if err != nil {
@@ -190,7 +191,7 @@ func TestLocationParser_SatelliteCount(t *testing.T) {
 	p := NewLocationParser()
 	ctx := DefaultContext()
 
-	data, _ := hex.DecodeString("787822220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
+	data, _ := hex.DecodeString("787826220f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f710001643f0d0a")
 
 	pkt, err := p.Parse(data, ctx)
 	if err != nil {
@@ -280,3 +281,45 @@ func TestLocation4GParser_ACCStatus(t *testing.T) {
 
 	t.Logf("4G ACC Status: %v (expected: true)", locPkt.ACC)
 }
+
+func TestLocationParser_StatusTail(t *testing.T) {
+	// 33-byte content of "basic GPS location", mileage included
+	const content = "0f0c1d023305c9026b8d550c39771d14140c01cc00000100287d00000000001f71"
+	tests := []struct {
+		name        string
+		tail        string
+		wantTail    bool
+		wantVoltage protocol.VoltageLevel
+		wantGSM     protocol.GSMSignalStrength
+	}{
+		{"no tail", "", false, 0, 0},
+		{"voltage and GSM", "0403", true, protocol.VoltageMedium, protocol.SignalGood},
+		{"extra bytes ignored", "06040000", true, protocol.VoltageExtremelyHigh, protocol.SignalStrong},
+		{"single byte", "04", false, 0, 0},
+		{"GSM out of range", "0409", false, 0, 0},
+	}
+
+	p := NewLocationParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length := 1 + (len(content)+len(tt.tail))/2 + 4
+			data, err := hex.DecodeString(fmt.Sprintf("7878%02x22%s%s000100000d0a", length, content, tt.tail))
+			if err != nil {
+				t.Fatalf("Failed to decode hex: %v", err)
+			}
+
+			pkt, err := p.Parse(data, DefaultContext())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			loc := pkt.(*packet.LocationPacket)
+			if loc.HasStatusTail != tt.wantTail || loc.VoltageLevel != tt.wantVoltage || loc.GSMSignal != tt.wantGSM {
+				t.Errorf("Expected tail %v (%s, %s), got %v (%s, %s)", tt.wantTail, tt.wantVoltage, tt.wantGSM,
+					loc.HasStatusTail, loc.VoltageLevel, loc.GSMSignal)
+			}
+			if loc.Mileage != 0x1f71 {
+				t.Errorf("Expected mileage 0x1F71, got 0x%X", loc.Mileage)
+			}
+		})
+	}
+}
@@ -23,7 +23,7 @@ func NewLoginParser() *LoginParser {
 
 // Parse implements Parser interface
 // Login packet content structure:
-// - IMEI: 8 bytes (BCD encoded, 15 digits + padding)
+// - IMEI: 8 bytes (BCD encoded, 15 digits + padding, or a 16-digit ID)
 // - Model Identification Code: 2 bytes
 // - Timezone/Language: 2 bytes
 // Total content: 12 bytes
@@ -37,15 +37,28 @@ func (p *LoginParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 		return nil, fmt.Errorf("login: content too short: %d bytes (need 12)", len(content))
 	}
 
-	// Parse IMEI (8 bytes BCD)
-	var imei types.IMEI
-	if ctx.ValidateIMEI {
-		imei, err = types.NewIMEIFromBytes(content[0:8])
-	} else {
-		imei, err = types.NewIMEIFromBytesUnchecked(content[0:8])
+	// Parse the device ID (8 bytes BCD); only IMEIs have a checksum
+	mapID := ctx.IDMapper
+	if mapID == nil {
+		mapID = types.DeviceIDFromBCD
 	}
+	id, err := mapID(content[0:8])
 	if err != nil {
-		return nil, fmt.Errorf("login: failed to parse IMEI: %w", err)
+		return nil, fmt.Errorf("login: failed to parse device ID: %w", err)
+	}
+	if !id.IsValid() {
+		return nil, fmt.Errorf("login: empty device ID")
+	}
+	var imei types.IMEI
+	if id.IsIMEI() {
+		if ctx.ValidateIMEI {
+			imei, err = types.NewIMEI(id.String())
+		} else {
+			imei, err = id.IMEI()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("login: failed to parse IMEI: %w", err)
+		}
 	}
 
 	// Parse Model ID (2 bytes big-endian)
@@ -56,6 +69,11 @@ func (p *LoginParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	if err != nil {
 		return nil, fmt.Errorf("login: failed to parse timezone: %w", err)
 	}
+	if len(content) > 12 {
+		if err := deviation(ctx, p.ProtocolNumber(), "trailing bytes", "%d bytes", len(content)-12); err != nil {
+			return nil, fmt.Errorf("login: %w", err)
+		}
+	}
 
 	// Extract serial number
 	serialNum, _ := ExtractSerialNumber(data)
@@ -67,6 +85,7 @@ func (p *LoginParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 			RawData:     data,
 			ParsedAt:    time.Now(),
 		},
+		DeviceID: id,
 		IMEI:     imei,
 		ModelID:  modelID,
 		Timezone: timezone,
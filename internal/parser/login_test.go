@@ -6,6 +6,7 @@ import (
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 func TestLoginParser_ProtocolNumber(t *testing.T) {
@@ -32,7 +33,7 @@ func TestLoginParser_Parse(t *testing.T) {
 	}{
 		{
 			name:        "valid login packet",
-			hex:         "78781101035933907393052380044D014E00015ED00D0A",
+			hex:         "78781201035933907393052380044D014E00015ED00D0A",
 			wantIMEI:    "359339073930523", // 16-digit BCD: 0359339073930523 → last 15 digits
 			wantModelID: 0x8004,
 			wantErr:     false,
@@ -99,7 +100,7 @@ func TestLoginParser_ParseIMEIValidation(t *testing.T) {
 	ctx := Context{ValidateIMEI: false}
 
 	// Valid login packet
-	data, _ := hex.DecodeString("78781101035933907393052380044D014E00015ED00D0A")
+	data, _ := hex.DecodeString("78781201035933907393052380044D014E00015ED00D0A")
 
 	pkt, err := p.Parse(data, ctx)
 	if err != nil {
@@ -119,6 +120,45 @@ func TestLoginParser_ParseIMEIValidation(t *testing.T) {
 	}
 }
 
+func TestLoginParser_DeviceID(t *testing.T) {
+	// Device ID 8612345678901234, model 0x044D, timezone 0x0320 (CRC not checked)
+	data, _ := hex.DecodeString("787811018612345678901234044D03200001ABCD0D0A")
+
+	tests := []struct {
+		name     string
+		ctx      Context
+		wantID   string
+		wantIMEI string
+	}{
+		{"16-digit ID", Context{ValidateIMEI: true}, "8612345678901234", ""},
+		{"custom mapper", Context{IDMapper: func(bcd []byte) (types.DeviceID, error) {
+			return types.DeviceID(hex.EncodeToString(bcd)[1:]), nil
+		}}, "612345678901234", "612345678901234"},
+	}
+	p := NewLoginParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := p.Parse(data, tt.ctx)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			login := pkt.(*packet.LoginPacket)
+			if login.DeviceID.String() != tt.wantID || login.GetIMEI() != tt.wantID {
+				t.Errorf("Expected device ID %s, got %s (GetIMEI %s)", tt.wantID, login.DeviceID, login.GetIMEI())
+			}
+			if login.IMEI.String() != tt.wantIMEI {
+				t.Errorf("Expected IMEI %q, got %q", tt.wantIMEI, login.IMEI)
+			}
+		})
+	}
+
+	// 15-digit IDs are still checked against the IMEI checksum
+	bad, _ := hex.DecodeString("787811010123456789012345044D03200001ABCD0D0A")
+	if _, err := p.Parse(bad, Context{ValidateIMEI: true}); err == nil {
+		t.Error("Expected an IMEI checksum error")
+	}
+}
+
 func TestLoginParser_TimezoneExtraction(t *testing.T) {
 	p := NewLoginParser()
 	ctx := Context{ValidateIMEI: false}
@@ -1,10 +1,19 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 // Parser is the interface that all protocol parsers must implement
@@ -24,23 +33,265 @@ type Parser interface {
 
 // Context provides additional context for parsing
 type Context struct {
-	// StrictMode enables strict validation
+	// StrictMode enables strict validation: fields deviating from the
+	// protocol fail with a *DeviationError instead of being decoded past
 	StrictMode bool
 
-	// ValidateIMEI enables IMEI checksum validation
+	// ValidateIMEI enables IMEI checksum validation of the login packet and,
+	// in strict mode, of the IMEI of ICCID information packets
 	ValidateIMEI bool
 
-	// TimezoneOffset is the default timezone offset in minutes
-	TimezoneOffset int
+	// IDMapper maps the login IMEI field to the device ID
+	// (nil = types.DeviceIDFromBCD)
+	IDMapper types.IDMapper
+
+	// TimeLocation is the timezone offset in minutes of devices whose clock
+	// runs on local time instead of UTC; date-times are converted from it,
+	// or from Timezone once known (nil = UTC)
+	TimeLocation *int
+
+	// Timezone is the timezone of the device reported by its login packet,
+	// set on the decoded date-times (nil = not known yet)
+	Timezone *types.Timezone
+
+	// Timeout bounds the time a single Parse call may take (0 = no limit)
+	Timeout time.Duration
+
+	// MaxStringLength caps variable-length text fields (addresses, commands,
+	// command responses) in bytes (0 = no limit)
+	MaxStringLength int
+
+	// MaxInfoDataLength caps the data of information transfer packets in bytes (0 = no limit)
+	MaxInfoDataLength int
+
+	// MaxNeighborCells caps the neighbor cells kept from LBS packets
+	// (0 = the protocol maximum of 6)
+	MaxNeighborCells int
+
+	// Logger receives debug logs about data a parser skips (nil = no logging)
+	Logger *slog.Logger
+
+	// AcceptZeroTime accepts the all-zero date-times of devices whose clock
+	// is not set, substituting the receive time flagged with ZeroTime
+	AcceptZeroTime bool
+
+	// SatelliteNibble selects the nibble of the GPS info byte holding the
+	// number of satellites (default SatellitesLowNibble, per the protocol)
+	SatelliteNibble SatelliteNibble
+
+	// SpeedFormat selects the encoding of the speed of location packets
+	// (default SpeedKmh, per the protocol)
+	SpeedFormat SpeedFormat
+}
+
+// SatelliteNibble selects the nibble of the GPS info byte that holds the
+// number of satellites. The protocol puts the GPS info length (12) in the
+// high nibble and the satellites in the low one; some firmware swaps them.
+type SatelliteNibble int
+
+const (
+	// SatellitesLowNibble reads the satellites from bits 3-0 (protocol default)
+	SatellitesLowNibble SatelliteNibble = iota
+
+	// SatellitesHighNibble reads the satellites from bits 7-4
+	SatellitesHighNibble
+
+	// SatellitesAuto reads the nibble that is not the GPS info length:
+	// the low one unless only the low one is 12
+	SatellitesAuto
+)
+
+// gpsInfoLength is the GPS info length of the GPS info byte: date-time,
+// satellites, coordinates, speed and course without the date-time
+const gpsInfoLength = 12
+
+// String returns the name of the nibble selection
+func (n SatelliteNibble) String() string {
+	switch n {
+	case SatellitesLowNibble:
+		return "low"
+	case SatellitesHighNibble:
+		return "high"
+	case SatellitesAuto:
+		return "auto"
+	}
+	return fmt.Sprintf("SatelliteNibble(%d)", int(n))
+}
+
+// satelliteCount returns the number of satellites of a GPS info byte
+func satelliteCount(gpsInfo byte, ctx Context) uint8 {
+	high, low := gpsInfo>>4, gpsInfo&0x0F
+	switch ctx.SatelliteNibble {
+	case SatellitesHighNibble:
+		return high
+	case SatellitesAuto:
+		if low == gpsInfoLength && high != gpsInfoLength {
+			if ctx.Logger != nil {
+				ctx.Logger.Debug("satellites read from high nibble", "gps_info", fmt.Sprintf("0x%02X", gpsInfo))
+			}
+			return high
+		}
+	}
+	return low
+}
+
+// SpeedFormat is the encoding of the speed field of location packets (0x22,
+// 0xA0). The protocol sends one byte in km/h; some firmware variants send
+// knots or widen the field to two bytes for speeds above 255 km/h.
+type SpeedFormat int
+
+const (
+	// SpeedKmh is one byte in km/h (protocol default)
+	SpeedKmh SpeedFormat = iota
+
+	// SpeedKnots is one byte in knots, converted to km/h
+	SpeedKnots
+
+	// SpeedKmh16 is two bytes (big-endian) in km/h
+	SpeedKmh16
+)
+
+// kmhPerKnot converts knots to km/h
+const kmhPerKnot = 1.852
+
+// String returns the name of the speed format
+func (f SpeedFormat) String() string {
+	switch f {
+	case SpeedKmh:
+		return "kmh"
+	case SpeedKnots:
+		return "knots"
+	case SpeedKmh16:
+		return "kmh16"
+	}
+	return fmt.Sprintf("SpeedFormat(%d)", int(f))
+}
+
+// Size returns the length of the speed field in bytes
+func (f SpeedFormat) Size() int {
+	if f == SpeedKmh16 {
+		return 2
+	}
+	return 1
+}
+
+// parseSpeed returns the speed in km/h at the start of data, which holds at
+// least ctx.SpeedFormat.Size() bytes
+func parseSpeed(data []byte, ctx Context) uint16 {
+	switch ctx.SpeedFormat {
+	case SpeedKnots:
+		return uint16(math.Round(float64(data[0]) * kmhPerKnot))
+	case SpeedKmh16:
+		return uint16(data[0])<<8 | uint16(data[1])
+	}
+	return uint16(data[0])
 }
 
+// Default limits for variable-length fields
+const (
+	DefaultMaxStringLength   = 1024
+	DefaultMaxInfoDataLength = 4096
+	DefaultMaxNeighborCells  = 6
+)
+
 // DefaultContext returns the default parser context
 func DefaultContext() Context {
 	return Context{
-		StrictMode:     true,
-		ValidateIMEI:   true,
-		TimezoneOffset: 0,
+		StrictMode:        true,
+		ValidateIMEI:      true,
+		MaxStringLength:   DefaultMaxStringLength,
+		MaxInfoDataLength: DefaultMaxInfoDataLength,
+		MaxNeighborCells:  DefaultMaxNeighborCells,
+	}
+}
+
+// parseDateTime decodes a 6-byte date-time, substituting the receive time
+// for an all-zero date-time when ctx.AcceptZeroTime is set, and applies the
+// device timezone of ctx
+func parseDateTime(data []byte, ctx Context) (types.DateTime, error) {
+	dt, err := types.DateTimeFromBytes(data)
+	if ctx.AcceptZeroTime && errors.Is(err, types.ErrZeroDateTime) {
+		dt, err = types.ReceivedDateTime(time.Now()), nil
+	}
+	if err != nil {
+		return dt, err
+	}
+
+	switch {
+	case ctx.TimeLocation != nil && ctx.Timezone != nil:
+		dt = dt.FromDeviceLocal(*ctx.Timezone)
+	case ctx.TimeLocation != nil:
+		dt = dt.FromDeviceLocal(types.Timezone{OffsetMinutes: *ctx.TimeLocation})
+	case ctx.Timezone != nil:
+		dt.Timezone = ctx.Timezone
+	}
+	return dt, nil
+}
+
+// LimitError is returned when a variable-length field exceeds a Context limit
+type LimitError struct {
+	Field string // Name of the field
+	Size  int    // Size of the field in the packet
+	Limit int    // The exceeded limit
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds limit of %d", e.Field, e.Size, e.Limit)
+}
+
+// checkLimit returns a *LimitError if size exceeds limit (0 = no limit)
+func checkLimit(field string, size, limit int) error {
+	if limit > 0 && size > limit {
+		return &LimitError{Field: field, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// DeviationError is returned in strict mode for a field that deviates from
+// the protocol but that lenient mode decodes past (an ACC byte other than
+// 0x00/0x01, a malformed cell, unexpected trailing bytes...)
+type DeviationError struct {
+	Field  string // Name of the field
+	Reason string // How the field deviates
+}
+
+// Error implements the error interface
+func (e *DeviationError) Error() string {
+	return fmt.Sprintf("%s: %s (strict mode)", e.Field, e.Reason)
+}
+
+// deviation reports a field deviating from the protocol: in strict mode it
+// returns a *DeviationError, otherwise it logs it and returns nil so the
+// parser decodes past it
+func deviation(ctx Context, proto byte, field, format string, args ...any) error {
+	reason := fmt.Sprintf(format, args...)
+	if ctx.StrictMode {
+		return &DeviationError{Field: field, Reason: reason}
+	}
+	if ctx.Logger != nil {
+		ctx.Logger.Debug("deviation tolerated", "protocol", fmt.Sprintf("0x%02X", proto), "field", field, "reason", reason)
+	}
+	return nil
+}
+
+// checkStatus reports a voltage level or GSM signal out of the protocol range
+func checkStatus(ctx Context, proto byte, voltage protocol.VoltageLevel, gsm protocol.GSMSignalStrength) error {
+	if voltage > protocol.VoltageExtremelyHigh {
+		return deviation(ctx, proto, "voltage level", "0x%02X out of range", byte(voltage))
 	}
+	if gsm > protocol.SignalStrong {
+		return deviation(ctx, proto, "GSM signal", "0x%02X out of range", byte(gsm))
+	}
+	return nil
+}
+
+// checkFlag reports a boolean byte other than 0x00 or 0x01
+func checkFlag(ctx Context, proto byte, field string, b byte) error {
+	if b > 0x01 {
+		return deviation(ctx, proto, field, "0x%02X is not 0x00 or 0x01", b)
+	}
+	return nil
 }
 
 // Registry maintains a mapping of protocol numbers to parsers
@@ -108,6 +359,108 @@ func (r *Registry) Parse(protocolNum byte, data []byte) (packet.Packet, error) {
 		return nil, fmt.Errorf("no parser registered for protocol 0x%02X", protocolNum)
 	}
 
+	return safeParse(p, data, ctx)
+}
+
+// ParseWithContext is like Parse but uses ctx instead of the registry context
+func (r *Registry) ParseWithContext(protocolNum byte, data []byte, ctx Context) (packet.Packet, error) {
+	r.mu.RLock()
+	p, ok := r.parsers[protocolNum]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no parser registered for protocol 0x%02X", protocolNum)
+	}
+
+	return safeParse(p, data, ctx)
+}
+
+// PanicError is returned when a parser panics, typically when slicing a
+// malformed packet, so one bad packet cannot crash the process
+type PanicError struct {
+	Protocol byte   // Protocol number of the packet
+	Parser   string // Name of the parser that panicked
+	Value    any    // Value passed to panic
+	Hex      string // The offending packet
+}
+
+// Error implements the error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("parser %s (protocol 0x%02X) panicked: %v (packet %s)",
+		e.Parser, e.Protocol, e.Value, e.Hex)
+}
+
+// Unwrap returns the panic value if it is an error (e.g. runtime.Error)
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutError is returned when a parser exceeds Context.Timeout
+type TimeoutError struct {
+	Protocol byte          // Protocol number of the packet
+	Parser   string        // Name of the parser that timed out
+	Timeout  time.Duration // The exceeded deadline
+	Hex      string        // The offending packet
+}
+
+// Error implements the error interface
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("parser %s (protocol 0x%02X) exceeded %s (packet %s)",
+		e.Parser, e.Protocol, e.Timeout, e.Hex)
+}
+
+// safeParse calls p.Parse, converting a panic into a *PanicError and,
+// when ctx.Timeout is set, an overrun into a *TimeoutError
+//
+// Go cannot stop a running goroutine: a parser that times out keeps running
+// in the background and its result is discarded. It works on its own copy of
+// data so the caller may reuse its buffer.
+func safeParse(p Parser, data []byte, ctx Context) (packet.Packet, error) {
+	if ctx.Timeout <= 0 {
+		return recoverParse(p, data, ctx)
+	}
+
+	type result struct {
+		pkt packet.Packet
+		err error
+	}
+	done := make(chan result, 1)
+	own := bytes.Clone(data)
+	go func() {
+		pkt, err := recoverParse(p, own, ctx)
+		done <- result{pkt, err}
+	}()
+
+	timer := time.NewTimer(ctx.Timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.pkt, r.err
+	case <-timer.C:
+		return nil, &TimeoutError{
+			Protocol: p.ProtocolNumber(),
+			Parser:   p.Name(),
+			Timeout:  ctx.Timeout,
+			Hex:      strings.ToUpper(hex.EncodeToString(data)),
+		}
+	}
+}
+
+// recoverParse calls p.Parse, converting a panic into a *PanicError
+func recoverParse(p Parser, data []byte, ctx Context) (pkt packet.Packet, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			pkt = nil
+			err = &PanicError{
+				Protocol: p.ProtocolNumber(),
+				Parser:   p.Name(),
+				Value:    v,
+				Hex:      strings.ToUpper(hex.EncodeToString(data)),
+			}
+		}
+	}()
 	return p.Parse(data, ctx)
 }
 
@@ -208,50 +561,73 @@ func (p *BaseParser) Name() string {
 	return p.name
 }
 
-// ExtractContent extracts the content portion of a packet (excluding header/footer)
+// ErrLengthMismatch is wrapped by LengthError
+var ErrLengthMismatch = errors.New("invalid packet length: length mismatch")
+
+// LengthError is returned when the declared length of a packet disagrees
+// with its size, so the content and serial number cannot be located
+type LengthError struct {
+	Declared int // Length field: protocol number to CRC
+	Actual   int // Bytes between the length field and the stop bit
+}
+
+// Error implements the error interface
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("declared length %d does not match the %d bytes of the packet", e.Declared, e.Actual)
+}
+
+// Unwrap returns ErrLengthMismatch
+func (e *LengthError) Unwrap() error {
+	return ErrLengthMismatch
+}
+
+// framePayload returns the offsets of the protocol number and of the stop
+// bit of a packet, checking them against the declared length
 // For 0x7878 packets: StartBit(2) + Length(1) + Protocol(1) + Content + Serial(2) + CRC(2) + StopBit(2)
 // For 0x7979 packets: StartBit(2) + Length(2) + Protocol(1) + Content + Serial(2) + CRC(2) + StopBit(2)
-func ExtractContent(data []byte) ([]byte, error) {
+func framePayload(data []byte) (start, end int, err error) {
 	if len(data) < 10 {
-		return nil, fmt.Errorf("packet too small: %d bytes", len(data))
+		return 0, 0, fmt.Errorf("packet too small: %d bytes", len(data))
 	}
 
-	startBit := uint16(data[0])<<8 | uint16(data[1])
-
-	var contentStart int
-	var contentEnd int
-
-	switch startBit {
+	var declared int
+	switch startBit := uint16(data[0])<<8 | uint16(data[1]); startBit {
 	case 0x7878:
-		// Short packet: 2 (start) + 1 (len) + 1 (proto) = 4
-		contentStart = 4
-		// End is 6 bytes from end: 2 (serial) + 2 (crc) + 2 (stop)
-		contentEnd = len(data) - 6
+		start, declared = 3, int(data[2])
 	case 0x7979:
-		// Long packet: 2 (start) + 2 (len) + 1 (proto) = 5
-		contentStart = 5
-		// End is same: 6 bytes from end
-		contentEnd = len(data) - 6
+		start, declared = 4, int(data[2])<<8|int(data[3])
 	default:
-		return nil, fmt.Errorf("invalid start bit: 0x%04X", startBit)
+		return 0, 0, fmt.Errorf("invalid start bit: 0x%04X", startBit)
 	}
 
-	if contentEnd < contentStart {
-		return nil, fmt.Errorf("invalid packet structure")
+	// The length counts protocol number, content, serial number and CRC
+	end = len(data) - 2
+	if declared != end-start || declared < 5 {
+		return 0, 0, &LengthError{Declared: declared, Actual: end - start}
 	}
+	return start, end, nil
+}
 
-	return data[contentStart:contentEnd], nil
+// ExtractContent extracts the content portion of a packet (excluding
+// header/footer). It returns a *LengthError if the declared length does not
+// match the packet.
+func ExtractContent(data []byte) ([]byte, error) {
+	start, end, err := framePayload(data)
+	if err != nil {
+		return nil, err
+	}
+	// Content follows the protocol number and ends before serial and CRC
+	return data[start+1 : end-4], nil
 }
 
-// ExtractSerialNumber extracts the serial number from a packet
+// ExtractSerialNumber extracts the serial number from a packet. It returns
+// a *LengthError if the declared length does not match the packet.
 func ExtractSerialNumber(data []byte) (uint16, error) {
-	if len(data) < 10 {
-		return 0, fmt.Errorf("packet too small")
+	_, end, err := framePayload(data)
+	if err != nil {
+		return 0, err
 	}
-
-	// Serial number is at position [len-6:len-4]
-	serialOffset := len(data) - 6
-	return uint16(data[serialOffset])<<8 | uint16(data[serialOffset+1]), nil
+	return uint16(data[end-4])<<8 | uint16(data[end-3]), nil
 }
 
 // IsShortPacket returns true if the packet uses 0x7878 format
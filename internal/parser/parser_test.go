@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// slicingParser reads a fixed offset without checking the length
+type slicingParser struct{}
+
+func (slicingParser) ProtocolNumber() byte { return 0x99 }
+func (slicingParser) Name() string         { return "Slicing" }
+func (slicingParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
+	_ = data[40]
+	return &packet.BasePacket{ProtocolNum: 0x99}, nil
+}
+
+// blockingParser never returns until release is closed
+type blockingParser struct{ release chan struct{} }
+
+func (blockingParser) ProtocolNumber() byte { return 0x98 }
+func (blockingParser) Name() string         { return "Blocking" }
+func (p blockingParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
+	<-p.release
+	return &packet.BasePacket{ProtocolNum: 0x98}, nil
+}
+
+func TestRegistry_ParseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	r := NewRegistry()
+	r.MustRegister(blockingParser{release})
+	r.MustRegister(slicingParser{})
+
+	ctx := DefaultContext()
+	ctx.Timeout = 20 * time.Millisecond
+	data := []byte{0x78, 0x78, 0x05, 0x98, 0x00, 0x01, 0xAB, 0xCD, 0x0D, 0x0A}
+
+	pkt, err := r.ParseWithContext(0x98, data, ctx)
+	if pkt != nil {
+		t.Errorf("Expected no packet, got %v", pkt)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected TimeoutError, got %v", err)
+	}
+	if timeoutErr.Parser != "Blocking" || timeoutErr.Timeout != ctx.Timeout || timeoutErr.Hex != "787805980001ABCD0D0A" {
+		t.Errorf("Unexpected timeout info: %+v", timeoutErr)
+	}
+
+	// Panics are still recovered when the parser runs on its own goroutine
+	data[3] = 0x99
+	if _, err := r.ParseWithContext(0x99, data, ctx); !errors.As(err, new(*PanicError)) {
+		t.Errorf("Expected PanicError with a timeout set, got %v", err)
+	}
+}
+
+func TestRegistry_ParseRecoversPanics(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(slicingParser{})
+
+	data := []byte{0x78, 0x78, 0x05, 0x99, 0x00, 0x01, 0xAB, 0xCD, 0x0D, 0x0A}
+
+	for name, parse := range map[string]func() (packet.Packet, error){
+		"Parse":            func() (packet.Packet, error) { return r.Parse(0x99, data) },
+		"ParseWithContext": func() (packet.Packet, error) { return r.ParseWithContext(0x99, data, DefaultContext()) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			pkt, err := parse()
+			if pkt != nil {
+				t.Errorf("Expected no packet, got %v", pkt)
+			}
+
+			var panicErr *PanicError
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("Expected PanicError, got %v", err)
+			}
+			if panicErr.Protocol != 0x99 || panicErr.Parser != "Slicing" {
+				t.Errorf("Unexpected parser info: %+v", panicErr)
+			}
+			if panicErr.Hex != "787805990001ABCD0D0A" || !strings.Contains(err.Error(), panicErr.Hex) {
+				t.Errorf("Expected error to include packet hex, got %q", err)
+			}
+
+			var rtErr runtime.Error
+			if !errors.As(err, &rtErr) {
+				t.Errorf("Expected the runtime error to be unwrapped, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		hex         string
+		wantContent string
+		wantSerial  uint16
+		wantErr     bool
+		wantLength  bool // a *LengthError
+	}{
+		{"short packet", "78780813040300000195A50D0A", "040300", 0x0001, false, false},
+		{"long packet", "797900079400000003A1B20D0A", "0000", 0x0003, false, false},
+		{"no content", "7878058A0001FC960D0A", "", 0x0001, false, false},
+		{"declared too short", "78780513040300010006950D0A", "", 0, true, true},
+		{"declared too long", "78780B13040300010006950D0A", "", 0, true, true},
+		{"long declared too long", "797900099400000003A1B20D0A", "", 0, true, true},
+		{"declared below minimum", "79790004940001AB0D0A", "", 0, true, true},
+		{"invalid start bit", "78770A13040300010006950D0A", "", 0, true, false},
+		{"too small", "7878050D0A", "", 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ExtractContent(data)
+			serial, serialErr := ExtractSerialNumber(data)
+			if tt.wantErr {
+				if err == nil || serialErr == nil {
+					t.Fatalf("Expected errors, got %v and %v", err, serialErr)
+				}
+				var lengthErr *LengthError
+				if errors.As(err, &lengthErr) != tt.wantLength || errors.Is(serialErr, ErrLengthMismatch) != tt.wantLength {
+					t.Errorf("Expected a length error %v, got %v and %v", tt.wantLength, err, serialErr)
+				}
+				return
+			}
+			if err != nil || serialErr != nil {
+				t.Fatalf("Unexpected errors %v and %v", err, serialErr)
+			}
+			if got := strings.ToUpper(hex.EncodeToString(content)); got != tt.wantContent {
+				t.Errorf("Expected content %s, got %s", tt.wantContent, got)
+			}
+			if serial != tt.wantSerial {
+				t.Errorf("Expected serial %d, got %d", tt.wantSerial, serial)
+			}
+		})
+	}
+}
+
+func TestSatelliteCount(t *testing.T) {
+	tests := []struct {
+		gpsInfo byte
+		nibble  SatelliteNibble
+		want    uint8
+	}{
+		{0xC9, SatellitesLowNibble, 9},
+		{0xC9, SatellitesHighNibble, 12},
+		{0xC9, SatellitesAuto, 9},
+		{0x9C, SatellitesLowNibble, 12},
+		{0x9C, SatellitesHighNibble, 9},
+		{0x9C, SatellitesAuto, 9},
+		{0xCC, SatellitesAuto, 12}, // 12 satellites with the protocol length
+		{0x05, SatellitesAuto, 5},  // no length reported
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%02X_%s", tt.gpsInfo, tt.nibble), func(t *testing.T) {
+			if got := satelliteCount(tt.gpsInfo, Context{SatelliteNibble: tt.nibble}); got != tt.want {
+				t.Errorf("satelliteCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	tests := []struct {
+		data   []byte
+		format SpeedFormat
+		want   uint16
+	}{
+		{[]byte{0x3C, 0x01}, SpeedKmh, 60},
+		{[]byte{0x3C, 0x01}, SpeedKnots, 111}, // 60 kn = 111.12 km/h
+		{[]byte{0xFF}, SpeedKnots, 472},
+		{[]byte{0x01, 0x2C}, SpeedKmh16, 300},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%X_%s", tt.data, tt.format), func(t *testing.T) {
+			if got := parseSpeed(tt.data, Context{SpeedFormat: tt.format}); got != tt.want {
+				t.Errorf("parseSpeed() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviation(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser Parser
+		hex    string
+		field  string
+	}{
+		{"ACC byte", NewLocationParser(), "787822221A02010E02118901C31ADC07ABA0CA00189301361A1234005678020000003C00B80D0A", "ACC"},
+		{"voltage level", NewHeartbeatParser(), "78780813040900010006950D0A", "voltage level"},
+		{"time calibration content", NewTimeCalibrationParser(), "7878068A00000100000D0A", "content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatalf("Failed to decode hex: %v", err)
+			}
+
+			_, err = tt.parser.Parse(data, Context{StrictMode: true})
+			var dev *DeviationError
+			if !errors.As(err, &dev) || dev.Field != tt.field {
+				t.Fatalf("Expected a %s deviation in strict mode, got %v", tt.field, err)
+			}
+			if _, err := tt.parser.Parse(data, Context{}); err != nil {
+				t.Errorf("Expected lenient mode to decode past the deviation, got %v", err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package parser
 // - Login (0x01)
 // - Heartbeat (0x13)
 // - Command Response Old (0x15)
+// - GPS LBS Status (0x16)
 // - Chinese Address Response (0x17)
 // - Command Response (0x21)
 // - GPS Location (0x22)
@@ -14,12 +15,15 @@ package parser
 // - Alarm Multi-Fence (0x27)
 // - LBS Multi-Base (0x28)
 // - GPS Address Request (0x2A)
+// - WiFi Info (0x2C)
+// - GPS LBS Status 4G (0x32, 0x33)
 // - Online Command (0x80)
 // - Time Calibration (0x8A)
 // - Information Transfer (0x94)
 // - English Address Response (0x97)
 // - GPS Location 4G (0xA0)
 // - LBS Multi-Base 4G (0xA1)
+// - WiFi Info 4G (0xA2)
 // - Alarm 4G (0xA4)
 
 // Ensure all parser files are compiled by referencing something from each.
@@ -34,6 +38,8 @@ var _ = NewAlarmParser
 var _ = NewAlarmMultiFenceParser
 var _ = NewLBSParser
 var _ = NewGPSAddressRequestParser
+var _ = NewGPSLBSStatusParser
+var _ = NewWiFiParser
 var _ = NewOnlineCommandParser
 var _ = NewTimeCalibrationParser
 var _ = NewInfoTransferParser
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
@@ -23,7 +24,15 @@ func NewTimeCalibrationParser() *TimeCalibrationParser {
 // Time calibration packet has no content - it's just a request
 func (p *TimeCalibrationParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
 	// Extract serial number
-	serialNum, _ := ExtractSerialNumber(data)
+	serialNum, err := ExtractSerialNumber(data)
+	if err != nil {
+		return nil, fmt.Errorf("time_calibration: %w", err)
+	}
+	if content, err := ExtractContent(data); err == nil && len(content) > 0 {
+		if err := deviation(ctx, p.ProtocolNumber(), "content", "%d unexpected bytes", len(content)); err != nil {
+			return nil, fmt.Errorf("time_calibration: %w", err)
+		}
+	}
 
 	pkt := &packet.TimeCalibrationPacket{
 		BasePacket: packet.BasePacket{
@@ -30,14 +30,19 @@ func TestTimeCalibrationParser_Parse(t *testing.T) {
 	}{
 		{
 			name:    "time calibration request",
-			hex:     "7878058A00010003870D0A",
+			hex:     "7878058A0001FC960D0A",
 			wantErr: false,
 		},
 		{
 			name:    "another time calibration",
-			hex:     "7878058A00020004980D0A",
+			hex:     "7878058A0002CE0D0D0A",
 			wantErr: false,
 		},
+		{
+			name:    "declared length mismatch",
+			hex:     "7878058A00010003870D0A",
+			wantErr: true,
+		},
 	}
 
 	p := NewTimeCalibrationParser()
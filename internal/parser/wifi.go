@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Neighbor cell sizes in WiFi packets: LAC + CellID + RSSI
+const (
+	wifiNeighborSize2G = 2 + 3 + 1
+	wifiNeighborSize4G = 4 + 8 + 1
+)
+
+// WiFiParser parses WiFi information packets (Protocols 0x2C and 0xA2)
+type WiFiParser struct {
+	BaseParser
+}
+
+// NewWiFiParser creates a new WiFi parser for protocol.ProtocolWiFi or
+// protocol.ProtocolWiFi4G
+func NewWiFiParser(protocolNum byte) *WiFiParser {
+	name := "WiFi Info"
+	if protocolNum == protocol.ProtocolWiFi4G {
+		name = "WiFi Info 4G"
+	}
+	return &WiFiParser{
+		BaseParser: NewBaseParser(protocolNum, name),
+	}
+}
+
+// Parse implements Parser interface
+// WiFi packet content structure:
+// - DateTime: 6 bytes
+// - Main cell (0x2C): MCC(2) + MNC(1) + LAC(2) + CellID(3) + RSSI(1)
+// - Main cell (0xA2): MCC(2) + MNC(1-2) + LAC(4) + CellID(8) + RSSI(1)
+// - Neighbor cells: 6 x LAC + CellID + RSSI, sized like the main cell
+// - Timing Advance: 1 byte
+// - WiFi Count: 1 byte
+// - Access points: WiFi Count x MAC(6) + RSSI(1)
+// Neighbor slots without a cell (LAC and CellID zero) are skipped.
+func (p *WiFiParser) Parse(data []byte, ctx Context) (packet.Packet, error) {
+	content, err := ExtractContent(data)
+	if err != nil {
+		return nil, fmt.Errorf("wifi: %w", err)
+	}
+
+	is4G := p.ProtocolNumber() == protocol.ProtocolWiFi4G
+	neighborSize := wifiNeighborSize2G
+	if is4G {
+		neighborSize = wifiNeighborSize4G
+	}
+
+	if len(content) < 6+8 {
+		return nil, fmt.Errorf("wifi: content too short: %d bytes", len(content))
+	}
+
+	offset := 0
+
+	dt, err := parseDateTime(content[offset:offset+6], ctx)
+	if err != nil {
+		return nil, fmt.Errorf("wifi: failed to parse datetime: %w", err)
+	}
+	offset += 6
+
+	// Main cell
+	mainCell, n, err := types.NewLBSInfoFromBytes(content[offset:], is4G)
+	if err != nil {
+		return nil, fmt.Errorf("wifi: failed to parse cell: %w", err)
+	}
+	offset += n + 1 // RSSI is not stored in LBSInfo
+
+	// Neighbor cells (cells beyond ctx.MaxNeighborCells are skipped, not kept)
+	if offset+lbsNeighborCells*neighborSize+2 > len(content) {
+		return nil, fmt.Errorf("wifi: content too short for neighbor cells: %d bytes", len(content))
+	}
+	maxCells := lbsNeighborCells
+	if ctx.MaxNeighborCells > 0 && ctx.MaxNeighborCells < maxCells {
+		maxCells = ctx.MaxNeighborCells
+	}
+	var neighborCells []types.LBSInfo
+	for range lbsNeighborCells {
+		cell := content[offset : offset+neighborSize]
+		offset += neighborSize
+
+		var lac uint32
+		var ci uint64
+		if is4G {
+			lac = uint32(cell[0])<<24 | uint32(cell[1])<<16 | uint32(cell[2])<<8 | uint32(cell[3])
+			for _, b := range cell[4:12] {
+				ci = ci<<8 | uint64(b)
+			}
+		} else {
+			lac = uint32(cell[0])<<8 | uint32(cell[1])
+			ci = uint64(cell[2])<<16 | uint64(cell[3])<<8 | uint64(cell[4])
+		}
+		if lac == 0 && ci == 0 {
+			continue
+		}
+		if len(neighborCells) < maxCells {
+			neighborCells = append(neighborCells, types.NewLBSInfo(mainCell.MCC, mainCell.MNC, lac, ci))
+		}
+	}
+
+	timingAdvance := content[offset]
+	offset++
+
+	// Access points
+	count := int(content[offset])
+	offset++
+	if offset+count*types.WiFiAccessPointSize > len(content) {
+		return nil, fmt.Errorf("wifi: %d access points exceed content", count)
+	}
+	accessPoints := make([]types.WiFiAccessPoint, 0, count)
+	for range count {
+		ap, err := types.NewWiFiAccessPointFromBytes(content[offset : offset+types.WiFiAccessPointSize])
+		if err != nil {
+			if err := deviation(ctx, p.ProtocolNumber(), "access point", "%v", err); err != nil {
+				return nil, fmt.Errorf("wifi: %w", err)
+			}
+		}
+		accessPoints = append(accessPoints, ap)
+		offset += types.WiFiAccessPointSize
+	}
+	if offset < len(content) {
+		if err := deviation(ctx, p.ProtocolNumber(), "trailing bytes", "%d bytes", len(content)-offset); err != nil {
+			return nil, fmt.Errorf("wifi: %w", err)
+		}
+	}
+
+	serialNum, _ := ExtractSerialNumber(data)
+
+	pkt := &packet.WiFiInfoPacket{
+		BasePacket: packet.BasePacket{
+			ProtocolNum: p.ProtocolNumber(),
+			SerialNum:   serialNum,
+			RawData:     data,
+			ParsedAt:    time.Now(),
+		},
+		DateTime:      dt,
+		LBSInfo:       mainCell,
+		NeighborCells: neighborCells,
+		TimingAdvance: timingAdvance,
+		AccessPoints:  accessPoints,
+	}
+
+	return pkt, nil
+}
+
+// init registers the WiFi parsers with the default registry
+func init() {
+	MustRegister(NewWiFiParser(protocol.ProtocolWiFi))
+	MustRegister(NewWiFiParser(protocol.ProtocolWiFi4G))
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestWiFiParser_Name(t *testing.T) {
+	tests := []struct {
+		protocolNum byte
+		name        string
+	}{
+		{protocol.ProtocolWiFi, "WiFi Info"},
+		{protocol.ProtocolWiFi4G, "WiFi Info 4G"},
+	}
+
+	for _, tt := range tests {
+		p := NewWiFiParser(tt.protocolNum)
+		if p.ProtocolNumber() != tt.protocolNum {
+			t.Errorf("Expected protocol 0x%02X, got 0x%02X", tt.protocolNum, p.ProtocolNumber())
+		}
+		if p.Name() != tt.name {
+			t.Errorf("Expected name '%s', got '%s'", tt.name, p.Name())
+		}
+	}
+}
+
+func TestWiFiParser_Parse(t *testing.T) {
+	tests := []struct {
+		name          string
+		hex           string
+		maxCells      int
+		wantCell      types.LBSInfo
+		wantNeighbors int
+		wantTA        uint8
+		wantAPs       []types.WiFiAccessPoint
+		wantSerial    uint16
+		wantErr       bool
+	}{
+		{
+			name:          "2G cells",
+			hex:           "78784F2C1A011A03121F01CC0027BD001EB80027BD001EB90027BE001F41000000000000000000000000000000000000000000000000000303A45E60E31C0230001A2B3C4D5E4BF09FC211223353003125BF0D0A",
+			wantCell:      types.NewLBSInfo(460, 0, 10173, 7864),
+			wantNeighbors: 2,
+			wantTA:        3,
+			wantAPs: []types.WiFiAccessPoint{
+				{MAC: [6]byte{0xA4, 0x5E, 0x60, 0xE3, 0x1C, 0x02}, RSSI: -48},
+				{MAC: [6]byte{0x00, 0x1A, 0x2B, 0x3C, 0x4D, 0x5E}, RSSI: -75},
+				{MAC: [6]byte{0xF0, 0x9F, 0xC2, 0x11, 0x22, 0x33}, RSSI: -83},
+			},
+			wantSerial: 0x31,
+		},
+		{
+			name:          "2G cells, neighbor limit",
+			hex:           "78784F2C1A011A03121F01CC0027BD001EB80027BD001EB90027BE001F41000000000000000000000000000000000000000000000000000303A45E60E31C0230001A2B3C4D5E4BF09FC211223353003125BF0D0A",
+			maxCells:      1,
+			wantCell:      types.NewLBSInfo(460, 0, 10173, 7864),
+			wantNeighbors: 1,
+			wantTA:        3,
+			wantAPs: []types.WiFiAccessPoint{
+				{MAC: [6]byte{0xA4, 0x5E, 0x60, 0xE3, 0x1C, 0x02}, RSSI: -48},
+				{MAC: [6]byte{0x00, 0x1A, 0x2B, 0x3C, 0x4D, 0x5E}, RSSI: -75},
+				{MAC: [6]byte{0xF0, 0x9F, 0xC2, 0x11, 0x22, 0x33}, RSSI: -83},
+			},
+			wantSerial: 0x31,
+		},
+		{
+			name:          "4G cells",
+			hex:           "787879A21A011A03121F02CC100000C60D0000000002CE68EA000000C60D0000000002CE68EB0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000002A45E60E31C0230001A2B3C4D5E4B003205A50D0A",
+			wantCell:      types.NewLBSInfo(716, 16, 50701, 47081706),
+			wantNeighbors: 1,
+			wantAPs: []types.WiFiAccessPoint{
+				{MAC: [6]byte{0xA4, 0x5E, 0x60, 0xE3, 0x1C, 0x02}, RSSI: -48},
+				{MAC: [6]byte{0x00, 0x1A, 0x2B, 0x3C, 0x4D, 0x5E}, RSSI: -75},
+			},
+			wantSerial: 0x32,
+		},
+		{
+			name:    "access point count exceeds content",
+			hex:     "78784F2C1A011A03121F01CC0027BD001EB80027BD001EB90027BE001F41000000000000000000000000000000000000000000000000000309A45E60E31C0230001A2B3C4D5E4BF09FC211223353003125BF0D0A",
+			wantErr: true,
+		},
+		{
+			name:    "packet too short",
+			hex:     "7878052C0001ABCD0D0A",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatalf("Failed to decode hex: %v", err)
+			}
+
+			ctx := DefaultContext()
+			ctx.MaxNeighborCells = tt.maxCells
+			pkt, err := NewWiFiParser(data[3]).Parse(data, ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			wifi, ok := pkt.(*packet.WiFiInfoPacket)
+			if !ok {
+				t.Fatalf("Expected *WiFiInfoPacket, got %T", pkt)
+			}
+
+			if wifi.ProtocolNumber() != data[3] {
+				t.Errorf("Protocol: expected 0x%02X, got 0x%02X", data[3], wifi.ProtocolNumber())
+			}
+			if wifi.LBSInfo != tt.wantCell {
+				t.Errorf("Cell: expected %s, got %s", tt.wantCell, wifi.LBSInfo)
+			}
+			if len(wifi.NeighborCells) != tt.wantNeighbors {
+				t.Errorf("Neighbors: expected %d, got %d", tt.wantNeighbors, len(wifi.NeighborCells))
+			}
+			if wifi.TimingAdvance != tt.wantTA {
+				t.Errorf("TimingAdvance: expected %d, got %d", tt.wantTA, wifi.TimingAdvance)
+			}
+			if len(wifi.AccessPoints) != len(tt.wantAPs) {
+				t.Fatalf("AccessPoints: expected %d, got %d", len(tt.wantAPs), len(wifi.AccessPoints))
+			}
+			for i, ap := range wifi.AccessPoints {
+				if ap != tt.wantAPs[i] {
+					t.Errorf("AccessPoint %d: expected %s, got %s", i, tt.wantAPs[i], ap)
+				}
+			}
+			if wifi.SerialNumber() != tt.wantSerial {
+				t.Errorf("Serial: expected %d, got %d", tt.wantSerial, wifi.SerialNumber())
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package rawlog reads and writes the raw packet logs of cmd/tcp-server
+// (-save-raw):
+//
+//	# Jimi VL103M GPS Tracker Raw Packet Log
+//	# Connection: tcp 10.0.0.7:40312
+//	[2024-06-15 14:30:45.120] RX 787811010359339073930523044d01f4000168db0d0a
+//	[2024-06-15 14:30:45.121] TX 787805010001d9dc0d0a
+//
+// RX lines are what the device sent, TX lines what the server answered.
+// Lines starting with '#' are comments.
+package rawlog
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the timestamp format of raw logs (local time)
+const TimestampLayout = "2006-01-02 15:04:05.000"
+
+// Record is one RX or TX line of a raw log
+type Record struct {
+	// Line is the line number in the log (1-based)
+	Line int
+
+	// At is when the data was received or sent
+	At time.Time
+
+	// RX is true for data sent by the device, false for server responses
+	RX bool
+
+	// Data is the raw bytes; a read may hold several or partial packets
+	Data []byte
+}
+
+// Format returns the log line of data received (rx) or sent at the given
+// time, including the trailing newline
+func Format(at time.Time, rx bool, data []byte) string {
+	direction := "TX"
+	if rx {
+		direction = "RX"
+	}
+	return fmt.Sprintf("[%s] %s %s\n", at.Format(TimestampLayout), direction, hex.EncodeToString(data))
+}
+
+// Read parses the RX and TX lines of a raw log, skipping blank and comment
+// lines. Timestamps are read in the local time zone, like tcp-server writes them.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rec, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rec.Line = n
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// parseLine parses one "[timestamp] RX|TX hex" line
+func parseLine(line string) (Record, error) {
+	stamp, rest, ok := strings.Cut(strings.TrimPrefix(line, "["), "] ")
+	if !ok {
+		return Record{}, fmt.Errorf("expected [timestamp] RX|TX hex")
+	}
+	at, err := time.ParseInLocation(TimestampLayout, stamp, time.Local)
+	if err != nil {
+		return Record{}, err
+	}
+	dir, hexData, _ := strings.Cut(rest, " ")
+	if dir != "RX" && dir != "TX" {
+		return Record{}, fmt.Errorf("unknown direction %q", dir)
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(hexData))
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{At: at, RX: dir == "RX", Data: data}, nil
+}
@@ -0,0 +1,62 @@
+package rawlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatRead(t *testing.T) {
+	at := time.Date(2024, 6, 15, 14, 30, 45, 120e6, time.Local)
+	login := []byte{0x78, 0x78, 0x11, 0x01}
+	ack := []byte{0x78, 0x78, 0x05, 0x01}
+
+	log := "# Jimi VL103M GPS Tracker Raw Packet Log\n#\n" +
+		Format(at, true, login) +
+		"\n" +
+		Format(at.Add(time.Millisecond), false, ack)
+	if !strings.Contains(log, "[2024-06-15 14:30:45.120] RX 78781101\n") {
+		t.Errorf("Unexpected log:\n%s", log)
+	}
+
+	records, err := Read(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	want := []Record{
+		{Line: 3, At: at, RX: true, Data: login},
+		{Line: 5, At: at.Add(time.Millisecond), RX: false, Data: ack},
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Line != w.Line || !r.At.Equal(w.At) || r.RX != w.RX || !bytes.Equal(r.Data, w.Data) {
+			t.Errorf("record %d = %+v, want %+v", i, r, w)
+		}
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"no timestamp", "RX 78781101"},
+		{"bad timestamp", "[2024-06-15] RX 78781101"},
+		{"unknown direction", "[2024-06-15 14:30:45.120] XX 78781101"},
+		{"bad hex", "[2024-06-15 14:30:45.120] RX 7878zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader("# header\n" + tt.line + "\n"))
+			if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+				t.Errorf("Read() error = %v, want a line 2 error", err)
+			}
+		})
+	}
+}
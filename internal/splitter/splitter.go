@@ -1,6 +1,7 @@
 package splitter
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
@@ -20,19 +21,115 @@ import (
 //	Input:  [CompletePacket1][CompletePacket2][PartialPacket3]
 //	Output: packets=[Packet1, Packet2], residue=[PartialPacket3]
 func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
+	return SplitPacketsFunc(data, nil)
+}
+
+// DiscardFunc is called with bytes the splitter skips while looking for the
+// next packet, and the reason they were skipped
+type DiscardFunc func(discarded []byte, reason string)
+
+// ErrDiscardLimit is returned by SplitPacketsPolicy when more bytes than
+// Policy.MaxDiscard were discarded in one call
+var ErrDiscardLimit = errors.New("discard limit exceeded")
+
+// LimitError is returned when a declared packet length or the residue
+// exceeds a Policy limit. The offending bytes are discarded up to the next
+// start bit and splitting goes on; the error is returned with the packets.
+type LimitError struct {
+	Limit string // "declared length" or "residue size"
+	Size  int    // Offending size in bytes
+	Max   int    // The exceeded limit
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds limit of %d", e.Limit, e.Size, e.Max)
+}
+
+// Policy controls how the splitter handles corrupted and oversized data
+type Policy struct {
+	// Resync makes corrupted data non-fatal: garbage is discarded with a nil
+	// error, packets before and after it are returned, and a trailing byte
+	// that may start the next packet is kept as residue
+	Resync bool
+
+	// MaxScan bounds the bytes searched for a start bit in one call (0 = no
+	// limit); once exceeded, the rest is returned as residue and searched on
+	// the next call, so one call never scans a long run of garbage
+	MaxScan int
+
+	// MaxDiscard bounds the bytes discarded in one call (0 = no limit);
+	// beyond it the split stops with ErrDiscardLimit
+	MaxDiscard int
+
+	// MaxLength bounds the declared length of a packet (0 = no limit)
+	MaxLength int
+
+	// MaxResidue bounds the residue held for an incomplete packet (0 = no limit)
+	MaxResidue int
+
+	// Discard is called for every run of discarded bytes
+	Discard DiscardFunc
+}
+
+// SplitPacketsFunc is like SplitPackets but calls discard (if not nil) for
+// every run of bytes skipped while resynchronizing on a start bit
+func SplitPacketsFunc(data []byte, discard DiscardFunc) (packets [][]byte, residue []byte, err error) {
+	return SplitPacketsPolicy(data, Policy{Discard: discard})
+}
+
+// SplitPacketsPolicy is like SplitPackets but handles corrupted and
+// oversized data according to policy
+func SplitPacketsPolicy(data []byte, policy Policy) (packets [][]byte, residue []byte, err error) {
 	if len(data) == 0 {
 		return nil, nil, nil
 	}
 
 	packets = make([][]byte, 0)
 	offset := 0
+	discarded := 0
+	discard := policy.Discard
+	var limitErr error // first *LimitError, returned if nothing worse happens
+
+	// resync discards bytes from a corrupted offset up to the next start bit
+	// (see Policy) and reports whether splitting goes on
+	resync := func(reason string) bool {
+		end := len(data)
+		if policy.MaxScan > 0 {
+			end = min(end, offset+2+max(policy.MaxScan-discarded, 0))
+		}
+		next := findNextStartBit(data[:end], offset+1)
+		exhausted := next == -1 && end < len(data)
+		if next == -1 {
+			next = end
+			// The last byte may be the first half of a start bit
+			if isStartByte(data[end-1]) && end-1 > offset {
+				next = end - 1
+			}
+		}
+
+		if discard != nil {
+			discard(data[offset:next], reason)
+		}
+		discarded += next - offset
+		offset = next
+		if policy.MaxDiscard > 0 && discarded > policy.MaxDiscard {
+			err = fmt.Errorf("%w: %d bytes (max %d)", ErrDiscardLimit, discarded, policy.MaxDiscard)
+			return false
+		}
+		if exhausted {
+			residue = data[offset:]
+			return false
+		}
+		return true
+	}
 
 	for offset < len(data) {
 		// Need at least 4 bytes to determine packet type and length
 		if len(data)-offset < 4 {
 			// Not enough data for a packet header, keep as residue
 			residue = data[offset:]
-			return packets, residue, nil
+			return packets, residue, limitErr
 		}
 
 		// Check for valid start bit
@@ -46,7 +143,7 @@ func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
 			lengthFieldSize = protocol.LengthFieldSizeShort // 1 byte
 			if len(data)-offset < 3 {
 				residue = data[offset:]
-				return packets, residue, nil
+				return packets, residue, limitErr
 			}
 			packetLengthField = int(data[offset+2])
 
@@ -54,18 +151,30 @@ func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
 			lengthFieldSize = protocol.LengthFieldSizeLong // 2 bytes
 			if len(data)-offset < 4 {
 				residue = data[offset:]
-				return packets, residue, nil
+				return packets, residue, limitErr
 			}
 			packetLengthField = int(data[offset+2])<<8 | int(data[offset+3])
 
 		default:
+			if policy.Resync {
+				if !resync("no start bit") {
+					return packets, residue, err
+				}
+				continue
+			}
 			// Invalid start bit - try to find next valid start bit
 			nextOffset := findNextStartBit(data, offset+1)
 			if nextOffset == -1 {
 				// No valid start bit found, discard all remaining data
+				if discard != nil {
+					discard(data[offset:], "no start bit")
+				}
 				return packets, nil, fmt.Errorf("no valid start bit found at offset %d: 0x%04X", offset, startBit)
 			}
 			// Skip to next valid start bit
+			if discard != nil {
+				discard(data[offset:nextOffset], "no start bit")
+			}
 			offset = nextOffset
 			continue
 		}
@@ -75,11 +184,33 @@ func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
 		// where PacketLengthField = ProtocolNum + Content + SerialNum + CRC
 		totalSize := protocol.StartBitSize + lengthFieldSize + packetLengthField + protocol.StopBitSize
 
+		// A header with an absurd length (e.g. 0xFFFF) is corrupted data,
+		// not a packet to wait for
+		if policy.MaxLength > 0 && packetLengthField > policy.MaxLength {
+			if limitErr == nil {
+				limitErr = &LimitError{Limit: "declared length", Size: packetLengthField, Max: policy.MaxLength}
+			}
+			if !resync("declared length too large") {
+				return packets, residue, err
+			}
+			continue
+		}
+
 		// Check if we have enough data for the complete packet
 		if len(data)-offset < totalSize {
+			if policy.MaxResidue > 0 && len(data)-offset > policy.MaxResidue {
+				// Stuck waiting for completion: look for a packet after the header
+				if limitErr == nil {
+					limitErr = &LimitError{Limit: "residue size", Size: len(data) - offset, Max: policy.MaxResidue}
+				}
+				if !resync("residue too large") {
+					return packets, residue, err
+				}
+				continue
+			}
 			// Incomplete packet, keep as residue
 			residue = data[offset:]
-			return packets, residue, nil
+			return packets, residue, limitErr
 		}
 
 		// Extract the packet
@@ -89,13 +220,25 @@ func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
 		stopBitOffset := totalSize - 2
 		stopBit := uint16(packet[stopBitOffset])<<8 | uint16(packet[stopBitOffset+1])
 		if stopBit != protocol.StopBit {
+			if policy.Resync {
+				if !resync("invalid stop bit") {
+					return packets, residue, err
+				}
+				continue
+			}
 			// Invalid stop bit - might be corrupted packet
 			// Try to find next valid start bit
 			nextOffset := findNextStartBit(data, offset+1)
 			if nextOffset == -1 {
+				if discard != nil {
+					discard(data[offset:], "invalid stop bit")
+				}
 				return packets, nil, fmt.Errorf("invalid stop bit at offset %d: expected 0x%04X, got 0x%04X",
 					offset+stopBitOffset, protocol.StopBit, stopBit)
 			}
+			if discard != nil {
+				discard(data[offset:nextOffset], "invalid stop bit")
+			}
 			offset = nextOffset
 			continue
 		}
@@ -105,7 +248,12 @@ func SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
 		offset += totalSize
 	}
 
-	return packets, nil, nil
+	return packets, nil, limitErr
+}
+
+// isStartByte reports whether b is the first byte of a start bit
+func isStartByte(b byte) bool {
+	return b == byte(protocol.StartBitShort>>8) || b == byte(protocol.StartBitLong>>8)
 }
 
 // findNextStartBit searches for the next valid start bit in the data
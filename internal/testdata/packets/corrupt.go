@@ -0,0 +1,201 @@
+package packets
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/internal/validator"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// CorruptedPackets contains every mutation (see Corrupt) of a generated
+// heartbeat, location and alarm packet. Each one breaks a single rule of the
+// protocol, so a strict decoder must reject all of them.
+var CorruptedPackets = CorruptPackets(generatedToCorrupt())
+
+// ErrNotApplicable is returned by Corrupt when a mutation does not apply to
+// a packet, such as a date-time mutation of a heartbeat
+var ErrNotApplicable = errors.New("mutation not applicable to the packet")
+
+// Mutation is a targeted change that makes a valid packet invalid
+type Mutation int
+
+const (
+	// MutateCRC flips every bit of the checksum
+	MutateCRC Mutation = iota
+
+	// MutateStartBit changes the start bit to 0x7877
+	MutateStartBit
+
+	// MutateStopBit changes the stop bit to 0x0D0B
+	MutateStopBit
+
+	// MutateLength declares one byte more than the packet has, with a
+	// checksum matching the altered length
+	MutateLength
+
+	// MutateTooShort keeps only the start bit, the length and the stop bit
+	MutateTooShort
+
+	// MutateTruncateContent keeps the first byte of the content, with the
+	// length and checksum of the shorter packet, so only the parser fails
+	MutateTruncateContent
+
+	// MutateDateTime sets the month of the date-time that starts the
+	// content to 13, with a matching checksum
+	MutateDateTime
+)
+
+// mutationNames are the names of the mutations, used in corrupted packet names
+var mutationNames = map[Mutation]string{
+	MutateCRC:             "crc",
+	MutateStartBit:        "start_bit",
+	MutateStopBit:         "stop_bit",
+	MutateLength:          "length",
+	MutateTooShort:        "too_short",
+	MutateTruncateContent: "truncated_content",
+	MutateDateTime:        "date_time",
+}
+
+// String returns the name of the mutation
+func (m Mutation) String() string {
+	if name, ok := mutationNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("mutation(%d)", int(m))
+}
+
+// Mutations returns every mutation
+func Mutations() []Mutation {
+	return []Mutation{
+		MutateCRC, MutateStartBit, MutateStopBit, MutateLength,
+		MutateTooShort, MutateTruncateContent, MutateDateTime,
+	}
+}
+
+// dateTimeProtocols are the protocols whose content starts with a date-time
+var dateTimeProtocols = map[byte]bool{
+	protocol.ProtocolGPSLBSStatus:      true,
+	protocol.ProtocolGPSLocation:       true,
+	protocol.ProtocolAlarm:             true,
+	protocol.ProtocolAlarmMultiFence:   true,
+	protocol.ProtocolLBSMultiBase:      true,
+	protocol.ProtocolGPSAddressRequest: true,
+	protocol.ProtocolWiFi:              true,
+	protocol.ProtocolGPSLBSStatus4G:    true,
+	protocol.ProtocolGPSLBSStatus4GAlt: true,
+	protocol.ProtocolGPSLocation4G:     true,
+	protocol.ProtocolLBSMultiBase4G:    true,
+	protocol.ProtocolWiFi4G:            true,
+	protocol.ProtocolAlarmMultiFence4G: true,
+}
+
+// Corrupt returns a copy of a valid packet with the mutation m applied. The
+// packet must have a valid structure; the mutations that keep the structure
+// intact recompute the CRC-ITU (X.25) checksum, so they only break the rule
+// they target.
+func Corrupt(frame []byte, m Mutation) ([]byte, error) {
+	if len(frame) < protocol.MinPacketSize || frame[0] != frame[1] ||
+		(frame[0] != 0x78 && frame[0] != 0x79) {
+		return nil, fmt.Errorf("corrupt: not a packet: % X", frame)
+	}
+	long := frame[0] == 0x79
+	header := 3 // start bit and length
+	if long {
+		header = 4
+	}
+	// content follows the protocol number and ends before the serial number
+	content := frame[header+1 : len(frame)-6]
+	out := append([]byte(nil), frame...)
+
+	switch m {
+	case MutateCRC:
+		out[len(out)-4] ^= 0xFF
+		out[len(out)-3] ^= 0xFF
+		return out, nil
+	case MutateStartBit:
+		out[1]--
+		return out, nil
+	case MutateStopBit:
+		out[len(out)-1]++
+		return out, nil
+	case MutateLength:
+		return frameWith(frame[:header], frame[header], content, frame[len(frame)-6:len(frame)-4], 1), nil
+	case MutateTooShort:
+		return append(out[:header:header], 0x0D, 0x0A), nil
+	case MutateTruncateContent:
+		if len(content) < 2 {
+			return nil, ErrNotApplicable
+		}
+		return frameWith(frame[:header], frame[header], content[:1], frame[len(frame)-6:len(frame)-4], 0), nil
+	case MutateDateTime:
+		if !dateTimeProtocols[frame[header]] || len(content) < 6 {
+			return nil, ErrNotApplicable
+		}
+		content = append([]byte(nil), content...)
+		content[1] = 13
+		return frameWith(frame[:header], frame[header], content, frame[len(frame)-6:len(frame)-4], 0), nil
+	}
+	return nil, fmt.Errorf("corrupt: unknown %v", m)
+}
+
+// frameWith frames a protocol number, content and serial number with the
+// start bit of header and a valid checksum. The declared length is extra
+// bytes longer than the packet.
+func frameWith(header []byte, protocolNum byte, content, serial []byte, extra int) []byte {
+	length := 1 + len(content) + 2 + 2 + extra // protocol, content, serial, CRC
+	out := []byte{header[0], header[1]}
+	if len(header) == 4 {
+		out = append(out, byte(length>>8), byte(length))
+	} else {
+		out = append(out, byte(length))
+	}
+	out = append(out, protocolNum)
+	out = append(out, content...)
+	out = append(out, serial...)
+	out = validator.AppendCRC(out)
+	return append(out, 0x0D, 0x0A)
+}
+
+// CorruptPackets applies every mutation that applies to each of the valid
+// packets. The corrupted packets are named after the packet and the
+// mutation, such as "generated_heartbeat_crc".
+func CorruptPackets(valid []TestPacket) []TestPacket {
+	var out []TestPacket
+	for _, tp := range valid {
+		frame, err := hex.DecodeString(tp.Hex)
+		if err != nil {
+			panic(fmt.Sprintf("%s: %v", tp.Name, err)) // the corpus is valid hex
+		}
+		for _, m := range Mutations() {
+			data, err := Corrupt(frame, m)
+			if errors.Is(err, ErrNotApplicable) {
+				continue
+			}
+			if err != nil {
+				panic(fmt.Sprintf("%s: %v", tp.Name, err)) // the packets are valid
+			}
+			out = append(out, TestPacket{
+				Name:        tp.Name + "_" + m.String(),
+				Hex:         strings.ToUpper(hex.EncodeToString(data)),
+				Protocol:    tp.Protocol,
+				Description: fmt.Sprintf("%s with mutation %s", tp.Description, m),
+				Valid:       false,
+			})
+		}
+	}
+	return out
+}
+
+// generatedToCorrupt returns generated heartbeat, location and alarm packets
+func generatedToCorrupt() []TestPacket {
+	g := NewGenerator(2)
+	var out []TestPacket
+	for _, proto := range []byte{protocol.ProtocolHeartbeat, protocol.ProtocolGPSLocation, protocol.ProtocolAlarm} {
+		tp, _ := g.Packet(proto)
+		out = append(out, tp)
+	}
+	return out
+}
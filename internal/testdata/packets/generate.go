@@ -0,0 +1,429 @@
+package packets
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// GeneratedPackets contains one generated packet of every protocol
+// (see Generator). Unlike the hand-written samples above, they decode
+// without WithSkipCRC or WithoutIMEIValidation.
+var GeneratedPackets = NewGenerator(1).Packets()
+
+// generatedStart is the time of the first generated packet
+var generatedStart = time.Date(2024, 6, 15, 8, 0, 0, 0, time.UTC)
+
+// city is a place generated positions are scattered around, with the
+// network of its cell towers and its UTC offset
+type city struct {
+	lat, lon float64
+	mcc, mnc uint16
+	offset   int // minutes
+}
+
+// cities spread the generated positions over all four hemispheres
+var cities = []city{
+	{-33.8688, 151.2093, 505, 1, 600},  // Sydney
+	{-23.5505, -46.6333, 724, 5, -180}, // Sao Paulo
+	{52.5200, 13.4050, 262, 1, 60},     // Berlin
+	{19.4326, -99.1332, 334, 20, -360}, // Mexico City
+	{22.5431, 114.0579, 460, 0, 480},   // Shenzhen
+	{-1.2921, 36.8219, 639, 2, 180},    // Nairobi
+}
+
+// Generator builds valid packets for every protocol the decoder parses:
+// each carries a correct CRC-ITU (X.25) checksum, a device IMEI with a
+// valid check digit and realistic values (positions around a few cities
+// with a cell of the local network, speeds, courses and device status).
+// Packets follow each other every 30 seconds with increasing serial
+// numbers. A generator with the same seed always builds the same packets.
+type Generator struct {
+	rng    *rand.Rand
+	enc    *encoder.Encoder
+	imei   string
+	city   city
+	serial uint16
+}
+
+// builder builds the packet of one protocol
+type builder struct {
+	protocol byte
+	name     string
+	build    func(g *Generator, protocolNum byte) []byte
+}
+
+// builders covers every protocol registered with the decoder
+var builders = []builder{
+	{protocol.ProtocolLogin, "login", (*Generator).login},
+	{protocol.ProtocolHeartbeat, "heartbeat", (*Generator).heartbeat},
+	{protocol.ProtocolCommandResponseOld, "command_response_old", (*Generator).commandResponse},
+	{protocol.ProtocolGPSLBSStatus, "gps_lbs_status", (*Generator).status},
+	{protocol.ProtocolAddressResponseChinese, "address_response_chinese", (*Generator).addressResponse},
+	{protocol.ProtocolCommandResponse, "command_response", (*Generator).commandResponse},
+	{protocol.ProtocolGPSLocation, "location", (*Generator).location},
+	{protocol.ProtocolAlarm, "alarm", (*Generator).alarm},
+	{protocol.ProtocolAlarmMultiFence, "alarm_multi_fence", (*Generator).alarm},
+	{protocol.ProtocolLBSMultiBase, "lbs", (*Generator).lbs},
+	{protocol.ProtocolGPSAddressRequest, "gps_address_request", (*Generator).addressRequest},
+	{protocol.ProtocolWiFi, "wifi", (*Generator).wifi},
+	{protocol.ProtocolGPSLBSStatus4G, "gps_lbs_status_4g", (*Generator).status},
+	{protocol.ProtocolGPSLBSStatus4GAlt, "gps_lbs_status_4g_alt", (*Generator).status},
+	{protocol.ProtocolOnlineCommand, "online_command", (*Generator).onlineCommand},
+	{protocol.ProtocolTimeCalibration, "time_calibration", (*Generator).timeCalibration},
+	{protocol.ProtocolInfoTransfer, "info_transfer", (*Generator).infoTransfer},
+	{protocol.ProtocolAddressResponseEnglish, "address_response_english", (*Generator).addressResponse},
+	{protocol.ProtocolGPSLocation4G, "location_4g", (*Generator).location},
+	{protocol.ProtocolLBSMultiBase4G, "lbs_4g", (*Generator).lbs4G},
+	{protocol.ProtocolWiFi4G, "wifi_4g", (*Generator).wifi},
+	{protocol.ProtocolAlarmMultiFence4G, "alarm_4g", (*Generator).alarm},
+}
+
+// NewGenerator creates a generator for one device, chosen by seed
+func NewGenerator(seed uint64) *Generator {
+	g := &Generator{
+		rng: rand.New(rand.NewPCG(seed, seed^0x9E3779B97F4A7C15)),
+		enc: encoder.New(),
+	}
+	g.city = cities[g.rng.IntN(len(cities))]
+
+	// A VL103M type allocation code, a random serial and the Luhn check digit
+	digits := fmt.Sprintf("86802004%06d", g.rng.IntN(1000000))
+	g.imei = digits + fmt.Sprint(luhn(digits))
+	return g
+}
+
+// IMEI returns the IMEI of the generated device
+func (g *Generator) IMEI() string {
+	return g.imei
+}
+
+// Packets returns one new packet of every protocol, in protocol number order
+func (g *Generator) Packets() []TestPacket {
+	all := make([]TestPacket, 0, len(builders))
+	for _, b := range builders {
+		all = append(all, g.packet(b))
+	}
+	return all
+}
+
+// Packet returns a new packet of a protocol, or false if the decoder has no
+// parser for it
+func (g *Generator) Packet(protocolNum byte) (TestPacket, bool) {
+	for _, b := range builders {
+		if b.protocol == protocolNum {
+			return g.packet(b), true
+		}
+	}
+	return TestPacket{}, false
+}
+
+// Alarm returns a new 0x26 alarm packet of an alarm type, e.g. one of the
+// ACC alarms Packets never picks
+func (g *Generator) Alarm(alarmType protocol.AlarmType) TestPacket {
+	return g.packet(builder{protocol.ProtocolAlarm, "alarm", func(g *Generator, _ byte) []byte {
+		p := g.alarmPacket(false)
+		p.AlarmType = alarmType
+		return g.enc.Alarm(p)
+	}})
+}
+
+// packet builds the next packet with b
+func (g *Generator) packet(b builder) TestPacket {
+	g.serial++
+	return TestPacket{
+		Name:        "generated_" + b.name,
+		Hex:         strings.ToUpper(hex.EncodeToString(b.build(g, b.protocol))),
+		Protocol:    b.protocol,
+		Description: fmt.Sprintf("Generated %s packet of device %s", strings.ReplaceAll(b.name, "_", " "), g.imei),
+		Valid:       true,
+	}
+}
+
+// now returns the device time of the current packet
+func (g *Generator) now() types.DateTime {
+	return types.NewDateTime(generatedStart.Add(time.Duration(g.serial) * 30 * time.Second))
+}
+
+// position returns a position within about 10 km of the city
+func (g *Generator) position() types.Coordinates {
+	return types.MustNewCoordinates(
+		g.city.lat+(g.rng.Float64()-0.5)*0.2,
+		g.city.lon+(g.rng.Float64()-0.5)*0.2,
+	)
+}
+
+// course returns the course of a positioned real-time fix at coords
+func (g *Generator) course(coords types.Coordinates) types.CourseStatus {
+	return types.NewCourseStatus(uint16(g.rng.IntN(360)), true, true, coords.IsEast, coords.IsNorth)
+}
+
+// satellites returns the number of satellites of a fix
+func (g *Generator) satellites() uint8 {
+	return uint8(5 + g.rng.IntN(8))
+}
+
+// cell returns a cell of the local network; 4G cells have 28-bit IDs
+func (g *Generator) cell(is4G bool) types.LBSInfo {
+	lac := uint32(1 + g.rng.IntN(0xFFFE))
+	if is4G {
+		return types.NewLBSInfo(g.city.mcc, g.city.mnc, lac, uint64(1+g.rng.IntN(0xFFFFFFE)))
+	}
+	return types.NewLBSInfo(g.city.mcc, g.city.mnc, lac, uint64(1+g.rng.IntN(0xFFFFFE)))
+}
+
+// terminal returns the status byte of a tracking device
+func (g *Generator) terminal() types.TerminalInfo {
+	return types.NewTerminalInfoBuilder().
+		SetGPSTracking(true).
+		SetACCOn(g.rng.IntN(2) == 1).
+		SetCharging(g.rng.IntN(2) == 1).
+		Build()
+}
+
+// voltage returns a battery level of a device in normal use
+func (g *Generator) voltage() protocol.VoltageLevel {
+	return protocol.VoltageLow + protocol.VoltageLevel(g.rng.IntN(3))
+}
+
+// signal returns a usable GSM signal strength
+func (g *Generator) signal() protocol.GSMSignalStrength {
+	return protocol.SignalWeak + protocol.GSMSignalStrength(g.rng.IntN(3))
+}
+
+// alarmType returns a common alarm
+func (g *Generator) alarmType() protocol.AlarmType {
+	alarms := []protocol.AlarmType{
+		protocol.AlarmSOS, protocol.AlarmPowerCut, protocol.AlarmVibration,
+		protocol.AlarmGeofenceEnter, protocol.AlarmGeofenceExit, protocol.AlarmSpeed,
+	}
+	return alarms[g.rng.IntN(len(alarms))]
+}
+
+// mileage returns an odometer reading in meters
+func (g *Generator) mileage() uint32 {
+	return uint32(1 + g.rng.IntN(500000000))
+}
+
+func (g *Generator) login(byte) []byte {
+	p := packet.NewLoginPacket(types.MustNewIMEI(g.imei), 0x4D01, types.Timezone{
+		OffsetMinutes: g.city.offset,
+		Language:      protocol.LanguageEnglish,
+	})
+	p.SerialNum = g.serial
+	data, err := g.enc.Login(p)
+	if err != nil {
+		panic(err) // the IMEI is always valid
+	}
+	return data
+}
+
+func (g *Generator) heartbeat(byte) []byte {
+	p := packet.NewHeartbeatPacket(g.terminal(), g.voltage(), g.signal())
+	p.SerialNum = g.serial
+	return g.enc.Heartbeat(p)
+}
+
+// location builds a 0x22 or 0xA0 packet
+func (g *Generator) location(protocolNum byte) []byte {
+	coords := g.position()
+	p := packet.NewLocationPacket(g.now(), coords, uint16(g.rng.IntN(120)), g.course(coords))
+	p.SerialNum = g.serial
+	p.Satellites = g.satellites()
+	p.ACC = p.Speed > 0
+	p.UploadMode = protocol.UploadModeInterval
+	p.Mileage = g.mileage()
+
+	if protocolNum == protocol.ProtocolGPSLocation4G {
+		p.ProtocolNum = protocolNum
+		p.LBSInfo = g.cell(true)
+		return g.enc.Location4G(&packet.Location4GPacket{LocationPacket: *p})
+	}
+	p.LBSInfo = g.cell(false)
+	return g.enc.Location(p)
+}
+
+// alarmPacket returns the alarm fields shared by the alarm and status packets
+func (g *Generator) alarmPacket(is4G bool) *packet.AlarmPacket {
+	coords := g.position()
+	p := packet.NewAlarmPacket(g.now(), coords, g.alarmType())
+	p.SerialNum = g.serial
+	p.Satellites = g.satellites()
+	p.Speed = uint8(g.rng.IntN(120))
+	p.CourseStatus = g.course(coords)
+	p.LBSInfo = g.cell(is4G)
+	p.TerminalInfo = g.terminal()
+	p.VoltageLevel = g.voltage()
+	p.GSMSignal = g.signal()
+	p.Language = protocol.LanguageEnglish
+	p.Mileage = g.mileage()
+	return p
+}
+
+// alarm builds a 0x26, 0x27 or 0xA4 packet
+func (g *Generator) alarm(protocolNum byte) []byte {
+	if protocolNum == protocol.ProtocolAlarmMultiFence {
+		p := g.alarmPacket(false)
+		return g.enc.AlarmMultiFence(&packet.AlarmMultiFencePacket{AlarmPacket: *p, FenceID: uint8(1 + g.rng.IntN(5))})
+	}
+	if protocolNum == protocol.ProtocolAlarmMultiFence4G {
+		p := &packet.Alarm4GPacket{AlarmPacket: *g.alarmPacket(true), FenceID: uint8(1 + g.rng.IntN(5))}
+		p.ProtocolNum = protocolNum
+		return g.enc.Alarm4G(p)
+	}
+	return g.enc.Alarm(g.alarmPacket(false))
+}
+
+// status builds the packets with the alarm layout the encoder has no
+// builder for: GPS LBS status (0x16, 0x32, 0x33)
+func (g *Generator) status(protocolNum byte) []byte {
+	is4G := protocolNum == protocol.ProtocolGPSLBSStatus4G || protocolNum == protocol.ProtocolGPSLBSStatus4GAlt
+	p := g.alarmPacket(is4G)
+	p.AlarmType = protocol.AlarmNormal
+
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, p.Speed, p.CourseStatus)
+	lbs := p.LBSInfo.Bytes2G()
+	if is4G {
+		lbs = p.LBSInfo.Bytes4G(false)
+	}
+	content = append(content, byte(len(lbs)+1)) // the length counts itself
+	content = append(content, lbs...)
+	content = append(content, p.TerminalInfo.Raw(), byte(p.VoltageLevel), byte(p.GSMSignal), byte(p.AlarmType), byte(p.Language))
+	content = append(content, byte(p.Mileage>>24), byte(p.Mileage>>16), byte(p.Mileage>>8), byte(p.Mileage))
+	return g.enc.CustomResponse(protocolNum, content, g.serial)
+}
+
+// lbs builds a 0x28 packet with neighbor cells
+func (g *Generator) lbs(byte) []byte {
+	p := packet.NewLBSPacket(g.now(), g.cell(false))
+	p.SerialNum = g.serial
+	for range 1 + g.rng.IntN(6) {
+		p.NeighborCells = append(p.NeighborCells, g.cell(false))
+	}
+	p.TimingAdvance = uint8(g.rng.IntN(64))
+	p.Language = protocol.LanguageEnglish
+	return g.enc.LBS(p)
+}
+
+// lbs4G builds a 0xA1 packet: the serving cell and the status of the device
+func (g *Generator) lbs4G(byte) []byte {
+	content := append(g.now().ToBytes(), g.cell(true).Bytes4G(false)...)
+	content = append(content, g.terminal().Raw(), byte(g.voltage()), byte(g.signal()), byte(protocol.UploadModeInterval))
+	return g.enc.CustomResponse(protocol.ProtocolLBSMultiBase4G, content, g.serial)
+}
+
+// wifi builds a 0x2C or 0xA2 packet with a few access points
+func (g *Generator) wifi(protocolNum byte) []byte {
+	is4G := protocolNum == protocol.ProtocolWiFi4G
+	var aps []types.WiFiAccessPoint
+	for range 1 + g.rng.IntN(5) {
+		var ap types.WiFiAccessPoint
+		for i := range ap.MAC {
+			ap.MAC[i] = byte(g.rng.IntN(256))
+		}
+		ap.MAC[0] &^= 0x01 // unicast
+		ap.RSSI = -int8(40 + g.rng.IntN(50))
+		aps = append(aps, ap)
+	}
+	p := packet.NewWiFiInfoPacket(g.now(), g.cell(is4G), aps)
+	p.ProtocolNum = protocolNum
+	p.SerialNum = g.serial
+	p.NeighborCells = []types.LBSInfo{g.cell(is4G), g.cell(is4G)}
+	data, err := g.enc.WiFiInfo(p)
+	if err != nil {
+		panic(err) // at most 5 access points
+	}
+	return data
+}
+
+// addressRequest builds a 0x2A packet asking for the address of a fix
+func (g *Generator) addressRequest(byte) []byte {
+	coords := g.position()
+	content := appendGPS(nil, g.now(), g.satellites(), coords, uint8(g.rng.IntN(120)), g.course(coords))
+	content = append(content, []byte(strings.Repeat("0", 21))...) // no phone number
+	content = append(content, byte(protocol.AlarmNormal), byte(protocol.LanguageEnglish))
+	return g.enc.CustomResponse(protocol.ProtocolGPSAddressRequest, content, g.serial)
+}
+
+// commandResponse builds a 0x21 or 0x15 answer to a STATUS# command
+func (g *Generator) commandResponse(protocolNum byte) []byte {
+	resp := fmt.Sprintf("Battery:%d%%,GPS:Fixed,GSM:%d", 40+g.rng.IntN(60), 10+g.rng.IntN(21))
+	p := packet.NewCommandResponsePacket(uint32(g.rng.Uint32()), resp)
+	p.ProtocolNum = protocolNum
+	p.SerialNum = g.serial
+	return g.enc.CommandResponse(p)
+}
+
+// onlineCommand builds a 0x80 command from the server
+func (g *Generator) onlineCommand(byte) []byte {
+	return g.enc.OnlineCommand(g.serial, g.rng.Uint32(), "WHERE#")
+}
+
+// timeCalibration builds a 0x8A request, which has no content
+func (g *Generator) timeCalibration(byte) []byte {
+	return g.enc.CustomResponse(protocol.ProtocolTimeCalibration, nil, g.serial)
+}
+
+// infoTransfer builds a 0x94 external voltage report (in 0.01 V)
+func (g *Generator) infoTransfer(byte) []byte {
+	mv := 1150 + g.rng.IntN(300)
+	p := packet.NewInfoTransferPacket(protocol.InfoTypeExternalVoltage, []byte{byte(mv >> 8), byte(mv)})
+	p.SerialNum = g.serial
+	data, err := g.enc.InfoTransfer(p)
+	if err != nil {
+		panic(err) // the data is set
+	}
+	return data
+}
+
+// addressResponse builds a 0x17 (Chinese) or 0x97 (English) server answer
+func (g *Generator) addressResponse(protocolNum byte) []byte {
+	lang, address := protocol.Language(protocol.LanguageEnglish), "12 George Street, Sydney NSW 2000"
+	if protocolNum == protocol.ProtocolAddressResponseChinese {
+		lang, address = protocol.LanguageChinese, "广东省深圳市南山区科技园"
+	}
+	data, err := encoder.AddressResponse(encoder.AddressResponseParams{
+		AlarmSMS:     "ALARMSMS",
+		Address:      address,
+		PhoneNumber:  strings.Repeat("0", 21),
+		SerialNumber: g.serial,
+		Language:     lang,
+	})
+	if err != nil {
+		panic(err) // the parameters are valid
+	}
+	return data
+}
+
+// appendGPS appends date-time, GPS info, coordinates, speed and course/status
+func appendGPS(content []byte, dt types.DateTime, satellites uint8, coords types.Coordinates, speed uint8, course types.CourseStatus) []byte {
+	content = append(content, dt.ToBytes()...)
+	content = append(content, 0x0C<<4|satellites&0x0F) // GPS information length 12
+	content = append(content, coords.LatitudeBytes()...)
+	content = append(content, coords.LongitudeBytes()...)
+	content = append(content, speed)
+	return append(content, course.Bytes()...)
+}
+
+// luhn returns the check digit of an IMEI without it
+func luhn(digits string) int {
+	sum := 0
+	for i, c := range digits {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
@@ -153,6 +153,38 @@ var AlarmPackets = []TestPacket{
 	},
 }
 
+// GPSLBSStatusPackets contains example combined GPS, LBS and status packets
+var GPSLBSStatusPackets = []TestPacket{
+	{
+		Name:        "gps_lbs_status",
+		Hex:         "787825160F0C1D030B26C9027AC8180C4658600004000901CC00287D001F7146040300000021BBB20D0A",
+		Protocol:    0x16,
+		Description: "GPS LBS status packet (2G cell)",
+		Valid:       true,
+	},
+	{
+		Name:        "gps_lbs_status_mileage",
+		Hex:         "787829160F0C1D030B26C9027AC8180C4658600004000901CC00287D001F7146040300000001E2400022A7E30D0A",
+		Protocol:    0x16,
+		Description: "GPS LBS status packet with mileage (123456 m)",
+		Valid:       true,
+	},
+	{
+		Name:        "gps_lbs_status_4g",
+		Hex:         "78782C321A011A03121FCA01C3AF5407AC8D200519191002CC100000C60D0000000002CE68EA410604000100738ED50D0A",
+		Protocol:    0x32,
+		Description: "GPS LBS status packet (4G cell)",
+		Valid:       true,
+	},
+	{
+		Name:        "gps_lbs_status_4g_alt",
+		Hex:         "787830331A011A03121FCA01C3AF5407AC8D200519191002CC100000C60D0000000002CE68EA41060400010000162E00741B130D0A",
+		Protocol:    0x33,
+		Description: "GPS LBS status packet (4G cell, 0x33 variant) with mileage",
+		Valid:       true,
+	},
+}
+
 // LBSPackets contains example LBS packets
 var LBSPackets = []TestPacket{
 	{
@@ -164,6 +196,24 @@ var LBSPackets = []TestPacket{
 	},
 }
 
+// WiFiPackets contains example WiFi information packets
+var WiFiPackets = []TestPacket{
+	{
+		Name:        "wifi_2g",
+		Hex:         "78784F2C1A011A03121F01CC0027BD001EB80027BD001EB90027BE001F41000000000000000000000000000000000000000000000000000303A45E60E31C0230001A2B3C4D5E4BF09FC211223353003125BF0D0A",
+		Protocol:    0x2C,
+		Description: "WiFi info with 2 neighbor cells and 3 access points",
+		Valid:       true,
+	},
+	{
+		Name:        "wifi_4g",
+		Hex:         "787879A21A011A03121F02CC100000C60D0000000002CE68EA000000C60D0000000002CE68EB0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000002A45E60E31C0230001A2B3C4D5E4B003205A50D0A",
+		Protocol:    0xA2,
+		Description: "4G WiFi info with 1 neighbor cell and 2 access points",
+		Valid:       true,
+	},
+}
+
 // TimeCalibrationPackets contains example time calibration packets
 var TimeCalibrationPackets = []TestPacket{
 	{
@@ -271,7 +321,9 @@ func GetAllValidPackets() []TestPacket {
 	all = append(all, HeartbeatPackets...)
 	all = append(all, LocationPackets...)
 	all = append(all, AlarmPackets...)
+	all = append(all, GPSLBSStatusPackets...)
 	all = append(all, LBSPackets...)
+	all = append(all, WiFiPackets...)
 	all = append(all, TimeCalibrationPackets...)
 	all = append(all, InfoTransferPackets...)
 	all = append(all, CommandPackets...)
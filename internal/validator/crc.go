@@ -3,6 +3,45 @@ package validator
 // CRC-ITU implementation for JM-VL03 protocol
 // Based on the official protocol specification v1.1.2
 
+// CRC computes the packet checksum over the bytes from the packet length to
+// the serial number. Implement it to plug in a firmware-specific variant.
+type CRC interface {
+	Checksum(data []byte) uint16
+}
+
+// TableCRC is a reflected CRC-16 with the ITU polynomial (0x1021), computed
+// with the lookup table, and the given initial and final XOR values
+type TableCRC struct {
+	Init   uint16
+	XorOut uint16
+}
+
+// CRC variants
+var (
+	// CRCX25 is the checksum of the protocol specification, which calls it
+	// CRC-ITU: initial value and final XOR 0xFFFF, i.e. CRC-16/X-25. It is
+	// the default.
+	CRCX25 = TableCRC{Init: 0xFFFF, XorOut: 0xFFFF}
+
+	// CRCITU is the plain ITU-T variant (CRC-16/KERMIT) with a zero initial
+	// value and no final XOR, used by some firmware
+	CRCITU = TableCRC{Init: 0x0000, XorOut: 0x0000}
+)
+
+// Checksum implements CRC
+func (c TableCRC) Checksum(data []byte) uint16 {
+	fcs := c.Init
+	for _, b := range data {
+		fcs = (fcs >> 8) ^ crcTable[(fcs^uint16(b))&0xFF]
+	}
+	return fcs ^ c.XorOut
+}
+
+// Table returns a copy of the CRC-ITU lookup table, for custom variants
+func Table() [256]uint16 {
+	return crcTable
+}
+
 // crcTable is the pre-computed CRC-ITU lookup table for fast calculation
 var crcTable = [256]uint16{
 	0x0000, 0x1189, 0x2312, 0x329B, 0x4624, 0x57AD, 0x6536, 0x74BF,
@@ -54,15 +93,7 @@ var crcTable = [256]uint16{
 //
 // 3. Return one's complement (~) of final FCS
 func CalculateCRC(data []byte) uint16 {
-	fcs := uint16(0xFFFF) // Initialize
-
-	for _, b := range data {
-		// XOR byte with low byte of FCS, use as index into table
-		// XOR table value with FCS shifted right 8 bits
-		fcs = (fcs >> 8) ^ crcTable[(fcs^uint16(b))&0xFF]
-	}
-
-	return ^fcs // Return one's complement
+	return CRCX25.Checksum(data)
 }
 
 // ValidateCRC validates that the CRC in the packet matches the calculated CRC
@@ -74,20 +105,8 @@ func CalculateCRC(data []byte) uint16 {
 // - Long packets (0x7979): CRC is at positions [len-4:len-2]
 // - CRC is calculated from byte 2 (Packet Length) to byte [len-5] (Serial Number end)
 func ValidateCRC(data []byte) bool {
-	if len(data) < 10 {
-		return false // Minimum packet size
-	}
-
-	// Extract CRC from packet (2 bytes before Stop Bit)
-	// CRC is stored as big-endian
-	crcReceived := uint16(data[len(data)-4])<<8 | uint16(data[len(data)-3])
-
-	// Calculate CRC from Packet Length to Information SN
-	// This is from byte 2 to byte [len-5]
-	crcData := data[2 : len(data)-4]
-	crcCalculated := CalculateCRC(crcData)
-
-	return crcReceived == crcCalculated
+	_, _, valid := VerifyPacketCRCWith(data, CRCX25)
+	return valid
 }
 
 // AppendCRC calculates and appends the CRC to the given packet data
@@ -120,13 +139,19 @@ func AppendCRC(data []byte) []byte {
 // VerifyPacketCRC is a convenience function that extracts and validates CRC
 // Returns the CRC value and whether it's valid
 func VerifyPacketCRC(data []byte) (received uint16, calculated uint16, valid bool) {
+	return VerifyPacketCRCWith(data, CRCX25)
+}
+
+// VerifyPacketCRCWith is like VerifyPacketCRC with the given CRC variant
+func VerifyPacketCRCWith(data []byte, crc CRC) (received uint16, calculated uint16, valid bool) {
 	if len(data) < 10 {
-		return 0, 0, false
+		return 0, 0, false // Minimum packet size
 	}
 
+	// CRC is stored as big-endian, 2 bytes before the Stop Bit, and
+	// calculated from Packet Length to Information SN
 	received = uint16(data[len(data)-4])<<8 | uint16(data[len(data)-3])
-	crcData := data[2 : len(data)-4]
-	calculated = CalculateCRC(crcData)
+	calculated = crc.Checksum(data[2 : len(data)-4])
 	valid = (received == calculated)
 
 	return
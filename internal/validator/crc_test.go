@@ -191,3 +191,31 @@ func BenchmarkValidateCRC(b *testing.B) {
 		_ = ValidateCRC(packet)
 	}
 }
+
+func TestCRCVariants(t *testing.T) {
+	check := []byte("123456789")
+	tests := []struct {
+		name string
+		crc  CRC
+		want uint16
+	}{
+		{"X.25", CRCX25, 0x906E},
+		{"ITU", CRCITU, 0x2189},
+		{"no final XOR", TableCRC{Init: 0xFFFF}, ^uint16(0x906E)},
+	}
+	for _, tt := range tests {
+		if got := tt.crc.Checksum(check); got != tt.want {
+			t.Errorf("%s: expected 0x%04X, got 0x%04X", tt.name, tt.want, got)
+		}
+	}
+
+	// A packet checked with the wrong variant fails
+	packet := AppendCRC([]byte{0x78, 0x78, 0x05, 0x13, 0x00, 0x01})
+	packet = append(packet, 0x0D, 0x0A)
+	if _, _, valid := VerifyPacketCRCWith(packet, CRCX25); !valid {
+		t.Error("Expected the packet to pass CRCX25")
+	}
+	if _, _, valid := VerifyPacketCRCWith(packet, CRCITU); valid {
+		t.Error("Expected the packet to fail CRCITU")
+	}
+}
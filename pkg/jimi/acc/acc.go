@@ -0,0 +1,387 @@
+// Package acc derives explicit ignition (ACC) transition events from decoded packets.
+//
+// The VL103M reports ACC status in three different places:
+//   - Alarm packets with AlarmACCOn (0xFE) / AlarmACCOff (0xFF)
+//   - The dedicated ACC byte of GPS location packets (0x22/0xA0)
+//   - Bit 1 of the TerminalInfo byte in heartbeat and alarm packets
+//
+// A Tracker consumes all of them and emits a single ignition-on/ignition-off
+// event per real transition, debounced against rapid flapping, carrying the
+// last known position and mileage at the moment of the transition.
+//
+// The debounce runs on the receive time of the packets (ParsedAt), the only
+// time every source carries: heartbeats have no device time, and device
+// clocks drift or report buffered fixes late. The device time is kept in
+// Event.Time for reporting only.
+//
+// Example usage:
+//
+//	tracker := acc.NewTracker(
+//	    acc.WithDebounce(30*time.Second),
+//	    acc.WithHandler(func(ev acc.Event) {
+//	        log.Printf("%s ignition %s at %s", ev.IMEI, ev.State(), ev.Time)
+//	    }),
+//	)
+//
+//	for _, pkt := range packets {
+//	    tracker.Observe(imei, pkt)
+//	}
+package acc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Source identifies which kind of packet reported the ACC status
+type Source int
+
+const (
+	SourceAlarm     Source = iota // AlarmACCOn/AlarmACCOff alarm packet
+	SourceLocation                // Dedicated ACC byte of a location packet
+	SourceHeartbeat               // TerminalInfo bit of a heartbeat packet
+	SourceTerminal                // TerminalInfo bit of a non-ACC alarm packet
+)
+
+// String returns the human-readable source name
+func (s Source) String() string {
+	switch s {
+	case SourceAlarm:
+		return "Alarm"
+	case SourceLocation:
+		return "Location"
+	case SourceHeartbeat:
+		return "Heartbeat"
+	case SourceTerminal:
+		return "Terminal Info"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+// Event represents a confirmed ignition transition
+type Event struct {
+	// IMEI identifies the device
+	IMEI string
+
+	// On is true for ignition-on, false for ignition-off
+	On bool
+
+	// Time is the device time at which the transition was first observed
+	// (the receive time for packets without one)
+	Time time.Time
+
+	// ReceivedAt is the receive time of the packet that first reported the
+	// new state; the debounce is measured from it
+	ReceivedAt time.Time
+
+	// Coordinates is the last known position at the transition
+	Coordinates types.Coordinates
+
+	// HasPosition indicates if Coordinates is populated
+	HasPosition bool
+
+	// Mileage is the last known device mileage at the transition
+	Mileage uint32
+
+	// HasMileage indicates if Mileage is populated
+	HasMileage bool
+
+	// Source is the packet kind that first reported the new state
+	Source Source
+
+	// Protocol is the protocol number of the packet that first reported the new state
+	Protocol byte
+}
+
+// State returns "ON" or "OFF"
+func (e Event) State() string {
+	if e.On {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// String returns a human-readable representation
+func (e Event) String() string {
+	return fmt.Sprintf("ACCEvent{IMEI: %s, ACC: %s, Time: %s, Source: %s, Mileage: %d}",
+		e.IMEI, e.State(), e.Time.Format(time.RFC3339), e.Source, e.Mileage)
+}
+
+// Handler is called for every confirmed transition
+type Handler func(Event)
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithDebounce sets how long a new ACC state must persist, by receive time,
+// before it is confirmed.
+// Flaps shorter than this duration are suppressed. Zero emits transitions immediately.
+func WithDebounce(d time.Duration) Option {
+	return func(t *Tracker) {
+		if d >= 0 {
+			t.debounce = d
+		}
+	}
+}
+
+// WithHandler sets the callback invoked for every confirmed transition
+func WithHandler(h Handler) Option {
+	return func(t *Tracker) {
+		t.handler = h
+	}
+}
+
+// WithInitialEvents makes the tracker emit an event for the first ACC state
+// observed on a device. By default the first observation only seeds the state.
+func WithInitialEvents() Option {
+	return func(t *Tracker) {
+		t.emitInitial = true
+	}
+}
+
+// Tracker tracks per-device ACC state and emits debounced transition events.
+// It is safe for concurrent use.
+type Tracker struct {
+	mu          sync.Mutex
+	debounce    time.Duration
+	handler     Handler
+	emitInitial bool
+	devices     map[string]*deviceState
+}
+
+// deviceState holds the ACC state machine for a single device
+type deviceState struct {
+	known     bool
+	confirmed bool
+	pending   *Event
+
+	coords      types.Coordinates
+	hasPosition bool
+	mileage     uint32
+	hasMileage  bool
+}
+
+// NewTracker creates a new ACC transition tracker
+func NewTracker(opts ...Option) *Tracker {
+	t := &Tracker{
+		devices: make(map[string]*deviceState),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// observation is the ACC-related content extracted from a packet
+type observation struct {
+	on          bool
+	time        time.Time
+	received    time.Time
+	source      Source
+	coords      types.Coordinates
+	hasPosition bool
+	mileage     uint32
+	hasMileage  bool
+}
+
+// Observe feeds a decoded packet into the tracker.
+// Returns the confirmed transition (if this packet confirmed one).
+// Packets that carry no ACC information are ignored.
+func (t *Tracker) Observe(imei string, p packet.Packet) (Event, bool) {
+	obs, ok := extract(p)
+	if !ok {
+		return Event{}, false
+	}
+	if obs.received.IsZero() {
+		obs.received = time.Now()
+	}
+	if obs.time.IsZero() {
+		obs.time = obs.received
+	}
+
+	t.mu.Lock()
+	ev, fired := t.apply(imei, p.ProtocolNumber(), obs)
+	handler := t.handler
+	t.mu.Unlock()
+
+	if fired && handler != nil {
+		handler(ev)
+	}
+	return ev, fired
+}
+
+// Flush confirms pending transitions received at least the debounce duration
+// before now. Call it periodically when devices may go silent right after a
+// transition.
+func (t *Tracker) Flush(now time.Time) []Event {
+	t.mu.Lock()
+	var events []Event
+	for _, st := range t.devices {
+		if st.pending != nil && now.Sub(st.pending.ReceivedAt) >= t.debounce {
+			events = append(events, *st.pending)
+			st.confirmed = st.pending.On
+			st.pending = nil
+		}
+	}
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler != nil {
+		for _, ev := range events {
+			handler(ev)
+		}
+	}
+	return events
+}
+
+// State returns the confirmed ACC state for a device
+// The second return value is false if no state is known yet.
+func (t *Tracker) State(imei string) (on bool, known bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.devices[imei]
+	if !ok || !st.known {
+		return false, false
+	}
+	return st.confirmed, true
+}
+
+// Forget removes all state for a device
+func (t *Tracker) Forget(imei string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.devices, imei)
+}
+
+// apply runs the state machine for one observation. Caller must hold t.mu.
+func (t *Tracker) apply(imei string, proto byte, obs observation) (Event, bool) {
+	st, ok := t.devices[imei]
+	if !ok {
+		st = &deviceState{}
+		t.devices[imei] = st
+	}
+
+	// Keep the last known position and mileage for snapshots
+	if obs.hasPosition {
+		st.coords = obs.coords
+		st.hasPosition = true
+	}
+	if obs.hasMileage {
+		st.mileage = obs.mileage
+		st.hasMileage = true
+	}
+
+	candidate := Event{
+		IMEI:        imei,
+		On:          obs.on,
+		Time:        obs.time,
+		ReceivedAt:  obs.received,
+		Coordinates: st.coords,
+		HasPosition: st.hasPosition,
+		Mileage:     st.mileage,
+		HasMileage:  st.hasMileage,
+		Source:      obs.source,
+		Protocol:    proto,
+	}
+
+	if !st.known {
+		st.known = true
+		st.confirmed = obs.on
+		return candidate, t.emitInitial
+	}
+
+	if obs.on == st.confirmed {
+		// Back to the confirmed state before the debounce elapsed: flap suppressed
+		st.pending = nil
+		return Event{}, false
+	}
+
+	if st.pending == nil {
+		st.pending = &candidate
+	}
+
+	if obs.received.Sub(st.pending.ReceivedAt) >= t.debounce {
+		ev := *st.pending
+		st.confirmed = ev.On
+		st.pending = nil
+		return ev, true
+	}
+
+	return Event{}, false
+}
+
+// extract returns the ACC observation carried by a packet
+func extract(p packet.Packet) (observation, bool) {
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		return locationObservation(v), true
+	case *packet.Location4GPacket:
+		return locationObservation(&v.LocationPacket), true
+	case *packet.HeartbeatPacket:
+		return observation{
+			on:       v.TerminalInfo.ACCOn(),
+			received: v.ParsedAt,
+			source:   SourceHeartbeat,
+		}, true
+	case *packet.AlarmPacket:
+		return alarmObservation(v), true
+	case *packet.AlarmMultiFencePacket:
+		return alarmObservation(&v.AlarmPacket), true
+	case *packet.Alarm4GPacket:
+		return alarmObservation(&v.AlarmPacket), true
+	case *packet.GPSLBSStatusPacket:
+		return alarmObservation(&v.AlarmPacket), true
+	case *packet.ACCStatusPacket:
+		return alarmObservation(&v.AlarmPacket), true
+	default:
+		return observation{}, false
+	}
+}
+
+// locationObservation builds an observation from the dedicated ACC byte
+func locationObservation(p *packet.LocationPacket) observation {
+	return observation{
+		on:          p.ACC,
+		time:        p.Timestamp(),
+		received:    p.ParsedAt,
+		source:      SourceLocation,
+		coords:      p.Coordinates,
+		hasPosition: p.IsPositioned() && !p.Coordinates.IsZero(),
+		mileage:     p.Mileage,
+		hasMileage:  p.Mileage != 0,
+	}
+}
+
+// alarmObservation builds an observation from an alarm packet
+// AlarmACCOn/AlarmACCOff are authoritative; other alarms use the TerminalInfo bit.
+func alarmObservation(p *packet.AlarmPacket) observation {
+	obs := observation{
+		on:          p.TerminalInfo.ACCOn(),
+		time:        p.Timestamp(),
+		received:    p.ParsedAt,
+		source:      SourceTerminal,
+		coords:      p.Coordinates,
+		hasPosition: p.IsPositioned() && !p.Coordinates.IsZero(),
+		mileage:     p.Mileage,
+		hasMileage:  p.Mileage != 0,
+	}
+
+	switch p.AlarmType {
+	case protocol.AlarmACCOn:
+		obs.on = true
+		obs.source = SourceAlarm
+	case protocol.AlarmACCOff:
+		obs.on = false
+		obs.source = SourceAlarm
+	}
+
+	return obs
+}
@@ -0,0 +1,249 @@
+package acc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+var baseTime = time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+
+func location(offset time.Duration, acc bool, mileage uint32) *packet.LocationPacket {
+	p := packet.NewLocationPacket(
+		types.NewDateTime(baseTime.Add(offset)),
+		types.MustNewCoordinates(-12.0464, -77.0428),
+		40,
+		types.NewCourseStatus(90, true, true, false, true),
+	)
+	p.ACC = acc
+	p.Mileage = mileage
+	p.ParsedAt = baseTime.Add(offset)
+	return p
+}
+
+func alarm(offset time.Duration, alarmType protocol.AlarmType) *packet.AlarmPacket {
+	p := packet.NewAlarmPacket(
+		types.NewDateTime(baseTime.Add(offset)),
+		types.Coordinates{},
+		alarmType,
+	)
+	p.ParsedAt = baseTime.Add(offset)
+	return p
+}
+
+func heartbeat(offset time.Duration, acc bool) *packet.HeartbeatPacket {
+	info := types.NewTerminalInfoBuilder().SetACCOn(acc).Build()
+	p := packet.NewHeartbeatPacket(info, protocol.VoltageMedium, protocol.SignalGood)
+	p.ParsedAt = baseTime.Add(offset)
+	return p
+}
+
+func TestTracker_FirstObservationSeedsState(t *testing.T) {
+	tracker := NewTracker()
+
+	if _, fired := tracker.Observe(testIMEI, location(0, true, 1000)); fired {
+		t.Error("Expected no event on first observation")
+	}
+
+	on, known := tracker.State(testIMEI)
+	if !known || !on {
+		t.Errorf("Expected known ACC ON state, got on=%v known=%v", on, known)
+	}
+}
+
+func TestTracker_InitialEvents(t *testing.T) {
+	tracker := NewTracker(WithInitialEvents())
+
+	ev, fired := tracker.Observe(testIMEI, location(0, true, 1000))
+	if !fired {
+		t.Fatal("Expected event on first observation")
+	}
+	if !ev.On || ev.Source != SourceLocation {
+		t.Errorf("Unexpected event: %s", ev)
+	}
+}
+
+func TestTracker_ImmediateTransition(t *testing.T) {
+	var got []Event
+	tracker := NewTracker(WithHandler(func(ev Event) { got = append(got, ev) }))
+
+	tracker.Observe(testIMEI, location(0, false, 1000))
+	ev, fired := tracker.Observe(testIMEI, location(10*time.Second, true, 1200))
+	if !fired {
+		t.Fatal("Expected ignition-on event")
+	}
+
+	if !ev.On {
+		t.Error("Expected ACC ON")
+	}
+	if !ev.Time.Equal(baseTime.Add(10 * time.Second)) {
+		t.Errorf("Unexpected event time: %s", ev.Time)
+	}
+	if !ev.HasMileage || ev.Mileage != 1200 {
+		t.Errorf("Expected mileage 1200, got %d (has=%v)", ev.Mileage, ev.HasMileage)
+	}
+	if !ev.HasPosition {
+		t.Error("Expected position snapshot")
+	}
+	if ev.Protocol != protocol.ProtocolGPSLocation {
+		t.Errorf("Expected protocol 0x%02X, got 0x%02X", protocol.ProtocolGPSLocation, ev.Protocol)
+	}
+	if len(got) != 1 {
+		t.Errorf("Expected handler to be called once, got %d", len(got))
+	}
+}
+
+func TestTracker_DebounceSuppressesFlap(t *testing.T) {
+	tracker := NewTracker(WithDebounce(30 * time.Second))
+
+	tracker.Observe(testIMEI, location(0, true, 1000))
+
+	// OFF for 10s then back ON: flap must be suppressed
+	if _, fired := tracker.Observe(testIMEI, location(10*time.Second, false, 1000)); fired {
+		t.Error("Expected no event before debounce elapsed")
+	}
+	if _, fired := tracker.Observe(testIMEI, location(20*time.Second, true, 1000)); fired {
+		t.Error("Expected flap to be suppressed")
+	}
+	if events := tracker.Flush(baseTime.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("Expected no pending events, got %d", len(events))
+	}
+
+	on, _ := tracker.State(testIMEI)
+	if !on {
+		t.Error("Expected confirmed state to remain ON")
+	}
+}
+
+func TestTracker_DebounceConfirmsPersistentChange(t *testing.T) {
+	tracker := NewTracker(WithDebounce(30 * time.Second))
+
+	tracker.Observe(testIMEI, location(0, true, 1000))
+	tracker.Observe(testIMEI, location(10*time.Second, false, 1500))
+
+	ev, fired := tracker.Observe(testIMEI, location(45*time.Second, false, 1500))
+	if !fired {
+		t.Fatal("Expected ignition-off event after debounce")
+	}
+
+	// Event carries the time and snapshot of the first OFF observation
+	if ev.On {
+		t.Error("Expected ACC OFF")
+	}
+	if !ev.Time.Equal(baseTime.Add(10 * time.Second)) {
+		t.Errorf("Expected time of first OFF observation, got %s", ev.Time)
+	}
+	if ev.Mileage != 1500 {
+		t.Errorf("Expected mileage 1500, got %d", ev.Mileage)
+	}
+}
+
+func TestTracker_DebounceIgnoresDeviceClock(t *testing.T) {
+	tracker := NewTracker(WithDebounce(30 * time.Second))
+
+	// The device clock runs two hours behind: heartbeats (receive time only)
+	// and locations (device time) must still share one debounce window
+	late := func(offset time.Duration, acc bool) *packet.LocationPacket {
+		p := location(offset, acc, 1000)
+		p.DateTime = types.NewDateTime(baseTime.Add(offset - 2*time.Hour))
+		return p
+	}
+
+	tracker.Observe(testIMEI, late(0, true))
+	if _, fired := tracker.Observe(testIMEI, heartbeat(10*time.Second, false)); fired {
+		t.Error("Expected no event before debounce elapsed")
+	}
+	if _, fired := tracker.Observe(testIMEI, late(20*time.Second, false)); fired {
+		t.Error("Expected the clock offset not to confirm the change early")
+	}
+	ev, fired := tracker.Observe(testIMEI, late(45*time.Second, false))
+	if !fired {
+		t.Fatal("Expected ignition-off event after debounce")
+	}
+	if ev.Source != SourceHeartbeat || !ev.ReceivedAt.Equal(baseTime.Add(10*time.Second)) {
+		t.Errorf("Expected the heartbeat to start the transition, got %s at %s", ev.Source, ev.ReceivedAt)
+	}
+}
+
+func TestTracker_Flush(t *testing.T) {
+	tracker := NewTracker(WithDebounce(30 * time.Second))
+
+	tracker.Observe(testIMEI, location(0, true, 1000))
+	tracker.Observe(testIMEI, location(10*time.Second, false, 1000))
+
+	if events := tracker.Flush(baseTime.Add(20 * time.Second)); len(events) != 0 {
+		t.Errorf("Expected no events before debounce, got %d", len(events))
+	}
+
+	events := tracker.Flush(baseTime.Add(time.Minute))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	if events[0].On {
+		t.Error("Expected ACC OFF")
+	}
+}
+
+func TestTracker_AlarmSources(t *testing.T) {
+	tracker := NewTracker()
+
+	// Seed position and mileage from a location packet
+	tracker.Observe(testIMEI, location(0, true, 2000))
+
+	ev, fired := tracker.Observe(testIMEI, alarm(time.Minute, protocol.AlarmACCOff))
+	if !fired {
+		t.Fatal("Expected ignition-off event from ACC alarm")
+	}
+	if ev.Source != SourceAlarm {
+		t.Errorf("Expected source Alarm, got %s", ev.Source)
+	}
+
+	// Alarm has no position or mileage, snapshot must come from the last location
+	if !ev.HasPosition || ev.Coordinates.IsZero() {
+		t.Error("Expected last known position in snapshot")
+	}
+	if ev.Mileage != 2000 {
+		t.Errorf("Expected mileage 2000, got %d", ev.Mileage)
+	}
+}
+
+func TestTracker_HeartbeatSource(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Observe(testIMEI, heartbeat(0, false))
+	ev, fired := tracker.Observe(testIMEI, heartbeat(time.Minute, true))
+	if !fired {
+		t.Fatal("Expected ignition-on event from heartbeat")
+	}
+	if ev.Source != SourceHeartbeat {
+		t.Errorf("Expected source Heartbeat, got %s", ev.Source)
+	}
+}
+
+func TestTracker_IgnoresUnrelatedPackets(t *testing.T) {
+	tracker := NewTracker()
+
+	login := &packet.LoginPacket{}
+	if _, fired := tracker.Observe(testIMEI, login); fired {
+		t.Error("Expected no event for login packet")
+	}
+	if _, known := tracker.State(testIMEI); known {
+		t.Error("Expected no state for login packet")
+	}
+}
+
+func TestTracker_Forget(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Observe(testIMEI, heartbeat(0, true))
+	tracker.Forget(testIMEI)
+
+	if _, known := tracker.State(testIMEI); known {
+		t.Error("Expected state to be cleared")
+	}
+}
@@ -0,0 +1,253 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Result is the outcome of decoding one hex input in a batch
+type Result struct {
+	// Index is the position of the input in the batch
+	Index int
+
+	// Input is the original hex string
+	Input string
+
+	// Packet is the decoded packet (nil on error)
+	Packet packet.Packet
+
+	// Err is the decoding error (nil on success)
+	Err error
+}
+
+// OK returns true if the input was decoded successfully
+func (r Result) OK() bool {
+	return r.Err == nil && r.Packet != nil
+}
+
+// DecodeBatch decodes a batch of hex-encoded packets concurrently using a
+// decoder created with the given options.
+// Each input must contain exactly one complete packet.
+// Results are returned in input order, one per input, with per-input errors.
+//
+// Example:
+//
+//	results := jimi.DecodeBatch(lines, jimi.WithSkipCRC())
+//	for _, r := range results {
+//	    if r.Err != nil {
+//	        log.Printf("line %d: %v", r.Index+1, r.Err)
+//	        continue
+//	    }
+//	    fmt.Println(r.Packet)
+//	}
+func DecodeBatch(hexPackets []string, opts ...Option) []Result {
+	return NewDecoder(opts...).DecodeBatch(hexPackets)
+}
+
+// DecodeBatch decodes a batch of hex-encoded packets concurrently.
+// See the package-level DecodeBatch for details.
+func (d *Decoder) DecodeBatch(hexPackets []string) []Result {
+	return d.DecodeBatchWorkers(hexPackets, runtime.GOMAXPROCS(0))
+}
+
+// DecodeBatchWorkers is like DecodeBatch with an explicit number of workers
+func (d *Decoder) DecodeBatchWorkers(hexPackets []string, workers int) []Result {
+	results := make([]Result, len(hexPackets))
+	if len(hexPackets) == 0 {
+		return results
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(hexPackets) {
+		workers = len(hexPackets)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = d.decodeHex(i, hexPackets[i])
+			}
+		}()
+	}
+
+	for i := range hexPackets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// decodeParallel decodes packets with the given number of workers and
+// returns the outcome of each packet by index.
+//
+// In strict mode DecodeStream stops at the first error, so the packets after
+// it must leave no trace: their shares stop once an earlier packet failed,
+// and the metrics, parse stats and quarantine records of every packet are
+// only applied when DecodeStream takes its outcome.
+func (d *Decoder) decodeParallel(raw [][]byte, workers int) func(int) (packet.Packet, error) {
+	packets := make([]packet.Packet, len(raw))
+	errs := make([]error, len(raw))
+	var deferred []effects
+	var failed atomic.Int64 // index of the first failed packet
+	if d.opts.StrictMode {
+		deferred = make([]effects, len(raw))
+		failed.Store(int64(len(raw)))
+	}
+
+	// Packets are small, so each worker takes a contiguous share rather than
+	// one packet at a time
+	var wg sync.WaitGroup
+	share := (len(raw) + workers - 1) / workers
+	for start := 0; start < len(raw); start += share {
+		end := min(start+share, len(raw))
+		wg.Go(func() {
+			for i := start; i < end; i++ {
+				if deferred == nil {
+					packets[i], errs[i] = d.decode(raw[i], nil, nil)
+					continue
+				}
+				if int64(i) > failed.Load() {
+					return
+				}
+				packets[i], errs[i] = d.decode(raw[i], nil, &deferred[i])
+				for errs[i] != nil {
+					first := failed.Load()
+					if int64(i) >= first || failed.CompareAndSwap(first, int64(i)) {
+						break
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	return func(i int) (packet.Packet, error) {
+		if deferred != nil {
+			deferred[i].apply()
+		}
+		return packets[i], errs[i]
+	}
+}
+
+// effects are the metrics, parse stats and quarantine records of a packet
+// decoded ahead of time, held until the packet is taken
+type effects []func()
+
+// do runs f, or defers it when fx is set
+func (fx *effects) do(f func()) {
+	if fx == nil {
+		f()
+		return
+	}
+	*fx = append(*fx, f)
+}
+
+// apply runs the deferred effects
+func (fx effects) apply() {
+	for _, f := range fx {
+		f()
+	}
+}
+
+// metrics returns m, deferring its calls when fx is set
+func (fx *effects) metrics(m Metrics) Metrics {
+	if fx == nil || m == nil {
+		return m
+	}
+	return deferredMetrics{m, fx}
+}
+
+// quarantine records a packet in q, or defers it when fx is set
+func (fx *effects) quarantine(q *Quarantine, protocolNum byte, data []byte) {
+	if fx == nil {
+		q.Record(protocolNum, data)
+		return
+	}
+	fx.do(func() { q.Record(protocolNum, data) })
+}
+
+// deferredMetrics defers the calls to Metrics to effects
+type deferredMetrics struct {
+	m  Metrics
+	fx *effects
+}
+
+func (dm deferredMetrics) BytesProcessed(n int) {
+	dm.fx.do(func() { dm.m.BytesProcessed(n) })
+}
+
+func (dm deferredMetrics) PacketDecoded(protocolNum byte) {
+	dm.fx.do(func() { dm.m.PacketDecoded(protocolNum) })
+}
+
+func (dm deferredMetrics) CRCFailure(protocolNum byte) {
+	dm.fx.do(func() { dm.m.CRCFailure(protocolNum) })
+}
+
+func (dm deferredMetrics) UnknownProtocol(protocolNum byte) {
+	dm.fx.do(func() { dm.m.UnknownProtocol(protocolNum) })
+}
+
+func (dm deferredMetrics) Resync(discarded int) {
+	dm.fx.do(func() { dm.m.Resync(discarded) })
+}
+
+// DecodeHex decodes a single hex-encoded packet
+// Whitespace, ':' and '-' separators and a leading "0x" are ignored.
+func (d *Decoder) DecodeHex(s string) (packet.Packet, error) {
+	data, err := ParseHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return d.Decode(data)
+}
+
+// decodeHex decodes one batch input
+func (d *Decoder) decodeHex(index int, input string) Result {
+	pkt, err := d.DecodeHex(input)
+	return Result{
+		Index:  index,
+		Input:  input,
+		Packet: pkt,
+		Err:    err,
+	}
+}
+
+// ParseHex converts a hex string to bytes
+// Whitespace, ':' and '-' separators and a leading "0x" are ignored.
+func ParseHex(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n', ':', '-':
+			return -1
+		}
+		return r
+	}, s)
+
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty input", ErrInvalidHex)
+	}
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHex, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,105 @@
+package jimi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantLen int
+		wantErr bool
+	}{
+		{"plain", "78780D0A", 4, false},
+		{"spaces", "78 78 0D 0A", 4, false},
+		{"prefix and colons", "0x78:78:0D:0A", 4, false},
+		{"trailing newline", "78780D0A\r\n", 4, false},
+		{"empty", "   ", 0, true},
+		{"odd length", "787", 0, true},
+		{"not hex", "zz", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ParseHex(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidHex) {
+					t.Errorf("Expected ErrInvalidHex, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(data) != tt.wantLen {
+				t.Errorf("Expected %d bytes, got %d", tt.wantLen, len(data))
+			}
+		})
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	inputs := []string{
+		"787808132404020001870D0D0A", // heartbeat
+		"not-hex",                    // invalid hex
+		"787811010123456789012348044D03200001ABCD0D0A", // login
+		"7878050100000000000D0A",                       // bad structure
+		accOnAlarmHex,                                  // alarm
+	}
+
+	results := DecodeBatch(inputs, WithSkipCRC(), WithoutIMEIValidation())
+	if len(results) != len(inputs) {
+		t.Fatalf("Expected %d results, got %d", len(inputs), len(results))
+	}
+
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("Result %d has index %d", i, r.Index)
+		}
+		if r.Input != inputs[i] {
+			t.Errorf("Result %d has input %q", i, r.Input)
+		}
+	}
+
+	if _, ok := results[0].Packet.(*packet.HeartbeatPacket); !ok || !results[0].OK() {
+		t.Errorf("Expected heartbeat, got %T (err: %v)", results[0].Packet, results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrInvalidHex) {
+		t.Errorf("Expected ErrInvalidHex, got %v", results[1].Err)
+	}
+	if _, ok := results[2].Packet.(*packet.LoginPacket); !ok {
+		t.Errorf("Expected login, got %T (err: %v)", results[2].Packet, results[2].Err)
+	}
+	if results[3].OK() {
+		t.Error("Expected malformed packet to fail")
+	}
+	if _, ok := results[4].Packet.(*packet.AlarmPacket); !ok {
+		t.Errorf("Expected alarm, got %T (err: %v)", results[4].Packet, results[4].Err)
+	}
+}
+
+func TestDecodeBatchWorkers(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC())
+
+	inputs := make([]string, 100)
+	for i := range inputs {
+		inputs[i] = "787808132404020001870D0D0A"
+	}
+
+	for _, workers := range []int{0, 1, 4, 1000} {
+		results := decoder.DecodeBatchWorkers(inputs, workers)
+		for i, r := range results {
+			if !r.OK() || r.Index != i {
+				t.Fatalf("workers=%d: result %d failed: %v", workers, i, r.Err)
+			}
+		}
+	}
+
+	if results := decoder.DecodeBatch(nil); len(results) != 0 {
+		t.Errorf("Expected no results for empty batch, got %d", len(results))
+	}
+}
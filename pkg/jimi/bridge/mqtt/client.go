@@ -0,0 +1,441 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Client defaults
+const (
+	// DefaultKeepAlive is the keep alive interval announced to the broker
+	DefaultKeepAlive = 60 * time.Second
+
+	// DefaultTimeout bounds connecting and each publish without a context deadline
+	DefaultTimeout = 10 * time.Second
+)
+
+// MQTT 3.1.1 control packet types (high nibble of the fixed header)
+const (
+	packetConnect    = 0x10
+	packetConnAck    = 0x20
+	packetPublish    = 0x30
+	packetPubAck     = 0x40
+	packetPingReq    = 0xC0
+	packetPingResp   = 0xD0
+	packetDisconnect = 0xE0
+)
+
+// ErrClosed is returned when publishing through a closed Client
+var ErrClosed = errors.New("mqtt: client closed")
+
+// connAckErrors are the CONNACK return codes of MQTT 3.1.1
+var connAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Client is a minimal MQTT 3.1.1 client that publishes with QoS 0 or 1.
+// It connects on the first publish and reconnects after a failed one, so a
+// broker outage only fails the messages published meanwhile. It is safe for
+// concurrent use; publishes are sent one at a time.
+type Client struct {
+	addr      string
+	host      string
+	tlsConfig *tls.Config
+	clientID  string
+	username  string
+	password  string
+	hasPass   bool
+	keepAlive time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	nextID  uint16
+	lastTX  time.Time
+	closed  bool
+	stopped chan struct{}
+}
+
+var _ Publisher = (*Client)(nil)
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithClientID sets the client identifier (default "jimi-" and a random suffix)
+func WithClientID(id string) ClientOption {
+	return func(c *Client) {
+		c.clientID = id
+	}
+}
+
+// WithKeepAlive sets the keep alive interval (default DefaultKeepAlive)
+func WithKeepAlive(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.keepAlive = d
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the broker connection; it
+// also enables TLS for mqtt:// URLs
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
+}
+
+// NewClient creates a client for a broker URL: mqtt://[user[:pass]@]host[:port]
+// (tcp:// is an alias, port 1883) or mqtts://... (ssl:// is an alias, port
+// 8883, TLS). A client_id query parameter sets the client identifier. The
+// broker is not contacted until the first publish.
+func NewClient(rawURL string, opts ...ClientOption) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: invalid broker URL: %w", err)
+	}
+
+	c := &Client{
+		keepAlive: DefaultKeepAlive,
+		clientID:  u.Query().Get("client_id"),
+		stopped:   make(chan struct{}),
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl":
+		port = "8883"
+		c.tlsConfig = &tls.Config{}
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported broker URL scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt: broker URL without host: %s", rawURL)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	c.host = u.Hostname()
+	c.addr = net.JoinHostPort(c.host, port)
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, c.hasPass = u.User.Password()
+		c.hasPass = c.hasPass && c.username != "" // a password needs a user name
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.clientID == "" {
+		var b [4]byte
+		rand.Read(b[:])
+		c.clientID = "jimi-" + hex.EncodeToString(b[:])
+	}
+	if c.keepAlive > 0 {
+		go c.pinger()
+	}
+	return c, nil
+}
+
+// Publish implements Publisher. QoS 1 waits for the broker acknowledgment;
+// QoS 2 is not supported.
+func (c *Client) Publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte) error {
+	if qos > 1 {
+		return fmt.Errorf("mqtt: QoS %d not supported", qos)
+	}
+	if topic == "" {
+		return errors.New("mqtt: empty topic")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if err := c.connectLocked(ctx); err != nil {
+		return err
+	}
+
+	header := byte(packetPublish) | qos<<1
+	if retained {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	var id uint16
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		body = append(body, byte(id>>8), byte(id))
+	}
+	body = append(body, payload...)
+
+	c.setDeadline(ctx)
+	err := c.writeLocked(header, body)
+	if err == nil && qos > 0 {
+		err = c.awaitLocked(packetPubAck, id)
+	}
+	if err != nil {
+		c.dropLocked()
+		return fmt.Errorf("mqtt: publish %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Ping connects to the broker if needed and waits for the answer to a
+// PINGREQ, to check connectivity (e.g. at startup)
+func (c *Client) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if err := c.connectLocked(ctx); err != nil {
+		return err
+	}
+
+	c.setDeadline(ctx)
+	err := c.writeLocked(packetPingReq, nil)
+	if err == nil {
+		err = c.awaitPingLocked()
+	}
+	if err != nil {
+		c.dropLocked()
+		return fmt.Errorf("mqtt: ping: %w", err)
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.stopped)
+	if c.conn == nil {
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeLocked(packetDisconnect, nil)
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connectLocked connects to the broker if not connected
+func (c *Client) connectLocked(ctx context.Context) error {
+	if c.conn != nil {
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("mqtt: connect: %w", err)
+	}
+	if c.tlsConfig != nil {
+		cfg := c.tlsConfig
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = c.host
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("mqtt: connect: %w", err)
+		}
+		conn = tc
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	c.setDeadline(ctx)
+
+	flags := byte(0x02) // clean session
+	if c.username != "" {
+		flags |= 0x80
+	}
+	if c.hasPass {
+		flags |= 0x40
+	}
+	keepAlive := uint16(min(c.keepAlive/time.Second, 0xFFFF))
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, c.clientID)
+	if c.username != "" {
+		body = appendString(body, c.username)
+	}
+	if c.hasPass {
+		body = appendString(body, c.password)
+	}
+
+	err = c.writeLocked(packetConnect, body)
+	if err == nil {
+		err = c.awaitLocked(packetConnAck, 0)
+	}
+	if err != nil {
+		c.dropLocked()
+		return fmt.Errorf("mqtt: connect: %w", err)
+	}
+	return nil
+}
+
+// awaitLocked reads packets until one of type want (with packet identifier
+// id for PUBACK) arrives
+func (c *Client) awaitLocked(want byte, id uint16) error {
+	for {
+		header, body, err := readPacket(c.r)
+		if err != nil {
+			return err
+		}
+		switch typ := header & 0xF0; {
+		case typ == packetConnAck && want == packetConnAck:
+			if len(body) < 2 {
+				return errors.New("malformed CONNACK")
+			}
+			if rc := body[1]; rc != 0 {
+				if reason, ok := connAckErrors[rc]; ok {
+					return fmt.Errorf("refused: %s", reason)
+				}
+				return fmt.Errorf("refused: return code %d", rc)
+			}
+			return nil
+		case typ == want && len(body) >= 2 && uint16(body[0])<<8|uint16(body[1]) == id:
+			return nil
+		}
+		// PINGRESP and acknowledgments of earlier publishes
+	}
+}
+
+// writeLocked writes one control packet
+func (c *Client) writeLocked(header byte, body []byte) error {
+	buf := append([]byte{header}, appendLength(nil, len(body))...)
+	_, err := c.conn.Write(append(buf, body...))
+	if err == nil {
+		c.lastTX = time.Now()
+	}
+	return err
+}
+
+// setDeadline sets the connection deadline of an exchange
+func (c *Client) setDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+}
+
+// dropLocked closes a failed connection; the next publish reconnects
+func (c *Client) dropLocked() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// pinger keeps an idle connection alive
+func (c *Client) pinger() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopped:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		if c.conn != nil && time.Since(c.lastTX) >= c.keepAlive/2 {
+			c.conn.SetDeadline(time.Now().Add(DefaultTimeout))
+			err := c.writeLocked(packetPingReq, nil)
+			if err == nil {
+				err = c.awaitPingLocked()
+			}
+			if err != nil {
+				c.dropLocked()
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// awaitPingLocked reads packets until PINGRESP
+func (c *Client) awaitPingLocked() error {
+	for {
+		header, _, err := readPacket(c.r)
+		if err != nil || header&0xF0 == packetPingResp {
+			return err
+		}
+	}
+}
+
+// readPacket reads one control packet and returns its fixed header byte
+// (type and flags) and body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, mult := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * mult
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		mult *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// appendLength appends an MQTT remaining length
+func appendLength(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+// appendString appends an MQTT UTF-8 string (2-byte length prefix)
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
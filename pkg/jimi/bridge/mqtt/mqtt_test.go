@@ -0,0 +1,301 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// message is one publish received by the test broker or publisher
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// broker is a minimal MQTT broker recording publishes
+type broker struct {
+	addr       string
+	returnCode byte
+	connects   chan []byte // CONNECT bodies
+	messages   chan message
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// startBroker runs a test broker answering CONNECT with returnCode
+func startBroker(t *testing.T, returnCode byte) *broker {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	b := &broker{
+		addr:       l.Addr().String(),
+		returnCode: returnCode,
+		connects:   make(chan []byte, 8),
+		messages:   make(chan message, 32),
+	}
+	t.Cleanup(func() {
+		l.Close()
+		b.dropAll()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+// serve answers the control packets of one client
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header & 0xF0 {
+		case packetConnect:
+			b.connects <- body
+			conn.Write([]byte{packetConnAck, 2, 0, b.returnCode})
+		case packetPublish:
+			m := message{qos: header >> 1 & 0x03, retained: header&0x01 != 0}
+			n := int(body[0])<<8 | int(body[1])
+			m.topic, body = string(body[2:2+n]), body[2+n:]
+			if m.qos > 0 {
+				conn.Write([]byte{packetPubAck, 2, body[0], body[1]})
+				body = body[2:]
+			}
+			m.payload = body
+			b.messages <- m
+		case packetPingReq:
+			conn.Write([]byte{packetPingResp, 0})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// dropAll closes the client connections, as a broker restart would
+func (b *broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = nil
+}
+
+// next waits for the next publish
+func (b *broker) next(t *testing.T) message {
+	t.Helper()
+	select {
+	case m := <-b.messages:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a publish")
+	}
+	return message{}
+}
+
+func TestClient_Publish(t *testing.T) {
+	b := startBroker(t, 0)
+	c, err := NewClient("mqtt://fleet:secret@"+b.addr, WithClientID("tracker-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	connect := string(<-b.connects)
+	for _, want := range []string{"MQTT", "tracker-1", "fleet", "secret"} {
+		if !strings.Contains(connect, want) {
+			t.Errorf("Expected %q in CONNECT %q", want, connect)
+		}
+	}
+	if err := c.Publish(ctx, "jimi/1/location", 1, true, []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if m := b.next(t); m.topic != "jimi/1/location" || m.qos != 1 || !m.retained || string(m.payload) != `{"a":1}` {
+		t.Errorf("Unexpected message %+v", m)
+	}
+
+	// A large payload needs a multi-byte remaining length
+	big := []byte(strings.Repeat("x", 20000))
+	if err := c.Publish(ctx, "jimi/1/packet", 0, false, big); err != nil {
+		t.Fatal(err)
+	}
+	if m := b.next(t); len(m.payload) != len(big) || m.qos != 0 || m.retained {
+		t.Errorf("Unexpected large message: %d bytes, qos %d", len(m.payload), m.qos)
+	}
+
+	// The client reconnects after the broker drops the connection
+	b.dropAll()
+	var published bool
+	for range 3 {
+		if c.Publish(ctx, "jimi/1/status", 1, false, []byte("{}")) == nil {
+			published = true
+			break
+		}
+	}
+	if !published {
+		t.Fatal("Expected a publish to succeed after reconnecting")
+	}
+	if m := b.next(t); m.topic != "jimi/1/status" {
+		t.Errorf("Unexpected message after reconnect %+v", m)
+	}
+
+	if err := c.Publish(ctx, "t", 2, false, nil); err == nil {
+		t.Error("Expected an error for QoS 2")
+	}
+	c.Close()
+	if err := c.Publish(ctx, "t", 0, false, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestClient_Refused(t *testing.T) {
+	b := startBroker(t, 5)
+	c, err := NewClient("tcp://" + b.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	err = c.Publish(context.Background(), "t", 0, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("Expected the connection refused, got %v", err)
+	}
+	if err := c.Ping(context.Background()); err == nil {
+		t.Error("Expected the ping to fail")
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantAddr string
+		wantTLS  bool
+		wantErr  bool
+	}{
+		{"mqtt://broker", "broker:1883", false, false},
+		{"tcp://broker:1884", "broker:1884", false, false},
+		{"mqtts://broker", "broker:8883", true, false},
+		{"ssl://broker:9000?client_id=x", "broker:9000", true, false},
+		{"http://broker", "", false, true},
+		{"mqtt://", "", false, true},
+	}
+	for _, tt := range tests {
+		c, err := NewClient(tt.url, WithKeepAlive(0))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.url, err)
+			continue
+		}
+		if err == nil && (c.addr != tt.wantAddr || (c.tlsConfig != nil) != tt.wantTLS) {
+			t.Errorf("%s: got addr %s, TLS %v", tt.url, c.addr, c.tlsConfig != nil)
+		}
+	}
+}
+
+// recorder is a Publisher recording messages
+type recorder struct {
+	messages []message
+}
+
+func (r *recorder) Publish(_ context.Context, topic string, qos byte, retained bool, payload []byte) error {
+	r.messages = append(r.messages, message{topic, qos, retained, payload})
+	return nil
+}
+
+func TestBridge_Publish(t *testing.T) {
+	decode := func(hex string) packet.Packet {
+		t.Helper()
+		p, err := jimi.NewDecoder(jimi.WithSkipCRC()).DecodeHex(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	const imei = "359339073930520"
+	location := decode(packets.LocationPackets[0].Hex)
+	alarm := decode(packets.AlarmPackets[0].Hex)
+
+	rec := &recorder{}
+	bridge := NewBridge(rec, WithTopicPrefix("fleet/"), WithQoS(1), WithRetainedPosition())
+	ctx := context.Background()
+	for _, p := range []packet.Packet{location, alarm} {
+		if err := bridge.Publish(ctx, imei, p, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := len(rec.messages)
+	if err := bridge.Publish(ctx, "", location, time.Now()); err != nil || len(rec.messages) != n {
+		t.Errorf("Expected packets before login skipped, got %v", err)
+	}
+
+	var topics []string
+	for _, m := range rec.messages {
+		topics = append(topics, m.topic)
+		if m.qos != 1 || m.retained != strings.HasSuffix(m.topic, "/position") {
+			t.Errorf("%s: unexpected qos %d retained %v", m.topic, m.qos, m.retained)
+		}
+	}
+	want := "fleet/" + imei + "/location fleet/" + imei + "/position fleet/" + imei + "/alarm"
+	if got := strings.Join(topics, " "); got != want && got != want+" fleet/"+imei+"/position" {
+		t.Errorf("Expected topics %s, got %s", want, got)
+	}
+
+	var r export.Record
+	if err := json.Unmarshal(rec.messages[0].payload, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.IMEI != imei || r.Position == nil || r.Raw != "" || r.ReceivedAt == nil {
+		t.Errorf("Unexpected record %+v", r)
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want string
+	}{
+		{packets.LoginPackets[0].Hex, KindLogin},
+		{packets.HeartbeatPackets[0].Hex, KindStatus},
+		{packets.LocationPackets[0].Hex, KindLocation},
+		{packets.AlarmPackets[0].Hex, KindAlarm},
+	}
+	for _, tt := range tests {
+		p, err := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithoutIMEIValidation()).DecodeHex(tt.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Kind(p); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", p.Type(), tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Package cadence learns how often each device uploads and reports when the
+// cadence shifts.
+//
+// A device that suddenly uploads less often (or stops uploading) is often
+// being jammed, losing power or was reconfigured, and it stays connected for
+// minutes before the read timeout finally drops it. A Detector learns the
+// typical interval between heartbeats and between location uploads of every
+// device, and raises an Anomaly when several intervals in a row are far off
+// the learned one, or when a device stays silent for much longer than usual.
+//
+// Location cadence follows the ignition (devices upload less when parked),
+// so a parked vehicle shows up as a Slower location stream; the heartbeat
+// stream is the stable signal.
+//
+// Example usage:
+//
+//	detector := cadence.NewDetector(cadence.WithHandler(func(a cadence.Anomaly) {
+//	    log.Printf("%s: %s", a.IMEI, a)
+//	}))
+//
+//	for _, pkt := range packets {
+//	    detector.Observe(imei, pkt)
+//	}
+//
+//	// Periodically, to catch devices that went silent
+//	detector.Check(time.Now())
+package cadence
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Default detection settings
+const (
+	// DefaultFactor is how far off (in either direction) an interval must be
+	// from the learned one to count as deviating
+	DefaultFactor = 2.0
+
+	// DefaultMinSamples is the number of intervals learned before detecting
+	DefaultMinSamples = 5
+
+	// DefaultConfirm is the number of consecutive deviating intervals that
+	// confirm a cadence shift
+	DefaultConfirm = 3
+)
+
+// smoothing is the weight of a new interval in the learned one
+const smoothing = 0.2
+
+// Stream identifies the kind of upload whose cadence is learned
+type Stream int
+
+const (
+	StreamHeartbeat Stream = iota // Heartbeat packets (0x13)
+	StreamLocation                // GPS location packets (0x22/0xA0)
+)
+
+// String returns the human-readable stream name
+func (s Stream) String() string {
+	switch s {
+	case StreamHeartbeat:
+		return "Heartbeat"
+	case StreamLocation:
+		return "Location"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+// Kind classifies an anomaly
+type Kind int
+
+const (
+	KindSlower Kind = iota // Uploads arrive less often than learned
+	KindFaster             // Uploads arrive more often than learned
+	KindSilent             // No upload for much longer than the learned interval
+)
+
+// String returns the human-readable kind name
+func (k Kind) String() string {
+	switch k {
+	case KindSlower:
+		return "Slower"
+	case KindFaster:
+		return "Faster"
+	case KindSilent:
+		return "Silent"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(k))
+	}
+}
+
+// Anomaly is a significant change of the upload cadence of a device
+type Anomaly struct {
+	// IMEI identifies the device
+	IMEI string
+
+	// Stream is the upload stream whose cadence changed
+	Stream Stream
+
+	// Kind tells how the cadence changed
+	Kind Kind
+
+	// Expected is the learned interval before the change
+	Expected time.Duration
+
+	// Observed is the mean deviating interval, or the silence so far for KindSilent
+	Observed time.Duration
+
+	// Time is when the change was confirmed
+	Time time.Time
+}
+
+// String returns a human-readable representation
+func (a Anomaly) String() string {
+	return fmt.Sprintf("CadenceAnomaly{IMEI: %s, Stream: %s, Kind: %s, Expected: %s, Observed: %s}",
+		a.IMEI, a.Stream, a.Kind, a.Expected.Round(time.Second), a.Observed.Round(time.Second))
+}
+
+// Handler is called for every anomaly
+type Handler func(Anomaly)
+
+// Option configures a Detector
+type Option func(*Detector)
+
+// WithHandler sets the callback invoked for every anomaly
+func WithHandler(h Handler) Option {
+	return func(d *Detector) {
+		d.handler = h
+	}
+}
+
+// WithFactor sets how far off an interval must be to count as deviating.
+// Values of 1 or less are ignored.
+func WithFactor(f float64) Option {
+	return func(d *Detector) {
+		if f > 1 {
+			d.factor = f
+		}
+	}
+}
+
+// WithMinSamples sets the number of intervals learned before detecting
+func WithMinSamples(n int) Option {
+	return func(d *Detector) {
+		if n > 0 {
+			d.minSamples = n
+		}
+	}
+}
+
+// WithConfirm sets the number of consecutive deviating intervals that
+// confirm a shift. A single late upload is jitter, not a new cadence.
+func WithConfirm(n int) Option {
+	return func(d *Detector) {
+		if n > 0 {
+			d.confirm = n
+		}
+	}
+}
+
+// Detector learns per-device upload cadences and detects anomalies.
+// It is safe for concurrent use.
+type Detector struct {
+	mu         sync.Mutex
+	handler    Handler
+	factor     float64
+	minSamples int
+	confirm    int
+	devices    map[string]*[2]streamState
+}
+
+// streamState holds the learned cadence of one stream of one device
+type streamState struct {
+	last      time.Time
+	expected  time.Duration
+	samples   int
+	direction Kind
+	deviating []time.Duration
+	silent    bool // silence already reported for the current gap
+}
+
+// NewDetector creates a new cadence detector
+func NewDetector(opts ...Option) *Detector {
+	d := &Detector{
+		factor:     DefaultFactor,
+		minSamples: DefaultMinSamples,
+		confirm:    DefaultConfirm,
+		devices:    make(map[string]*[2]streamState),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Observe feeds a decoded packet received now into the detector.
+// See ObserveAt.
+func (d *Detector) Observe(imei string, p packet.Packet) (Anomaly, bool) {
+	return d.ObserveAt(imei, p, time.Now())
+}
+
+// ObserveAt feeds a decoded packet received at the given time.
+// Receive times are used rather than device times, so buffered uploads sent
+// in a burst after a reconnect do not look like a regular cadence.
+// Returns the anomaly if this packet confirmed one. Packets of other types
+// are ignored.
+func (d *Detector) ObserveAt(imei string, p packet.Packet, at time.Time) (Anomaly, bool) {
+	stream, ok := streamOf(p)
+	if !ok {
+		return Anomaly{}, false
+	}
+
+	d.mu.Lock()
+	a, fired := d.apply(imei, stream, at)
+	handler := d.handler
+	d.mu.Unlock()
+
+	if fired && handler != nil {
+		handler(a)
+	}
+	return a, fired
+}
+
+// Check reports the streams that have been silent for more than the factor
+// times their learned interval as of now, sorted by IMEI. Each silence is
+// reported once. Call it periodically.
+func (d *Detector) Check(now time.Time) []Anomaly {
+	d.mu.Lock()
+	var anomalies []Anomaly
+	for imei, streams := range d.devices {
+		for i := range streams {
+			st := &streams[i]
+			if st.silent || st.samples < d.minSamples {
+				continue
+			}
+			silence := now.Sub(st.last)
+			if float64(silence) <= d.factor*float64(st.expected) {
+				continue
+			}
+			st.silent = true
+			anomalies = append(anomalies, Anomaly{
+				IMEI:     imei,
+				Stream:   Stream(i),
+				Kind:     KindSilent,
+				Expected: st.expected,
+				Observed: silence,
+				Time:     now,
+			})
+		}
+	}
+	handler := d.handler
+	d.mu.Unlock()
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].IMEI != anomalies[j].IMEI {
+			return anomalies[i].IMEI < anomalies[j].IMEI
+		}
+		return anomalies[i].Stream < anomalies[j].Stream
+	})
+	if handler != nil {
+		for _, a := range anomalies {
+			handler(a)
+		}
+	}
+	return anomalies
+}
+
+// Expected returns the learned interval of a stream of a device.
+// The second return value is false while the cadence is still being learned.
+func (d *Detector) Expected(imei string, stream Stream) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	streams, ok := d.devices[imei]
+	if !ok || stream < 0 || int(stream) >= len(streams) {
+		return 0, false
+	}
+	st := streams[stream]
+	return st.expected, st.samples >= d.minSamples
+}
+
+// Forget removes all state for a device
+func (d *Detector) Forget(imei string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.devices, imei)
+}
+
+// apply learns one upload and detects shifts. Caller must hold d.mu.
+func (d *Detector) apply(imei string, stream Stream, at time.Time) (Anomaly, bool) {
+	streams, ok := d.devices[imei]
+	if !ok {
+		streams = &[2]streamState{}
+		d.devices[imei] = streams
+	}
+	st := &streams[stream]
+
+	if st.last.IsZero() {
+		st.last = at
+		return Anomaly{}, false
+	}
+	interval := at.Sub(st.last)
+	if interval <= 0 {
+		// Duplicate or out of order
+		return Anomaly{}, false
+	}
+	st.last = at
+	st.silent = false
+
+	if st.samples < d.minSamples {
+		st.learn(interval)
+		return Anomaly{}, false
+	}
+
+	var kind Kind
+	switch {
+	case float64(interval) > d.factor*float64(st.expected):
+		kind = KindSlower
+	case float64(interval)*d.factor < float64(st.expected):
+		kind = KindFaster
+	default:
+		st.deviating = nil
+		st.learn(interval)
+		return Anomaly{}, false
+	}
+
+	if kind != st.direction {
+		st.deviating = nil
+		st.direction = kind
+	}
+	st.deviating = append(st.deviating, interval)
+	if len(st.deviating) < d.confirm {
+		return Anomaly{}, false
+	}
+
+	// Shift confirmed: the deviating intervals are the new cadence
+	var sum time.Duration
+	for _, iv := range st.deviating {
+		sum += iv
+	}
+	observed := sum / time.Duration(len(st.deviating))
+	a := Anomaly{
+		IMEI:     imei,
+		Stream:   stream,
+		Kind:     kind,
+		Expected: st.expected,
+		Observed: observed,
+		Time:     at,
+	}
+	st.expected = observed
+	st.deviating = nil
+	return a, true
+}
+
+// learn blends an interval into the learned one
+func (st *streamState) learn(interval time.Duration) {
+	if st.samples == 0 {
+		st.expected = interval
+	} else {
+		st.expected += time.Duration(smoothing * float64(interval-st.expected))
+	}
+	st.samples++
+}
+
+// streamOf returns the stream a packet belongs to
+func streamOf(p packet.Packet) (Stream, bool) {
+	switch {
+	case p.ProtocolNumber() == protocol.ProtocolHeartbeat:
+		return StreamHeartbeat, true
+	case packet.IsLocationPacket(p):
+		return StreamLocation, true
+	default:
+		return 0, false
+	}
+}
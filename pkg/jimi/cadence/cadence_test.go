@@ -0,0 +1,146 @@
+package cadence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+const testIMEI = "359339073930523"
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func heartbeat() packet.Packet {
+	return &packet.HeartbeatPacket{BasePacket: packet.BasePacket{ProtocolNum: protocol.ProtocolHeartbeat}}
+}
+
+func location() packet.Packet {
+	return &packet.LocationPacket{BasePacket: packet.BasePacket{ProtocolNum: protocol.ProtocolGPSLocation}}
+}
+
+// feed observes heartbeats at the given intervals and returns the anomalies
+func feed(d *Detector, at time.Time, intervals ...time.Duration) (time.Time, []Anomaly) {
+	var anomalies []Anomaly
+	for _, iv := range intervals {
+		at = at.Add(iv)
+		if a, ok := d.ObserveAt(testIMEI, heartbeat(), at); ok {
+			anomalies = append(anomalies, a)
+		}
+	}
+	return at, anomalies
+}
+
+// repeat returns n copies of iv
+func repeat(iv time.Duration, n int) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = iv
+	}
+	return out
+}
+
+func TestDetector_Shifts(t *testing.T) {
+	learned := repeat(3*time.Minute, 6)
+
+	tests := []struct {
+		name      string
+		intervals []time.Duration
+		wantKinds []Kind
+	}{
+		{"steady", repeat(3*time.Minute, 5), nil},
+		{"jitter", []time.Duration{4 * time.Minute, 2 * time.Minute, 3 * time.Minute}, nil},
+		{"single late upload", []time.Duration{10 * time.Minute, 3 * time.Minute, 3 * time.Minute}, nil},
+		{"slower", repeat(10*time.Minute, 3), []Kind{KindSlower}},
+		{"faster", repeat(30*time.Second, 3), []Kind{KindFaster}},
+		{"new cadence is learned", repeat(10*time.Minute, 8), []Kind{KindSlower}},
+		{"mixed deviations do not confirm", []time.Duration{10 * time.Minute, 30 * time.Second, 10 * time.Minute, 30 * time.Second}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector()
+			at, anomalies := feed(d, start, learned...)
+			if len(anomalies) != 0 {
+				t.Fatalf("Expected no anomalies while learning, got %v", anomalies)
+			}
+			if exp, ok := d.Expected(testIMEI, StreamHeartbeat); !ok || exp != 3*time.Minute {
+				t.Fatalf("Expected learned 3m, got %s (%v)", exp, ok)
+			}
+
+			_, anomalies = feed(d, at, tt.intervals...)
+			if len(anomalies) != len(tt.wantKinds) {
+				t.Fatalf("Expected %v, got %v", tt.wantKinds, anomalies)
+			}
+			for i, a := range anomalies {
+				if a.Kind != tt.wantKinds[i] || a.Stream != StreamHeartbeat || a.Expected != 3*time.Minute {
+					t.Errorf("Unexpected anomaly %s", a)
+				}
+			}
+		})
+	}
+}
+
+func TestDetector_Check(t *testing.T) {
+	var handled []Anomaly
+	d := NewDetector(WithHandler(func(a Anomaly) { handled = append(handled, a) }))
+
+	// Not learned yet: silence is not reported
+	at, _ := feed(d, start, repeat(time.Minute, 3)...)
+	if got := d.Check(at.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Expected no silence before learning, got %v", got)
+	}
+
+	at, _ = feed(d, at, repeat(time.Minute, 3)...)
+	if got := d.Check(at.Add(90 * time.Second)); len(got) != 0 {
+		t.Errorf("Expected no silence within the factor, got %v", got)
+	}
+
+	got := d.Check(at.Add(5 * time.Minute))
+	if len(got) != 1 || got[0].Kind != KindSilent || got[0].Observed != 5*time.Minute {
+		t.Fatalf("Expected one silence of 5m, got %v", got)
+	}
+	if got := d.Check(at.Add(10 * time.Minute)); len(got) != 0 {
+		t.Errorf("Expected the silence to be reported once, got %v", got)
+	}
+	if len(handled) != 1 {
+		t.Errorf("Expected the handler to be called once, got %d", len(handled))
+	}
+
+	// The next upload ends the silence
+	at, _ = feed(d, at.Add(10*time.Minute), time.Minute)
+	if got := d.Check(at.Add(5 * time.Minute)); len(got) != 1 {
+		t.Errorf("Expected a new silence to be reported, got %v", got)
+	}
+}
+
+func TestDetector_Streams(t *testing.T) {
+	d := NewDetector(WithMinSamples(1), WithConfirm(1))
+
+	at := start
+	for range 3 {
+		at = at.Add(10 * time.Second)
+		d.ObserveAt(testIMEI, location(), at)
+	}
+	if _, ok := d.Expected(testIMEI, StreamHeartbeat); ok {
+		t.Error("Expected no heartbeat cadence from location packets")
+	}
+	if exp, ok := d.Expected(testIMEI, StreamLocation); !ok || exp != 10*time.Second {
+		t.Errorf("Expected location cadence 10s, got %s (%v)", exp, ok)
+	}
+
+	if _, ok := d.ObserveAt(testIMEI, &packet.LoginPacket{}, at.Add(time.Hour)); ok {
+		t.Error("Expected login packets to be ignored")
+	}
+
+	a, ok := d.ObserveAt(testIMEI, location(), at.Add(time.Minute))
+	if !ok || a.Stream != StreamLocation || a.Kind != KindSlower || a.Observed != time.Minute {
+		t.Errorf("Unexpected anomaly %s (%v)", a, ok)
+	}
+
+	d.Forget(testIMEI)
+	if _, ok := d.Expected(testIMEI, StreamLocation); ok {
+		t.Error("Expected device to be forgotten")
+	}
+}
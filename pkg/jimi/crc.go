@@ -0,0 +1,40 @@
+package jimi
+
+import "github.com/fcode09/jimi-vl103m/internal/validator"
+
+// CRC computes the packet checksum over the bytes from the packet length to
+// the serial number. Implement it for firmware with a checksum of its own.
+type CRC = validator.CRC
+
+// TableCRC is a reflected CRC-16 with the ITU polynomial (0x1021) and the
+// given initial and final XOR values, for variants that only differ in those
+type TableCRC = validator.TableCRC
+
+// CRC variants (see WithCRCVariant)
+var (
+	// CRCX25 is the checksum of the protocol specification, which calls it
+	// CRC-ITU (CRC-16/X-25: initial value and final XOR 0xFFFF). It is the
+	// default.
+	CRCX25 CRC = validator.CRCX25
+
+	// CRCITU is the plain ITU-T variant (CRC-16/KERMIT: zero initial value,
+	// no final XOR) used by some firmware
+	CRCITU CRC = validator.CRCITU
+)
+
+// WithCRCVariant validates packet checksums with crc instead of CRCX25, for
+// firmware that computes them differently. Responses to such devices need
+// the same variant (see encoder.Encoder.CRC).
+func WithCRCVariant(crc CRC) Option {
+	return func(o *Options) {
+		o.CRC = crc
+	}
+}
+
+// crc returns the CRC variant of the options
+func (o *Options) crc() CRC {
+	if o.CRC == nil {
+		return CRCX25
+	}
+	return o.CRC
+}
@@ -9,12 +9,16 @@ import (
 	"github.com/fcode09/jimi-vl103m/internal/validator"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 // Decoder is the main entry point for decoding VL103M protocol packets
 type Decoder struct {
 	opts     Options
 	registry *parser.Registry
+
+	// protoOpts caches the effective options of protocols with overrides
+	protoOpts map[byte]*Options
 }
 
 // NewDecoder creates a new decoder with optional configuration
@@ -44,15 +48,12 @@ func NewDecoder(opts ...Option) *Decoder {
 	}
 
 	// Configure context based on options
-	registry.SetContext(parser.Context{
-		StrictMode:     options.StrictMode,
-		ValidateIMEI:   options.ValidateIMEIChecksum,
-		TimezoneOffset: 0,
-	})
+	registry.SetContext(parserContext(&options))
 
 	return &Decoder{
-		opts:     options,
-		registry: registry,
+		opts:      options,
+		registry:  registry,
+		protoOpts: resolveProtocolOptions(&options),
 	}
 }
 
@@ -65,8 +66,9 @@ func NewDecoderWithRegistry(registry *parser.Registry, opts ...Option) *Decoder
 	}
 
 	return &Decoder{
-		opts:     options,
-		registry: registry,
+		opts:      options,
+		registry:  registry,
+		protoOpts: resolveProtocolOptions(&options),
 	}
 }
 
@@ -91,21 +93,52 @@ func NewDecoderWithRegistry(registry *parser.Registry, opts ...Option) *Decoder
 //	    fmt.Printf("Location: %s\n", loc.Coordinates)
 //	}
 func (d *Decoder) Decode(data []byte) (packet.Packet, error) {
+	return d.decode(data, nil, nil)
+}
+
+// decode decodes one packet with the login timezone tz of its device
+// session, deferring its metrics, parse stats and quarantine records to fx
+// when set
+func (d *Decoder) decode(data []byte, tz *types.Timezone, fx *effects) (packet.Packet, error) {
+	if m := fx.metrics(d.opts.Metrics); m != nil {
+		m.BytesProcessed(len(data))
+	}
 	if len(data) < protocol.MinPacketSize {
+		if log := d.opts.logger(); log != nil {
+			log.Warn("packet too short", packetAttrs(data)...)
+		}
 		return nil, ErrInvalidPacketSize
 	}
 
+	// Protocol-specific overrides apply from structure validation onwards
+	opts := &d.opts
+	if proto, err := splitter.GetPacketType(data); err == nil {
+		opts = d.optionsFor(proto)
+	}
+	log := opts.logger()
+
 	// Validate structure (start bit, stop bit, length)
-	if !d.opts.SkipStructureValidation {
-		if err := d.validateStructure(data); err != nil {
+	if !opts.SkipStructureValidation {
+		if err := validateStructure(data, opts); err != nil {
+			if log != nil {
+				log.Warn("invalid packet structure", append(packetAttrs(data), "err", err)...)
+			}
 			return nil, err
 		}
 	}
 
 	// Validate CRC
-	if !d.opts.SkipCRCValidation {
-		if !validator.ValidateCRC(data) {
-			received, calculated, _ := validator.VerifyPacketCRC(data)
+	if !opts.SkipCRCValidation {
+		if received, calculated, valid := validator.VerifyPacketCRCWith(data, opts.crc()); !valid {
+			if log != nil {
+				log.Warn("crc mismatch", append(packetAttrs(data),
+					"received", fmt.Sprintf("0x%04X", received), "calculated", fmt.Sprintf("0x%04X", calculated))...)
+			}
+			if m := fx.metrics(opts.Metrics); m != nil {
+				if proto, err := splitter.GetPacketType(data); err == nil {
+					m.CRCFailure(proto)
+				}
+			}
 			return nil, NewCRCError(calculated, received, len(data))
 		}
 	}
@@ -117,23 +150,44 @@ func (d *Decoder) Decode(data []byte) (packet.Packet, error) {
 	}
 
 	// Try to use registered parser
-	if d.registry != nil && d.registry.Has(protocolNum) {
-		pkt, parseErr := d.registry.Parse(protocolNum, data)
+	known := d.registry != nil && d.registry.Has(protocolNum)
+	if known {
+		pkt, parseErr := d.parse(protocolNum, data, opts, tz, fx)
 		if parseErr != nil {
-			if d.opts.StrictMode {
+			if log != nil {
+				log.Warn("parse failed", append(packetAttrs(data), "err", parseErr, "strict", opts.StrictMode)...)
+			}
+			if opts.StrictMode {
 				return nil, fmt.Errorf("failed to parse protocol 0x%02X: %w", protocolNum, parseErr)
 			}
 			// Fall through to return base packet in lenient mode
 		} else {
-			return pkt, nil
+			if m := fx.metrics(opts.Metrics); m != nil {
+				m.PacketDecoded(protocolNum)
+			}
+			return route(pkt, opts), nil
 		}
 	}
 
 	// No parser registered or parse failed in lenient mode
 	// Check if we should reject unknown protocols
-	if !d.opts.AllowUnknownProtocols && (d.registry == nil || !d.registry.Has(protocolNum)) {
+	if m := fx.metrics(opts.Metrics); !known && m != nil {
+		m.UnknownProtocol(protocolNum)
+	}
+	if !opts.AllowUnknownProtocols && !known {
+		if log != nil {
+			log.Warn("unknown protocol rejected", packetAttrs(data)...)
+		}
 		return nil, NewProtocolError(protocolNum, "no parser registered for this protocol")
 	}
+	if log != nil && !known {
+		log.Debug("unknown protocol", packetAttrs(data)...)
+	}
+
+	// Learning mode: keep track of protocols without a parser
+	if opts.Quarantine != nil && !known {
+		fx.quarantine(opts.Quarantine, protocolNum, data)
+	}
 
 	// Return a base packet for unknown protocols
 	serialNum, _ := splitter.GetSerialNumber(data)
@@ -145,9 +199,58 @@ func (d *Decoder) Decode(data []byte) (packet.Packet, error) {
 		ParsedAt:    time.Now(),
 	}
 
+	if m := fx.metrics(opts.Metrics); m != nil {
+		m.PacketDecoded(protocolNum)
+	}
 	return basePacket, nil
 }
 
+// parse runs the registered parser, recording its duration in opts.ParseStats
+// and in opts.Metrics if they implement ParseMetrics, deferred to fx when set.
+// The login timezone tz applies with DeviceTimezone.
+func (d *Decoder) parse(protocolNum byte, data []byte, opts *Options, tz *types.Timezone, fx *effects) (packet.Packet, error) {
+	parseMetrics, _ := opts.Metrics.(ParseMetrics)
+	var start time.Time
+	if opts.ParseStats != nil || parseMetrics != nil {
+		start = time.Now()
+	}
+
+	var pkt packet.Packet
+	var err error
+	if !opts.DeviceTimezone {
+		tz = nil
+	}
+	switch {
+	case opts == &d.opts && tz == nil:
+		pkt, err = d.registry.Parse(protocolNum, data)
+	case opts == &d.opts:
+		ctx := d.registry.Context()
+		ctx.Timezone = tz
+		pkt, err = d.registry.ParseWithContext(protocolNum, data, ctx)
+	default:
+		ctx := parserContext(opts)
+		ctx.Timezone = tz
+		pkt, err = d.registry.ParseWithContext(protocolNum, data, ctx)
+	}
+
+	if start.IsZero() {
+		return pkt, err
+	}
+	elapsed := time.Since(start)
+	if parseMetrics != nil {
+		fx.do(func() { parseMetrics.ParseDuration(protocolNum, elapsed) })
+	}
+	if opts.ParseStats != nil {
+		var name string
+		if p, ok := d.registry.Get(protocolNum); ok {
+			name = p.Name()
+		}
+		timedOut := IsParseTimeout(err)
+		fx.do(func() { opts.ParseStats.Record(protocolNum, name, elapsed, timedOut, data) })
+	}
+	return pkt, err
+}
+
 // DecodeStream decodes packets from a TCP stream
 //
 // This method handles the common scenario where multiple packets are
@@ -185,8 +288,20 @@ func (d *Decoder) Decode(data []byte) (packet.Packet, error) {
 //	    }
 //	}
 func (d *Decoder) DecodeStream(stream []byte) (packets []packet.Packet, residue []byte, err error) {
+	return d.decodeStream(stream, nil)
+}
+
+// decodeStream decodes a stream of the device session sess, if any
+func (d *Decoder) decodeStream(stream []byte, sess *DeviceSession) (packets []packet.Packet, residue []byte, err error) {
 	// Split the stream into individual packets
-	rawPackets, residue, err := splitter.SplitPackets(stream)
+	rawPackets, residue, err := d.opts.split(stream)
+
+	// Stream limit errors, and with a resync policy ErrResyncLimit, are
+	// returned with the packets found around them
+	var limitErr error
+	if d.opts.Resync != nil || IsStreamLimitError(err) {
+		limitErr, err = err, nil
+	}
 	if err != nil {
 		// If split fails, try to continue with what we have
 		if !d.opts.StrictMode {
@@ -199,26 +314,32 @@ func (d *Decoder) DecodeStream(stream []byte) (packets []packet.Packet, residue
 		}
 	}
 
-	// Decode each packet
+	// Decode each packet, ahead of time with WithParallelism unless the
+	// packets depend on the login before them
+	decode := func(i int) (packet.Packet, error) { return d.decode(rawPackets[i], sess.timezone(), nil) }
+	workers := min(d.opts.Parallelism, len(rawPackets))
+	if workers > 1 && (sess == nil || !d.usesDeviceTimezone()) {
+		decode = d.decodeParallel(rawPackets, workers)
+	}
 	packets = make([]packet.Packet, 0, len(rawPackets))
 	for i, raw := range rawPackets {
-		pkt, decodeErr := d.Decode(raw)
+		pkt, decodeErr := decode(i)
+		sess.observe(pkt)
 		if decodeErr != nil {
 			if d.opts.StrictMode {
 				// In strict mode, fail on first error
 				return packets, residue, fmt.Errorf("failed to decode packet %d: %w", i, decodeErr)
 			}
 			// In lenient mode, skip invalid packets
-			if d.opts.EnableLogging {
-				// TODO: Add logging
-				_ = decodeErr
+			if log := d.opts.logger(); log != nil {
+				log.Debug("packet skipped", append(packetAttrs(raw), "index", i, "err", decodeErr)...)
 			}
 			continue
 		}
 		packets = append(packets, pkt)
 	}
 
-	return packets, residue, nil
+	return packets, residue, limitErr
 }
 
 // SplitPackets splits concatenated packets without decoding them
@@ -226,8 +347,12 @@ func (d *Decoder) DecodeStream(stream []byte) (packets []packet.Packet, residue
 // This is useful if you want to split packets but decode them later,
 // or if you want to forward raw packets to another system.
 //
-// Returns the same values as splitter.SplitPackets
+// Returns the same values as splitter.SplitPackets, applying the decoder's
+// ResyncPolicy and stream limits if set
 func (d *Decoder) SplitPackets(data []byte) (packets [][]byte, residue []byte, err error) {
+	if d.opts.customSplit() {
+		return d.opts.split(data)
+	}
 	return splitter.SplitPackets(data)
 }
 
@@ -235,8 +360,7 @@ func (d *Decoder) SplitPackets(data []byte) (packets [][]byte, residue []byte, e
 //
 // Returns nil if CRC is valid, error otherwise
 func (d *Decoder) ValidateCRC(data []byte) error {
-	if !validator.ValidateCRC(data) {
-		received, calculated, _ := validator.VerifyPacketCRC(data)
+	if received, calculated, valid := validator.VerifyPacketCRCWith(data, d.opts.crc()); !valid {
 		return NewCRCError(calculated, received, len(data))
 	}
 	return nil
@@ -246,7 +370,21 @@ func (d *Decoder) ValidateCRC(data []byte) error {
 //
 // Returns nil if structure is valid, error otherwise
 func (d *Decoder) ValidateStructure(data []byte) error {
-	return d.validateStructure(data)
+	return validateStructure(data, &d.opts)
+}
+
+// usesDeviceTimezone reports whether date-times depend on the login
+// timezone of the device, for any protocol
+func (d *Decoder) usesDeviceTimezone() bool {
+	if d.opts.DeviceTimezone {
+		return true
+	}
+	for _, o := range d.protoOpts {
+		if o.DeviceTimezone {
+			return true
+		}
+	}
+	return false
 }
 
 // GetOptions returns a copy of the decoder options
@@ -260,11 +398,64 @@ func (d *Decoder) SetOptions(opts Options) error {
 		return err
 	}
 	d.opts = opts
+	d.protoOpts = resolveProtocolOptions(&d.opts)
+	if d.registry != nil {
+		d.registry.SetContext(parserContext(&d.opts))
+	}
 	return nil
 }
 
+// optionsFor returns the effective options for a protocol number
+func (d *Decoder) optionsFor(protocolNum byte) *Options {
+	if o, ok := d.protoOpts[protocolNum]; ok {
+		return o
+	}
+	return &d.opts
+}
+
+// resolveProtocolOptions precomputes the effective options of each overridden protocol
+func resolveProtocolOptions(opts *Options) map[byte]*Options {
+	if len(opts.ProtocolOptions) == 0 {
+		return nil
+	}
+	resolved := make(map[byte]*Options, len(opts.ProtocolOptions))
+	for proto := range opts.ProtocolOptions {
+		effective := opts.ForProtocol(proto)
+		resolved[proto] = &effective
+	}
+	return resolved
+}
+
+// parserContext builds the parser context for a set of options
+func parserContext(opts *Options) parser.Context {
+	return parser.Context{
+		StrictMode:        opts.StrictMode,
+		ValidateIMEI:      opts.ValidateIMEIChecksum,
+		IDMapper:          opts.IDMapper,
+		TimeLocation:      opts.TimeLocation,
+		Timeout:           opts.ParseTimeout,
+		MaxStringLength:   opts.MaxStringLength,
+		MaxInfoDataLength: opts.MaxInfoDataLength,
+		MaxNeighborCells:  opts.MaxNeighborCells,
+		Logger:            opts.logger(),
+		AcceptZeroTime:    opts.AcceptZeroTime,
+		SatelliteNibble:   opts.SatelliteNibble,
+		SpeedFormat:       opts.SpeedFormat,
+	}
+}
+
+// route applies option-driven re-classification to a parsed packet
+func route(pkt packet.Packet, opts *Options) packet.Packet {
+	if opts.ACCAlarmsAsStatus {
+		if status := packet.NewACCStatusPacket(pkt); status != nil {
+			return status
+		}
+	}
+	return pkt
+}
+
 // validateStructure performs basic packet structure validation
-func (d *Decoder) validateStructure(data []byte) error {
+func validateStructure(data []byte, opts *Options) error {
 	if len(data) < protocol.MinPacketSize {
 		return ErrInvalidPacketSize
 	}
@@ -299,7 +490,7 @@ func (d *Decoder) validateStructure(data []byte) error {
 	}
 
 	// Check max packet size
-	if len(data) > d.opts.MaxPacketSize {
+	if len(data) > opts.MaxPacketSize {
 		return ErrBufferOverflow
 	}
 
@@ -0,0 +1,93 @@
+package jimi
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// ACC alarm packets (0x26) with alarm type 0xFE (ON) and 0xFF (OFF), and a
+// speed alarm, generated with valid checksums; the same seed makes the alarm
+// type their only difference
+var (
+	accOnAlarmHex  = packets.NewGenerator(1).Alarm(protocol.AlarmACCOn).Hex
+	accOffAlarmHex = packets.NewGenerator(1).Alarm(protocol.AlarmACCOff).Hex
+	speedAlarmHex  = packets.NewGenerator(1).Alarm(protocol.AlarmSpeed).Hex
+)
+
+func TestDecoder_ACCAlarmsDefault(t *testing.T) {
+	decoder := NewDecoder()
+
+	pkt, err := decoder.DecodeHex(accOnAlarmHex)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	alarm, ok := pkt.(*packet.AlarmPacket)
+	if !ok {
+		t.Fatalf("Expected *packet.AlarmPacket, got %T", pkt)
+	}
+	if alarm.AlarmType != protocol.AlarmACCOn {
+		t.Errorf("Expected ACC ON alarm, got %s", alarm.AlarmType)
+	}
+	if !packet.IsACCAlarm(pkt) {
+		t.Error("Expected IsACCAlarm to be true")
+	}
+}
+
+func TestDecoder_ACCAlarmsAsStatus(t *testing.T) {
+	decoder := NewDecoder(WithACCAlarmsAsStatus())
+
+	tests := []struct {
+		name   string
+		hex    string
+		wantOn bool
+	}{
+		{"acc on", accOnAlarmHex, true},
+		{"acc off", accOffAlarmHex, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := decoder.DecodeHex(tt.hex)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			status, ok := pkt.(*packet.ACCStatusPacket)
+			if !ok {
+				t.Fatalf("Expected *packet.ACCStatusPacket, got %T", pkt)
+			}
+			if status.ACCOn() != tt.wantOn {
+				t.Errorf("Expected ACCOn=%v, got %v", tt.wantOn, status.ACCOn())
+			}
+			if status.ProtocolNumber() != protocol.ProtocolAlarm {
+				t.Errorf("Expected protocol 0x%02X, got 0x%02X", protocol.ProtocolAlarm, status.ProtocolNumber())
+			}
+			if _, ok := status.Alarm.(*packet.AlarmPacket); !ok {
+				t.Errorf("Expected original *packet.AlarmPacket, got %T", status.Alarm)
+			}
+			if !packet.IsACCAlarm(pkt) {
+				t.Error("Expected IsACCAlarm to be true")
+			}
+		})
+	}
+}
+
+func TestDecoder_ACCAlarmsAsStatusKeepsRealAlarms(t *testing.T) {
+	decoder := NewDecoder(WithACCAlarmsAsStatus())
+
+	pkt, err := decoder.DecodeHex(speedAlarmHex)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if _, ok := pkt.(*packet.AlarmPacket); !ok {
+		t.Fatalf("Expected *packet.AlarmPacket, got %T", pkt)
+	}
+	if packet.IsACCAlarm(pkt) {
+		t.Error("Expected IsACCAlarm to be false for speed alarm")
+	}
+}
@@ -0,0 +1,101 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+func TestDecoder_CorruptedPackets(t *testing.T) {
+	// Each mutation is rejected with the error of the rule it breaks
+	isCRCError := func(err error) bool {
+		var crcErr *CRCError
+		return errors.As(err, &crcErr)
+	}
+	isParseError := func(err error) bool {
+		return err != nil && strings.HasPrefix(err.Error(), "failed to parse protocol")
+	}
+	want := map[packets.Mutation]func(error) bool{
+		packets.MutateCRC:             isCRCError,
+		packets.MutateStartBit:        func(err error) bool { return errors.Is(err, ErrInvalidStartBit) },
+		packets.MutateStopBit:         func(err error) bool { return errors.Is(err, ErrInvalidStopBit) },
+		packets.MutateLength:          func(err error) bool { return errors.Is(err, ErrInvalidPacketLength) },
+		packets.MutateTooShort:        func(err error) bool { return errors.Is(err, ErrInvalidPacketSize) },
+		packets.MutateTruncateContent: isParseError,
+		packets.MutateDateTime:        isParseError,
+	}
+
+	decoder := NewDecoder(WithStrictMode(true))
+	for _, tp := range packets.NewGenerator(3).Packets() {
+		frame, err := hex.DecodeString(tp.Hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range packets.Mutations() {
+			data, err := packets.Corrupt(frame, m)
+			if errors.Is(err, packets.ErrNotApplicable) {
+				continue
+			}
+			// An information type alone is a valid transfer
+			if m == packets.MutateTruncateContent && tp.Protocol == protocol.ProtocolInfoTransfer {
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %s: %v", tp.Name, m, err)
+				continue
+			}
+			_, err = decoder.Decode(data)
+			if !want[m](err) {
+				t.Errorf("%s %s: unexpected error %v (% X)", tp.Name, m, err, data)
+			}
+		}
+	}
+
+	if _, err := packets.Corrupt([]byte{0x78, 0x78, 0x05}, packets.MutateCRC); err == nil {
+		t.Error("Expected an error for a truncated packet")
+	}
+}
+
+func TestCorruptedPackets(t *testing.T) {
+	// heartbeat: 6 mutations (no date-time), location and alarm: all 7
+	if got := len(packets.CorruptedPackets); got != 20 {
+		t.Errorf("Expected 20 corrupted packets, got %d", got)
+	}
+	decoder := NewDecoder()
+	for _, tp := range packets.CorruptedPackets {
+		if tp.Valid {
+			t.Errorf("%s: expected an invalid packet", tp.Name)
+		}
+		if _, err := decoder.DecodeHex(tp.Hex); err == nil {
+			t.Errorf("%s: decoded, expected an error", tp.Name)
+		}
+	}
+}
+
+func TestDecoder_LengthMismatchWithoutStructureValidation(t *testing.T) {
+	// The parsers reject a wrong declared length the decoder did not check
+	decoder := NewDecoder(WithStrictMode(true), WithSkipStructureValidation())
+	for _, tp := range packets.NewGenerator(4).Packets() {
+		frame, err := hex.DecodeString(tp.Hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := packets.Corrupt(frame, packets.MutateLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = decoder.Decode(data)
+		var lengthErr *PacketLengthError
+		if !errors.As(err, &lengthErr) || !errors.Is(err, ErrInvalidPacketLength) {
+			t.Errorf("%s: expected a length error, got %v", tp.Name, err)
+			continue
+		}
+		if lengthErr.Declared != lengthErr.Actual+1 {
+			t.Errorf("%s: unexpected %+v", tp.Name, lengthErr)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package jimi
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// kermitCRC is a custom variant, plugged in through the CRC interface
+type kermitCRC struct{}
+
+func (kermitCRC) Checksum(data []byte) uint16 {
+	return CRCITU.Checksum(data)
+}
+
+func TestWithCRCVariant(t *testing.T) {
+	hb := &packet.HeartbeatPacket{}
+	hb.SerialNum = 7
+	x25 := encoder.New().Heartbeat(hb)
+	itu := (&encoder.Encoder{UseShortFormat: true, CRC: CRCITU}).Heartbeat(hb)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		data    []byte
+		wantErr bool
+	}{
+		{"default X.25", nil, x25, false},
+		{"default rejects ITU", nil, itu, true},
+		{"ITU", []Option{WithCRCVariant(CRCITU)}, itu, false},
+		{"ITU rejects X.25", []Option{WithCRCVariant(CRCITU)}, x25, true},
+		{"custom", []Option{WithCRCVariant(kermitCRC{})}, itu, false},
+		{"table variant", []Option{WithCRCVariant(TableCRC{Init: 0xFFFF, XorOut: 0xFFFF})}, x25, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(tt.opts...)
+			_, err := d.Decode(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decode: expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !IsInvalidCRC(err) {
+				t.Errorf("Expected a CRC error, got %v", err)
+			}
+			if err := d.ValidateCRC(tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCRC: expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
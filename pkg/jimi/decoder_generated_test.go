@@ -0,0 +1,67 @@
+package jimi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/parser"
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+func TestDecoder_GeneratedPackets(t *testing.T) {
+	gen := packets.NewGenerator(7)
+	generated := gen.Packets()
+
+	covered := make(map[byte]bool)
+	for _, tp := range generated {
+		covered[tp.Protocol] = true
+	}
+	for _, proto := range parser.DefaultRegistry().List() {
+		if !covered[proto] {
+			t.Errorf("No generated packet for protocol 0x%02X", proto)
+		}
+	}
+
+	// Generated packets pass the checksum and IMEI validation, also in strict mode
+	for _, decoder := range []*Decoder{NewDecoder(), NewDecoder(WithStrictMode(true))} {
+		for _, tp := range generated {
+			p, err := decoder.DecodeHex(tp.Hex)
+			if err != nil {
+				t.Errorf("%s: %v (%s)", tp.Name, err, tp.Hex)
+				continue
+			}
+			if p.ProtocolNumber() != tp.Protocol {
+				t.Errorf("%s: decoded protocol 0x%02X", tp.Name, p.ProtocolNumber())
+			}
+			if err := p.Validate(); err != nil {
+				t.Errorf("%s: invalid packet: %v", tp.Name, err)
+			}
+
+			switch p := p.(type) {
+			case *packet.LoginPacket:
+				if p.GetIMEI() != gen.IMEI() {
+					t.Errorf("%s: expected IMEI %s, got %s", tp.Name, gen.IMEI(), p.GetIMEI())
+				}
+			case *packet.LocationPacket:
+				if !p.CourseStatus.IsPositioned || p.Coordinates.IsZero() || p.LBSInfo.MCC == 0 {
+					t.Errorf("%s: unrealistic fix %+v", tp.Name, p)
+				}
+			}
+		}
+	}
+
+	// The same seed builds the same packets
+	if again := packets.NewGenerator(7).Packets(); !reflect.DeepEqual(again, generated) {
+		t.Error("Expected the same packets for the same seed")
+	}
+	if other := packets.NewGenerator(8).Packets(); reflect.DeepEqual(other, generated) {
+		t.Error("Expected other packets for another seed")
+	}
+	if _, ok := gen.Packet(0x22); !ok {
+		t.Error("Expected a location packet")
+	}
+	if _, ok := gen.Packet(0xEE); ok {
+		t.Error("Expected no packet of an unknown protocol")
+	}
+}
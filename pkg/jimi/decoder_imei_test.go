@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 func TestDecoder_WithoutIMEIValidation(t *testing.T) {
@@ -92,3 +93,30 @@ func TestDecoder_IMEIValidationOption(t *testing.T) {
 		t.Error("Decoder with WithoutIMEIValidation() should have ValidateIMEIChecksum=false")
 	}
 }
+
+func TestDecoder_WithIDMapper(t *testing.T) {
+	// Login packet of a device reporting the 16-digit ID 8612345678901234
+	data, _ := hex.DecodeString("787811018612345678901234044D03200001ABCD0D0A")
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		wantID string
+	}{
+		{"default mapper", nil, "8612345678901234"},
+		{"custom mapper", []Option{WithIDMapper(func(bcd []byte) (types.DeviceID, error) {
+			return types.DeviceID("dev-" + hex.EncodeToString(bcd[6:])), nil
+		})}, "dev-1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := NewDecoder(append(tt.opts, WithSkipCRC())...).Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pkt.(*packet.LoginPacket).GetIMEI(); got != tt.wantID {
+				t.Errorf("Expected device ID %s, got %s", tt.wantID, got)
+			}
+		})
+	}
+}
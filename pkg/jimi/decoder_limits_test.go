@@ -0,0 +1,162 @@
+package jimi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestDecoder_FieldLimits(t *testing.T) {
+	info, err := encoder.New().InfoTransfer(packet.NewInfoTransferPacket(protocol.InfoTypeTerminalSync, bytes.Repeat([]byte("A"), 200)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := encoder.New().CustomResponse(protocol.ProtocolCommandResponse,
+		append([]byte{204, 0, 0, 0, 1}, bytes.Repeat([]byte("x"), 200)...), 1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    []Option
+		wantErr bool
+	}{
+		{"info data within default", info, nil, false},
+		{"info data over limit", info, []Option{WithMaxInfoDataLength(100)}, true},
+		{"info data unlimited", info, []Option{WithMaxInfoDataLength(0)}, false},
+		{"response within default", response, nil, false},
+		{"response over limit", response, []Option{WithMaxStringLength(64)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDecoder(tt.opts...).Decode(tt.data)
+			if tt.wantErr != IsFieldLimitError(err) {
+				t.Errorf("Expected limit error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Decode failed: %v", err)
+			}
+		})
+	}
+
+	// Lenient mode falls back to a base packet
+	pkt, err := NewDecoder(WithLenientMode(), WithMaxInfoDataLength(100)).Decode(info)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, ok := pkt.(*packet.BasePacket); !ok {
+		t.Errorf("Expected base packet, got %T", pkt)
+	}
+}
+
+func TestDecoder_MaxNeighborCells(t *testing.T) {
+	lbs := packet.NewLBSPacket(types.Now(), types.NewLBSInfo(460, 0, 0x287D, 0x1F71))
+	for i := 0; i < 6; i++ {
+		lbs.NeighborCells = append(lbs.NeighborCells, types.NewLBSInfo(460, 0, 0x2800+uint32(i), uint64(i+1)))
+	}
+	lbs.TimingAdvance = 0x0F
+	lbs.Language = protocol.LanguageEnglish
+	data := encoder.New().LBS(lbs)
+
+	for _, n := range []int{0, 2, 6} {
+		pkt, err := NewDecoder(WithMaxNeighborCells(n)).Decode(data)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		got := pkt.(*packet.LBSPacket)
+
+		want := n
+		if n == 0 {
+			want = 6
+		}
+		if len(got.NeighborCells) != want {
+			t.Errorf("MaxNeighborCells(%d): expected %d cells, got %d", n, want, len(got.NeighborCells))
+		}
+		// Skipped cells do not shift the fields that follow
+		if got.TimingAdvance != 0x0F || got.Language != protocol.LanguageEnglish {
+			t.Errorf("MaxNeighborCells(%d): unexpected trailer %d/%v", n, got.TimingAdvance, got.Language)
+		}
+	}
+}
+
+func TestOptions_ValidateLimits(t *testing.T) {
+	for _, opt := range []Option{WithMaxStringLength(-1), WithMaxInfoDataLength(-1), WithMaxNeighborCells(-1)} {
+		opts := DefaultOptions()
+		opt(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Error("Expected error for negative limit")
+		}
+	}
+}
+
+func TestDecoder_StreamLimits(t *testing.T) {
+	hb := streamHeartbeatHex
+	filler := strings.Repeat("00", 30)
+
+	tests := []struct {
+		name        string
+		data        string
+		opts        []Option
+		wantPackets int
+		wantResidue int
+		wantLimit   string
+	}{
+		{"huge length waits forever", "7979FFFF" + hb, nil, 0, 17, ""},
+		{"huge length", "7979FFFF" + hb, []Option{WithMaxDeclaredLength(1024)}, 1, 0, "declared length"},
+		{"huge length lenient", "7979FFFF" + hb, []Option{WithLenientMode(), WithMaxDeclaredLength(1024)}, 1, 0, "declared length"},
+		{"length within limit", hb + hb, []Option{WithMaxDeclaredLength(8)}, 2, 0, ""},
+		{"stuck residue", "7878FF" + filler + hb, []Option{WithMaxResidueSize(32)}, 1, 0, "residue size"},
+		{"residue within limit", "7878FF" + filler + hb, []Option{WithMaxResidueSize(64)}, 0, 46, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.data)
+			packets, residue, err := NewDecoder(tt.opts...).DecodeStream(data)
+			if len(packets) != tt.wantPackets || len(residue) != tt.wantResidue {
+				t.Errorf("Expected %d packets and %d residue bytes, got %d and %d",
+					tt.wantPackets, tt.wantResidue, len(packets), len(residue))
+			}
+
+			var limitErr *StreamLimitError
+			switch {
+			case tt.wantLimit == "" && err != nil:
+				t.Errorf("Unexpected error %v", err)
+			case tt.wantLimit != "" && (!errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit):
+				t.Errorf("Expected %s limit error, got %v", tt.wantLimit, err)
+			}
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.MaxResidueSize = -1
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected validation error for a negative residue size")
+	}
+}
+
+func TestStreamDecoder_StreamLimits(t *testing.T) {
+	s := NewStreamDecoder(streamOf(t, streamLoginHex, "7979FFFF", streamHeartbeatHex), WithMaxDeclaredLength(1024))
+
+	if _, err := s.Next(); err != nil {
+		t.Fatalf("Expected login, got %v", err)
+	}
+	// The error follows the packets split with it
+	if p, err := s.Next(); err != nil || p.ProtocolNumber() != protocol.ProtocolHeartbeat {
+		t.Errorf("Expected heartbeat after the corrupted header, got %v, %v", p, err)
+	}
+	if _, err := s.Next(); !IsStreamLimitError(err) {
+		t.Errorf("Expected stream limit error, got %v", err)
+	}
+	if _, err := s.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package jimi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecords decodes the JSON lines written by a slog.JSONHandler
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestDecoder_Logging(t *testing.T) {
+	// Heartbeat with a corrupted CRC
+	badCRC := "787808132404020001FFFF0D0A"
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		stream   bool
+		hex      string
+		wantMsg  string
+		wantAttr map[string]any
+	}{
+		{
+			name:     "crc mismatch",
+			hex:      badCRC,
+			wantMsg:  "crc mismatch",
+			wantAttr: map[string]any{"protocol": "0x13", "hex": strings.ToLower(badCRC), "received": "0xFFFF"},
+		},
+		{
+			name:     "unknown protocol",
+			opts:     []Option{WithSkipCRC()},
+			hex:      "787805EE00010000" + "0D0A",
+			wantMsg:  "unknown protocol rejected",
+			wantAttr: map[string]any{"protocol": "0xEE"},
+		},
+		{
+			name:     "packet skipped in lenient stream",
+			opts:     []Option{WithLenientMode()},
+			stream:   true,
+			hex:      badCRC,
+			wantMsg:  "packet skipped",
+			wantAttr: map[string]any{"protocol": "0x13", "index": float64(0)},
+		},
+		{
+			name:     "garbage discarded",
+			stream:   true,
+			hex:      "DEADBEEF787808132404020001870D0D0A",
+			wantMsg:  "stream bytes discarded",
+			wantAttr: map[string]any{"reason": "no start bit", "hex": "deadbeef", "len": float64(4)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			decoder := NewDecoder(append(tt.opts, WithLogger(newTestLogger(&buf)))...)
+			data, _ := hex.DecodeString(tt.hex)
+			if tt.stream {
+				decoder.DecodeStream(data)
+			} else {
+				decoder.Decode(data)
+			}
+
+			for _, r := range logRecords(t, &buf) {
+				if r["msg"] != tt.wantMsg {
+					continue
+				}
+				for k, v := range tt.wantAttr {
+					if r[k] != v {
+						t.Errorf("Attribute %s = %v, want %v", k, r[k], v)
+					}
+				}
+				return
+			}
+			t.Errorf("No %q record in:\n%s", tt.wantMsg, buf.String())
+		})
+	}
+}
+
+func TestDecoder_LoggingDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	decoder := NewDecoder(WithLogger(newTestLogger(&buf)))
+	decoder.opts.EnableLogging = false
+
+	data, _ := hex.DecodeString("DEADBEEF787808132404020001FFFF0D0A")
+	decoder.DecodeStream(data)
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected no logs, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,44 @@
+package jimi
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/parser"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// panickingParser is a buggy custom parser
+type panickingParser struct{}
+
+func (panickingParser) ProtocolNumber() byte { return 0x99 }
+func (panickingParser) Name() string         { return "Panicking" }
+func (panickingParser) Parse(data []byte, ctx parser.Context) (packet.Packet, error) {
+	panic("index out of range")
+}
+
+func TestDecoder_RecoversParserPanic(t *testing.T) {
+	data := unknownPacket(0x99, []byte{0x01}, 1)
+
+	strict := NewDecoder()
+	if err := strict.RegisterParser(panickingParser{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Decode(data); !IsPanicError(err) {
+		t.Errorf("Expected panic error in strict mode, got %v", err)
+	}
+
+	// Lenient mode falls back to a base packet, and the stream keeps going
+	lenient := NewDecoder(WithLenientMode())
+	lenient.RegisterParser(panickingParser{})
+	stream := append(append([]byte{}, data...), 0x78, 0x78, 0x08, 0x13, 0x24, 0x04, 0x02, 0x00, 0x01, 0x87, 0x0D, 0x0D, 0x0A)
+	packets, _, err := lenient.DecodeStream(stream)
+	if err != nil {
+		t.Fatalf("DecodeStream failed: %v", err)
+	}
+	if len(packets) != 2 {
+		t.Fatalf("Expected 2 packets, got %d", len(packets))
+	}
+	if _, ok := packets[0].(*packet.BasePacket); !ok {
+		t.Errorf("Expected base packet for the panicking protocol, got %T", packets[0])
+	}
+}
@@ -0,0 +1,179 @@
+package jimi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// mixedStream returns the test packets of every protocol that decode with
+// the default options, repeated n times, as one stream, and the number of
+// packets in it
+func mixedStream(tb testing.TB, n int) ([]byte, int) {
+	tb.Helper()
+	decoder := NewDecoder()
+	var round []byte
+	count := 0
+	for _, tp := range packets.GetAllValidPackets() {
+		data, err := hex.DecodeString(tp.Hex)
+		if err != nil {
+			tb.Fatalf("%s: %v", tp.Name, err)
+		}
+		if _, err := decoder.Decode(data); err == nil {
+			round = append(round, data...)
+			count++
+		}
+	}
+	return bytes.Repeat(round, n), count * n
+}
+
+// rawPackets returns the raw bytes of decoded packets
+func rawPackets(pkts []packet.Packet) [][]byte {
+	out := make([][]byte, len(pkts))
+	for i, p := range pkts {
+		out[i] = p.Raw()
+	}
+	return out
+}
+
+func TestDecodeStream_Parallel(t *testing.T) {
+	stream, count := mixedStream(t, 3)
+	// A packet with an invalid CRC after the first round of packets
+	bad, _ := hex.DecodeString(packets.InvalidPackets[3].Hex)
+	first, _ := mixedStream(t, 1)
+	corrupted := append(append(first, bad...), stream...)
+
+	tests := []struct {
+		name   string
+		stream []byte
+		strict bool
+	}{
+		{"strict", stream, true},
+		{"lenient", stream, false},
+		{"strict with bad packet", corrupted, true},
+		{"lenient with bad packet", corrupted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, wantResidue, wantErr := NewDecoder(WithStrictMode(tt.strict)).DecodeStream(tt.stream)
+			if len(want) == 0 {
+				t.Fatalf("Expected packets from the sequential decoder, got none (%v)", wantErr)
+			}
+			for _, workers := range []int{2, 8, 64} {
+				d := NewDecoder(WithStrictMode(tt.strict), WithParallelism(workers))
+				got, residue, err := d.DecodeStream(tt.stream)
+				if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+					t.Errorf("%d workers: expected error %v, got %v", workers, wantErr, err)
+				}
+				if !reflect.DeepEqual(rawPackets(got), rawPackets(want)) || !bytes.Equal(residue, wantResidue) {
+					t.Errorf("%d workers: got %d packets, expected the %d of the sequential decoder in order",
+						workers, len(got), len(want))
+				}
+			}
+		})
+	}
+
+	if got, _, _ := NewDecoder(WithStrictMode(false), WithParallelism(4)).DecodeStream(stream); len(got) != count {
+		t.Errorf("Expected %d packets, got %d", count, len(got))
+	}
+}
+
+// countingMetrics counts the Metrics calls
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (m *countingMetrics) count(name string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	m.counts[name] += n
+}
+
+func (m *countingMetrics) BytesProcessed(n int)              { m.count("bytes", n) }
+func (m *countingMetrics) PacketDecoded(byte)                { m.count("decoded", 1) }
+func (m *countingMetrics) CRCFailure(byte)                   { m.count("crc", 1) }
+func (m *countingMetrics) UnknownProtocol(byte)              { m.count("unknown", 1) }
+func (m *countingMetrics) Resync(int)                        { m.count("resync", 1) }
+func (m *countingMetrics) ParseDuration(byte, time.Duration) { m.count("parsed", 1) }
+
+func TestDecodeStream_ParallelStrictSideEffects(t *testing.T) {
+	// Unknown protocols before and after a packet with an invalid CRC
+	first, _ := mixedStream(t, 1)
+	rest, _ := mixedStream(t, 20)
+	bad := unknownPacket(0x9B, []byte{0x03}, 3)
+	bad[len(bad)-4] ^= 0xFF
+	var stream []byte
+	stream = append(stream, first...)
+	stream = append(stream, unknownPacket(0x99, []byte{0x01}, 1)...)
+	stream = append(stream, bad...)
+	stream = append(stream, unknownPacket(0x9A, []byte{0x02}, 2)...)
+	stream = append(stream, rest...)
+
+	decode := func(workers int) (map[string]int, []QuarantineEntry) {
+		m := &countingMetrics{}
+		q := NewQuarantine()
+		d := NewDecoder(WithStrictMode(true), WithLearningMode(q), WithMetrics(m), WithParallelism(workers))
+		if _, _, err := d.DecodeStream(stream); err == nil {
+			t.Fatalf("%d workers: expected an error", workers)
+		}
+		return m.counts, q.Entries()
+	}
+	wantCounts, wantEntries := decode(1)
+	if len(wantEntries) != 1 || wantEntries[0].Protocol != 0x99 {
+		t.Fatalf("Expected only 0x99 quarantined sequentially, got %+v", wantEntries)
+	}
+	for _, workers := range []int{2, 8, 64} {
+		counts, entries := decode(workers)
+		if !reflect.DeepEqual(counts, wantCounts) {
+			t.Errorf("%d workers: expected metrics %v, got %v", workers, wantCounts, counts)
+		}
+		if len(entries) != len(wantEntries) || entries[0].Protocol != 0x99 {
+			t.Errorf("%d workers: expected quarantine %+v, got %+v", workers, wantEntries, entries)
+		}
+	}
+}
+
+func TestWithParallelism_Validate(t *testing.T) {
+	opts := DefaultOptions()
+	WithParallelism(-1)(&opts)
+	if err := opts.Validate(); !IsValidationError(err) {
+		t.Errorf("Expected a validation error for negative parallelism, got %v", err)
+	}
+}
+
+// Benchmark decoding a stream of mixed protocols, sequentially and in parallel
+func BenchmarkDecodeStream(b *testing.B) {
+	stream, _ := mixedStream(b, 50)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			decoder := NewDecoder(WithStrictMode(false), WithParallelism(workers))
+			b.SetBytes(int64(len(stream)))
+			b.ResetTimer()
+			for b.Loop() {
+				_, _, _ = decoder.DecodeStream(stream)
+			}
+		})
+	}
+}
+
+// Benchmark splitting a stream of mixed protocols without decoding
+func BenchmarkSplitPackets(b *testing.B) {
+	stream, _ := mixedStream(b, 50)
+	decoder := NewDecoder()
+	b.SetBytes(int64(len(stream)))
+	b.ResetTimer()
+	for b.Loop() {
+		_, _, _ = decoder.SplitPackets(stream)
+	}
+}
@@ -0,0 +1,197 @@
+package jimi
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// badCRCHeartbeatHex is a valid heartbeat with a corrupted CRC
+const badCRCHeartbeatHex = "787808132404020001FFFF0D0A"
+
+func TestDecoder_ProtocolOptionsSkipCRC(t *testing.T) {
+	decoder := NewDecoder(WithProtocolOptions(protocol.ProtocolAlarm, WithSkipCRC()))
+
+	badCRCAlarmHex := accOnAlarmHex[:len(accOnAlarmHex)-8] + "FFFF0D0A"
+	if _, err := decoder.DecodeHex(badCRCAlarmHex); err != nil {
+		t.Errorf("Expected alarm CRC to be skipped, got %v", err)
+	}
+
+	_, err := decoder.DecodeHex(badCRCHeartbeatHex)
+	var crcErr *CRCError
+	if !errors.As(err, &crcErr) {
+		t.Errorf("Expected CRC error for heartbeat, got %v", err)
+	}
+}
+
+func TestDecoder_ProtocolOptionsParserContext(t *testing.T) {
+	// Sample login packets carry IMEIs with invalid checksums
+	login := packets.LoginPackets[0].Hex
+
+	strict := NewDecoder(WithSkipCRC())
+	if _, err := strict.DecodeHex(login); err == nil {
+		t.Fatal("Expected IMEI checksum error without override")
+	}
+
+	decoder := NewDecoder(WithSkipCRC(), WithProtocolOptions(protocol.ProtocolLogin, WithoutIMEIValidation()))
+	pkt, err := decoder.DecodeHex(login)
+	if err != nil {
+		t.Fatalf("Expected login to decode with override, got %v", err)
+	}
+	if _, ok := pkt.(*packet.LoginPacket); !ok {
+		t.Errorf("Expected *packet.LoginPacket, got %T", pkt)
+	}
+}
+
+func TestDecoder_ProtocolOptionsRouting(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithProtocolOptions(protocol.ProtocolAlarm, WithACCAlarmsAsStatus()))
+
+	pkt, err := decoder.DecodeHex(accOnAlarmHex)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, ok := pkt.(*packet.ACCStatusPacket); !ok {
+		t.Errorf("Expected *packet.ACCStatusPacket, got %T", pkt)
+	}
+}
+
+func TestOptions_ProtocolOptions(t *testing.T) {
+	opts := DefaultOptions()
+	WithProtocolOptions(protocol.ProtocolInfoTransfer, WithSkipCRC())(&opts)
+	WithProtocolOptions(protocol.ProtocolInfoTransfer, WithMaxPacketSize(2048))(&opts)
+
+	effective := opts.ForProtocol(protocol.ProtocolInfoTransfer)
+	if !effective.SkipCRCValidation || effective.MaxPacketSize != 2048 {
+		t.Errorf("Expected accumulated overrides, got %+v", effective)
+	}
+	if base := opts.ForProtocol(protocol.ProtocolLogin); base.SkipCRCValidation {
+		t.Error("Expected other protocols to keep base options")
+	}
+	if opts.IsProduction() {
+		t.Error("Expected protocol-level SkipCRC to make options non-production")
+	}
+
+	clone := opts.Clone()
+	WithProtocolOptions(protocol.ProtocolLogin, WithSkipCRC())(&clone)
+	if _, ok := opts.ProtocolOptions[protocol.ProtocolLogin]; ok {
+		t.Error("Expected Clone to copy protocol options")
+	}
+
+	WithProtocolOptions(protocol.ProtocolLogin, WithMaxPacketSize(1<<21))(&opts)
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected invalid protocol override to fail validation")
+	}
+}
+
+func TestDecoder_ProtocolOptionsSatelliteNibble(t *testing.T) {
+	// Firmware swapping the nibbles of the GPS info byte in alarms only
+	enc := encoder.New()
+	coords := types.MustNewCoordinates(22.546, 113.945)
+	alarm := packet.NewAlarmPacket(types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)), coords, protocol.AlarmSOS)
+	alarm.Satellites = 9
+	frame := enc.Alarm(alarm)
+	content := append([]byte(nil), frame[4:len(frame)-6]...)
+	content[6] = 0x9C
+	swapped := enc.CustomResponse(protocol.ProtocolAlarm, content, 0)
+
+	// 0x27 read the high nibble before the option existed
+	fence := enc.AlarmMultiFence(&packet.AlarmMultiFencePacket{AlarmPacket: *alarm, FenceID: 2})
+	fenceContent := append([]byte(nil), fence[4:len(fence)-6]...)
+	fenceContent[6] = 0x9C
+	fenceSwapped := enc.CustomResponse(protocol.ProtocolAlarmMultiFence, fenceContent, 0)
+
+	tests := []struct {
+		name string
+		data []byte
+		opts []Option
+		want uint8
+	}{
+		{"protocol default", frame, nil, 9},
+		{"swapped default", swapped, nil, 12},
+		{"swapped high", swapped, []Option{WithProtocolOptions(protocol.ProtocolAlarm, WithSatelliteNibble(SatellitesHighNibble))}, 9},
+		{"swapped auto", swapped, []Option{WithProtocolOptions(protocol.ProtocolAlarm, WithSatelliteNibble(SatellitesAuto))}, 9},
+		{"standard auto", frame, []Option{WithSatelliteNibble(SatellitesAuto)}, 9},
+		{"other protocol", swapped, []Option{WithProtocolOptions(protocol.ProtocolGPSLocation, WithSatelliteNibble(SatellitesHighNibble))}, 12},
+		{"multi-fence default", fence, nil, 9},
+		{"multi-fence swapped default", fenceSwapped, nil, 12},
+		{"multi-fence swapped high", fenceSwapped, []Option{WithProtocolOptions(protocol.ProtocolAlarmMultiFence, WithSatelliteNibble(SatellitesHighNibble))}, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := NewDecoder(tt.opts...).Decode(tt.data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			var got uint8
+			switch p := pkt.(type) {
+			case *packet.AlarmPacket:
+				got = p.Satellites
+			case *packet.AlarmMultiFencePacket:
+				got = p.Satellites
+			default:
+				t.Fatalf("Expected an alarm, got %T", pkt)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d satellites, got %d", tt.want, got)
+			}
+		})
+	}
+
+	opts := DefaultOptions()
+	WithSatelliteNibble(SatelliteNibble(7))(&opts)
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected an invalid satellite nibble to fail validation")
+	}
+}
+
+func TestDecoder_ProtocolOptionsSpeedFormat(t *testing.T) {
+	// Firmware widening the speed of 4G location packets to two bytes
+	enc := encoder.New()
+	coords := types.MustNewCoordinates(22.546, 113.945)
+	loc := packet.NewLocationPacket(types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)), coords, 60,
+		types.NewCourseStatus(90, true, true, true, true))
+	loc.LBSInfo = types.NewLBSInfo(460, 0, 0x1234, 0x5678)
+	frame := enc.Location4G(&packet.Location4GPacket{LocationPacket: *loc})
+	content := append([]byte(nil), frame[4:len(frame)-6]...)
+	wide := slices.Concat(content[:15], []byte{0x01, 0x2C}, content[16:]) // 300 km/h
+	widened := enc.CustomResponse(protocol.ProtocolGPSLocation4G, wide, 0)
+
+	tests := []struct {
+		name string
+		data []byte
+		opts []Option
+		want uint16
+	}{
+		{"protocol default", frame, nil, 60},
+		{"knots", frame, []Option{WithSpeedFormat(SpeedKnots)}, 111},
+		{"two bytes", widened, []Option{WithProtocolOptions(protocol.ProtocolGPSLocation4G, WithSpeedFormat(SpeedKmh16))}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := NewDecoder(tt.opts...).Decode(tt.data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			got := pkt.(*packet.Location4GPacket)
+			if got.Speed != tt.want {
+				t.Errorf("Expected %d km/h, got %d", tt.want, got.Speed)
+			}
+			if got.CourseStatus.Course != 90 || got.LBSInfo.CellID != 0x5678 {
+				t.Errorf("Fields after the speed misaligned: %v / %v", got.CourseStatus, got.LBSInfo)
+			}
+		})
+	}
+
+	opts := DefaultOptions()
+	WithSpeedFormat(SpeedFormat(7))(&opts)
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected an invalid speed format to fail validation")
+	}
+}
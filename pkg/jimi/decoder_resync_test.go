@@ -0,0 +1,121 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// resyncHex decodes concatenated hex parts
+func resyncHex(t *testing.T, parts ...string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// errAny matches any non-nil error in TestDecoder_ResyncPolicy
+var errAny = errors.New("any error")
+
+func TestDecoder_ResyncPolicy(t *testing.T) {
+	hb := streamHeartbeatHex
+	badStop := "787808132404020001870D0D0B"
+	garbage := strings.Repeat("DEADBEEF", 5)
+
+	tests := []struct {
+		name        string
+		data        []string
+		policy      *ResyncPolicy
+		wantPackets int
+		wantResidue string
+		wantErr     error
+	}{
+		{"trailing garbage, strict", []string{hb, "DEADBEEF"}, nil, 0, "", errAny},
+		{"trailing garbage", []string{hb, "DEADBEEF"}, &ResyncPolicy{}, 1, "", nil},
+		{"leading garbage", []string{"DEADBEEF", hb}, &ResyncPolicy{}, 1, "", nil},
+		{"partial start bit kept", []string{hb, "DEADBE78"}, &ResyncPolicy{}, 1, "78", nil},
+		{"invalid stop bit, strict", []string{hb, badStop}, nil, 0, "", errAny},
+		{"invalid stop bit", []string{hb, badStop, hb}, &ResyncPolicy{}, 2, "", nil},
+		{"discard limit", []string{hb, garbage, hb}, &ResyncPolicy{MaxDiscardBytes: 8}, 1, "", ErrResyncLimit},
+		{"within discard limit", []string{hb, garbage, hb}, &ResyncPolicy{MaxDiscardBytes: 20}, 2, "", nil},
+		{"scan limit", []string{hb, garbage, hb}, &ResyncPolicy{MaxScanBytes: 8}, 1, garbage[20:] + hb, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.policy != nil {
+				opts = append(opts, WithResyncPolicy(*tt.policy))
+			}
+			packets, residue, err := NewDecoder(opts...).DecodeStream(resyncHex(t, tt.data...))
+			switch {
+			case tt.wantErr == errAny && err == nil:
+				t.Error("Expected error")
+			case tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if len(packets) != tt.wantPackets {
+				t.Errorf("Expected %d packets, got %d", tt.wantPackets, len(packets))
+			}
+			if got := strings.ToUpper(hex.EncodeToString(residue)); got != tt.wantResidue {
+				t.Errorf("Expected residue %q, got %q", tt.wantResidue, got)
+			}
+		})
+	}
+}
+
+func TestDecoder_ResyncPolicy_ScanResumes(t *testing.T) {
+	var discarded []string
+	d := NewDecoder(WithResyncPolicy(ResyncPolicy{
+		MaxScanBytes: 8,
+		OnDiscard: func(b []byte, reason string) {
+			discarded = append(discarded, reason)
+		},
+	}))
+
+	// The residue of each call is scanned further by the next one
+	buffer := resyncHex(t, streamHeartbeatHex, strings.Repeat("00", 30), streamHeartbeatHex)
+	total := 0
+	for calls := 1; len(buffer) > 0; calls++ {
+		packets, residue, err := d.DecodeStream(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(packets)
+		buffer = residue
+		if calls > 10 {
+			t.Fatal("Scan does not progress")
+		}
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 packets, got %d", total)
+	}
+	if len(discarded) < 3 || discarded[0] != "no start bit" {
+		t.Errorf("Unexpected discards %v", discarded)
+	}
+
+	frames, _, err := d.SplitPackets(resyncHex(t, streamHeartbeatHex, "FFFF"+"FFFF"))
+	if err != nil || len(frames) != 1 {
+		t.Errorf("SplitPackets() = %d frames, %v", len(frames), err)
+	}
+}
+
+func TestStreamDecoder_ResyncLimit(t *testing.T) {
+	data := resyncHex(t, streamLoginHex, strings.Repeat("00", 40))
+	s := NewStreamDecoder(strings.NewReader(string(data)+string(resyncHex(t, streamHeartbeatHex))),
+		WithResyncPolicy(ResyncPolicy{MaxDiscardBytes: 16}))
+
+	if _, err := s.Next(); err != nil {
+		t.Fatalf("Expected login, got %v", err)
+	}
+	if _, err := s.Next(); !errors.Is(err, ErrResyncLimit) {
+		t.Errorf("Expected ErrResyncLimit, got %v", err)
+	}
+	if _, err := s.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the dropped data, got %v", err)
+	}
+}
@@ -3,9 +3,13 @@ package jimi
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 
+	"github.com/fcode09/jimi-vl103m/internal/validator"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 func TestNewDecoder(t *testing.T) {
@@ -250,6 +254,35 @@ func TestDecodeStream(t *testing.T) {
 	t.Logf("Decoded %d packets, residue length: %d", len(packets), len(residue))
 }
 
+func TestDecodeStream_ReuploadTrain(t *testing.T) {
+	// Fixes buffered offline come back as one 0x22 packet each, often in a
+	// single read, and keep the time they were recorded at
+	enc := encoder.New()
+	start := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	var stream []byte
+	for i := range 3 {
+		loc := packet.NewLocationPacket(types.NewDateTime(start.Add(time.Duration(i)*time.Minute)),
+			types.MustNewCoordinates(-33.868820, 151.209296), 30, types.NewCourseStatus(90, true, true, false, true))
+		loc.IsReupload = true
+		loc.SerialNum = uint16(i + 1)
+		stream = append(stream, enc.Location(loc)...)
+	}
+
+	pkts, residue, err := NewDecoder().DecodeStream(stream)
+	if err != nil || len(residue) != 0 || len(pkts) != 3 {
+		t.Fatalf("Expected 3 packets, got %d (residue %d, %v)", len(pkts), len(residue), err)
+	}
+	for i, p := range pkts {
+		loc, ok := p.(*packet.LocationPacket)
+		if !ok {
+			t.Fatalf("Expected *packet.LocationPacket, got %T", p)
+		}
+		if want := start.Add(time.Duration(i) * time.Minute); !loc.DateTime.Time.Equal(want) || !loc.IsReupload {
+			t.Errorf("Packet %d: expected a re-upload at %v, got %v (re-upload %v)", i, want, loc.DateTime, loc.IsReupload)
+		}
+	}
+}
+
 // Benchmark decoder creation
 func BenchmarkNewDecoder(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -267,3 +300,22 @@ func BenchmarkGetProtocolNumber(b *testing.B) {
 		_, _ = decoder.GetProtocolNumber(packet)
 	}
 }
+
+func TestDecodeAlarm_WithoutCellStrict(t *testing.T) {
+	// An SOS alarm sent without cell info: LBS length 0, the LBS bytes zero
+	dt := types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC))
+	alarm := packet.NewAlarmPacket(dt, types.MustNewCoordinates(-33.868820, 151.209296), protocol.AlarmSOS)
+	frame := encoder.New().Alarm(alarm)
+	frame[22] = 0 // LBS length, after the 4-byte header and 18 bytes of GPS
+	frame = validator.AppendCRC(frame[:len(frame)-4])
+	frame = append(frame, 0x0D, 0x0A)
+
+	pkt, err := NewDecoder().Decode(frame)
+	if err != nil {
+		t.Fatalf("Expected the alarm to decode in strict mode, got %v", err)
+	}
+	got := pkt.(*packet.AlarmPacket)
+	if got.AlarmType != protocol.AlarmSOS || got.LBSInfo.IsValid() {
+		t.Errorf("Expected an SOS alarm without cell, got %v %+v", got.AlarmType, got.LBSInfo)
+	}
+}
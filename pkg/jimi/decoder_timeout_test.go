@@ -0,0 +1,100 @@
+package jimi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/parser"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// sleepingParser is a custom parser that takes too long
+type sleepingParser struct{ d time.Duration }
+
+func (sleepingParser) ProtocolNumber() byte { return 0x99 }
+func (sleepingParser) Name() string         { return "Sleeping" }
+func (p sleepingParser) Parse(data []byte, ctx parser.Context) (packet.Packet, error) {
+	time.Sleep(p.d)
+	return &packet.BasePacket{ProtocolNum: 0x99}, nil
+}
+
+func TestDecoder_ParseTimeout(t *testing.T) {
+	data := unknownPacket(0x99, []byte{0x01}, 1)
+
+	var slow []SlowParse
+	stats := NewParseStats(WithSlowParseHandler(func(s SlowParse) { slow = append(slow, s) }))
+
+	strict := NewDecoder(WithParseTimeout(10*time.Millisecond), WithParseStats(stats))
+	strict.RegisterParser(sleepingParser{200 * time.Millisecond})
+	if _, err := strict.Decode(data); !IsParseTimeout(err) {
+		t.Errorf("Expected parse timeout in strict mode, got %v", err)
+	}
+
+	// Lenient mode falls back to a base packet
+	lenient := NewDecoder(WithLenientMode(), WithParseTimeout(10*time.Millisecond), WithParseStats(stats))
+	lenient.RegisterParser(sleepingParser{200 * time.Millisecond})
+	pkt, err := lenient.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, ok := pkt.(*packet.BasePacket); !ok {
+		t.Errorf("Expected base packet, got %T", pkt)
+	}
+
+	s, ok := stats.Protocol(0x99)
+	if !ok {
+		t.Fatal("Expected stats for protocol 0x99")
+	}
+	if s.Count != 2 || s.Timeouts != 2 || s.Slow != 2 || s.Parser != "Sleeping" {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if len(slow) != 2 || !slow[0].TimedOut || slow[0].Hex == "" {
+		t.Errorf("Unexpected slow parse reports %+v", slow)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	var slow []SlowParse
+	stats := NewParseStats(
+		WithSlowParseThreshold(5*time.Millisecond),
+		WithSlowParseHandler(func(s SlowParse) { slow = append(slow, s) }),
+	)
+
+	decoder := NewDecoder(WithParseStats(stats))
+	decoder.RegisterParser(sleepingParser{20 * time.Millisecond})
+
+	heartbeat := []byte{0x78, 0x78, 0x08, 0x13, 0x24, 0x04, 0x02, 0x00, 0x01, 0x87, 0x0D, 0x0D, 0x0A}
+	for i := 0; i < 3; i++ {
+		if _, err := decoder.Decode(heartbeat); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+	}
+	if _, err := decoder.Decode(unknownPacket(0x99, []byte{0x01}, 1)); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	all := stats.Stats()
+	if len(all) != 2 || all[0].Protocol != 0x13 || all[1].Protocol != 0x99 {
+		t.Fatalf("Unexpected stats %+v", all)
+	}
+	if all[0].Count != 3 || all[0].Slow != 0 || all[0].Average() > all[0].Max {
+		t.Errorf("Unexpected heartbeat stats %+v", all[0])
+	}
+	if all[1].Slow != 1 || all[1].Timeouts != 0 || all[1].Max < 20*time.Millisecond {
+		t.Errorf("Unexpected stats for the slow parser %+v", all[1])
+	}
+	if len(slow) != 1 || slow[0].Protocol != 0x99 || slow[0].TimedOut {
+		t.Errorf("Unexpected slow parse reports %+v", slow)
+	}
+	if top := stats.Slowest(1); len(top) != 1 || top[0].Protocol != 0x99 {
+		t.Errorf("Expected the slow parser first, got %+v", top)
+	}
+	if top := stats.Slowest(0); len(top) != 2 || top[1].Protocol != 0x13 {
+		t.Errorf("Expected every protocol, got %+v", top)
+	}
+
+	stats.Reset()
+	if len(stats.Stats()) != 0 {
+		t.Error("Expected no stats after Reset")
+	}
+}
@@ -0,0 +1,112 @@
+package jimi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// timezoneLogin encodes a login packet reporting offsetMinutes
+func timezoneLogin(t *testing.T, offsetMinutes int) []byte {
+	t.Helper()
+	login, err := encoder.New().Login(packet.NewLoginPacket(types.MustNewIMEI("359339073930520"), 0x4D01,
+		types.Timezone{OffsetMinutes: offsetMinutes, Language: protocol.LanguageEnglish}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return login
+}
+
+func TestDeviceSession_Timezone(t *testing.T) {
+	login := timezoneLogin(t, 480)
+	wall := time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)
+	coords := types.MustNewCoordinates(-33.868820, 151.209296)
+	location := encoder.New().Location(packet.NewLocationPacket(types.NewDateTime(wall), coords, 0, types.CourseStatus{}))
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		login     bool
+		wantTime  time.Time
+		wantLocal string
+	}{
+		{"default", nil, true, wall, "14:30 UTC"},
+		{"timezone before login", []Option{WithDeviceTimezone()}, false, wall, "14:30 UTC"},
+		{"timezone", []Option{WithDeviceTimezone()}, true, wall, "22:30 UTC+08:00"},
+		{"fixed local time", []Option{WithTimeLocation(-300)}, true, wall.Add(5 * time.Hour), "14:30 UTC-05:00"},
+		{"local time before login", []Option{WithDeviceTimezone(), WithTimeLocation(-300)}, false, wall.Add(5 * time.Hour), "14:30 UTC-05:00"},
+		{"local time", []Option{WithDeviceTimezone(), WithTimeLocation(-300)}, true, wall.Add(-8 * time.Hour), "14:30 UTC+08:00"},
+		{"per protocol", []Option{WithProtocolOptions(protocol.ProtocolGPSLocation, WithDeviceTimezone(), WithTimeLocation(0))}, true, wall.Add(-8 * time.Hour), "14:30 UTC+08:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := NewDecoder(tt.opts...).NewDeviceSession()
+			if tt.login {
+				if _, err := sess.Decode(login); err != nil {
+					t.Fatal(err)
+				}
+				if tz, ok := sess.DeviceTimezone(); !ok || tz.OffsetMinutes != 480 {
+					t.Errorf("Unexpected device timezone %v %v", tz, ok)
+				}
+			}
+			pkt, err := sess.Decode(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dt := pkt.(*packet.LocationPacket).DateTime
+			if !dt.Time.Equal(tt.wantTime) {
+				t.Errorf("Time = %v, want %v", dt.Time, tt.wantTime)
+			}
+			if got := dt.Local().Format("15:04 MST"); got != tt.wantLocal {
+				t.Errorf("Local() = %s, want %s", got, tt.wantLocal)
+			}
+		})
+	}
+
+	offset := 15 * 60
+	if err := NewDecoder().SetOptions(Options{MaxPacketSize: 1024, TimeLocation: &offset}); err == nil {
+		t.Error("Expected an error for an offset beyond 14 hours")
+	}
+}
+
+func TestDeviceSession_SharedDecoder(t *testing.T) {
+	wall := time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)
+	coords := types.MustNewCoordinates(-33.868820, 151.209296)
+	location := encoder.New().Location(packet.NewLocationPacket(types.NewDateTime(wall), coords, 0, types.CourseStatus{}))
+	decoder := NewDecoder(WithDeviceTimezone(), WithParallelism(8))
+
+	// Each device keeps its own timezone, even with the login and the fixes
+	// after it in the same buffer
+	for _, offset := range []int{480, -300} {
+		var stream []byte
+		stream = append(stream, timezoneLogin(t, offset)...)
+		for range 16 {
+			stream = append(stream, location...)
+		}
+		packets, _, err := decoder.NewDeviceSession().DecodeStream(stream)
+		if err != nil || len(packets) != 17 {
+			t.Fatalf("Expected 17 packets, got %d (%v)", len(packets), err)
+		}
+		for _, p := range packets[1:] {
+			if tz := p.(*packet.LocationPacket).DateTime.Timezone; tz == nil || tz.OffsetMinutes != offset {
+				t.Fatalf("Expected timezone %d, got %v", offset, tz)
+			}
+		}
+	}
+
+	// The decoder itself remembers no login
+	if _, err := decoder.Decode(timezoneLogin(t, 480)); err != nil {
+		t.Fatal(err)
+	}
+	pkt, err := decoder.Decode(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tz := pkt.(*packet.LocationPacket).DateTime.Timezone; tz != nil {
+		t.Errorf("Expected no timezone without a session, got %v", tz)
+	}
+}
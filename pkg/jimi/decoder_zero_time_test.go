@@ -0,0 +1,74 @@
+package jimi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestDecoder_ZeroTime(t *testing.T) {
+	// A cold-booted device reports an SOS alarm before its clock is set
+	coords := types.MustNewCoordinates(-33.868820, 151.209296)
+	zero := types.DateTime{ZeroTime: true}
+	enc := encoder.New()
+	alarm := enc.Alarm(packet.NewAlarmPacket(zero, coords, protocol.AlarmSOS))
+	location := enc.Location(packet.NewLocationPacket(zero, coords, 0, types.CourseStatus{}))
+
+	for _, data := range [][]byte{alarm, location} {
+		if _, err := NewDecoder().Decode(data); !errors.Is(err, ErrZeroDateTime) {
+			t.Errorf("Expected ErrZeroDateTime by default, got %v", err)
+		}
+
+		before := time.Now().Truncate(time.Second)
+		pkt, err := NewDecoder(WithAcceptZeroTime()).Decode(data)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if !packet.HasZeroTime(pkt) {
+			t.Errorf("%s: expected a zero time flag", pkt.Type())
+		}
+		if ts := pkt.Timestamp(); ts.Before(before) || ts.After(time.Now()) {
+			t.Errorf("%s: expected the receive time, got %v", pkt.Type(), ts)
+		}
+		if err := pkt.Validate(); err != nil {
+			t.Errorf("%s: %v", pkt.Type(), err)
+		}
+	}
+
+	pkt, err := NewDecoder(WithAcceptZeroTime()).Decode(alarm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := pkt.(*packet.AlarmPacket); a.AlarmType != protocol.AlarmSOS {
+		t.Errorf("Expected an SOS alarm, got %v", a.AlarmType)
+	}
+	// Re-encoding restores the bytes the device sent
+	if got := enc.Alarm(pkt.(*packet.AlarmPacket)); string(got) != string(alarm) {
+		t.Errorf("Expected % X, got % X", alarm, got)
+	}
+}
+
+func TestHasZeroTime(t *testing.T) {
+	dt := types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC))
+	tests := []struct {
+		name   string
+		packet packet.Packet
+		want   bool
+	}{
+		{"clock set", packet.NewLocationPacket(dt, types.Coordinates{}, 0, types.CourseStatus{}), false},
+		{"substituted", packet.NewLocationPacket(types.ReceivedDateTime(time.Now()), types.Coordinates{}, 0, types.CourseStatus{}), true},
+		{"no date-time", &packet.HeartbeatPacket{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := packet.HasZeroTime(tt.packet); got != tt.want {
+				t.Errorf("HasZeroTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
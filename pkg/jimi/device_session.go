@@ -0,0 +1,75 @@
+package jimi
+
+import (
+	"sync/atomic"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// DeviceSession decodes the packets of one device with a shared Decoder,
+// remembering what the device reported at login for the packets after it:
+// its timezone, applied with WithDeviceTimezone. Keep one per device
+// connection (or UDP peer); the Decoder itself remembers nothing, so it can
+// be shared by all of them.
+//
+// Example:
+//
+//	decoder := jimi.NewDecoder(jimi.WithDeviceTimezone())
+//	// per connection
+//	sess := decoder.NewDeviceSession()
+//	packets, residue, err := sess.DecodeStream(buffer)
+type DeviceSession struct {
+	decoder *Decoder
+
+	// tz is the timezone of the last login packet decoded
+	tz atomic.Pointer[types.Timezone]
+}
+
+// NewDeviceSession creates a session decoding the packets of one device
+func (d *Decoder) NewDeviceSession() *DeviceSession {
+	return &DeviceSession{decoder: d}
+}
+
+// Decode decodes a single complete packet of the device (see Decoder.Decode)
+func (s *DeviceSession) Decode(data []byte) (packet.Packet, error) {
+	pkt, err := s.decoder.decode(data, s.timezone(), nil)
+	s.observe(pkt)
+	return pkt, err
+}
+
+// DecodeStream decodes packets of the device from a TCP stream (see
+// Decoder.DecodeStream). Packets after a login in the same stream get its
+// timezone; with WithDeviceTimezone they are decoded sequentially, ignoring
+// WithParallelism.
+func (s *DeviceSession) DecodeStream(stream []byte) (packets []packet.Packet, residue []byte, err error) {
+	return s.decoder.decodeStream(stream, s)
+}
+
+// DeviceTimezone returns the timezone of the last login packet decoded,
+// applied to the date-times decoded after it with WithDeviceTimezone
+func (s *DeviceSession) DeviceTimezone() (types.Timezone, bool) {
+	if tz := s.timezone(); tz != nil {
+		return *tz, true
+	}
+	return types.Timezone{}, false
+}
+
+// timezone returns the login timezone (nil if unknown or s is nil)
+func (s *DeviceSession) timezone() *types.Timezone {
+	if s == nil {
+		return nil
+	}
+	return s.tz.Load()
+}
+
+// observe remembers the timezone of a login packet
+func (s *DeviceSession) observe(pkt packet.Packet) {
+	if s == nil {
+		return
+	}
+	if login, ok := pkt.(*packet.LoginPacket); ok {
+		tz := login.Timezone
+		s.tz.Store(&tz)
+	}
+}
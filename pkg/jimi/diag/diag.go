@@ -0,0 +1,365 @@
+// Package diag provides diagnostics that cross-check decoded packets.
+//
+// The VL103M reports the same device state in several packet types:
+// ACC in the heartbeat TerminalInfo, the dedicated location ACC byte and the
+// alarm TerminalInfo; GPS fix in TerminalInfo bit 6 and in CourseStatus;
+// battery voltage in heartbeat, alarm and 4G LBS packets. When two of those
+// sources disagree within a short window, it usually points to a parser
+// bit-mapping bug or flaky firmware rather than a real state change.
+//
+// Example usage:
+//
+//	checker := diag.NewChecker(
+//	    diag.WithWindow(10*time.Second),
+//	    diag.WithReporter(func(i diag.Inconsistency) {
+//	        log.Printf("DIAG: %s", i)
+//	    }),
+//	)
+//
+//	for _, pkt := range packets {
+//	    checker.Observe(imei, pkt)
+//	}
+package diag
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// DefaultWindow is the default time window in which readings are compared
+const DefaultWindow = 10 * time.Second
+
+// Field identifies the device state being cross-checked
+type Field int
+
+const (
+	FieldACC        Field = iota // Ignition (ACC) status
+	FieldPositioned              // GPS fix status
+	FieldVoltage                 // Battery voltage level
+)
+
+// String returns the human-readable field name
+func (f Field) String() string {
+	switch f {
+	case FieldACC:
+		return "ACC"
+	case FieldPositioned:
+		return "Positioned"
+	case FieldVoltage:
+		return "Voltage"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(f))
+	}
+}
+
+// Source identifies where a reading came from
+type Source int
+
+const (
+	SourceHeartbeat Source = iota // Heartbeat TerminalInfo / voltage
+	SourceLocation                // Location ACC byte / CourseStatus
+	SourceAlarm                   // Alarm TerminalInfo / voltage
+	SourceLBS                     // 4G LBS TerminalInfo / voltage
+)
+
+// String returns the human-readable source name
+func (s Source) String() string {
+	switch s {
+	case SourceHeartbeat:
+		return "Heartbeat"
+	case SourceLocation:
+		return "Location"
+	case SourceAlarm:
+		return "Alarm"
+	case SourceLBS:
+		return "LBS"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+// Reading is a single observed value of a field
+type Reading struct {
+	// Source is the packet kind that reported the value
+	Source Source
+
+	// Protocol is the protocol number of the reporting packet
+	Protocol byte
+
+	// Value is the reported value (0/1 for boolean fields, level for voltage)
+	Value int
+
+	// At is when the packet was observed
+	At time.Time
+}
+
+// Inconsistency describes two sources disagreeing about the same field
+type Inconsistency struct {
+	// IMEI identifies the device
+	IMEI string
+
+	// Field is the disagreeing field
+	Field Field
+
+	// Current is the reading that triggered the check
+	Current Reading
+
+	// Previous is the earlier, conflicting reading from another source
+	Previous Reading
+}
+
+// String returns a human-readable representation
+func (i Inconsistency) String() string {
+	return fmt.Sprintf("Inconsistency{IMEI: %s, Field: %s, %s(0x%02X)=%s, %s(0x%02X)=%s, Gap: %s}",
+		i.IMEI, i.Field,
+		i.Current.Source, i.Current.Protocol, formatValue(i.Field, i.Current.Value),
+		i.Previous.Source, i.Previous.Protocol, formatValue(i.Field, i.Previous.Value),
+		i.Current.At.Sub(i.Previous.At))
+}
+
+// formatValue renders a reading value for the given field
+func formatValue(f Field, v int) string {
+	switch f {
+	case FieldACC:
+		if v != 0 {
+			return "ON"
+		}
+		return "OFF"
+	case FieldPositioned:
+		return fmt.Sprintf("%v", v != 0)
+	case FieldVoltage:
+		return protocol.VoltageLevel(v).String()
+	default:
+		return fmt.Sprintf("%d", v)
+	}
+}
+
+// Reporter is called for every detected inconsistency
+type Reporter func(Inconsistency)
+
+// Option configures a Checker
+type Option func(*Checker)
+
+// WithWindow sets how close in time two readings must be to be compared
+func WithWindow(d time.Duration) Option {
+	return func(c *Checker) {
+		if d > 0 {
+			c.window = d
+		}
+	}
+}
+
+// WithVoltageTolerance sets how many voltage levels two sources may differ
+// before being reported. Default: 1.
+func WithVoltageTolerance(levels int) Option {
+	return func(c *Checker) {
+		if levels >= 0 {
+			c.voltageTolerance = levels
+		}
+	}
+}
+
+// WithReporter sets the callback invoked for every inconsistency
+func WithReporter(r Reporter) Option {
+	return func(c *Checker) {
+		c.reporter = r
+	}
+}
+
+// WithClock sets the time source used to timestamp readings.
+// Readings are timestamped on arrival because heartbeat packets carry no device time.
+func WithClock(now func() time.Time) Option {
+	return func(c *Checker) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// Checker cross-checks readings from different packet types per device.
+// It is safe for concurrent use.
+type Checker struct {
+	mu               sync.Mutex
+	window           time.Duration
+	voltageTolerance int
+	reporter         Reporter
+	now              func() time.Time
+	devices          map[string]map[Field]map[Source]Reading
+	counts           map[Field]int
+}
+
+// NewChecker creates a new consistency checker
+func NewChecker(opts ...Option) *Checker {
+	c := &Checker{
+		window:           DefaultWindow,
+		voltageTolerance: 1,
+		now:              time.Now,
+		devices:          make(map[string]map[Field]map[Source]Reading),
+		counts:           make(map[Field]int),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Observe feeds a decoded packet into the checker.
+// Returns the inconsistencies detected by this packet, if any.
+func (c *Checker) Observe(imei string, p packet.Packet) []Inconsistency {
+	values := extract(p)
+	if len(values) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	var found []Inconsistency
+	for _, v := range values {
+		reading := Reading{Source: v.source, Protocol: p.ProtocolNumber(), Value: v.value, At: now}
+		found = append(found, c.check(imei, v.field, reading)...)
+	}
+	reporter := c.reporter
+	c.mu.Unlock()
+
+	if reporter != nil {
+		for _, i := range found {
+			reporter(i)
+		}
+	}
+	return found
+}
+
+// Counts returns the number of inconsistencies detected per field
+func (c *Checker) Counts() map[Field]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[Field]int, len(c.counts))
+	for k, v := range c.counts {
+		result[k] = v
+	}
+	return result
+}
+
+// Forget removes all readings for a device
+func (c *Checker) Forget(imei string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.devices, imei)
+}
+
+// check compares a reading against other sources and stores it. Caller must hold c.mu.
+func (c *Checker) check(imei string, field Field, reading Reading) []Inconsistency {
+	fields, ok := c.devices[imei]
+	if !ok {
+		fields = make(map[Field]map[Source]Reading)
+		c.devices[imei] = fields
+	}
+	sources, ok := fields[field]
+	if !ok {
+		sources = make(map[Source]Reading)
+		fields[field] = sources
+	}
+
+	var found []Inconsistency
+	for src, prev := range sources {
+		if src == reading.Source {
+			continue
+		}
+		if reading.At.Sub(prev.At) > c.window {
+			continue
+		}
+		if c.agree(field, prev.Value, reading.Value) {
+			continue
+		}
+		found = append(found, Inconsistency{
+			IMEI:     imei,
+			Field:    field,
+			Current:  reading,
+			Previous: prev,
+		})
+		c.counts[field]++
+	}
+
+	sources[reading.Source] = reading
+	return found
+}
+
+// agree reports whether two values of a field are consistent
+func (c *Checker) agree(field Field, a, b int) bool {
+	if field == FieldVoltage {
+		diff := a - b
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff <= c.voltageTolerance
+	}
+	return a == b
+}
+
+// value is a single field value extracted from a packet
+type value struct {
+	field  Field
+	source Source
+	value  int
+}
+
+// extract returns the cross-checkable values carried by a packet
+func extract(p packet.Packet) []value {
+	switch v := p.(type) {
+	case *packet.HeartbeatPacket:
+		return []value{
+			{FieldACC, SourceHeartbeat, boolValue(v.TerminalInfo.ACCOn())},
+			{FieldPositioned, SourceHeartbeat, boolValue(v.TerminalInfo.GPSTrackingEnabled())},
+			{FieldVoltage, SourceHeartbeat, int(v.VoltageLevel)},
+		}
+	case *packet.LocationPacket:
+		return locationValues(v)
+	case *packet.Location4GPacket:
+		return locationValues(&v.LocationPacket)
+	case *packet.AlarmPacket:
+		return alarmValues(v)
+	case *packet.AlarmMultiFencePacket:
+		return alarmValues(&v.AlarmPacket)
+	case *packet.Alarm4GPacket:
+		return alarmValues(&v.AlarmPacket)
+	case *packet.ACCStatusPacket:
+		return alarmValues(&v.AlarmPacket)
+	case *packet.LBS4GPacket:
+		return []value{
+			{FieldACC, SourceLBS, boolValue(v.TerminalInfo.ACCOn())},
+			{FieldVoltage, SourceLBS, int(v.VoltageLevel)},
+		}
+	default:
+		return nil
+	}
+}
+
+// locationValues extracts the dedicated ACC byte and the CourseStatus fix flag
+func locationValues(p *packet.LocationPacket) []value {
+	return []value{
+		{FieldACC, SourceLocation, boolValue(p.ACC)},
+		{FieldPositioned, SourceLocation, boolValue(p.CourseStatus.GetIsPositioned())},
+	}
+}
+
+// alarmValues extracts the alarm TerminalInfo bits and voltage
+func alarmValues(p *packet.AlarmPacket) []value {
+	return []value{
+		{FieldACC, SourceAlarm, boolValue(p.TerminalInfo.ACCOn())},
+		{FieldPositioned, SourceAlarm, boolValue(p.TerminalInfo.GPSTrackingEnabled())},
+		{FieldVoltage, SourceAlarm, int(p.VoltageLevel)},
+	}
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package diag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+// fakeClock returns a controllable clock for deterministic windows
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestChecker(opts ...Option) (*Checker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)}
+	return NewChecker(append([]Option{WithClock(clock.now)}, opts...)...), clock
+}
+
+func heartbeat(acc, gps bool, voltage protocol.VoltageLevel) *packet.HeartbeatPacket {
+	info := types.NewTerminalInfoBuilder().SetACCOn(acc).SetGPSTracking(gps).Build()
+	return packet.NewHeartbeatPacket(info, voltage, protocol.SignalGood)
+}
+
+func location(acc, positioned bool) *packet.LocationPacket {
+	p := packet.NewLocationPacket(
+		types.Now(),
+		types.MustNewCoordinates(-12.0464, -77.0428),
+		0,
+		types.NewCourseStatus(0, true, positioned, false, false),
+	)
+	p.ACC = acc
+	return p
+}
+
+func alarm(acc, gps bool, voltage protocol.VoltageLevel) *packet.AlarmPacket {
+	p := packet.NewAlarmPacket(types.Now(), types.Coordinates{}, protocol.AlarmVibration)
+	p.TerminalInfo = types.NewTerminalInfoBuilder().SetACCOn(acc).SetGPSTracking(gps).Build()
+	p.VoltageLevel = voltage
+	return p
+}
+
+func TestChecker_ConsistentSources(t *testing.T) {
+	checker, clock := newTestChecker()
+
+	checker.Observe(testIMEI, heartbeat(true, true, protocol.VoltageHigh))
+	clock.advance(2 * time.Second)
+	if found := checker.Observe(testIMEI, location(true, true)); len(found) != 0 {
+		t.Errorf("Expected no inconsistencies, got %v", found)
+	}
+	clock.advance(2 * time.Second)
+	if found := checker.Observe(testIMEI, alarm(true, true, protocol.VoltageMedium)); len(found) != 0 {
+		t.Errorf("Expected voltage within tolerance, got %v", found)
+	}
+}
+
+func TestChecker_ACCDisagreement(t *testing.T) {
+	var reported []Inconsistency
+	checker, clock := newTestChecker(WithReporter(func(i Inconsistency) {
+		reported = append(reported, i)
+	}))
+
+	checker.Observe(testIMEI, heartbeat(false, true, protocol.VoltageHigh))
+	clock.advance(3 * time.Second)
+	found := checker.Observe(testIMEI, location(true, true))
+
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 inconsistency, got %d", len(found))
+	}
+	i := found[0]
+	if i.Field != FieldACC {
+		t.Errorf("Expected field ACC, got %s", i.Field)
+	}
+	if i.Current.Source != SourceLocation || i.Previous.Source != SourceHeartbeat {
+		t.Errorf("Unexpected sources: %s vs %s", i.Current.Source, i.Previous.Source)
+	}
+	if len(reported) != 1 {
+		t.Errorf("Expected reporter to be called once, got %d", len(reported))
+	}
+	if checker.Counts()[FieldACC] != 1 {
+		t.Errorf("Expected ACC count 1, got %d", checker.Counts()[FieldACC])
+	}
+}
+
+func TestChecker_OutsideWindow(t *testing.T) {
+	checker, clock := newTestChecker(WithWindow(5 * time.Second))
+
+	checker.Observe(testIMEI, heartbeat(false, true, protocol.VoltageHigh))
+	clock.advance(time.Minute)
+	if found := checker.Observe(testIMEI, location(true, true)); len(found) != 0 {
+		t.Errorf("Expected no inconsistencies outside window, got %v", found)
+	}
+}
+
+func TestChecker_SameSourceChangesIgnored(t *testing.T) {
+	checker, clock := newTestChecker()
+
+	checker.Observe(testIMEI, heartbeat(false, true, protocol.VoltageHigh))
+	clock.advance(time.Second)
+	if found := checker.Observe(testIMEI, heartbeat(true, true, protocol.VoltageLow)); len(found) != 0 {
+		t.Errorf("Expected same-source changes to be ignored, got %v", found)
+	}
+}
+
+func TestChecker_PositionedAndVoltage(t *testing.T) {
+	checker, clock := newTestChecker()
+
+	checker.Observe(testIMEI, heartbeat(true, false, protocol.VoltageExtremelyHigh))
+	clock.advance(time.Second)
+	found := checker.Observe(testIMEI, alarm(true, true, protocol.VoltageVeryLow))
+
+	fields := make(map[Field]bool)
+	for _, i := range found {
+		fields[i.Field] = true
+	}
+	if !fields[FieldPositioned] {
+		t.Error("Expected positioned inconsistency")
+	}
+	if !fields[FieldVoltage] {
+		t.Error("Expected voltage inconsistency")
+	}
+	if fields[FieldACC] {
+		t.Error("Expected no ACC inconsistency")
+	}
+}
+
+func TestChecker_DevicesIsolated(t *testing.T) {
+	checker, clock := newTestChecker()
+
+	checker.Observe(testIMEI, heartbeat(false, true, protocol.VoltageHigh))
+	clock.advance(time.Second)
+	if found := checker.Observe("868120145233604", location(true, true)); len(found) != 0 {
+		t.Errorf("Expected devices to be isolated, got %v", found)
+	}
+}
+
+func TestChecker_IgnoresUnrelatedPackets(t *testing.T) {
+	checker, _ := newTestChecker()
+
+	if found := checker.Observe(testIMEI, &packet.LoginPacket{}); found != nil {
+		t.Errorf("Expected nil for login packet, got %v", found)
+	}
+}
@@ -0,0 +1,572 @@
+// Package dispatch derives device events from decoded packets (critical
+// alarms, geofence enter/exit of device and server-side fences, ignition
+// changes, SIM swaps, scored harsh driving) and delivers
+// them, with the devices going offline and online (see
+// server.WithOfflineWatchdog), to registered handlers and HTTP webhooks.
+//
+// Each registration has a Filter selecting event types, devices and alarm
+// types. Handlers are called in the goroutine dispatching the event;
+// webhooks are delivered in the background, retrying failed requests with
+// exponential backoff (see Webhook).
+//
+// Example usage:
+//
+//	d := dispatch.New(dispatch.WithFenceResolver(resolver))
+//	defer d.Close()
+//
+//	d.Handle(dispatch.Filter{Types: []dispatch.Type{dispatch.TypeCriticalAlarm}}, func(e dispatch.Event) {
+//	    log.Printf("%s: %s", e.IMEI, e.Alarm.Name)
+//	})
+//	d.AddWebhook(dispatch.Filter{
+//	    Types: []dispatch.Type{dispatch.TypeGeofenceEnter, dispatch.TypeGeofenceExit},
+//	    IMEIs: []string{"359339073930520"},
+//	}, dispatch.NewWebhook("https://hooks.example.com/jimi"))
+//
+//	srv.OnPacket(func(s *server.Session, p packet.Packet) {
+//	    d.Observe(s.IMEI(), p, time.Now())
+//	})
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/acc"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/driving"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/fence"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geofence"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/sim"
+)
+
+// DefaultQueueSize is the number of events buffered per webhook
+const DefaultQueueSize = 256
+
+// ErrQueueFull is reported when an event is dropped because a webhook is
+// falling behind
+var ErrQueueFull = errors.New("dispatch: webhook queue full")
+
+// Type is the kind of an event
+type Type string
+
+// Event types
+const (
+	// TypeAlarm is an alarm that is not critical, geofence or ACC related
+	TypeAlarm Type = "alarm"
+
+	// TypeCriticalAlarm is an alarm requiring immediate attention (SOS,
+	// power cut, tow/theft, tamper, collision)
+	TypeCriticalAlarm Type = "critical_alarm"
+
+	// TypeGeofenceEnter and TypeGeofenceExit are geofence alarms and
+	// crossings of server-side fences
+	TypeGeofenceEnter Type = "geofence_enter"
+	TypeGeofenceExit  Type = "geofence_exit"
+
+	// TypeACCChange is a confirmed ignition transition
+	TypeACCChange Type = "acc_change"
+
+	// TypeSIMChange is a device reporting other SIM identifiers than before
+	TypeSIMChange Type = "sim_change"
+
+	// TypeHarshDriving is a harsh-event alarm scored by a driving.Scorer
+	TypeHarshDriving Type = "harsh_driving"
+
+	// TypeDeviceOffline is a device that stopped reporting and
+	// TypeDeviceOnline one that reports again
+	TypeDeviceOffline Type = "device_offline"
+	TypeDeviceOnline  Type = "device_online"
+)
+
+// Types returns every event type
+func Types() []Type {
+	return []Type{TypeAlarm, TypeCriticalAlarm, TypeGeofenceEnter, TypeGeofenceExit, TypeACCChange, TypeSIMChange, TypeHarshDriving, TypeDeviceOffline, TypeDeviceOnline}
+}
+
+// ParseTypes parses a comma-separated list of event types
+func ParseTypes(s string) ([]Type, error) {
+	var types []Type
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(Types(), Type(v)) {
+			return nil, fmt.Errorf("unknown event type %q", v)
+		}
+		types = append(types, Type(v))
+	}
+	return types, nil
+}
+
+// Event is a device event delivered to handlers and, as JSON, to webhooks
+type Event struct {
+	// Type is the kind of event
+	Type Type `json:"type"`
+
+	// IMEI identifies the device
+	IMEI string `json:"imei"`
+
+	// Time is the device time of the event, or the receive time for packets
+	// without one
+	Time time.Time `json:"time"`
+
+	// Alarm is set for alarm, geofence and harsh driving events
+	Alarm *Alarm `json:"alarm,omitempty"`
+
+	// Fence is set for geofence events
+	Fence *Fence `json:"fence,omitempty"`
+
+	// ACC is the new ignition state of ACC change events ("ON" or "OFF")
+	ACC string `json:"acc,omitempty"`
+
+	// SIM is the SIM swap of SIM change events
+	SIM *sim.Change `json:"sim,omitempty"`
+
+	// Driving is the harsh event and new driver score of harsh driving events
+	Driving *Driving `json:"driving,omitempty"`
+
+	// Position is the device position, if known
+	Position *export.Position `json:"position,omitempty"`
+
+	// LastSeen is the time of the last packet before device offline and
+	// online events
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+
+	// Reason describes why a device went offline
+	Reason string `json:"reason,omitempty"`
+}
+
+// Alarm is the alarm of an event
+type Alarm struct {
+	Code     protocol.AlarmType `json:"code"`
+	Name     string             `json:"name"`
+	Critical bool               `json:"critical"`
+}
+
+// Fence is the geofence of an event
+type Fence struct {
+	// ID is the device fence slot, 0 for single-fence alarms and
+	// server-side fences
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	// Key is the ID of a server-side fence (see package geofence)
+	Key string `json:"key,omitempty"`
+}
+
+// Driving is the scored harsh event of an event
+type Driving struct {
+	Kind  driving.Kind `json:"kind"`
+	Score float64      `json:"score"`
+}
+
+// ACCEvent converts an ignition transition of an acc.Tracker, e.g. one
+// returned by Tracker.Flush
+func ACCEvent(ev acc.Event) Event {
+	e := Event{Type: TypeACCChange, IMEI: ev.IMEI, Time: ev.Time.UTC(), ACC: ev.State()}
+	if ev.HasPosition {
+		e.Position = &export.Position{
+			Latitude:   ev.Coordinates.SignedLatitude(),
+			Longitude:  ev.Coordinates.SignedLongitude(),
+			Positioned: true,
+		}
+	}
+	return e
+}
+
+// SIMEvent converts a SIM swap of a sim.Tracker
+func SIMEvent(c sim.Change) Event {
+	return Event{Type: TypeSIMChange, IMEI: c.IMEI, Time: c.New.Time.UTC(), SIM: &c}
+}
+
+// GeofenceEvent converts a crossing of a server-side fence
+func GeofenceEvent(ev geofence.Event) Event {
+	e := Event{
+		Type:  TypeGeofenceEnter,
+		IMEI:  ev.IMEI,
+		Time:  ev.Time.UTC(),
+		Fence: &Fence{Name: ev.Fence.DisplayName(), Key: ev.Fence.ID},
+		Position: &export.Position{
+			Latitude:   ev.Coordinates.SignedLatitude(),
+			Longitude:  ev.Coordinates.SignedLongitude(),
+			Positioned: true,
+		},
+	}
+	if ev.Transition == geofence.Exit {
+		e.Type = TypeGeofenceExit
+	}
+	return e
+}
+
+// DrivingEvent converts a harsh event of a driving.Scorer
+func DrivingEvent(ev driving.Event) Event {
+	e := Event{
+		Type:    TypeHarshDriving,
+		IMEI:    ev.IMEI,
+		Time:    ev.Time.UTC(),
+		Alarm:   &Alarm{Code: ev.Alarm, Name: ev.Alarm.String(), Critical: ev.Alarm.IsCritical()},
+		Driving: &Driving{Kind: ev.Kind, Score: ev.Score},
+	}
+	if ev.HasPosition {
+		e.Position = &export.Position{
+			Latitude:   ev.Coordinates.SignedLatitude(),
+			Longitude:  ev.Coordinates.SignedLongitude(),
+			Speed:      uint16(ev.Speed),
+			Positioned: true,
+		}
+	}
+	return e
+}
+
+// OfflineEvent builds the event of a device that went offline at t, last
+// seen at lastSeen (zero if unknown)
+func OfflineEvent(imei string, t, lastSeen time.Time, reason string) Event {
+	e := Event{Type: TypeDeviceOffline, IMEI: imei, Time: t.UTC(), Reason: reason}
+	if !lastSeen.IsZero() {
+		seen := lastSeen.UTC()
+		e.LastSeen = &seen
+	}
+	return e
+}
+
+// OnlineEvent builds the event of a device that reports again at t, last
+// seen before at lastSeen (zero if unknown)
+func OnlineEvent(imei string, t, lastSeen time.Time) Event {
+	e := OfflineEvent(imei, t, lastSeen, "")
+	e.Type = TypeDeviceOnline
+	return e
+}
+
+// Filter selects the events of a registration. Empty fields match all.
+type Filter struct {
+	// Types are the event types delivered
+	Types []Type
+
+	// IMEIs are the devices whose events are delivered
+	IMEIs []string
+
+	// AlarmTypes are the alarm types delivered. Events without an alarm
+	// (ACC changes, SIM swaps, devices offline or online) are not affected.
+	AlarmTypes []protocol.AlarmType
+}
+
+// Match reports whether an event passes the filter
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.IMEIs) > 0 && !slices.Contains(f.IMEIs, e.IMEI) {
+		return false
+	}
+	if len(f.AlarmTypes) > 0 && e.Alarm != nil && !slices.Contains(f.AlarmTypes, e.Alarm.Code) {
+		return false
+	}
+	return true
+}
+
+// Handler is called with the matching events of a registration
+type Handler func(Event)
+
+// Stats counts the events of a registration
+type Stats struct {
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`  // webhook deliveries given up after the retries
+	Dropped   uint64 `json:"dropped"` // dropped because the webhook queue was full
+}
+
+// Registration is a handler or webhook registered with a Dispatcher
+type Registration struct {
+	d       *Dispatcher
+	filter  Filter
+	handler Handler
+
+	// Webhook delivery, nil for handlers
+	webhook *Webhook
+	queue   chan Event
+	done    chan struct{}
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Stats returns the event counters
+func (r *Registration) Stats() Stats {
+	return Stats{
+		Delivered: r.delivered.Load(),
+		Failed:    r.failed.Load(),
+		Dropped:   r.dropped.Load(),
+	}
+}
+
+// Close unregisters. A webhook first delivers its queued events.
+func (r *Registration) Close() {
+	d := r.d
+	d.mu.Lock()
+	i := slices.Index(d.regs, r)
+	if i >= 0 {
+		d.regs = slices.Delete(d.regs, i, i+1)
+		if r.queue != nil {
+			close(r.queue)
+		}
+	}
+	d.mu.Unlock()
+
+	if r.done != nil {
+		<-r.done
+	}
+}
+
+// Option configures a Dispatcher
+type Option func(*Dispatcher)
+
+// WithFenceResolver resolves the fences of geofence alarms with r, so events
+// carry the fence names set on it (default a resolver without names)
+func WithFenceResolver(r *fence.Resolver) Option {
+	return func(d *Dispatcher) {
+		d.fences = r
+	}
+}
+
+// WithACCTracker derives ACC change events with t, e.g. one with a debounce
+// (default a tracker without debounce)
+func WithACCTracker(t *acc.Tracker) Option {
+	return func(d *Dispatcher) {
+		d.acc = t
+	}
+}
+
+// WithSIMTracker derives SIM change events with t, e.g. one with a
+// persistent store (default a tracker with a MemoryStore)
+func WithSIMTracker(t *sim.Tracker) Option {
+	return func(d *Dispatcher) {
+		d.sim = t
+	}
+}
+
+// WithGeofenceEngine derives geofence events from the crossings of the
+// server-side fences of e (default none)
+func WithGeofenceEngine(e *geofence.Engine) Option {
+	return func(d *Dispatcher) {
+		d.geofences = e
+	}
+}
+
+// WithDrivingScorer derives harsh driving events, carrying the driver score,
+// from the harsh-event alarms scored by s (default none). The alarms still
+// produce their alarm events.
+func WithDrivingScorer(s *driving.Scorer) Option {
+	return func(d *Dispatcher) {
+		d.driving = s
+	}
+}
+
+// WithErrorHandler sets the function called with webhook deliveries given
+// up after the retries and events dropped from full queues (default none)
+func WithErrorHandler(fn func(error)) Option {
+	return func(d *Dispatcher) {
+		d.onError = fn
+	}
+}
+
+// Dispatcher derives events from packets and delivers them to the matching
+// registrations. It is safe for concurrent use.
+type Dispatcher struct {
+	fences  *fence.Resolver
+	acc     *acc.Tracker
+	sim     *sim.Tracker
+	onError func(error)
+
+	geofences *geofence.Engine
+	driving   *driving.Scorer
+
+	mu   sync.RWMutex
+	regs []*Registration
+}
+
+// New creates a dispatcher
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.fences == nil {
+		d.fences = fence.NewResolver()
+	}
+	if d.acc == nil {
+		d.acc = acc.NewTracker()
+	}
+	if d.sim == nil {
+		d.sim = sim.NewTracker()
+	}
+	return d
+}
+
+// Handle registers a handler for the events matching f. The handler runs in
+// the goroutine of Dispatch, must be fast and must not register or close
+// registrations.
+func (d *Dispatcher) Handle(f Filter, h Handler) *Registration {
+	r := &Registration{d: d, filter: f, handler: h}
+	d.add(r)
+	return r
+}
+
+// AddWebhook registers a webhook for the events matching f. Events are
+// queued and posted by a background goroutine; when the webhook falls
+// behind, events are dropped instead of blocking Dispatch.
+func (d *Dispatcher) AddWebhook(f Filter, w *Webhook) *Registration {
+	r := &Registration{
+		d:       d,
+		filter:  f,
+		webhook: w,
+		queue:   make(chan Event, max(w.queueSize, 1)),
+		done:    make(chan struct{}),
+	}
+	go r.deliver()
+	d.add(r)
+	return r
+}
+
+// add appends a registration
+func (d *Dispatcher) add(r *Registration) {
+	d.mu.Lock()
+	d.regs = append(d.regs, r)
+	d.mu.Unlock()
+}
+
+// Dispatch delivers an event to the matching registrations
+func (d *Dispatcher) Dispatch(e Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, r := range d.regs {
+		if !r.filter.Match(e) {
+			continue
+		}
+		if r.handler != nil {
+			r.handler(e)
+			r.delivered.Add(1)
+			continue
+		}
+		select {
+		case r.queue <- e:
+		default:
+			r.dropped.Add(1)
+			d.report(fmt.Errorf("%w: %s %s event of %s", ErrQueueFull, r.webhook.url, e.Type, e.IMEI))
+		}
+	}
+}
+
+// Observe derives the events of a packet of a device received at
+// receivedAt, dispatches them and returns them. Packets of devices that
+// have not logged in yet (empty imei) are skipped.
+func (d *Dispatcher) Observe(imei string, p packet.Packet, receivedAt time.Time) []Event {
+	if imei == "" {
+		return nil
+	}
+	d.fences.Observe(imei, p)
+
+	var events []Event
+	if e, ok := d.alarmEvent(imei, p, receivedAt); ok {
+		events = append(events, e)
+	}
+	if ev, ok := d.acc.Observe(imei, p); ok {
+		events = append(events, ACCEvent(ev))
+	}
+	if c, ok := d.sim.Observe(imei, p); ok {
+		events = append(events, SIMEvent(c))
+	}
+	if d.geofences != nil {
+		for _, ev := range d.geofences.Observe(imei, p) {
+			events = append(events, GeofenceEvent(ev))
+		}
+	}
+	if d.driving != nil {
+		if ev, ok := d.driving.Observe(imei, p); ok {
+			events = append(events, DrivingEvent(ev))
+		}
+	}
+	for _, e := range events {
+		d.Dispatch(e)
+	}
+	return events
+}
+
+// Close closes every registration, delivering the queued webhook events
+func (d *Dispatcher) Close() error {
+	d.mu.RLock()
+	regs := slices.Clone(d.regs)
+	d.mu.RUnlock()
+
+	for _, r := range regs {
+		r.Close()
+	}
+	return nil
+}
+
+// alarmEvent builds the event of an alarm packet. ACC on/off alarms are
+// reported as ACC changes instead.
+func (d *Dispatcher) alarmEvent(imei string, p packet.Packet, receivedAt time.Time) (Event, bool) {
+	a, ok := p.(packet.PacketWithAlarm)
+	if !ok || packet.IsACCAlarm(p) {
+		return Event{}, false
+	}
+
+	rec := export.NewRecord(imei, p, receivedAt)
+	at := receivedAt.UTC()
+	if rec.Time != nil {
+		at = *rec.Time
+	}
+	alarm := a.GetAlarmType()
+	e := Event{
+		Type:     TypeAlarm,
+		IMEI:     imei,
+		Time:     at,
+		Alarm:    &Alarm{Code: alarm, Name: alarm.String(), Critical: a.IsCritical()},
+		Position: rec.Position,
+	}
+
+	switch {
+	case alarm == protocol.AlarmGeofenceEnter || alarm == protocol.AlarmGeofenceExit:
+		e.Type = TypeGeofenceEnter
+		if alarm == protocol.AlarmGeofenceExit {
+			e.Type = TypeGeofenceExit
+		}
+		e.Fence = &Fence{}
+		if fe, ok := d.fences.ResolveAlarm(imei, p); ok {
+			e.Fence = &Fence{ID: fe.Fence.ID, Name: fe.Fence.DisplayName()}
+		}
+	case a.IsCritical():
+		e.Type = TypeCriticalAlarm
+	}
+	return e, true
+}
+
+// deliver posts the queued events of a webhook until the queue is closed
+func (r *Registration) deliver() {
+	defer close(r.done)
+	for e := range r.queue {
+		if err := r.webhook.Deliver(context.Background(), e); err != nil {
+			r.failed.Add(1)
+			r.d.report(err)
+			continue
+		}
+		r.delivered.Add(1)
+	}
+}
+
+// report passes an error to the error handler
+func (d *Dispatcher) report(err error) {
+	if d.onError != nil {
+		d.onError(err)
+	}
+}
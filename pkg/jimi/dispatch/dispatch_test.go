@@ -0,0 +1,234 @@
+package dispatch
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/driving"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/fence"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geofence"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930520"
+
+var testTime = time.Date(2024, 6, 15, 14, 30, 45, 0, time.UTC)
+
+// alarm builds an alarm packet, with a fence ID if fenceID > 0
+func alarm(a protocol.AlarmType, fenceID uint8) packet.Packet {
+	p := packet.NewAlarmPacket(types.NewDateTime(testTime), types.MustNewCoordinates(-33.8688, 151.2093), a)
+	if fenceID == 0 {
+		return p
+	}
+	return &packet.AlarmMultiFencePacket{AlarmPacket: *p, FenceID: fenceID}
+}
+
+// heartbeat builds a heartbeat reporting the ACC state
+func heartbeat(accOn bool) packet.Packet {
+	return &packet.HeartbeatPacket{
+		TerminalInfo: types.NewTerminalInfoBuilder().SetACCOn(accOn).Build(),
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	sos := Event{Type: TypeCriticalAlarm, IMEI: testIMEI, Alarm: &Alarm{Code: protocol.AlarmSOS}}
+	offline := OfflineEvent(testIMEI, testTime, time.Time{}, "connection closed")
+
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"empty", Filter{}, sos, true},
+		{"type", Filter{Types: []Type{TypeCriticalAlarm, TypeGeofenceEnter}}, sos, true},
+		{"other type", Filter{Types: []Type{TypeACCChange}}, sos, false},
+		{"imei", Filter{IMEIs: []string{"1", testIMEI}}, sos, true},
+		{"other imei", Filter{IMEIs: []string{"1"}}, sos, false},
+		{"alarm type", Filter{AlarmTypes: []protocol.AlarmType{protocol.AlarmSOS}}, sos, true},
+		{"other alarm type", Filter{AlarmTypes: []protocol.AlarmType{protocol.AlarmPowerCut}}, sos, false},
+		{"alarm type without alarm", Filter{AlarmTypes: []protocol.AlarmType{protocol.AlarmPowerCut}}, offline, true},
+		{"all fields", Filter{
+			Types:      []Type{TypeCriticalAlarm},
+			IMEIs:      []string{testIMEI},
+			AlarmTypes: []protocol.AlarmType{protocol.AlarmSOS},
+		}, sos, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.event); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	got, err := ParseTypes("critical_alarm, geofence_exit,,device_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Type{TypeCriticalAlarm, TypeGeofenceExit, TypeDeviceOffline}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if _, err := ParseTypes("alarm,panic"); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
+
+func TestDispatcher_Observe(t *testing.T) {
+	resolver := fence.NewResolver()
+	resolver.SetName(testIMEI, 2, "Warehouse")
+	d := New(WithFenceResolver(resolver))
+
+	tests := []struct {
+		name      string
+		imei      string
+		packet    packet.Packet
+		wantType  Type
+		wantFence string
+		wantNone  bool
+	}{
+		{"critical alarm", testIMEI, alarm(protocol.AlarmSOS, 0), TypeCriticalAlarm, "", false},
+		{"alarm", testIMEI, alarm(protocol.AlarmVibration, 0), TypeAlarm, "", false},
+		{"geofence enter", testIMEI, alarm(protocol.AlarmGeofenceEnter, 2), TypeGeofenceEnter, "Warehouse", false},
+		{"geofence exit", testIMEI, alarm(protocol.AlarmGeofenceExit, 3), TypeGeofenceExit, "GFENCE3", false},
+		{"single fence", testIMEI, alarm(protocol.AlarmGeofenceExit, 0), TypeGeofenceExit, "", false},
+		// The earlier alarms reported ACC off
+		{"acc alarm", testIMEI, alarm(protocol.AlarmACCOn, 0), TypeACCChange, "", false},
+		{"not logged in", "", alarm(protocol.AlarmSOS, 0), "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := d.Observe(tt.imei, tt.packet, testTime)
+			if tt.wantNone {
+				if len(events) != 0 {
+					t.Fatalf("Expected no events, got %+v", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("Expected one event, got %+v", events)
+			}
+			e := events[0]
+			if e.Type != tt.wantType || e.IMEI != tt.imei || !e.Time.Equal(testTime) || (e.Alarm == nil) != (e.Type == TypeACCChange) {
+				t.Errorf("Unexpected event %+v", e)
+			}
+			if tt.wantFence != "" && (e.Fence == nil || e.Fence.Name != tt.wantFence) {
+				t.Errorf("Expected fence %q, got %+v", tt.wantFence, e.Fence)
+			}
+		})
+	}
+}
+
+func TestDispatcher_ACC(t *testing.T) {
+	d := New()
+	var got []string
+	d.Handle(Filter{Types: []Type{TypeACCChange}}, func(e Event) {
+		got = append(got, e.ACC)
+	})
+
+	for _, on := range []bool{false, false, true, true, false} {
+		d.Observe(testIMEI, heartbeat(on), testTime)
+	}
+	// The first state only seeds the tracker
+	if want := []string{"ON", "OFF"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestDispatcher_Handle(t *testing.T) {
+	d := New()
+	var sos, all []Type
+	r := d.Handle(Filter{AlarmTypes: []protocol.AlarmType{protocol.AlarmSOS}}, func(e Event) {
+		sos = append(sos, e.Type)
+	})
+	d.Handle(Filter{}, func(e Event) {
+		all = append(all, e.Type)
+	})
+
+	d.Observe(testIMEI, alarm(protocol.AlarmSOS, 0), testTime)
+	d.Observe(testIMEI, alarm(protocol.AlarmPowerCut, 0), testTime)
+	d.Dispatch(OfflineEvent(testIMEI, testTime, testTime.Add(-time.Minute), "timeout"))
+	r.Close()
+	d.Observe(testIMEI, alarm(protocol.AlarmSOS, 0), testTime)
+
+	if want := []Type{TypeCriticalAlarm, TypeDeviceOffline}; !slices.Equal(sos, want) {
+		t.Errorf("Expected %v, got %v", want, sos)
+	}
+	if len(all) != 4 {
+		t.Errorf("Expected 4 events, got %v", all)
+	}
+	if stats := r.Stats(); stats.Delivered != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestDispatcher_SIM(t *testing.T) {
+	d := New()
+	var got []Event
+	d.Handle(Filter{Types: []Type{TypeSIMChange}}, func(e Event) {
+		got = append(got, e)
+	})
+
+	for _, iccid := range []string{"89860012345678901234", "89860012345678901234", "89860098765432109876"} {
+		p := packet.NewInfoTransferPacket(protocol.InfoTypeICCID, nil)
+		p.ICCID, p.ParsedAt = iccid, testTime
+		d.Observe(testIMEI, p, testTime)
+	}
+	// The first identifiers only seed the tracker
+	if len(got) != 1 || got[0].SIM == nil || got[0].SIM.Old.ICCID != "89860012345678901234" ||
+		got[0].SIM.New.ICCID != "89860098765432109876" || !got[0].Time.Equal(testTime) {
+		t.Errorf("Unexpected events %+v", got)
+	}
+}
+
+func TestDispatcher_Geofence(t *testing.T) {
+	engine := geofence.NewEngine(geofence.WithMargin(0))
+	center := types.MustNewCoordinates(-33.8688, 151.2093)
+	engine.AddFence(geofence.Fence{ID: "opera", Name: "Opera House", Center: &center, Radius: 200})
+	engine.Assign(geofence.AllDevices, "opera")
+
+	d := New(WithGeofenceEngine(engine))
+	var got []Event
+	d.Handle(Filter{Types: []Type{TypeGeofenceEnter, TypeGeofenceExit}}, func(e Event) {
+		got = append(got, e)
+	})
+
+	for i, lat := range []float64{-33.8688, -33.8788, -33.8689} {
+		c := types.MustNewCoordinates(lat, 151.2093)
+		d.Observe(testIMEI, packet.NewLocationPacket(types.NewDateTime(testTime.Add(time.Duration(i)*time.Minute)), c, 0,
+			types.NewCourseStatus(0, true, true, c.IsEast, c.IsNorth)), testTime)
+	}
+	if len(got) != 2 || got[0].Type != TypeGeofenceExit || got[1].Type != TypeGeofenceEnter {
+		t.Fatalf("Unexpected events %+v", got)
+	}
+	if f := got[1].Fence; f.Key != "opera" || f.Name != "Opera House" || f.ID != 0 {
+		t.Errorf("Unexpected fence %+v", f)
+	}
+	if !got[1].Time.Equal(testTime.Add(2*time.Minute)) || got[1].Position == nil || got[1].Alarm != nil {
+		t.Errorf("Unexpected event %+v", got[1])
+	}
+}
+
+func TestDispatcher_Driving(t *testing.T) {
+	d := New(WithDrivingScorer(driving.NewScorer()))
+	var got []Event
+	d.Handle(Filter{Types: []Type{TypeHarshDriving}}, func(e Event) {
+		got = append(got, e)
+	})
+
+	for i, alarm := range []protocol.AlarmType{protocol.AlarmHarshBraking, protocol.AlarmSOS, protocol.AlarmCollision} {
+		a := packet.NewAlarmPacket(types.NewDateTime(testTime.Add(time.Duration(i)*time.Minute)), types.Coordinates{}, alarm)
+		d.Observe(testIMEI, a, testTime)
+	}
+	if len(got) != 2 || got[0].Driving.Kind != driving.KindBraking || got[0].Driving.Score != 97 {
+		t.Fatalf("Unexpected events %+v", got)
+	}
+	if got[1].Driving.Score != 77 || got[1].Alarm == nil || !got[1].Alarm.Critical || got[1].Position != nil {
+		t.Errorf("Unexpected event %+v", got[1])
+	}
+}
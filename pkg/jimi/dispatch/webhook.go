@@ -0,0 +1,187 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Default webhook settings
+const (
+	// DefaultRetries is the number of times a failed delivery is retried
+	DefaultRetries = 5
+
+	// DefaultBackoff is the wait before the first retry; it doubles with
+	// every further retry
+	DefaultBackoff = time.Second
+
+	// DefaultMaxBackoff bounds the wait between retries
+	DefaultMaxBackoff = time.Minute
+
+	// DefaultTimeout bounds each request
+	DefaultTimeout = 10 * time.Second
+)
+
+// StatusError is a webhook response with an unexpected status code
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// Temporary reports whether the request may succeed when retried: server
+// errors, timeouts and rate limiting
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests
+}
+
+// Webhook posts events as JSON to a URL. Each request carries the event
+// type in the X-Jimi-Event header; any 2xx response acknowledges the event.
+// Network errors, 5xx, 408 and 429 responses are retried with exponential
+// backoff, other responses fail at once.
+type Webhook struct {
+	url        string
+	client     *http.Client
+	header     http.Header
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+	queueSize  int
+}
+
+// WebhookOption configures a Webhook
+type WebhookOption func(*Webhook)
+
+// WithHTTPClient sets the HTTP client (default http.DefaultClient)
+func WithHTTPClient(c *http.Client) WebhookOption {
+	return func(w *Webhook) {
+		w.client = c
+	}
+}
+
+// WithHeader adds a header to the requests, e.g. an Authorization token
+func WithHeader(key, value string) WebhookOption {
+	return func(w *Webhook) {
+		w.header.Add(key, value)
+	}
+}
+
+// WithRetries sets the number of times a failed delivery is retried
+// (default DefaultRetries)
+func WithRetries(n int) WebhookOption {
+	return func(w *Webhook) {
+		w.retries = n
+	}
+}
+
+// WithBackoff sets the wait before the first retry and its bound (default
+// DefaultBackoff and DefaultMaxBackoff)
+func WithBackoff(initial, limit time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.backoff, w.maxBackoff = initial, limit
+	}
+}
+
+// WithTimeout bounds each request (default DefaultTimeout)
+func WithTimeout(d time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.timeout = d
+	}
+}
+
+// WithQueueSize sets the number of events buffered ahead of the requests
+// (default DefaultQueueSize)
+func WithQueueSize(n int) WebhookOption {
+	return func(w *Webhook) {
+		w.queueSize = n
+	}
+}
+
+// NewWebhook creates a webhook posting to url
+func NewWebhook(url string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		url:        url,
+		client:     http.DefaultClient,
+		header:     make(http.Header),
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		timeout:    DefaultTimeout,
+		queueSize:  DefaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// URL returns the URL the events are posted to
+func (w *Webhook) URL() string {
+	return w.url
+}
+
+// Deliver posts an event, retrying until it is acknowledged, the retries
+// are exhausted or ctx is done
+func (w *Webhook) Deliver(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	wait := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, e.Type, body)
+		if err == nil {
+			return nil
+		}
+		var se *StatusError
+		if (errors.As(err, &se) && !se.Temporary()) || attempt >= w.retries {
+			break
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("webhook %s: %w (after %v)", w.url, ctx.Err(), err)
+		case <-timer.C:
+		}
+		wait = min(2*wait, w.maxBackoff)
+	}
+	return fmt.Errorf("webhook %s: %s event of %s: %w", w.url, e.Type, e.IMEI, err)
+}
+
+// post sends one request
+func (w *Webhook) post(ctx context.Context, typ Type, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range w.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Jimi-Event", string(typ))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
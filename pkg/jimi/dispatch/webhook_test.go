@@ -0,0 +1,152 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// hookServer is a webhook endpoint answering with a sequence of status codes
+type hookServer struct {
+	*httptest.Server
+	requests atomic.Int32
+
+	mu     sync.Mutex
+	codes  []int // answered in turn, then 200
+	events []Event
+	header http.Header
+	block  chan struct{} // blocks requests until closed, if set
+}
+
+func newHookServer(t *testing.T, codes ...int) *hookServer {
+	h := &hookServer{codes: codes}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.block != nil {
+			<-h.block
+		}
+		n := int(h.requests.Add(1))
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.header = r.Header.Clone()
+		if n <= len(h.codes) && h.codes[n-1] != http.StatusOK {
+			w.WriteHeader(h.codes[n-1])
+			return
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("Invalid body: %v", err)
+		}
+		h.events = append(h.events, e)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestWebhook_Deliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"ok", nil, false, 1},
+		{"recovers", []int{500, 429}, false, 3},
+		{"gives up", []int{503, 503, 503}, true, 3},
+		{"client error", []int{400}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHookServer(t, tt.codes...)
+			w := NewWebhook(h.URL, WithRetries(2), WithBackoff(time.Millisecond, 2*time.Millisecond), WithHeader("Authorization", "Bearer t0k"))
+
+			e := Event{Type: TypeCriticalAlarm, IMEI: testIMEI, Time: testTime, Alarm: &Alarm{Code: protocol.AlarmSOS, Name: "SOS", Critical: true}}
+			err := w.Deliver(context.Background(), e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			var se *StatusError
+			if tt.wantErr && !errors.As(err, &se) {
+				t.Errorf("Expected a StatusError, got %v", err)
+			}
+			if got := h.requests.Load(); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(h.events) != 1 || h.events[0].IMEI != testIMEI || h.events[0].Alarm.Name != "SOS" {
+				t.Errorf("Unexpected events %+v", h.events)
+			}
+			if h.header.Get("X-Jimi-Event") != "critical_alarm" || h.header.Get("Authorization") != "Bearer t0k" {
+				t.Errorf("Unexpected headers %v", h.header)
+			}
+		})
+	}
+}
+
+func TestWebhook_DeliverCanceled(t *testing.T) {
+	h := newHookServer(t, 500)
+	w := NewWebhook(h.URL, WithBackoff(time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Deliver(ctx, Event{Type: TypeAlarm}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline error, got %v", err)
+	}
+}
+
+func TestDispatcher_Webhook(t *testing.T) {
+	h := newHookServer(t, 500)
+	var errs []error
+	d := New(WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	r := d.AddWebhook(Filter{Types: []Type{TypeDeviceOffline}}, NewWebhook(h.URL, WithBackoff(time.Millisecond, time.Millisecond)))
+
+	d.Dispatch(OfflineEvent(testIMEI, testTime, time.Time{}, "timeout"))
+	d.Dispatch(Event{Type: TypeAlarm, IMEI: testIMEI})
+	d.Dispatch(OfflineEvent("868020041234567", testTime, time.Time{}, "timeout"))
+	d.Close()
+
+	if len(h.events) != 2 || h.events[0].IMEI != testIMEI || h.events[0].Reason != "timeout" {
+		t.Errorf("Unexpected events %+v", h.events)
+	}
+	if stats := r.Stats(); stats.Delivered != 2 || stats.Failed != 0 || len(errs) != 0 {
+		t.Errorf("Unexpected stats %+v, errors %v", stats, errs)
+	}
+}
+
+func TestDispatcher_WebhookQueueFull(t *testing.T) {
+	h := newHookServer(t)
+	h.block = make(chan struct{})
+	var dropped atomic.Int32
+	d := New(WithErrorHandler(func(err error) {
+		if errors.Is(err, ErrQueueFull) {
+			dropped.Add(1)
+		}
+	}))
+	r := d.AddWebhook(Filter{}, NewWebhook(h.URL, WithQueueSize(1)))
+
+	// The first event is held by the blocked request, one fills the queue
+	d.Dispatch(Event{Type: TypeAlarm})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	d.Dispatch(Event{Type: TypeAlarm})
+	d.Dispatch(Event{Type: TypeAlarm})
+	close(h.block)
+	d.Close()
+
+	if stats := r.Stats(); stats.Delivered != 2 || stats.Dropped != 1 || dropped.Load() != 1 {
+		t.Errorf("Unexpected stats %+v, dropped %d", stats, dropped.Load())
+	}
+}
@@ -0,0 +1,314 @@
+// Package driving scores driver behavior from the harsh-event alarms.
+//
+// Devices with an accelerometer report harsh acceleration (0x29), sharp
+// left and right cornering (0x2A, 0x2B), collisions (0x2C) and harsh braking
+// (0x30) as alarms. A Scorer keeps the events of every device over a rolling
+// window and computes a score from 100 (no harsh events) down to 0, each
+// event costing the weight of its kind.
+//
+// Example usage:
+//
+//	scorer := driving.NewScorer(
+//	    driving.WithWindow(24*time.Hour),
+//	    driving.WithHandler(func(e driving.Event) {
+//	        log.Printf("%s: %s, score %.0f", e.IMEI, e.Kind, e.Score)
+//	    }),
+//	)
+//
+//	srv.OnPacket(func(s *server.Session, p packet.Packet) {
+//	    scorer.Observe(s.IMEI(), p)
+//	})
+//	worst := scorer.Scores(time.Now())[0]
+package driving
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Scorer defaults
+const (
+	// MaxScore is the score of a device without harsh events
+	MaxScore = 100.0
+
+	// DefaultWindow is how long harsh events count against the score
+	DefaultWindow = 7 * 24 * time.Hour
+)
+
+// Kind is a kind of harsh event
+type Kind string
+
+// Harsh event kinds
+const (
+	KindAcceleration Kind = "harsh_acceleration" // 0x29
+	KindBraking      Kind = "harsh_braking"      // 0x30
+	KindCornering    Kind = "sharp_cornering"    // 0x2A, 0x2B
+	KindCollision    Kind = "collision"          // 0x2C
+)
+
+// KindOf returns the harsh event kind of an alarm type
+func KindOf(a protocol.AlarmType) (Kind, bool) {
+	switch a {
+	case protocol.AlarmHarshAcceleration:
+		return KindAcceleration, true
+	case protocol.AlarmHarshBraking:
+		return KindBraking, true
+	case protocol.AlarmSharpLeftCorner, protocol.AlarmSharpRightCorner:
+		return KindCornering, true
+	case protocol.AlarmCollision:
+		return KindCollision, true
+	}
+	return "", false
+}
+
+// Weights are the score points each harsh event kind costs. Kinds without a
+// weight do not affect the score, but are still counted.
+type Weights map[Kind]float64
+
+// DefaultWeights returns the default weights: braking costs more than
+// acceleration and cornering, and a collision most
+func DefaultWeights() Weights {
+	return Weights{
+		KindAcceleration: 2,
+		KindBraking:      3,
+		KindCornering:    2,
+		KindCollision:    20,
+	}
+}
+
+// Event is a harsh event of a device
+type Event struct {
+	IMEI  string             `json:"imei"`
+	Kind  Kind               `json:"kind"`
+	Alarm protocol.AlarmType `json:"alarm"`
+
+	// Time is the device time of the alarm
+	Time time.Time `json:"time"`
+
+	// Coordinates and Speed (km/h) are those of the alarm, set when
+	// HasPosition is true
+	Coordinates types.Coordinates `json:"coordinates"`
+	Speed       uint8             `json:"speed"`
+	HasPosition bool              `json:"has_position"`
+
+	// Score is the score of the device including this event
+	Score float64 `json:"score"`
+}
+
+// String returns a human-readable representation
+func (e Event) String() string {
+	return fmt.Sprintf("DrivingEvent{IMEI: %s, Kind: %s, Time: %s, Score: %.1f}",
+		e.IMEI, e.Kind, e.Time.Format(time.RFC3339), e.Score)
+}
+
+// Score is the driver score of a device over the rolling window
+type Score struct {
+	IMEI string `json:"imei"`
+
+	// Score goes from MaxScore (no weighted events) down to 0
+	Score float64 `json:"score"`
+
+	// Counts are the harsh events of each kind in the window
+	Counts map[Kind]int `json:"counts"`
+
+	// Since is the start of the window
+	Since time.Time `json:"since"`
+
+	// Last is the time of the last harsh event in the window (zero if none)
+	Last time.Time `json:"last,omitzero"`
+}
+
+// Handler is called for every harsh event
+type Handler func(Event)
+
+// Option configures a Scorer
+type Option func(*Scorer)
+
+// WithWindow sets how long harsh events count against the score (default
+// DefaultWindow)
+func WithWindow(d time.Duration) Option {
+	return func(s *Scorer) {
+		if d > 0 {
+			s.window = d
+		}
+	}
+}
+
+// WithWeights sets the score points each kind costs (default
+// DefaultWeights)
+func WithWeights(w Weights) Option {
+	return func(s *Scorer) {
+		s.weights = maps.Clone(w)
+	}
+}
+
+// WithHandler sets the callback invoked for every harsh event
+func WithHandler(h Handler) Option {
+	return func(s *Scorer) {
+		s.handler = h
+	}
+}
+
+// record is a harsh event kept in the window
+type record struct {
+	time time.Time
+	kind Kind
+}
+
+// Scorer keeps the harsh events of every device over a rolling window and
+// scores them. It is safe for concurrent use.
+type Scorer struct {
+	window  time.Duration
+	weights Weights
+	handler Handler
+
+	mu      sync.Mutex
+	devices map[string][]record // in arrival order
+}
+
+// NewScorer creates a new driver scorer
+func NewScorer(opts ...Option) *Scorer {
+	s := &Scorer{
+		window:  DefaultWindow,
+		weights: DefaultWeights(),
+		devices: make(map[string][]record),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Observe feeds a decoded packet into the scorer and returns the harsh event
+// it carries, with the new score of the device. Other packets are ignored.
+func (s *Scorer) Observe(imei string, p packet.Packet) (Event, bool) {
+	a, ok := alarmPacket(p)
+	if imei == "" || !ok {
+		return Event{}, false
+	}
+	kind, ok := KindOf(a.AlarmType)
+	if !ok {
+		return Event{}, false
+	}
+
+	ev := Event{
+		IMEI:        imei,
+		Kind:        kind,
+		Alarm:       a.AlarmType,
+		Time:        a.DateTime.Time,
+		Coordinates: a.Coordinates,
+		Speed:       a.Speed,
+		HasPosition: a.IsPositioned() && !a.Coordinates.IsZero(),
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	s.mu.Lock()
+	s.devices[imei] = append(s.devices[imei], record{time: ev.Time, kind: kind})
+	ev.Score = s.scoreLocked(imei, latest(s.devices[imei])).Score
+	handler := s.handler
+	s.mu.Unlock()
+
+	if handler != nil {
+		handler(ev)
+	}
+	return ev, true
+}
+
+// Score returns the score of a device over the window ending at now.
+// Devices without harsh events score MaxScore.
+func (s *Scorer) Score(imei string, now time.Time) Score {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scoreLocked(imei, now)
+}
+
+// Scores returns the scores of every device with harsh events, lowest first
+func (s *Scorer) Scores(now time.Time) []Score {
+	s.mu.Lock()
+	out := make([]Score, 0, len(s.devices))
+	for imei := range s.devices {
+		out = append(out, s.scoreLocked(imei, now))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].IMEI < out[j].IMEI
+	})
+	return out
+}
+
+// Forget removes the events of a device
+func (s *Scorer) Forget(imei string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, imei)
+}
+
+// scoreLocked scores a device over the window ending at now and drops its
+// events older than the window. Caller must hold s.mu.
+func (s *Scorer) scoreLocked(imei string, now time.Time) Score {
+	since := now.Add(-s.window)
+	records := s.devices[imei]
+	kept := records[:0]
+	for _, r := range records {
+		if r.time.After(since) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.devices, imei)
+	} else if len(kept) < len(records) {
+		s.devices[imei] = kept
+	}
+
+	score := Score{IMEI: imei, Score: MaxScore, Counts: make(map[Kind]int), Since: since}
+	for _, r := range kept {
+		if r.time.After(now) {
+			continue
+		}
+		score.Counts[r.kind]++
+		score.Score -= s.weights[r.kind]
+		if r.time.After(score.Last) {
+			score.Last = r.time
+		}
+	}
+	score.Score = max(score.Score, 0)
+	return score
+}
+
+// latest returns the latest time of records
+func latest(records []record) time.Time {
+	var t time.Time
+	for _, r := range records {
+		if r.time.After(t) {
+			t = r.time
+		}
+	}
+	return t
+}
+
+// alarmPacket returns the alarm of p
+func alarmPacket(p packet.Packet) (*packet.AlarmPacket, bool) {
+	switch v := p.(type) {
+	case *packet.AlarmPacket:
+		return v, true
+	case *packet.AlarmMultiFencePacket:
+		return &v.AlarmPacket, true
+	case *packet.Alarm4GPacket:
+		return &v.AlarmPacket, true
+	default:
+		return nil, false
+	}
+}
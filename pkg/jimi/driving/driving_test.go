@@ -0,0 +1,103 @@
+package driving
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const (
+	testIMEI  = "359339073930523"
+	otherIMEI = "868020041234567"
+)
+
+var baseTime = time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+
+// alarm builds a positioned alarm hours after baseTime
+func alarm(hours int, alarmType protocol.AlarmType) *packet.AlarmPacket {
+	c := types.MustNewCoordinates(-12.0464, -77.0428)
+	p := packet.NewAlarmPacket(types.NewDateTime(baseTime.Add(time.Duration(hours)*time.Hour)), c, alarmType)
+	p.CourseStatus = types.NewCourseStatus(0, true, true, c.IsEast, c.IsNorth)
+	return p
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		alarm protocol.AlarmType
+		want  Kind
+		ok    bool
+	}{
+		{protocol.AlarmHarshAcceleration, KindAcceleration, true},
+		{protocol.AlarmHarshBraking, KindBraking, true},
+		{protocol.AlarmSharpLeftCorner, KindCornering, true},
+		{protocol.AlarmSharpRightCorner, KindCornering, true},
+		{protocol.AlarmCollision, KindCollision, true},
+		{protocol.AlarmSOS, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alarm.String(), func(t *testing.T) {
+			if got, ok := KindOf(tt.alarm); got != tt.want || ok != tt.ok {
+				t.Errorf("KindOf() = %q, %v", got, ok)
+			}
+		})
+	}
+}
+
+func TestScorer_Observe(t *testing.T) {
+	var handled []Event
+	scorer := NewScorer(WithWindow(24*time.Hour), WithHandler(func(e Event) { handled = append(handled, e) }))
+
+	steps := []struct {
+		packet    packet.Packet
+		wantScore float64
+		wantEvent bool
+	}{
+		{alarm(0, protocol.AlarmHarshBraking), 97, true},
+		{alarm(1, protocol.AlarmSharpLeftCorner), 95, true},
+		{alarm(2, protocol.AlarmSOS), 0, false},
+		{&packet.HeartbeatPacket{}, 0, false},
+		{alarm(3, protocol.AlarmHarshAcceleration), 93, true},
+		{alarm(26, protocol.AlarmHarshBraking), 95, true}, // the first two left the window
+	}
+	for i, step := range steps {
+		ev, ok := scorer.Observe(testIMEI, step.packet)
+		if ok != step.wantEvent || (ok && ev.Score != step.wantScore) {
+			t.Errorf("step %d: got %v %v, want score %.0f", i, ev, ok, step.wantScore)
+		}
+	}
+	if len(handled) != 4 || handled[1].Kind != KindCornering || !handled[1].HasPosition {
+		t.Errorf("Unexpected events %v", handled)
+	}
+
+	s := scorer.Score(testIMEI, baseTime.Add(26*time.Hour))
+	if s.Score != 95 || s.Counts[KindAcceleration] != 1 || s.Counts[KindBraking] != 1 || !s.Last.Equal(baseTime.Add(26*time.Hour)) {
+		t.Errorf("Unexpected score %+v", s)
+	}
+	if s := scorer.Score(testIMEI, baseTime.Add(60*time.Hour)); s.Score != MaxScore || len(s.Counts) != 0 {
+		t.Errorf("Expected a clean score after the window, got %+v", s)
+	}
+}
+
+func TestScorer_Scores(t *testing.T) {
+	scorer := NewScorer(WithWeights(Weights{KindCollision: 150, KindBraking: 10}))
+	scorer.Observe(testIMEI, alarm(0, protocol.AlarmHarshBraking))
+	scorer.Observe(testIMEI, alarm(1, protocol.AlarmHarshAcceleration)) // no weight
+	scorer.Observe(otherIMEI, alarm(1, protocol.AlarmCollision))
+	scorer.Observe("", alarm(1, protocol.AlarmCollision))
+
+	scores := scorer.Scores(baseTime.Add(2 * time.Hour))
+	if len(scores) != 2 || scores[0].IMEI != otherIMEI || scores[0].Score != 0 || scores[1].Score != 90 {
+		t.Fatalf("Unexpected scores %+v", scores)
+	}
+	if scores[1].Counts[KindAcceleration] != 1 {
+		t.Errorf("Expected unweighted events to be counted, got %v", scores[1].Counts)
+	}
+
+	scorer.Forget(otherIMEI)
+	if s := scorer.Score(otherIMEI, baseTime.Add(2*time.Hour)); s.Score != MaxScore {
+		t.Errorf("Expected a forgotten device to score %v, got %v", MaxScore, s.Score)
+	}
+}
@@ -31,6 +31,9 @@ type AddressResponseParams struct {
 	// - LanguageChinese (0x01) → Protocol 0x17 (short packet, UNICODE)
 	// - LanguageEnglish (0x02) → Protocol 0x97 (long packet, ASCII/UTF-8)
 	Language protocol.Language
+
+	// CRC computes the packet checksum; nil uses the protocol default
+	CRC validator.CRC
 }
 
 // ChineseAddressResponse creates a Chinese address response packet (0x17)
@@ -125,7 +128,7 @@ func ChineseAddressResponse(params AddressResponseParams) ([]byte, error) {
 
 	// Calculate CRC for: Length + Protocol + Content + Serial
 	crcData := packet.Bytes()[2:] // Skip start bit
-	crc := validator.CalculateCRC(crcData)
+	crc := checksum(params.CRC, crcData)
 
 	// CRC (2 bytes, big-endian)
 	packet.WriteByte(byte(crc >> 8))
@@ -225,7 +228,7 @@ func EnglishAddressResponse(params AddressResponseParams) ([]byte, error) {
 
 	// Calculate CRC for: Length + Protocol + Content + Serial
 	crcData := packet.Bytes()[2:] // Skip start bit
-	crc := validator.CalculateCRC(crcData)
+	crc := checksum(params.CRC, crcData)
 
 	// CRC (2 bytes, big-endian)
 	packet.WriteByte(byte(crc >> 8))
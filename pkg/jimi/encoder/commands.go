@@ -33,16 +33,22 @@ const (
 	// Alarm Configuration
 	CmdAlarmOn  = "ALARM,1#" // Enable alarms
 	CmdAlarmOff = "ALARM,0#" // Disable alarms
+
+	// Defense (armed) mode
+	CmdDefenseOn  = "DEFENSE,1#" // Arm the device
+	CmdDefenseOff = "DEFENSE,0#" // Disarm the device
 )
 
 // CommandBuilder helps construct device commands with parameters
 type CommandBuilder struct {
 	encoder    *Encoder
 	serialNum  uint16
+	serials    *SerialCounter
 	serverFlag uint32
 }
 
-// NewCommandBuilder creates a new command builder
+// NewCommandBuilder creates a new command builder.
+// Every command gets serialNum; see NewSerialCommandBuilder for sessions.
 func (e *Encoder) NewCommandBuilder(serialNum uint16, serverFlag uint32) *CommandBuilder {
 	return &CommandBuilder{
 		encoder:    e,
@@ -51,9 +57,23 @@ func (e *Encoder) NewCommandBuilder(serialNum uint16, serverFlag uint32) *Comman
 	}
 }
 
+// NewSerialCommandBuilder creates a command builder that takes the serial
+// number of each command from serials
+func (e *Encoder) NewSerialCommandBuilder(serials *SerialCounter, serverFlag uint32) *CommandBuilder {
+	return &CommandBuilder{
+		encoder:    e,
+		serials:    serials,
+		serverFlag: serverFlag,
+	}
+}
+
 // Send sends a raw command string
 func (cb *CommandBuilder) Send(command string) []byte {
-	return cb.encoder.OnlineCommand(cb.serialNum, cb.serverFlag, command)
+	serialNum := cb.serialNum
+	if cb.serials != nil {
+		serialNum = cb.serials.Next()
+	}
+	return cb.encoder.OnlineCommand(serialNum, cb.serverFlag, command)
 }
 
 // GetIMEI requests the device IMEI
@@ -0,0 +1,338 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/internal/codec"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Device-originated packets.
+//
+// These builders produce the packets a terminal sends to the server, using
+// the same packet types the decoder returns, so a decoded packet can be
+// encoded again and a device simulator can be built on top of the library.
+// The serial number is taken from the packet's BasePacket.
+//
+// Example usage:
+//
+//	login := packet.NewLoginPacket(types.MustNewIMEI("359339073930520"), 0x4D01, tz)
+//	login.SerialNum = 1
+//	data, err := encoder.New().Login(login)
+
+// gpsInfoLength is the GPS information length reported in the high nibble
+// of the GPS info byte (date-time excluded)
+const gpsInfoLength = 0x0C
+
+// maxNeighborCells is the number of neighbor cells in an LBS packet
+const maxNeighborCells = 6
+
+// Login creates a login packet (Protocol 0x01)
+func (e *Encoder) Login(p *packet.LoginPacket) ([]byte, error) {
+	if p.GetIMEI() == "" {
+		return nil, fmt.Errorf("login: invalid IMEI")
+	}
+
+	// Devices send the 15 IMEI digits with a leading zero
+	imei, err := bcd(p.GetIMEI(), 8)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	content := make([]byte, 0, 12)
+	content = append(content, imei...)
+	content = append(content, byte(p.ModelID>>8), byte(p.ModelID))
+	content = append(content, p.Timezone.ToBytes()...)
+
+	return e.buildPacket(protocol.ProtocolLogin, content, p.SerialNum), nil
+}
+
+// Heartbeat creates a heartbeat packet (Protocol 0x13)
+// ExtendedInfo is only included when HasExtended is set
+func (e *Encoder) Heartbeat(p *packet.HeartbeatPacket) []byte {
+	content := []byte{
+		p.TerminalInfo.Raw(),
+		byte(p.VoltageLevel),
+		byte(p.GSMSignal),
+	}
+	if p.HasExtended {
+		content = append(content, byte(p.ExtendedInfo>>8), byte(p.ExtendedInfo))
+	}
+
+	return e.buildPacket(protocol.ProtocolHeartbeat, content, p.SerialNum)
+}
+
+// Location creates a GPS location packet (Protocol 0x22)
+func (e *Encoder) Location(p *packet.LocationPacket) []byte {
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, speedByte(p.Speed), p.CourseStatus)
+	content = append(content, p.LBSInfo.Bytes2G()...)
+	content = appendLocationStatus(content, p)
+	if p.HasStatusTail {
+		// The tail follows the mileage, sent even when zero
+		if p.Mileage == 0 {
+			content = append(content, 0, 0, 0, 0)
+		}
+		content = append(content, byte(p.VoltageLevel), byte(p.GSMSignal))
+	}
+
+	return e.buildPacket(protocol.ProtocolGPSLocation, content, p.SerialNum)
+}
+
+// Location4G creates a 4G GPS location packet (Protocol 0xA0)
+func (e *Encoder) Location4G(p *packet.Location4GPacket) []byte {
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, speedByte(p.Speed), p.CourseStatus)
+	content = append(content, bytes4G(p.LBSInfo)...)
+	content = appendLocationStatus(content, &p.LocationPacket)
+
+	return e.buildPacket(protocol.ProtocolGPSLocation4G, content, p.SerialNum)
+}
+
+// Alarm creates an alarm packet (Protocol 0x26)
+func (e *Encoder) Alarm(p *packet.AlarmPacket) []byte {
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, p.Speed, p.CourseStatus)
+
+	// LBS length counts itself
+	lbs := p.LBSInfo.Bytes2G()
+	content = append(content, byte(len(lbs)+1))
+	content = append(content, lbs...)
+
+	content = appendAlarmStatus(content, p)
+	content = appendMileage(content, p.Mileage)
+
+	return e.buildPacket(protocol.ProtocolAlarm, content, p.SerialNum)
+}
+
+// AlarmMultiFence creates a multi-fence alarm packet (Protocol 0x27)
+func (e *Encoder) AlarmMultiFence(p *packet.AlarmMultiFencePacket) []byte {
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, p.Speed, p.CourseStatus)
+
+	// LBS length counts itself
+	lbs := p.LBSInfo.Bytes2G()
+	content = append(content, byte(len(lbs)+1))
+	content = append(content, lbs...)
+
+	content = appendAlarmStatus(content, &p.AlarmPacket)
+	content = append(content, p.FenceID)
+	content = appendMileage(content, p.Mileage)
+
+	return e.buildPacket(protocol.ProtocolAlarmMultiFence, content, p.SerialNum)
+}
+
+// Alarm4G creates a 4G alarm packet (Protocol 0xA4)
+func (e *Encoder) Alarm4G(p *packet.Alarm4GPacket) []byte {
+	content := appendGPS(nil, p.DateTime, p.Satellites, p.Coordinates, p.Speed, p.CourseStatus)
+
+	// LBS length counts itself
+	lbs := bytes4G(p.LBSInfo)
+	content = append(content, byte(len(lbs)+1))
+	content = append(content, lbs...)
+
+	content = appendAlarmStatus(content, &p.AlarmPacket)
+	content = append(content, p.FenceID)
+	content = appendMileage(content, p.Mileage)
+
+	return e.buildPacket(protocol.ProtocolAlarmMultiFence4G, content, p.SerialNum)
+}
+
+// LBS creates a multi-base-station LBS packet (Protocol 0x28)
+// Up to 6 neighbor cells are encoded; they share the MCC and MNC of the main cell.
+// Signal strengths are not part of LBSInfo and are sent as zero.
+func (e *Encoder) LBS(p *packet.LBSPacket) []byte {
+	content := make([]byte, 0, 6+9+maxNeighborCells*6+3)
+	content = append(content, p.DateTime.ToBytes()...)
+
+	// Main cell: MCC(2) + MNC(1) + LAC(2) + CellID(3) + RSSI(1)
+	content = append(content, p.LBSInfo.Bytes2G()...)
+	content = append(content, 0x00)
+
+	// Neighbor cells: LAC(2) + CellID(3) + RSSI(1)
+	for i, cell := range p.NeighborCells {
+		if i == maxNeighborCells {
+			break
+		}
+		content = append(content, cell.Bytes2G()[3:]...)
+		content = append(content, 0x00)
+	}
+
+	content = append(content, p.TimingAdvance)
+	content = append(content, 0x00, byte(p.Language))
+
+	return e.buildPacket(protocol.ProtocolLBSMultiBase, content, p.SerialNum)
+}
+
+// WiFiInfo creates a WiFi information packet (Protocol 0x2C, or 0xA2 when
+// ProtocolNum says so). The 6 neighbor cell slots are always written, unused
+// ones as zero; cell signal strengths are not part of LBSInfo and are sent as zero.
+func (e *Encoder) WiFiInfo(p *packet.WiFiInfoPacket) ([]byte, error) {
+	if len(p.AccessPoints) > 0xFF {
+		return nil, fmt.Errorf("wifi: %d access points (max 255)", len(p.AccessPoints))
+	}
+
+	content := append([]byte(nil), p.DateTime.ToBytes()...)
+	if p.Is4G() {
+		content = append(content, bytes4G(p.LBSInfo)...)
+	} else {
+		content = append(content, p.LBSInfo.Bytes2G()...)
+	}
+	content = append(content, 0x00)
+
+	for i := range maxNeighborCells {
+		var cell types.LBSInfo
+		if i < len(p.NeighborCells) {
+			cell = p.NeighborCells[i]
+		}
+		if p.Is4G() {
+			// LAC and CellID without MCC and MNC
+			content = append(content, cell.Bytes4G(false)[3:]...)
+		} else {
+			content = append(content, cell.Bytes2G()[3:]...)
+		}
+		content = append(content, 0x00)
+	}
+
+	content = append(content, p.TimingAdvance, byte(len(p.AccessPoints)))
+	for _, ap := range p.AccessPoints {
+		content = append(content, ap.Bytes()...)
+	}
+
+	protocolNum := byte(protocol.ProtocolWiFi)
+	if p.Is4G() {
+		protocolNum = protocol.ProtocolWiFi4G
+	}
+	return e.buildPacket(protocolNum, content, p.SerialNum), nil
+}
+
+// CommandResponse creates a device response to an online command
+// (Protocol 0x21, or 0x15 when ProtocolNum says so)
+func (e *Encoder) CommandResponse(p *packet.CommandResponsePacket) []byte {
+	proto := byte(protocol.ProtocolCommandResponse)
+	if p.ProtocolNum == protocol.ProtocolCommandResponseOld {
+		proto = protocol.ProtocolCommandResponseOld
+	}
+
+	// Length counts the server flag and the response
+	resp := []byte(p.Response)
+	content := make([]byte, 0, 5+len(resp))
+	content = append(content, byte(4+len(resp)))
+	content = append(content,
+		byte(p.ServerFlag>>24),
+		byte(p.ServerFlag>>16),
+		byte(p.ServerFlag>>8),
+		byte(p.ServerFlag),
+	)
+	content = append(content, resp...)
+
+	return e.buildPacket(proto, content, p.SerialNum)
+}
+
+// InfoTransfer creates an information transfer packet (Protocol 0x94)
+// Data is sent as-is when set. Otherwise it is built from the parsed fields
+// of the external voltage and ICCID sub-protocols.
+func (e *Encoder) InfoTransfer(p *packet.InfoTransferPacket) ([]byte, error) {
+	data := p.Data
+	if data == nil {
+		var err error
+		data, err = infoData(p)
+		if err != nil {
+			return nil, fmt.Errorf("info_transfer: %w", err)
+		}
+	}
+
+	content := make([]byte, 0, 1+len(data))
+	content = append(content, byte(p.SubProtocol))
+	content = append(content, data...)
+
+	return e.buildPacket(protocol.ProtocolInfoTransfer, content, p.SerialNum), nil
+}
+
+// infoData builds the data of an info transfer packet from its parsed fields
+func infoData(p *packet.InfoTransferPacket) ([]byte, error) {
+	switch p.SubProtocol {
+	case protocol.InfoTypeExternalVoltage:
+		return []byte{byte(p.ExternalVoltage >> 8), byte(p.ExternalVoltage)}, nil
+
+	case protocol.InfoTypeICCID:
+		imei, err := bcd(p.IMEI, 8)
+		if err != nil {
+			return nil, fmt.Errorf("IMEI: %w", err)
+		}
+		imsi, err := bcd(p.IMSI, 8)
+		if err != nil {
+			return nil, fmt.Errorf("IMSI: %w", err)
+		}
+		iccid, err := bcd(p.ICCID, 10)
+		if err != nil {
+			return nil, fmt.Errorf("ICCID: %w", err)
+		}
+		data := append(imei, imsi...)
+		return append(data, iccid...), nil
+	}
+	return nil, nil
+}
+
+// bcd encodes digits into size BCD bytes, left-padded with zeros
+func bcd(digits string, size int) ([]byte, error) {
+	if len(digits) > size*2 {
+		return nil, fmt.Errorf("%d digits do not fit in %d bytes", len(digits), size)
+	}
+	return codec.EncodeBCD(strings.Repeat("0", size*2-len(digits)) + digits)
+}
+
+// appendGPS appends date-time, GPS info, coordinates, speed and course/status.
+// The hemisphere bits of the course/status are taken from the coordinates.
+func appendGPS(content []byte, dt types.DateTime, satellites uint8, coords types.Coordinates, speed uint8, course types.CourseStatus) []byte {
+	course.IsNorthLatitude = coords.IsNorth
+	course.IsEastLongitude = coords.IsEast
+
+	content = append(content, dt.ToBytes()...)
+	content = append(content, gpsInfoLength<<4|satellites&0x0F)
+	content = append(content, coords.LatitudeBytes()...)
+	content = append(content, coords.LongitudeBytes()...)
+	content = append(content, speed)
+	return append(content, course.Bytes()...)
+}
+
+// appendLocationStatus appends ACC, upload mode, re-upload flag and mileage
+func appendLocationStatus(content []byte, p *packet.LocationPacket) []byte {
+	var acc, reupload byte
+	if p.ACC {
+		acc = 0x01
+	}
+	if p.IsReupload {
+		reupload = 0x01
+	}
+	content = append(content, acc, byte(p.UploadMode), reupload)
+	return appendMileage(content, p.Mileage)
+}
+
+// appendAlarmStatus appends terminal info, voltage, GSM signal, alarm type and language
+func appendAlarmStatus(content []byte, p *packet.AlarmPacket) []byte {
+	return append(content,
+		p.TerminalInfo.Raw(),
+		byte(p.VoltageLevel),
+		byte(p.GSMSignal),
+		byte(p.AlarmType),
+		byte(p.Language),
+	)
+}
+
+// speedByte returns the one-byte km/h speed of the protocol, capped at 255
+func speedByte(kmh uint16) uint8 {
+	return uint8(min(kmh, 0xFF))
+}
+
+// appendMileage appends the optional mileage (omitted when zero)
+func appendMileage(content []byte, mileage uint32) []byte {
+	if mileage == 0 {
+		return content
+	}
+	return append(content, byte(mileage>>24), byte(mileage>>16), byte(mileage>>8), byte(mileage))
+}
+
+// bytes4G encodes 4G LBS info, using a 2-byte MNC only when needed
+func bytes4G(lbs types.LBSInfo) []byte {
+	return lbs.Bytes4G(lbs.MNC > 0xFF)
+}
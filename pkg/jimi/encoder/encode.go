@@ -0,0 +1,59 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// ErrUnsupportedPacket is returned by Encode for packet types without an encoder
+var ErrUnsupportedPacket = errors.New("encoder: unsupported packet type")
+
+// Encode encodes a packet with the builder of its type, the inverse of
+// jimi.Decoder.Decode, e.g. to re-encode decoded and modified packets in a
+// proxy or a protocol translation gateway. The serial number is kept and
+// RawData is ignored. ACC status reports are encoded as the alarm that
+// delivered them (ACCStatusPacket.Alarm). Packet types without a builder
+// (GPS/LBS status, 4G LBS, time calibration, address packets) return
+// ErrUnsupportedPacket.
+//
+// Example usage:
+//
+//	pkt, err := decoder.Decode(data)
+//	...
+//	out, err := encoder.New().Encode(pkt)
+func (e *Encoder) Encode(p packet.Packet) ([]byte, error) {
+	switch p := p.(type) {
+	case *packet.LoginPacket:
+		return e.Login(p)
+	case *packet.HeartbeatPacket:
+		return e.Heartbeat(p), nil
+	case *packet.LocationPacket:
+		return e.Location(p), nil
+	case *packet.Location4GPacket:
+		return e.Location4G(p), nil
+	case *packet.AlarmPacket:
+		return e.Alarm(p), nil
+	case *packet.AlarmMultiFencePacket:
+		return e.AlarmMultiFence(p), nil
+	case *packet.Alarm4GPacket:
+		return e.Alarm4G(p), nil
+	case *packet.ACCStatusPacket:
+		if p.Alarm == nil {
+			return e.Alarm(&p.AlarmPacket), nil
+		}
+		return e.Encode(p.Alarm)
+	case *packet.LBSPacket:
+		return e.LBS(p), nil
+	case *packet.WiFiInfoPacket:
+		return e.WiFiInfo(p)
+	case *packet.InfoTransferPacket:
+		return e.InfoTransfer(p)
+	case *packet.CommandResponsePacket:
+		return e.CommandResponse(p), nil
+	case *packet.OnlineCommandPacket:
+		return e.OnlineCommand(p.SerialNum, p.ServerFlag, p.Command), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedPacket, p)
+}
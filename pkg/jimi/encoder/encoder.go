@@ -2,6 +2,8 @@
 //
 // The encoder creates properly formatted response packets that can be sent back
 // to GPS tracking devices. Each response type has its own builder for convenience.
+// It also builds device-originated packets (see Login, Location, Alarm) for
+// round-trip tests and device simulators.
 //
 // Example usage:
 //
@@ -19,6 +21,7 @@ import (
 
 	"github.com/fcode09/jimi-vl103m/internal/validator"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 // Encoder creates response packets for the VL103M protocol
@@ -26,13 +29,41 @@ type Encoder struct {
 	// UseShortFormat uses 0x7878 format (default)
 	// Set to false to use 0x7979 format for long packets
 	UseShortFormat bool
+
+	// CRC computes packet checksums; nil uses validator.CRCX25, the protocol
+	// default (see jimi.WithCRCVariant for devices using another variant)
+	CRC validator.CRC
+
+	// TimezoneOffset is the offset in minutes from UTC of the time sent by
+	// TimeCalibrationResponse. The protocol sends UTC (0), which devices
+	// shift by their configured GMT offset; set it only for devices that set
+	// their clock to the time received as is (see WithTimezone).
+	TimezoneOffset int
+}
+
+// Option configures an Encoder
+type Option func(*Encoder)
+
+// WithTimezone sets the offset in minutes from UTC of the time sent by
+// TimeCalibrationResponse (e.g. -300 for UTC-5). Use it for devices that
+// keep their clock in local time without applying their GMT offset to the
+// time received, and keep the default UTC for the others: they would shift
+// a local time a second time.
+func WithTimezone(offsetMinutes int) Option {
+	return func(e *Encoder) {
+		e.TimezoneOffset = offsetMinutes
+	}
 }
 
 // New creates a new Encoder with default settings
-func New() *Encoder {
-	return &Encoder{
+func New(opts ...Option) *Encoder {
+	e := &Encoder{
 		UseShortFormat: true,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // buildPacket creates a complete packet with start bit, length, content, CRC, and stop bit
@@ -71,7 +102,7 @@ func (e *Encoder) buildPacket(protocolNum byte, content []byte, serialNum uint16
 		crcStart = 2 // After start bit
 	}
 	crcData := packet[crcStart:]
-	crc := validator.CalculateCRC(crcData)
+	crc := checksum(e.CRC, crcData)
 	packet = append(packet, byte(crc>>8), byte(crc&0xFF))
 
 	// Add stop bit
@@ -80,6 +111,14 @@ func (e *Encoder) buildPacket(protocolNum byte, content []byte, serialNum uint16
 	return packet
 }
 
+// checksum computes the CRC of data with crc, or the protocol default if nil
+func checksum(crc validator.CRC, data []byte) uint16 {
+	if crc == nil {
+		return validator.CalculateCRC(data)
+	}
+	return crc.Checksum(data)
+}
+
 // LoginResponse creates a response to a login packet
 // The device expects this response to confirm successful login
 func (e *Encoder) LoginResponse(serialNum uint16) []byte {
@@ -110,11 +149,29 @@ func (e *Encoder) Alarm4GResponse(serialNum uint16) []byte {
 }
 
 // TimeCalibrationResponse creates a response with current server time
-// The device uses this to synchronize its internal clock
+// The device uses this to synchronize its internal clock. The time is sent
+// in UTC unless the encoder has a TimezoneOffset.
 func (e *Encoder) TimeCalibrationResponse(serialNum uint16, t time.Time) []byte {
 	// Time response content: YY MM DD HH MM SS (6 bytes)
-	t = t.UTC()
-	content := []byte{
+	content := wallClock(t, e.TimezoneOffset)
+	return e.buildPacket(protocol.ProtocolTimeCalibration, content, serialNum)
+}
+
+// TimeCalibrationResponseWithTimezone creates the extended time response:
+// the wall clock of t in tz followed by the 2-byte timezone/language field
+// of the login packet, for firmware that sets both its clock and its GMT
+// offset from it. The protocol has no DST flag: pass the offset in effect
+// at t (e.g. from t.In(loc).Zone()).
+func (e *Encoder) TimeCalibrationResponseWithTimezone(serialNum uint16, t time.Time, tz types.Timezone) []byte {
+	// Content: YY MM DD HH MM SS (6 bytes) + timezone/language (2 bytes)
+	content := append(wallClock(t, tz.OffsetMinutes), tz.ToBytes()...)
+	return e.buildPacket(protocol.ProtocolTimeCalibration, content, serialNum)
+}
+
+// wallClock returns the YY MM DD HH MM SS bytes of t at offsetMinutes from UTC
+func wallClock(t time.Time, offsetMinutes int) []byte {
+	t = t.UTC().Add(time.Duration(offsetMinutes) * time.Minute)
+	return []byte{
 		byte(t.Year() - 2000),
 		byte(t.Month()),
 		byte(t.Day()),
@@ -122,7 +179,6 @@ func (e *Encoder) TimeCalibrationResponse(serialNum uint16, t time.Time) []byte
 		byte(t.Minute()),
 		byte(t.Second()),
 	}
-	return e.buildPacket(protocol.ProtocolTimeCalibration, content, serialNum)
 }
 
 // TimeCalibrationResponseNow creates a time response with current time
@@ -1,11 +1,15 @@
 package encoder
 
 import (
+	"bytes"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/fcode09/jimi-vl103m/internal/validator"
 	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 func TestNew(t *testing.T) {
@@ -129,6 +133,34 @@ func TestTimeCalibrationResponse(t *testing.T) {
 	}
 }
 
+func TestTimeCalibrationResponse_Timezone(t *testing.T) {
+	testTime := time.Date(2024, 6, 15, 2, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name string
+		resp []byte
+		want string
+	}{
+		{"UTC", New().TimeCalibrationResponse(0x0001, testTime.In(time.FixedZone("", 3600))), "18060F021E2D"},
+		{"UTC-5", New(WithTimezone(-300)).TimeCalibrationResponse(0x0001, testTime), "18060E151E2D"},
+		{"UTC+8 with timezone", New().TimeCalibrationResponseWithTimezone(0x0001, testTime, types.Timezone{OffsetMinutes: 480, Language: 0x02}), "18060F0A1E2D3202"},
+		{"UTC-3:30 with timezone", New().TimeCalibrationResponseWithTimezone(0x0001, testTime, types.Timezone{OffsetMinutes: -210}), "18060E17002D14A8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.resp[4 : len(tt.resp)-6]
+			if got := fmt.Sprintf("%X", content); got != tt.want {
+				t.Errorf("Expected content %s, got %s", tt.want, got)
+			}
+			if int(tt.resp[2]) != len(tt.resp)-5 {
+				t.Errorf("Length %d does not match packet of %d bytes", tt.resp[2], len(tt.resp))
+			}
+			if !validator.ValidateCRC(tt.resp) {
+				t.Error("CRC validation failed")
+			}
+		})
+	}
+}
+
 func TestOnlineCommand(t *testing.T) {
 	enc := New()
 	serialNum := uint16(0x0001)
@@ -245,6 +277,77 @@ func TestCommandBuilder(t *testing.T) {
 	}
 }
 
+func TestSerialCounter(t *testing.T) {
+	var c SerialCounter
+	if c.Last() != 0 {
+		t.Errorf("Expected last 0 before the first serial, got %d", c.Last())
+	}
+	for want := uint16(1); want <= 3; want++ {
+		if got := c.Next(); got != want {
+			t.Errorf("Expected serial %d, got %d", want, got)
+		}
+	}
+	if c.Last() != 3 {
+		t.Errorf("Expected last 3, got %d", c.Last())
+	}
+
+	c.Set(0xFFFF)
+	if got := c.Next(); got != 0xFFFF {
+		t.Errorf("Expected serial 0xFFFF, got 0x%04X", got)
+	}
+	if got := c.Next(); got != 0 {
+		t.Errorf("Expected wrap to 0, got %d", got)
+	}
+
+	if got := NewSerialCounter(100).Next(); got != 100 {
+		t.Errorf("Expected serial 100, got %d", got)
+	}
+}
+
+func TestSerialCounter_Concurrent(t *testing.T) {
+	var c SerialCounter
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Next()
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Last() != 800 {
+		t.Errorf("Expected last 800, got %d", c.Last())
+	}
+}
+
+func TestSerialCommandBuilder(t *testing.T) {
+	enc := New()
+	serials := NewSerialCounter(7)
+	cb := enc.NewSerialCommandBuilder(serials, 0x12345678)
+
+	tests := []struct {
+		command []byte
+		want    []byte
+	}{
+		{cb.GetStatus(), enc.OnlineCommand(7, 0x12345678, CmdGetStatus)},
+		{cb.GetVersion(), enc.OnlineCommand(8, 0x12345678, CmdGetVersion)},
+		{cb.Send("WHERE#"), enc.OnlineCommand(9, 0x12345678, "WHERE#")},
+	}
+	for i, tt := range tests {
+		if !bytes.Equal(tt.command, tt.want) {
+			t.Errorf("Command %d: expected %X, got %X", i, tt.want, tt.command)
+		}
+	}
+
+	// Builders sharing a counter share the sequence
+	other := enc.NewSerialCommandBuilder(serials, 0x00000001)
+	if got, want := other.GetIMEI(), enc.OnlineCommand(10, 0x00000001, CmdGetIMEI); !bytes.Equal(got, want) {
+		t.Errorf("Expected %X, got %X", want, got)
+	}
+}
+
 // Benchmarks
 
 func BenchmarkLoginResponse(b *testing.B) {
@@ -0,0 +1,42 @@
+package encoder
+
+import "sync/atomic"
+
+// SerialCounter hands out the serial numbers of the packets a server sends
+// to one device. Devices that validate sequence ordering reject repeated
+// serial numbers, so keep one counter per session.
+//
+// The zero value is ready to use: the first serial number is 1, and the
+// counter wraps from 0xFFFF to 0. It is safe for concurrent use.
+//
+// Example usage:
+//
+//	var serials encoder.SerialCounter
+//	cb := enc.NewSerialCommandBuilder(&serials, 0x00000001)
+//	conn.Write(cb.GetStatus())  // serial 1
+//	conn.Write(cb.GetVersion()) // serial 2
+type SerialCounter struct {
+	n atomic.Uint32
+}
+
+// NewSerialCounter creates a counter whose next serial number is next
+func NewSerialCounter(next uint16) *SerialCounter {
+	c := &SerialCounter{}
+	c.Set(next)
+	return c
+}
+
+// Next returns the next serial number
+func (c *SerialCounter) Next() uint16 {
+	return uint16(c.n.Add(1))
+}
+
+// Last returns the last serial number handed out (0 before the first)
+func (c *SerialCounter) Last() uint16 {
+	return uint16(c.n.Load())
+}
+
+// Set makes next the next serial number
+func (c *SerialCounter) Set(next uint16) {
+	c.n.Store(uint32(next - 1))
+}
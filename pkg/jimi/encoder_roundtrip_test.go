@@ -0,0 +1,207 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// encodeDevicePacket encodes p with Encoder.Encode, false if its type has
+// no encoder
+func encodeDevicePacket(t *testing.T, enc *encoder.Encoder, p packet.Packet) ([]byte, bool) {
+	t.Helper()
+
+	data, err := enc.Encode(p)
+	if errors.Is(err, encoder.ErrUnsupportedPacket) {
+		return nil, false
+	}
+	if err != nil {
+		t.Fatalf("Encode %T failed: %v", p, err)
+	}
+	return data, true
+}
+
+// withoutRaw clears the fields that differ between two decodes of equal packets
+func withoutRaw(p packet.Packet) packet.Packet {
+	v := reflect.ValueOf(p).Elem()
+	for _, name := range []string{"RawData", "ParsedAt"} {
+		f := v.FieldByName(name)
+		f.Set(reflect.Zero(f.Type()))
+	}
+	return p
+}
+
+func TestEncoder_DeviceRoundTrip(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithLenientMode())
+	strict := NewDecoder(WithoutIMEIValidation())
+	enc := encoder.New()
+
+	tested := 0
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tp.Hex)
+			pkt, err := decoder.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			encoded, ok := encodeDevicePacket(t, enc, pkt)
+			if !ok {
+				t.Skipf("%T has no device encoder", pkt)
+			}
+			tested++
+
+			// Encoded packets carry a valid CRC (sample IMEIs may fail the checksum)
+			again, err := strict.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode of %X failed: %v", encoded, err)
+			}
+
+			// The command samples' length bytes count more bytes than they
+			// carry; the encoder always writes the actual length
+			switch r := pkt.(type) {
+			case *packet.CommandResponsePacket:
+				r.ResponseLength = byte(4 + len(r.Response))
+			case *packet.OnlineCommandPacket:
+				r.CommandLength = byte(4 + len(r.Command))
+			}
+
+			if !reflect.DeepEqual(withoutRaw(pkt), withoutRaw(again)) {
+				t.Errorf("Round trip mismatch\nfirst:  %+v\nsecond: %+v", pkt, again)
+			}
+		})
+	}
+	if tested == 0 {
+		t.Fatal("No packets were round-tripped")
+	}
+}
+
+func TestEncoder_DeviceExactBytes(t *testing.T) {
+	tests := []string{
+		"787811010359339073930520044D01E00001EB830D0A", // login
+		"787808132404020001870D0D0A",                   // heartbeat
+	}
+
+	decoder := NewDecoder()
+	enc := encoder.New()
+	for _, want := range tests {
+		data, _ := hex.DecodeString(want)
+		pkt, err := decoder.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode %s failed: %v", want, err)
+		}
+		got, _ := encodeDevicePacket(t, enc, pkt)
+		if h := strings.ToUpper(hex.EncodeToString(got)); h != want {
+			t.Errorf("Expected %s, got %s", want, h)
+		}
+	}
+}
+
+func TestEncoder_DeviceLocation(t *testing.T) {
+	dt := types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC))
+	coords := types.MustNewCoordinates(-33.868820, -151.209296)
+	course := types.NewCourseStatus(270, true, true, true, true)
+
+	loc := packet.NewLocationPacket(dt, coords, 60, course)
+	loc.SerialNum = 42
+	loc.Satellites = 9
+	loc.ACC = true
+	loc.Mileage = 123456
+	loc.LBSInfo = types.NewLBSInfo(505, 1, 0x1234, 0xABCDEF)
+
+	pkt, err := NewDecoder().Decode(encoder.New().Location(loc))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	got, ok := pkt.(*packet.LocationPacket)
+	if !ok {
+		t.Fatalf("Expected *packet.LocationPacket, got %T", pkt)
+	}
+
+	if got.SerialNum != 42 || got.Satellites != 9 || got.Speed != 60 || !got.ACC || got.Mileage != 123456 {
+		t.Errorf("Unexpected packet %+v", got)
+	}
+	if got.ProtocolNum != protocol.ProtocolGPSLocation {
+		t.Errorf("Expected protocol 0x22, got 0x%02X", got.ProtocolNum)
+	}
+	if !got.DateTime.Time.Equal(dt.Time) {
+		t.Errorf("Expected time %s, got %s", dt, got.DateTime)
+	}
+	// Hemispheres come from the coordinates, not the course flags
+	if lat := got.Coordinates.SignedLatitude(); lat > -33.8688 || lat < -33.8689 {
+		t.Errorf("Expected latitude -33.86882, got %f", lat)
+	}
+	if lon := got.Coordinates.SignedLongitude(); lon > -151.2092 || lon < -151.2093 {
+		t.Errorf("Expected longitude -151.209296, got %f", lon)
+	}
+	if got.CourseStatus.Course != 270 || got.LBSInfo != loc.LBSInfo {
+		t.Errorf("Unexpected course %v / LBS %v", got.CourseStatus, got.LBSInfo)
+	}
+
+	// The status tail follows the mileage, also when it is zero
+	loc.Mileage = 0
+	loc.HasStatusTail, loc.VoltageLevel, loc.GSMSignal = true, protocol.VoltageHigh, protocol.SignalWeak
+	pkt, err = NewDecoder().Decode(encoder.New().Location(loc))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	got = pkt.(*packet.LocationPacket)
+	if !got.HasStatusTail || got.VoltageLevel != protocol.VoltageHigh || got.GSMSignal != protocol.SignalWeak || got.Mileage != 0 {
+		t.Errorf("Unexpected status tail %+v", got)
+	}
+}
+
+func TestEncoder_DeviceLoginInvalidIMEI(t *testing.T) {
+	if _, err := encoder.New().Login(&packet.LoginPacket{}); err == nil {
+		t.Error("Expected error for missing IMEI")
+	}
+}
+
+func TestEncoder_DeviceInfoTransferFields(t *testing.T) {
+	info := packet.NewInfoTransferPacket(protocol.InfoTypeExternalVoltage, nil)
+	info.ExternalVoltage = 1183
+
+	data, err := encoder.New().InfoTransfer(info)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	pkt, err := NewDecoder().Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got := pkt.(*packet.InfoTransferPacket).ExternalVoltage; got != 1183 {
+		t.Errorf("Expected external voltage 1183, got %d", got)
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	enc := encoder.New()
+
+	// ACC status reports are encoded as the alarm that delivered them
+	data, _ := hex.DecodeString(packets.AlarmPackets[0].Hex)
+	alarm, err := NewDecoder(WithSkipCRC(), WithLenientMode()).Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := &packet.ACCStatusPacket{AlarmPacket: *alarm.(*packet.AlarmPacket), Alarm: alarm}
+	got, err := enc.Encode(acc)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if want := enc.Alarm(alarm.(*packet.AlarmPacket)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %X, got %X", want, got)
+	}
+
+	if _, err := enc.Encode(packet.NewTimeCalibrationPacket()); !errors.Is(err, encoder.ErrUnsupportedPacket) {
+		t.Errorf("Expected ErrUnsupportedPacket, got %v", err)
+	}
+}
@@ -3,6 +3,10 @@ package jimi
 import (
 	"errors"
 	"fmt"
+
+	"github.com/fcode09/jimi-vl103m/internal/parser"
+	"github.com/fcode09/jimi-vl103m/internal/splitter"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
 )
 
 // Common errors returned by the decoder
@@ -22,8 +26,9 @@ var (
 	// ErrUnsupportedProtocol indicates the protocol number is not supported
 	ErrUnsupportedProtocol = errors.New("unsupported protocol number")
 
-	// ErrInvalidPacketLength indicates the packet length field is inconsistent
-	ErrInvalidPacketLength = errors.New("invalid packet length: length mismatch")
+	// ErrInvalidPacketLength indicates the packet length field is inconsistent.
+	// PacketLengthError wraps it.
+	ErrInvalidPacketLength = parser.ErrLengthMismatch
 
 	// ErrInsufficientData indicates not enough data to parse the packet
 	ErrInsufficientData = errors.New("insufficient data: incomplete packet")
@@ -33,6 +38,17 @@ var (
 
 	// ErrBufferOverflow indicates the packet exceeds maximum size
 	ErrBufferOverflow = errors.New("buffer overflow: packet too large")
+
+	// ErrInvalidHex indicates a hex-encoded input could not be decoded
+	ErrInvalidHex = errors.New("invalid hex input")
+
+	// ErrResyncLimit indicates a stream split discarded more bytes than
+	// ResyncPolicy.MaxDiscardBytes
+	ErrResyncLimit = splitter.ErrDiscardLimit
+
+	// ErrZeroDateTime indicates an all-zero date-time from a device whose
+	// clock is not set (see WithAcceptZeroTime)
+	ErrZeroDateTime = types.ErrZeroDateTime
 )
 
 // DecodeError represents a packet decoding error with additional context
@@ -131,6 +147,36 @@ func NewProtocolError(protocol byte, message string) *ProtocolError {
 	}
 }
 
+// PanicError is returned when a parser panics on a malformed packet.
+// It carries the protocol number, the parser name, the panic value and the
+// packet hex; the decoder never lets the panic escape.
+type PanicError = parser.PanicError
+
+// ParseTimeoutError is returned when a parser exceeds the WithParseTimeout
+// deadline. The parser goroutine is abandoned and its result discarded.
+type ParseTimeoutError = parser.TimeoutError
+
+// FieldLimitError is returned when a variable-length field exceeds one of the
+// MaxStringLength / MaxInfoDataLength limits
+type FieldLimitError = parser.LimitError
+
+// PacketLengthError is returned by the parsers when the declared length of a
+// packet does not match its size. The decoder validates the length before
+// parsing; parsers reject such packets too, so they never read fields at the
+// wrong offsets (with WithSkipStructureValidation or a custom splitter).
+type PacketLengthError = parser.LengthError
+
+// DeviationError is returned in strict mode when a field deviates from the
+// protocol, e.g. an ACC byte other than 0x00/0x01 or unexpected trailing
+// bytes. With WithStrictMode(false) the parsers decode past such fields.
+type DeviationError = parser.DeviationError
+
+// StreamLimitError is returned when a declared packet length or the stream
+// residue exceeds WithMaxDeclaredLength / WithMaxResidueSize. The offending
+// bytes are discarded up to the next start bit; packets around them are
+// still returned.
+type StreamLimitError = splitter.LimitError
+
 // Helper functions for error checking
 
 // IsInvalidCRC returns true if the error is a CRC error
@@ -174,3 +220,49 @@ func IsDecodeError(err error) bool {
 	var decErr *DecodeError
 	return errors.As(err, &decErr)
 }
+
+// IsPanicError returns true if the error comes from a recovered parser panic
+func IsPanicError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var panicErr *PanicError
+	return errors.As(err, &panicErr)
+}
+
+// IsParseTimeout returns true if the error comes from a parser exceeding its deadline
+func IsParseTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var timeoutErr *ParseTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// IsStreamLimitError returns true if the error comes from a stream exceeding
+// a declared length or residue limit
+func IsStreamLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var limitErr *StreamLimitError
+	return errors.As(err, &limitErr)
+}
+
+// IsFieldLimitError returns true if the error comes from a field exceeding a decoder limit
+func IsFieldLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var limitErr *FieldLimitError
+	return errors.As(err, &limitErr)
+}
+
+// IsDeviationError returns true if the error comes from a field deviating from the protocol in strict mode
+func IsDeviationError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var devErr *DeviationError
+	return errors.As(err, &devErr)
+}
@@ -0,0 +1,561 @@
+package jimi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Field is one byte range of a packet and its meaning (see Decoder.Explain)
+type Field struct {
+	// Name is the field name of the protocol specification
+	// (e.g. "Latitude", "Information Serial Number")
+	Name string
+
+	// Offset is the position of the first byte in the packet, start bit
+	// included
+	Offset int
+
+	// Raw holds the bytes of the field
+	Raw []byte
+
+	// Value is the decoded value ("" for reserved and opaque bytes)
+	Value string
+}
+
+// End returns the offset of the byte after the field
+func (f Field) End() int {
+	return f.Offset + len(f.Raw)
+}
+
+// Explanation is the byte-level layout of a packet (see Decoder.Explain)
+type Explanation struct {
+	// Protocol is the protocol number of the packet
+	Protocol byte
+
+	// Packet is the decoded packet, nil if decoding failed
+	Packet packet.Packet
+
+	// Fields covers the packet from its start bit to its stop bit, in order
+	Fields []Field
+}
+
+// String returns the fields as an annotated hex dump, one per line
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, f := range e.Fields {
+		span := strconv.Itoa(f.Offset)
+		if len(f.Raw) > 1 {
+			span += "-" + strconv.Itoa(f.End()-1)
+		}
+		fmt.Fprintf(&b, "%-7s %-24X %s", span, f.Raw, f.Name)
+		if f.Value != "" {
+			fmt.Fprintf(&b, ": %s", f.Value)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Explain decodes a packet and describes each of its fields: name, byte
+// range, raw bytes and decoded value, for hex dumps with annotations in
+// tools and bug reports.
+//
+// When the packet does not decode, the fields that can be read are still
+// described and the decode error is returned with them, so a damaged packet
+// can be inspected; only empty data returns a nil Explanation. Values are
+// read from the raw bytes with the decoder's options (speed format,
+// satellite nibble, CRC variant), so they show what the device sent, e.g.
+// the date-time before any timezone conversion.
+//
+// Example usage:
+//
+//	exp, err := decoder.Explain(data)
+//	if err != nil {
+//	    log.Printf("Decode failed: %v", err)
+//	}
+//	if exp != nil {
+//	    fmt.Print(exp)
+//	}
+func (d *Decoder) Explain(data []byte) (*Explanation, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidPacketSize
+	}
+	pkt, err := d.Decode(data)
+
+	e := &explainer{data: data}
+	lengthSize := 0
+	switch {
+	case len(data) < 2:
+	case data[0] == 0x78 && data[1] == 0x78:
+		lengthSize = protocol.LengthFieldSizeShort
+	case data[0] == 0x79 && data[1] == 0x79:
+		lengthSize = protocol.LengthFieldSizeLong
+	}
+	if lengthSize == 0 {
+		e.end = len(data)
+		e.take("Unknown", len(data), nil)
+		return &Explanation{Fields: e.fields}, err
+	}
+
+	e.end = len(data)
+	e.take("Start Bit", 2, func(b []byte) string { return fmt.Sprintf("%d-byte length", lengthSize) })
+	e.take("Packet Length", lengthSize, func(b []byte) string {
+		declared := int(beUint(b))
+		if want := 2 + lengthSize + declared + 2; want != len(data) {
+			return fmt.Sprintf("%d bytes (packet is %d bytes, expected %d)", declared, len(data), want)
+		}
+		return fmt.Sprintf("%d bytes", declared)
+	})
+
+	// The trailer is read from the end, so a wrong length does not hide it
+	proto := 2 + lengthSize
+	trailer := len(data) - 6
+	if trailer <= proto {
+		e.take("Truncated", len(data)-e.pos, nil)
+		return &Explanation{Fields: e.fields}, err
+	}
+	num := data[proto]
+	e.take("Protocol Number", 1, func(b []byte) string {
+		return fmt.Sprintf("%s (0x%02X)", protocol.Number(num), num)
+	})
+
+	opts := d.optionsFor(num)
+	e.end, e.opts = trailer, opts
+	if explain, ok := contentExplainers[num]; ok {
+		explain(e, num)
+	}
+	e.rest("Information Content", nil)
+
+	e.end = len(data)
+	e.take("Information Serial Number", 2, fmtUint)
+	e.take("Error Check", 2, func(b []byte) string {
+		if calculated := opts.crc().Checksum(data[2 : len(data)-4]); calculated != uint16(beUint(b)) {
+			return fmt.Sprintf("invalid, expected %04X", calculated)
+		}
+		return "valid"
+	})
+	e.take("Stop Bit", 2, func(b []byte) string {
+		if b[0] != 0x0D || b[1] != 0x0A {
+			return "invalid, expected 0D0A"
+		}
+		return "valid"
+	})
+	return &Explanation{Protocol: num, Packet: pkt, Fields: e.fields}, err
+}
+
+// explainer reads the fields of a packet in order
+type explainer struct {
+	data   []byte
+	opts   *Options
+	pos    int // offset of the next field
+	end    int // offset after the last byte the fields may take
+	fields []Field
+}
+
+// take adds the field of the next n bytes, with a value formatted by format
+// (nil for none). A field cut short by the end is added as truncated, and
+// take reports whether it was complete.
+func (e *explainer) take(name string, n int, format func([]byte) string) bool {
+	if n <= 0 || e.pos >= e.end {
+		return false
+	}
+	if e.pos+n > e.end {
+		e.fields = append(e.fields, Field{Name: name + " (truncated)", Offset: e.pos, Raw: e.data[e.pos:e.end]})
+		e.pos = e.end
+		return false
+	}
+	raw := e.data[e.pos : e.pos+n]
+	f := Field{Name: name, Offset: e.pos, Raw: raw}
+	if format != nil {
+		f.Value = format(raw)
+	}
+	e.fields = append(e.fields, f)
+	e.pos += n
+	return true
+}
+
+// rest adds the remaining bytes as one field, if any
+func (e *explainer) rest(name string, format func([]byte) string) {
+	e.take(name, e.remaining(), format)
+}
+
+// remaining returns the number of bytes left for the fields
+func (e *explainer) remaining() int {
+	return max(e.end-e.pos, 0)
+}
+
+// contentExplainers describes the content of the packets of each protocol
+var contentExplainers = map[byte]func(e *explainer, num byte){
+	protocol.ProtocolLogin:                  explainLogin,
+	protocol.ProtocolHeartbeat:              explainHeartbeat,
+	protocol.ProtocolGPSLocation:            explainLocation,
+	protocol.ProtocolGPSLocation4G:          explainLocation,
+	protocol.ProtocolAlarm:                  explainAlarm,
+	protocol.ProtocolAlarmMultiFence:        explainAlarm,
+	protocol.ProtocolAlarmMultiFence4G:      explainAlarm,
+	protocol.ProtocolGPSLBSStatus:           explainAlarm,
+	protocol.ProtocolGPSLBSStatus4G:         explainAlarm,
+	protocol.ProtocolGPSLBSStatus4GAlt:      explainAlarm,
+	protocol.ProtocolLBSMultiBase:           explainLBS,
+	protocol.ProtocolLBSMultiBase4G:         explainLBS4G,
+	protocol.ProtocolWiFi:                   explainWiFi,
+	protocol.ProtocolWiFi4G:                 explainWiFi,
+	protocol.ProtocolGPSAddressRequest:      explainAddressRequest,
+	protocol.ProtocolOnlineCommand:          explainCommand,
+	protocol.ProtocolCommandResponse:        explainCommand,
+	protocol.ProtocolCommandResponseOld:     explainCommand,
+	protocol.ProtocolInfoTransfer:           explainInfoTransfer,
+	protocol.ProtocolAddressResponseChinese: explainAddress,
+	protocol.ProtocolAddressResponseEnglish: explainAddress,
+}
+
+// explainLogin describes a login packet (0x01)
+func explainLogin(e *explainer, _ byte) {
+	e.take("Terminal ID", 8, func(b []byte) string {
+		return strings.TrimPrefix(fmt.Sprintf("%X", b), "0")
+	})
+	e.take("Model Identification Code", 2, func(b []byte) string { return fmt.Sprintf("0x%04X", beUint(b)) })
+	e.take("Time Zone Language", 2, func(b []byte) string {
+		tz, err := types.TimezoneFromBytes(b)
+		if err != nil {
+			return ""
+		}
+		return tz.String()
+	})
+	e.rest("Trailing Bytes", nil)
+}
+
+// explainHeartbeat describes a heartbeat packet (0x13)
+func explainHeartbeat(e *explainer, _ byte) {
+	e.explainStatus()
+	if e.remaining() >= 2 {
+		e.take("Extended Information", 2, fmtHex)
+	}
+	e.rest("Trailing Bytes", nil)
+}
+
+// explainLocation describes a GPS location packet (0x22, 0xA0)
+func explainLocation(e *explainer, num byte) {
+	e.explainGPS(e.opts.SpeedFormat)
+	if num == protocol.ProtocolGPSLocation4G {
+		e.explainLBS4G()
+	} else {
+		e.explainLBS2G()
+	}
+	e.take("ACC", 1, fmtACC)
+	e.take("Data Upload Mode", 1, func(b []byte) string { return protocol.UploadMode(b[0]).String() })
+	e.take("GPS Real-Time Re-Upload", 1, func(b []byte) string {
+		if b[0] == 0x01 {
+			return "re-upload"
+		}
+		return "real-time"
+	})
+	if e.remaining() >= 4 {
+		e.take("Mileage Statistics", 4, fmtUint)
+	}
+	if num == protocol.ProtocolGPSLocation && e.remaining() >= 2 {
+		e.take("Voltage Level", 1, fmtVoltage)
+		e.take("GSM Signal Strength", 1, fmtGSM)
+	}
+}
+
+// explainAlarm describes an alarm (0x26, 0x27, 0xA4) or GPS LBS status
+// (0x16, 0x32, 0x33) packet
+func explainAlarm(e *explainer, num byte) {
+	e.explainGPS(SpeedKmh)
+	lbsLength := 0
+	if !e.take("LBS Length", 1, func(b []byte) string { return fmt.Sprintf("%d bytes", b[0]) }) {
+		return
+	}
+	lbsLength = int(e.data[e.pos-1])
+
+	switch num {
+	case protocol.ProtocolAlarmMultiFence4G, protocol.ProtocolGPSLBSStatus4G, protocol.ProtocolGPSLBSStatus4GAlt:
+		// The 4G length includes the length byte itself
+		if lbsLength > 1 {
+			end := e.end
+			e.end = min(e.pos+lbsLength-1, end)
+			e.explainLBS4G()
+			e.rest("LBS Padding", nil)
+			e.end = end
+		}
+	default:
+		if lbsLength > 0 || num == protocol.ProtocolGPSLBSStatus {
+			e.explainLBS2G()
+		}
+	}
+
+	e.explainStatus()
+	e.take("Alarm Type", 1, func(b []byte) string { return protocol.AlarmType(b[0]).String() })
+	e.take("Language", 1, func(b []byte) string { return protocol.Language(b[0]).String() })
+	if num == protocol.ProtocolAlarmMultiFence || num == protocol.ProtocolAlarmMultiFence4G {
+		e.take("Fence ID", 1, fmtUint)
+	}
+	if e.remaining() >= 4 {
+		e.take("Mileage Statistics", 4, fmtUint)
+	}
+}
+
+// explainLBS describes an LBS multi-base packet (0x28)
+func explainLBS(e *explainer, _ byte) {
+	e.take("Date Time", 6, fmtDateTime)
+	e.explainLBS2G()
+	e.take("RSSI", 1, fmtUint)
+	for i := 1; i <= 6 && e.remaining() >= 6; i++ {
+		e.take(fmt.Sprintf("Neighbor %d LAC", i), 2, fmtUint)
+		e.take(fmt.Sprintf("Neighbor %d Cell ID", i), 3, fmtUint)
+		e.take(fmt.Sprintf("Neighbor %d RSSI", i), 1, fmtUint)
+	}
+	e.take("Timing Advance", 1, fmtUint)
+	e.take("Language", 2, fmtHex)
+}
+
+// explainLBS4G describes a 4G LBS multi-base packet (0xA1): the main cell,
+// and the status in the last 4 bytes
+func explainLBS4G(e *explainer, _ byte) {
+	e.take("Date Time", 6, fmtDateTime)
+	status := e.end
+	if e.remaining() >= 4 {
+		status = e.end - 4
+	}
+	if status-e.pos >= 15 {
+		end := e.end
+		e.end = status
+		e.explainLBS4G()
+		e.rest("Neighbor Cells", nil)
+		e.end = end
+	}
+	if e.remaining() >= 4 {
+		e.take("Terminal Information", 1, fmtTerminal)
+		e.take("Voltage Level", 1, fmtVoltage)
+		e.take("GSM Signal Strength", 1, fmtGSM)
+		e.take("Data Upload Mode", 1, func(b []byte) string { return protocol.UploadMode(b[0]).String() })
+	}
+}
+
+// explainWiFi describes a WiFi packet (0x2C, 0xA2)
+func explainWiFi(e *explainer, num byte) {
+	e.take("Date Time", 6, fmtDateTime)
+	lacSize, cellSize := 2, 3
+	if num == protocol.ProtocolWiFi4G {
+		lacSize, cellSize = 4, 8
+		e.explainLBS4G()
+	} else {
+		e.explainLBS2G()
+	}
+	e.take("RSSI", 1, fmtUint)
+	for i := 1; i <= 6; i++ {
+		e.take(fmt.Sprintf("Neighbor %d LAC", i), lacSize, fmtUint)
+		e.take(fmt.Sprintf("Neighbor %d Cell ID", i), cellSize, fmtUint)
+		e.take(fmt.Sprintf("Neighbor %d RSSI", i), 1, fmtUint)
+	}
+	e.take("Timing Advance", 1, fmtUint)
+	if !e.take("WiFi Count", 1, fmtUint) {
+		return
+	}
+	count := int(e.data[e.pos-1])
+	for i := 1; i <= count; i++ {
+		if e.remaining() < types.WiFiAccessPointSize {
+			e.rest(fmt.Sprintf("WiFi %d", i), nil)
+			return
+		}
+		ap, _ := types.NewWiFiAccessPointFromBytes(e.data[e.pos:])
+		e.take(fmt.Sprintf("WiFi %d MAC", i), 6, func([]byte) string { return ap.MACString() })
+		e.take(fmt.Sprintf("WiFi %d RSSI", i), 1, func([]byte) string { return fmt.Sprintf("%d dBm", ap.RSSI) })
+	}
+}
+
+// explainAddressRequest describes a GPS address request packet (0x2A)
+func explainAddressRequest(e *explainer, _ byte) {
+	e.explainGPS(SpeedKmh)
+	e.take("Phone Number", 21, fmtASCII)
+	e.take("Alarm Type", 1, func(b []byte) string { return protocol.AlarmType(b[0]).String() })
+	e.take("Language", 1, func(b []byte) string { return protocol.Language(b[0]).String() })
+}
+
+// explainCommand describes an online command (0x80) or a command response
+// (0x21, 0x15)
+func explainCommand(e *explainer, num byte) {
+	name := "Response"
+	if num == protocol.ProtocolOnlineCommand {
+		name = "Command"
+	}
+	if !e.take(name+" Length", 1, func(b []byte) string { return fmt.Sprintf("%d bytes", b[0]) }) {
+		return
+	}
+	length := int(e.data[e.pos-1])
+	e.take("Server Flag", 4, fmtHex)
+	e.take(name+" Content", min(length-4, e.remaining()), fmtASCII)
+}
+
+// explainInfoTransfer describes an information transfer packet (0x94)
+func explainInfoTransfer(e *explainer, _ byte) {
+	if !e.take("Information Type", 1, func(b []byte) string { return protocol.InfoType(b[0]).String() }) {
+		return
+	}
+	switch e.data[e.pos-1] {
+	case protocol.InfoTypeExternalVoltage:
+		e.take("External Voltage", 2, func(b []byte) string { return fmt.Sprintf("%.2f V", float64(beUint(b))/100) })
+	case protocol.InfoTypeICCID:
+		e.take("IMEI", 8, fmtBCD)
+		e.take("IMSI", 8, fmtBCD)
+		e.take("ICCID", 10, fmtBCD)
+	case protocol.InfoTypeTerminalSync:
+		e.rest("Terminal Status", fmtASCII)
+	case protocol.InfoTypeDoorStatus:
+		e.take("Door Status", 1, func(b []byte) string { return fmt.Sprintf("%08b", b[0]) })
+	}
+}
+
+// explainAddress describes an address response packet (0x17, 0x97)
+func explainAddress(e *explainer, num byte) {
+	e.take("Content Length", 1, func(b []byte) string { return fmt.Sprintf("%d bytes", b[0]) })
+	e.take("Server Flag", 4, fmtHex)
+	e.take("ALARMSMS", 8, fmtASCII)
+	e.take("Separator", 2, fmtASCII)
+
+	n := bytes.Index(e.data[e.pos:e.end], []byte("&&"))
+	if n < 0 {
+		return
+	}
+	e.take("Address Content", n, func(b []byte) string {
+		if num == protocol.ProtocolAddressResponseChinese {
+			units := make([]uint16, len(b)/2)
+			for i := range units {
+				units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			}
+			return strconv.Quote(string(utf16.Decode(units)))
+		}
+		return fmtASCII(b)
+	})
+	e.take("Separator", 2, fmtASCII)
+	e.take("Phone Number", 21, fmtASCII)
+	e.take("Separator", 2, fmtASCII)
+}
+
+// explainGPS describes the GPS block of location and alarm packets, from
+// the date-time to the course and status
+func (e *explainer) explainGPS(speed SpeedFormat) {
+	e.take("Date Time", 6, fmtDateTime)
+	e.take("GPS Information", 1, func(b []byte) string {
+		high, low := b[0]>>4, b[0]&0x0F
+		satellites := low
+		switch e.opts.SatelliteNibble {
+		case SatellitesHighNibble:
+			satellites = high
+		case SatellitesAuto:
+			if low == 12 && high != 12 {
+				satellites = high
+			}
+		}
+		return fmt.Sprintf("%d satellites", satellites)
+	})
+	e.take("Latitude", 4, fmtCoordinate)
+	e.take("Longitude", 4, fmtCoordinate)
+	e.take("Speed", speed.Size(), func(b []byte) string {
+		if speed == SpeedKnots {
+			return fmt.Sprintf("%d knots", b[0])
+		}
+		return fmt.Sprintf("%d km/h", beUint(b))
+	})
+	e.take("Course Status", 2, func(b []byte) string {
+		cs, err := types.NewCourseStatusFromBytes(b)
+		if err != nil {
+			return ""
+		}
+		return cs.String()
+	})
+}
+
+// explainLBS2G describes a 2G/3G cell: MCC(2) + MNC(1) + LAC(2) + CellID(3)
+func (e *explainer) explainLBS2G() {
+	e.take("MCC", 2, fmtUint)
+	e.take("MNC", 1, fmtUint)
+	e.take("LAC", 2, fmtUint)
+	e.take("Cell ID", 3, fmtUint)
+}
+
+// explainLBS4G describes a 4G cell: MCC(2) + MNC(1-2) + LAC(4) + CellID(8).
+// Bit 15 of the MCC marks a 2-byte MNC.
+func (e *explainer) explainLBS4G() {
+	if e.remaining() < 2 {
+		e.rest("LBS", nil)
+		return
+	}
+	mncSize := 1
+	if e.data[e.pos]&0x80 != 0 {
+		mncSize = 2
+	}
+	e.take("MCC", 2, func(b []byte) string {
+		mcc := fmt.Sprintf("%d", beUint(b)&0x7FFF)
+		if mncSize == 2 {
+			mcc += " (2-byte MNC)"
+		}
+		return mcc
+	})
+	e.take("MNC", mncSize, fmtUint)
+	e.take("LAC", 4, fmtUint)
+	e.take("Cell ID", 8, fmtUint)
+}
+
+// explainStatus describes the terminal information, voltage level and GSM
+// signal strength bytes
+func (e *explainer) explainStatus() {
+	e.take("Terminal Information", 1, fmtTerminal)
+	e.take("Voltage Level", 1, fmtVoltage)
+	e.take("GSM Signal Strength", 1, fmtGSM)
+}
+
+// beUint reads a big-endian unsigned integer of up to 8 bytes
+func beUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// Field value formats
+func fmtUint(b []byte) string    { return strconv.FormatUint(beUint(b), 10) }
+func fmtHex(b []byte) string     { return fmt.Sprintf("0x%X", b) }
+func fmtBCD(b []byte) string     { return strings.TrimRight(fmt.Sprintf("%X", b), "F") }
+func fmtASCII(b []byte) string   { return strconv.Quote(string(b)) }
+func fmtVoltage(b []byte) string { return protocol.VoltageLevel(b[0]).String() }
+func fmtGSM(b []byte) string     { return protocol.GSMSignalStrength(b[0]).String() }
+
+// fmtTerminal formats a terminal information byte
+func fmtTerminal(b []byte) string {
+	return types.NewTerminalInfo(b[0]).String()
+}
+
+// fmtDateTime formats a YY MM DD hh mm ss date-time as sent
+func fmtDateTime(b []byte) string {
+	if bytes.Count(b, []byte{0}) == len(b) {
+		return "zero (not set)"
+	}
+	return fmt.Sprintf("20%02d-%02d-%02d %02d:%02d:%02d", b[0], b[1], b[2], b[3], b[4], b[5])
+}
+
+// fmtCoordinate formats a latitude or longitude without its hemisphere,
+// which is in the course and status
+func fmtCoordinate(b []byte) string {
+	return fmt.Sprintf("%.6f°", float64(beUint(b))/1800000)
+}
+
+// fmtACC formats the ACC byte of location packets
+func fmtACC(b []byte) string {
+	switch b[0] {
+	case 0x00:
+		return "off"
+	case 0x01:
+		return "on"
+	}
+	return fmt.Sprintf("0x%02X", b[0])
+}
@@ -0,0 +1,106 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+)
+
+func TestDecoder_Explain(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithLenientMode())
+
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tp.Hex)
+			exp, err := decoder.Explain(data)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			if exp.Protocol != tp.Protocol || exp.Packet == nil {
+				t.Errorf("Expected protocol 0x%02X decoded, got 0x%02X %v", tp.Protocol, exp.Protocol, exp.Packet)
+			}
+
+			// The fields cover the packet without gaps or overlaps
+			offset := 0
+			for _, f := range exp.Fields {
+				if f.Offset != offset {
+					t.Fatalf("Field %q at %d, expected %d\n%s", f.Name, f.Offset, offset, exp)
+				}
+				offset = f.End()
+			}
+			if offset != len(data) {
+				t.Fatalf("Fields end at %d, expected %d\n%s", offset, len(data), exp)
+			}
+			if last := exp.Fields[len(exp.Fields)-1]; last.Name != "Stop Bit" || last.Value != "valid" {
+				t.Errorf("Expected the stop bit last, got %+v", last)
+			}
+		})
+	}
+}
+
+func TestDecoder_ExplainValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		hex   string
+		field string
+		value string
+	}{
+		{"login IMEI", packets.LoginPackets[0].Hex, "Terminal ID", "359339073930530"},
+		{"location latitude", packets.LocationPackets[0].Hex, "Latitude", "23.111693°"},
+		{"location speed", packets.LocationPackets[0].Hex, "Speed", "0 km/h"},
+		{"location ACC", packets.LocationPackets[1].Hex, "ACC", "on"},
+		{"4G cell", packets.LocationPackets[3].Hex, "Cell ID", "47081706"},
+		{"alarm type", packets.AlarmPackets[0].Hex, "Alarm Type", "SOS"},
+		{"heartbeat voltage", packets.HeartbeatPackets[0].Hex, "Voltage Level", ""},
+		{"protocol", packets.HeartbeatPackets[0].Hex, "Protocol Number", "heartbeat (0x13)"},
+	}
+
+	decoder := NewDecoder(WithSkipCRC(), WithLenientMode())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			exp, err := decoder.Explain(data)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			for _, f := range exp.Fields {
+				if f.Name == tt.field {
+					if tt.value != "" && f.Value != tt.value {
+						t.Errorf("Expected %s %q, got %q", tt.field, tt.value, f.Value)
+					}
+					return
+				}
+			}
+			t.Errorf("No field %s in\n%s", tt.field, exp)
+		})
+	}
+}
+
+func TestDecoder_ExplainDamaged(t *testing.T) {
+	data, _ := hex.DecodeString(packets.LoginPackets[0].Hex)
+	data[len(data)-3] ^= 0xFF // CRC
+
+	exp, err := NewDecoder().Explain(data)
+	var crcErr *CRCError
+	if !errors.As(err, &crcErr) {
+		t.Fatalf("Expected a CRC error, got %v", err)
+	}
+	if exp == nil || exp.Packet != nil {
+		t.Fatalf("Expected an explanation without packet, got %+v", exp)
+	}
+	if !strings.Contains(exp.String(), "Error Check: invalid, expected") {
+		t.Errorf("Expected the CRC flagged\n%s", exp)
+	}
+
+	// Without a start bit the bytes are unknown
+	exp, err = NewDecoder().Explain([]byte{0x01, 0x02, 0x03})
+	if err == nil || len(exp.Fields) != 1 || exp.Fields[0].Name != "Unknown" {
+		t.Errorf("Expected one unknown field and an error, got %+v, %v", exp, err)
+	}
+	if _, err := NewDecoder().Explain(nil); err == nil {
+		t.Error("Expected an error for empty data")
+	}
+}
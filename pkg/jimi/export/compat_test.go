@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+var update = flag.Bool("update", false, "Regenerate golden files in testdata/")
+
+// goldenDir holds the serialized form pinned for the current schema version
+var goldenDir = filepath.Join("testdata", "schema_v"+strconv.Itoa(SchemaVersion))
+
+// checkGolden compares v's indented JSON with testdata/schema_vN/name.json
+func checkGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join(goldenDir, name+".json")
+	if *update {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Missing golden file (run with -update for a new schema version): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Serialized form of %s changed.\n"+
+			"If this is a breaking change, increment SchemaVersion; if it is additive, run with -update.\n"+
+			"got:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// TestSchemaCompatibility pins the JSON form of every sample packet
+func TestSchemaCompatibility(t *testing.T) {
+	d := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode())
+
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			p, err := d.DecodeHex(tp.Hex)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			checkGolden(t, tp.Name, FromPacket(p))
+		})
+	}
+}
+
+// TestSchemaCompatibility_Packet pins the packet JSON of every sample packet
+func TestSchemaCompatibility_Packet(t *testing.T) {
+	d := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode())
+	parsedAt := time.Date(2024, 6, 15, 14, 30, 1, 0, time.UTC)
+
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			p, err := d.DecodeHex(tp.Hex)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			// The receive time is not part of the schema
+			reflect.ValueOf(p).Elem().FieldByName("ParsedAt").Set(reflect.ValueOf(parsedAt))
+			checkGolden(t, "packet_"+tp.Name, p)
+		})
+	}
+}
+
+// TestSchemaCompatibility_ZeroTime pins the flag of a substituted date-time
+func TestSchemaCompatibility_ZeroTime(t *testing.T) {
+	received := time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)
+	coords := types.MustNewCoordinates(-33.868820, 151.209296)
+	p := packet.NewLocationPacket(types.ReceivedDateTime(received), coords, 0, types.CourseStatus{})
+	checkGolden(t, "location_zero_time", FromPacket(p))
+}
+
+// TestSchemaCompatibility_Result pins the binding envelope
+func TestSchemaCompatibility_Result(t *testing.T) {
+	d := jimi.NewDecoder(jimi.WithSkipCRC())
+
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"result_single", packets.HeartbeatPackets[0].Hex},
+		{"result_concatenated", packets.HeartbeatPackets[0].Hex + packets.HeartbeatPackets[1].Hex + "7878"},
+		{"result_error", "zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := DecodeHex(d, tt.hex)
+			// The library version changes on every release; it is not part of the schema
+			res.Version = "x.y.z"
+			checkGolden(t, tt.name, res)
+		})
+	}
+}
+
+// TestSchemaVersionField ensures every output carries the schema version
+func TestSchemaVersionField(t *testing.T) {
+	res := DecodeHex(jimi.NewDecoder(jimi.WithSkipCRC()), packets.HeartbeatPackets[0].Hex)
+
+	var out map[string]any
+	data, _ := json.Marshal(res)
+	json.Unmarshal(data, &out)
+
+	if out["schema"] != float64(SchemaVersion) {
+		t.Errorf("Expected result schema %d, got %v", SchemaVersion, out["schema"])
+	}
+	record, _ := out["record"].(map[string]any)
+	if record["schema"] != float64(SchemaVersion) {
+		t.Errorf("Expected record schema %d, got %v", SchemaVersion, record["schema"])
+	}
+}
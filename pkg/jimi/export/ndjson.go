@@ -0,0 +1,43 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// NDJSONWriter writes records as newline-delimited JSON, one object per line.
+// It is safe for concurrent use, so one writer can be shared by all
+// connections of a server.
+//
+// Example:
+//
+//	out := export.NewNDJSONWriter(os.Stdout)
+//	out.WritePacket(imei, pkt, time.Now())
+type NDJSONWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter creates a writer that encodes records to w
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONWriter{w: w, enc: enc}
+}
+
+// Write encodes one record as a single line
+func (n *NDJSONWriter) Write(rec Record) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.enc.Encode(rec)
+}
+
+// WritePacket converts a packet with NewRecord and writes it as a single line
+func (n *NDJSONWriter) WritePacket(imei string, p packet.Packet, receivedAt time.Time) error {
+	return n.Write(NewRecord(imei, p, receivedAt))
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestNDJSONWriter_WritePacket(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONWriter(&buf)
+
+	hb := &packet.HeartbeatPacket{TerminalInfo: types.NewTerminalInfo(0x44)}
+	received := time.Date(2024, 6, 15, 14, 30, 45, 0, time.FixedZone("PET", -5*3600))
+
+	if err := w.WritePacket("359339073930520", hb, received); err != nil {
+		t.Fatalf("WritePacket failed: %v", err)
+	}
+	if err := w.WritePacket("", hb, time.Time{}); err != nil {
+		t.Fatalf("WritePacket failed: %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var first map[string]any
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatalf("Invalid JSON line: %v", err)
+	}
+	if first["imei"] != "359339073930520" {
+		t.Errorf("Expected caller IMEI, got %v", first["imei"])
+	}
+	if first["received_at"] != "2024-06-15T19:30:45Z" {
+		t.Errorf("Expected UTC receive time, got %v", first["received_at"])
+	}
+
+	var second map[string]any
+	json.Unmarshal(lines[1], &second)
+	if _, ok := second["received_at"]; ok {
+		t.Error("Expected zero receive time to be omitted")
+	}
+}
+
+func TestNDJSONWriter_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONWriter(&buf)
+	hb := &packet.HeartbeatPacket{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.WritePacket("359339073930520", hb, time.Now())
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Interleaved or invalid line %q: %v", scanner.Text(), err)
+		}
+		count++
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 lines, got %d", count)
+	}
+}
@@ -0,0 +1,412 @@
+// Package export converts decoded packets into flat, JSON-friendly records.
+//
+// A Record has a fixed envelope (type, protocol, serial, time, position) and
+// a per-packet-type Fields map with snake_case keys. It is the common output
+// format for the CLI, the WASM and C bindings, and event sinks.
+//
+// # Compatibility
+//
+// Every Record and Result carries a "schema" field set to SchemaVersion.
+// Within a schema version, existing keys keep their name, type and meaning;
+// new keys may be added, so consumers must ignore keys they do not know.
+// Renaming, removing or retyping a key requires incrementing SchemaVersion.
+// The same rules and version cover the packet JSON (packet.SchemaVersion).
+// The golden files in testdata/ pin the serialized forms and the
+// compatibility test fails on any change to it; regenerate them with
+//
+//	go test ./pkg/jimi/export -run TestSchemaCompatibility -update
+//
+// only for additive changes or together with a SchemaVersion bump.
+//
+// Example usage:
+//
+//	pkt, _ := decoder.Decode(data)
+//	rec := export.FromPacket(pkt)
+//	rec.IMEI = imei
+//	out, _ := json.Marshal(rec)
+package export
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// SchemaVersion is the version of the Record JSON layout, shared with the
+// packet JSON (packet.SchemaVersion).
+// It is incremented whenever a field is renamed, removed or changes type,
+// so consumers can route or reject records they do not understand.
+const SchemaVersion = packet.SchemaVersion
+
+// Record is the exported representation of a decoded packet
+type Record struct {
+	// Schema is the record layout version (SchemaVersion)
+	Schema int `json:"schema"`
+
+	// Type is the human-readable packet type (e.g. "GPS Location")
+	Type string `json:"type"`
+
+	// Protocol is the protocol number formatted as "0xNN"
+	Protocol string `json:"protocol"`
+
+	// Serial is the packet serial number
+	Serial uint16 `json:"serial"`
+
+	// IMEI identifies the device (set from login packets or by the caller)
+	IMEI string `json:"imei,omitempty"`
+
+	// Time is the device timestamp, if the packet carries one
+	Time *time.Time `json:"time,omitempty"`
+
+	// ZeroTime reports that the device sent an all-zero date-time (its
+	// clock was not set) and Time is the receive time instead
+	ZeroTime bool `json:"zero_time,omitempty"`
+
+	// ReceivedAt is the server receive time (set by the caller)
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+
+	// Position is the GPS position, if the packet carries one
+	Position *Position `json:"position,omitempty"`
+
+	// Fields contains the packet-type specific values
+	Fields map[string]any `json:"fields,omitempty"`
+
+	// Raw is the hex-encoded raw packet
+	Raw string `json:"raw,omitempty"`
+}
+
+// Position is an exported GPS position
+type Position struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Speed      uint16  `json:"speed"`
+	Course     uint16  `json:"course"`
+	Satellites uint8   `json:"satellites"`
+	Positioned bool    `json:"positioned"`
+}
+
+// Cell is an exported cell tower
+type Cell struct {
+	MCC    uint16 `json:"mcc"`
+	MNC    uint16 `json:"mnc"`
+	LAC    uint32 `json:"lac"`
+	CellID uint64 `json:"cell_id"`
+}
+
+// AccessPoint is an exported WiFi access point
+type AccessPoint struct {
+	MAC  string `json:"mac"`
+	RSSI int8   `json:"rssi"`
+}
+
+// FromPacket converts a decoded packet to a Record
+func FromPacket(p packet.Packet) Record {
+	rec := Record{
+		Schema:   SchemaVersion,
+		Type:     p.Type(),
+		Protocol: fmt.Sprintf("0x%02X", p.ProtocolNumber()),
+		Serial:   p.SerialNumber(),
+		Fields:   map[string]any{},
+	}
+	if raw := p.Raw(); len(raw) > 0 {
+		rec.Raw = hex.EncodeToString(raw)
+	}
+
+	switch v := p.(type) {
+	case *packet.LoginPacket:
+		rec.IMEI = v.GetIMEI()
+		rec.Fields["model_id"] = v.ModelID
+		rec.Fields["timezone_offset_minutes"] = v.Timezone.OffsetMinutes
+		rec.Fields["language"] = v.Timezone.LanguageString()
+
+	case *packet.HeartbeatPacket:
+		addTerminal(rec.Fields, v.TerminalInfo)
+		rec.Fields["voltage_level"] = v.VoltageLevel.String()
+		rec.Fields["battery_percent"] = v.VoltageLevel.Percentage()
+		rec.Fields["gsm_signal"] = v.GSMSignal.String()
+		if v.HasExtended {
+			rec.Fields["extended_info"] = v.ExtendedInfo
+		}
+
+	case *packet.LocationPacket:
+		addLocation(&rec, v)
+
+	case *packet.Location4GPacket:
+		addLocation(&rec, &v.LocationPacket)
+		rec.Fields["mcc_mnc"] = v.MCCMNC
+		if len(v.ExtendedLBS) > 0 {
+			rec.Fields["extended_cells"] = cells(v.ExtendedLBS)
+		}
+
+	case *packet.AlarmPacket:
+		addAlarm(&rec, v)
+
+	case *packet.AlarmMultiFencePacket:
+		addAlarm(&rec, &v.AlarmPacket)
+		rec.Fields["fence_id"] = v.FenceID
+
+	case *packet.Alarm4GPacket:
+		addAlarm(&rec, &v.AlarmPacket)
+		rec.Fields["fence_id"] = v.FenceID
+		rec.Fields["mcc_mnc"] = v.MCCMNC
+		if len(v.ExtendedLBS) > 0 {
+			rec.Fields["extended_cells"] = cells(v.ExtendedLBS)
+		}
+
+	case *packet.GPSLBSStatusPacket:
+		addAlarm(&rec, &v.AlarmPacket)
+		if v.Is4G() {
+			rec.Fields["mcc_mnc"] = v.MCCMNC
+		}
+
+	case *packet.ACCStatusPacket:
+		addAlarm(&rec, &v.AlarmPacket)
+		rec.Fields["acc"] = v.ACCOn()
+
+	case *packet.LBSPacket:
+		setTime(&rec, v.DateTime)
+		rec.Fields["cell"] = cell(v.LBSInfo)
+		if len(v.NeighborCells) > 0 {
+			rec.Fields["neighbor_cells"] = cells(v.NeighborCells)
+		}
+		rec.Fields["timing_advance"] = v.TimingAdvance
+		rec.Fields["language"] = v.Language.String()
+		if v.HasStatus {
+			addTerminal(rec.Fields, v.TerminalInfo)
+			rec.Fields["voltage_level"] = v.VoltageLevel.String()
+			rec.Fields["gsm_signal"] = v.GSMSignal.String()
+			rec.Fields["upload_mode"] = v.UploadMode.String()
+			rec.Fields["upload_mode_code"] = fmt.Sprintf("0x%02X", byte(v.UploadMode))
+		}
+
+	case *packet.LBS4GPacket:
+		setTime(&rec, v.DateTime)
+		rec.Fields["cell"] = cell(v.LBSInfo)
+		if len(v.NeighborCells) > 0 {
+			rec.Fields["neighbor_cells"] = cells(v.NeighborCells)
+		}
+		addTerminal(rec.Fields, v.TerminalInfo)
+		rec.Fields["voltage_level"] = v.VoltageLevel.String()
+		rec.Fields["gsm_signal"] = v.GSMSignal.String()
+		rec.Fields["upload_mode"] = v.UploadMode.String()
+		rec.Fields["upload_mode_code"] = fmt.Sprintf("0x%02X", byte(v.UploadMode))
+
+	case *packet.WiFiInfoPacket:
+		setTime(&rec, v.DateTime)
+		if v.LBSInfo.IsValid() {
+			rec.Fields["cell"] = cell(v.LBSInfo)
+		}
+		if len(v.NeighborCells) > 0 {
+			rec.Fields["neighbor_cells"] = cells(v.NeighborCells)
+		}
+		rec.Fields["timing_advance"] = v.TimingAdvance
+		rec.Fields["wifi"] = accessPoints(v.AccessPoints)
+
+	case *packet.InfoTransferPacket:
+		addInfoTransfer(rec.Fields, v)
+
+	case *packet.OnlineCommandPacket:
+		rec.Fields["server_flag"] = v.ServerFlag
+		rec.Fields["command"] = v.Command
+
+	case *packet.CommandResponsePacket:
+		rec.Fields["server_flag"] = v.ServerFlag
+		rec.Fields["response"] = v.Response
+
+	case *packet.GPSAddressRequestPacket:
+		setTime(&rec, v.DateTime)
+		rec.Position = position(v.Coordinates, uint16(v.Speed), v.CourseStatus, v.Satellites)
+		rec.Fields["phone_number"] = v.PhoneNumber
+		rec.Fields["alarm_type"] = v.AlarmType.String()
+		rec.Fields["language"] = v.Language.String()
+
+	case *packet.AddressResponsePacket:
+		rec.Fields["address"] = v.Address
+		rec.Fields["alarm_sms"] = v.AlarmSMS
+		rec.Fields["phone_number"] = v.PhoneNumber
+		rec.Fields["language"] = v.Language.String()
+	}
+
+	if len(rec.Fields) == 0 {
+		rec.Fields = nil
+	}
+	return rec
+}
+
+// NewRecord converts a packet like FromPacket and fills in what only the
+// caller knows: the session IMEI (used when the packet does not carry one)
+// and the receive time (omitted when zero)
+func NewRecord(imei string, p packet.Packet, receivedAt time.Time) Record {
+	rec := FromPacket(p)
+	if rec.IMEI == "" {
+		rec.IMEI = imei
+	}
+	if !receivedAt.IsZero() {
+		t := receivedAt.UTC()
+		rec.ReceivedAt = &t
+	}
+	return rec
+}
+
+// setTime sets the record time from a device timestamp
+func setTime(rec *Record, dt types.DateTime) {
+	if dt.IsZero() {
+		return
+	}
+	t := dt.Time.UTC()
+	rec.Time = &t
+	rec.ZeroTime = dt.ZeroTime
+}
+
+// position builds an exported position
+func position(c types.Coordinates, speed uint16, cs types.CourseStatus, sats uint8) *Position {
+	return &Position{
+		Latitude:   c.SignedLatitude(),
+		Longitude:  c.SignedLongitude(),
+		Speed:      speed,
+		Course:     cs.Course,
+		Satellites: sats,
+		Positioned: cs.IsPositioned,
+	}
+}
+
+// addLocation adds GPS location fields
+func addLocation(rec *Record, v *packet.LocationPacket) {
+	setTime(rec, v.DateTime)
+	rec.Position = position(v.Coordinates, v.Speed, v.CourseStatus, v.Satellites)
+	rec.Fields["acc"] = v.ACC
+	rec.Fields["upload_mode"] = v.UploadMode.String()
+	rec.Fields["upload_mode_code"] = fmt.Sprintf("0x%02X", byte(v.UploadMode))
+	rec.Fields["reupload"] = v.IsReupload
+	rec.Fields["mileage"] = v.Mileage
+	if v.HasStatusTail {
+		rec.Fields["voltage_level"] = v.VoltageLevel.String()
+		rec.Fields["gsm_signal"] = v.GSMSignal.String()
+	}
+	if v.LBSInfo.IsValid() {
+		rec.Fields["cell"] = cell(v.LBSInfo)
+	}
+}
+
+// addAlarm adds alarm fields
+func addAlarm(rec *Record, v *packet.AlarmPacket) {
+	setTime(rec, v.DateTime)
+	rec.Position = position(v.Coordinates, uint16(v.Speed), v.CourseStatus, v.Satellites)
+	rec.Fields["alarm_type"] = v.AlarmType.String()
+	rec.Fields["alarm_code"] = fmt.Sprintf("0x%02X", byte(v.AlarmType))
+	rec.Fields["critical"] = v.IsCritical()
+	rec.Fields["voltage_level"] = v.VoltageLevel.String()
+	rec.Fields["gsm_signal"] = v.GSMSignal.String()
+	rec.Fields["mileage"] = v.Mileage
+	addTerminal(rec.Fields, v.TerminalInfo)
+	if v.LBSInfo.IsValid() {
+		rec.Fields["cell"] = cell(v.LBSInfo)
+	}
+}
+
+// addTerminal adds TerminalInfo flags
+func addTerminal(fields map[string]any, t types.TerminalInfo) {
+	fields["terminal"] = map[string]any{
+		"raw":        fmt.Sprintf("0x%02X", t.Raw()),
+		"acc":        t.ACCOn(),
+		"charging":   t.IsCharging(),
+		"gps":        t.GPSTrackingEnabled(),
+		"armed":      t.IsArmed(),
+		"oil_cut":    t.OilElectricityDisconnected(),
+		"alarm_bits": t.AlarmTypeBits(),
+	}
+}
+
+// addInfoTransfer adds information transfer fields by sub-protocol
+func addInfoTransfer(fields map[string]any, v *packet.InfoTransferPacket) {
+	fields["sub_protocol"] = v.SubProtocol.String()
+	fields["sub_protocol_code"] = fmt.Sprintf("0x%02X", byte(v.SubProtocol))
+
+	switch {
+	case v.HasTerminalSync():
+		ts := v.TerminalSync
+		fields["terminal_sync"] = map[string]any{
+			"raw":           ts.RawString,
+			"alm1":          ts.ALM1,
+			"alm2":          ts.ALM2,
+			"alm3":          ts.ALM3,
+			"alm4":          ts.ALM4,
+			"sta1":          ts.STA1,
+			"dyd":           ts.DYD,
+			"sos_numbers":   ts.SOSNumbers,
+			"center_number": ts.CenterNumber,
+			"mode":          ts.Mode,
+			"imsi":          ts.IMSI,
+			"iccid":         ts.ICCID,
+			"geofences":     geofences(ts.Geofences),
+		}
+	case v.HasDoorStatus():
+		fields["door_open"] = v.DoorStatus.DoorOpen
+		fields["trigger_high"] = v.DoorStatus.TriggerHigh
+		fields["io_port_high"] = v.DoorStatus.IOPortHigh
+	case v.HasGPSStatusInfo():
+		fields["gps_status"] = v.GPSStatusInfo.ModuleStatus.String()
+		fields["satellites_in_fix"] = v.GPSStatusInfo.SatellitesInFix
+		fields["visible_satellites"] = v.GPSStatusInfo.VisibleSatellites
+	}
+
+	if v.ExternalVoltage != 0 {
+		fields["external_voltage"] = v.GetExternalVoltageVolts()
+	}
+	if v.ICCID != "" {
+		fields["iccid"] = v.ICCID
+	}
+	if v.IMEI != "" {
+		fields["imei"] = v.IMEI
+	}
+	if v.IMSI != "" {
+		fields["imsi"] = v.IMSI
+	}
+	if len(v.Data) > 0 {
+		fields["data"] = hex.EncodeToString(v.Data)
+	}
+}
+
+// geofences converts synced fence configs
+func geofences(in []packet.GeofenceConfig) []map[string]any {
+	out := make([]map[string]any, 0, len(in))
+	for _, gf := range in {
+		out = append(out, map[string]any{
+			"id":         gf.ID,
+			"enabled":    gf.Enabled,
+			"shape":      gf.Shape,
+			"latitude":   gf.Latitude,
+			"longitude":  gf.Longitude,
+			"radius":     gf.Radius,
+			"direction":  gf.Direction,
+			"alarm_type": gf.AlarmType,
+		})
+	}
+	return out
+}
+
+// cell converts LBS info
+func cell(l types.LBSInfo) Cell {
+	return Cell{MCC: l.MCC, MNC: l.MNC, LAC: l.LAC, CellID: l.CellID}
+}
+
+// cells converts a list of LBS info
+func cells(in []types.LBSInfo) []Cell {
+	out := make([]Cell, len(in))
+	for i, l := range in {
+		out[i] = cell(l)
+	}
+	return out
+}
+
+// accessPoints converts a list of WiFi access points
+func accessPoints(in []types.WiFiAccessPoint) []AccessPoint {
+	out := make([]AccessPoint, len(in))
+	for i, ap := range in {
+		out[i] = AccessPoint{MAC: ap.MACString(), RSSI: ap.RSSI}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestFromPacket_Location(t *testing.T) {
+	dt := types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 45, 0, time.UTC))
+	loc := packet.NewLocationPacket(
+		dt,
+		types.MustNewCoordinates(-12.0464, -77.0428),
+		42,
+		types.NewCourseStatus(180, true, true, false, false),
+	)
+	loc.SerialNum = 7
+	loc.ACC = true
+	loc.Mileage = 1234
+	loc.Satellites = 9
+
+	rec := FromPacket(loc)
+
+	if rec.Type != "GPS Location" || rec.Protocol != "0x22" || rec.Serial != 7 {
+		t.Errorf("Unexpected envelope: %+v", rec)
+	}
+	if rec.Time == nil || !rec.Time.Equal(dt.Time) {
+		t.Errorf("Unexpected time: %v", rec.Time)
+	}
+	if rec.Position == nil {
+		t.Fatal("Expected position")
+	}
+	if rec.Position.Latitude != -12.0464 || rec.Position.Longitude != -77.0428 {
+		t.Errorf("Unexpected position: %+v", rec.Position)
+	}
+	if rec.Position.Speed != 42 || rec.Position.Course != 180 || rec.Position.Satellites != 9 {
+		t.Errorf("Unexpected motion: %+v", rec.Position)
+	}
+	if rec.Fields["acc"] != true || rec.Fields["mileage"] != uint32(1234) {
+		t.Errorf("Unexpected fields: %v", rec.Fields)
+	}
+}
+
+func TestFromPacket_Heartbeat(t *testing.T) {
+	info := types.NewTerminalInfoBuilder().SetACCOn(true).SetCharging(true).Build()
+	hb := packet.NewHeartbeatPacket(info, protocol.VoltageHigh, protocol.SignalStrong)
+
+	rec := FromPacket(hb)
+
+	if rec.Time != nil {
+		t.Error("Expected no device time for heartbeat")
+	}
+	if rec.Position != nil {
+		t.Error("Expected no position for heartbeat")
+	}
+	terminal, ok := rec.Fields["terminal"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected terminal map, got %T", rec.Fields["terminal"])
+	}
+	if terminal["acc"] != true || terminal["charging"] != true {
+		t.Errorf("Unexpected terminal flags: %v", terminal)
+	}
+}
+
+func TestFromPacket_LoginSetsIMEI(t *testing.T) {
+	imei, err := types.NewIMEI("359339073930520")
+	if err != nil {
+		t.Fatalf("NewIMEI failed: %v", err)
+	}
+	login := &packet.LoginPacket{
+		BasePacket: packet.BasePacket{ProtocolNum: protocol.ProtocolLogin},
+		IMEI:       imei,
+		ModelID:    0x044D,
+	}
+
+	rec := FromPacket(login)
+	if rec.IMEI != "359339073930520" {
+		t.Errorf("Expected IMEI from login, got %q", rec.IMEI)
+	}
+}
+
+func TestFromPacket_UnknownProtocol(t *testing.T) {
+	rec := FromPacket(&packet.BasePacket{ProtocolNum: 0xEE, SerialNum: 3, RawData: []byte{0x78, 0x78}})
+
+	if rec.Protocol != "0xEE" || rec.Fields != nil {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+	if rec.Raw != "7878" {
+		t.Errorf("Expected raw hex 7878, got %q", rec.Raw)
+	}
+}
+
+func TestRecord_JSON(t *testing.T) {
+	alarm := packet.NewAlarmPacket(types.Now(), types.MustNewCoordinates(1, 2), protocol.AlarmSOS)
+
+	out, err := json.Marshal(FromPacket(alarm))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, key := range []string{"type", "protocol", "serial", "time", "position", "fields"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, out)
+		}
+	}
+	fields := decoded["fields"].(map[string]any)
+	if fields["alarm_type"] != "SOS" {
+		t.Errorf("Expected alarm_type SOS, got %v", fields["alarm_type"])
+	}
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+)
+
+// Result is the JSON envelope returned by the language bindings
+// (WebAssembly, C-shared library, JSON-RPC) for one hex input
+type Result struct {
+	// Schema is the envelope and record layout version (SchemaVersion)
+	Schema int `json:"schema"`
+
+	// OK is true if at least one packet was decoded
+	OK bool `json:"ok"`
+
+	// Record is set when the input contained exactly one packet
+	Record *Record `json:"record,omitempty"`
+
+	// Records is set when the input contained several concatenated packets
+	Records []Record `json:"records,omitempty"`
+
+	// Residue is the hex-encoded trailing data that did not form a complete packet
+	Residue string `json:"residue,omitempty"`
+
+	// Error describes why decoding failed (or a partial failure when OK)
+	Error string `json:"error,omitempty"`
+
+	// Version is the library version that produced the result
+	Version string `json:"version"`
+}
+
+// DecodeHex decodes a hex string that may contain one or more concatenated
+// packets and wraps the outcome in a Result
+func DecodeHex(d *jimi.Decoder, s string) Result {
+	res := Result{Schema: SchemaVersion, Version: jimi.Version}
+
+	data, err := jimi.ParseHex(s)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	packets, residue, err := d.DecodeStream(data)
+	if len(packets) == 0 {
+		if err == nil {
+			// No complete packet found: decode directly for a precise error
+			_, err = d.Decode(data)
+		}
+		if err == nil {
+			err = errors.New("no complete packet found")
+		}
+		res.Error = err.Error()
+		return res
+	}
+
+	res.OK = true
+	if len(packets) == 1 {
+		rec := FromPacket(packets[0])
+		res.Record = &rec
+	} else {
+		for _, p := range packets {
+			res.Records = append(res.Records, FromPacket(p))
+		}
+	}
+	if len(residue) > 0 {
+		res.Residue = hex.EncodeToString(residue)
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
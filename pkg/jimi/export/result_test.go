@@ -0,0 +1,55 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+)
+
+const heartbeatHex = "787808132404020001870D0D0A"
+
+func TestDecodeHex(t *testing.T) {
+	decoder := jimi.NewDecoder()
+
+	tests := []struct {
+		name        string
+		input       string
+		wantOK      bool
+		wantRecords int
+		wantResidue bool
+		wantError   bool
+	}{
+		{"single packet", heartbeatHex, true, 1, false, false},
+		{"two packets", heartbeatHex + heartbeatHex, true, 2, false, false},
+		{"packet with residue", heartbeatHex + "7878", true, 1, true, false},
+		{"invalid hex", "zz", false, 0, false, true},
+		{"no packet", "00112233445566778899", false, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := DecodeHex(decoder, tt.input)
+
+			if res.OK != tt.wantOK {
+				t.Errorf("Expected OK=%v, got %v (error: %s)", tt.wantOK, res.OK, res.Error)
+			}
+			if (res.Error != "") != tt.wantError {
+				t.Errorf("Unexpected error state: %q", res.Error)
+			}
+			if (res.Residue != "") != tt.wantResidue {
+				t.Errorf("Unexpected residue: %q", res.Residue)
+			}
+			if res.Version != jimi.Version {
+				t.Errorf("Expected version %s, got %s", jimi.Version, res.Version)
+			}
+
+			got := len(res.Records)
+			if res.Record != nil {
+				got = 1
+			}
+			if got != tt.wantRecords {
+				t.Errorf("Expected %d records, got %d", tt.wantRecords, got)
+			}
+		})
+	}
+}
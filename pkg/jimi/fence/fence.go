@@ -0,0 +1,240 @@
+// Package fence resolves geofence alarms against device fence configurations.
+//
+// Multi-fence alarm packets (0x27, 0xA4) only carry a numeric FenceID. The
+// device reports its fence table (GFENCE1..GFENCEn) in Terminal Sync info
+// transfer packets (0x94, sub-type 0x04). A Resolver keeps the latest table per
+// device together with user-supplied fence names, so alarm events can carry
+// the fence's center, radius and name instead of just an ID.
+//
+// Example usage:
+//
+//	resolver := fence.NewResolver()
+//	resolver.SetName(imei, 1, "Warehouse")
+//
+//	for _, pkt := range packets {
+//	    resolver.Observe(imei, pkt)
+//	    if ev, ok := resolver.ResolveAlarm(imei, pkt); ok {
+//	        log.Printf("%s %s fence %q", ev.IMEI, ev.Alarm.AlarmType, ev.Fence.Name)
+//	    }
+//	}
+package fence
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Fence is a resolved geofence
+type Fence struct {
+	// ID is the device fence slot (the N in GFENCEN)
+	ID int
+
+	// Name is the user-supplied name (empty if none was set)
+	Name string
+
+	// Config is the configuration reported by the device
+	Config packet.GeofenceConfig
+
+	// HasConfig indicates if Config was reported by the device
+	HasConfig bool
+}
+
+// DisplayName returns the fence name, or "GFENCE<ID>" if no name is set
+func (f Fence) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return fmt.Sprintf("GFENCE%d", f.ID)
+}
+
+// String returns a human-readable representation
+func (f Fence) String() string {
+	if !f.HasConfig {
+		return fmt.Sprintf("Fence{ID: %d, Name: %s}", f.ID, f.DisplayName())
+	}
+	return fmt.Sprintf("Fence{ID: %d, Name: %s, Center: [%.6f, %.6f], Radius: %dm, Enabled: %v}",
+		f.ID, f.DisplayName(), f.Config.Latitude, f.Config.Longitude, f.Config.Radius, f.Config.Enabled)
+}
+
+// Event is a geofence alarm enriched with the resolved fence
+type Event struct {
+	// IMEI identifies the device
+	IMEI string
+
+	// FenceID is the fence identifier reported in the alarm
+	FenceID uint8
+
+	// Fence is the resolved fence
+	Fence Fence
+
+	// Known indicates if the fence was found in the device config or name table
+	Known bool
+
+	// Alarm is the underlying alarm data
+	Alarm *packet.AlarmPacket
+}
+
+// Entered returns true for fence-enter alarms
+func (e Event) Entered() bool {
+	return e.Alarm.AlarmType == protocol.AlarmGeofenceEnter
+}
+
+// Exited returns true for fence-exit alarms
+func (e Event) Exited() bool {
+	return e.Alarm.AlarmType == protocol.AlarmGeofenceExit
+}
+
+// Time returns the alarm timestamp
+func (e Event) Time() time.Time {
+	return e.Alarm.Timestamp()
+}
+
+// String returns a human-readable representation
+func (e Event) String() string {
+	return fmt.Sprintf("FenceEvent{IMEI: %s, Alarm: %s, Fence: %s, Known: %v}",
+		e.IMEI, e.Alarm.AlarmType, e.Fence.DisplayName(), e.Known)
+}
+
+// Resolver maps fence IDs to device fence configurations and names.
+// It is safe for concurrent use.
+type Resolver struct {
+	mu      sync.RWMutex
+	configs map[string]map[int]packet.GeofenceConfig
+	names   map[string]map[int]string
+}
+
+// NewResolver creates a new fence resolver
+func NewResolver() *Resolver {
+	return &Resolver{
+		configs: make(map[string]map[int]packet.GeofenceConfig),
+		names:   make(map[string]map[int]string),
+	}
+}
+
+// Observe updates the device fence table from Terminal Sync packets.
+// Returns true if the packet carried fence configuration.
+func (r *Resolver) Observe(imei string, p packet.Packet) bool {
+	info, ok := p.(*packet.InfoTransferPacket)
+	if !ok || !info.HasTerminalSync() {
+		return false
+	}
+	r.UpdateFromSync(imei, info.TerminalSync)
+	return true
+}
+
+// UpdateFromSync replaces the device fence table with the synced GFENCE config
+func (r *Resolver) UpdateFromSync(imei string, data *packet.TerminalSyncData) {
+	if data == nil {
+		return
+	}
+
+	table := make(map[int]packet.GeofenceConfig, len(data.Geofences))
+	for _, gf := range data.Geofences {
+		table[gf.ID] = gf
+	}
+
+	r.mu.Lock()
+	r.configs[imei] = table
+	r.mu.Unlock()
+}
+
+// SetName assigns a user-supplied name to a device fence slot
+func (r *Resolver) SetName(imei string, id int, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names, ok := r.names[imei]
+	if !ok {
+		names = make(map[int]string)
+		r.names[imei] = names
+	}
+	if name == "" {
+		delete(names, id)
+		return
+	}
+	names[id] = name
+}
+
+// Resolve looks up a fence by device slot ID.
+// The second return value is false if neither a config nor a name is known.
+func (r *Resolver) Resolve(imei string, id int) (Fence, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	f := Fence{ID: id}
+	known := false
+
+	if cfg, ok := r.configs[imei][id]; ok {
+		f.Config = cfg
+		f.HasConfig = true
+		known = true
+	}
+	if name, ok := r.names[imei][id]; ok {
+		f.Name = name
+		known = true
+	}
+
+	return f, known
+}
+
+// Fences returns all known fences for a device, ordered by ID
+func (r *Resolver) Fences(imei string) []Fence {
+	r.mu.RLock()
+	ids := make(map[int]struct{})
+	for id := range r.configs[imei] {
+		ids[id] = struct{}{}
+	}
+	for id := range r.names[imei] {
+		ids[id] = struct{}{}
+	}
+	r.mu.RUnlock()
+
+	result := make([]Fence, 0, len(ids))
+	for id := range ids {
+		f, _ := r.Resolve(imei, id)
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// Forget removes the fence table and names for a device
+func (r *Resolver) Forget(imei string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.configs, imei)
+	delete(r.names, imei)
+}
+
+// ResolveAlarm enriches a multi-fence alarm with the resolved fence.
+// Returns false for packets that carry no FenceID (0x26 alarms, non-alarm packets).
+func (r *Resolver) ResolveAlarm(imei string, p packet.Packet) (Event, bool) {
+	var (
+		alarm   *packet.AlarmPacket
+		fenceID uint8
+	)
+
+	switch v := p.(type) {
+	case *packet.AlarmMultiFencePacket:
+		alarm, fenceID = &v.AlarmPacket, v.FenceID
+	case *packet.Alarm4GPacket:
+		alarm, fenceID = &v.AlarmPacket, v.FenceID
+	default:
+		return Event{}, false
+	}
+
+	f, known := r.Resolve(imei, int(fenceID))
+	return Event{
+		IMEI:    imei,
+		FenceID: fenceID,
+		Fence:   f,
+		Known:   known,
+		Alarm:   alarm,
+	}, true
+}
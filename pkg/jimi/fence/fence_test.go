@@ -0,0 +1,127 @@
+package fence
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+func syncPacket(raw string) *packet.InfoTransferPacket {
+	return &packet.InfoTransferPacket{
+		BasePacket:   packet.BasePacket{ProtocolNum: protocol.ProtocolInfoTransfer},
+		SubProtocol:  protocol.InfoTypeTerminalSync,
+		TerminalSync: packet.ParseTerminalSyncString(raw),
+	}
+}
+
+func fenceAlarm(fenceID uint8, alarmType protocol.AlarmType) *packet.AlarmMultiFencePacket {
+	return &packet.AlarmMultiFencePacket{
+		AlarmPacket: *packet.NewAlarmPacket(types.Now(), types.Coordinates{}, alarmType),
+		FenceID:     fenceID,
+	}
+}
+
+func TestResolver_ObserveTerminalSync(t *testing.T) {
+	r := NewResolver()
+
+	ok := r.Observe(testIMEI, syncPacket(
+		"ALM1=FF;GFENCE1,ON,0,-12.046400,-77.042800,300,IN,1;GFENCE2,OFF,0,0.000000,0.000000,300,IN or OUT,1"))
+	if !ok {
+		t.Fatal("Expected terminal sync to be observed")
+	}
+
+	f, known := r.Resolve(testIMEI, 1)
+	if !known || !f.HasConfig {
+		t.Fatal("Expected fence 1 to be known")
+	}
+	if f.Config.Radius != 300 || !f.Config.Enabled {
+		t.Errorf("Unexpected config: %+v", f.Config)
+	}
+	if f.DisplayName() != "GFENCE1" {
+		t.Errorf("Expected default name GFENCE1, got %s", f.DisplayName())
+	}
+
+	if _, known := r.Resolve(testIMEI, 3); known {
+		t.Error("Expected fence 3 to be unknown")
+	}
+}
+
+func TestResolver_IgnoresOtherPackets(t *testing.T) {
+	r := NewResolver()
+
+	if r.Observe(testIMEI, &packet.HeartbeatPacket{}) {
+		t.Error("Expected heartbeat to be ignored")
+	}
+}
+
+func TestResolver_ResolveAlarm(t *testing.T) {
+	r := NewResolver()
+	r.Observe(testIMEI, syncPacket("GFENCE1,ON,0,-12.046400,-77.042800,500,IN,1"))
+	r.SetName(testIMEI, 1, "Warehouse")
+
+	ev, ok := r.ResolveAlarm(testIMEI, fenceAlarm(1, protocol.AlarmGeofenceEnter))
+	if !ok {
+		t.Fatal("Expected multi-fence alarm to resolve")
+	}
+	if !ev.Known {
+		t.Error("Expected fence to be known")
+	}
+	if ev.Fence.Name != "Warehouse" {
+		t.Errorf("Expected name Warehouse, got %q", ev.Fence.Name)
+	}
+	if ev.Fence.Config.Radius != 500 {
+		t.Errorf("Expected radius 500, got %d", ev.Fence.Config.Radius)
+	}
+	if !ev.Entered() || ev.Exited() {
+		t.Error("Expected enter event")
+	}
+}
+
+func TestResolver_ResolveAlarmUnknownFence(t *testing.T) {
+	r := NewResolver()
+
+	ev, ok := r.ResolveAlarm(testIMEI, fenceAlarm(4, protocol.AlarmGeofenceExit))
+	if !ok {
+		t.Fatal("Expected multi-fence alarm to resolve")
+	}
+	if ev.Known {
+		t.Error("Expected fence to be unknown")
+	}
+	if ev.Fence.DisplayName() != "GFENCE4" {
+		t.Errorf("Expected GFENCE4, got %s", ev.Fence.DisplayName())
+	}
+}
+
+func TestResolver_ResolveAlarmIgnoresPlainAlarms(t *testing.T) {
+	r := NewResolver()
+
+	alarm := packet.NewAlarmPacket(types.Now(), types.Coordinates{}, protocol.AlarmGeofenceEnter)
+	if _, ok := r.ResolveAlarm(testIMEI, alarm); ok {
+		t.Error("Expected 0x26 alarm without FenceID to be ignored")
+	}
+}
+
+func TestResolver_FencesAndForget(t *testing.T) {
+	r := NewResolver()
+	r.Observe(testIMEI, syncPacket("GFENCE2,ON,0,1.0,2.0,100,IN,1;GFENCE1,ON,0,3.0,4.0,200,OUT,1"))
+	r.SetName(testIMEI, 5, "Depot")
+
+	fences := r.Fences(testIMEI)
+	if len(fences) != 3 {
+		t.Fatalf("Expected 3 fences, got %d", len(fences))
+	}
+	for i, want := range []int{1, 2, 5} {
+		if fences[i].ID != want {
+			t.Errorf("Expected fence[%d].ID=%d, got %d", i, want, fences[i].ID)
+		}
+	}
+
+	r.Forget(testIMEI)
+	if len(r.Fences(testIMEI)) != 0 {
+		t.Error("Expected no fences after Forget")
+	}
+}
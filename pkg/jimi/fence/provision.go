@@ -0,0 +1,397 @@
+package fence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+// Default device fence slot layout (GFENCE1..GFENCE5)
+const (
+	DefaultFirstSlot = 1
+	DefaultSlotCount = 5
+)
+
+// DefaultSlotNamespace is the store namespace of the device slot tables
+const DefaultSlotNamespace = "fence_slots"
+
+// Provisioning errors
+var (
+	ErrNoFreeSlot      = errors.New("no free fence slot")
+	ErrUnknownFence    = errors.New("fence not assigned to device")
+	ErrInvalidFence    = errors.New("invalid fence definition")
+	ErrCommandsMissing = errors.New("command builder is required")
+)
+
+// Definition is a server-tracked circular geofence
+type Definition struct {
+	// Key uniquely identifies the fence on the server side
+	Key string
+
+	// Name is the human-readable fence name
+	Name string
+
+	// Latitude and Longitude are the fence center (signed decimal degrees)
+	Latitude  float64
+	Longitude float64
+
+	// Radius in meters
+	Radius int
+
+	// Pinned fences are never evicted to make room for others
+	Pinned bool
+}
+
+// Validate checks if the definition can be provisioned
+func (d Definition) Validate() error {
+	if d.Key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidFence)
+	}
+	if d.Latitude < -90 || d.Latitude > 90 || d.Longitude < -180 || d.Longitude > 180 {
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidFence)
+	}
+	if d.Radius <= 0 {
+		return fmt.Errorf("%w: radius must be positive", ErrInvalidFence)
+	}
+	return nil
+}
+
+// Slot is a device fence slot holding a server-tracked fence
+type Slot struct {
+	// ID is the device slot number (matches the alarm FenceID)
+	ID int
+
+	// Fence is the assigned definition
+	Fence Definition
+
+	// AssignedAt is when the fence was written to the slot
+	AssignedAt time.Time
+
+	// LastUsed is when the fence was last assigned or touched by an alarm
+	LastUsed time.Time
+}
+
+// Assignment is the result of assigning or evicting a fence
+type Assignment struct {
+	// Slot is the affected device slot
+	Slot Slot
+
+	// Evicted is the fence that was removed from the slot (if any)
+	Evicted *Definition
+
+	// Command is the encoded online command to send to the device
+	Command []byte
+}
+
+// ProvisionerOption configures a Provisioner
+type ProvisionerOption func(*Provisioner)
+
+// WithSlots sets the device slot layout
+func WithSlots(first, count int) ProvisionerOption {
+	return func(p *Provisioner) {
+		if first >= 0 && count > 0 {
+			p.firstSlot = first
+			p.slotCount = count
+		}
+	}
+}
+
+// WithoutEviction makes Assign fail with ErrNoFreeSlot instead of evicting
+func WithoutEviction() ProvisionerOption {
+	return func(p *Provisioner) {
+		p.noEviction = true
+	}
+}
+
+// WithResolver keeps the resolver's fence names in sync with slot assignments
+func WithResolver(r *Resolver) ProvisionerOption {
+	return func(p *Provisioner) {
+		p.resolver = r
+	}
+}
+
+// WithSlotStore writes the slot table of each device through to ds, so the
+// fences written to the devices are still known after a restart. Tables are
+// loaded on the first use of each device, restoring the resolver names.
+//
+// Example usage:
+//
+//	slots := store.NewDeviceStore(kv, fence.DefaultSlotNamespace)
+//	p := fence.NewProvisioner(fence.WithSlotStore(slots), fence.WithResolver(resolver))
+func WithSlotStore(ds store.DeviceStore) ProvisionerOption {
+	return func(p *Provisioner) {
+		p.store = ds
+	}
+}
+
+// WithProvisionerClock sets the time source used for slot bookkeeping
+func WithProvisionerClock(now func() time.Time) ProvisionerOption {
+	return func(p *Provisioner) {
+		if now != nil {
+			p.now = now
+		}
+	}
+}
+
+// Provisioner manages the limited fence slots of each device.
+// When all slots are in use, the least recently used unpinned fence is evicted.
+// It is safe for concurrent use.
+type Provisioner struct {
+	mu         sync.Mutex
+	firstSlot  int
+	slotCount  int
+	noEviction bool
+	resolver   *Resolver
+	store      store.DeviceStore
+	now        func() time.Time
+	devices    map[string]map[int]*Slot
+}
+
+// NewProvisioner creates a new fence slot provisioner
+func NewProvisioner(opts ...ProvisionerOption) *Provisioner {
+	p := &Provisioner{
+		firstSlot: DefaultFirstSlot,
+		slotCount: DefaultSlotCount,
+		now:       time.Now,
+		devices:   make(map[string]map[int]*Slot),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Capacity returns the number of fence slots per device
+func (p *Provisioner) Capacity() int {
+	return p.slotCount
+}
+
+// Assign writes a fence to a device slot and returns the SetGeofence command.
+// Re-assigning an already provisioned key updates its slot in place.
+func (p *Provisioner) Assign(imei string, def Definition, cb *encoder.CommandBuilder) (Assignment, error) {
+	if cb == nil {
+		return Assignment{}, ErrCommandsMissing
+	}
+	if err := def.Validate(); err != nil {
+		return Assignment{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, err := p.slots(imei)
+	if err != nil {
+		return Assignment{}, err
+	}
+	now := p.now()
+
+	id, found := p.slotForLocked(slots, def.Key)
+	if !found {
+		var err error
+		id, err = p.freeSlotLocked(slots)
+		if err != nil {
+			return Assignment{}, err
+		}
+	}
+
+	result := Assignment{}
+	prev, occupied := slots[id]
+	if occupied && prev.Fence.Key != def.Key {
+		evicted := prev.Fence
+		result.Evicted = &evicted
+	}
+
+	slot := &Slot{ID: id, Fence: def, AssignedAt: now, LastUsed: now}
+	slots[id] = slot
+	if err := p.save(imei, slots); err != nil {
+		if occupied {
+			slots[id] = prev
+		} else {
+			delete(slots, id)
+		}
+		return Assignment{}, err
+	}
+
+	if p.resolver != nil {
+		p.resolver.SetName(imei, id, def.Name)
+	}
+
+	result.Slot = *slot
+	result.Command = cb.SetGeofence(id, def.Latitude, def.Longitude, def.Radius, true)
+	return result, nil
+}
+
+// Evict removes a fence from its device slot and returns the DeleteGeofence command
+func (p *Provisioner) Evict(imei, key string, cb *encoder.CommandBuilder) (Assignment, error) {
+	if cb == nil {
+		return Assignment{}, ErrCommandsMissing
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, err := p.slots(imei)
+	if err != nil {
+		return Assignment{}, err
+	}
+	id, found := p.slotForLocked(slots, key)
+	if !found {
+		return Assignment{}, fmt.Errorf("%w: %s", ErrUnknownFence, key)
+	}
+
+	prev := slots[id]
+	delete(slots, id)
+	if err := p.save(imei, slots); err != nil {
+		slots[id] = prev
+		return Assignment{}, err
+	}
+	evicted := prev.Fence
+
+	if p.resolver != nil {
+		p.resolver.SetName(imei, id, "")
+	}
+
+	return Assignment{
+		Slot:    Slot{ID: id},
+		Evicted: &evicted,
+		Command: cb.DeleteGeofence(id),
+	}, nil
+}
+
+// Touch marks the fence in a slot as recently used (e.g. on a fence alarm).
+// A failed store write only loses the eviction order, so it is not reported.
+func (p *Provisioner) Touch(imei string, id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, _ := p.slots(imei)
+	if slot, ok := slots[id]; ok {
+		slot.LastUsed = p.now()
+		p.save(imei, slots)
+	}
+}
+
+// List returns the occupied slots of a device, ordered by slot ID
+func (p *Provisioner) List(imei string) []Slot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, _ := p.slots(imei)
+	return sortedSlots(slots)
+}
+
+// SlotFor returns the device slot holding a fence key
+func (p *Provisioner) SlotFor(imei, key string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, _ := p.slots(imei)
+	return p.slotForLocked(slots, key)
+}
+
+// FenceAt returns the fence assigned to a device slot (i.e. resolves an alarm FenceID)
+func (p *Provisioner) FenceAt(imei string, id int) (Definition, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, _ := p.slots(imei)
+	slot, ok := slots[id]
+	if !ok {
+		return Definition{}, false
+	}
+	return slot.Fence, true
+}
+
+// slots returns the slot table for a device, loading it from the store or
+// creating it if needed. A table that fails to load is not cached, so the
+// next call retries. Caller must hold p.mu.
+func (p *Provisioner) slots(imei string) (map[int]*Slot, error) {
+	if slots, ok := p.devices[imei]; ok {
+		return slots, nil
+	}
+	slots := make(map[int]*Slot)
+	if p.store != nil {
+		var stored []Slot
+		if _, err := p.store.Load(context.Background(), imei, &stored); err != nil {
+			return nil, fmt.Errorf("load fence slots of %s: %w", imei, err)
+		}
+		for _, slot := range stored {
+			slots[slot.ID] = &slot
+			if p.resolver != nil {
+				p.resolver.SetName(imei, slot.ID, slot.Fence.Name)
+			}
+		}
+	}
+	p.devices[imei] = slots
+	return slots, nil
+}
+
+// save writes the slot table of a device through to the store. Caller must hold p.mu.
+func (p *Provisioner) save(imei string, slots map[int]*Slot) error {
+	if p.store == nil {
+		return nil
+	}
+	ctx := context.Background()
+	if len(slots) == 0 {
+		return p.store.Delete(ctx, imei)
+	}
+	if err := p.store.Save(ctx, imei, sortedSlots(slots)); err != nil {
+		return fmt.Errorf("save fence slots of %s: %w", imei, err)
+	}
+	return nil
+}
+
+// sortedSlots returns copies of the slots ordered by slot ID
+func sortedSlots(slots map[int]*Slot) []Slot {
+	result := make([]Slot, 0, len(slots))
+	for _, slot := range slots {
+		result = append(result, *slot)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// slotForLocked finds the slot holding a key. Caller must hold p.mu.
+func (p *Provisioner) slotForLocked(slots map[int]*Slot, key string) (int, bool) {
+	for id, slot := range slots {
+		if slot.Fence.Key == key {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// freeSlotLocked returns the lowest free slot, or the LRU unpinned slot to evict.
+// Caller must hold p.mu.
+func (p *Provisioner) freeSlotLocked(slots map[int]*Slot) (int, error) {
+	for id := p.firstSlot; id < p.firstSlot+p.slotCount; id++ {
+		if _, used := slots[id]; !used {
+			return id, nil
+		}
+	}
+
+	if p.noEviction {
+		return 0, ErrNoFreeSlot
+	}
+
+	var victim *Slot
+	for _, slot := range slots {
+		if slot.Fence.Pinned {
+			continue
+		}
+		if victim == nil || slot.LastUsed.Before(victim.LastUsed) ||
+			(slot.LastUsed.Equal(victim.LastUsed) && slot.ID < victim.ID) {
+			victim = slot
+		}
+	}
+	if victim == nil {
+		return 0, fmt.Errorf("%w: all %d slots are pinned", ErrNoFreeSlot, p.slotCount)
+	}
+	return victim.ID, nil
+}
@@ -0,0 +1,212 @@
+package fence
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/validator"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+func newTestProvisioner(opts ...ProvisionerOption) (*Provisioner, *time.Time) {
+	now := time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	p := NewProvisioner(append([]ProvisionerOption{WithProvisionerClock(clock)}, opts...)...)
+	return p, &now
+}
+
+func commands() *encoder.CommandBuilder {
+	return encoder.New().NewCommandBuilder(1, 0x00000001)
+}
+
+func def(key string) Definition {
+	return Definition{Key: key, Name: "Fence " + key, Latitude: -12.0464, Longitude: -77.0428, Radius: 300}
+}
+
+func TestProvisioner_AssignFillsLowestFreeSlot(t *testing.T) {
+	p, _ := newTestProvisioner()
+
+	a, err := p.Assign(testIMEI, def("a"), commands())
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if a.Slot.ID != DefaultFirstSlot {
+		t.Errorf("Expected slot %d, got %d", DefaultFirstSlot, a.Slot.ID)
+	}
+	if a.Evicted != nil {
+		t.Error("Expected no eviction")
+	}
+	if !validator.ValidateCRC(a.Command) {
+		t.Error("CRC validation failed")
+	}
+	if !strings.Contains(string(a.Command), "FENCE,1,1,") {
+		t.Errorf("Expected FENCE command for slot 1, got %q", a.Command)
+	}
+
+	b, _ := p.Assign(testIMEI, def("b"), commands())
+	if b.Slot.ID != DefaultFirstSlot+1 {
+		t.Errorf("Expected slot %d, got %d", DefaultFirstSlot+1, b.Slot.ID)
+	}
+}
+
+func TestProvisioner_ReassignKeepsSlot(t *testing.T) {
+	p, _ := newTestProvisioner()
+
+	first, _ := p.Assign(testIMEI, def("a"), commands())
+	updated := def("a")
+	updated.Radius = 800
+	second, err := p.Assign(testIMEI, updated, commands())
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+
+	if second.Slot.ID != first.Slot.ID {
+		t.Errorf("Expected slot %d to be reused, got %d", first.Slot.ID, second.Slot.ID)
+	}
+	if second.Evicted != nil {
+		t.Error("Expected no eviction on update")
+	}
+	if len(p.List(testIMEI)) != 1 {
+		t.Errorf("Expected 1 slot in use, got %d", len(p.List(testIMEI)))
+	}
+}
+
+func TestProvisioner_EvictsLeastRecentlyUsed(t *testing.T) {
+	p, now := newTestProvisioner(WithSlots(1, 2))
+
+	p.Assign(testIMEI, def("a"), commands())
+	*now = now.Add(time.Minute)
+	p.Assign(testIMEI, def("b"), commands())
+
+	// Fence "a" triggered an alarm, so "b" is now least recently used
+	*now = now.Add(time.Minute)
+	p.Touch(testIMEI, 1)
+
+	*now = now.Add(time.Minute)
+	c, err := p.Assign(testIMEI, def("c"), commands())
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if c.Slot.ID != 2 {
+		t.Errorf("Expected slot 2 to be reused, got %d", c.Slot.ID)
+	}
+	if c.Evicted == nil || c.Evicted.Key != "b" {
+		t.Errorf("Expected fence b to be evicted, got %+v", c.Evicted)
+	}
+	if _, ok := p.SlotFor(testIMEI, "b"); ok {
+		t.Error("Expected fence b to be unassigned")
+	}
+}
+
+func TestProvisioner_PinnedAndNoEviction(t *testing.T) {
+	p, _ := newTestProvisioner(WithSlots(1, 1))
+
+	pinned := def("a")
+	pinned.Pinned = true
+	p.Assign(testIMEI, pinned, commands())
+
+	if _, err := p.Assign(testIMEI, def("b"), commands()); !errors.Is(err, ErrNoFreeSlot) {
+		t.Errorf("Expected ErrNoFreeSlot for pinned slots, got %v", err)
+	}
+
+	strict, _ := newTestProvisioner(WithSlots(1, 1), WithoutEviction())
+	strict.Assign(testIMEI, def("a"), commands())
+	if _, err := strict.Assign(testIMEI, def("b"), commands()); !errors.Is(err, ErrNoFreeSlot) {
+		t.Errorf("Expected ErrNoFreeSlot without eviction, got %v", err)
+	}
+}
+
+func TestProvisioner_Evict(t *testing.T) {
+	p, _ := newTestProvisioner()
+	p.Assign(testIMEI, def("a"), commands())
+
+	a, err := p.Evict(testIMEI, "a", commands())
+	if err != nil {
+		t.Fatalf("Evict failed: %v", err)
+	}
+	if a.Evicted == nil || a.Evicted.Key != "a" {
+		t.Errorf("Expected fence a to be evicted, got %+v", a.Evicted)
+	}
+	if !strings.Contains(string(a.Command), "FENCE,1,0#") {
+		t.Errorf("Expected delete command for slot 1, got %q", a.Command)
+	}
+
+	if _, err := p.Evict(testIMEI, "a", commands()); !errors.Is(err, ErrUnknownFence) {
+		t.Errorf("Expected ErrUnknownFence, got %v", err)
+	}
+}
+
+func TestProvisioner_KeepsResolverNames(t *testing.T) {
+	r := NewResolver()
+	p, _ := newTestProvisioner(WithResolver(r))
+
+	a, _ := p.Assign(testIMEI, def("a"), commands())
+	if f, _ := r.Resolve(testIMEI, a.Slot.ID); f.Name != "Fence a" {
+		t.Errorf("Expected resolver name 'Fence a', got %q", f.Name)
+	}
+
+	if got, ok := p.FenceAt(testIMEI, a.Slot.ID); !ok || got.Key != "a" {
+		t.Errorf("Expected FenceAt to return fence a, got %+v", got)
+	}
+
+	p.Evict(testIMEI, "a", commands())
+	if _, known := r.Resolve(testIMEI, a.Slot.ID); known {
+		t.Error("Expected resolver name to be cleared after eviction")
+	}
+}
+
+func TestProvisioner_SlotStore(t *testing.T) {
+	slots := store.NewDeviceStore(store.NewMemory(), DefaultSlotNamespace)
+	p, _ := newTestProvisioner(WithSlotStore(slots))
+	p.Assign(testIMEI, def("a"), commands())
+	p.Assign(testIMEI, def("b"), commands())
+	p.Evict(testIMEI, "a", commands())
+
+	// A new provisioner on the same store knows the fences on the device
+	r := NewResolver()
+	restarted, _ := newTestProvisioner(WithSlotStore(slots), WithResolver(r))
+	if got, ok := restarted.FenceAt(testIMEI, DefaultFirstSlot+1); !ok || got.Key != "b" {
+		t.Errorf("Expected fence b restored in slot %d, got %+v", DefaultFirstSlot+1, got)
+	}
+	if f, _ := r.Resolve(testIMEI, DefaultFirstSlot+1); f.Name != "Fence b" {
+		t.Errorf("Expected resolver name 'Fence b' restored, got %q", f.Name)
+	}
+	if a, _ := restarted.Assign(testIMEI, def("c"), commands()); a.Slot.ID != DefaultFirstSlot || a.Evicted != nil {
+		t.Errorf("Expected the freed slot %d reused, got %+v", DefaultFirstSlot, a)
+	}
+
+	restarted.Evict(testIMEI, "b", commands())
+	restarted.Evict(testIMEI, "c", commands())
+	if imeis, _ := slots.IMEIs(context.Background()); len(imeis) != 0 {
+		t.Errorf("Expected the empty slot table deleted, got %v", imeis)
+	}
+}
+
+func TestProvisioner_InvalidDefinition(t *testing.T) {
+	p, _ := newTestProvisioner()
+
+	tests := []struct {
+		name string
+		def  Definition
+	}{
+		{"empty key", Definition{Latitude: 1, Longitude: 1, Radius: 100}},
+		{"bad latitude", Definition{Key: "x", Latitude: 91, Longitude: 1, Radius: 100}},
+		{"zero radius", Definition{Key: "x", Latitude: 1, Longitude: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.Assign(testIMEI, tt.def, commands()); !errors.Is(err, ErrInvalidFence) {
+				t.Errorf("Expected ErrInvalidFence, got %v", err)
+			}
+		})
+	}
+
+	if _, err := p.Assign(testIMEI, def("a"), nil); !errors.Is(err, ErrCommandsMissing) {
+		t.Errorf("Expected ErrCommandsMissing, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package jimi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+func TestFingerprint_IgnoresRawAndParseTime(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC())
+
+	first, err := decoder.DecodeHex(accOnAlarmHex)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// Same content with a different (ignored) CRC
+	second, err := decoder.DecodeHex(accOnAlarmHex[:len(accOnAlarmHex)-8] + "00000D0A")
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if packet.Fingerprint(first) != packet.Fingerprint(second) {
+		t.Error("Expected equal fingerprints for the same content")
+	}
+}
+
+func TestFingerprint_DistinguishesContent(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC())
+	on, _ := decoder.DecodeHex(accOnAlarmHex)
+	off, _ := decoder.DecodeHex(accOffAlarmHex)
+	speed, _ := decoder.DecodeHex(speedAlarmHex)
+
+	status := NewDecoder(WithSkipCRC(), WithACCAlarmsAsStatus())
+	onStatus, _ := status.DecodeHex(accOnAlarmHex)
+
+	prints := map[string]string{
+		"acc on":        packet.Fingerprint(on),
+		"acc off":       packet.Fingerprint(off),
+		"speed":         packet.Fingerprint(speed),
+		"acc on status": packet.Fingerprint(onStatus),
+	}
+	seen := make(map[string]string)
+	for name, fp := range prints {
+		if other, ok := seen[fp]; ok {
+			t.Errorf("Fingerprint collision between %q and %q", name, other)
+		}
+		seen[fp] = name
+	}
+
+	hb := &packet.HeartbeatPacket{}
+	hb.SerialNum = 1
+	hb2 := &packet.HeartbeatPacket{}
+	hb2.SerialNum = 2
+	if packet.Fingerprint(hb) == packet.Fingerprint(hb2) {
+		t.Error("Expected serial number to be part of the fingerprint")
+	}
+
+	if packet.Fingerprint(nil) != "" {
+		t.Error("Expected empty fingerprint for nil packet")
+	}
+}
+
+func TestFingerprint_Stable(t *testing.T) {
+	// Pinned so that accidental changes to the hashing scheme are caught
+	pkt, err := NewDecoder().DecodeHex("787808132404020001870D0D0A")
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	const want = "cf533410e88e6329e629d8bf305d067161f0cc79e15d4b9375ccbf29d1876d93"
+	if got := packet.Fingerprint(pkt); got != want {
+		t.Errorf("Fingerprint changed: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+// Package geocoder answers GPS address requests (0x2A) by reverse
+// geocoding the position and building the address response (0x17/0x97)
+// the device expects.
+//
+// Lookups go through the Provider interface; Nominatim, Google and a custom
+// HTTP endpoint are built in, and any other service can be plugged in with
+// ProviderFunc.
+//
+// Example usage:
+//
+//	resolver := geocoder.NewResolver(geocoder.NewNominatim(
+//	    geocoder.WithUserAgent("fleet-server/1.0 ops@example.com"),
+//	))
+//	srv := server.New(server.WithAddressResolver(resolver))
+package geocoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// DefaultUserAgent is sent to the geocoding services without WithUserAgent
+const DefaultUserAgent = "jimi-vl103m"
+
+// maxResponseBody bounds the geocoding service responses read
+const maxResponseBody = 1 << 20
+
+// ErrNoAddress is returned when the service has no address for a position
+var ErrNoAddress = errors.New("geocoder: no address found")
+
+// Provider looks up the address of a position
+type Provider interface {
+	// ReverseGeocode returns the address of the position, in the language
+	// the device asked for when the service supports it
+	ReverseGeocode(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error)
+
+// ReverseGeocode implements Provider
+func (f ProviderFunc) ReverseGeocode(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error) {
+	return f(ctx, lat, lon, lang)
+}
+
+// Option configures the built-in providers
+type Option func(*client)
+
+// WithHTTPClient sets the HTTP client of the requests (default http.DefaultClient)
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *client) {
+		cl.http = c
+	}
+}
+
+// WithBaseURL sets the service URL, e.g. a self-hosted Nominatim instance
+func WithBaseURL(url string) Option {
+	return func(cl *client) {
+		cl.baseURL = url
+	}
+}
+
+// WithUserAgent sets the User-Agent of the requests. The public Nominatim
+// service requires one that identifies the application.
+func WithUserAgent(ua string) Option {
+	return func(cl *client) {
+		cl.userAgent = ua
+	}
+}
+
+// client is the HTTP plumbing shared by the built-in providers
+type client struct {
+	http      *http.Client
+	baseURL   string
+	userAgent string
+}
+
+// newClient creates a client for the service at baseURL
+func newClient(baseURL string, opts []Option) client {
+	cl := client{http: http.DefaultClient, baseURL: baseURL, userAgent: DefaultUserAgent}
+	for _, opt := range opts {
+		opt(&cl)
+	}
+	return cl
+}
+
+// get requests url and returns the body of a 200 response
+func (cl client) get(ctx context.Context, url string, lang protocol.Language) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", cl.userAgent)
+	req.Header.Set("Accept-Language", languageTag(lang))
+
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoder: %s: HTTP %d", req.URL.Host, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// getJSON requests url and decodes the JSON body into out
+func (cl client) getJSON(ctx context.Context, url string, lang protocol.Language, out any) error {
+	body, err := cl.get(ctx, url, lang)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("geocoder: invalid response: %w", err)
+	}
+	return nil
+}
+
+// languageTag returns the language tag of a device language
+func languageTag(lang protocol.Language) string {
+	if lang == protocol.LanguageChinese {
+		return "zh"
+	}
+	return "en"
+}
+
+// withTimeout bounds ctx by d (0 leaves it unbounded)
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
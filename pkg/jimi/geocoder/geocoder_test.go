@@ -0,0 +1,208 @@
+package geocoder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// serve runs an HTTP service answering with status and body, and records
+// the last request
+func serve(t *testing.T, status int, body string) (*httptest.Server, **http.Request) {
+	t.Helper()
+	var last *http.Request
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(hs.Close)
+	return hs, &last
+}
+
+// request returns a GPS address request for a position
+func request(t *testing.T, lat, lon float64, phone string, lang protocol.Language) *packet.GPSAddressRequestPacket {
+	t.Helper()
+	coords, err := types.NewCoordinates(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := packet.NewGPSAddressRequestPacket(coords, phone, lang)
+	req.SerialNum = 0x0102
+	return req
+}
+
+func TestNominatim(t *testing.T) {
+	hs, last := serve(t, http.StatusOK, `{"display_name":"1 George St, Sydney NSW 2000, Australia"}`)
+	p := NewNominatim(WithBaseURL(hs.URL+"/"), WithUserAgent("test/1.0"))
+
+	got, err := p.ReverseGeocode(context.Background(), -33.86, 151.2, protocol.LanguageEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "1 George St, Sydney NSW 2000, Australia" {
+		t.Errorf("Unexpected address %q", got)
+	}
+	r := *last
+	if r.URL.Path != "/reverse" || r.URL.Query().Get("lat") != "-33.860000" || r.URL.Query().Get("lon") != "151.200000" {
+		t.Errorf("Unexpected request %s", r.URL)
+	}
+	if r.Header.Get("User-Agent") != "test/1.0" || r.Header.Get("Accept-Language") != "en" {
+		t.Errorf("Unexpected headers %v", r.Header)
+	}
+
+	hs, _ = serve(t, http.StatusOK, `{"error":"Unable to geocode"}`)
+	if _, err := NewNominatim(WithBaseURL(hs.URL)).ReverseGeocode(context.Background(), 0, 0, protocol.LanguageEnglish); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Expected ErrNoAddress, got %v", err)
+	}
+}
+
+func TestGoogle(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr error
+	}{
+		{"ok", `{"status":"OK","results":[{"formatted_address":"深圳市福田区"}]}`, "深圳市福田区", nil},
+		{"zero results", `{"status":"ZERO_RESULTS","results":[]}`, "", ErrNoAddress},
+		{"denied", `{"status":"REQUEST_DENIED","error_message":"bad key"}`, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs, last := serve(t, http.StatusOK, tt.body)
+			got, err := NewGoogle("KEY", WithBaseURL(hs.URL)).ReverseGeocode(context.Background(), 22.54, 114.05, protocol.LanguageChinese)
+			switch {
+			case tt.want != "" && (err != nil || got != tt.want):
+				t.Errorf("Expected %q, got %q (%v)", tt.want, got, err)
+			case tt.want == "" && err == nil:
+				t.Errorf("Expected an error, got %q", got)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			q := (*last).URL.Query()
+			if q.Get("latlng") != "22.540000,114.050000" || q.Get("key") != "KEY" || q.Get("language") != "zh" {
+				t.Errorf("Unexpected query %v", q)
+			}
+		})
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"json", http.StatusOK, `{"address":"Main St 1"}`, "Main St 1"},
+		{"text", http.StatusOK, "Main St 1\n", "Main St 1"},
+		{"empty", http.StatusOK, "", ""},
+		{"server error", http.StatusBadGateway, "down", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs, last := serve(t, tt.status, tt.body)
+			p := NewHTTP(hs.URL + "/geo?lat={lat}&lon={lon}&lang={lang}")
+			got, err := p.ReverseGeocode(context.Background(), 1.5, -2.25, protocol.LanguageEnglish)
+			if got != tt.want || (err != nil) != (tt.want == "") {
+				t.Errorf("Expected %q, got %q (%v)", tt.want, got, err)
+			}
+			if q := (*last).URL.Query(); q.Get("lat") != "1.500000" || q.Get("lon") != "-2.250000" || q.Get("lang") != "en" {
+				t.Errorf("Unexpected query %v", q)
+			}
+		})
+	}
+}
+
+func TestResolver_Response(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  Provider
+		lang      protocol.Language
+		phone     string
+		opts      []ResolverOption
+		wantProto byte
+		want      string
+	}{
+		{"english", fixed("1 George St"), protocol.LanguageEnglish, "0412345678", nil,
+			protocol.ProtocolAddressResponseEnglish, "1 George St"},
+		{"chinese", fixed("深圳市福田区"), protocol.LanguageChinese, "", nil,
+			protocol.ProtocolAddressResponseChinese, "深圳市福田区"},
+		{"fallback", failing(), protocol.LanguageEnglish, "", []ResolverOption{WithCoordinateFallback()},
+			protocol.ProtocolAddressResponseEnglish, "-33.860000,151.200000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request(t, -33.86, 151.2, tt.phone, tt.lang)
+			data, err := NewResolver(tt.provider, tt.opts...).Response(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := jimi.NewDecoder().Decode(data)
+			if err != nil {
+				t.Fatalf("Response does not decode: %v", err)
+			}
+			resp, ok := p.(*packet.AddressResponsePacket)
+			if !ok || resp.ProtocolNum != tt.wantProto || resp.SerialNumber() != 0x0102 {
+				t.Fatalf("Unexpected response %#v", p)
+			}
+			if resp.Address != tt.want {
+				t.Errorf("Expected address %q, got %q", tt.want, resp.Address)
+			}
+			if wantPhone := tt.phone; wantPhone != "" && strings.TrimSpace(resp.PhoneNumber) != wantPhone {
+				t.Errorf("Expected phone %q, got %q", wantPhone, resp.PhoneNumber)
+			}
+		})
+	}
+
+	if _, err := NewResolver(failing()).Response(context.Background(), request(t, 0, 0, "", 0)); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Expected the provider error without fallback, got %v", err)
+	}
+}
+
+func TestFitAddress(t *testing.T) {
+	long := strings.Repeat("Straße ", 60)
+	if got := fitAddress(long, protocol.LanguageEnglish); len(got) > maxEnglishAddress || !strings.HasPrefix(long, got) {
+		t.Errorf("English address not fitted: %d bytes", len(got))
+	}
+	cjk := strings.Repeat("深圳𠀀", 60)
+	got := fitAddress(cjk, protocol.LanguageChinese)
+	if n := len(utf16.Encode([]rune(got))); n > maxChineseAddress || n < maxChineseAddress-1 {
+		t.Errorf("Chinese address not fitted: %d UTF-16 units", n)
+	}
+	if got := fitAddress("short", protocol.LanguageChinese); got != "short" {
+		t.Errorf("Expected a short address unchanged, got %q", got)
+	}
+
+	// Fitted addresses encode at the limits
+	for _, lang := range []protocol.Language{protocol.LanguageEnglish, protocol.LanguageChinese} {
+		req := request(t, 1, 1, "", lang)
+		if _, err := NewResolver(fixed(long+cjk)).Response(context.Background(), req); err != nil {
+			t.Errorf("%s: %v", lang, err)
+		}
+	}
+}
+
+// fixed returns a provider answering with address
+func fixed(address string) Provider {
+	return ProviderFunc(func(context.Context, float64, float64, protocol.Language) (string, error) {
+		return address, nil
+	})
+}
+
+// failing returns a provider without addresses
+func failing() Provider {
+	return ProviderFunc(func(context.Context, float64, float64, protocol.Language) (string, error) {
+		return "", ErrNoAddress
+	})
+}
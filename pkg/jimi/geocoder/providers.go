@@ -0,0 +1,144 @@
+package geocoder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Service URLs of the built-in providers
+const (
+	// NominatimURL is the public OpenStreetMap Nominatim service
+	NominatimURL = "https://nominatim.openstreetmap.org"
+
+	// GoogleURL is the Google Maps Geocoding API
+	GoogleURL = "https://maps.googleapis.com/maps/api/geocode/json"
+)
+
+// Nominatim looks up addresses with OpenStreetMap Nominatim. The public
+// service allows one request per second; use WithBaseURL for a self-hosted
+// instance under heavier load.
+type Nominatim struct {
+	client client
+}
+
+var _ Provider = (*Nominatim)(nil)
+
+// NewNominatim creates a Nominatim provider
+func NewNominatim(opts ...Option) *Nominatim {
+	return &Nominatim{client: newClient(NominatimURL, opts)}
+}
+
+// ReverseGeocode implements Provider
+func (n *Nominatim) ReverseGeocode(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error) {
+	q := url.Values{}
+	q.Set("format", "jsonv2")
+	q.Set("lat", formatCoord(lat))
+	q.Set("lon", formatCoord(lon))
+
+	var resp struct {
+		DisplayName string `json:"display_name"`
+		Error       string `json:"error"`
+	}
+	if err := n.client.getJSON(ctx, strings.TrimSuffix(n.client.baseURL, "/")+"/reverse?"+q.Encode(), lang, &resp); err != nil {
+		return "", err
+	}
+	if resp.DisplayName == "" {
+		return "", ErrNoAddress
+	}
+	return resp.DisplayName, nil
+}
+
+// Google looks up addresses with the Google Maps Geocoding API
+type Google struct {
+	client client
+	key    string
+}
+
+var _ Provider = (*Google)(nil)
+
+// NewGoogle creates a Google provider with an API key
+func NewGoogle(key string, opts ...Option) *Google {
+	return &Google{client: newClient(GoogleURL, opts), key: key}
+}
+
+// ReverseGeocode implements Provider
+func (g *Google) ReverseGeocode(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error) {
+	q := url.Values{}
+	q.Set("latlng", formatCoord(lat)+","+formatCoord(lon))
+	q.Set("language", languageTag(lang))
+	q.Set("key", g.key)
+
+	var resp struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			FormattedAddress string `json:"formatted_address"`
+		} `json:"results"`
+	}
+	if err := g.client.getJSON(ctx, g.client.baseURL+"?"+q.Encode(), lang, &resp); err != nil {
+		return "", err
+	}
+	switch {
+	case resp.Status == "ZERO_RESULTS" || resp.Status == "OK" && len(resp.Results) == 0:
+		return "", ErrNoAddress
+	case resp.Status != "OK":
+		return "", fmt.Errorf("geocoder: google: %s %s", resp.Status, resp.ErrorMessage)
+	}
+	return resp.Results[0].FormattedAddress, nil
+}
+
+// HTTP looks up addresses with a custom HTTP service. The URL template may
+// contain {lat}, {lon} and {lang} (zh or en), e.g.
+// "https://geo.example.com/reverse?lat={lat}&lon={lon}&lang={lang}". The
+// service answers with a JSON object holding the address in an "address"
+// field, or with the address as plain text.
+type HTTP struct {
+	client client
+}
+
+var _ Provider = (*HTTP)(nil)
+
+// NewHTTP creates a provider for a custom service
+func NewHTTP(urlTemplate string, opts ...Option) *HTTP {
+	return &HTTP{client: newClient(urlTemplate, opts)}
+}
+
+// ReverseGeocode implements Provider
+func (h *HTTP) ReverseGeocode(ctx context.Context, lat, lon float64, lang protocol.Language) (string, error) {
+	u := strings.NewReplacer(
+		"{lat}", formatCoord(lat),
+		"{lon}", formatCoord(lon),
+		"{lang}", languageTag(lang),
+	).Replace(h.client.baseURL)
+
+	body, err := h.client.get(ctx, u, lang)
+	if err != nil {
+		return "", err
+	}
+
+	address := strings.TrimSpace(string(body))
+	if strings.HasPrefix(address, "{") {
+		var resp struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", fmt.Errorf("geocoder: invalid response: %w", err)
+		}
+		address = strings.TrimSpace(resp.Address)
+	}
+	if address == "" {
+		return "", ErrNoAddress
+	}
+	return address, nil
+}
+
+// formatCoord formats a coordinate with the precision of the protocol
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
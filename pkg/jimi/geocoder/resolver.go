@@ -0,0 +1,134 @@
+package geocoder
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// DefaultTimeout bounds each address lookup of a Resolver
+const DefaultTimeout = 5 * time.Second
+
+// Address response fields (see encoder.AddressResponseParams)
+const (
+	// AlarmSMS is the alarm code flag of address responses
+	AlarmSMS = "ALARMSMS"
+
+	// NoPhoneNumber is the phone number of responses to requests without one
+	NoPhoneNumber = "000000000000000000000"
+)
+
+// Address limits of the response packets
+const (
+	// maxEnglishAddress is the longest address (bytes) whose 0x97 content
+	// length still fits its 1-byte field
+	maxEnglishAddress = 255 - 39
+
+	// maxChineseAddress is the longest address (UTF-16 units) whose 0x17
+	// packet length still fits its 1-byte field
+	maxChineseAddress = (255 - 45) / 2
+)
+
+// Resolver answers GPS address requests with a provider
+type Resolver struct {
+	provider Provider
+	timeout  time.Duration
+	fallback bool
+}
+
+// ResolverOption configures a Resolver
+type ResolverOption func(*Resolver)
+
+// WithTimeout bounds each lookup (default DefaultTimeout, 0 disables)
+func WithTimeout(d time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.timeout = d
+	}
+}
+
+// WithCoordinateFallback answers with the coordinates ("22.546000,114.079000")
+// when the lookup fails, so the device still gets a response
+func WithCoordinateFallback() ResolverOption {
+	return func(r *Resolver) {
+		r.fallback = true
+	}
+}
+
+// NewResolver creates a resolver looking up addresses with p
+func NewResolver(p Provider, opts ...ResolverOption) *Resolver {
+	r := &Resolver{provider: p, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve looks up the address of a request and returns the parameters of
+// its response: the address shortened to fit the packet, the phone number
+// and serial number of the request, and the language the device asked for.
+// Set CRC for devices with another CRC variant before encoding.
+func (r *Resolver) Resolve(ctx context.Context, req *packet.GPSAddressRequestPacket) (encoder.AddressResponseParams, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	lat, lon := req.Coordinates.SignedLatitude(), req.Coordinates.SignedLongitude()
+	lang := req.Language
+	if lang != protocol.LanguageChinese {
+		lang = protocol.LanguageEnglish
+	}
+
+	address, err := r.provider.ReverseGeocode(ctx, lat, lon, lang)
+	if err != nil {
+		if !r.fallback {
+			return encoder.AddressResponseParams{}, err
+		}
+		address = formatCoord(lat) + "," + formatCoord(lon)
+	}
+
+	phone := strings.TrimSpace(req.PhoneNumber)
+	if phone == "" {
+		phone = NoPhoneNumber
+	}
+	return encoder.AddressResponseParams{
+		AlarmSMS:     AlarmSMS,
+		Address:      fitAddress(address, lang),
+		PhoneNumber:  phone,
+		SerialNumber: req.SerialNumber(),
+		Language:     lang,
+	}, nil
+}
+
+// Response looks up the address of a request and encodes its response
+// (0x17 for Chinese, 0x97 otherwise) with the protocol default CRC
+func (r *Resolver) Response(ctx context.Context, req *packet.GPSAddressRequestPacket) ([]byte, error) {
+	params, err := r.Resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.AddressResponse(params)
+}
+
+// fitAddress shortens an address to the longest prefix of whole characters
+// that fits the response packet of lang
+func fitAddress(address string, lang protocol.Language) string {
+	size := 0
+	for i, r := range address {
+		if lang == protocol.LanguageChinese {
+			size++
+			if r > 0xFFFF {
+				size++ // surrogate pair
+			}
+			if size > maxChineseAddress {
+				return address[:i]
+			}
+		} else if i+utf8.RuneLen(r) > maxEnglishAddress {
+			return address[:i]
+		}
+	}
+	return address
+}
@@ -0,0 +1,342 @@
+package geofence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Engine defaults
+const (
+	// DefaultMargin is the distance in meters beyond a boundary a fix must be
+	// to change the state of a device, about the accuracy of a GPS fix
+	DefaultMargin = 20.0
+
+	// DefaultConfirmations is the number of consecutive fixes beyond the
+	// margin needed to change the state of a device
+	DefaultConfirmations = 1
+)
+
+// AllDevices assigns fences to every device (see Engine.Assign)
+const AllDevices = "*"
+
+// ErrUnknownFence is returned when assigning a fence that was not added
+var ErrUnknownFence = errors.New("unknown geofence")
+
+// Transition is the direction of a boundary crossing
+type Transition string
+
+// Transitions
+const (
+	Enter Transition = "enter"
+	Exit  Transition = "exit"
+)
+
+// Event is a device crossing a fence boundary
+type Event struct {
+	IMEI       string     `json:"imei"`
+	Fence      Fence      `json:"fence"`
+	Transition Transition `json:"transition"`
+
+	// Time is the device time of the fix that confirmed the crossing
+	Time        time.Time         `json:"time"`
+	Coordinates types.Coordinates `json:"coordinates"`
+
+	// Distance is the distance of the fix to the boundary in meters,
+	// negative inside the fence
+	Distance float64 `json:"distance"`
+}
+
+// String returns a human-readable representation
+func (e Event) String() string {
+	return fmt.Sprintf("GeofenceEvent{IMEI: %s, %s %s, Distance: %.0fm, Time: %s}",
+		e.IMEI, e.Transition, e.Fence.DisplayName(), e.Distance, e.Time.Format(time.RFC3339))
+}
+
+// Handler is called for every event
+type Handler func(Event)
+
+// Option configures an Engine
+type Option func(*Engine)
+
+// WithMargin sets the distance in meters beyond a boundary a fix must be to
+// change the state of a device (default DefaultMargin, 0 disables)
+func WithMargin(meters float64) Option {
+	return func(e *Engine) {
+		e.margin = max(meters, 0)
+	}
+}
+
+// WithConfirmations sets the number of consecutive fixes beyond the margin
+// needed to change the state of a device (default DefaultConfirmations)
+func WithConfirmations(n int) Option {
+	return func(e *Engine) {
+		e.confirmations = max(n, 1)
+	}
+}
+
+// WithHandler sets the callback invoked for every event
+func WithHandler(h Handler) Option {
+	return func(e *Engine) {
+		e.handler = h
+	}
+}
+
+// stateKey identifies the state of a device in a fence
+type stateKey struct {
+	imei  string
+	fence string
+}
+
+// state is the last confirmed side of a fence a device is on
+type state struct {
+	inside  bool
+	pending int // consecutive fixes beyond the margin on the other side
+}
+
+// Engine keeps the server-side fences, their assignment to devices and the
+// side of each fence every device is on. It is safe for concurrent use.
+type Engine struct {
+	margin        float64
+	confirmations int
+	handler       Handler
+
+	mu       sync.Mutex
+	fences   map[string]Fence
+	assigned map[string][]string // IMEI (or AllDevices) -> fence IDs
+	states   map[stateKey]*state
+}
+
+// NewEngine creates an engine without fences
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{
+		margin:        DefaultMargin,
+		confirmations: DefaultConfirmations,
+		fences:        make(map[string]Fence),
+		assigned:      make(map[string][]string),
+		states:        make(map[stateKey]*state),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// AddFence adds a fence or replaces the fence with the same ID. The state of
+// devices in a replaced fence is reset.
+func (e *Engine) AddFence(f Fence) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.fences[f.ID]; exists {
+		e.resetFence(f.ID)
+	}
+	e.fences[f.ID] = f
+	return nil
+}
+
+// RemoveFence removes a fence and its assignments
+func (e *Engine) RemoveFence(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.fences, id)
+	for imei, ids := range e.assigned {
+		e.assigned[imei] = slices.DeleteFunc(ids, func(v string) bool { return v == id })
+	}
+	e.resetFence(id)
+}
+
+// Fence returns a fence by ID
+func (e *Engine) Fence(id string) (Fence, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, ok := e.fences[id]
+	return f, ok
+}
+
+// Fences returns every fence, sorted by ID
+func (e *Engine) Fences() []Fence {
+	e.mu.Lock()
+	out := make([]Fence, 0, len(e.fences))
+	for _, f := range e.fences {
+		out = append(out, f)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Assign assigns fences to a device, or to every device with AllDevices
+func (e *Engine) Assign(imei string, ids ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := e.fences[id]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownFence, id)
+		}
+	}
+	for _, id := range ids {
+		if !slices.Contains(e.assigned[imei], id) {
+			e.assigned[imei] = append(e.assigned[imei], id)
+		}
+	}
+	return nil
+}
+
+// Unassign removes fences from a device (or from AllDevices)
+func (e *Engine) Unassign(imei string, ids ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.assigned[imei] = slices.DeleteFunc(e.assigned[imei], func(v string) bool { return slices.Contains(ids, v) })
+	for _, id := range ids {
+		delete(e.states, stateKey{imei, id})
+	}
+}
+
+// Assigned returns the fences evaluated for a device: its own and those
+// assigned to every device, sorted by ID
+func (e *Engine) Assigned(imei string) []Fence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.assignedLocked(imei)
+}
+
+// Inside returns the IDs of the fences a device is confirmed inside, sorted
+func (e *Engine) Inside(imei string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []string
+	for key, st := range e.states {
+		if key.imei == imei && st.inside {
+			out = append(out, key.fence)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Observe evaluates the fix of a positioned location packet (0x22, 0xA0) of
+// a device against its fences and returns the crossings it confirms. The
+// first fix of a device in a fence only sets its side, without an event.
+// Other packets are ignored.
+func (e *Engine) Observe(imei string, p packet.Packet) []Event {
+	loc, ok := locationOf(p)
+	if imei == "" || !ok || !loc.IsPositioned() {
+		return nil
+	}
+
+	e.mu.Lock()
+	var events []Event
+	for _, f := range e.assignedLocked(imei) {
+		d := f.Distance(loc.Coordinates)
+		key := stateKey{imei, f.ID}
+		st, known := e.states[key]
+		if !known {
+			e.states[key] = &state{inside: d <= 0}
+			continue
+		}
+
+		beyond := d > e.margin
+		if !st.inside {
+			beyond = d < -e.margin
+		}
+		if !beyond {
+			st.pending = 0
+			continue
+		}
+		if st.pending++; st.pending < e.confirmations {
+			continue
+		}
+		st.inside, st.pending = !st.inside, 0
+		ev := Event{IMEI: imei, Fence: f, Transition: Exit, Time: loc.DateTime.Time, Coordinates: loc.Coordinates, Distance: d}
+		if st.inside {
+			ev.Transition = Enter
+		}
+		events = append(events, ev)
+	}
+	handler := e.handler
+	e.mu.Unlock()
+
+	if handler != nil {
+		for _, ev := range events {
+			handler(ev)
+		}
+	}
+	return events
+}
+
+// Config is the JSON form of the fences and assignments of an engine:
+//
+//	{
+//	  "fences": [
+//	    {"id": "depot", "center": {"latitude": -12.0464, "longitude": -77.0428}, "radius": 300},
+//	    {"id": "yard", "name": "North yard", "polygon": [{"latitude": ...}, ...]}
+//	  ],
+//	  "devices": {"359339073930520": ["yard"], "*": ["depot"]}
+//	}
+type Config struct {
+	Fences  []Fence             `json:"fences"`
+	Devices map[string][]string `json:"devices"`
+}
+
+// Load adds the fences of a JSON Config and assigns them
+func (e *Engine) Load(r io.Reader) error {
+	var cfg Config
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return fmt.Errorf("geofence config: %w", err)
+	}
+	for _, f := range cfg.Fences {
+		if err := e.AddFence(f); err != nil {
+			return err
+		}
+	}
+	for imei, ids := range cfg.Devices {
+		if err := e.Assign(imei, ids...); err != nil {
+			return fmt.Errorf("device %s: %w", imei, err)
+		}
+	}
+	return nil
+}
+
+// assignedLocked returns the fences of a device; e.mu must be held
+func (e *Engine) assignedLocked(imei string) []Fence {
+	var out []Fence
+	for _, id := range append(slices.Clone(e.assigned[imei]), e.assigned[AllDevices]...) {
+		if f, ok := e.fences[id]; ok && !slices.ContainsFunc(out, func(o Fence) bool { return o.ID == id }) {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// resetFence forgets the state of every device in a fence; e.mu must be held
+func (e *Engine) resetFence(id string) {
+	for key := range e.states {
+		if key.fence == id {
+			delete(e.states, key)
+		}
+	}
+}
+
+// locationOf returns the location packet of p
+func locationOf(p packet.Packet) (*packet.LocationPacket, bool) {
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		return v, true
+	case *packet.Location4GPacket:
+		return &v.LocationPacket, true
+	}
+	return nil, false
+}
@@ -0,0 +1,158 @@
+// Package geofence evaluates server-side geofences against the fixes of
+// every device.
+//
+// Device fences (GFENCE1..GFENCE5, see package fence) are few, circular and
+// configured over SMS or online commands. An Engine complements them with
+// any number of circle and polygon fences kept on the server, assigned per
+// IMEI (or to every device), and reports an enter or exit Event when a
+// positioned location packet (0x22, 0xA0) crosses a fence boundary.
+//
+// Fixes near a boundary jitter in and out of the fence. The engine only
+// changes the state of a device once a fix is Margin meters beyond the
+// boundary, on Confirmations consecutive fixes.
+//
+// Example usage:
+//
+//	engine := geofence.NewEngine(geofence.WithHandler(func(e geofence.Event) {
+//	    log.Printf("%s %s %s", e.IMEI, e.Transition, e.Fence.DisplayName())
+//	}))
+//	engine.AddFence(geofence.NewCircle("depot", types.MustNewCoordinates(-12.0464, -77.0428), 300))
+//	engine.Assign("359339073930520", "depot")
+//
+//	srv.OnLocation(func(s *server.Session, p packet.Packet) {
+//	    engine.Observe(s.IMEI(), p)
+//	})
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// ErrInvalidFence is wrapped by the errors of fences that are neither a
+// circle nor a polygon
+var ErrInvalidFence = errors.New("invalid geofence")
+
+// earthRadius is the mean Earth radius in meters, as in types.Coordinates.DistanceTo
+const earthRadius = 6371000.0
+
+// Fence is a circle (Center and Radius) or a polygon (at least 3 vertices)
+type Fence struct {
+	// ID identifies the fence in assignments and events
+	ID string `json:"id"`
+
+	// Name is a display name (optional)
+	Name string `json:"name,omitempty"`
+
+	// Center and Radius (meters) define a circle
+	Center *types.Coordinates `json:"center,omitempty"`
+	Radius float64            `json:"radius,omitempty"`
+
+	// Polygon are the vertices of a polygon, in order; the last connects to
+	// the first. Polygons must not cross the antimeridian.
+	Polygon []types.Coordinates `json:"polygon,omitempty"`
+}
+
+// NewCircle creates a circular fence
+func NewCircle(id string, center types.Coordinates, radius float64) Fence {
+	return Fence{ID: id, Center: &center, Radius: radius}
+}
+
+// NewPolygon creates a polygonal fence
+func NewPolygon(id string, vertices ...types.Coordinates) Fence {
+	return Fence{ID: id, Polygon: vertices}
+}
+
+// DisplayName returns the fence name, or its ID if no name is set
+func (f Fence) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.ID
+}
+
+// IsCircle reports whether the fence is a circle
+func (f Fence) IsCircle() bool {
+	return f.Center != nil
+}
+
+// Validate checks that the fence has an ID and exactly one valid shape
+func (f Fence) Validate() error {
+	switch {
+	case f.ID == "":
+		return fmt.Errorf("%w: missing id", ErrInvalidFence)
+	case f.Center != nil && len(f.Polygon) > 0:
+		return fmt.Errorf("%w %s: both a circle and a polygon", ErrInvalidFence, f.ID)
+	case f.Center != nil && (f.Radius <= 0 || math.IsNaN(f.Radius) || math.IsInf(f.Radius, 0)):
+		return fmt.Errorf("%w %s: radius must be positive, got %v", ErrInvalidFence, f.ID, f.Radius)
+	case f.Center == nil && len(f.Polygon) < 3:
+		return fmt.Errorf("%w %s: need a center and radius or at least 3 vertices", ErrInvalidFence, f.ID)
+	}
+	return nil
+}
+
+// Contains reports whether c is inside the fence (on the boundary counts)
+func (f Fence) Contains(c types.Coordinates) bool {
+	return f.Distance(c) <= 0
+}
+
+// Distance returns the distance in meters from c to the fence boundary:
+// negative inside the fence, positive outside
+func (f Fence) Distance(c types.Coordinates) float64 {
+	if f.IsCircle() {
+		return c.DistanceTo(*f.Center) - f.Radius
+	}
+	d := polygonEdgeDistance(f.Polygon, c)
+	if pointInPolygon(f.Polygon, c) {
+		return -d
+	}
+	return d
+}
+
+// pointInPolygon reports whether c is inside the polygon (ray casting on
+// signed degrees)
+func pointInPolygon(vertices []types.Coordinates, c types.Coordinates) bool {
+	x, y := c.SignedLongitude(), c.SignedLatitude()
+	inside := false
+	for i, j := 0, len(vertices)-1; i < len(vertices); j, i = i, i+1 {
+		xi, yi := vertices[i].SignedLongitude(), vertices[i].SignedLatitude()
+		xj, yj := vertices[j].SignedLongitude(), vertices[j].SignedLatitude()
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// polygonEdgeDistance returns the distance in meters from c to the nearest
+// polygon edge, in an equirectangular projection centered on c (accurate
+// for fences up to tens of kilometers)
+func polygonEdgeDistance(vertices []types.Coordinates, c types.Coordinates) float64 {
+	lat0 := c.SignedLatitude() * math.Pi / 180
+	project := func(v types.Coordinates) (x, y float64) {
+		x = (v.SignedLongitude() - c.SignedLongitude()) * math.Pi / 180 * math.Cos(lat0) * earthRadius
+		y = (v.SignedLatitude() - c.SignedLatitude()) * math.Pi / 180 * earthRadius
+		return x, y
+	}
+
+	best := math.Inf(1)
+	for i := range vertices {
+		ax, ay := project(vertices[i])
+		bx, by := project(vertices[(i+1)%len(vertices)])
+		best = min(best, segmentDistance(ax, ay, bx, by))
+	}
+	return best
+}
+
+// segmentDistance returns the distance from the origin to the segment a-b
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = max(0, min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
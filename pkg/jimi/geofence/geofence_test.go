@@ -0,0 +1,204 @@
+package geofence
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+var (
+	baseTime = time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+	depot    = types.MustNewCoordinates(-12.046400, -77.042800)
+)
+
+// north returns the point meters north of depot
+func north(meters float64) types.Coordinates {
+	return types.MustNewCoordinates(depot.SignedLatitude()+meters/111195, depot.SignedLongitude())
+}
+
+// fix builds a positioned location packet at c
+func fix(c types.Coordinates, minute int) *packet.LocationPacket {
+	return packet.NewLocationPacket(types.NewDateTime(baseTime.Add(time.Duration(minute)*time.Minute)), c, 30,
+		types.NewCourseStatus(0, true, true, c.IsEast, c.IsNorth))
+}
+
+// square is a polygon of about 1 km around depot
+func square() Fence {
+	lat, lon := depot.SignedLatitude(), depot.SignedLongitude()
+	const d = 0.0045
+	return NewPolygon("square",
+		types.MustNewCoordinates(lat-d, lon-d), types.MustNewCoordinates(lat-d, lon+d),
+		types.MustNewCoordinates(lat+d, lon+d), types.MustNewCoordinates(lat+d, lon-d))
+}
+
+func TestFence_Distance(t *testing.T) {
+	circle := NewCircle("depot", depot, 300)
+	tests := []struct {
+		name  string
+		fence Fence
+		at    types.Coordinates
+		want  float64
+	}{
+		{"circle center", circle, depot, -300},
+		{"circle outside", circle, north(500), 200},
+		{"polygon center", square(), depot, -489}, // nearest edges east and west
+		{"polygon outside", square(), north(600), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fence.Distance(tt.at); math.Abs(got-tt.want) > 2 {
+				t.Errorf("Distance() = %.1f, want %.1f", got, tt.want)
+			}
+			if tt.fence.Contains(tt.at) != (tt.want < 0) {
+				t.Errorf("Contains() = %v", !(tt.want < 0))
+			}
+		})
+	}
+}
+
+func TestFence_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		fence Fence
+		valid bool
+	}{
+		{"circle", NewCircle("a", depot, 100), true},
+		{"polygon", square(), true},
+		{"no id", NewCircle("", depot, 100), false},
+		{"no radius", NewCircle("a", depot, 0), false},
+		{"two vertices", NewPolygon("a", depot, north(10)), false},
+		{"both shapes", Fence{ID: "a", Center: &depot, Radius: 10, Polygon: square().Polygon}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fence.Validate()
+			if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidFence)) {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestEngine_Observe(t *testing.T) {
+	var handled []Event
+	engine := NewEngine(WithMargin(20), WithConfirmations(2), WithHandler(func(e Event) { handled = append(handled, e) }))
+	if err := engine.AddFence(NewCircle("depot", depot, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Assign(testIMEI, "depot"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		at   types.Coordinates
+		want Transition
+	}{
+		{"first fix seeds", north(100), ""},
+		{"jitter across the boundary", north(310), ""},
+		{"back inside", north(290), ""},
+		{"beyond the margin once", north(400), ""},
+		{"confirmed exit", north(500), Exit},
+		{"inside the margin", north(290), ""},
+		{"entering", north(200), ""},
+		{"confirmed enter", north(0), Enter},
+	}
+	for i, step := range steps {
+		events := engine.Observe(testIMEI, fix(step.at, i))
+		var got Transition
+		if len(events) == 1 {
+			got = events[0].Transition
+		}
+		if len(events) > 1 || got != step.want {
+			t.Errorf("%s: expected %q, got %v", step.name, step.want, events)
+		}
+	}
+	if len(handled) != 2 || handled[0].Fence.ID != "depot" || !handled[1].Time.Equal(baseTime.Add(7*time.Minute)) {
+		t.Errorf("Unexpected events %v", handled)
+	}
+	if got := engine.Inside(testIMEI); !slices.Equal(got, []string{"depot"}) {
+		t.Errorf("Expected inside depot, got %v", got)
+	}
+}
+
+func TestEngine_Assignments(t *testing.T) {
+	engine := NewEngine(WithMargin(0))
+	engine.AddFence(NewCircle("depot", depot, 300))
+	engine.AddFence(square())
+	if err := engine.Assign(testIMEI, "nowhere"); !errors.Is(err, ErrUnknownFence) {
+		t.Errorf("Expected ErrUnknownFence, got %v", err)
+	}
+	engine.Assign(AllDevices, "square")
+	engine.Assign(testIMEI, "depot", "square")
+
+	ids := func(fences []Fence) (out []string) {
+		for _, f := range fences {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+	if got := ids(engine.Assigned(testIMEI)); !slices.Equal(got, []string{"depot", "square"}) {
+		t.Errorf("Unexpected fences %v", got)
+	}
+	if got := ids(engine.Assigned("868020041234567")); !slices.Equal(got, []string{"square"}) {
+		t.Errorf("Unexpected fences of another device %v", got)
+	}
+
+	// Leaving the circle but not the square, of a device known to both
+	engine.Observe(testIMEI, fix(depot, 0))
+	events := engine.Observe(testIMEI, fix(north(400), 1))
+	if len(events) != 1 || events[0].Fence.ID != "depot" || events[0].Transition != Exit {
+		t.Errorf("Unexpected events %v", events)
+	}
+
+	// Unpositioned fixes, other packets and devices without login are ignored
+	unpositioned := fix(depot, 2)
+	unpositioned.CourseStatus = types.NewCourseStatus(0, true, false, false, false)
+	if engine.Observe(testIMEI, unpositioned) != nil || engine.Observe(testIMEI, &packet.HeartbeatPacket{}) != nil ||
+		engine.Observe("", fix(depot, 2)) != nil {
+		t.Error("Expected no events")
+	}
+
+	engine.RemoveFence("square")
+	if got := ids(engine.Assigned(testIMEI)); !slices.Equal(got, []string{"depot"}) {
+		t.Errorf("Expected the removed fence to be unassigned, got %v", got)
+	}
+	engine.Unassign(testIMEI, "depot")
+	if len(engine.Assigned(testIMEI)) != 0 || len(engine.Inside(testIMEI)) != 0 {
+		t.Error("Expected no fences left")
+	}
+}
+
+func TestEngine_Load(t *testing.T) {
+	engine := NewEngine()
+	err := engine.Load(strings.NewReader(`{
+		"fences": [
+			{"id": "depot", "name": "Depot", "center": {"latitude": -12.0464, "longitude": -77.0428}, "radius": 300},
+			{"id": "yard", "polygon": [{"latitude": 0, "longitude": 0}, {"latitude": 0, "longitude": 1}, {"latitude": 1, "longitude": 1}]}
+		],
+		"devices": {"*": ["depot"], "359339073930523": ["yard"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := engine.Fence("depot"); !ok || f.DisplayName() != "Depot" || !f.IsCircle() {
+		t.Errorf("Unexpected fence %+v", f)
+	}
+	if len(engine.Fences()) != 2 || len(engine.Assigned(testIMEI)) != 2 {
+		t.Errorf("Unexpected fences %v", engine.Fences())
+	}
+
+	for _, cfg := range []string{`{"fences": [{"id": "a"}]}`, `{"devices": {"1": ["a"]}}`, `{`} {
+		if err := NewEngine().Load(strings.NewReader(cfg)); err == nil {
+			t.Errorf("Expected an error loading %s", cfg)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package geojson exports decoded location streams as GeoJSON (RFC 7946),
+// for quick visualization in Kepler.gl, QGIS or geojson.io.
+//
+// A FeatureCollectionWriter accumulates the positioned fixes of every device
+// and writes one FeatureCollection holding, per IMEI, a LineString track
+// followed by one Point feature per fix (speed, ACC, alarm... as properties).
+//
+// Example usage:
+//
+//	w := geojson.NewFeatureCollectionWriter()
+//	for _, pkt := range packets {
+//	    w.Add(imei, pkt)
+//	}
+//	if _, err := w.WriteTo(file); err != nil {
+//	    log.Fatal(err)
+//	}
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Feature is a GeoJSON Feature
+type Feature = packet.GeoJSONFeature
+
+// Geometry is a GeoJSON Point or LineString
+type Geometry = packet.GeoJSONGeometry
+
+// FeatureCollection is a GeoJSON FeatureCollection
+type FeatureCollection struct {
+	// Type is always "FeatureCollection"
+	Type string `json:"type"`
+
+	// Features are the tracks and points
+	Features []Feature `json:"features"`
+}
+
+// featurer is implemented by packets that convert to a GeoJSON feature
+type featurer interface {
+	ToGeoJSONFeature() packet.GeoJSONFeature
+}
+
+// Option configures a FeatureCollectionWriter
+type Option func(*FeatureCollectionWriter)
+
+// WithPoints sets whether a Point feature is written for every fix.
+// Default: true. Without points only the tracks are written.
+func WithPoints(enabled bool) Option {
+	return func(w *FeatureCollectionWriter) {
+		w.points = enabled
+	}
+}
+
+// WithMaxFixes bounds the fixes kept per device; the oldest are dropped.
+// Default: 0 (unbounded).
+func WithMaxFixes(n int) Option {
+	return func(w *FeatureCollectionWriter) {
+		if n >= 0 {
+			w.maxFixes = n
+		}
+	}
+}
+
+// FeatureCollectionWriter accumulates fixes per IMEI and writes them as a
+// FeatureCollection. It is safe for concurrent use.
+type FeatureCollectionWriter struct {
+	points   bool
+	maxFixes int
+
+	mu    sync.Mutex
+	fixes map[string][]fix
+}
+
+// fix is an accumulated feature with its device time for ordering
+type fix struct {
+	time    time.Time
+	feature Feature
+}
+
+// NewFeatureCollectionWriter creates an empty writer
+func NewFeatureCollectionWriter(opts ...Option) *FeatureCollectionWriter {
+	w := &FeatureCollectionWriter{
+		points: true,
+		fixes:  make(map[string][]fix),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Add accumulates the fix carried by a packet (location and alarm packets).
+// Returns false if the packet carries no positioned fix.
+func (w *FeatureCollectionWriter) Add(imei string, p packet.Packet) bool {
+	f, ok := p.(featurer)
+	if !ok {
+		return false
+	}
+	loc, ok := p.(packet.PacketWithLocation)
+	if !ok || !loc.HasLocation() || !loc.IsPositioned() {
+		return false
+	}
+	feature := f.ToGeoJSONFeature()
+	feature.Properties["imei"] = imei
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fixes := append(w.fixes[imei], fix{time: p.Timestamp(), feature: feature})
+	if w.maxFixes > 0 && len(fixes) > w.maxFixes {
+		fixes = fixes[len(fixes)-w.maxFixes:]
+	}
+	w.fixes[imei] = fixes
+	return true
+}
+
+// Len returns the number of accumulated fixes
+func (w *FeatureCollectionWriter) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := 0
+	for _, fixes := range w.fixes {
+		n += len(fixes)
+	}
+	return n
+}
+
+// Reset drops all accumulated fixes
+func (w *FeatureCollectionWriter) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fixes = make(map[string][]fix)
+}
+
+// FeatureCollection builds the collection: devices sorted by IMEI, each with
+// its track (when it has two fixes or more) followed by its points. Fixes
+// are ordered by device time, so re-uploaded fixes land in place.
+func (w *FeatureCollectionWriter) FeatureCollection() FeatureCollection {
+	w.mu.Lock()
+	imeis := make([]string, 0, len(w.fixes))
+	devices := make(map[string][]fix, len(w.fixes))
+	for imei, fixes := range w.fixes {
+		imeis = append(imeis, imei)
+		devices[imei] = append([]fix(nil), fixes...)
+	}
+	w.mu.Unlock()
+
+	sort.Strings(imeis)
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, imei := range imeis {
+		fixes := devices[imei]
+		sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].time.Before(fixes[j].time) })
+
+		if len(fixes) >= 2 {
+			fc.Features = append(fc.Features, track(imei, fixes))
+		}
+		if w.points {
+			for _, f := range fixes {
+				fc.Features = append(fc.Features, f.feature)
+			}
+		}
+	}
+	return fc
+}
+
+// WriteTo writes the collection as JSON
+func (w *FeatureCollectionWriter) WriteTo(out io.Writer) (int64, error) {
+	data, err := json.Marshal(w.FeatureCollection())
+	if err != nil {
+		return 0, err
+	}
+	n, err := out.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// track returns the LineString of the fixes of one device
+func track(imei string, fixes []fix) Feature {
+	line := make([][2]float64, len(fixes))
+	for i, f := range fixes {
+		line[i] = f.feature.Geometry.Coordinates.([2]float64)
+	}
+	return Feature{
+		Type:     "Feature",
+		Geometry: Geometry{Type: "LineString", Coordinates: line},
+		Properties: map[string]any{
+			"imei":  imei,
+			"fixes": len(fixes),
+			"start": fixes[0].time.UTC().Format(time.RFC3339),
+			"end":   fixes[len(fixes)-1].time.UTC().Format(time.RFC3339),
+			"type":  "Track",
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const (
+	imeiA = "359339073930523"
+	imeiB = "359339073930524"
+)
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func location(t *testing.T, minute int, lat, lon float64, positioned bool) *packet.LocationPacket {
+	t.Helper()
+	coords, err := types.NewCoordinates(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := packet.NewLocationPacket(types.DateTime{Time: start.Add(time.Duration(minute) * time.Minute)}, coords, 40,
+		types.NewCourseStatus(90, true, positioned, coords.IsEast, coords.IsNorth))
+	p.ACC = true
+	return p
+}
+
+func alarm(t *testing.T, minute int, lat, lon float64) *packet.AlarmPacket {
+	t.Helper()
+	coords, err := types.NewCoordinates(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := packet.NewAlarmPacket(types.DateTime{Time: start.Add(time.Duration(minute) * time.Minute)}, coords, protocol.AlarmSOS)
+	p.CourseStatus = types.NewCourseStatus(0, true, true, coords.IsEast, coords.IsNorth)
+	return p
+}
+
+func TestFeatureCollectionWriter_Add(t *testing.T) {
+	tests := []struct {
+		name string
+		p    packet.Packet
+		want bool
+	}{
+		{"positioned location", location(t, 0, 22.5, 113.9, true), true},
+		{"4G location", &packet.Location4GPacket{LocationPacket: *location(t, 0, 22.5, 113.9, true)}, true},
+		{"alarm", alarm(t, 0, 22.5, 113.9), true},
+		{"not positioned", location(t, 0, 22.5, 113.9, false), false},
+		{"heartbeat", &packet.HeartbeatPacket{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewFeatureCollectionWriter()
+			if got := w.Add(imeiA, tt.p); got != tt.want {
+				t.Errorf("Add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeatureCollectionWriter_FeatureCollection(t *testing.T) {
+	w := NewFeatureCollectionWriter()
+	// Out of device-time order, as with re-uploaded fixes
+	w.Add(imeiB, location(t, 1, 22.6, 114.0, true))
+	w.Add(imeiA, location(t, 2, 22.7, 114.1, true))
+	w.Add(imeiA, alarm(t, 1, -33.9, -151.2))
+	w.Add(imeiA, location(t, 0, 22.5, 113.9, true))
+
+	fc := w.FeatureCollection()
+	if fc.Type != "FeatureCollection" {
+		t.Errorf("Type = %q", fc.Type)
+	}
+	// imeiA: track + 3 points, imeiB: 1 point (no track for a single fix)
+	if len(fc.Features) != 5 {
+		t.Fatalf("features = %d, want 5", len(fc.Features))
+	}
+
+	tr := fc.Features[0]
+	if tr.Geometry.Type != "LineString" || tr.Properties["imei"] != imeiA || tr.Properties["fixes"] != 3 {
+		t.Errorf("track = %+v", tr)
+	}
+	line := tr.Geometry.Coordinates.([][2]float64)
+	wantLine := [][2]float64{{113.9, 22.5}, {-151.2, -33.9}, {114.1, 22.7}}
+	for i := range wantLine {
+		if line[i] != wantLine[i] {
+			t.Errorf("track[%d] = %v, want %v", i, line[i], wantLine[i])
+		}
+	}
+
+	sos := fc.Features[2]
+	if sos.Geometry.Type != "Point" || sos.Properties["alarm"] != "SOS" || sos.Properties["imei"] != imeiA {
+		t.Errorf("alarm point = %+v", sos)
+	}
+	last := fc.Features[4]
+	if last.Properties["imei"] != imeiB || last.Properties["acc"] != true || last.Properties["speed"] != uint16(40) {
+		t.Errorf("last point = %+v", last)
+	}
+}
+
+func TestFeatureCollectionWriter_Options(t *testing.T) {
+	w := NewFeatureCollectionWriter(WithPoints(false), WithMaxFixes(2))
+	for i := range 5 {
+		w.Add(imeiA, location(t, i, 22.5+float64(i)/100, 113.9, true))
+	}
+	if w.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", w.Len())
+	}
+
+	fc := w.FeatureCollection()
+	if len(fc.Features) != 1 || fc.Features[0].Geometry.Type != "LineString" {
+		t.Fatalf("features = %+v, want one track", fc.Features)
+	}
+	if got := fc.Features[0].Properties["start"]; got != "2026-01-01T12:03:00Z" {
+		t.Errorf("start = %v, want the 4th fix", got)
+	}
+
+	w.Reset()
+	if w.Len() != 0 {
+		t.Errorf("Len() after Reset = %d", w.Len())
+	}
+}
+
+func TestFeatureCollectionWriter_WriteTo(t *testing.T) {
+	w := NewFeatureCollectionWriter()
+	w.Add(imeiA, location(t, 0, 22.5, 113.9, true))
+	w.Add(imeiA, location(t, 1, 22.6, 114.0, true))
+
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if out.Type != "FeatureCollection" || len(out.Features) != 3 {
+		t.Fatalf("collection = %+v", out)
+	}
+	if got := string(out.Features[0].Geometry.Coordinates); got != "[[113.9,22.5],[114,22.6]]" {
+		t.Errorf("track coordinates = %s", got)
+	}
+	if got := out.Features[1].Properties["time"]; got != "2026-01-01T12:00:00Z" {
+		t.Errorf("time = %v", got)
+	}
+
+	// An empty collection still has a features array
+	buf.Reset()
+	NewFeatureCollectionWriter().WriteTo(&buf)
+	if got := buf.String(); got != `{"type":"FeatureCollection","features":[]}`+"\n" {
+		t.Errorf("empty collection = %s", got)
+	}
+}
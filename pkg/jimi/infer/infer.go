@@ -0,0 +1,474 @@
+// Package infer suggests field layouts for unknown protocol numbers.
+//
+// Given many sample packets of one protocol number, Infer looks for the
+// patterns shared by the known JM-VL03 packet types: a 6-byte YY MM DD hh mm ss
+// date-time, latitude/longitude as big-endian uint32 in 1/1800000 degree
+// units, counters, constant bytes, and the trailing information serial
+// number. The result is a starting point for writing a parser, not a parser.
+//
+// Example usage:
+//
+//	layout, err := infer.Infer(samples) // complete frames of one protocol
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Print(layout)
+//
+// Offsets are relative to the first content byte (the byte after the protocol
+// number). The information serial number follows the content, so its offset is
+// the content length.
+package infer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Kind is the suggested meaning of a field
+type Kind string
+
+const (
+	// KindDateTime is a 6-byte YY MM DD hh mm ss date-time
+	KindDateTime Kind = "datetime"
+
+	// KindLatitude is a 4-byte latitude (uint32 / 1800000 degrees)
+	KindLatitude Kind = "latitude"
+
+	// KindLongitude is a 4-byte longitude (uint32 / 1800000 degrees)
+	KindLongitude Kind = "longitude"
+
+	// KindSerial is the 2-byte information serial number
+	KindSerial Kind = "serial"
+
+	// KindCounter is a value that grows across samples (odometer, sequence)
+	KindCounter Kind = "counter"
+
+	// KindConstant is a run of bytes with the same value in all samples
+	KindConstant Kind = "constant"
+
+	// KindUnknown is everything else
+	KindUnknown Kind = "unknown"
+)
+
+// minValidRatio is the share of samples a pattern must match
+const minValidRatio = 0.9
+
+// coordinateScale converts raw coordinates to degrees
+const coordinateScale = 1800000.0
+
+// Errors returned by Infer
+var (
+	ErrNoSamples      = errors.New("infer: no samples")
+	ErrMixedProtocols = errors.New("infer: samples have different protocol numbers")
+)
+
+// Field is one suggested field
+type Field struct {
+	// Offset is the position relative to the first content byte
+	Offset int
+
+	// Size is the field length in bytes
+	Size int
+
+	// Kind is the suggested meaning
+	Kind Kind
+
+	// Confidence is the share of samples matching the pattern (0-1)
+	Confidence float64
+
+	// Example is the value in the first sample (hex, or decoded when possible)
+	Example string
+}
+
+// Layout is the suggested layout of one protocol number
+type Layout struct {
+	// Protocol is the protocol number of the samples
+	Protocol byte
+
+	// Samples is the number of samples analyzed
+	Samples int
+
+	// ContentLength is the content length of the analyzed samples
+	ContentLength int
+
+	// Fields covers the content and the serial number, ordered by offset
+	Fields []Field
+
+	// Notes explains what was skipped or assumed
+	Notes []string
+}
+
+// frame is the part of a sample Infer looks at
+type frame struct {
+	content []byte
+	serial  uint16
+}
+
+// Infer suggests a layout from complete frames of one protocol number.
+// Samples should be in the order they were received, for counter detection.
+// Only samples with the most common length are analyzed.
+func Infer(samples [][]byte) (Layout, error) {
+	if len(samples) == 0 {
+		return Layout{}, ErrNoSamples
+	}
+
+	var layout Layout
+	byLength := make(map[int][]frame)
+	skipped := 0
+
+	for _, data := range samples {
+		proto, content, serial, err := split(data)
+		if err != nil {
+			skipped++
+			continue
+		}
+		if layout.Samples == 0 {
+			layout.Protocol = proto
+		} else if proto != layout.Protocol {
+			return Layout{}, fmt.Errorf("%w: 0x%02X and 0x%02X", ErrMixedProtocols, layout.Protocol, proto)
+		}
+		layout.Samples++
+		byLength[len(content)] = append(byLength[len(content)], frame{content, serial})
+	}
+	if layout.Samples == 0 {
+		return Layout{}, fmt.Errorf("%w: no valid frames", ErrNoSamples)
+	}
+	if skipped > 0 {
+		layout.Notes = append(layout.Notes, fmt.Sprintf("%d malformed samples skipped", skipped))
+	}
+
+	frames := mostCommon(byLength)
+	layout.ContentLength = len(frames[0].content)
+	if len(byLength) > 1 {
+		layout.Notes = append(layout.Notes, fmt.Sprintf(
+			"content lengths vary (%s); analyzed the %d samples of %d bytes",
+			lengths(byLength), len(frames), layout.ContentLength))
+	}
+	layout.Samples = len(frames)
+	if len(frames) == 1 {
+		layout.Notes = append(layout.Notes, "single sample: constants and counters cannot be detected")
+	}
+
+	used := make([]bool, layout.ContentLength)
+	var fields []Field
+
+	fields = append(fields, findDateTimes(frames, used)...)
+	fields = append(fields, findCoordinates(frames, used)...)
+	fields = append(fields, findCounters(frames, used)...)
+	fields = append(fields, findConstants(frames, used)...)
+	fields = append(fields, unknownRuns(frames, used)...)
+	fields = append(fields, serialField(frames))
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	layout.Fields = fields
+	return layout, nil
+}
+
+// String renders the layout as a table
+func (l Layout) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Protocol 0x%02X: %d samples, %d content bytes\n", l.Protocol, l.Samples, l.ContentLength)
+	fmt.Fprintf(&b, "  %-6s %-4s %-10s %-5s %s\n", "OFFSET", "SIZE", "KIND", "CONF", "EXAMPLE")
+	for _, f := range l.Fields {
+		fmt.Fprintf(&b, "  %-6d %-4d %-10s %4.0f%% %s\n", f.Offset, f.Size, f.Kind, f.Confidence*100, f.Example)
+	}
+	for _, n := range l.Notes {
+		fmt.Fprintf(&b, "  note: %s\n", n)
+	}
+	return b.String()
+}
+
+// split extracts protocol, content and serial number from a frame
+func split(data []byte) (byte, []byte, uint16, error) {
+	if len(data) < protocol.MinPacketSize {
+		return 0, nil, 0, errors.New("frame too short")
+	}
+
+	header := 4 // start(2) + length(1) + protocol(1)
+	switch uint16(data[0])<<8 | uint16(data[1]) {
+	case protocol.StartBitShort:
+	case protocol.StartBitLong:
+		header = 5
+	default:
+		return 0, nil, 0, errors.New("invalid start bit")
+	}
+
+	end := len(data) - 6 // serial(2) + crc(2) + stop(2)
+	if end < header {
+		return 0, nil, 0, errors.New("frame too short")
+	}
+	serial := uint16(data[end])<<8 | uint16(data[end+1])
+	return data[header-1], data[header:end], serial, nil
+}
+
+// mostCommon returns the largest group (the shortest length on ties)
+func mostCommon(byLength map[int][]frame) []frame {
+	best := -1
+	for n, group := range byLength {
+		if best < 0 || len(group) > len(byLength[best]) || (len(group) == len(byLength[best]) && n < best) {
+			best = n
+		}
+	}
+	return byLength[best]
+}
+
+// lengths formats the length histogram
+func lengths(byLength map[int][]frame) string {
+	keys := make([]int, 0, len(byLength))
+	for n := range byLength {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	parts := make([]string, len(keys))
+	for i, n := range keys {
+		parts[i] = fmt.Sprintf("%d bytes x%d", n, len(byLength[n]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// free reports whether used[off:off+size] is unassigned
+func free(used []bool, off, size int) bool {
+	if off+size > len(used) {
+		return false
+	}
+	for _, u := range used[off : off+size] {
+		if u {
+			return false
+		}
+	}
+	return true
+}
+
+// claim marks used[off:off+size] as assigned
+func claim(used []bool, off, size int) {
+	for i := off; i < off+size; i++ {
+		used[i] = true
+	}
+}
+
+// ratio returns the share of frames for which match is true
+func ratio(frames []frame, match func(c []byte) bool) float64 {
+	n := 0
+	for _, f := range frames {
+		if match(f.content) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(frames))
+}
+
+// findDateTimes looks for YY MM DD hh mm ss
+func findDateTimes(frames []frame, used []bool) []Field {
+	var fields []Field
+	for off := 0; off+6 <= len(used); off++ {
+		if !free(used, off, 6) {
+			continue
+		}
+		conf := ratio(frames, func(c []byte) bool { return isDateTime(c[off : off+6]) })
+		if conf < minValidRatio {
+			continue
+		}
+		claim(used, off, 6)
+		c := frames[0].content[off : off+6]
+		fields = append(fields, Field{
+			Offset:     off,
+			Size:       6,
+			Kind:       KindDateTime,
+			Confidence: conf,
+			Example:    fmt.Sprintf("20%02d-%02d-%02d %02d:%02d:%02d", c[0], c[1], c[2], c[3], c[4], c[5]),
+		})
+	}
+	return fields
+}
+
+// isDateTime reports whether b is a plausible date-time (years 2010-2050)
+func isDateTime(b []byte) bool {
+	return b[0] >= 10 && b[0] <= 50 &&
+		b[1] >= 1 && b[1] <= 12 &&
+		b[2] >= 1 && b[2] <= 31 &&
+		b[3] < 24 && b[4] < 60 && b[5] < 60
+}
+
+// findCoordinates looks for a latitude followed by a longitude
+func findCoordinates(frames []frame, used []bool) []Field {
+	var fields []Field
+	for off := 0; off+8 <= len(used); off++ {
+		if !free(used, off, 8) {
+			continue
+		}
+		conf := ratio(frames, func(c []byte) bool {
+			lat, lon := be32(c[off:]), be32(c[off+4:])
+			return lat > 0 && lon > 0 && float64(lat) <= 90*coordinateScale && float64(lon) <= 180*coordinateScale
+		})
+		if conf < minValidRatio {
+			continue
+		}
+		claim(used, off, 8)
+		c := frames[0].content
+		fields = append(fields,
+			Field{off, 4, KindLatitude, conf, fmt.Sprintf("%.6f", float64(be32(c[off:]))/coordinateScale)},
+			Field{off + 4, 4, KindLongitude, conf, fmt.Sprintf("%.6f", float64(be32(c[off+4:]))/coordinateScale)},
+		)
+	}
+	return fields
+}
+
+// findCounters looks for 4- and 2-byte values that never decrease.
+// Overlapping windows of one counter all grow; the one with the smallest
+// value (most leading zero bytes) is the actual field.
+func findCounters(frames []frame, used []bool) []Field {
+	if len(frames) < 3 {
+		return nil
+	}
+	var fields []Field
+	for _, size := range []int{4, 2} {
+		var candidates []int
+		for off := 0; off+size <= len(used); off++ {
+			if free(used, off, size) && isCounter(frames, off, size) {
+				candidates = append(candidates, off)
+			}
+		}
+		value := func(off int) uint64 { return beN(frames[0].content[off : off+size]) }
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return value(candidates[i]) < value(candidates[j])
+		})
+
+		for _, off := range candidates {
+			if !free(used, off, size) {
+				continue
+			}
+			claim(used, off, size)
+			fields = append(fields, Field{
+				Offset:     off,
+				Size:       size,
+				Kind:       KindCounter,
+				Confidence: 1,
+				Example:    fmt.Sprintf("%d", value(off)),
+			})
+		}
+	}
+	return fields
+}
+
+// isCounter reports whether a field grows in small steps and is not constant
+func isCounter(frames []frame, off, size int) bool {
+	first := beN(frames[0].content[off : off+size])
+	last := beN(frames[len(frames)-1].content[off : off+size])
+	if first == last || first == 0 {
+		return false
+	}
+
+	prev := first
+	for _, f := range frames[1:] {
+		v := beN(f.content[off : off+size])
+		if v < prev {
+			return false
+		}
+		prev = v
+	}
+
+	// A counter moves slowly relative to its magnitude; random bytes do not
+	return last-first < last/2+uint64(len(frames))
+}
+
+// findConstants groups bytes equal in all samples into runs
+func findConstants(frames []frame, used []bool) []Field {
+	if len(frames) < 2 {
+		return nil
+	}
+	var fields []Field
+	for off := 0; off < len(used); {
+		if used[off] || !constantAt(frames, off) {
+			off++
+			continue
+		}
+		end := off + 1
+		for end < len(used) && !used[end] && constantAt(frames, end) {
+			end++
+		}
+		claim(used, off, end-off)
+		fields = append(fields, Field{
+			Offset:     off,
+			Size:       end - off,
+			Kind:       KindConstant,
+			Confidence: 1,
+			Example:    strings.ToUpper(hex.EncodeToString(frames[0].content[off:end])),
+		})
+		off = end
+	}
+	return fields
+}
+
+// constantAt reports whether byte off is the same in all frames
+func constantAt(frames []frame, off int) bool {
+	for _, f := range frames[1:] {
+		if f.content[off] != frames[0].content[off] {
+			return false
+		}
+	}
+	return true
+}
+
+// unknownRuns groups the remaining bytes
+func unknownRuns(frames []frame, used []bool) []Field {
+	var fields []Field
+	for off := 0; off < len(used); {
+		if used[off] {
+			off++
+			continue
+		}
+		end := off + 1
+		for end < len(used) && !used[end] {
+			end++
+		}
+		fields = append(fields, Field{
+			Offset:  off,
+			Size:    end - off,
+			Kind:    KindUnknown,
+			Example: strings.ToUpper(hex.EncodeToString(frames[0].content[off:end])),
+		})
+		off = end
+	}
+	return fields
+}
+
+// serialField describes the information serial number after the content;
+// confidence is the share of consecutive samples where it increases
+func serialField(frames []frame) Field {
+	conf := 1.0
+	if len(frames) > 1 {
+		n := 0
+		for i := 1; i < len(frames); i++ {
+			if frames[i].serial > frames[i-1].serial {
+				n++
+			}
+		}
+		conf = float64(n) / float64(len(frames)-1)
+	}
+	return Field{
+		Offset:     len(frames[0].content),
+		Size:       2,
+		Kind:       KindSerial,
+		Confidence: conf,
+		Example:    fmt.Sprintf("%d", frames[0].serial),
+	}
+}
+
+// be32 reads a big-endian uint32
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// beN reads a big-endian unsigned integer of len(b) bytes
+func beN(b []byte) uint64 {
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package infer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/validator"
+)
+
+// buildFrame wraps content in a short frame with a valid CRC
+func buildFrame(protocolNum byte, content []byte, serial uint16) []byte {
+	frame := []byte{0x78, 0x78, byte(1 + len(content) + 4), protocolNum}
+	frame = append(frame, content...)
+	frame = append(frame, byte(serial>>8), byte(serial))
+	frame = validator.AppendCRC(frame)
+	return append(frame, 0x0D, 0x0A)
+}
+
+// locationLike builds content shaped like a location packet with a firmware tag
+func locationLike(i int) []byte {
+	lat := uint32(41600024 + i*37)
+	lon := uint32(205936736 - i*53)
+	odometer := uint32(120000 + i*15)
+	return []byte{
+		24, 6, 15, 14, byte(30 + i), byte(i * 7 % 60), // datetime
+		0xC9,                                                        // GPS info
+		byte(lat >> 24), byte(lat >> 16), byte(lat >> 8), byte(lat), // latitude
+		byte(lon >> 24), byte(lon >> 16), byte(lon >> 8), byte(lon), // longitude
+		0xAB, 0xCD, // firmware tag
+		byte(odometer >> 24), byte(odometer >> 16), byte(odometer >> 8), byte(odometer),
+		byte(i * 91), byte(i * 13), // noise
+	}
+}
+
+func TestInfer_LocationLikeLayout(t *testing.T) {
+	var samples [][]byte
+	for i := 0; i < 10; i++ {
+		samples = append(samples, buildFrame(0x99, locationLike(i), uint16(100+i)))
+	}
+
+	layout, err := Infer(samples)
+	if err != nil {
+		t.Fatalf("Infer failed: %v", err)
+	}
+	if layout.Protocol != 0x99 || layout.Samples != 10 || layout.ContentLength != 23 {
+		t.Fatalf("Unexpected layout header: %+v", layout)
+	}
+
+	want := map[int]Kind{
+		0:  KindDateTime,
+		7:  KindLatitude,
+		11: KindLongitude,
+		15: KindConstant,
+		17: KindCounter,
+		23: KindSerial,
+	}
+	got := make(map[int]Field)
+	for _, f := range layout.Fields {
+		got[f.Offset] = f
+	}
+	for off, kind := range want {
+		if f, ok := got[off]; !ok || f.Kind != kind {
+			t.Errorf("Expected %s at offset %d, got %+v", kind, off, got[off])
+		}
+	}
+	if f := got[0]; f.Example != "2024-06-15 14:30:00" {
+		t.Errorf("Unexpected datetime example %q", f.Example)
+	}
+	if f := got[23]; f.Confidence != 1 {
+		t.Errorf("Expected increasing serial, got confidence %v", f.Confidence)
+	}
+
+	// Fields must tile the content without gaps or overlaps
+	next := 0
+	for _, f := range layout.Fields {
+		if f.Offset != next {
+			t.Fatalf("Gap or overlap at offset %d (field starts at %d)", next, f.Offset)
+		}
+		next += f.Size
+	}
+	if next != layout.ContentLength+2 {
+		t.Errorf("Expected fields to cover %d bytes, covered %d", layout.ContentLength+2, next)
+	}
+}
+
+func TestInfer_MostCommonLength(t *testing.T) {
+	samples := [][]byte{
+		buildFrame(0x99, []byte{1, 2, 3}, 1),
+		buildFrame(0x99, []byte{1, 2, 3, 4}, 2),
+		buildFrame(0x99, []byte{1, 2, 3, 5}, 3),
+		{0x00, 0x01},
+	}
+
+	layout, err := Infer(samples)
+	if err != nil {
+		t.Fatalf("Infer failed: %v", err)
+	}
+	if layout.ContentLength != 4 || layout.Samples != 2 {
+		t.Errorf("Expected 2 samples of 4 bytes, got %d of %d", layout.Samples, layout.ContentLength)
+	}
+	if len(layout.Notes) != 2 {
+		t.Errorf("Expected notes for malformed and varying samples, got %v", layout.Notes)
+	}
+}
+
+func TestInfer_Errors(t *testing.T) {
+	if _, err := Infer(nil); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("Expected ErrNoSamples, got %v", err)
+	}
+
+	mixed := [][]byte{buildFrame(0x99, []byte{1}, 1), buildFrame(0x9A, []byte{1}, 2)}
+	if _, err := Infer(mixed); !errors.Is(err, ErrMixedProtocols) {
+		t.Errorf("Expected ErrMixedProtocols, got %v", err)
+	}
+}
@@ -0,0 +1,426 @@
+// Package jamming runs a response playbook when a device is likely being
+// jammed or attacked through a rogue base station.
+//
+// Two situations start the playbook:
+//   - the device raises AlarmRogueBaseStation (0x17)
+//   - the device suddenly falls back to LBS-only uploads (0x28/0xA1) after
+//     having a GPS fix, while its GSM signal is degraded, which is what GPS
+//     jammers usually look like from the server
+//
+// The playbook is a list of actions: notify (through a Notifier), request an
+// immediate location (WHERE#) and arm the defense mode (DEFENSE,1#). Commands
+// go through a Commander, which *server.Server implements.
+//
+// Example usage:
+//
+//	pb := jamming.NewPlaybook(
+//	    jamming.WithCommander(srv),
+//	    jamming.WithNotifier(jamming.NotifierFunc(func(ctx context.Context, inc jamming.Incident) error {
+//	        return pager.Send(ctx, inc.String())
+//	    })),
+//	    jamming.WithActions(jamming.ActionNotify, jamming.ActionRequestLocation, jamming.ActionArmDefense),
+//	)
+//
+//	srv.OnPacket(func(s *server.Session, p packet.Packet) {
+//	    pb.Observe(ctx, s.IMEI(), p)
+//	})
+package jamming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Default detection settings
+const (
+	// DefaultCooldown is the minimum time between two playbook runs for a device
+	DefaultCooldown = 10 * time.Minute
+
+	// DefaultLBSOnlyUploads is the number of consecutive LBS-only uploads
+	// after a GPS fix that count as a fallback
+	DefaultLBSOnlyUploads = 3
+
+	// DefaultDegradedSignal is the GSM level at or below which the signal is degraded
+	DefaultDegradedSignal = protocol.SignalWeak
+)
+
+// ServerFlag is the server flag of the commands sent by the playbook ("JAM\0")
+const ServerFlag uint32 = 0x4A414D00
+
+// Trigger identifies what started a playbook run
+type Trigger int
+
+const (
+	TriggerRogueBaseStation Trigger = iota // AlarmRogueBaseStation alarm
+	TriggerLBSFallback                     // LBS-only uploads with a degraded GSM signal
+)
+
+// String returns the human-readable trigger name
+func (t Trigger) String() string {
+	switch t {
+	case TriggerRogueBaseStation:
+		return "Rogue Base Station"
+	case TriggerLBSFallback:
+		return "LBS Fallback"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(t))
+	}
+}
+
+// Action is one step of the playbook
+type Action int
+
+const (
+	ActionNotify          Action = iota // Notify through the Notifier
+	ActionRequestLocation               // Send WHERE# for an immediate location
+	ActionArmDefense                    // Send DEFENSE,1# to arm the device
+)
+
+// String returns the human-readable action name
+func (a Action) String() string {
+	switch a {
+	case ActionNotify:
+		return "Notify"
+	case ActionRequestLocation:
+		return "Request Location"
+	case ActionArmDefense:
+		return "Arm Defense"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(a))
+	}
+}
+
+// Incident is a detected jamming or rogue base station situation
+type Incident struct {
+	// IMEI identifies the device
+	IMEI string
+
+	// Trigger tells what started the playbook
+	Trigger Trigger
+
+	// GSMSignal is the last reported GSM signal strength
+	GSMSignal protocol.GSMSignalStrength
+
+	// Time is when the incident was detected
+	Time time.Time
+
+	// Packet is the packet that triggered the playbook
+	Packet packet.Packet
+}
+
+// String returns a human-readable representation
+func (i Incident) String() string {
+	return fmt.Sprintf("JammingIncident{IMEI: %s, Trigger: %s, GSM: %s, Time: %s}",
+		i.IMEI, i.Trigger, i.GSMSignal, i.Time.Format(time.RFC3339))
+}
+
+// Commander sends online commands to devices. *server.Server implements it.
+type Commander interface {
+	SendCommand(imei string, serverFlag uint32, command string) error
+}
+
+// Notifier delivers incidents to people or systems (pager, chat, webhook)
+type Notifier interface {
+	Notify(ctx context.Context, inc Incident) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, inc Incident) error
+
+// Notify implements Notifier
+func (f NotifierFunc) Notify(ctx context.Context, inc Incident) error {
+	return f(ctx, inc)
+}
+
+// ErrorHandler is called when an action of the playbook fails
+type ErrorHandler func(inc Incident, action Action, err error)
+
+// Option configures a Playbook
+type Option func(*Playbook)
+
+// WithActions sets the actions run for every incident, in order.
+// Default: ActionNotify and ActionRequestLocation.
+func WithActions(actions ...Action) Option {
+	return func(pb *Playbook) {
+		pb.actions = actions
+	}
+}
+
+// WithCommander sets where commands are sent
+func WithCommander(c Commander) Option {
+	return func(pb *Playbook) {
+		pb.commander = c
+	}
+}
+
+// WithNotifier sets where incidents are notified
+func WithNotifier(n Notifier) Option {
+	return func(pb *Playbook) {
+		pb.notifier = n
+	}
+}
+
+// WithErrorHandler sets the callback for failed actions
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(pb *Playbook) {
+		pb.onError = h
+	}
+}
+
+// WithCooldown sets the minimum time between two runs for a device
+func WithCooldown(d time.Duration) Option {
+	return func(pb *Playbook) {
+		if d >= 0 {
+			pb.cooldown = d
+		}
+	}
+}
+
+// WithLBSOnlyUploads sets the number of consecutive LBS-only uploads after a
+// GPS fix that count as a fallback
+func WithLBSOnlyUploads(n int) Option {
+	return func(pb *Playbook) {
+		if n > 0 {
+			pb.lbsOnly = n
+		}
+	}
+}
+
+// WithDegradedSignal sets the GSM level at or below which the signal is degraded
+func WithDegradedSignal(level protocol.GSMSignalStrength) Option {
+	return func(pb *Playbook) {
+		pb.degraded = level
+	}
+}
+
+// Playbook detects incidents and runs the configured actions.
+// It is safe for concurrent use.
+type Playbook struct {
+	actions   []Action
+	commander Commander
+	notifier  Notifier
+	onError   ErrorHandler
+	cooldown  time.Duration
+	lbsOnly   int
+	degraded  protocol.GSMSignalStrength
+
+	mu      sync.Mutex
+	devices map[string]*deviceState
+}
+
+// deviceState holds the detection state of one device
+type deviceState struct {
+	hadFix    bool
+	lbsRun    int
+	gsm       protocol.GSMSignalStrength
+	gsmKnown  bool
+	lastRunAt time.Time
+}
+
+// NewPlaybook creates a playbook
+func NewPlaybook(opts ...Option) *Playbook {
+	pb := &Playbook{
+		actions:  []Action{ActionNotify, ActionRequestLocation},
+		cooldown: DefaultCooldown,
+		lbsOnly:  DefaultLBSOnlyUploads,
+		degraded: DefaultDegradedSignal,
+		devices:  make(map[string]*deviceState),
+	}
+	for _, opt := range opts {
+		opt(pb)
+	}
+	return pb
+}
+
+// Observe feeds a decoded packet into the playbook. When the packet starts
+// an incident, the actions run before Observe returns, so the Notifier should
+// hand slow deliveries off (e.g. to a queue).
+// Returns the incident, if any, and the errors of the failed actions.
+func (pb *Playbook) Observe(ctx context.Context, imei string, p packet.Packet) (Incident, bool, error) {
+	if imei == "" {
+		return Incident{}, false, nil
+	}
+	now := time.Now()
+
+	pb.mu.Lock()
+	inc, ok := pb.detect(imei, p, now)
+	pb.mu.Unlock()
+	if !ok {
+		return Incident{}, false, nil
+	}
+	return inc, true, pb.Run(ctx, inc)
+}
+
+// Run runs the actions for an incident, continuing after failures.
+// Returns the joined errors of the failed actions.
+func (pb *Playbook) Run(ctx context.Context, inc Incident) error {
+	var errs []error
+	for _, action := range pb.actions {
+		if err := pb.run(ctx, inc, action); err != nil {
+			err = fmt.Errorf("%s: %w", action, err)
+			errs = append(errs, err)
+			if pb.onError != nil {
+				pb.onError(inc, action, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Forget removes the detection state of a device
+func (pb *Playbook) Forget(imei string) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	delete(pb.devices, imei)
+}
+
+// run runs one action
+func (pb *Playbook) run(ctx context.Context, inc Incident, action Action) error {
+	switch action {
+	case ActionNotify:
+		if pb.notifier == nil {
+			return errors.New("no notifier")
+		}
+		return pb.notifier.Notify(ctx, inc)
+	case ActionRequestLocation:
+		return pb.send(inc.IMEI, encoder.CmdSingleLocation)
+	case ActionArmDefense:
+		return pb.send(inc.IMEI, encoder.CmdDefenseOn)
+	default:
+		return fmt.Errorf("unknown action %d", int(action))
+	}
+}
+
+// send sends a command through the Commander
+func (pb *Playbook) send(imei, command string) error {
+	if pb.commander == nil {
+		return errors.New("no commander")
+	}
+	return pb.commander.SendCommand(imei, ServerFlag, command)
+}
+
+// detect updates the state of a device and reports an incident.
+// Caller must hold pb.mu.
+func (pb *Playbook) detect(imei string, p packet.Packet, now time.Time) (Incident, bool) {
+	st, ok := pb.devices[imei]
+	if !ok {
+		st = &deviceState{}
+		pb.devices[imei] = st
+	}
+	if gsm, ok := gsmSignal(p); ok {
+		st.gsm, st.gsmKnown = gsm, true
+	}
+
+	var trigger Trigger
+	switch {
+	case isRogueBaseStation(p):
+		trigger = TriggerRogueBaseStation
+
+	case isLBSOnly(p):
+		if !st.hadFix {
+			return Incident{}, false
+		}
+		st.lbsRun++
+		if st.lbsRun < pb.lbsOnly || !st.gsmKnown || st.gsm > pb.degraded {
+			return Incident{}, false
+		}
+		trigger = TriggerLBSFallback
+
+	default:
+		if hasFix(p) {
+			st.hadFix = true
+			st.lbsRun = 0
+		}
+		return Incident{}, false
+	}
+
+	if !st.lastRunAt.IsZero() && now.Sub(st.lastRunAt) < pb.cooldown {
+		return Incident{}, false
+	}
+	st.lastRunAt = now
+	if trigger == TriggerLBSFallback {
+		// Re-arm only after the device gets a fix again
+		st.hadFix = false
+		st.lbsRun = 0
+	}
+
+	return Incident{
+		IMEI:      imei,
+		Trigger:   trigger,
+		GSMSignal: st.gsm,
+		Time:      now,
+		Packet:    p,
+	}, true
+}
+
+// isRogueBaseStation reports whether p is a rogue base station alarm
+func isRogueBaseStation(p packet.Packet) bool {
+	a, ok := alarmPacket(p)
+	return ok && a.AlarmType == protocol.AlarmRogueBaseStation
+}
+
+// isLBSOnly reports whether p is an LBS-only upload
+func isLBSOnly(p packet.Packet) bool {
+	switch p.(type) {
+	case *packet.LBSPacket, *packet.LBS4GPacket:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasFix reports whether p carries a GPS fix
+func hasFix(p packet.Packet) bool {
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		return v.IsPositioned()
+	case *packet.Location4GPacket:
+		return v.IsPositioned()
+	}
+	if a, ok := alarmPacket(p); ok {
+		return a.IsPositioned()
+	}
+	return false
+}
+
+// gsmSignal returns the GSM signal strength carried by p
+func gsmSignal(p packet.Packet) (protocol.GSMSignalStrength, bool) {
+	switch v := p.(type) {
+	case *packet.HeartbeatPacket:
+		return v.GSMSignal, true
+	case *packet.LBSPacket:
+		return v.GSMSignal, v.HasStatus
+	case *packet.LBS4GPacket:
+		return v.GSMSignal, true
+	}
+	if a, ok := alarmPacket(p); ok {
+		return a.GSMSignal, true
+	}
+	return 0, false
+}
+
+// alarmPacket returns the alarm layout embedded in a packet
+func alarmPacket(p packet.Packet) (*packet.AlarmPacket, bool) {
+	switch v := p.(type) {
+	case *packet.AlarmPacket:
+		return v, true
+	case *packet.AlarmMultiFencePacket:
+		return &v.AlarmPacket, true
+	case *packet.Alarm4GPacket:
+		return &v.AlarmPacket, true
+	case *packet.GPSLBSStatusPacket:
+		return &v.AlarmPacket, true
+	case *packet.ACCStatusPacket:
+		return &v.AlarmPacket, true
+	default:
+		return nil, false
+	}
+}
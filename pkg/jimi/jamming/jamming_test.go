@@ -0,0 +1,225 @@
+package jamming
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+// fakeCommander records the commands sent
+type fakeCommander struct {
+	commands []string
+	err      error
+}
+
+func (c *fakeCommander) SendCommand(imei string, serverFlag uint32, command string) error {
+	if imei != testIMEI || serverFlag != ServerFlag {
+		return errors.New("unexpected target")
+	}
+	c.commands = append(c.commands, command)
+	return c.err
+}
+
+func fix() packet.Packet {
+	return &packet.LocationPacket{CourseStatus: types.NewCourseStatus(0, true, true, true, true)}
+}
+
+func noFix() packet.Packet {
+	return &packet.LocationPacket{CourseStatus: types.NewCourseStatus(0, true, false, true, true)}
+}
+
+func lbs(gsm protocol.GSMSignalStrength) packet.Packet {
+	return &packet.LBS4GPacket{GSMSignal: gsm}
+}
+
+func rogue() packet.Packet {
+	return &packet.AlarmPacket{AlarmType: protocol.AlarmRogueBaseStation, GSMSignal: protocol.SignalGood}
+}
+
+func repeat(p packet.Packet, n int) []packet.Packet {
+	out := make([]packet.Packet, n)
+	for i := range out {
+		out[i] = p
+	}
+	return out
+}
+
+func TestPlaybook_Detect(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		packets []packet.Packet
+		want    []Trigger
+	}{
+		{
+			name:    "rogue base station alarm",
+			packets: []packet.Packet{rogue()},
+			want:    []Trigger{TriggerRogueBaseStation},
+		},
+		{
+			name:    "other alarm",
+			packets: []packet.Packet{&packet.AlarmPacket{AlarmType: protocol.AlarmSOS}},
+		},
+		{
+			name:    "LBS fallback with weak signal",
+			packets: append([]packet.Packet{fix()}, repeat(lbs(protocol.SignalWeak), 3)...),
+			want:    []Trigger{TriggerLBSFallback},
+		},
+		{
+			name:    "LBS fallback with good signal",
+			packets: append([]packet.Packet{fix()}, repeat(lbs(protocol.SignalGood), 5)...),
+		},
+		{
+			name:    "LBS only without a prior fix",
+			packets: append([]packet.Packet{noFix()}, repeat(lbs(protocol.SignalNone), 5)...),
+		},
+		{
+			name:    "too few LBS uploads",
+			packets: append([]packet.Packet{fix()}, repeat(lbs(protocol.SignalWeak), 2)...),
+		},
+		{
+			name:    "fix resets the LBS run",
+			packets: []packet.Packet{fix(), lbs(protocol.SignalWeak), lbs(protocol.SignalWeak), fix(), lbs(protocol.SignalWeak)},
+		},
+		{
+			name:    "degraded signal reported by heartbeat",
+			opts:    []Option{WithLBSOnlyUploads(1)},
+			packets: []packet.Packet{fix(), &packet.HeartbeatPacket{GSMSignal: protocol.SignalNone}, &packet.LBSPacket{}},
+			want:    []Trigger{TriggerLBSFallback},
+		},
+		{
+			name:    "cooldown",
+			packets: []packet.Packet{rogue(), rogue()},
+			want:    []Trigger{TriggerRogueBaseStation},
+		},
+		{
+			name:    "no cooldown",
+			opts:    []Option{WithCooldown(0)},
+			packets: []packet.Packet{rogue(), rogue()},
+			want:    []Trigger{TriggerRogueBaseStation, TriggerRogueBaseStation},
+		},
+		{
+			name:    "fallback re-arms after a new fix",
+			opts:    []Option{WithCooldown(0), WithLBSOnlyUploads(1)},
+			packets: []packet.Packet{fix(), lbs(protocol.SignalWeak), lbs(protocol.SignalWeak), fix(), lbs(protocol.SignalWeak)},
+			want:    []Trigger{TriggerLBSFallback, TriggerLBSFallback},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithCommander(&fakeCommander{}), WithActions()}, tt.opts...)
+			pb := NewPlaybook(opts...)
+
+			var got []Trigger
+			for _, p := range tt.packets {
+				inc, ok, err := pb.Observe(context.Background(), testIMEI, p)
+				if err != nil {
+					t.Fatalf("Observe() error = %v", err)
+				}
+				if ok {
+					got = append(got, inc.Trigger)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("triggers = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("trigger[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlaybook_Actions(t *testing.T) {
+	cmd := &fakeCommander{}
+	var notified []Incident
+	pb := NewPlaybook(
+		WithCommander(cmd),
+		WithNotifier(NotifierFunc(func(ctx context.Context, inc Incident) error {
+			notified = append(notified, inc)
+			return nil
+		})),
+		WithActions(ActionNotify, ActionRequestLocation, ActionArmDefense),
+	)
+
+	inc, ok, err := pb.Observe(context.Background(), testIMEI, rogue())
+	if err != nil || !ok {
+		t.Fatalf("Observe() = %v, %v, want incident", ok, err)
+	}
+	if inc.IMEI != testIMEI || inc.GSMSignal != protocol.SignalGood || inc.Time.IsZero() {
+		t.Errorf("incident = %s", inc)
+	}
+	if len(notified) != 1 || notified[0].Trigger != TriggerRogueBaseStation {
+		t.Errorf("notified = %v, want one rogue base station incident", notified)
+	}
+	want := []string{encoder.CmdSingleLocation, encoder.CmdDefenseOn}
+	if len(cmd.commands) != len(want) {
+		t.Fatalf("commands = %v, want %v", cmd.commands, want)
+	}
+	for i := range want {
+		if cmd.commands[i] != want[i] {
+			t.Errorf("command[%d] = %q, want %q", i, cmd.commands[i], want[i])
+		}
+	}
+}
+
+func TestPlaybook_ActionErrors(t *testing.T) {
+	sendErr := errors.New("device offline")
+	cmd := &fakeCommander{err: sendErr}
+	var failed []Action
+	pb := NewPlaybook(
+		WithCommander(cmd),
+		WithActions(ActionNotify, ActionRequestLocation, ActionArmDefense),
+		WithErrorHandler(func(inc Incident, action Action, err error) {
+			failed = append(failed, action)
+		}),
+	)
+
+	_, ok, err := pb.Observe(context.Background(), testIMEI, rogue())
+	if !ok {
+		t.Fatal("Observe() reported no incident")
+	}
+	if !errors.Is(err, sendErr) {
+		t.Errorf("Observe() error = %v, want %v", err, sendErr)
+	}
+	// Every action runs despite the failures
+	if len(cmd.commands) != 2 {
+		t.Errorf("commands = %v, want 2", cmd.commands)
+	}
+	want := []Action{ActionNotify, ActionRequestLocation, ActionArmDefense}
+	if len(failed) != len(want) {
+		t.Fatalf("failed actions = %v, want %v", failed, want)
+	}
+	for i := range want {
+		if failed[i] != want[i] {
+			t.Errorf("failed[%d] = %s, want %s", i, failed[i], want[i])
+		}
+	}
+}
+
+func TestPlaybook_Forget(t *testing.T) {
+	pb := NewPlaybook(WithActions(), WithCooldown(time.Hour))
+	ctx := context.Background()
+
+	if _, ok, _ := pb.Observe(ctx, testIMEI, rogue()); !ok {
+		t.Fatal("first alarm reported no incident")
+	}
+	if _, ok, _ := pb.Observe(ctx, testIMEI, rogue()); ok {
+		t.Fatal("second alarm reported an incident during the cooldown")
+	}
+	pb.Forget(testIMEI)
+	if _, ok, _ := pb.Observe(ctx, testIMEI, rogue()); !ok {
+		t.Error("alarm after Forget reported no incident")
+	}
+}
@@ -0,0 +1,42 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/fcode09/jimi-vl103m/internal/splitter"
+)
+
+// maxLogBytes caps the packet bytes included in a log record as hex
+const maxLogBytes = 512
+
+// logger returns the logger of the options, or nil when logging is disabled
+func (o *Options) logger() *slog.Logger {
+	if !o.EnableLogging {
+		return nil
+	}
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
+// packetAttrs returns the log attributes identifying a packet: its protocol
+// number (when the header is readable), length and hex bytes
+func packetAttrs(data []byte) []any {
+	attrs := make([]any, 0, 6)
+	if proto, err := splitter.GetPacketType(data); err == nil {
+		attrs = append(attrs, "protocol", fmt.Sprintf("0x%02X", proto))
+	}
+	attrs = append(attrs, "len", len(data), "hex", logHex(data))
+	return attrs
+}
+
+// logHex formats data as hex, truncated to maxLogBytes
+func logHex(data []byte) string {
+	if len(data) > maxLogBytes {
+		return hex.EncodeToString(data[:maxLogBytes]) + "..."
+	}
+	return hex.EncodeToString(data)
+}
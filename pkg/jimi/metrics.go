@@ -0,0 +1,56 @@
+package jimi
+
+import (
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/splitter"
+)
+
+// Metrics receives decoder events as counter increments, for monitoring
+// systems such as Prometheus (see the metrics package for a ready-made
+// adapter). Implementations must be safe for concurrent use; methods run on
+// the decoding goroutine and should return quickly.
+type Metrics interface {
+	// BytesProcessed counts bytes passed to Decode and bytes discarded from streams
+	BytesProcessed(n int)
+
+	// PacketDecoded counts a packet returned without error
+	PacketDecoded(protocolNum byte)
+
+	// CRCFailure counts a packet rejected for a CRC mismatch
+	CRCFailure(protocolNum byte)
+
+	// UnknownProtocol counts a packet without a registered parser, whether
+	// rejected or returned as a base packet
+	UnknownProtocol(protocolNum byte)
+
+	// Resync counts a run of discarded bytes skipped while looking for the
+	// next start bit in a stream
+	Resync(discarded int)
+}
+
+// ParseMetrics is implemented by Metrics that also observe parse durations.
+// When the Metrics of a decoder implement it, every parser call is timed,
+// including failed and timed-out ones.
+type ParseMetrics interface {
+	// ParseDuration observes the duration of one parser call
+	ParseDuration(protocolNum byte, d time.Duration)
+}
+
+// discardFunc returns the splitter callback reporting discarded stream bytes
+// to the logger and metrics, or nil when neither is configured
+func (o *Options) discardFunc() splitter.DiscardFunc {
+	log, metrics := o.logger(), o.Metrics
+	if log == nil && metrics == nil {
+		return nil
+	}
+	return func(discarded []byte, reason string) {
+		if log != nil {
+			log.Warn("stream bytes discarded", "reason", reason, "len", len(discarded), "hex", logHex(discarded))
+		}
+		if metrics != nil {
+			metrics.BytesProcessed(len(discarded))
+			metrics.Resync(len(discarded))
+		}
+	}
+}
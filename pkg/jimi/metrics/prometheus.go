@@ -0,0 +1,221 @@
+// Package metrics exposes decoder counters in the Prometheus text format.
+//
+// Prometheus implements jimi.Metrics, jimi.ParseMetrics and http.Handler
+// without depending on the Prometheus client library, so a server can be
+// scraped directly:
+//
+//	prom := metrics.NewPrometheus()
+//	prom.GaugeFunc("jimi_sessions", "Active device sessions", func() float64 {
+//	    return float64(len(srv.Sessions()))
+//	})
+//	srv := server.New(server.WithDecoderOptions(jimi.WithMetrics(prom)))
+//	http.Handle("/metrics", prom)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ParseBuckets are the upper bounds of the parse duration histogram. Built-in
+// parsers take microseconds; the upper buckets catch pathological inputs and
+// slow custom parsers.
+var ParseBuckets = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// Prometheus counts decoder events and serves them in the Prometheus text
+// format. It is safe for concurrent use and can be shared between decoders.
+type Prometheus struct {
+	bytes     atomic.Uint64
+	resyncs   atomic.Uint64
+	discarded atomic.Uint64
+	decoded   protocolCounter
+	crcErrors protocolCounter
+	unknown   protocolCounter
+	parses    [256]*histogram // allocated on the first parse of a protocol
+	parsesMu  sync.Mutex
+
+	gaugesMu sync.Mutex
+	gauges   []gauge
+}
+
+// gauge is a value read at scrape time
+type gauge struct {
+	name, help string
+	fn         func() float64
+}
+
+// protocolCounter counts events per protocol number
+type protocolCounter struct {
+	counts [256]atomic.Uint64
+}
+
+func (c *protocolCounter) inc(protocolNum byte) {
+	c.counts[protocolNum].Add(1)
+}
+
+// histogram counts observations in ParseBuckets
+type histogram struct {
+	buckets []atomic.Uint64 // not cumulative, one per bucket and +Inf
+	sum     atomic.Int64    // nanoseconds
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(ParseBuckets), func(i int) bool { return d <= ParseBuckets[i] })
+	h.buckets[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// NewPrometheus creates zeroed counters
+func NewPrometheus() *Prometheus {
+	return &Prometheus{}
+}
+
+// BytesProcessed implements jimi.Metrics
+func (p *Prometheus) BytesProcessed(n int) {
+	p.bytes.Add(uint64(n))
+}
+
+// PacketDecoded implements jimi.Metrics
+func (p *Prometheus) PacketDecoded(protocolNum byte) {
+	p.decoded.inc(protocolNum)
+}
+
+// CRCFailure implements jimi.Metrics
+func (p *Prometheus) CRCFailure(protocolNum byte) {
+	p.crcErrors.inc(protocolNum)
+}
+
+// UnknownProtocol implements jimi.Metrics
+func (p *Prometheus) UnknownProtocol(protocolNum byte) {
+	p.unknown.inc(protocolNum)
+}
+
+// Resync implements jimi.Metrics
+func (p *Prometheus) Resync(discarded int) {
+	p.resyncs.Add(1)
+	p.discarded.Add(uint64(discarded))
+}
+
+// ParseDuration implements jimi.ParseMetrics
+func (p *Prometheus) ParseDuration(protocolNum byte, d time.Duration) {
+	p.parsesMu.Lock()
+	h := p.parses[protocolNum]
+	if h == nil {
+		h = &histogram{buckets: make([]atomic.Uint64, len(ParseBuckets)+1)}
+		p.parses[protocolNum] = h
+	}
+	p.parsesMu.Unlock()
+	h.observe(d)
+}
+
+// GaugeFunc adds a gauge whose value is read from fn at every scrape, for
+// values owned by the application (e.g. active sessions)
+func (p *Prometheus) GaugeFunc(name, help string, fn func() float64) {
+	p.gaugesMu.Lock()
+	defer p.gaugesMu.Unlock()
+
+	p.gauges = append(p.gauges, gauge{name: name, help: help, fn: fn})
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	bw := bufio.NewWriter(w)
+	p.write(bw)
+	bw.Flush()
+}
+
+// write writes every metric; per-protocol counters only list seen protocols
+func (p *Prometheus) write(w *bufio.Writer) {
+	writeProtocols(w, "jimi_packets_decoded_total", "Packets decoded, by protocol number", &p.decoded)
+	writeProtocols(w, "jimi_crc_failures_total", "Packets rejected for a CRC mismatch, by protocol number", &p.crcErrors)
+	writeProtocols(w, "jimi_unknown_protocol_packets_total", "Packets without a registered parser, by protocol number", &p.unknown)
+	p.writeParses(w)
+	writeCounter(w, "jimi_bytes_processed_total", "Bytes decoded or discarded", p.bytes.Load())
+	writeCounter(w, "jimi_resyncs_total", "Stream resynchronizations on a start bit", p.resyncs.Load())
+	writeCounter(w, "jimi_resync_discarded_bytes_total", "Bytes discarded while resynchronizing", p.discarded.Load())
+
+	p.gaugesMu.Lock()
+	gauges := append([]gauge(nil), p.gauges...)
+	p.gaugesMu.Unlock()
+	sort.Slice(gauges, func(i, j int) bool { return gauges[i].name < gauges[j].name })
+	for _, g := range gauges {
+		writeHeader(w, g.name, g.help, "gauge")
+		fmt.Fprintf(w, "%s %s\n", g.name, strconv.FormatFloat(g.fn(), 'g', -1, 64))
+	}
+}
+
+// writeParses writes the parse duration histogram of the parsed protocols
+func (p *Prometheus) writeParses(w *bufio.Writer) {
+	const name = "jimi_parse_duration_seconds"
+	writeHeader(w, name, "Parser call durations, by protocol number", "histogram")
+
+	p.parsesMu.Lock()
+	parses := p.parses
+	p.parsesMu.Unlock()
+	for i, h := range parses {
+		if h == nil {
+			continue
+		}
+		var count uint64
+		for b := range h.buckets {
+			count += h.buckets[b].Load()
+			le := "+Inf"
+			if b < len(ParseBuckets) {
+				le = strconv.FormatFloat(ParseBuckets[b].Seconds(), 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, protocolLabels(i), le, count)
+		}
+		sum := time.Duration(h.sum.Load()).Seconds()
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, protocolLabels(i), strconv.FormatFloat(sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, protocolLabels(i), count)
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeCounter writes a counter without labels
+func writeCounter(w *bufio.Writer, name, help string, value uint64) {
+	writeHeader(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+// writeProtocols writes a counter with a protocol label
+func writeProtocols(w *bufio.Writer, name, help string, c *protocolCounter) {
+	writeHeader(w, name, help, "counter")
+	for i := range c.counts {
+		if n := c.counts[i].Load(); n > 0 {
+			fmt.Fprintf(w, "%s{%s} %d\n", name, protocolLabels(i), n)
+		}
+	}
+}
+
+// protocolLabels returns the labels of a protocol number: its hex value and
+// its name (see protocol.Number)
+func protocolLabels(i int) string {
+	return fmt.Sprintf("protocol=\"0x%02X\",name=\"%s\"", i, protocol.Number(i))
+}
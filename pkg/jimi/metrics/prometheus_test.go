@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+)
+
+var (
+	_ jimi.Metrics      = (*Prometheus)(nil)
+	_ jimi.ParseMetrics = (*Prometheus)(nil)
+)
+
+func TestPrometheus_Decoder(t *testing.T) {
+	prom := NewPrometheus()
+	decoder := jimi.NewDecoder(jimi.WithStrictMode(false), jimi.WithMetrics(prom))
+
+	// Garbage, a heartbeat, a heartbeat with a bad CRC and an unknown protocol
+	stream, _ := hex.DecodeString("DEADBEEF" +
+		"787808132404020001870D0D0A" +
+		"787808132404020001FFFF0D0A" +
+		"787805EE00019ABA0D0A")
+	decoder.DecodeStream(stream)
+
+	prom.GaugeFunc("jimi_sessions", "Active device sessions", func() float64 { return 3 })
+
+	rec := httptest.NewRecorder()
+	prom.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE jimi_packets_decoded_total counter\n",
+		`jimi_packets_decoded_total{protocol="0x13",name="heartbeat"} 1` + "\n",
+		`jimi_crc_failures_total{protocol="0x13",name="heartbeat"} 1` + "\n",
+		`jimi_unknown_protocol_packets_total{protocol="0xEE",name="0xEE"} 1` + "\n",
+		"jimi_bytes_processed_total 40\n",
+		"jimi_resyncs_total 1\n",
+		"jimi_resync_discarded_bytes_total 4\n",
+		"# TYPE jimi_sessions gauge\njimi_sessions 3\n",
+		"# TYPE jimi_parse_duration_seconds histogram\n",
+		`jimi_parse_duration_seconds_bucket{protocol="0x13",name="heartbeat",le="+Inf"} 1` + "\n",
+		`jimi_parse_duration_seconds_count{protocol="0x13",name="heartbeat"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `jimi_packets_decoded_total{protocol="0xEE"`) {
+		t.Errorf("Rejected unknown protocol counted as decoded:\n%s", body)
+	}
+}
+
+func TestPrometheus_ParseDuration(t *testing.T) {
+	prom := NewPrometheus()
+	for _, d := range []time.Duration{5 * time.Microsecond, 2 * time.Millisecond, time.Second} {
+		prom.ParseDuration(0x22, d)
+	}
+
+	rec := httptest.NewRecorder()
+	prom.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	// Buckets are cumulative
+	for _, want := range []string{
+		`jimi_parse_duration_seconds_bucket{protocol="0x22",name="gps_location",le="1e-05"} 1`,
+		`jimi_parse_duration_seconds_bucket{protocol="0x22",name="gps_location",le="0.001"} 1`,
+		`jimi_parse_duration_seconds_bucket{protocol="0x22",name="gps_location",le="0.005"} 2`,
+		`jimi_parse_duration_seconds_bucket{protocol="0x22",name="gps_location",le="0.1"} 2`,
+		`jimi_parse_duration_seconds_bucket{protocol="0x22",name="gps_location",le="+Inf"} 3`,
+		`jimi_parse_duration_seconds_sum{protocol="0x22",name="gps_location"} 1.002005`,
+		`jimi_parse_duration_seconds_count{protocol="0x22",name="gps_location"} 3`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Missing %q in:\n%s", want, body)
+		}
+	}
+}
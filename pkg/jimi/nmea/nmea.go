@@ -0,0 +1,156 @@
+// Package nmea converts decoded GPS fixes to NMEA 0183 sentences.
+//
+// Many downstream tools (chart plotters, gpsd, OpenCPN, GIS importers) read
+// NMEA 0183 rather than the Jimi protocol. Location packets (0x22/0xA0) are
+// converted to a GPRMC sentence (time, position, speed, course, date) and a
+// GPGGA sentence (fix quality and satellites). The devices report neither
+// altitude nor dilution of precision, so those fields are left empty.
+//
+// Example usage:
+//
+//	out := nmea.NewWriter(conn)
+//	for _, pkt := range packets {
+//	    if err := out.WritePacket(pkt); err != nil {
+//	        log.Printf("NMEA write failed: %v", err)
+//	    }
+//	}
+package nmea
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// knotsPerKmh converts km/h to knots
+const knotsPerKmh = 1 / 1.852
+
+// Sentences returns the GPRMC and GPGGA sentences of a location packet.
+// Returns false for packets without a location.
+func Sentences(p packet.Packet) ([]string, bool) {
+	loc, ok := locationOf(p)
+	if !ok {
+		return nil, false
+	}
+	return []string{RMC(loc), GGA(loc)}, true
+}
+
+// RMC returns the GPRMC (recommended minimum) sentence of a fix.
+// Fixes that are not positioned are marked void ("V").
+func RMC(p *packet.LocationPacket) string {
+	t := p.DateTime.Time.UTC()
+	status, mode := "V", "N"
+	if p.IsPositioned() {
+		status, mode = "A", "A"
+	}
+	lat, ns, lon, ew := coordinates(p)
+	return sentence(fmt.Sprintf("GPRMC,%s,%s,%s,%s,%s,%s,%.1f,%.1f,%s,,,%s",
+		t.Format("150405.00"), status, lat, ns, lon, ew,
+		float64(p.Speed)*knotsPerKmh, float64(p.Heading()), t.Format("020106"), mode))
+}
+
+// GGA returns the GPGGA (fix data) sentence of a fix
+func GGA(p *packet.LocationPacket) string {
+	t := p.DateTime.Time.UTC()
+	quality := 0
+	if p.IsPositioned() {
+		quality = 1
+	}
+	lat, ns, lon, ew := coordinates(p)
+	return sentence(fmt.Sprintf("GPGGA,%s,%s,%s,%s,%s,%d,%02d,,,M,,M,,",
+		t.Format("150405.00"), lat, ns, lon, ew, quality, p.Satellites))
+}
+
+// Checksum returns the XOR of the bytes between "$" and "*"
+func Checksum(body string) byte {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
+
+// Verify reports whether a sentence ("$...*hh", with or without the line
+// ending) carries a correct checksum
+func Verify(s string) bool {
+	s = strings.TrimRight(s, "\r\n")
+	star := strings.LastIndexByte(s, '*')
+	if !strings.HasPrefix(s, "$") || star < 0 || len(s)-star != 3 {
+		return false
+	}
+	return fmt.Sprintf("%02X", Checksum(s[1:star])) == strings.ToUpper(s[star+1:])
+}
+
+// Writer writes the sentences of location packets to an io.Writer, one per
+// CRLF-terminated line. It is safe for concurrent use.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter creates a writer of NMEA sentences to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WritePacket writes the sentences of a location packet.
+// Packets without a location are skipped.
+func (n *Writer) WritePacket(p packet.Packet) error {
+	sentences, ok := Sentences(p)
+	if !ok {
+		return nil
+	}
+	var b strings.Builder
+	for _, s := range sentences {
+		b.WriteString(s)
+		b.WriteString("\r\n")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := io.WriteString(n.w, b.String())
+	return err
+}
+
+// sentence wraps a sentence body with "$" and its checksum
+func sentence(body string) string {
+	return fmt.Sprintf("$%s*%02X", body, Checksum(body))
+}
+
+// coordinates returns the NMEA latitude (ddmm.mmmm) and longitude
+// (dddmm.mmmm) with their hemispheres
+func coordinates(p *packet.LocationPacket) (lat, ns, lon, ew string) {
+	ns, ew = "N", "E"
+	if !p.Coordinates.IsNorth {
+		ns = "S"
+	}
+	if !p.Coordinates.IsEast {
+		ew = "W"
+	}
+	return degreesMinutes(p.Coordinates.Latitude, 2), ns, degreesMinutes(p.Coordinates.Longitude, 3), ew
+}
+
+// degreesMinutes formats unsigned decimal degrees as degrees and decimal
+// minutes, rounding to 1/10000 minute without producing "60.0000"
+func degreesMinutes(deg float64, width int) string {
+	units := int64(math.Round(math.Abs(deg) * 60 * 10000))
+	d := units / (60 * 10000)
+	m := units % (60 * 10000)
+	return fmt.Sprintf("%0*d%02d.%04d", width, d, m/10000, m%10000)
+}
+
+// locationOf returns the GPS layout of a location packet
+func locationOf(p packet.Packet) (*packet.LocationPacket, bool) {
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		return v, true
+	case *packet.Location4GPacket:
+		return &v.LocationPacket, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,138 @@
+package nmea
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func location(t *testing.T, at time.Time, lat, lon float64, speed, course uint16, positioned bool, sats uint8) *packet.LocationPacket {
+	t.Helper()
+	coords, err := types.NewCoordinates(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := packet.NewLocationPacket(types.DateTime{Time: at}, coords, speed,
+		types.NewCourseStatus(course, true, positioned, coords.IsEast, coords.IsNorth))
+	p.Satellites = sats
+	return p
+}
+
+func TestSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		p    packet.Packet
+		want []string
+	}{
+		{
+			name: "positioned fix",
+			p:    location(t, time.Date(2026, 1, 1, 12, 34, 56, 0, time.UTC), 48.1173, 11.516666666666667, 41, 84, true, 8),
+			want: []string{
+				"$GPRMC,123456.00,A,4807.0380,N,01131.0000,E,22.1,84.0,010126,,,A*52",
+				"$GPGGA,123456.00,4807.0380,N,01131.0000,E,1,08,,,M,,M,,*7F",
+			},
+		},
+		{
+			name: "southern and western hemispheres without fix",
+			p:    location(t, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), -33.86866666666667, -151.21, 0, 0, false, 0),
+			want: []string{
+				"$GPRMC,000000.00,V,3352.1200,S,15112.6000,W,0.0,0.0,311225,,,N*4B",
+				"$GPGGA,000000.00,3352.1200,S,15112.6000,W,0,00,,,M,,M,,*78",
+			},
+		},
+		{
+			name: "4G location",
+			p: &packet.Location4GPacket{
+				LocationPacket: *location(t, time.Date(2026, 1, 1, 12, 34, 56, 0, time.UTC), 48.1173, 11.516666666666667, 41, 84, true, 8),
+			},
+			want: []string{
+				"$GPRMC,123456.00,A,4807.0380,N,01131.0000,E,22.1,84.0,010126,,,A*52",
+				"$GPGGA,123456.00,4807.0380,N,01131.0000,E,1,08,,,M,,M,,*7F",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Sentences(tt.p)
+			if !ok {
+				t.Fatal("Sentences() = false, want true")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Sentences() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sentence[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+				if !Verify(got[i]) {
+					t.Errorf("Verify(%q) = false", got[i])
+				}
+			}
+		})
+	}
+
+	if _, ok := Sentences(&packet.HeartbeatPacket{}); ok {
+		t.Error("Sentences(heartbeat) = true, want false")
+	}
+}
+
+func TestDegreesMinutes(t *testing.T) {
+	tests := []struct {
+		deg   float64
+		width int
+		want  string
+	}{
+		{0, 2, "0000.0000"},
+		{48.1173, 2, "4807.0380"},
+		{11.5, 3, "01130.0000"},
+		{179.999999999, 3, "18000.0000"}, // rounds up to the next degree, not "60.0000" minutes
+	}
+
+	for _, tt := range tests {
+		if got := degreesMinutes(tt.deg, tt.width); got != tt.want {
+			t.Errorf("degreesMinutes(%v, %d) = %q, want %q", tt.deg, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A", true},
+		{"$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n", true},
+		{"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6B", false},
+		{"GPRMC,123519*6A", false},
+		{"$GPRMC,123519", false},
+	}
+
+	for _, tt := range tests {
+		if got := Verify(tt.s); got != tt.want {
+			t.Errorf("Verify(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	if err := w.WritePacket(&packet.HeartbeatPacket{}); err != nil {
+		t.Fatal(err)
+	}
+	p := location(t, time.Date(2026, 1, 1, 12, 34, 56, 0, time.UTC), 48.1173, 11.516666666666667, 41, 84, true, 8)
+	if err := w.WritePacket(p); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "$GPRMC,123456.00,A,4807.0380,N,01131.0000,E,22.1,84.0,010126,,,A*52\r\n" +
+		"$GPGGA,123456.00,4807.0380,N,01131.0000,E,1,08,,,M,,M,,*7F\r\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
@@ -1,5 +1,14 @@
 package jimi
 
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/parser"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
 // Options contains configuration for the decoder
 type Options struct {
 	// StrictMode enables strict validation (fail on any validation error)
@@ -24,23 +33,149 @@ type Options struct {
 	// When false, unknown protocols return an error
 	AllowUnknownProtocols bool
 
-	// EnableLogging enables internal debug logging
-	// Logs are written to the configured logger (if any)
+	// EnableLogging enables logging of parse errors, CRC failures, skipped
+	// packets and discarded stream bytes
+	// Logs are written to Logger, or to slog.Default() if Logger is nil
 	EnableLogging bool
 
-	// TimeLocation sets the default timezone for datetime decoding
-	// If nil, UTC is used
+	// Logger receives the decoder logs when EnableLogging is set
+	// Shared, not copied, by Clone
+	Logger *slog.Logger
+
+	// TimeLocation is the timezone offset in minutes of the device clock,
+	// for devices configured to report local time instead of UTC. Date-times
+	// are converted to UTC from it, or from the login timezone once known
+	// with DeviceTimezone. If nil, date-times are UTC
 	TimeLocation *int // Timezone offset in minutes
 
 	// ValidateIMEIChecksum enables IMEI Luhn checksum validation
 	// When false, IMEI format is validated but checksum is not verified
 	ValidateIMEIChecksum bool
 
+	// CRC is the checksum variant packets are validated with (see WithCRCVariant)
+	// nil uses CRCX25, the protocol default
+	CRC CRC
+
+	// IDMapper maps the IMEI field of login packets to the device ID
+	// nil uses types.DeviceIDFromBCD; 15-digit results are still checked
+	// against ValidateIMEIChecksum
+	IDMapper types.IDMapper
+
 	// EnableAutoCorrection enables automatic correction of minor packet issues
 	// For example: auto-trimming trailing zeros, fixing minor length mismatches
 	EnableAutoCorrection bool
+
+	// ACCAlarmsAsStatus routes ACC on/off pseudo-alarms (0xFE/0xFF) as status events
+	// When true, they are returned as *packet.ACCStatusPacket instead of alarm packets
+	ACCAlarmsAsStatus bool
+
+	// AcceptZeroTime decodes packets with an all-zero date-time, sent by
+	// devices whose clock is not set yet (e.g. alarms right after a cold boot)
+	// The receive time is substituted, with DateTime.ZeroTime set
+	// When false, such packets fail with ErrZeroDateTime
+	AcceptZeroTime bool
+
+	// DeviceTimezone sets the timezone of the login packet of a device
+	// session on the date-times decoded after it (DateTime.Timezone, see
+	// DateTime.Local and DeviceSession)
+	DeviceTimezone bool
+
+	// SatelliteNibble selects the nibble of the GPS info byte holding the
+	// number of satellites, for firmware that swaps it with the GPS info length
+	// Combine with WithProtocolOptions for variants that differ per packet type
+	SatelliteNibble SatelliteNibble
+
+	// SpeedFormat selects the encoding of the speed of location packets
+	// (0x22, 0xA0), for firmware sending knots or a 2-byte speed
+	// Combine with WithProtocolOptions for variants that differ per packet type
+	SpeedFormat SpeedFormat
+
+	// ProtocolOptions holds options applied on top of the others for specific
+	// protocol numbers, for firmware bugs that only affect one packet type
+	// Stream-level behavior (DecodeStream error handling) always uses the base options
+	ProtocolOptions map[byte][]Option
+
+	// Quarantine records packets with unknown protocol numbers
+	// Only used when AllowUnknownProtocols is true; shared, not copied, by Clone
+	Quarantine *Quarantine
+
+	// ParseTimeout bounds the time a single parser call may take (0 = no limit)
+	// Guards against misbehaving custom parsers and pathological payloads
+	ParseTimeout time.Duration
+
+	// ParseStats records parse durations, slow parses and timeouts
+	// Shared, not copied, by Clone
+	ParseStats *ParseStats
+
+	// Metrics receives counters of decoded packets, CRC failures, unknown
+	// protocols, processed bytes and stream resyncs
+	// Shared, not copied, by Clone
+	Metrics Metrics
+
+	// MaxStringLength caps variable-length text fields (addresses, commands,
+	// command responses) in bytes; longer fields fail with a *FieldLimitError
+	// 0 disables the limit
+	MaxStringLength int
+
+	// MaxInfoDataLength caps the data of information transfer packets in bytes
+	// 0 disables the limit
+	MaxInfoDataLength int
+
+	// MaxNeighborCells caps the neighbor cells kept from LBS packets
+	// Extra cells are skipped; 0 means the protocol maximum of 6
+	MaxNeighborCells int
+
+	// MaxDeclaredLength bounds the packet length field accepted from streams
+	// Larger headers are treated as corrupted data (*StreamLimitError)
+	// 0 disables the limit
+	MaxDeclaredLength int
+
+	// MaxResidueSize bounds the bytes buffered for an incomplete stream packet
+	// Beyond it the splitter resyncs past the stuck header (*StreamLimitError)
+	// 0 disables the limit
+	MaxResidueSize int
+
+	// Resync makes corrupted stream data non-fatal (see WithResyncPolicy)
+	// nil keeps the strict split behavior
+	Resync *ResyncPolicy
+
+	// Parallelism is the number of workers decoding the packets of one
+	// DecodeStream call; 0 or 1 decodes them sequentially
+	Parallelism int
 }
 
+// Default limits for variable-length fields
+const (
+	DefaultMaxStringLength   = parser.DefaultMaxStringLength
+	DefaultMaxInfoDataLength = parser.DefaultMaxInfoDataLength
+	DefaultMaxNeighborCells  = parser.DefaultMaxNeighborCells
+)
+
+// maxTimezoneOffset bounds TimeLocation in minutes (UTC-14:00 to UTC+14:00)
+const maxTimezoneOffset = 14 * 60
+
+// SatelliteNibble selects the nibble of the GPS info byte that holds the
+// number of satellites (see WithSatelliteNibble)
+type SatelliteNibble = parser.SatelliteNibble
+
+// Satellite nibble selections
+const (
+	SatellitesLowNibble  = parser.SatellitesLowNibble  // bits 3-0, the protocol default
+	SatellitesHighNibble = parser.SatellitesHighNibble // bits 7-4
+	SatellitesAuto       = parser.SatellitesAuto       // the nibble that is not the GPS info length (12)
+)
+
+// SpeedFormat is the encoding of the speed of location packets (see
+// WithSpeedFormat)
+type SpeedFormat = parser.SpeedFormat
+
+// Speed formats
+const (
+	SpeedKmh   = parser.SpeedKmh   // one byte in km/h, the protocol default
+	SpeedKnots = parser.SpeedKnots // one byte in knots, converted to km/h
+	SpeedKmh16 = parser.SpeedKmh16 // two bytes in km/h, for speeds above 255 km/h
+)
+
 // Option is a functional option for configuring the Decoder
 type Option func(*Options)
 
@@ -56,6 +191,10 @@ func DefaultOptions() Options {
 		TimeLocation:            nil, // UTC
 		ValidateIMEIChecksum:    true,
 		EnableAutoCorrection:    false,
+		ACCAlarmsAsStatus:       false,
+		MaxStringLength:         DefaultMaxStringLength,
+		MaxInfoDataLength:       DefaultMaxInfoDataLength,
+		MaxNeighborCells:        DefaultMaxNeighborCells,
 	}
 }
 
@@ -89,6 +228,54 @@ func WithMaxPacketSize(size int) Option {
 	}
 }
 
+// WithMaxStringLength caps variable-length text fields (0 disables the limit)
+func WithMaxStringLength(n int) Option {
+	return func(o *Options) {
+		o.MaxStringLength = n
+	}
+}
+
+// WithMaxInfoDataLength caps information transfer data (0 disables the limit)
+func WithMaxInfoDataLength(n int) Option {
+	return func(o *Options) {
+		o.MaxInfoDataLength = n
+	}
+}
+
+// WithMaxNeighborCells caps the neighbor cells kept from LBS packets
+func WithMaxNeighborCells(n int) Option {
+	return func(o *Options) {
+		o.MaxNeighborCells = n
+	}
+}
+
+// WithMaxDeclaredLength treats stream packets whose length field exceeds n
+// as corrupted data: they are discarded up to the next start bit and a
+// *StreamLimitError is returned with the other packets (0 disables the limit)
+func WithMaxDeclaredLength(n int) Option {
+	return func(o *Options) {
+		o.MaxDeclaredLength = n
+	}
+}
+
+// WithMaxResidueSize stops waiting for an incomplete stream packet once n
+// bytes are buffered for it: the splitter resyncs past its header and
+// returns a *StreamLimitError (0 disables the limit)
+func WithMaxResidueSize(n int) Option {
+	return func(o *Options) {
+		o.MaxResidueSize = n
+	}
+}
+
+// WithParallelism decodes the packets split from one DecodeStream call with
+// n workers. Packets are returned in stream order either way; it pays off for
+// large buffers (e.g. bulk imports) on multi-core machines (0 or 1 disables).
+func WithParallelism(n int) Option {
+	return func(o *Options) {
+		o.Parallelism = n
+	}
+}
+
 // WithAllowUnknownProtocols allows decoding of unknown protocol numbers
 func WithAllowUnknownProtocols() Option {
 	return func(o *Options) {
@@ -96,15 +283,82 @@ func WithAllowUnknownProtocols() Option {
 	}
 }
 
-// WithLogging enables internal debug logging
+// WithQuarantine records unknown-protocol packets in q
+// Packets are only recorded when unknown protocols are allowed
+func WithQuarantine(q *Quarantine) Option {
+	return func(o *Options) {
+		o.Quarantine = q
+	}
+}
+
+// WithLearningMode accepts unknown protocols and records them in q
+func WithLearningMode(q *Quarantine) Option {
+	return func(o *Options) {
+		o.AllowUnknownProtocols = true
+		o.Quarantine = q
+	}
+}
+
+// WithParseTimeout fails a packet whose parser runs longer than d
+// The packet is returned as a *ParseTimeoutError (a base packet in lenient mode)
+func WithParseTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		if d >= 0 {
+			o.ParseTimeout = d
+		}
+	}
+}
+
+// WithParseStats records parse durations in s
+func WithParseStats(s *ParseStats) Option {
+	return func(o *Options) {
+		o.ParseStats = s
+	}
+}
+
+// WithMetrics reports decoder counters to m
+//
+// Example:
+//
+//	prom := metrics.NewPrometheus()
+//	decoder := jimi.NewDecoder(jimi.WithMetrics(prom))
+//	http.Handle("/metrics", prom)
+func WithMetrics(m Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// WithLogging enables logging to slog.Default()
 func WithLogging() Option {
 	return func(o *Options) {
 		o.EnableLogging = true
 	}
 }
 
-// WithTimeLocation sets the timezone for datetime decoding
+// WithLogger enables logging to l
+//
+// Records carry the protocol number ("protocol"), packet length ("len") and
+// bytes ("hex") as attributes. Rejected packets (structure, CRC, parse and
+// unknown protocol errors) are logged at Warn, packets skipped in lenient
+// mode and data dropped by parsers at Debug.
+//
+// Example:
+//
+//	decoder := jimi.NewDecoder(jimi.WithLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+		o.EnableLogging = true
+	}
+}
+
+// WithTimeLocation decodes date-times as the local time of a device clock
+// in offset instead of UTC, for devices configured to report local time
 // offset: timezone offset in minutes (e.g., 480 for UTC+8, -300 for UTC-5)
+// The date-times are converted to UTC and carry the timezone. Combined with
+// WithDeviceTimezone, the login timezone of the device replaces offset once
+// decoded.
 func WithTimeLocation(offset int) Option {
 	return func(o *Options) {
 		o.TimeLocation = &offset
@@ -118,6 +372,15 @@ func WithoutIMEIValidation() Option {
 	}
 }
 
+// WithIDMapper sets how the IMEI field of login packets maps to the device
+// ID, for firmware that encodes its identifier in an unusual way
+// (default types.DeviceIDFromBCD)
+func WithIDMapper(m types.IDMapper) Option {
+	return func(o *Options) {
+		o.IDMapper = m
+	}
+}
+
 // WithAutoCorrection enables automatic correction of minor issues
 func WithAutoCorrection() Option {
 	return func(o *Options) {
@@ -125,6 +388,91 @@ func WithAutoCorrection() Option {
 	}
 }
 
+// WithACCAlarmsAsStatus decodes ACC on/off alarms (0xFE/0xFF) as *packet.ACCStatusPacket
+// These are ignition reports, not emergencies
+func WithACCAlarmsAsStatus() Option {
+	return func(o *Options) {
+		o.ACCAlarmsAsStatus = true
+	}
+}
+
+// WithAcceptZeroTime decodes packets with an all-zero date-time instead of
+// rejecting them, substituting the receive time flagged with ZeroTime
+// Use packet.HasZeroTime to tell substituted timestamps apart
+func WithAcceptZeroTime() Option {
+	return func(o *Options) {
+		o.AcceptZeroTime = true
+	}
+}
+
+// WithDeviceTimezone sets the timezone reported by the login packet of a
+// device on the date-times of its following packets (DateTime.Timezone), so
+// DateTime.Local returns them in the device's local time. The date-times
+// stay in UTC, as the protocol specifies, unless WithTimeLocation says the
+// device clock runs on local time.
+//
+// The timezone is remembered per DeviceSession, one per device connection
+// (as server.Server and StreamDecoder do); Decoder.Decode remembers nothing.
+func WithDeviceTimezone() Option {
+	return func(o *Options) {
+		o.DeviceTimezone = true
+	}
+}
+
+// WithSatelliteNibble selects the nibble of the GPS info byte read as the
+// number of satellites (default SatellitesLowNibble)
+//
+// Example:
+//
+//	// Firmware reporting 0x9C instead of 0xC9 for 9 satellites in alarms
+//	decoder := jimi.NewDecoder(jimi.WithProtocolOptions(protocol.ProtocolAlarm,
+//	    jimi.WithSatelliteNibble(jimi.SatellitesAuto)))
+func WithSatelliteNibble(n SatelliteNibble) Option {
+	return func(o *Options) {
+		o.SatelliteNibble = n
+	}
+}
+
+// WithSpeedFormat selects the encoding of the speed of location packets
+// (0x22, 0xA0). Speeds are always reported in km/h (default SpeedKmh)
+//
+// Example:
+//
+//	// Firmware widening the speed of 4G location packets to two bytes
+//	decoder := jimi.NewDecoder(jimi.WithProtocolOptions(protocol.ProtocolGPSLocation4G,
+//	    jimi.WithSpeedFormat(jimi.SpeedKmh16)))
+func WithSpeedFormat(f SpeedFormat) Option {
+	return func(o *Options) {
+		o.SpeedFormat = f
+	}
+}
+
+// WithProtocolOptions applies opts only to packets with the given protocol number
+// Options for the same protocol accumulate across calls
+//
+// Example:
+//
+//	// Skip CRC only for information transfer packets with buggy checksums
+//	decoder := jimi.NewDecoder(jimi.WithProtocolOptions(protocol.ProtocolInfoTransfer, jimi.WithSkipCRC()))
+func WithProtocolOptions(protocolNum byte, opts ...Option) Option {
+	return func(o *Options) {
+		if o.ProtocolOptions == nil {
+			o.ProtocolOptions = make(map[byte][]Option)
+		}
+		o.ProtocolOptions[protocolNum] = append(o.ProtocolOptions[protocolNum], opts...)
+	}
+}
+
+// ForProtocol returns the effective options for a protocol number
+func (o *Options) ForProtocol(protocolNum byte) Options {
+	effective := o.Clone()
+	effective.ProtocolOptions = nil
+	for _, opt := range o.ProtocolOptions[protocolNum] {
+		opt(&effective)
+	}
+	return effective
+}
+
 // WithLenientMode configures the decoder for lenient/permissive decoding
 // This is a convenience option that sets multiple flags for maximum compatibility
 func WithLenientMode() Option {
@@ -159,15 +507,69 @@ func (o *Options) Validate() error {
 		return NewValidationError("MaxPacketSize", "must not exceed 1 MB", o.MaxPacketSize)
 	}
 
+	if o.MaxStringLength < 0 {
+		return NewValidationError("MaxStringLength", "must not be negative", o.MaxStringLength)
+	}
+
+	if o.MaxInfoDataLength < 0 {
+		return NewValidationError("MaxInfoDataLength", "must not be negative", o.MaxInfoDataLength)
+	}
+
+	if o.MaxNeighborCells < 0 {
+		return NewValidationError("MaxNeighborCells", "must not be negative", o.MaxNeighborCells)
+	}
+
+	if o.TimeLocation != nil && (*o.TimeLocation < -maxTimezoneOffset || *o.TimeLocation > maxTimezoneOffset) {
+		return NewValidationError("TimeLocation", "must be within 14 hours of UTC", *o.TimeLocation)
+	}
+
+	if o.SatelliteNibble < SatellitesLowNibble || o.SatelliteNibble > SatellitesAuto {
+		return NewValidationError("SatelliteNibble", "must be low, high or auto", o.SatelliteNibble)
+	}
+
+	if o.SpeedFormat < SpeedKmh || o.SpeedFormat > SpeedKmh16 {
+		return NewValidationError("SpeedFormat", "must be kmh, knots or kmh16", o.SpeedFormat)
+	}
+
+	if o.MaxDeclaredLength < 0 {
+		return NewValidationError("MaxDeclaredLength", "must not be negative", o.MaxDeclaredLength)
+	}
+
+	if o.MaxResidueSize < 0 {
+		return NewValidationError("MaxResidueSize", "must not be negative", o.MaxResidueSize)
+	}
+
+	if o.Parallelism < 0 {
+		return NewValidationError("Parallelism", "must not be negative", o.Parallelism)
+	}
+
+	if o.Resync != nil && (o.Resync.MaxScanBytes < 0 || o.Resync.MaxDiscardBytes < 0) {
+		return NewValidationError("Resync", "limits must not be negative", *o.Resync)
+	}
+
+	for proto := range o.ProtocolOptions {
+		effective := o.ForProtocol(proto)
+		if err := effective.Validate(); err != nil {
+			return fmt.Errorf("protocol 0x%02X: %w", proto, err)
+		}
+	}
+
 	return nil
 }
 
 // IsProduction returns true if options are safe for production use
+// Protocol-specific overrides are taken into account
 func (o *Options) IsProduction() bool {
-	return !o.SkipCRCValidation &&
-		!o.SkipStructureValidation &&
-		o.StrictMode &&
-		o.ValidateIMEIChecksum
+	if o.SkipCRCValidation || o.SkipStructureValidation || !o.StrictMode || !o.ValidateIMEIChecksum {
+		return false
+	}
+	for proto := range o.ProtocolOptions {
+		effective := o.ForProtocol(proto)
+		if !effective.IsProduction() {
+			return false
+		}
+	}
+	return true
 }
 
 // Clone creates a deep copy of the options
@@ -177,5 +579,15 @@ func (o *Options) Clone() Options {
 		offset := *o.TimeLocation
 		clone.TimeLocation = &offset
 	}
+	if o.Resync != nil {
+		resync := *o.Resync
+		clone.Resync = &resync
+	}
+	if o.ProtocolOptions != nil {
+		clone.ProtocolOptions = make(map[byte][]Option, len(o.ProtocolOptions))
+		for proto, opts := range o.ProtocolOptions {
+			clone.ProtocolOptions[proto] = append([]Option(nil), opts...)
+		}
+	}
 	return clone
 }
@@ -18,25 +18,25 @@ type AddressResponsePacket struct {
 	BasePacket
 
 	// ContentLength is the length of data between server flag and serial number
-	ContentLength uint8
+	ContentLength uint8 `json:"content_length"`
 
 	// ServerFlag is a 4-byte marker used by server to identify the alarm
-	ServerFlag [4]byte
+	ServerFlag [4]byte `json:"server_flag"`
 
 	// AlarmSMS is the alarm code flag (typically "ALARMSMS" in ASCII)
-	AlarmSMS string
+	AlarmSMS string `json:"alarm_sms"`
 
 	// Address is the parsed address string
 	// - For 0x17 (Chinese): UNICODE encoded
 	// - For 0x97 (English): ASCII/UTF-8 encoded
-	Address string
+	Address string `json:"address"`
 
 	// PhoneNumber is the destination phone number for SMS
 	// Typically "0" repeated 21 times for alarm packets uploaded to server
-	PhoneNumber string
+	PhoneNumber string `json:"phone_number"`
 
 	// Language indicates the language of the address
-	Language protocol.Language
+	Language protocol.Language `json:"language"`
 }
 
 // NewAddressResponsePacket creates a new address response packet
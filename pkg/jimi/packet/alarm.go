@@ -28,40 +28,40 @@ type AlarmPacket struct {
 	BasePacket
 
 	// DateTime is when the alarm was triggered
-	DateTime types.DateTime
+	DateTime types.DateTime `json:"time"`
 
 	// Satellites is the number of GPS satellites used
-	Satellites uint8
+	Satellites uint8 `json:"satellites"`
 
 	// Coordinates contains the GPS position
-	Coordinates types.Coordinates
+	Coordinates types.Coordinates `json:"coordinates"`
 
 	// Speed in km/h
-	Speed uint8
+	Speed uint8 `json:"speed"`
 
 	// CourseStatus contains heading and GPS status flags
-	CourseStatus types.CourseStatus
+	CourseStatus types.CourseStatus `json:"course"`
 
 	// LBSInfo contains cell tower information
-	LBSInfo types.LBSInfo
+	LBSInfo types.LBSInfo `json:"cell"`
 
 	// TerminalInfo contains device status
-	TerminalInfo types.TerminalInfo
+	TerminalInfo types.TerminalInfo `json:"terminal"`
 
 	// VoltageLevel indicates battery level
-	VoltageLevel protocol.VoltageLevel
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
 
 	// GSMSignal indicates network signal strength
-	GSMSignal protocol.GSMSignalStrength
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
 
 	// AlarmType indicates the type of alarm triggered
-	AlarmType protocol.AlarmType
+	AlarmType protocol.AlarmType `json:"alarm_type"`
 
 	// Language is the device language setting
-	Language protocol.Language
+	Language protocol.Language `json:"language"`
 
 	// Mileage is the mileage statistics from the device
-	Mileage uint32
+	Mileage uint32 `json:"mileage"`
 }
 
 // NewAlarmPacket creates a new AlarmPacket
@@ -100,6 +100,12 @@ func (p *AlarmPacket) HasTimestamp() bool {
 	return !p.DateTime.IsZero()
 }
 
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *AlarmPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
 // HasLocation implements PacketWithLocation interface
 func (p *AlarmPacket) HasLocation() bool {
 	return p.Coordinates.IsValid()
@@ -145,7 +151,7 @@ type AlarmMultiFencePacket struct {
 	AlarmPacket
 
 	// FenceID is the geo-fence identifier
-	FenceID uint8
+	FenceID uint8 `json:"fence_id"`
 }
 
 // Type implements Packet interface
@@ -168,13 +174,13 @@ type Alarm4GPacket struct {
 	AlarmPacket
 
 	// MCCMNC is the Mobile Country Code + Mobile Network Code
-	MCCMNC uint32
+	MCCMNC uint32 `json:"mcc_mnc"`
 
 	// ExtendedLBS contains additional LBS information for 4G
-	ExtendedLBS []types.LBSInfo
+	ExtendedLBS []types.LBSInfo `json:"extended_cells,omitempty"`
 
 	// FenceID is the geo-fence identifier (for multi-fence alarms)
-	FenceID uint8
+	FenceID uint8 `json:"fence_id"`
 }
 
 // Type implements Packet interface
@@ -192,3 +198,62 @@ func (p *Alarm4GPacket) String() string {
 		p.MCCMNC,
 		p.IsCritical())
 }
+
+// ACCStatusPacket represents an ACC on/off report (alarm types 0xFE/0xFF)
+// routed as a status event instead of an alarm.
+// The device still delivers it with an alarm protocol (0x26, 0x27 or 0xA4);
+// the original decoded packet is kept in Alarm.
+type ACCStatusPacket struct {
+	AlarmPacket
+
+	// Alarm is the original alarm packet (*AlarmPacket, *AlarmMultiFencePacket or *Alarm4GPacket)
+	Alarm Packet `json:"-"`
+}
+
+// NewACCStatusPacket wraps an ACC alarm as a status packet.
+// Returns nil if the packet is not an ACC on/off alarm.
+func NewACCStatusPacket(p Packet) *ACCStatusPacket {
+	var alarm *AlarmPacket
+	switch v := p.(type) {
+	case *AlarmPacket:
+		alarm = v
+	case *AlarmMultiFencePacket:
+		alarm = &v.AlarmPacket
+	case *Alarm4GPacket:
+		alarm = &v.AlarmPacket
+	default:
+		return nil
+	}
+
+	if alarm.AlarmType != protocol.AlarmACCOn && alarm.AlarmType != protocol.AlarmACCOff {
+		return nil
+	}
+
+	return &ACCStatusPacket{
+		AlarmPacket: *alarm,
+		Alarm:       p,
+	}
+}
+
+// Type implements Packet interface
+func (p *ACCStatusPacket) Type() string {
+	return "ACC Status"
+}
+
+// ACCOn returns true if the report is ACC on
+func (p *ACCStatusPacket) ACCOn() bool {
+	return p.AlarmType == protocol.AlarmACCOn
+}
+
+// String returns a human-readable representation
+func (p *ACCStatusPacket) String() string {
+	acc := "OFF"
+	if p.ACCOn() {
+		acc = "ON"
+	}
+	return fmt.Sprintf("ACCStatusPacket{ACC: %s, Time: %s, Pos: [%.6f, %.6f]}",
+		acc,
+		p.DateTime,
+		p.Latitude(),
+		p.Longitude())
+}
@@ -18,13 +18,13 @@ type OnlineCommandPacket struct {
 	BasePacket
 
 	// ServerFlag is a 4-byte identifier for the command
-	ServerFlag uint32
+	ServerFlag uint32 `json:"server_flag"`
 
 	// Command is the ASCII command string
-	Command string
+	Command string `json:"command"`
 
 	// CommandLength is the length of the command
-	CommandLength uint8
+	CommandLength uint8 `json:"command_length"`
 }
 
 // NewOnlineCommandPacket creates a new OnlineCommandPacket
@@ -70,13 +70,13 @@ type CommandResponsePacket struct {
 	BasePacket
 
 	// ServerFlag echoes the flag from the original command
-	ServerFlag uint32
+	ServerFlag uint32 `json:"server_flag"`
 
 	// Response is the ASCII response string
-	Response string
+	Response string `json:"response"`
 
 	// ResponseLength is the length of the response
-	ResponseLength uint8
+	ResponseLength uint8 `json:"response_length"`
 }
 
 // NewCommandResponsePacket creates a new CommandResponsePacket
@@ -130,16 +130,16 @@ type GPSAddressRequestPacket struct {
 	BasePacket
 
 	// GPS Data
-	DateTime     types.DateTime
-	Satellites   uint8
-	Coordinates  types.Coordinates
-	Speed        uint8
-	CourseStatus types.CourseStatus
+	DateTime     types.DateTime     `json:"time"`
+	Satellites   uint8              `json:"satellites"`
+	Coordinates  types.Coordinates  `json:"coordinates"`
+	Speed        uint8              `json:"speed"`
+	CourseStatus types.CourseStatus `json:"course"`
 
 	// Request-specific data
-	PhoneNumber string // 21 bytes ASCII, trimmed
-	AlarmType   protocol.AlarmType
-	Language    protocol.Language
+	PhoneNumber string             `json:"phone_number"` // 21 bytes ASCII, trimmed
+	AlarmType   protocol.AlarmType `json:"alarm_type"`
+	Language    protocol.Language  `json:"language"`
 }
 
 // NewGPSAddressRequestPacket creates a new GPSAddressRequestPacket
@@ -170,6 +170,12 @@ func (p *GPSAddressRequestPacket) HasTimestamp() bool {
 	return !p.DateTime.IsZero()
 }
 
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *GPSAddressRequestPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
 // HasLocation implements PacketWithLocation interface
 func (p *GPSAddressRequestPacket) HasLocation() bool {
 	return p.Coordinates.IsValid()
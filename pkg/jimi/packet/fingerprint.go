@@ -0,0 +1,160 @@
+package packet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Fingerprint returns a stable hash of the semantic content of a packet.
+//
+// Two packets have the same fingerprint when they are of the same type and
+// carry the same decoded values, including protocol and serial number.
+// RawData and ParsedAt are excluded, so the same packet decoded twice (or
+// re-encoded with a different CRC) yields the same fingerprint. Zero-valued
+// fields are skipped, which keeps fingerprints unchanged when new fields are
+// added to a packet type.
+//
+// The result is a lowercase hex SHA-256 digest, usable as a map key for
+// deduplication or as a stable identifier in tests and change detection.
+func Fingerprint(p Packet) string {
+	if p == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	v := reflect.ValueOf(p)
+	writeString(h, indirectType(v.Type()).String())
+	writeValue(h, v)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprintExcluded lists BasePacket fields that do not carry semantic content
+var fingerprintExcluded = map[string]bool{
+	"RawData":  true,
+	"ParsedAt": true,
+}
+
+var (
+	basePacketType = reflect.TypeOf(BasePacket{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// writeValue hashes v in a canonical, type-tagged form
+func writeValue(h hash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			h.Write([]byte{0})
+			return
+		}
+		h.Write([]byte{1})
+		elem := v.Elem()
+		if v.Kind() == reflect.Interface {
+			writeString(h, indirectType(elem.Type()).String())
+		}
+		writeValue(h, elem)
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if v.CanInterface() {
+				t := v.Interface().(time.Time)
+				writeUint(h, uint64(t.UnixNano()))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			fv := v.Field(i)
+			if v.Type() == basePacketType && fingerprintExcluded[field.Name] {
+				continue
+			}
+			if fv.IsZero() {
+				continue
+			}
+			writeString(h, field.Name)
+			writeValue(h, fv)
+		}
+		// End-of-struct marker so adjacent structs cannot alias
+		h.Write([]byte{0xFF})
+
+	case reflect.Slice, reflect.Array:
+		writeUint(h, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keyString(keys[i]) < keyString(keys[j])
+		})
+		writeUint(h, uint64(len(keys)))
+		for _, k := range keys {
+			writeValue(h, k)
+			writeValue(h, v.MapIndex(k))
+		}
+
+	case reflect.String:
+		writeString(h, v.String())
+
+	case reflect.Bool:
+		if v.Bool() {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(h, uint64(v.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(h, v.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		writeUint(h, math.Float64bits(v.Float()))
+	}
+}
+
+// writeString hashes a length-prefixed string
+func writeString(h hash.Hash, s string) {
+	writeUint(h, uint64(len(s)))
+	h.Write([]byte(s))
+}
+
+// writeUint hashes a fixed-width integer
+func writeUint(h hash.Hash, n uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	h.Write(buf[:])
+}
+
+// keyString orders map keys of basic kinds
+func keyString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(v.Int())^(1<<63))
+		return string(buf[:])
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], v.Uint())
+		return string(buf[:])
+	}
+	return v.String()
+}
+
+// indirectType strips pointer types
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
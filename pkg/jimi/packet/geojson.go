@@ -0,0 +1,67 @@
+package packet
+
+import "time"
+
+// GeoJSONFeature is a GeoJSON (RFC 7946) Feature
+type GeoJSONFeature struct {
+	// Type is always "Feature"
+	Type string `json:"type"`
+
+	// Geometry is the Point or LineString of the feature
+	Geometry GeoJSONGeometry `json:"geometry"`
+
+	// Properties describe the feature (speed, ACC, alarm...)
+	Properties map[string]any `json:"properties"`
+}
+
+// GeoJSONGeometry is a GeoJSON Point or LineString.
+// Positions are [longitude, latitude], as required by RFC 7946.
+type GeoJSONGeometry struct {
+	// Type is "Point" or "LineString"
+	Type string `json:"type"`
+
+	// Coordinates is a [2]float64 for a Point and a [][2]float64 for a LineString
+	Coordinates any `json:"coordinates"`
+}
+
+// NewGeoJSONPoint creates a Point feature at the given signed coordinates
+func NewGeoJSONPoint(lat, lon float64, properties map[string]any) GeoJSONFeature {
+	if properties == nil {
+		properties = make(map[string]any)
+	}
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   GeoJSONGeometry{Type: "Point", Coordinates: [2]float64{lon, lat}},
+		Properties: properties,
+	}
+}
+
+// ToGeoJSONFeature returns the fix as a GeoJSON Point feature with its
+// speed (km/h), course, satellites, ACC and upload mode as properties
+func (p *LocationPacket) ToGeoJSONFeature() GeoJSONFeature {
+	return NewGeoJSONPoint(p.Latitude(), p.Longitude(), map[string]any{
+		"type":        p.Type(),
+		"time":        p.DateTime.Time.UTC().Format(time.RFC3339),
+		"speed":       p.Speed,
+		"course":      p.Heading(),
+		"satellites":  p.Satellites,
+		"positioned":  p.IsPositioned(),
+		"acc":         p.ACCOn(),
+		"upload_mode": p.UploadMode.String(),
+	})
+}
+
+// ToGeoJSONFeature returns the alarm as a GeoJSON Point feature with the
+// alarm name and the fix details as properties
+func (p *AlarmPacket) ToGeoJSONFeature() GeoJSONFeature {
+	return NewGeoJSONPoint(p.Latitude(), p.Longitude(), map[string]any{
+		"type":       p.Type(),
+		"time":       p.DateTime.Time.UTC().Format(time.RFC3339),
+		"speed":      p.Speed,
+		"course":     p.CourseStatus.GetCourse(),
+		"satellites": p.Satellites,
+		"positioned": p.IsPositioned(),
+		"acc":        p.TerminalInfo.ACCOn(),
+		"alarm":      p.AlarmType.String(),
+	})
+}
@@ -0,0 +1,62 @@
+package packet
+
+import (
+	"fmt"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// GPSLBSStatusPacket represents a combined GPS, LBS and status packet
+// (Protocols 0x16, 0x32 and 0x33)
+//
+// The content has the layout of an alarm packet without a fence ID: GPS
+// position, the serving cell and the status block (terminal info, voltage,
+// GSM signal, alert and language), optionally followed by the mileage.
+// 0x16 carries 2G/3G cell data like 0x26; 0x32 and 0x33 carry length-prefixed
+// 4G cell data like 0xA4. AlarmType is usually protocol.AlarmNormal.
+//
+// Content structure:
+// - DateTime: 6 bytes
+// - GPS Info Length: 1 byte
+// - Latitude: 4 bytes
+// - Longitude: 4 bytes
+// - Speed: 1 byte
+// - Course/Status: 2 bytes
+// - LBS Length: 1 byte
+// - LBS Info: 8 bytes (0x16) or LBS Length - 1 bytes (0x32, 0x33)
+// - Terminal Info: 1 byte
+// - Voltage Level: 1 byte
+// - GSM Signal: 1 byte
+// - Alert: 1 byte
+// - Language: 1 byte
+// - Mileage: 4 bytes (optional)
+type GPSLBSStatusPacket struct {
+	AlarmPacket
+
+	// MCCMNC is the Mobile Country Code + Mobile Network Code (4G variants only)
+	MCCMNC uint32 `json:"mcc_mnc"`
+}
+
+// Type implements Packet interface
+func (p *GPSLBSStatusPacket) Type() string {
+	if p.Is4G() {
+		return "GPS LBS Status 4G"
+	}
+	return "GPS LBS Status"
+}
+
+// Is4G returns true for the 4G variants (0x32, 0x33)
+func (p *GPSLBSStatusPacket) Is4G() bool {
+	return p.ProtocolNum == protocol.ProtocolGPSLBSStatus4G || p.ProtocolNum == protocol.ProtocolGPSLBSStatus4GAlt
+}
+
+// String returns a human-readable representation
+func (p *GPSLBSStatusPacket) String() string {
+	return fmt.Sprintf("GPSLBSStatusPacket{Protocol: 0x%02X, Time: %s, Pos: [%.6f, %.6f], Cell: %s, Alert: %s}",
+		p.ProtocolNum,
+		p.DateTime,
+		p.Latitude(),
+		p.Longitude(),
+		p.LBSInfo,
+		p.AlarmType)
+}
@@ -20,19 +20,19 @@ type HeartbeatPacket struct {
 	BasePacket
 
 	// TerminalInfo contains device status flags
-	TerminalInfo types.TerminalInfo
+	TerminalInfo types.TerminalInfo `json:"terminal"`
 
 	// VoltageLevel indicates battery level
-	VoltageLevel protocol.VoltageLevel
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
 
 	// GSMSignal indicates network signal strength
-	GSMSignal protocol.GSMSignalStrength
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
 
 	// ExtendedInfo contains additional status (if present)
-	ExtendedInfo uint16
+	ExtendedInfo uint16 `json:"extended_info"`
 
 	// HasExtended indicates if extended info is present
-	HasExtended bool
+	HasExtended bool `json:"has_extended"`
 }
 
 // NewHeartbeatPacket creates a new HeartbeatPacket
@@ -18,105 +18,105 @@ type InfoTransferPacket struct {
 	BasePacket
 
 	// SubProtocol identifies the type of information being transferred
-	SubProtocol protocol.InfoType
+	SubProtocol protocol.InfoType `json:"sub_protocol"`
 
 	// Data contains the raw information data
-	Data []byte
+	Data []byte `json:"data"`
 
 	// Parsed fields depending on SubProtocol:
 
 	// ExternalVoltage in millivolts (when SubProtocol = InfoTypeExternalVoltage)
-	ExternalVoltage uint16
+	ExternalVoltage uint16 `json:"external_voltage,omitempty"`
 
 	// ICCID (when SubProtocol = InfoTypeICCID)
-	ICCID string
+	ICCID string `json:"iccid,omitempty"`
 
 	// IMEI (when SubProtocol = InfoTypeICCID)
-	IMEI string
+	IMEI string `json:"imei,omitempty"`
 
 	// IMSI (when SubProtocol = InfoTypeICCID)
-	IMSI string
+	IMSI string `json:"imsi,omitempty"`
 
 	// GPSModuleStatus (when SubProtocol = InfoTypeGPSStatus)
-	GPSStatus protocol.GPSModuleStatus
+	GPSStatus protocol.GPSModuleStatus `json:"gps_status,omitempty"`
 
 	// TerminalSync contains parsed terminal sync data (when SubProtocol = InfoTypeTerminalSync)
-	TerminalSync *TerminalSyncData
+	TerminalSync *TerminalSyncData `json:"terminal_sync,omitempty"`
 
 	// DoorStatus (when SubProtocol = InfoTypeDoorStatus)
-	DoorStatus *DoorStatusData
+	DoorStatus *DoorStatusData `json:"door_status,omitempty"`
 
 	// GPSStatusInfo contains detailed GPS status (when SubProtocol = InfoTypeGPSStatus)
-	GPSStatusInfo *GPSStatusData
+	GPSStatusInfo *GPSStatusData `json:"gps_status_info,omitempty"`
 }
 
 // TerminalSyncData contains parsed terminal synchronization information
 type TerminalSyncData struct {
 	// Raw string data
-	RawString string
+	RawString string `json:"raw"`
 
 	// Alarm configuration bytes (hex values)
-	ALM1 string // Alarm byte 1
-	ALM2 string // Alarm byte 2
-	ALM3 string // Alarm byte 3
-	ALM4 string // Alarm byte 4
+	ALM1 string `json:"alm1"` // Alarm byte 1
+	ALM2 string `json:"alm2"` // Alarm byte 2
+	ALM3 string `json:"alm3"` // Alarm byte 3
+	ALM4 string `json:"alm4"` // Alarm byte 4
 
 	// Status byte
-	STA1 string // Status byte 1
+	STA1 string `json:"sta1"` // Status byte 1
 
 	// Fuel/power cutoff status
-	DYD string
+	DYD string `json:"dyd"`
 
 	// SOS numbers (comma separated)
-	SOSNumbers []string
+	SOSNumbers []string `json:"sos_numbers"`
 
 	// Center number
-	CenterNumber string
+	CenterNumber string `json:"center_number"`
 
 	// Geofences
-	Geofences []GeofenceConfig
+	Geofences []GeofenceConfig `json:"geofences"`
 
 	// Mode settings
-	Mode string
+	Mode string `json:"mode"`
 
 	// IMSI from sync data
-	IMSI string
+	IMSI string `json:"imsi"`
 
 	// ICCID from sync data
-	ICCID string
+	ICCID string `json:"iccid"`
 }
 
 // GeofenceConfig represents a geofence configuration
 type GeofenceConfig struct {
-	ID        int
-	Enabled   bool
-	Shape     int // 0=circle, 1=polygon
-	Latitude  float64
-	Longitude float64
-	Radius    int    // meters
-	Direction string // "IN", "OUT", "IN or OUT"
-	AlarmType int
+	ID        int     `json:"id"`
+	Enabled   bool    `json:"enabled"`
+	Shape     int     `json:"shape"` // 0=circle, 1=polygon
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    int     `json:"radius"`    // meters
+	Direction string  `json:"direction"` // "IN", "OUT", "IN or OUT"
+	AlarmType int     `json:"alarm_type"`
 }
 
 // DoorStatusData contains door status information
 type DoorStatusData struct {
-	DoorOpen    bool // bit0: 1=ON (open), 0=OFF (closed)
-	TriggerHigh bool // bit1: 1=Level high, 0=Level low
-	IOPortHigh  bool // bit2: 1=High, 0=Low
+	DoorOpen    bool `json:"door_open"`    // bit0: 1=ON (open), 0=OFF (closed)
+	TriggerHigh bool `json:"trigger_high"` // bit1: 1=Level high, 0=Level low
+	IOPortHigh  bool `json:"io_port_high"` // bit2: 1=High, 0=Low
 }
 
 // GPSStatusData contains detailed GPS module status information
 type GPSStatusData struct {
-	ModuleStatus          protocol.GPSModuleStatus
-	SatellitesInFix       int
-	SatelliteStrengths    []int // Signal strength of satellites in fix
-	VisibleSatellites     int
-	VisibleStrengths      []int // Signal strength of visible satellites
-	BDSModuleStatus       protocol.GPSModuleStatus
-	BDSSatellitesInFix    int
-	BDSSatelliteStrengths []int
-	BDSVisibleSatellites  int
-	BDSVisibleStrengths   []int
+	ModuleStatus          protocol.GPSModuleStatus `json:"module_status"`
+	SatellitesInFix       int                      `json:"satellites_in_fix"`
+	SatelliteStrengths    []int                    `json:"satellite_strengths"` // Signal strength of satellites in fix
+	VisibleSatellites     int                      `json:"visible_satellites"`
+	VisibleStrengths      []int                    `json:"visible_strengths"` // Signal strength of visible satellites
+	BDSModuleStatus       protocol.GPSModuleStatus `json:"bds_module_status"`
+	BDSSatellitesInFix    int                      `json:"bds_satellites_in_fix"`
+	BDSSatelliteStrengths []int                    `json:"bds_satellite_strengths"`
+	BDSVisibleSatellites  int                      `json:"bds_visible_satellites"`
+	BDSVisibleStrengths   []int                    `json:"bds_visible_strengths"`
 }
 
 // NewInfoTransferPacket creates a new InfoTransferPacket
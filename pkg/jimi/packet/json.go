@@ -0,0 +1,443 @@
+package packet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// JSON encoding of the packets.
+//
+// Every packet encodes as one object with the packet type, the schema
+// version, the common fields of BasePacket and its own fields under the
+// names of their json struct tags. Field names are snake_case and stable; value types encode
+// as documented in the types package. Protocol enums (alarm type, voltage
+// level, ...) encode as their protocol numbers.
+//
+//	{
+//	  "type": "GPS Location",
+//	  "schema": 1,
+//	  "protocol": 34,
+//	  "serial": 1,
+//	  "raw": "eHgiIhgBAQ...",  (base64)
+//	  "parsed_at": "2024-06-15T14:30:01.123Z",
+//	  "time": "2024-06-15T14:30:00Z",
+//	  "satellites": 9,
+//	  "coordinates": {"latitude": 22.546, "longitude": 113.945},
+//	  ...
+//	}
+//
+// Packets whose device sent an all-zero date-time (see types.DateTime
+// ZeroTime) carry the receive time in "time" and "zero_time": true.
+//
+// Use UnmarshalPacket to decode a packet of unknown type.
+//
+// This shape mirrors the packet structs, for round trips between Go
+// programs; export.Record is the flat, integration-oriented form. Both
+// follow the compatibility rules of SchemaVersion.
+
+// SchemaVersion is the version of the JSON layout of the packets and of
+// export.Record. Within a version, existing keys keep their name, type and
+// meaning; new keys may be added. Renaming, removing or retyping a key
+// requires incrementing it.
+const SchemaVersion = 1
+
+// The *Fields types have the fields of a packet without its methods, so
+// encoding/json uses the struct tags instead of calling MarshalJSON again
+type (
+	loginFields           LoginPacket
+	heartbeatFields       HeartbeatPacket
+	locationFields        LocationPacket
+	alarmFields           AlarmPacket
+	lbsFields             LBSPacket
+	lbs4GFields           LBS4GPacket
+	wifiInfoFields        WiFiInfoPacket
+	infoTransferFields    InfoTransferPacket
+	onlineCommandFields   OnlineCommandPacket
+	commandResponseFields CommandResponsePacket
+	addressRequestFields  GPSAddressRequestPacket
+	addressResponseFields AddressResponsePacket
+	timeCalibrationFields TimeCalibrationPacket
+)
+
+// location4GFields is the JSON form of Location4GPacket
+type location4GFields struct {
+	locationFields
+	MCCMNC      uint32          `json:"mcc_mnc"`
+	ExtendedLBS []types.LBSInfo `json:"extended_cells,omitempty"`
+}
+
+// alarmMultiFenceFields is the JSON form of AlarmMultiFencePacket
+type alarmMultiFenceFields struct {
+	alarmFields
+	FenceID uint8 `json:"fence_id"`
+}
+
+// alarm4GFields is the JSON form of Alarm4GPacket
+type alarm4GFields struct {
+	alarmFields
+	MCCMNC      uint32          `json:"mcc_mnc"`
+	ExtendedLBS []types.LBSInfo `json:"extended_cells,omitempty"`
+	FenceID     uint8           `json:"fence_id"`
+}
+
+// gpsLBSStatusFields is the JSON form of GPSLBSStatusPacket
+type gpsLBSStatusFields struct {
+	alarmFields
+	MCCMNC uint32 `json:"mcc_mnc"`
+}
+
+// accStatusFields is the JSON form of ACCStatusPacket
+type accStatusFields struct {
+	alarmFields
+	ACCOn bool            `json:"acc_on"`
+	Alarm json.RawMessage `json:"alarm"` // the original alarm packet
+}
+
+// withType encodes fields (a JSON object) of p with the packet type and
+// the schema version as its first members, and "zero_time" last when p has
+// a substituted date-time
+func withType(p Packet, fields any) ([]byte, error) {
+	obj, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	name, err := json.Marshal(p.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(obj)+len(name)+30)
+	out = append(out, `{"type":`...)
+	out = append(out, name...)
+	out = append(out, `,"schema":`...)
+	out = strconv.AppendInt(out, SchemaVersion, 10)
+	if len(obj) > 2 {
+		out = append(out, ',')
+	}
+	if !HasZeroTime(p) {
+		return append(out, obj[1:]...), nil
+	}
+	out = append(out, obj[1:len(obj)-1]...)
+	if len(obj) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, `"zero_time":true}`...), nil
+}
+
+// unmarshalTimed decodes fields with the date-time dt, restoring its
+// "zero_time" flag
+func unmarshalTimed(data []byte, fields any, dt *types.DateTime) error {
+	if err := json.Unmarshal(data, fields); err != nil {
+		return err
+	}
+	var flag struct {
+		ZeroTime bool `json:"zero_time"`
+	}
+	if err := json.Unmarshal(data, &flag); err != nil {
+		return err
+	}
+	dt.ZeroTime = flag.ZeroTime
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *LoginPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*loginFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *LoginPacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*loginFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *HeartbeatPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*heartbeatFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *HeartbeatPacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*heartbeatFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *LocationPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*locationFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *LocationPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*locationFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *AlarmPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*alarmFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *AlarmPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*alarmFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *LBSPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*lbsFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *LBSPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*lbsFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *LBS4GPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*lbs4GFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *LBS4GPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*lbs4GFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *WiFiInfoPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*wifiInfoFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *WiFiInfoPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*wifiInfoFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *InfoTransferPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*infoTransferFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *InfoTransferPacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*infoTransferFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *OnlineCommandPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*onlineCommandFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *OnlineCommandPacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*onlineCommandFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *CommandResponsePacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*commandResponseFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *CommandResponsePacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*commandResponseFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *GPSAddressRequestPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*addressRequestFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *GPSAddressRequestPacket) UnmarshalJSON(data []byte) error {
+	return unmarshalTimed(data, (*addressRequestFields)(p), &p.DateTime)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *AddressResponsePacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*addressResponseFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *AddressResponsePacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*addressResponseFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *TimeCalibrationPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, (*timeCalibrationFields)(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *TimeCalibrationPacket) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*timeCalibrationFields)(p))
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *Location4GPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, location4GFields{locationFields(p.LocationPacket), p.MCCMNC, p.ExtendedLBS})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Location4GPacket) UnmarshalJSON(data []byte) error {
+	var v location4GFields
+	if err := unmarshalTimed(data, &v, &v.DateTime); err != nil {
+		return err
+	}
+	*p = Location4GPacket{LocationPacket(v.locationFields), v.MCCMNC, v.ExtendedLBS}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *AlarmMultiFencePacket) MarshalJSON() ([]byte, error) {
+	return withType(p, alarmMultiFenceFields{alarmFields(p.AlarmPacket), p.FenceID})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *AlarmMultiFencePacket) UnmarshalJSON(data []byte) error {
+	var v alarmMultiFenceFields
+	if err := unmarshalTimed(data, &v, &v.DateTime); err != nil {
+		return err
+	}
+	*p = AlarmMultiFencePacket{AlarmPacket(v.alarmFields), v.FenceID}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *Alarm4GPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, alarm4GFields{alarmFields(p.AlarmPacket), p.MCCMNC, p.ExtendedLBS, p.FenceID})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Alarm4GPacket) UnmarshalJSON(data []byte) error {
+	var v alarm4GFields
+	if err := unmarshalTimed(data, &v, &v.DateTime); err != nil {
+		return err
+	}
+	*p = Alarm4GPacket{AlarmPacket(v.alarmFields), v.MCCMNC, v.ExtendedLBS, v.FenceID}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *GPSLBSStatusPacket) MarshalJSON() ([]byte, error) {
+	return withType(p, gpsLBSStatusFields{alarmFields(p.AlarmPacket), p.MCCMNC})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *GPSLBSStatusPacket) UnmarshalJSON(data []byte) error {
+	var v gpsLBSStatusFields
+	if err := unmarshalTimed(data, &v, &v.DateTime); err != nil {
+		return err
+	}
+	*p = GPSLBSStatusPacket{AlarmPacket(v.alarmFields), v.MCCMNC}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// The original alarm packet is nested under "alarm".
+func (p *ACCStatusPacket) MarshalJSON() ([]byte, error) {
+	var alarm json.RawMessage
+	if p.Alarm != nil {
+		var err error
+		if alarm, err = json.Marshal(p.Alarm); err != nil {
+			return nil, err
+		}
+	}
+	return withType(p, accStatusFields{alarmFields(p.AlarmPacket), p.ACCOn(), alarm})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// The packet is rebuilt from the nested original alarm packet.
+func (p *ACCStatusPacket) UnmarshalJSON(data []byte) error {
+	var v accStatusFields
+	if err := unmarshalTimed(data, &v, &v.DateTime); err != nil {
+		return err
+	}
+	if len(v.Alarm) == 0 || string(v.Alarm) == "null" {
+		*p = ACCStatusPacket{AlarmPacket: AlarmPacket(v.alarmFields)}
+		return nil
+	}
+
+	alarm, err := UnmarshalPacket(v.Alarm)
+	if err != nil {
+		return fmt.Errorf("acc status: %w", err)
+	}
+	acc := NewACCStatusPacket(alarm)
+	if acc == nil {
+		return fmt.Errorf("acc status: %s is not an ACC on/off alarm", alarm.Type())
+	}
+	*p = *acc
+	return nil
+}
+
+// UnmarshalPacket decodes a packet encoded with json.Marshal, choosing the
+// packet type from its "type" and "protocol" members. Unknown protocols
+// decode to *BasePacket.
+func UnmarshalPacket(data []byte) (Packet, error) {
+	var head struct {
+		Type     string `json:"type"`
+		Protocol *byte  `json:"protocol"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	if head.Protocol == nil {
+		return nil, fmt.Errorf("packet json: missing protocol")
+	}
+
+	var p Packet
+	switch {
+	case head.Type == "ACC Status":
+		p = &ACCStatusPacket{}
+	default:
+		p = newPacket(*head.Protocol)
+	}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("packet json (%s): %w", head.Type, err)
+	}
+	return p, nil
+}
+
+// newPacket returns an empty packet of the type decoded for a protocol number
+func newPacket(protocolNum byte) Packet {
+	switch protocolNum {
+	case protocol.ProtocolLogin:
+		return &LoginPacket{}
+	case protocol.ProtocolHeartbeat:
+		return &HeartbeatPacket{}
+	case protocol.ProtocolGPSLocation:
+		return &LocationPacket{}
+	case protocol.ProtocolGPSLocation4G:
+		return &Location4GPacket{}
+	case protocol.ProtocolLBSMultiBase:
+		return &LBSPacket{}
+	case protocol.ProtocolLBSMultiBase4G:
+		return &LBS4GPacket{}
+	case protocol.ProtocolWiFi, protocol.ProtocolWiFi4G:
+		return &WiFiInfoPacket{}
+	case protocol.ProtocolAlarm:
+		return &AlarmPacket{}
+	case protocol.ProtocolAlarmMultiFence:
+		return &AlarmMultiFencePacket{}
+	case protocol.ProtocolAlarmMultiFence4G:
+		return &Alarm4GPacket{}
+	case protocol.ProtocolGPSAddressRequest:
+		return &GPSAddressRequestPacket{}
+	case protocol.ProtocolGPSLBSStatus, protocol.ProtocolGPSLBSStatus4G, protocol.ProtocolGPSLBSStatus4GAlt:
+		return &GPSLBSStatusPacket{}
+	case protocol.ProtocolOnlineCommand:
+		return &OnlineCommandPacket{}
+	case protocol.ProtocolCommandResponse, protocol.ProtocolCommandResponseOld:
+		return &CommandResponsePacket{}
+	case protocol.ProtocolTimeCalibration:
+		return &TimeCalibrationPacket{}
+	case protocol.ProtocolInfoTransfer:
+		return &InfoTransferPacket{}
+	case protocol.ProtocolAddressResponseChinese, protocol.ProtocolAddressResponseEnglish:
+		return &AddressResponsePacket{}
+	default:
+		return &BasePacket{}
+	}
+}
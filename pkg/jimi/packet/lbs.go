@@ -25,34 +25,34 @@ type LBSPacket struct {
 	BasePacket
 
 	// DateTime is when the LBS data was recorded
-	DateTime types.DateTime
+	DateTime types.DateTime `json:"time"`
 
 	// LBSInfo contains primary cell tower information
-	LBSInfo types.LBSInfo
+	LBSInfo types.LBSInfo `json:"cell"`
 
 	// NeighborCells contains information about neighboring cell towers
-	NeighborCells []types.LBSInfo
+	NeighborCells []types.LBSInfo `json:"neighbor_cells"`
 
 	// TimingAdvance is the timing advance value
-	TimingAdvance uint8
+	TimingAdvance uint8 `json:"timing_advance"`
 
 	// Language is the language setting for the device
-	Language protocol.Language
+	Language protocol.Language `json:"language"`
 
 	// TerminalInfo contains device status (if present)
-	TerminalInfo types.TerminalInfo
+	TerminalInfo types.TerminalInfo `json:"terminal"`
 
 	// VoltageLevel indicates battery level (if present)
-	VoltageLevel protocol.VoltageLevel
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
 
 	// GSMSignal indicates network signal strength (if present)
-	GSMSignal protocol.GSMSignalStrength
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
 
 	// UploadMode indicates why this data was uploaded
-	UploadMode protocol.UploadMode
+	UploadMode protocol.UploadMode `json:"upload_mode"`
 
 	// HasStatus indicates if terminal status fields are present
-	HasStatus bool
+	HasStatus bool `json:"has_status"`
 }
 
 // NewLBSPacket creates a new LBSPacket
@@ -93,6 +93,12 @@ func (p *LBSPacket) HasTimestamp() bool {
 	return !p.DateTime.IsZero()
 }
 
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *LBSPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
 // String returns a human-readable representation
 func (p *LBSPacket) String() string {
 	return fmt.Sprintf("LBSPacket{Time: %s, %s}", p.DateTime, p.LBSInfo)
@@ -103,25 +109,25 @@ type LBS4GPacket struct {
 	BasePacket
 
 	// DateTime is when the LBS data was recorded
-	DateTime types.DateTime
+	DateTime types.DateTime `json:"time"`
 
 	// LBSInfo contains primary cell tower information
-	LBSInfo types.LBSInfo
+	LBSInfo types.LBSInfo `json:"cell"`
 
 	// NeighborCells contains information about neighboring cell towers
-	NeighborCells []types.LBSInfo
+	NeighborCells []types.LBSInfo `json:"neighbor_cells"`
 
 	// TerminalInfo contains device status
-	TerminalInfo types.TerminalInfo
+	TerminalInfo types.TerminalInfo `json:"terminal"`
 
 	// VoltageLevel indicates battery level
-	VoltageLevel protocol.VoltageLevel
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
 
 	// GSMSignal indicates network signal strength
-	GSMSignal protocol.GSMSignalStrength
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
 
 	// UploadMode indicates why this data was uploaded
-	UploadMode protocol.UploadMode
+	UploadMode protocol.UploadMode `json:"upload_mode"`
 }
 
 // NewLBS4GPacket creates a new LBS4GPacket
@@ -159,6 +165,12 @@ func (p *LBS4GPacket) HasTimestamp() bool {
 	return !p.DateTime.IsZero()
 }
 
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *LBS4GPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
 // String returns a human-readable representation
 func (p *LBS4GPacket) String() string {
 	return fmt.Sprintf("LBS4GPacket{Time: %s, %s, Neighbors: %d}",
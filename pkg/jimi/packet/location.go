@@ -22,56 +22,61 @@ type LocationPacket struct {
 	BasePacket
 
 	// DateTime is when the location was recorded by the device
-	DateTime types.DateTime
+	DateTime types.DateTime `json:"time"`
 
 	// Satellites is the number of GPS satellites used
-	Satellites uint8
+	Satellites uint8 `json:"satellites"`
 
 	// Coordinates contains the GPS position
-	Coordinates types.Coordinates
+	Coordinates types.Coordinates `json:"coordinates"`
 
-	// Speed in km/h
-	Speed uint8
+	// Speed in km/h (above 255 only with the 2-byte speed of some firmware,
+	// see jimi.WithSpeedFormat)
+	Speed uint16 `json:"speed"`
 
 	// CourseStatus contains heading and GPS status flags
-	CourseStatus types.CourseStatus
+	CourseStatus types.CourseStatus `json:"course"`
 
 	// LBSInfo contains cell tower information
-	LBSInfo types.LBSInfo
+	LBSInfo types.LBSInfo `json:"cell"`
 
 	// TerminalInfo contains device status (if present)
 	// Note: For GPS Location packets, this contains other status bits but NOT ACC status.
 	// ACC status is stored in the dedicated 'ACC' field below.
-	TerminalInfo types.TerminalInfo
+	TerminalInfo types.TerminalInfo `json:"terminal"`
 
 	// ACC indicates whether the vehicle ignition is ON (true) or OFF (false).
 	// For GPS Location packets (0x22 and 0xA0), ACC is a dedicated byte where:
 	// - 0x00 = ACC off
 	// - 0x01 = ACC on
 	// This is different from heartbeat/alarm packets where ACC is bit 1 of a status byte.
-	ACC bool
+	ACC bool `json:"acc"`
 
 	// VoltageLevel indicates battery level (if present)
-	VoltageLevel protocol.VoltageLevel
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
 
 	// GSMSignal indicates network signal strength (if present)
-	GSMSignal protocol.GSMSignalStrength
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
 
 	// UploadMode indicates why this location was uploaded
-	UploadMode protocol.UploadMode
+	UploadMode protocol.UploadMode `json:"upload_mode"`
 
 	// IsReupload indicates if this is a re-uploaded packet
-	IsReupload bool
+	IsReupload bool `json:"reupload"`
 
 	// Mileage is the mileage statistics from the device
-	Mileage uint32
+	Mileage uint32 `json:"mileage"`
 
 	// HasStatus indicates if terminal status fields are present
-	HasStatus bool
+	HasStatus bool `json:"has_status"`
+
+	// HasStatusTail indicates that VoltageLevel and GSMSignal were sent in the
+	// optional status tail after the mileage (0x22 of some firmwares)
+	HasStatusTail bool `json:"has_status_tail,omitempty"`
 }
 
 // NewLocationPacket creates a new LocationPacket
-func NewLocationPacket(dt types.DateTime, coords types.Coordinates, speed uint8, course types.CourseStatus) *LocationPacket {
+func NewLocationPacket(dt types.DateTime, coords types.Coordinates, speed uint16, course types.CourseStatus) *LocationPacket {
 	return &LocationPacket{
 		BasePacket: BasePacket{
 			ProtocolNum: protocol.ProtocolGPSLocation,
@@ -113,6 +118,12 @@ func (p *LocationPacket) HasTimestamp() bool {
 	return !p.DateTime.IsZero()
 }
 
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *LocationPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
 // HasLocation implements PacketWithLocation interface
 func (p *LocationPacket) HasLocation() bool {
 	return p.Coordinates.IsValid()
@@ -201,10 +212,10 @@ type Location4GPacket struct {
 	LocationPacket
 
 	// MCCMNC is the Mobile Country Code + Mobile Network Code
-	MCCMNC uint32
+	MCCMNC uint32 `json:"mcc_mnc"`
 
 	// ExtendedLBS contains additional LBS information for 4G
-	ExtendedLBS []types.LBSInfo
+	ExtendedLBS []types.LBSInfo `json:"extended_cells,omitempty"`
 }
 
 // Type implements Packet interface
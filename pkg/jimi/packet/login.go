@@ -11,20 +11,24 @@ import (
 // This is the first packet sent by the device when connecting
 //
 // Content structure:
-// - IMEI: 8 bytes (BCD encoded)
+// - IMEI: 8 bytes (BCD encoded, see types.DeviceIDFromBCD)
 // - Model Identification Code: 2 bytes
 // - Timezone/Language: 2 bytes
 type LoginPacket struct {
 	BasePacket
 
-	// IMEI is the device identifier (15 digits)
-	IMEI types.IMEI
+	// DeviceID is the canonical device identifier: the IMEI, or the 16-digit
+	// identifier of devices that report one instead
+	DeviceID types.DeviceID `json:"device_id"`
+
+	// IMEI is the device IMEI (15 digits); unset for 16-digit identifiers
+	IMEI types.IMEI `json:"imei"`
 
 	// ModelID is the device model identification code
-	ModelID uint16
+	ModelID uint16 `json:"model_id"`
 
 	// Timezone contains timezone offset and language setting
-	Timezone types.Timezone
+	Timezone types.Timezone `json:"timezone"`
 }
 
 // NewLoginPacket creates a new LoginPacket
@@ -34,6 +38,7 @@ func NewLoginPacket(imei types.IMEI, modelID uint16, tz types.Timezone) *LoginPa
 			ProtocolNum: protocol.ProtocolLogin,
 			ParsedAt:    time.Now(),
 		},
+		DeviceID: types.DeviceID(imei.String()),
 		IMEI:     imei,
 		ModelID:  modelID,
 		Timezone: tz,
@@ -53,20 +58,24 @@ func (p *LoginPacket) Timestamp() time.Time {
 
 // Validate implements Packet interface
 func (p *LoginPacket) Validate() error {
-	if !p.IMEI.IsValid() {
+	if p.GetIMEI() == "" {
 		return &ValidationError{Field: "IMEI", Reason: "invalid IMEI"}
 	}
 	return nil
 }
 
 // GetIMEI implements PacketWithIMEI interface
+// It returns the canonical device ID, which is the IMEI for most devices
 func (p *LoginPacket) GetIMEI() string {
+	if p.DeviceID.IsValid() {
+		return p.DeviceID.String()
+	}
 	return p.IMEI.String()
 }
 
 // String returns a human-readable representation
 func (p *LoginPacket) String() string {
-	return "LoginPacket{IMEI: " + p.IMEI.String() + ", ModelID: " + formatModelID(p.ModelID) + ", Timezone: " + p.Timezone.String() + "}"
+	return "LoginPacket{IMEI: " + p.GetIMEI() + ", ModelID: " + formatModelID(p.ModelID) + ", Timezone: " + p.Timezone.String() + "}"
 }
 
 // formatModelID formats the model ID as hex
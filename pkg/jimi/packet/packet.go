@@ -34,10 +34,10 @@ type Packet interface {
 // BasePacket contains fields common to all packets
 // Specific packet types should embed this struct
 type BasePacket struct {
-	ProtocolNum byte      // Protocol number (e.g., 0x01, 0x22, etc.)
-	SerialNum   uint16    // Information serial number
-	RawData     []byte    // Original raw packet bytes
-	ParsedAt    time.Time // When this packet was parsed
+	ProtocolNum byte      `json:"protocol"`           // Protocol number (e.g., 0x01, 0x22, etc.)
+	SerialNum   uint16    `json:"serial"`             // Information serial number
+	RawData     []byte    `json:"raw,omitempty"`      // Original raw packet bytes
+	ParsedAt    time.Time `json:"parsed_at,omitzero"` // When this packet was parsed
 }
 
 // ProtocolNumber implements Packet interface
@@ -85,6 +85,14 @@ func (p *BasePacket) Type() string {
 		return "Alarm 4G"
 	case protocol.ProtocolGPSAddressRequest:
 		return "GPS Address Request"
+	case protocol.ProtocolGPSLBSStatus:
+		return "GPS LBS Status"
+	case protocol.ProtocolGPSLBSStatus4G, protocol.ProtocolGPSLBSStatus4GAlt:
+		return "GPS LBS Status 4G"
+	case protocol.ProtocolWiFi:
+		return "WiFi Info"
+	case protocol.ProtocolWiFi4G:
+		return "WiFi Info 4G"
 	case protocol.ProtocolOnlineCommand:
 		return "Online Command"
 	case protocol.ProtocolCommandResponse:
@@ -147,6 +155,13 @@ type PacketWithTimestamp interface {
 	HasTimestamp() bool
 }
 
+// HasZeroTime reports whether p carries an all-zero date-time replaced by
+// the receive time (see jimi.WithAcceptZeroTime)
+func HasZeroTime(p Packet) bool {
+	z, ok := p.(interface{ HasZeroTime() bool })
+	return ok && z.HasZeroTime()
+}
+
 // PacketWithLocation is an interface for packets that contain GPS location
 type PacketWithLocation interface {
 	Packet
@@ -190,6 +205,15 @@ func IsLocationPacket(p Packet) bool {
 	return proto == protocol.ProtocolGPSLocation || proto == protocol.ProtocolGPSLocation4G
 }
 
+// IsGPSLBSStatusPacket returns true if the packet is a combined GPS, LBS and
+// status packet (0x16, 0x32 or 0x33)
+func IsGPSLBSStatusPacket(p Packet) bool {
+	proto := p.ProtocolNumber()
+	return proto == protocol.ProtocolGPSLBSStatus ||
+		proto == protocol.ProtocolGPSLBSStatus4G ||
+		proto == protocol.ProtocolGPSLBSStatus4GAlt
+}
+
 // IsAlarmPacket returns true if the packet is an alarm packet
 func IsAlarmPacket(p Packet) bool {
 	proto := p.ProtocolNumber()
@@ -198,6 +222,17 @@ func IsAlarmPacket(p Packet) bool {
 		proto == protocol.ProtocolAlarmMultiFence4G
 }
 
+// IsACCAlarm returns true if the packet is an ACC on/off pseudo-alarm (0xFE/0xFF),
+// whether delivered as an alarm or routed as an ACCStatusPacket
+func IsACCAlarm(p Packet) bool {
+	a, ok := p.(PacketWithAlarm)
+	if !ok {
+		return false
+	}
+	t := a.GetAlarmType()
+	return t == protocol.AlarmACCOn || t == protocol.AlarmACCOff
+}
+
 // IsLBSPacket returns true if the packet is an LBS packet
 func IsLBSPacket(p Packet) bool {
 	proto := p.ProtocolNumber()
@@ -0,0 +1,101 @@
+package packet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// WiFiInfoPacket represents a WiFi information packet (Protocols 0x2C and 0xA2)
+// Devices with WiFi scanning upload the access points they see, together with
+// the serving and neighbor cells, so a positioning service can locate them
+// indoors where GPS has no fix.
+//
+// Content structure:
+// - DateTime: 6 bytes
+// - Main cell (0x2C): MCC(2) + MNC(1) + LAC(2) + CellID(3) + RSSI(1)
+// - Main cell (0xA2): MCC(2) + MNC(1-2) + LAC(4) + CellID(8) + RSSI(1)
+// - Neighbor cells: 6 x LAC + CellID + RSSI, sized like the main cell
+// - Timing Advance: 1 byte
+// - WiFi Count: 1 byte
+// - Access points: WiFi Count x MAC(6) + RSSI(1)
+type WiFiInfoPacket struct {
+	BasePacket
+
+	// DateTime is when the scan was made
+	DateTime types.DateTime `json:"time"`
+
+	// LBSInfo contains the serving cell
+	LBSInfo types.LBSInfo `json:"cell"`
+
+	// NeighborCells contains the neighbor cells (empty slots are omitted)
+	NeighborCells []types.LBSInfo `json:"neighbor_cells"`
+
+	// TimingAdvance is the timing advance value
+	TimingAdvance uint8 `json:"timing_advance"`
+
+	// AccessPoints contains the WiFi access points seen by the device
+	AccessPoints []types.WiFiAccessPoint `json:"wifi"`
+}
+
+// NewWiFiInfoPacket creates a new 2G/3G WiFiInfoPacket (use ProtocolNum
+// protocol.ProtocolWiFi4G for the 4G variant)
+func NewWiFiInfoPacket(dt types.DateTime, lbs types.LBSInfo, aps []types.WiFiAccessPoint) *WiFiInfoPacket {
+	return &WiFiInfoPacket{
+		BasePacket: BasePacket{
+			ProtocolNum: protocol.ProtocolWiFi,
+			ParsedAt:    time.Now(),
+		},
+		DateTime:     dt,
+		LBSInfo:      lbs,
+		AccessPoints: aps,
+	}
+}
+
+// Type implements Packet interface
+func (p *WiFiInfoPacket) Type() string {
+	if p.Is4G() {
+		return "WiFi Info 4G"
+	}
+	return "WiFi Info"
+}
+
+// Is4G returns true for the 4G variant (0xA2)
+func (p *WiFiInfoPacket) Is4G() bool {
+	return p.ProtocolNum == protocol.ProtocolWiFi4G
+}
+
+// Timestamp implements Packet interface
+func (p *WiFiInfoPacket) Timestamp() time.Time {
+	return p.DateTime.Time
+}
+
+// Validate implements Packet interface
+func (p *WiFiInfoPacket) Validate() error {
+	if p.DateTime.IsZero() {
+		return &ValidationError{Field: "DateTime", Reason: "missing timestamp"}
+	}
+	if len(p.AccessPoints) == 0 && !p.LBSInfo.IsValid() {
+		return &ValidationError{Field: "AccessPoints", Reason: "no access points or cell data"}
+	}
+	return nil
+}
+
+// HasTimestamp implements PacketWithTimestamp interface
+func (p *WiFiInfoPacket) HasTimestamp() bool {
+	return !p.DateTime.IsZero()
+}
+
+// HasZeroTime reports whether the device sent an all-zero date-time and
+// DateTime holds the receive time instead
+func (p *WiFiInfoPacket) HasZeroTime() bool {
+	return p.DateTime.ZeroTime
+}
+
+// String returns a human-readable representation
+func (p *WiFiInfoPacket) String() string {
+	return fmt.Sprintf("WiFiInfoPacket{Protocol: 0x%02X, Time: %s, %s, Neighbors: %d, AccessPoints: %d}",
+		p.ProtocolNum, p.DateTime, p.LBSInfo, len(p.NeighborCells), len(p.AccessPoints))
+}
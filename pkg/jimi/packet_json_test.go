@@ -0,0 +1,171 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// jsonRoundTrip encodes p and decodes it back with packet.UnmarshalPacket
+func jsonRoundTrip(t *testing.T, p packet.Packet) (packet.Packet, []byte) {
+	t.Helper()
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal %T failed: %v", p, err)
+	}
+	again, err := packet.UnmarshalPacket(data)
+	if err != nil {
+		t.Fatalf("UnmarshalPacket failed: %v\n%s", err, data)
+	}
+	if reflect.TypeOf(again) != reflect.TypeOf(p) {
+		t.Fatalf("Expected %T, got %T", p, again)
+	}
+	return again, data
+}
+
+// withoutParseTime clears ParsedAt after checking it survived as the same instant
+func withoutParseTime(t *testing.T, first, second packet.Packet) (packet.Packet, packet.Packet) {
+	t.Helper()
+	a := reflect.ValueOf(first).Elem().FieldByName("ParsedAt")
+	b := reflect.ValueOf(second).Elem().FieldByName("ParsedAt")
+	if !a.Interface().(time.Time).Equal(b.Interface().(time.Time)) {
+		t.Errorf("ParsedAt mismatch: %v != %v", a, b)
+	}
+	a.Set(reflect.Zero(a.Type()))
+	b.Set(reflect.Zero(b.Type()))
+	return first, second
+}
+
+func TestPacketJSON_RoundTrip(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithLenientMode())
+
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tp.Hex)
+			pkt, err := decoder.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			again, encoded := jsonRoundTrip(t, pkt)
+			if !strings.HasPrefix(string(encoded), `{"type":"`+pkt.Type()+`","schema":1,`) {
+				t.Errorf("Expected the type and schema first, got %s", encoded)
+			}
+
+			pkt, again = withoutParseTime(t, pkt, again)
+			if !reflect.DeepEqual(pkt, again) {
+				t.Errorf("Round trip mismatch\nfirst:  %+v\nsecond: %+v\njson:   %s", pkt, again, encoded)
+			}
+		})
+	}
+}
+
+func TestPacketJSON_FieldNames(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithLenientMode())
+	data, _ := hex.DecodeString(packets.LocationPackets[0].Hex)
+	pkt, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	encoded, err := json.Marshal(pkt)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	for _, name := range []string{"type", "protocol", "serial", "raw", "parsed_at", "time", "satellites", "coordinates", "speed", "course", "cell", "terminal"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("Missing field %q in %s", name, encoded)
+		}
+	}
+	if coords, ok := fields["coordinates"].(map[string]any); !ok || coords["latitude"] == nil || coords["longitude"] == nil {
+		t.Errorf("Expected latitude and longitude, got %v", fields["coordinates"])
+	}
+	if cell, ok := fields["cell"].(map[string]any); !ok || cell["cell_id"] == nil {
+		t.Errorf("Expected cell_id, got %v", fields["cell"])
+	}
+}
+
+func TestPacketJSON_ZeroTime(t *testing.T) {
+	coords := types.MustNewCoordinates(-33.868820, 151.209296)
+	zero := types.DateTime{ZeroTime: true}
+	enc := encoder.New()
+	frames := [][]byte{
+		enc.Alarm(packet.NewAlarmPacket(zero, coords, protocol.AlarmSOS)),
+		enc.Location(packet.NewLocationPacket(zero, coords, 0, types.CourseStatus{})),
+	}
+	decoder := NewDecoder(WithAcceptZeroTime(), WithACCAlarmsAsStatus())
+
+	for _, data := range frames {
+		pkt, err := decoder.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		again, encoded := jsonRoundTrip(t, pkt)
+		if !strings.HasSuffix(string(encoded), `,"zero_time":true}`) {
+			t.Errorf("Expected zero_time, got %s", encoded)
+		}
+		if !packet.HasZeroTime(again) {
+			t.Errorf("%s: zero time flag lost in %s", pkt.Type(), encoded)
+		}
+		pkt, again = withoutParseTime(t, pkt, again)
+		if !reflect.DeepEqual(pkt, again) {
+			t.Errorf("Round trip mismatch\nfirst:  %+v\nsecond: %+v", pkt, again)
+		}
+	}
+}
+
+func TestPacketJSON_ACCStatus(t *testing.T) {
+	decoder := NewDecoder(WithSkipCRC(), WithACCAlarmsAsStatus())
+	data, _ := hex.DecodeString(accOffAlarmHex)
+	pkt, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	again, encoded := jsonRoundTrip(t, pkt)
+	if !strings.Contains(string(encoded), `"acc_on":false`) || !strings.Contains(string(encoded), `"alarm":{"type":"Alarm"`) {
+		t.Errorf("Expected acc_on and the nested alarm, got %s", encoded)
+	}
+
+	status := again.(*packet.ACCStatusPacket)
+	if status.ACCOn() {
+		t.Error("Expected ACC off")
+	}
+	if _, ok := status.Alarm.(*packet.AlarmPacket); !ok {
+		t.Errorf("Expected the original *packet.AlarmPacket, got %T", status.Alarm)
+	}
+}
+
+func TestUnmarshalPacket_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `login`},
+		{"missing protocol", `{"type":"Login","serial":1}`},
+		{"bad field", `{"type":"Login","protocol":1,"imei":"123"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := packet.UnmarshalPacket([]byte(tt.data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSlowParseThreshold is the parse duration above which a parse counts as slow
+const DefaultSlowParseThreshold = 10 * time.Millisecond
+
+// ProtocolParseStats summarizes the parse durations of one protocol number
+type ProtocolParseStats struct {
+	// Protocol is the protocol number
+	Protocol byte `json:"protocol"`
+
+	// Parser is the name of the parser
+	Parser string `json:"parser"`
+
+	// Count is the number of parser calls, including failed ones
+	Count int `json:"count"`
+
+	// Slow is the number of calls that took longer than the threshold
+	Slow int `json:"slow"`
+
+	// Timeouts is the number of calls that exceeded the parse timeout
+	Timeouts int `json:"timeouts"`
+
+	// Total and Max are the summed and longest call durations
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+// Average returns the mean parse duration
+func (s ProtocolParseStats) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// SlowParse describes one parse slower than the threshold (or timed out)
+type SlowParse struct {
+	Protocol byte
+	Parser   string
+	Duration time.Duration
+	TimedOut bool
+	Hex      string
+}
+
+// ParseStats collects per-protocol parse durations, slow parses and timeouts.
+// It is safe for concurrent use and can be shared between decoders.
+//
+// Example usage:
+//
+//	stats := jimi.NewParseStats(jimi.WithSlowParseHandler(func(s jimi.SlowParse) {
+//	    log.Printf("slow parse: %s 0x%02X took %s: %s", s.Parser, s.Protocol, s.Duration, s.Hex)
+//	}))
+//	decoder := jimi.NewDecoder(jimi.WithParseTimeout(50*time.Millisecond), jimi.WithParseStats(stats))
+type ParseStats struct {
+	threshold time.Duration
+	onSlow    func(SlowParse)
+
+	mu    sync.Mutex
+	stats map[byte]*ProtocolParseStats
+}
+
+// ParseStatsOption configures a ParseStats
+type ParseStatsOption func(*ParseStats)
+
+// WithSlowParseThreshold sets the duration above which a parse counts as slow
+func WithSlowParseThreshold(d time.Duration) ParseStatsOption {
+	return func(s *ParseStats) {
+		if d > 0 {
+			s.threshold = d
+		}
+	}
+}
+
+// WithSlowParseHandler calls fn for every slow or timed-out parse
+// fn runs on the decoding goroutine and should return quickly
+func WithSlowParseHandler(fn func(SlowParse)) ParseStatsOption {
+	return func(s *ParseStats) {
+		s.onSlow = fn
+	}
+}
+
+// NewParseStats creates empty parse statistics
+func NewParseStats(opts ...ParseStatsOption) *ParseStats {
+	s := &ParseStats{
+		threshold: DefaultSlowParseThreshold,
+		stats:     make(map[byte]*ProtocolParseStats),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Record adds one parser call
+func (s *ParseStats) Record(protocolNum byte, parserName string, d time.Duration, timedOut bool, data []byte) {
+	slow := timedOut || d > s.threshold
+
+	s.mu.Lock()
+	e, ok := s.stats[protocolNum]
+	if !ok {
+		e = &ProtocolParseStats{Protocol: protocolNum, Parser: parserName}
+		s.stats[protocolNum] = e
+	}
+	e.Count++
+	e.Total += d
+	if d > e.Max {
+		e.Max = d
+	}
+	if slow {
+		e.Slow++
+	}
+	if timedOut {
+		e.Timeouts++
+	}
+	s.mu.Unlock()
+
+	if slow && s.onSlow != nil {
+		s.onSlow(SlowParse{
+			Protocol: protocolNum,
+			Parser:   parserName,
+			Duration: d,
+			TimedOut: timedOut,
+			Hex:      strings.ToUpper(hex.EncodeToString(data)),
+		})
+	}
+}
+
+// Stats returns the statistics of every protocol, ordered by protocol number
+func (s *ParseStats) Stats() []ProtocolParseStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]ProtocolParseStats, 0, len(s.stats))
+	for _, e := range s.stats {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Protocol < out[j].Protocol })
+	return out
+}
+
+// Slowest returns the statistics of the n protocols with the longest average
+// parse duration, slowest first (all protocols if n <= 0). Protocols with
+// timeouts come first, as their calls were cut short.
+func (s *ParseStats) Slowest(n int) []ProtocolParseStats {
+	out := s.Stats()
+	sort.SliceStable(out, func(i, j int) bool {
+		if (out[i].Timeouts > 0) != (out[j].Timeouts > 0) {
+			return out[i].Timeouts > 0
+		}
+		return out[i].Average() > out[j].Average()
+	})
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+// Protocol returns the statistics of one protocol number
+func (s *ParseStats) Protocol(protocolNum byte) (ProtocolParseStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.stats[protocolNum]
+	if !ok {
+		return ProtocolParseStats{}, false
+	}
+	return *e, true
+}
+
+// Reset clears all statistics
+func (s *ParseStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = make(map[byte]*ProtocolParseStats)
+}
@@ -0,0 +1,69 @@
+package jimi
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// forbiddenImports are packages that prevent pkg/jimi from being used in
+// WebAssembly (browser) and C-shared builds
+var forbiddenImports = map[string]bool{
+	"net":      true,
+	"net/http": true,
+	"os":       true,
+	"os/exec":  true,
+	"syscall":  true,
+	"unsafe":   true,
+}
+
+// portableDirs are the decoder core packages (relative to pkg/jimi) used by
+// the WebAssembly and C-shared builds
+var portableDirs = []string{
+	".",
+	"packet",
+	"protocol",
+	"types",
+	"encoder",
+	"export",
+	"geojson",
+	"nmea",
+	"proto",
+	"../../internal/parser",
+	"../../internal/codec",
+	"../../internal/splitter",
+	"../../internal/validator",
+}
+
+// TestPortableImports ensures the decoder core stays free of network,
+// file system and syscall dependencies so it compiles to js/wasm
+func TestPortableImports(t *testing.T) {
+	fset := token.NewFileSet()
+
+	for _, dir := range portableDirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", dir, err)
+		}
+
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+
+			f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", path, err)
+			}
+			for _, imp := range f.Imports {
+				name, _ := strconv.Unquote(imp.Path.Value)
+				if forbiddenImports[name] {
+					t.Errorf("%s imports %q; the decoder core must stay portable to js/wasm", path, name)
+				}
+			}
+		}
+	}
+}
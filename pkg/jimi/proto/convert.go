@@ -0,0 +1,692 @@
+// Package proto converts decoded packets to and from Protocol Buffers.
+//
+// The schema is jimi.proto in this directory (package jimi.v1). Messages are
+// written and read directly in the protobuf wire format, so this package has
+// no dependencies; consumers in other languages (gRPC services, Kafka
+// consumers) generate their bindings from jimi.proto.
+//
+// Example usage:
+//
+//	pkt, _ := decoder.Decode(data)
+//	msg, _ := proto.ToProto(pkt, sess.IMEI())
+//	producer.Send(topic, msg)
+//
+//	pkt, imei, err := proto.FromProto(msg)
+//
+// Packets without a message in the schema (online commands, address
+// packets) keep only the envelope and their raw bytes; FromProto returns
+// them as *packet.BasePacket.
+package proto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Field numbers of the Packet payload oneof
+const (
+	fieldLogin           = 10
+	fieldHeartbeat       = 11
+	fieldLocation        = 12
+	fieldAlarm           = 13
+	fieldLBS             = 14
+	fieldCommandResponse = 15
+	fieldInfoTransfer    = 16
+	fieldWiFi            = 17
+)
+
+// accStatusType is the Packet type of ACC status packets
+const accStatusType = "ACC Status"
+
+// ToProto encodes p as a jimi.v1.Packet message.
+// imei identifies the device; for login packets it defaults to the packet's IMEI.
+func ToProto(p packet.Packet, imei string) ([]byte, error) {
+	if p == nil {
+		return nil, errors.New("proto: nil packet")
+	}
+	if login, ok := p.(*packet.LoginPacket); ok && imei == "" {
+		imei = login.GetIMEI()
+	}
+
+	w := &writer{}
+	w.string(1, p.Type())
+	w.uint(2, uint64(p.ProtocolNumber()))
+	w.uint(3, uint64(p.SerialNumber()))
+	w.string(4, imei)
+	w.bytes(5, p.Raw())
+	if base := basePacket(p); base != nil {
+		w.timestamp(6, base.ParsedAt)
+	}
+
+	switch v := p.(type) {
+	case *packet.LoginPacket:
+		w.message(fieldLogin, func(m *writer) {
+			m.string(1, v.GetIMEI())
+			m.uint(2, uint64(v.ModelID))
+			m.sint(3, int64(v.Timezone.OffsetMinutes))
+			m.uint(4, uint64(v.Timezone.Language))
+		})
+	case *packet.HeartbeatPacket:
+		w.message(fieldHeartbeat, func(m *writer) {
+			m.uint(1, uint64(v.TerminalInfo.Raw()))
+			m.uint(2, uint64(v.VoltageLevel))
+			m.uint(3, uint64(v.GSMSignal))
+			m.uint(4, uint64(v.ExtendedInfo))
+			m.bool(5, v.HasExtended)
+		})
+	case *packet.LocationPacket:
+		w.message(fieldLocation, func(m *writer) { writeLocation(m, v, 0, nil) })
+	case *packet.Location4GPacket:
+		w.message(fieldLocation, func(m *writer) { writeLocation(m, &v.LocationPacket, v.MCCMNC, v.ExtendedLBS) })
+	case *packet.AlarmPacket:
+		w.message(fieldAlarm, func(m *writer) { writeAlarm(m, v, 0, 0, nil) })
+	case *packet.AlarmMultiFencePacket:
+		w.message(fieldAlarm, func(m *writer) { writeAlarm(m, &v.AlarmPacket, v.FenceID, 0, nil) })
+	case *packet.Alarm4GPacket:
+		w.message(fieldAlarm, func(m *writer) { writeAlarm(m, &v.AlarmPacket, v.FenceID, v.MCCMNC, v.ExtendedLBS) })
+	case *packet.GPSLBSStatusPacket:
+		w.message(fieldAlarm, func(m *writer) { writeAlarm(m, &v.AlarmPacket, 0, v.MCCMNC, nil) })
+	case *packet.ACCStatusPacket:
+		// Encoded as the original alarm; the type tells them apart
+		var fenceID uint8
+		var mccmnc uint32
+		var ext []types.LBSInfo
+		switch a := v.Alarm.(type) {
+		case *packet.AlarmMultiFencePacket:
+			fenceID = a.FenceID
+		case *packet.Alarm4GPacket:
+			fenceID, mccmnc, ext = a.FenceID, a.MCCMNC, a.ExtendedLBS
+		}
+		w.message(fieldAlarm, func(m *writer) { writeAlarm(m, &v.AlarmPacket, fenceID, mccmnc, ext) })
+	case *packet.LBSPacket:
+		w.message(fieldLBS, func(m *writer) {
+			m.timestamp(1, v.DateTime.Time)
+			m.message(2, func(c *writer) { writeCell(c, v.LBSInfo) })
+			writeCells(m, 3, v.NeighborCells)
+			m.uint(4, uint64(v.TimingAdvance))
+			m.uint(5, uint64(v.Language))
+			m.uint(6, uint64(v.TerminalInfo.Raw()))
+			m.uint(7, uint64(v.VoltageLevel))
+			m.uint(8, uint64(v.GSMSignal))
+			m.uint(9, uint64(v.UploadMode))
+			m.bool(10, v.HasStatus)
+		})
+	case *packet.LBS4GPacket:
+		w.message(fieldLBS, func(m *writer) {
+			m.timestamp(1, v.DateTime.Time)
+			m.message(2, func(c *writer) { writeCell(c, v.LBSInfo) })
+			writeCells(m, 3, v.NeighborCells)
+			m.uint(6, uint64(v.TerminalInfo.Raw()))
+			m.uint(7, uint64(v.VoltageLevel))
+			m.uint(8, uint64(v.GSMSignal))
+			m.uint(9, uint64(v.UploadMode))
+		})
+	case *packet.WiFiInfoPacket:
+		w.message(fieldWiFi, func(m *writer) {
+			m.timestamp(1, v.DateTime.Time)
+			m.message(2, func(c *writer) { writeCell(c, v.LBSInfo) })
+			writeCells(m, 3, v.NeighborCells)
+			m.uint(4, uint64(v.TimingAdvance))
+			for _, ap := range v.AccessPoints {
+				m.message(5, func(a *writer) {
+					a.bytes(1, ap.MAC[:])
+					a.sint(2, int64(ap.RSSI))
+				})
+			}
+		})
+	case *packet.CommandResponsePacket:
+		w.message(fieldCommandResponse, func(m *writer) {
+			m.uint(1, uint64(v.ServerFlag))
+			m.string(2, v.Response)
+			m.uint(3, uint64(v.ResponseLength))
+		})
+	case *packet.InfoTransferPacket:
+		w.message(fieldInfoTransfer, func(m *writer) {
+			m.uint(1, uint64(v.SubProtocol))
+			m.bytes(2, v.Data)
+			m.uint(3, uint64(v.ExternalVoltage))
+			m.string(4, v.ICCID)
+			m.string(5, v.IMEI)
+			m.string(6, v.IMSI)
+			m.uint(7, uint64(v.GPSStatus))
+		})
+	}
+
+	return w.buf, nil
+}
+
+// writeLocation writes a Location message
+func writeLocation(m *writer, p *packet.LocationPacket, mccmnc uint32, ext []types.LBSInfo) {
+	m.timestamp(1, p.DateTime.Time)
+	m.uint(2, uint64(p.Satellites))
+	m.message(3, func(c *writer) { writeCoordinates(c, p.Coordinates) })
+	m.uint(4, uint64(p.Speed))
+	m.message(5, func(c *writer) { writeCourse(c, p.CourseStatus) })
+	m.message(6, func(c *writer) { writeCell(c, p.LBSInfo) })
+	m.uint(7, uint64(p.TerminalInfo.Raw()))
+	m.bool(8, p.ACC)
+	m.uint(9, uint64(p.VoltageLevel))
+	m.uint(10, uint64(p.GSMSignal))
+	m.uint(11, uint64(p.UploadMode))
+	m.bool(12, p.IsReupload)
+	m.uint(13, uint64(p.Mileage))
+	m.bool(14, p.HasStatus)
+	m.uint(15, uint64(mccmnc))
+	writeCells(m, 16, ext)
+	m.bool(17, p.HasStatusTail)
+}
+
+// writeAlarm writes an Alarm message
+func writeAlarm(m *writer, p *packet.AlarmPacket, fenceID uint8, mccmnc uint32, ext []types.LBSInfo) {
+	m.timestamp(1, p.DateTime.Time)
+	m.uint(2, uint64(p.Satellites))
+	m.message(3, func(c *writer) { writeCoordinates(c, p.Coordinates) })
+	m.uint(4, uint64(p.Speed))
+	m.message(5, func(c *writer) { writeCourse(c, p.CourseStatus) })
+	m.message(6, func(c *writer) { writeCell(c, p.LBSInfo) })
+	m.uint(7, uint64(p.TerminalInfo.Raw()))
+	m.uint(8, uint64(p.VoltageLevel))
+	m.uint(9, uint64(p.GSMSignal))
+	m.uint(10, uint64(p.AlarmType))
+	m.uint(11, uint64(p.Language))
+	m.uint(12, uint64(p.Mileage))
+	m.uint(13, uint64(fenceID))
+	m.uint(14, uint64(mccmnc))
+	writeCells(m, 15, ext)
+}
+
+func writeCoordinates(m *writer, c types.Coordinates) {
+	m.double(1, signed(c.Latitude, c.IsNorth))
+	m.double(2, signed(c.Longitude, c.IsEast))
+}
+
+// signed negates v (keeping a negative zero) when positive is false
+func signed(v float64, positive bool) float64 {
+	if positive {
+		return v
+	}
+	return math.Copysign(v, -1)
+}
+
+func writeCourse(m *writer, c types.CourseStatus) {
+	m.uint(1, uint64(c.Course))
+	m.bool(2, c.IsGPSRealtime)
+	m.bool(3, c.IsPositioned)
+	m.bool(4, c.IsEastLongitude)
+	m.bool(5, c.IsNorthLatitude)
+	m.uint(6, uint64(c.Reserved))
+}
+
+func writeCell(m *writer, c types.LBSInfo) {
+	m.uint(1, uint64(c.MCC))
+	m.uint(2, uint64(c.MNC))
+	m.uint(3, uint64(c.LAC))
+	m.uint(4, c.CellID)
+}
+
+func writeCells(m *writer, field int, cells []types.LBSInfo) {
+	for _, c := range cells {
+		m.message(field, func(w *writer) { writeCell(w, c) })
+	}
+}
+
+// basePacket returns the BasePacket embedded in p, or nil for unknown types
+func basePacket(p packet.Packet) *packet.BasePacket {
+	switch v := p.(type) {
+	case *packet.BasePacket:
+		return v
+	case *packet.LoginPacket:
+		return &v.BasePacket
+	case *packet.HeartbeatPacket:
+		return &v.BasePacket
+	case *packet.LocationPacket:
+		return &v.BasePacket
+	case *packet.Location4GPacket:
+		return &v.BasePacket
+	case *packet.AlarmPacket:
+		return &v.BasePacket
+	case *packet.AlarmMultiFencePacket:
+		return &v.BasePacket
+	case *packet.Alarm4GPacket:
+		return &v.BasePacket
+	case *packet.GPSLBSStatusPacket:
+		return &v.BasePacket
+	case *packet.ACCStatusPacket:
+		return &v.BasePacket
+	case *packet.LBSPacket:
+		return &v.BasePacket
+	case *packet.LBS4GPacket:
+		return &v.BasePacket
+	case *packet.WiFiInfoPacket:
+		return &v.BasePacket
+	case *packet.CommandResponsePacket:
+		return &v.BasePacket
+	case *packet.InfoTransferPacket:
+		return &v.BasePacket
+	case *packet.OnlineCommandPacket:
+		return &v.BasePacket
+	case *packet.GPSAddressRequestPacket:
+		return &v.BasePacket
+	case *packet.AddressResponsePacket:
+		return &v.BasePacket
+	case *packet.TimeCalibrationPacket:
+		return &v.BasePacket
+	}
+	return nil
+}
+
+// FromProto decodes a jimi.v1.Packet message into the packet type ToProto
+// was given, and returns the IMEI it was sent with
+func FromProto(data []byte) (packet.Packet, string, error) {
+	var (
+		typ, imei    string
+		base         packet.BasePacket
+		payload      int
+		payloadData  []byte
+		parsedAtData []byte
+	)
+	err := readFields(data, func(f field) error {
+		switch f.num {
+		case 1:
+			typ = f.string()
+		case 2:
+			base.ProtocolNum = byte(f.value)
+		case 3:
+			base.SerialNum = uint16(f.value)
+		case 4:
+			imei = f.string()
+		case 5:
+			base.RawData = bytes.Clone(f.data)
+		case 6:
+			parsedAtData = f.data
+		case fieldLogin, fieldHeartbeat, fieldLocation, fieldAlarm, fieldLBS, fieldCommandResponse, fieldInfoTransfer, fieldWiFi:
+			payload, payloadData = f.num, f.data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if parsedAtData != nil {
+		if base.ParsedAt, err = readTimestamp(parsedAtData); err != nil {
+			return nil, "", err
+		}
+	}
+
+	p, err := readPayload(base, typ, payload, payloadData)
+	if err != nil {
+		return nil, "", fmt.Errorf("proto: %s: %w", typ, err)
+	}
+	return p, imei, nil
+}
+
+// readPayload builds the packet of a payload message
+func readPayload(base packet.BasePacket, typ string, payload int, data []byte) (packet.Packet, error) {
+	switch payload {
+	case fieldLogin:
+		p := &packet.LoginPacket{BasePacket: base}
+		return p, readLogin(p, data)
+
+	case fieldHeartbeat:
+		p := &packet.HeartbeatPacket{BasePacket: base}
+		return p, readFields(data, func(f field) error {
+			switch f.num {
+			case 1:
+				p.TerminalInfo = types.NewTerminalInfo(byte(f.value))
+			case 2:
+				p.VoltageLevel = protocol.VoltageLevel(f.value)
+			case 3:
+				p.GSMSignal = protocol.GSMSignalStrength(f.value)
+			case 4:
+				p.ExtendedInfo = uint16(f.value)
+			case 5:
+				p.HasExtended = f.bool()
+			}
+			return nil
+		})
+
+	case fieldLocation:
+		loc := packet.LocationPacket{BasePacket: base}
+		var mccmnc uint32
+		var ext []types.LBSInfo
+		if err := readLocation(&loc, &mccmnc, &ext, data); err != nil {
+			return nil, err
+		}
+		if base.ProtocolNum == protocol.ProtocolGPSLocation4G {
+			return &packet.Location4GPacket{LocationPacket: loc, MCCMNC: mccmnc, ExtendedLBS: ext}, nil
+		}
+		return &loc, nil
+
+	case fieldAlarm:
+		alarm := packet.AlarmPacket{BasePacket: base}
+		var fenceID uint8
+		var mccmnc uint32
+		var ext []types.LBSInfo
+		if err := readAlarm(&alarm, &fenceID, &mccmnc, &ext, data); err != nil {
+			return nil, err
+		}
+
+		var p packet.Packet
+		switch base.ProtocolNum {
+		case protocol.ProtocolAlarmMultiFence:
+			p = &packet.AlarmMultiFencePacket{AlarmPacket: alarm, FenceID: fenceID}
+		case protocol.ProtocolAlarmMultiFence4G:
+			p = &packet.Alarm4GPacket{AlarmPacket: alarm, MCCMNC: mccmnc, ExtendedLBS: ext, FenceID: fenceID}
+		case protocol.ProtocolGPSLBSStatus, protocol.ProtocolGPSLBSStatus4G, protocol.ProtocolGPSLBSStatus4GAlt:
+			p = &packet.GPSLBSStatusPacket{AlarmPacket: alarm, MCCMNC: mccmnc}
+		default:
+			p = &alarm
+		}
+		if typ != accStatusType {
+			return p, nil
+		}
+		acc := packet.NewACCStatusPacket(p)
+		if acc == nil {
+			return nil, fmt.Errorf("alarm type 0x%02X is not ACC on/off", byte(alarm.AlarmType))
+		}
+		return acc, nil
+
+	case fieldLBS:
+		lbs := packet.LBSPacket{BasePacket: base}
+		if err := readLBS(&lbs, data); err != nil {
+			return nil, err
+		}
+		if base.ProtocolNum == protocol.ProtocolLBSMultiBase4G {
+			return &packet.LBS4GPacket{
+				BasePacket:    base,
+				DateTime:      lbs.DateTime,
+				LBSInfo:       lbs.LBSInfo,
+				NeighborCells: lbs.NeighborCells,
+				TerminalInfo:  lbs.TerminalInfo,
+				VoltageLevel:  lbs.VoltageLevel,
+				GSMSignal:     lbs.GSMSignal,
+				UploadMode:    lbs.UploadMode,
+			}, nil
+		}
+		return &lbs, nil
+
+	case fieldWiFi:
+		p := &packet.WiFiInfoPacket{BasePacket: base}
+		return p, readFields(data, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				p.DateTime.Time, err = readTimestamp(f.data)
+			case 2:
+				p.LBSInfo, err = readCell(f.data)
+			case 3:
+				err = appendCell(&p.NeighborCells, f.data)
+			case 4:
+				p.TimingAdvance = uint8(f.value)
+			case 5:
+				var ap types.WiFiAccessPoint
+				err = readFields(f.data, func(f field) error {
+					switch f.num {
+					case 1:
+						copy(ap.MAC[:], f.data)
+					case 2:
+						ap.RSSI = int8(f.sint())
+					}
+					return nil
+				})
+				p.AccessPoints = append(p.AccessPoints, ap)
+			}
+			return err
+		})
+
+	case fieldCommandResponse:
+		p := &packet.CommandResponsePacket{BasePacket: base}
+		return p, readFields(data, func(f field) error {
+			switch f.num {
+			case 1:
+				p.ServerFlag = uint32(f.value)
+			case 2:
+				p.Response = f.string()
+			case 3:
+				p.ResponseLength = uint8(f.value)
+			}
+			return nil
+		})
+
+	case fieldInfoTransfer:
+		p := &packet.InfoTransferPacket{BasePacket: base}
+		return p, readFields(data, func(f field) error {
+			switch f.num {
+			case 1:
+				p.SubProtocol = protocol.InfoType(f.value)
+			case 2:
+				p.Data = bytes.Clone(f.data)
+			case 3:
+				p.ExternalVoltage = uint16(f.value)
+			case 4:
+				p.ICCID = f.string()
+			case 5:
+				p.IMEI = f.string()
+			case 6:
+				p.IMSI = f.string()
+			case 7:
+				p.GPSStatus = protocol.GPSModuleStatus(f.value)
+			}
+			return nil
+		})
+	}
+
+	if base.ProtocolNum == protocol.ProtocolTimeCalibration {
+		return &packet.TimeCalibrationPacket{BasePacket: base}, nil
+	}
+	return &base, nil
+}
+
+func readLogin(p *packet.LoginPacket, data []byte) error {
+	return readFields(data, func(f field) error {
+		switch f.num {
+		case 1:
+			p.DeviceID = types.DeviceID(f.string())
+			if p.DeviceID.IsIMEI() {
+				imei, err := p.DeviceID.IMEI()
+				if err != nil {
+					return err
+				}
+				p.IMEI = imei
+			}
+		case 2:
+			p.ModelID = uint16(f.value)
+		case 3:
+			p.Timezone.OffsetMinutes = int(f.sint())
+		case 4:
+			p.Timezone.Language = byte(f.value)
+		}
+		return nil
+	})
+}
+
+func readLocation(p *packet.LocationPacket, mccmnc *uint32, ext *[]types.LBSInfo, data []byte) error {
+	return readFields(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			p.DateTime.Time, err = readTimestamp(f.data)
+		case 2:
+			p.Satellites = uint8(f.value)
+		case 3:
+			p.Coordinates, err = readCoordinates(f.data)
+		case 4:
+			p.Speed = uint16(f.value)
+		case 5:
+			p.CourseStatus, err = readCourse(f.data)
+		case 6:
+			p.LBSInfo, err = readCell(f.data)
+		case 7:
+			p.TerminalInfo = types.NewTerminalInfo(byte(f.value))
+		case 8:
+			p.ACC = f.bool()
+		case 9:
+			p.VoltageLevel = protocol.VoltageLevel(f.value)
+		case 10:
+			p.GSMSignal = protocol.GSMSignalStrength(f.value)
+		case 11:
+			p.UploadMode = protocol.UploadMode(f.value)
+		case 12:
+			p.IsReupload = f.bool()
+		case 13:
+			p.Mileage = uint32(f.value)
+		case 14:
+			p.HasStatus = f.bool()
+		case 15:
+			*mccmnc = uint32(f.value)
+		case 16:
+			err = appendCell(ext, f.data)
+		case 17:
+			p.HasStatusTail = f.bool()
+		}
+		return err
+	})
+}
+
+func readAlarm(p *packet.AlarmPacket, fenceID *uint8, mccmnc *uint32, ext *[]types.LBSInfo, data []byte) error {
+	return readFields(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			p.DateTime.Time, err = readTimestamp(f.data)
+		case 2:
+			p.Satellites = uint8(f.value)
+		case 3:
+			p.Coordinates, err = readCoordinates(f.data)
+		case 4:
+			p.Speed = uint8(f.value)
+		case 5:
+			p.CourseStatus, err = readCourse(f.data)
+		case 6:
+			p.LBSInfo, err = readCell(f.data)
+		case 7:
+			p.TerminalInfo = types.NewTerminalInfo(byte(f.value))
+		case 8:
+			p.VoltageLevel = protocol.VoltageLevel(f.value)
+		case 9:
+			p.GSMSignal = protocol.GSMSignalStrength(f.value)
+		case 10:
+			p.AlarmType = protocol.AlarmType(f.value)
+		case 11:
+			p.Language = protocol.Language(f.value)
+		case 12:
+			p.Mileage = uint32(f.value)
+		case 13:
+			*fenceID = uint8(f.value)
+		case 14:
+			*mccmnc = uint32(f.value)
+		case 15:
+			err = appendCell(ext, f.data)
+		}
+		return err
+	})
+}
+
+// readLBS reads an LBS message (the 4G packet has a subset of its fields)
+func readLBS(p *packet.LBSPacket, data []byte) error {
+	return readFields(data, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			p.DateTime.Time, err = readTimestamp(f.data)
+		case 2:
+			p.LBSInfo, err = readCell(f.data)
+		case 3:
+			err = appendCell(&p.NeighborCells, f.data)
+		case 4:
+			p.TimingAdvance = uint8(f.value)
+		case 5:
+			p.Language = protocol.Language(f.value)
+		case 6:
+			p.TerminalInfo = types.NewTerminalInfo(byte(f.value))
+		case 7:
+			p.VoltageLevel = protocol.VoltageLevel(f.value)
+		case 8:
+			p.GSMSignal = protocol.GSMSignalStrength(f.value)
+		case 9:
+			p.UploadMode = protocol.UploadMode(f.value)
+		case 10:
+			p.HasStatus = f.bool()
+		}
+		return err
+	})
+}
+
+func readCoordinates(data []byte) (types.Coordinates, error) {
+	var lat, lon float64
+	err := readFields(data, func(f field) error {
+		switch f.num {
+		case 1:
+			lat = f.double()
+		case 2:
+			lon = f.double()
+		}
+		return nil
+	})
+	if err != nil {
+		return types.Coordinates{}, err
+	}
+
+	c, err := types.NewCoordinates(lat, lon)
+	if err != nil {
+		return types.Coordinates{}, err
+	}
+	c.IsNorth = !math.Signbit(lat)
+	c.IsEast = !math.Signbit(lon)
+	return c, nil
+}
+
+func readCourse(data []byte) (types.CourseStatus, error) {
+	var course uint16
+	var realtime, positioned, east, north bool
+	var reserved uint8
+	err := readFields(data, func(f field) error {
+		switch f.num {
+		case 1:
+			course = uint16(f.value)
+		case 2:
+			realtime = f.bool()
+		case 3:
+			positioned = f.bool()
+		case 4:
+			east = f.bool()
+		case 5:
+			north = f.bool()
+		case 6:
+			reserved = uint8(f.value)
+		}
+		return nil
+	})
+	c := types.NewCourseStatus(course, realtime, positioned, east, north)
+	c.Reserved = reserved
+	return c, err
+}
+
+func readCell(data []byte) (types.LBSInfo, error) {
+	var c types.LBSInfo
+	err := readFields(data, func(f field) error {
+		switch f.num {
+		case 1:
+			c.MCC = uint16(f.value)
+		case 2:
+			c.MNC = uint16(f.value)
+		case 3:
+			c.LAC = uint32(f.value)
+		case 4:
+			c.CellID = f.value
+		}
+		return nil
+	})
+	return c, err
+}
+
+func appendCell(cells *[]types.LBSInfo, data []byte) error {
+	c, err := readCell(data)
+	if err != nil {
+		return err
+	}
+	*cells = append(*cells, c)
+	return nil
+}
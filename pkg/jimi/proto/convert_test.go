@@ -0,0 +1,148 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// decodeHex decodes a sample packet
+func decodeHex(t *testing.T, d *jimi.Decoder, s string) packet.Packet {
+	t.Helper()
+	data, _ := hex.DecodeString(s)
+	p, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	return p
+}
+
+// withoutParseTime clears ParsedAt after checking it survived as the same instant
+func withoutParseTime(t *testing.T, first, second packet.Packet) {
+	t.Helper()
+	a, b := basePacket(first), basePacket(second)
+	if !a.ParsedAt.Equal(b.ParsedAt) {
+		t.Errorf("ParsedAt mismatch: %v != %v", a.ParsedAt, b.ParsedAt)
+	}
+	a.ParsedAt, b.ParsedAt = time.Time{}, time.Time{}
+}
+
+func TestRoundTrip(t *testing.T) {
+	decoder := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode())
+
+	for _, tp := range packets.GetAllValidPackets() {
+		t.Run(tp.Name, func(t *testing.T) {
+			pkt := decodeHex(t, decoder, tp.Hex)
+
+			msg, err := ToProto(pkt, "359339073930520")
+			if err != nil {
+				t.Fatalf("ToProto failed: %v", err)
+			}
+			again, imei, err := FromProto(msg)
+			if err != nil {
+				t.Fatalf("FromProto failed: %v", err)
+			}
+			if imei != "359339073930520" {
+				t.Errorf("Expected IMEI to round trip, got %q", imei)
+			}
+
+			// Packets outside the schema keep only the envelope
+			switch pkt.(type) {
+			case *packet.OnlineCommandPacket, *packet.GPSAddressRequestPacket, *packet.AddressResponsePacket:
+				base, ok := again.(*packet.BasePacket)
+				if !ok || base.ProtocolNum != pkt.ProtocolNumber() || !bytes.Equal(base.RawData, pkt.Raw()) {
+					t.Errorf("Expected the envelope as *packet.BasePacket, got %+v", again)
+				}
+				return
+			}
+
+			if reflect.TypeOf(again) != reflect.TypeOf(pkt) {
+				t.Fatalf("Expected %T, got %T", pkt, again)
+			}
+
+			// Parsed sub-records of info transfer packets are not in the schema
+			if info, ok := pkt.(*packet.InfoTransferPacket); ok {
+				info.TerminalSync, info.DoorStatus, info.GPSStatusInfo = nil, nil, nil
+			}
+
+			withoutParseTime(t, pkt, again)
+			if !reflect.DeepEqual(pkt, again) {
+				t.Errorf("Round trip mismatch\nfirst:  %+v\nsecond: %+v", pkt, again)
+			}
+		})
+	}
+}
+
+func TestRoundTrip_ACCStatus(t *testing.T) {
+	decoder := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithACCAlarmsAsStatus())
+	pkt := decodeHex(t, decoder, "787825260F0C1D030B26C9027AC8180C4658600004000901CC00287D001F71800404FE02000C472A0D0A")
+
+	msg, err := ToProto(pkt, "")
+	if err != nil {
+		t.Fatalf("ToProto failed: %v", err)
+	}
+	again, _, err := FromProto(msg)
+	if err != nil {
+		t.Fatalf("FromProto failed: %v", err)
+	}
+
+	status, ok := again.(*packet.ACCStatusPacket)
+	if !ok {
+		t.Fatalf("Expected *packet.ACCStatusPacket, got %T", again)
+	}
+	if !status.ACCOn() {
+		t.Error("Expected ACC on")
+	}
+	if _, ok := status.Alarm.(*packet.AlarmPacket); !ok {
+		t.Errorf("Expected the original *packet.AlarmPacket, got %T", status.Alarm)
+	}
+}
+
+func TestToProto_LoginIMEI(t *testing.T) {
+	decoder := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode())
+	login := decodeHex(t, decoder, packets.LoginPackets[0].Hex).(*packet.LoginPacket)
+
+	msg, _ := ToProto(login, "")
+	_, imei, err := FromProto(msg)
+	if err != nil {
+		t.Fatalf("FromProto failed: %v", err)
+	}
+	if imei != login.GetIMEI() {
+		t.Errorf("Expected IMEI %s from the login, got %q", login.GetIMEI(), imei)
+	}
+}
+
+func TestWire(t *testing.T) {
+	// Known encodings from the protobuf documentation
+	w := &writer{}
+	w.uint(1, 150)
+	w.string(2, "testing")
+	w.sint(3, -2)
+	w.double(4, 0) // default values are not written
+	w.bool(5, false)
+	want, _ := hex.DecodeString("089601120774657374696e671803")
+	if !bytes.Equal(w.buf, want) {
+		t.Errorf("Expected %X, got %X", want, w.buf)
+	}
+
+	var got []field
+	if err := readFields(w.buf, func(f field) error { got = append(got, f); return nil }); err != nil {
+		t.Fatalf("readFields failed: %v", err)
+	}
+	if len(got) != 3 || got[0].value != 150 || got[1].string() != "testing" || got[2].sint() != -2 {
+		t.Errorf("Unexpected fields %+v", got)
+	}
+
+	for _, bad := range []string{"08", "1207746573", "0B"} {
+		data, _ := hex.DecodeString(bad)
+		if _, _, err := FromProto(data); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
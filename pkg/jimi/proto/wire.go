@@ -0,0 +1,170 @@
+package proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errTruncated is returned for messages that end inside a field
+var errTruncated = errors.New("proto: truncated message")
+
+// writer appends fields in the protobuf wire format.
+// Zero values are skipped, as proto3 does for scalar fields.
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (w *writer) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *writer) sint(field int, v int64) {
+	w.uint(field, uint64(v<<1)^uint64(v>>63))
+}
+
+func (w *writer) bool(field int, v bool) {
+	if v {
+		w.uint(field, 1)
+	}
+}
+
+func (w *writer) double(field int, v float64) {
+	bits := math.Float64bits(v)
+	if bits == 0 {
+		return
+	}
+	w.tag(field, wireFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, bits)
+}
+
+func (w *writer) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *writer) string(field int, v string) {
+	w.bytes(field, []byte(v))
+}
+
+// message writes a nested message built by fn; empty messages are written
+// too, since a set message differs from an unset one
+func (w *writer) message(field int, fn func(*writer)) {
+	var m writer
+	fn(&m)
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(m.buf)))
+	w.buf = append(w.buf, m.buf...)
+}
+
+// timestamp writes a google.protobuf.Timestamp; zero times are skipped
+func (w *writer) timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	w.message(field, func(m *writer) {
+		m.uint(1, uint64(t.Unix()))
+		m.uint(2, uint64(t.Nanosecond()))
+	})
+}
+
+// field is one decoded field
+type field struct {
+	num      int
+	wireType int
+	value    uint64 // varint and fixed values
+	data     []byte // length-delimited values
+}
+
+func (f field) int64() int64 { return int64(f.value) }
+
+func (f field) sint() int64 { return int64(f.value>>1) ^ -int64(f.value&1) }
+
+func (f field) bool() bool { return f.value != 0 }
+
+func (f field) double() float64 { return math.Float64frombits(f.value) }
+
+func (f field) string() string { return string(f.data) }
+
+// readFields calls fn for every field of a message
+func readFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+
+		f := field{num: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			if f.value, n = binary.Uvarint(b); n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.value, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.value, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errTruncated
+			}
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			return fmt.Errorf("proto: unsupported wire type %d", f.wireType)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTimestamp decodes a google.protobuf.Timestamp as a UTC time
+func readTimestamp(b []byte) (time.Time, error) {
+	var sec, nsec int64
+	err := readFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			sec = f.int64()
+		case 2:
+			nsec = f.int64()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, nsec).UTC(), nil
+}
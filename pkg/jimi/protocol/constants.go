@@ -19,6 +19,15 @@ const (
 	ProtocolAlarmMultiFence   = 0x27 // Alarm data packet (UTC, multiple geofences)
 	ProtocolGPSAddressRequest = 0x2A // GPS address request packet (UTC)
 
+	// Combined GPS, LBS and Status
+	ProtocolGPSLBSStatus      = 0x16 // GPS, LBS and status packet (2G/3G base station)
+	ProtocolGPSLBSStatus4G    = 0x32 // GPS, LBS and status packet (4G base station)
+	ProtocolGPSLBSStatus4GAlt = 0x33 // GPS, LBS and status packet (4G, sent by some firmware instead of 0x32)
+
+	// WiFi Positioning
+	ProtocolWiFi   = 0x2C // WiFi information packet (2G/3G base station)
+	ProtocolWiFi4G = 0xA2 // WiFi information packet (4G base station)
+
 	// Command and Control
 	ProtocolOnlineCommand      = 0x80 // Online command from server to terminal
 	ProtocolCommandResponse    = 0x21 // Response to online command by terminal (universal version)
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number is a protocol number. The Protocol* constants are untyped and
+// convert to it, e.g. Number(ProtocolLogin).String() == "login".
+type Number byte
+
+// numberNames are the names of the known protocol numbers, in ascending order
+var numberNames = []struct {
+	num  Number
+	name string
+}{
+	{ProtocolLogin, "login"},
+	{ProtocolHeartbeat, "heartbeat"},
+	{ProtocolCommandResponseOld, "command_response_old"},
+	{ProtocolGPSLBSStatus, "gps_lbs_status"},
+	{ProtocolAddressResponseChinese, "address_response_chinese"},
+	{ProtocolCommandResponse, "command_response"},
+	{ProtocolGPSLocation, "gps_location"},
+	{ProtocolAlarm, "alarm"},
+	{ProtocolAlarmMultiFence, "alarm_multi_fence"},
+	{ProtocolLBSMultiBase, "lbs_multi_base"},
+	{ProtocolGPSAddressRequest, "gps_address_request"},
+	{ProtocolWiFi, "wifi"},
+	{ProtocolGPSLBSStatus4G, "gps_lbs_status_4g"},
+	{ProtocolGPSLBSStatus4GAlt, "gps_lbs_status_4g_alt"},
+	{ProtocolOnlineCommand, "online_command"},
+	{ProtocolTimeCalibration, "time_calibration"},
+	{ProtocolInfoTransfer, "info_transfer"},
+	{ProtocolAddressResponseEnglish, "address_response_english"},
+	{ProtocolGPSLocation4G, "gps_location_4g"},
+	{ProtocolLBSMultiBase4G, "lbs_multi_base_4g"},
+	{ProtocolWiFi4G, "wifi_4g"},
+	{ProtocolAlarmMultiFence4G, "alarm_multi_fence_4g"},
+}
+
+// Numbers returns every known protocol number, in ascending order
+func Numbers() []Number {
+	out := make([]Number, len(numberNames))
+	for i, n := range numberNames {
+		out[i] = n.num
+	}
+	return out
+}
+
+// String returns the name of the protocol number (e.g. "gps_location"), or
+// its hex form (e.g. "0x99") if unknown. The name is stable and suitable for
+// command-line flags, config files and metrics labels.
+func (n Number) String() string {
+	for _, v := range numberNames {
+		if v.num == n {
+			return v.name
+		}
+	}
+	return fmt.Sprintf("0x%02X", byte(n))
+}
+
+// IsKnown reports whether the protocol number is defined by the protocol
+func (n Number) IsKnown() bool {
+	for _, v := range numberNames {
+		if v.num == n {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNumber parses a protocol number from its name (case-insensitive,
+// with '-' or '_' separators, e.g. "login" or "GPS-Location-4G") or its
+// value (e.g. "0x22" or "34"). Values are decimal unless prefixed with "0x",
+// so "034" is 34, not octal. Values of unknown protocols are accepted.
+func ParseNumber(s string) (Number, error) {
+	s = strings.TrimSpace(s)
+	name := strings.ReplaceAll(strings.ToLower(s), "-", "_")
+	for _, v := range numberNames {
+		if v.name == name {
+			return v.num, nil
+		}
+	}
+	digits, base := s, 10
+	if len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		digits, base = s[2:], 16
+	}
+	if v, err := strconv.ParseUint(digits, base, 8); err == nil {
+		return Number(v), nil
+	}
+	return 0, fmt.Errorf("unknown protocol %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, so config files and JSON
+// carry the protocol name
+func (n Number) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler (see ParseNumber)
+func (n *Number) UnmarshalText(b []byte) error {
+	v, err := ParseNumber(string(b))
+	if err != nil {
+		return err
+	}
+	*n = v
+	return nil
+}
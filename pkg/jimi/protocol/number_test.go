@@ -0,0 +1,80 @@
+package protocol
+
+import "testing"
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Number
+		wantErr bool
+	}{
+		{"name", "login", ProtocolLogin, false},
+		{"mixed case and dashes", "GPS-Location-4G", ProtocolGPSLocation4G, false},
+		{"surrounding spaces", " heartbeat ", ProtocolHeartbeat, false},
+		{"hex", "0x22", ProtocolGPSLocation, false},
+		{"upper hex prefix", "0X26", ProtocolAlarm, false},
+		{"decimal", "34", ProtocolGPSLocation, false},
+		{"leading zero is decimal", "034", ProtocolGPSLocation, false},
+		{"unknown value", "0x99", 0x99, false},
+		{"unknown name", "teleport", 0, true},
+		{"out of range", "256", 0, true},
+		{"bare prefix", "0x", 0, true},
+		{"empty", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNumber(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNumber(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseNumber(%q) = 0x%02X, want 0x%02X", tt.input, byte(got), byte(tt.want))
+			}
+		})
+	}
+}
+
+func TestNumber_String(t *testing.T) {
+	tests := []struct {
+		num  Number
+		want string
+	}{
+		{ProtocolLogin, "login"},
+		{ProtocolGPSLocation4G, "gps_location_4g"},
+		{0x99, "0x99"},
+		{0x05, "0x05"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.num.String(); got != tt.want {
+			t.Errorf("Number(0x%02X).String() = %q, want %q", byte(tt.num), got, tt.want)
+		}
+	}
+
+	// Every name parses back to its number
+	for _, n := range Numbers() {
+		if got, err := ParseNumber(n.String()); err != nil || got != n {
+			t.Errorf("ParseNumber(%q) = 0x%02X, %v", n.String(), byte(got), err)
+		}
+	}
+}
+
+func TestNumber_UnmarshalText(t *testing.T) {
+	var n Number
+	if err := n.UnmarshalText([]byte("alarm_multi_fence")); err != nil || n != ProtocolAlarmMultiFence {
+		t.Errorf("UnmarshalText = 0x%02X, %v", byte(n), err)
+	}
+	if err := n.UnmarshalText([]byte("0x2a")); err != nil || n != ProtocolGPSAddressRequest {
+		t.Errorf("UnmarshalText = 0x%02X, %v", byte(n), err)
+	}
+
+	before := n
+	if err := n.UnmarshalText([]byte("bogus")); err == nil {
+		t.Error("Expected an error for an unknown name")
+	}
+	if n != before {
+		t.Errorf("Expected the value to be kept on error, got 0x%02X", byte(n))
+	}
+}
@@ -0,0 +1,272 @@
+package jimi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultQuarantineSamples is the number of sample packets kept per protocol
+const DefaultQuarantineSamples = 5
+
+// QuarantineEntry summarizes the traffic seen for one unknown protocol number
+type QuarantineEntry struct {
+	// Protocol is the unknown protocol number
+	Protocol byte `json:"protocol"`
+
+	// Count is the number of packets seen
+	Count int `json:"count"`
+
+	// FirstSeen and LastSeen bound the time the protocol was seen
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Lengths is a histogram of total packet lengths (bytes -> packets)
+	Lengths map[int]int `json:"lengths"`
+
+	// Samples holds uppercase hex of distinct packets, new lengths first
+	Samples []string `json:"samples"`
+}
+
+// QuarantineReport is the persisted form of a Quarantine
+type QuarantineReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Entries     []QuarantineEntry `json:"entries"`
+}
+
+// Quarantine collects packets with unknown protocol numbers ("learning mode")
+// so parser work can be prioritized from real traffic.
+// It is safe for concurrent use and can be shared between decoders.
+//
+// Example usage:
+//
+//	q := jimi.NewQuarantine()
+//	decoder := jimi.NewDecoder(jimi.WithLearningMode(q))
+//	...
+//	for _, e := range q.Entries() {
+//	    fmt.Printf("0x%02X: %d packets, lengths %v\n", e.Protocol, e.Count, e.Lengths)
+//	}
+//	q.WriteReport(f)
+type Quarantine struct {
+	maxSamples int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[byte]*QuarantineEntry
+}
+
+// QuarantineOption configures a Quarantine
+type QuarantineOption func(*Quarantine)
+
+// WithQuarantineSamples sets the number of sample packets kept per protocol
+func WithQuarantineSamples(n int) QuarantineOption {
+	return func(q *Quarantine) {
+		if n >= 0 {
+			q.maxSamples = n
+		}
+	}
+}
+
+// WithQuarantineClock sets the time source (for tests)
+func WithQuarantineClock(now func() time.Time) QuarantineOption {
+	return func(q *Quarantine) {
+		q.now = now
+	}
+}
+
+// NewQuarantine creates an empty quarantine
+func NewQuarantine(opts ...QuarantineOption) *Quarantine {
+	q := &Quarantine{
+		maxSamples: DefaultQuarantineSamples,
+		now:        time.Now,
+		entries:    make(map[byte]*QuarantineEntry),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Record adds one packet with an unknown protocol number
+//
+// The first packet of each new length is always sampled (while there is room),
+// so the samples cover as many layouts as possible.
+func (q *Quarantine) Record(protocolNum byte, data []byte) {
+	now := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.entries[protocolNum]
+	if !ok {
+		e = &QuarantineEntry{
+			Protocol:  protocolNum,
+			FirstSeen: now,
+			Lengths:   make(map[int]int),
+		}
+		q.entries[protocolNum] = e
+	}
+
+	e.Count++
+	e.LastSeen = now
+	newLength := e.Lengths[len(data)] == 0
+	e.Lengths[len(data)]++
+
+	if len(e.Samples) >= q.maxSamples && !newLength {
+		return
+	}
+	sample := strings.ToUpper(hex.EncodeToString(data))
+	for _, s := range e.Samples {
+		if s == sample {
+			return
+		}
+	}
+	if len(e.Samples) < q.maxSamples {
+		e.Samples = append(e.Samples, sample)
+		return
+	}
+	// Full: replace a sample whose length is already covered by another one
+	if i := q.redundantSample(e); i >= 0 {
+		e.Samples[i] = sample
+	}
+}
+
+// redundantSample returns the index of the newest sample sharing its length
+// with an earlier one, or -1
+func (q *Quarantine) redundantSample(e *QuarantineEntry) int {
+	seen := make(map[int]bool, len(e.Samples))
+	found := -1
+	for i, s := range e.Samples {
+		if seen[len(s)] {
+			found = i
+		}
+		seen[len(s)] = true
+	}
+	return found
+}
+
+// Entries returns all entries, most frequent first
+func (q *Quarantine) Entries() []QuarantineEntry {
+	q.mu.Lock()
+	out := make([]QuarantineEntry, 0, len(q.entries))
+	for _, e := range q.entries {
+		out = append(out, copyEntry(e))
+	}
+	q.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Protocol < out[j].Protocol
+	})
+	return out
+}
+
+// Entry returns the entry of one protocol number
+func (q *Quarantine) Entry(protocolNum byte) (QuarantineEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.entries[protocolNum]
+	if !ok {
+		return QuarantineEntry{}, false
+	}
+	return copyEntry(e), true
+}
+
+// Len returns the number of quarantined protocol numbers
+func (q *Quarantine) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Reset removes all entries
+func (q *Quarantine) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = make(map[byte]*QuarantineEntry)
+}
+
+// Report returns a snapshot of the quarantine
+func (q *Quarantine) Report() QuarantineReport {
+	return QuarantineReport{
+		GeneratedAt: q.now().UTC(),
+		Entries:     q.Entries(),
+	}
+}
+
+// WriteReport writes the report as indented JSON
+func (q *Quarantine) WriteReport(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(q.Report())
+}
+
+// LoadReport merges a report written by WriteReport, so counts survive restarts
+func (q *Quarantine) LoadReport(r io.Reader) error {
+	var report QuarantineReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, in := range report.Entries {
+		e, ok := q.entries[in.Protocol]
+		if !ok {
+			c := copyEntry(&in)
+			if c.Lengths == nil {
+				c.Lengths = make(map[int]int)
+			}
+			q.entries[in.Protocol] = &c
+			continue
+		}
+
+		e.Count += in.Count
+		if !in.FirstSeen.IsZero() && (e.FirstSeen.IsZero() || in.FirstSeen.Before(e.FirstSeen)) {
+			e.FirstSeen = in.FirstSeen
+		}
+		if in.LastSeen.After(e.LastSeen) {
+			e.LastSeen = in.LastSeen
+		}
+		for n, c := range in.Lengths {
+			e.Lengths[n] += c
+		}
+		for _, s := range in.Samples {
+			if len(e.Samples) >= q.maxSamples {
+				break
+			}
+			if !containsString(e.Samples, s) {
+				e.Samples = append(e.Samples, s)
+			}
+		}
+	}
+	return nil
+}
+
+// copyEntry deep-copies an entry
+func copyEntry(e *QuarantineEntry) QuarantineEntry {
+	c := *e
+	c.Lengths = make(map[int]int, len(e.Lengths))
+	for n, count := range e.Lengths {
+		c.Lengths[n] = count
+	}
+	c.Samples = append([]string(nil), e.Samples...)
+	return c
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
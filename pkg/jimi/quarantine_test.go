@@ -0,0 +1,120 @@
+package jimi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/validator"
+)
+
+// unknownPacket builds a valid short frame for a protocol without a parser
+func unknownPacket(protocolNum byte, content []byte, serial uint16) []byte {
+	frame := []byte{0x78, 0x78, byte(1 + len(content) + 4), protocolNum}
+	frame = append(frame, content...)
+	frame = append(frame, byte(serial>>8), byte(serial))
+	frame = validator.AppendCRC(frame)
+	return append(frame, 0x0D, 0x0A)
+}
+
+func TestDecoder_LearningMode(t *testing.T) {
+	q := NewQuarantine()
+	decoder := NewDecoder(WithLearningMode(q))
+
+	packets := [][]byte{
+		unknownPacket(0x99, []byte{0x01, 0x02}, 1),
+		unknownPacket(0x99, []byte{0x01, 0x02}, 1), // duplicate: counted, not sampled twice
+		unknownPacket(0x99, []byte{0x01, 0x02, 0x03}, 2),
+		unknownPacket(0x9A, nil, 3),
+	}
+	for _, p := range packets {
+		if _, err := decoder.Decode(p); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+	}
+
+	// Known protocols are not quarantined
+	if _, err := decoder.DecodeHex("787808132404020001870D0D0A"); err != nil {
+		t.Fatalf("Decode heartbeat failed: %v", err)
+	}
+
+	entries := q.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Protocol != 0x99 || e.Count != 3 {
+		t.Errorf("Expected 0x99 with 3 packets first, got 0x%02X with %d", e.Protocol, e.Count)
+	}
+	if e.Lengths[12] != 2 || e.Lengths[13] != 1 {
+		t.Errorf("Unexpected length histogram %v", e.Lengths)
+	}
+	if len(e.Samples) != 2 {
+		t.Errorf("Expected 2 distinct samples, got %v", e.Samples)
+	}
+}
+
+func TestDecoder_QuarantineRequiresUnknownProtocols(t *testing.T) {
+	q := NewQuarantine()
+	decoder := NewDecoder(WithQuarantine(q))
+
+	_, err := decoder.Decode(unknownPacket(0x99, nil, 1))
+	var protoErr *ProtocolError
+	if !errors.As(err, &protoErr) {
+		t.Errorf("Expected protocol error, got %v", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Expected nothing quarantined, got %d entries", q.Len())
+	}
+}
+
+func TestQuarantine_SamplesPreferNewLengths(t *testing.T) {
+	q := NewQuarantine(WithQuarantineSamples(2))
+
+	q.Record(0x99, []byte{0x01, 0x02})
+	q.Record(0x99, []byte{0x01, 0x03})
+	q.Record(0x99, []byte{0x01, 0x04}) // full, same length: dropped
+	q.Record(0x99, []byte{0x01, 0x02, 0x03})
+
+	e, _ := q.Entry(0x99)
+	want := []string{"0102", "010203"}
+	if len(e.Samples) != len(want) || e.Samples[0] != want[0] || e.Samples[1] != want[1] {
+		t.Errorf("Expected samples %v, got %v", want, e.Samples)
+	}
+}
+
+func TestQuarantine_ReportRoundTrip(t *testing.T) {
+	t1 := time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	q := NewQuarantine(WithQuarantineClock(func() time.Time { return t1 }))
+	q.Record(0x99, []byte{0x01})
+	q.Record(0x99, []byte{0x01, 0x02})
+
+	var buf bytes.Buffer
+	if err := q.WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewQuarantine(WithQuarantineClock(func() time.Time { return t2 }))
+	restored.Record(0x99, []byte{0x03})
+	if err := restored.LoadReport(&buf); err != nil {
+		t.Fatalf("LoadReport failed: %v", err)
+	}
+
+	e, ok := restored.Entry(0x99)
+	if !ok {
+		t.Fatal("Expected entry after load")
+	}
+	if e.Count != 3 || e.Lengths[1] != 2 || e.Lengths[2] != 1 {
+		t.Errorf("Expected merged counts, got %d %v", e.Count, e.Lengths)
+	}
+	if !e.FirstSeen.Equal(t1) || !e.LastSeen.Equal(t2) {
+		t.Errorf("Expected seen range %v-%v, got %v-%v", t1, t2, e.FirstSeen, e.LastSeen)
+	}
+	if len(e.Samples) != 3 {
+		t.Errorf("Expected 3 samples, got %v", e.Samples)
+	}
+}
@@ -0,0 +1,364 @@
+package response
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/sim"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// FirmwareVersion is the reply to VERSION#, e.g. "[VERSION]VL103M_20240101"
+type FirmwareVersion struct {
+	Raw string `json:"raw"`
+
+	// Version is the reply without its label, e.g. "VL103M_20240101"
+	Version string `json:"version"`
+
+	// Model is the part of Version before the first '_', if any
+	Model string `json:"model,omitempty"`
+
+	// Date is the build date of a YYYYMMDD part of Version, if any
+	Date time.Time `json:"date,omitzero"`
+}
+
+// ParseVersion parses the reply to VERSION#
+func ParseVersion(resp string) (*FirmwareVersion, error) {
+	v := &FirmwareVersion{Raw: resp, Version: strings.TrimSpace(stripLabel(resp, "VER"))}
+	if v.Version == "" {
+		return nil, malformed("empty version")
+	}
+	parts := strings.FieldsFunc(v.Version, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	if strings.Contains(v.Version, "_") {
+		v.Model = parts[0]
+	}
+	for _, part := range parts {
+		if len(part) != 8 {
+			continue
+		}
+		if date, err := time.Parse("20060102", part); err == nil {
+			v.Date = date
+			break
+		}
+	}
+	return v, nil
+}
+
+// DeviceStatus is the reply to STATUS#, e.g.
+// "Battery:100%,GPRS:Link Up,GSM Signal Level:20,GPS:Fixed,ACC:ON,Defense:OFF"
+type DeviceStatus struct {
+	Raw string `json:"raw"`
+
+	// Battery is the battery level in percent (0 when not reported)
+	Battery int `json:"battery,omitempty"`
+
+	// GSMSignal is the GSM signal level as reported (0 when not reported)
+	GSMSignal int `json:"gsm_signal,omitempty"`
+
+	// GPS is the positioning state as reported, e.g. "Fixed"
+	GPS string `json:"gps,omitempty"`
+
+	// Fields has every "name:value" pair of the reply, by name as reported
+	Fields map[string]string `json:"fields"`
+}
+
+// ParseStatus parses the reply to STATUS#
+func ParseStatus(resp string) (*DeviceStatus, error) {
+	pairs := splitPairs(resp, ",;")
+	if len(pairs) == 0 {
+		return nil, malformed("no status fields in %q", resp)
+	}
+	s := &DeviceStatus{Raw: resp, Fields: make(map[string]string, len(pairs))}
+	for _, p := range pairs {
+		s.Fields[p.name] = p.value
+		switch p.key {
+		case "battery", "bat", "batterylevel", "power":
+			s.Battery = leadingInt(p.value)
+		case "gsm", "gsmsignal", "gsmsignallevel", "signal", "csq":
+			s.GSMSignal = leadingInt(p.value)
+		case "gps":
+			s.GPS = p.value
+		}
+	}
+	return s, nil
+}
+
+// GPSFixed reports whether the reported GPS state is a fix
+func (s *DeviceStatus) GPSFixed() bool {
+	gps := strings.ToLower(s.GPS)
+	if strings.Contains(gps, "not") || strings.Contains(gps, "un") || strings.Contains(gps, "fail") {
+		return false
+	}
+	return strings.Contains(gps, "fix") || strings.Contains(gps, "success")
+}
+
+// Flag returns an ON/OFF field of the reply (e.g. "ACC", "Defense"), case-insensitively
+func (s *DeviceStatus) Flag(name string) (on, ok bool) {
+	for field, value := range s.Fields {
+		if strings.EqualFold(field, name) {
+			return onOff(value)
+		}
+	}
+	return false, false
+}
+
+// ParamSet is the reply to PARAM#, e.g. "TIMER:10,180;SENDS:5;SOS:,,"
+type ParamSet struct {
+	Raw string `json:"raw"`
+
+	// Params maps the parameter names, as reported, to their values
+	Params map[string]string `json:"params"`
+
+	// Names are the parameter names in reply order
+	Names []string `json:"names"`
+}
+
+// ParseParams parses the reply to PARAM#
+func ParseParams(resp string) (*ParamSet, error) {
+	pairs := splitPairs(resp, ";")
+	if len(pairs) == 0 {
+		return nil, malformed("no parameters in %q", resp)
+	}
+	ps := &ParamSet{Raw: resp, Params: make(map[string]string, len(pairs))}
+	for _, p := range pairs {
+		if _, dup := ps.Params[p.name]; !dup {
+			ps.Names = append(ps.Names, p.name)
+		}
+		ps.Params[p.name] = p.value
+	}
+	return ps, nil
+}
+
+// Get returns the value of a parameter, case-insensitively
+func (ps *ParamSet) Get(name string) (string, bool) {
+	if v, ok := ps.Params[name]; ok {
+		return v, true
+	}
+	for _, n := range ps.Names {
+		if strings.EqualFold(n, name) {
+			return ps.Params[n], true
+		}
+	}
+	return "", false
+}
+
+// Ints returns the comma-separated integer values of a parameter, e.g.
+// [10 180] for "TIMER:10,180"
+func (ps *ParamSet) Ints(name string) ([]int, bool) {
+	v, ok := ps.Get(name)
+	if !ok {
+		return nil, false
+	}
+	var out []int
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, true
+}
+
+// ParseICCID parses the reply to ICCID#, e.g. "ICCID:89860012345678901234",
+// into the SIM identifiers it carries (Time is not set)
+func ParseICCID(resp string) (sim.Identity, error) {
+	var id sim.Identity
+	pairs := splitPairs(resp, ",;")
+	for _, p := range pairs {
+		switch p.key {
+		case "iccid":
+			id.ICCID = p.value
+		case "imsi":
+			id.IMSI = p.value
+		}
+	}
+	if len(pairs) == 0 {
+		id.ICCID = strings.TrimSpace(resp)
+	}
+	id.ICCID = strings.TrimRight(strings.ToUpper(id.ICCID), "F")
+	if len(id.ICCID) < 18 || len(id.ICCID) > 22 || !isDigits(id.ICCID) {
+		return sim.Identity{}, malformed("invalid ICCID in %q", resp)
+	}
+	return id, nil
+}
+
+// Position is the reply to WHERE#, e.g.
+// "Lat:N22.571258,Lon:E113.861603,Course:90.00,Speed:0.00Km/h,DateTime:2024-06-15 14:30:00"
+// or a map link "http://maps.google.com/maps?q=N22.571258,E113.861603"
+type Position struct {
+	Raw         string            `json:"raw"`
+	Coordinates types.Coordinates `json:"coordinates"`
+
+	// Speed (km/h) and Course (degrees) are 0 when not reported
+	Speed  float64 `json:"speed,omitempty"`
+	Course float64 `json:"course,omitempty"`
+
+	// Time is the time of the fix, if reported
+	Time time.Time `json:"time,omitzero"`
+}
+
+// positionTimeLayouts are the date-time layouts of WHERE# replies
+var positionTimeLayouts = []string{"2006-01-02 15:04:05", "06-01-02 15:04:05", "2006/01/02 15:04:05"}
+
+// ParsePosition parses the reply to WHERE#
+func ParsePosition(resp string) (*Position, error) {
+	if _, query, ok := strings.Cut(resp, "q="); ok {
+		lat, lon, _ := strings.Cut(query, ",")
+		lon, _, _ = strings.Cut(lon, "&")
+		return position(resp, lat, strings.TrimFunc(lon, func(r rune) bool { return !unicode.IsPrint(r) || r == ' ' }))
+	}
+
+	var lat, lon string
+	var p Position
+	for _, f := range splitPairs(resp, ",;") {
+		switch f.key {
+		case "lat", "latitude":
+			lat = f.value
+		case "lon", "lng", "longitude":
+			lon = f.value
+		case "speed":
+			p.Speed = leadingFloat(f.value)
+		case "course", "direction":
+			p.Course = leadingFloat(f.value)
+		case "datetime", "time":
+			for _, layout := range positionTimeLayouts {
+				if t, err := time.Parse(layout, f.value); err == nil {
+					p.Time = t
+					break
+				}
+			}
+		}
+	}
+	pos, err := position(resp, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	p.Raw, p.Coordinates = pos.Raw, pos.Coordinates
+	return &p, nil
+}
+
+// position returns the position of a latitude and a longitude with optional
+// hemisphere letters ("N22.5", "22.5S", "-22.5")
+func position(resp, lat, lon string) (*Position, error) {
+	latitude, err := coordinate(lat, 'N', 'S')
+	if err != nil {
+		return nil, malformed("latitude %q: %v", lat, err)
+	}
+	longitude, err := coordinate(lon, 'E', 'W')
+	if err != nil {
+		return nil, malformed("longitude %q: %v", lon, err)
+	}
+	coords, err := types.NewCoordinates(latitude, longitude)
+	if err != nil {
+		return nil, malformed("%v", err)
+	}
+	return &Position{Raw: resp, Coordinates: coords}, nil
+}
+
+// coordinate parses signed degrees with an optional leading or trailing
+// hemisphere letter
+func coordinate(s string, pos, neg byte) (float64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	sign := 1.0
+	for _, trim := range []func(string, string) (string, bool){strings.CutPrefix, strings.CutSuffix} {
+		if rest, ok := trim(s, string(neg)); ok {
+			s, sign = strings.TrimSpace(rest), -1
+		} else if rest, ok := trim(s, string(pos)); ok {
+			s = strings.TrimSpace(rest)
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return sign * v, err
+}
+
+// pair is a "name:value" field of a reply
+type pair struct {
+	name  string // as reported
+	key   string // lower-case letters of the name's last word, for matching
+	value string
+}
+
+// splitPairs splits a reply into its "name:value" fields at the separators
+// seps; fields without ':' or with an empty name are skipped
+func splitPairs(resp, seps string) []pair {
+	var out []pair
+	for _, field := range strings.FieldsFunc(resp, func(r rune) bool { return strings.ContainsRune(seps, r) }) {
+		name, value, ok := strings.Cut(field, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		out = append(out, pair{name: name, key: pairKey(name), value: strings.TrimSpace(value)})
+	}
+	return out
+}
+
+// pairKey returns the lower-case letters of the last word of a name, so
+// "Current position!Lat" matches "lat" and "GSM Signal Level" matches
+// "gsmsignallevel"
+func pairKey(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	i := strings.LastIndexFunc(name, func(r rune) bool { return r < 'a' || r > 'z' })
+	return name[i+1:]
+}
+
+// stripLabel removes a label containing marker ("[VERSION]", "Ver:") from
+// the start of a reply
+func stripLabel(resp, marker string) string {
+	resp = strings.TrimSpace(resp)
+	if strings.HasPrefix(resp, "[") {
+		if label, rest, ok := strings.Cut(resp[1:], "]"); ok && strings.Contains(strings.ToUpper(label), marker) {
+			return rest
+		}
+	}
+	if label, rest, ok := strings.Cut(resp, ":"); ok && strings.Contains(strings.ToUpper(label), marker) {
+		return rest
+	}
+	return resp
+}
+
+// leadingInt returns the integer at the start of s ("100%" = 100), 0 if none
+func leadingInt(s string) int {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(s)
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// leadingFloat returns the number at the start of s ("0.00Km/h" = 0), 0 if none
+func leadingFloat(s string) float64 {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' })
+	if end < 0 {
+		end = len(s)
+	}
+	v, _ := strconv.ParseFloat(s[:end], 64)
+	return v
+}
+
+// onOff parses an ON/OFF style value
+func onOff(s string) (on, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "ON", "1", "YES", "TRUE", "OPEN":
+		return true, true
+	case "OFF", "0", "NO", "FALSE", "CLOSE", "CLOSED":
+		return false, true
+	}
+	return false, false
+}
+
+// isDigits reports whether s has only decimal digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
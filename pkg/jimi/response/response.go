@@ -0,0 +1,142 @@
+// Package response interprets the text devices send in reply to online
+// commands (CommandResponsePacket.Response).
+//
+// The protocol leaves the reply to each command free-form ASCII. A Registry
+// maps commands to grammars turning their replies into typed values; it
+// knows the replies to VERSION#, STATUS#, PARAM#, ICCID# and WHERE#, and
+// accepts grammars for other commands or firmware dialects. The reply does
+// not name its command: pass the command sent with the server flag the
+// reply echoes.
+//
+// Example usage:
+//
+//	v, err := response.Parse(encoder.CmdGetStatus, p.Response)
+//	if status, ok := v.(*response.DeviceStatus); ok {
+//	    log.Printf("battery %d%%, GSM %d", status.Battery, status.GSMSignal)
+//	}
+//
+//	// A custom grammar, for the argument of the reply to a BALANCE# command
+//	registry := response.NewRegistry()
+//	registry.MustRegister("BALANCE#", func(resp string) (any, error) {
+//	    return strings.TrimPrefix(resp, "Balance:"), nil
+//	})
+package response
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+)
+
+var (
+	// ErrUnknownCommand is returned for a command without a grammar
+	ErrUnknownCommand = errors.New("no grammar for command")
+
+	// ErrMalformed is wrapped by the errors of replies a grammar does not match
+	ErrMalformed = errors.New("malformed response")
+)
+
+// Grammar parses the reply to a command into a typed value
+type Grammar func(response string) (any, error)
+
+// Registry maps commands to the grammars of their replies.
+// It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	grammars map[string]Grammar
+}
+
+// NewRegistry creates a registry with the built-in grammars
+func NewRegistry() *Registry {
+	r := &Registry{grammars: make(map[string]Grammar)}
+	r.MustRegister(encoder.CmdGetVersion, func(resp string) (any, error) { return ParseVersion(resp) })
+	r.MustRegister(encoder.CmdGetStatus, func(resp string) (any, error) { return ParseStatus(resp) })
+	r.MustRegister(encoder.CmdGetParam, func(resp string) (any, error) { return ParseParams(resp) })
+	r.MustRegister(encoder.CmdGetICCID, func(resp string) (any, error) { return ParseICCID(resp) })
+	r.MustRegister(encoder.CmdSingleLocation, func(resp string) (any, error) { return ParsePosition(resp) })
+	return r
+}
+
+// DefaultRegistry is the registry used by Parse
+var DefaultRegistry = NewRegistry()
+
+// Parse interprets the reply to a command with DefaultRegistry
+func Parse(command, response string) (any, error) {
+	return DefaultRegistry.Parse(command, response)
+}
+
+// Register adds the grammar of the replies to a command
+// Returns an error if the command already has a grammar
+func (r *Registry) Register(command string, g Grammar) error {
+	key := commandKey(command)
+	if key == "" {
+		return fmt.Errorf("invalid command %q", command)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.grammars[key]; exists {
+		return fmt.Errorf("grammar for command %s already registered", key)
+	}
+	r.grammars[key] = g
+	return nil
+}
+
+// MustRegister adds a grammar and panics if registration fails
+func (r *Registry) MustRegister(command string, g Grammar) {
+	if err := r.Register(command, g); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes the grammar of a command, e.g. to replace a built-in one
+func (r *Registry) Unregister(command string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.grammars, commandKey(command))
+}
+
+// Commands returns the names of the commands with a grammar, sorted
+func (r *Registry) Commands() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.grammars))
+	for key := range r.grammars {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Parse interprets the reply to a command. Arguments and case of the
+// command are ignored: "where,1#" selects the grammar of WHERE#.
+func (r *Registry) Parse(command, response string) (any, error) {
+	key := commandKey(command)
+	r.mu.RLock()
+	g, ok := r.grammars[key]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownCommand, command)
+	}
+	v, err := g(strings.TrimSpace(response))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return v, nil
+}
+
+// commandKey returns the name of a command: upper case, without arguments
+// and the terminating '#'
+func commandKey(command string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(command), ",")
+	return strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(name), "#"))
+}
+
+// malformed returns an error wrapping ErrMalformed
+func malformed(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrMalformed, fmt.Sprintf(format, args...))
+}
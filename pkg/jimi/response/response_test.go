@@ -0,0 +1,188 @@
+package response
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/sim"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		resp      string
+		wantVer   string
+		wantModel string
+		wantDate  time.Time
+		wantErr   bool
+	}{
+		{"[VERSION]VL103M_20240101", "VL103M_20240101", "VL103M", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"Ver:GT06N_20_60DM2_B25E_V5", "GT06N_20_60DM2_B25E_V5", "GT06N", time.Time{}, false},
+		{"VL103M V1.0.3", "VL103M V1.0.3", "", time.Time{}, false},
+		{"[VERSION]", "", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resp, func(t *testing.T) {
+			v, err := ParseVersion(tt.resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if v.Version != tt.wantVer || v.Model != tt.wantModel || !v.Date.Equal(tt.wantDate) {
+				t.Errorf("Unexpected version %+v", v)
+			}
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	s, err := ParseStatus("Battery:100%,GPRS:Link Up,GSM Signal Level:20,GPS:Successful positioning,ACC:ON,Defense:OFF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Battery != 100 || s.GSMSignal != 20 || !s.GPSFixed() || s.Fields["GPRS"] != "Link Up" {
+		t.Errorf("Unexpected status %+v", s)
+	}
+	if on, ok := s.Flag("acc"); !on || !ok {
+		t.Errorf("Expected ACC on, got %v %v", on, ok)
+	}
+	if on, ok := s.Flag("Defense"); on || !ok {
+		t.Errorf("Expected defense off, got %v %v", on, ok)
+	}
+	if _, ok := s.Flag("Oil"); ok {
+		t.Error("Expected no oil flag")
+	}
+
+	// The generated test packets reply in the short form
+	s, err = ParseStatus("Battery:45%,GPS:Not fixed,GSM:12")
+	if err != nil || s.Battery != 45 || s.GSMSignal != 12 || s.GPSFixed() {
+		t.Errorf("Unexpected status %+v (%v)", s, err)
+	}
+
+	if _, err := ParseStatus("OK"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	ps, err := ParseParams("IMEI:359339073930523;TIMER:10,180;SENDS:5;SOS:,,;Center:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"IMEI", "TIMER", "SENDS", "SOS", "Center"}; !reflect.DeepEqual(ps.Names, want) {
+		t.Errorf("Expected names %v, got %v", want, ps.Names)
+	}
+	if timer, ok := ps.Ints("timer"); !ok || !reflect.DeepEqual(timer, []int{10, 180}) {
+		t.Errorf("Unexpected TIMER %v", timer)
+	}
+	if v, ok := ps.Get("SOS"); !ok || v != ",," {
+		t.Errorf("Unexpected SOS %q", v)
+	}
+	if _, ok := ps.Ints("SOS"); ok {
+		t.Error("Expected SOS not to be integers")
+	}
+	if _, ok := ps.Get("GMT"); ok {
+		t.Error("Expected no GMT parameter")
+	}
+}
+
+func TestParseICCID(t *testing.T) {
+	tests := []struct {
+		resp    string
+		want    sim.Identity
+		wantErr bool
+	}{
+		{"ICCID:89860012345678901234", sim.Identity{ICCID: "89860012345678901234"}, false},
+		{"89860012345678901234", sim.Identity{ICCID: "89860012345678901234"}, false},
+		{"IMSI:460011234567890,ICCID:8986001234567890123F", sim.Identity{ICCID: "8986001234567890123", IMSI: "460011234567890"}, false},
+		{"ICCID:ERROR", sim.Identity{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resp, func(t *testing.T) {
+			id, err := ParseICCID(tt.resp)
+			if (err != nil) != tt.wantErr || id != tt.want {
+				t.Errorf("ParseICCID() = %+v, %v", id, err)
+			}
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      string
+		lat, lon  float64
+		speed     float64
+		course    float64
+		time      time.Time
+		wantError bool
+	}{
+		{"fields", "Current position!Lat:N22.571258,Lon:E113.861603,Course:90.00,Speed:12.50Km/h,DateTime:2024-06-15 14:30:00",
+			22.571258, 113.861603, 12.5, 90, time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC), false},
+		{"southern", "Lat:S33.868820,Lon:E151.209296", -33.868820, 151.209296, 0, 0, time.Time{}, false},
+		{"link", "<Date Time:24-06-15 14:30:00>http://maps.google.com/maps?q=N22.571258,W113.861603", 22.571258, -113.861603, 0, 0, time.Time{}, false},
+		{"no fix", "No GPS fix", 0, 0, 0, 0, time.Time{}, true},
+		{"out of range", "Lat:N95.0,Lon:E10.0", 0, 0, 0, 0, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePosition(tt.resp)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("ParsePosition() error = %v, wantError %v", err, tt.wantError)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrMalformed) {
+					t.Errorf("Expected ErrMalformed, got %v", err)
+				}
+				return
+			}
+			lat, lon := p.Coordinates.SignedLatitude(), p.Coordinates.SignedLongitude()
+			if math.Abs(lat-tt.lat) > 1e-9 || math.Abs(lon-tt.lon) > 1e-9 {
+				t.Errorf("Expected %f,%f, got %f,%f", tt.lat, tt.lon, lat, lon)
+			}
+			if p.Speed != tt.speed || p.Course != tt.course || !p.Time.Equal(tt.time) || p.Raw != tt.resp {
+				t.Errorf("Unexpected position %+v", p)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	// Arguments and case of the command do not matter
+	v, err := Parse("version#", "[VERSION]VL103M_20240101")
+	if fw, ok := v.(*FirmwareVersion); err != nil || !ok || fw.Model != "VL103M" {
+		t.Errorf("Unexpected %#v (%v)", v, err)
+	}
+	if _, err := Parse("where,1#", "Lat:N22.5,Lon:E113.8"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if _, err := Parse("RESET#", "OK"); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("Expected ErrUnknownCommand, got %v", err)
+	}
+	if _, err := Parse("STATUS#", "OK"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed, got %v", err)
+	}
+
+	r := NewRegistry()
+	if err := r.Register("STATUS#", nil); err == nil {
+		t.Error("Expected an error registering a command twice")
+	}
+	if err := r.Register("#", nil); err == nil {
+		t.Error("Expected an error registering an empty command")
+	}
+	r.MustRegister("BALANCE#", func(resp string) (any, error) { return resp[len("Balance:"):], nil })
+	if v, err := r.Parse("BALANCE#", "Balance:12.30"); err != nil || v != "12.30" {
+		t.Errorf("Unexpected %v (%v)", v, err)
+	}
+	r.Unregister("version#")
+	if want := []string{"BALANCE", "ICCID", "PARAM", "STATUS", "WHERE"}; !reflect.DeepEqual(r.Commands(), want) {
+		t.Errorf("Expected %v, got %v", want, r.Commands())
+	}
+	if _, err := DefaultRegistry.Parse("BALANCE#", "Balance:1"); !errors.Is(err, ErrUnknownCommand) {
+		t.Error("Expected registries to be independent")
+	}
+}
@@ -0,0 +1,65 @@
+package jimi
+
+import "github.com/fcode09/jimi-vl103m/internal/splitter"
+
+// ResyncPolicy controls how streams recover from corrupted data (see
+// WithResyncPolicy). Without a policy, garbage with no start bit after it
+// fails the whole split: DecodeStream returns no packets in strict mode and
+// a trailing byte that may start the next packet is dropped.
+type ResyncPolicy struct {
+	// MaxScanBytes bounds the bytes searched for a start bit per split
+	// (0 = no limit); the rest is kept as residue and searched on the next
+	// split, so a flood of garbage cannot stall one call
+	MaxScanBytes int
+
+	// MaxDiscardBytes bounds the bytes discarded per split (0 = no limit);
+	// beyond it the split stops with ErrResyncLimit and the remaining data
+	// is dropped, e.g. to close a connection that only sends garbage
+	MaxDiscardBytes int
+
+	// OnDiscard is called with every run of discarded bytes and the reason
+	// ("no start bit" or "invalid stop bit"), after logging and metrics
+	OnDiscard func(discarded []byte, reason string)
+}
+
+// WithResyncPolicy makes corrupted stream data non-fatal: DecodeStream,
+// SplitPackets and StreamDecoder discard bytes up to the next start bit and
+// keep the packets before and after them, in strict mode too. Decode errors
+// of complete packets are still handled by the strict mode.
+func WithResyncPolicy(p ResyncPolicy) Option {
+	return func(o *Options) {
+		o.Resync = &p
+	}
+}
+
+// customSplit reports whether streams are split with a resync policy or
+// stream limits
+func (o *Options) customSplit() bool {
+	return o.Resync != nil || o.MaxDeclaredLength > 0 || o.MaxResidueSize > 0
+}
+
+// split splits a stream, applying the resync policy and stream limits
+func (o *Options) split(data []byte) (packets [][]byte, residue []byte, err error) {
+	discard := o.discardFunc()
+	policy := splitter.Policy{
+		MaxLength:  o.MaxDeclaredLength,
+		MaxResidue: o.MaxResidueSize,
+		Discard:    discard,
+	}
+	if o.Resync == nil {
+		return splitter.SplitPacketsPolicy(data, policy)
+	}
+
+	policy.Resync = true
+	policy.MaxScan = o.Resync.MaxScanBytes
+	policy.MaxDiscard = o.Resync.MaxDiscardBytes
+	if fn := o.Resync.OnDiscard; fn != nil {
+		policy.Discard = func(discarded []byte, reason string) {
+			if discard != nil {
+				discard(discarded, reason)
+			}
+			fn(discarded, reason)
+		}
+	}
+	return splitter.SplitPacketsPolicy(data, policy)
+}
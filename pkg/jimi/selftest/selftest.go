@@ -0,0 +1,331 @@
+// Package selftest checks a deployment at startup, so problems show up
+// before the first device connects instead of in its first packets.
+//
+// A self-test is a list of checks run in order. Corpus and RoundTrip verify
+// the decoder and encoder against the packet corpus built into the library;
+// the application adds checks for its sinks (files, brokers, databases):
+//
+//	report := selftest.Run(ctx,
+//	    selftest.Corpus(),
+//	    selftest.RoundTrip(nil),
+//	    selftest.Check{Name: "mqtt broker", Run: func(ctx context.Context) (string, error) {
+//	        return "", client.Ping(ctx)
+//	    }},
+//	)
+//	log.Print(report)
+//	if err := report.Err(); err != nil {
+//	    log.Fatal(err)
+//	}
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// ErrFailed is returned by Report.Err when a check failed
+var ErrFailed = errors.New("self-test failed")
+
+// maxProblems bounds the problems listed in the error of a corpus check
+const maxProblems = 5
+
+// Check is one self-test step. Run returns a short detail on success (e.g.
+// the number of packets checked) and an error on failure.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string        `json:"name"`
+	Detail   string        `json:"detail,omitempty"`
+	Err      error         `json:"-"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Passed reports whether the check succeeded
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Report is the outcome of a self-test
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Run runs the checks in order. A check that panics fails with the panic.
+func Run(ctx context.Context, checks ...Check) Report {
+	var r Report
+	for _, c := range checks {
+		start := time.Now()
+		detail, err := run(ctx, c)
+		r.Results = append(r.Results, Result{
+			Name:     c.Name,
+			Detail:   detail,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+	return r
+}
+
+// run runs one check, recovering from panics
+func run(ctx context.Context, c Check) (detail string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return c.Run(ctx)
+}
+
+// Passed reports whether every check succeeded
+func (r Report) Passed() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the results of the failed checks
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns nil when every check succeeded, or an ErrFailed naming the
+// failed checks
+func (r Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	names := make([]string, len(failed))
+	for i, res := range failed {
+		names[i] = res.Name
+	}
+	return fmt.Errorf("%w: %d of %d checks (%s)", ErrFailed, len(failed), len(r.Results), strings.Join(names, ", "))
+}
+
+// String formats the report with one line per check:
+//
+//	PASS  decode corpus  52 packets, 22 protocols, 4 rejected (1ms)
+//	FAIL  mqtt broker    mqtt: connect: dial tcp 10.0.0.5:1883: connection refused (5s)
+func (r Report) String() string {
+	width := 0
+	for _, res := range r.Results {
+		width = max(width, len(res.Name))
+	}
+
+	var b strings.Builder
+	for _, res := range r.Results {
+		status, detail := "PASS", res.Detail
+		if !res.Passed() {
+			status, detail = "FAIL", strings.ReplaceAll(res.Err.Error(), "\n", "; ")
+		}
+		fmt.Fprintf(&b, "%s  %-*s  %s (%s)\n", status, width, res.Name, detail, res.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// Corpus decodes the packet corpus built into the library: hand-written
+// samples and generated packets of every protocol. Every valid packet must
+// decode to its protocol and every invalid one (hand-written or corrupted
+// generated packets) must be rejected. opts are
+// added to the decoder options, so the check runs with the configuration of
+// the application. Checksums and IMEI check digits are only verified for
+// the generated packets (which use the protocol default CRC).
+func Corpus(opts ...jimi.Option) Check {
+	return Check{
+		Name: "decode corpus",
+		Run: func(ctx context.Context) (string, error) {
+			decoder := jimi.NewDecoder(append([]jimi.Option{
+				jimi.WithSkipCRC(),
+				jimi.WithLenientMode(),
+				jimi.WithoutIMEIValidation(),
+			}, opts...)...)
+			generated := jimi.NewDecoder(append(opts, jimi.WithCRCVariant(jimi.CRCX25))...)
+			strict := jimi.NewDecoder()
+
+			var problems []error
+			protocols := make(map[byte]bool)
+			samples := packets.GetAllValidPackets()
+			valid := append(samples[:len(samples):len(samples)], packets.GeneratedPackets...)
+			for i, tp := range valid {
+				d := decoder
+				if i >= len(samples) {
+					d = generated
+				}
+				p, err := d.DecodeHex(tp.Hex)
+				switch {
+				case err != nil:
+					problems = append(problems, fmt.Errorf("%s: %w", tp.Name, err))
+				case p.ProtocolNumber() != tp.Protocol:
+					problems = append(problems, fmt.Errorf("%s: decoded protocol 0x%02X, expected 0x%02X", tp.Name, p.ProtocolNumber(), tp.Protocol))
+				default:
+					protocols[tp.Protocol] = true
+				}
+			}
+			invalid := append(packets.InvalidPackets[:len(packets.InvalidPackets):len(packets.InvalidPackets)], packets.CorruptedPackets...)
+			for _, tp := range invalid {
+				if _, err := strict.DecodeHex(tp.Hex); err == nil {
+					problems = append(problems, fmt.Errorf("%s: decoded, expected an error", tp.Name))
+				}
+			}
+
+			if err := joinProblems(problems); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d packets, %d protocols, %d rejected", len(valid), len(protocols), len(invalid)), ctx.Err()
+		},
+	}
+}
+
+// RoundTrip re-encodes the corpus packets that have a device encoder (login,
+// heartbeat, location, alarm, LBS, WiFi, command response and information
+// transfer) with the CRC variant crc (nil for the protocol default). Each
+// encoded packet must pass the checksum, decode again and encode to the same
+// bytes.
+func RoundTrip(crc jimi.CRC) Check {
+	return Check{
+		Name: "encoder round trip",
+		Run: func(ctx context.Context) (string, error) {
+			lenient := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode(), jimi.WithoutIMEIValidation())
+			strict := jimi.NewDecoder(jimi.WithoutIMEIValidation())
+			enc := encoder.New()
+			if crc != nil {
+				strict = jimi.NewDecoder(jimi.WithoutIMEIValidation(), jimi.WithCRCVariant(crc))
+				enc.CRC = crc
+			}
+
+			var problems []error
+			tested := 0
+			for _, tp := range packets.GetAllValidPackets() {
+				p, err := lenient.DecodeHex(tp.Hex)
+				if err != nil {
+					continue // reported by Corpus
+				}
+				ok, err := roundTrip(enc, strict, p)
+				if !ok {
+					continue
+				}
+				if err != nil {
+					problems = append(problems, fmt.Errorf("%s: %w", tp.Name, err))
+				}
+				tested++
+			}
+
+			if err := joinProblems(problems); err != nil {
+				return "", err
+			}
+			if tested == 0 {
+				return "", errors.New("no packets round-tripped")
+			}
+			return fmt.Sprintf("%d packets", tested), ctx.Err()
+		},
+	}
+}
+
+// Writable checks that the file at path can be opened for appending, e.g.
+// an output file that log rotation or a full disk may have broken. The file
+// is created if it does not exist.
+func Writable(name, path string) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) (string, error) {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return "", err
+			}
+			return path, f.Close()
+		},
+	}
+}
+
+// Dial checks that a TCP service (e.g. a broker or database the application
+// writes to) accepts connections at addr
+func Dial(name, addr string) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (string, error) {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return "", err
+			}
+			return addr, conn.Close()
+		},
+	}
+}
+
+// roundTrip encodes p, decodes the result with decoder and compares its
+// encoding; ok is false for packet types without a device encoder
+func roundTrip(enc *encoder.Encoder, decoder *jimi.Decoder, p packet.Packet) (ok bool, err error) {
+	first, ok, err := encodeDevicePacket(enc, p)
+	if !ok || err != nil {
+		return ok, err
+	}
+	again, err := decoder.Decode(first)
+	if err != nil {
+		return true, err
+	}
+	second, _, err := encodeDevicePacket(enc, again)
+	if err == nil && !bytes.Equal(first, second) {
+		err = fmt.Errorf("encoded %s, then %s", hex.EncodeToString(first), hex.EncodeToString(second))
+	}
+	return true, err
+}
+
+// encodeDevicePacket encodes p with the device packet builders; ok is false
+// for packet types without one
+func encodeDevicePacket(enc *encoder.Encoder, p packet.Packet) (data []byte, ok bool, err error) {
+	switch p := p.(type) {
+	case *packet.LoginPacket:
+		data, err = enc.Login(p)
+	case *packet.HeartbeatPacket:
+		data = enc.Heartbeat(p)
+	case *packet.LocationPacket:
+		data = enc.Location(p)
+	case *packet.Location4GPacket:
+		data = enc.Location4G(p)
+	case *packet.AlarmPacket:
+		data = enc.Alarm(p)
+	case *packet.Alarm4GPacket:
+		data = enc.Alarm4G(p)
+	case *packet.LBSPacket:
+		data = enc.LBS(p)
+	case *packet.InfoTransferPacket:
+		data, err = enc.InfoTransfer(p)
+	case *packet.WiFiInfoPacket:
+		data, err = enc.WiFiInfo(p)
+	case *packet.CommandResponsePacket:
+		data = enc.CommandResponse(p)
+	default:
+		return nil, false, nil
+	}
+	return data, true, err
+}
+
+// joinProblems joins the first problems of a check and counts the rest
+func joinProblems(problems []error) error {
+	if len(problems) <= maxProblems {
+		return errors.Join(problems...)
+	}
+	more := fmt.Errorf("and %d more", len(problems)-maxProblems)
+	return errors.Join(append(problems[:maxProblems:maxProblems], more)...)
+}
@@ -0,0 +1,95 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+)
+
+func TestBuiltinChecks(t *testing.T) {
+	dir := t.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	defer l.Close()
+
+	checks := []Check{
+		Corpus(),
+		Corpus(jimi.WithACCAlarmsAsStatus()),
+		RoundTrip(nil),
+		RoundTrip(jimi.CRCITU),
+		Writable("events file", filepath.Join(dir, "events.ndjson")),
+		Dial("broker", l.Addr().String()),
+	}
+	report := Run(context.Background(), checks...)
+	if err := report.Err(); err != nil {
+		t.Fatalf("Expected the built-in checks to pass:\n%s", report)
+	}
+	for _, res := range report.Results {
+		if res.Detail == "" {
+			t.Errorf("%s: expected a detail", res.Name)
+		}
+	}
+
+	if res := Run(context.Background(), Writable("events file", dir)).Results[0]; res.Passed() {
+		t.Error("Expected a directory not writable as a file")
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if res := Run(context.Background(), Dial("broker", addr)).Results[0]; res.Passed() {
+		t.Error("Expected a closed port to fail")
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := Run(context.Background(),
+		Check{Name: "ok", Run: func(context.Context) (string, error) { return "3 items", nil }},
+		Check{Name: "broker", Run: func(context.Context) (string, error) {
+			return "", errors.Join(errors.New("refused"), errors.New("retry failed"))
+		}},
+		Check{Name: "panics", Run: func(context.Context) (string, error) { panic("boom") }},
+	)
+
+	if report.Passed() || len(report.Failed()) != 2 {
+		t.Fatalf("Expected 2 failed checks, got %+v", report.Results)
+	}
+	err := report.Err()
+	if !errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "2 of 3 checks (broker, panics)") {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n")
+	want := []string{"PASS  ok      3 items", "FAIL  broker  refused; retry failed", "FAIL  panics  panic: boom"}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %q", len(want), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]+" (") {
+			t.Errorf("Expected line %q, got %q", want[i], line)
+		}
+	}
+
+	if err := Run(context.Background()).Err(); err != nil {
+		t.Errorf("Expected an empty self-test to pass, got %v", err)
+	}
+}
+
+func TestJoinProblems(t *testing.T) {
+	var problems []error
+	for range maxProblems + 2 {
+		problems = append(problems, errors.New("bad"))
+	}
+	err := joinProblems(problems)
+	if got := strings.Count(err.Error(), "bad"); got != maxProblems || !strings.HasSuffix(err.Error(), "and 2 more") {
+		t.Errorf("Unexpected error %q", err)
+	}
+	if joinProblems(nil) != nil {
+		t.Error("Expected no error without problems")
+	}
+}
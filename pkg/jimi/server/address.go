@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geocoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// WithAddressResolver answers GPS address requests (0x2A) with the address
+// looked up by r, as a 0x17 (Chinese) or 0x97 (English) response in the
+// CRC variant of the encoder. Lookups run in the background so they do not
+// hold up the connection; failures are reported to OnError.
+func WithAddressResolver(r *geocoder.Resolver) Option {
+	return func(s *Server) {
+		s.resolver = r
+	}
+}
+
+// resolveAddress answers a GPS address request in the background
+func (s *Server) resolveAddress(sess *Session, p packet.Packet) {
+	req, ok := p.(*packet.GPSAddressRequestPacket)
+	if !ok || s.resolver == nil || s.passive {
+		return
+	}
+
+	go func() {
+		params, err := s.resolver.Resolve(context.Background(), req)
+		if err != nil {
+			s.report(sess, fmt.Errorf("reverse geocoding: %w", err))
+			return
+		}
+		params.CRC = s.encoder.CRC
+		resp, err := encoder.AddressResponse(params)
+		if err == nil {
+			err = sess.Send(resp)
+		}
+		if err != nil && !s.isClosed() {
+			s.report(sess, fmt.Errorf("address response: %w", err))
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geocoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// addressRequest builds a GPS address request (0x2A) with the GPS data of
+// a location packet
+func addressRequest(t *testing.T, lang protocol.Language) []byte {
+	t.Helper()
+	gps := mustHex(t, packets.LocationPackets[0].Hex)[4:22]
+	content := append(gps, []byte(strings.Repeat("0", 21))...)
+	content = append(content, 0x00, byte(lang))
+	return encoder.New().CustomResponse(protocol.ProtocolGPSAddressRequest, content, 7)
+}
+
+func TestServer_AddressResolver(t *testing.T) {
+	var asked protocol.Language
+	resolver := geocoder.NewResolver(geocoder.ProviderFunc(
+		func(_ context.Context, lat, lon float64, lang protocol.Language) (string, error) {
+			asked = lang
+			return "Main St 1", nil
+		}))
+	_, addr, events := startServer(t, WithAddressResolver(resolver))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	readTCP(t, conn)
+
+	conn.Write(addressRequest(t, protocol.LanguageEnglish))
+	p, err := jimi.NewDecoder().Decode(readTCP(t, conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := p.(*packet.AddressResponsePacket)
+	if !ok || resp.Address != "Main St 1" || resp.SerialNumber() != 7 {
+		t.Fatalf("Expected the address response, got %#v", p)
+	}
+	if asked != protocol.LanguageEnglish {
+		t.Errorf("Expected an English lookup, got %v", asked)
+	}
+}
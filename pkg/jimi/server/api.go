@@ -0,0 +1,874 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/driving"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/state"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/uploads"
+)
+
+// maxCommandBody limits the size of command requests
+const maxCommandBody = 4096
+
+// maxShareMinutes bounds the lifetime of share links (7 days)
+const maxShareMinutes = 7 * 24 * 60
+
+// SessionInfo is the JSON form of a Session
+type SessionInfo struct {
+	IMEI        string      `json:"imei,omitempty"`
+	Transport   string      `json:"transport"`
+	RemoteAddr  string      `json:"remote_addr"`
+	Identity    string      `json:"identity,omitempty"`
+	Geo         *GeoVerdict `json:"geo,omitempty"`
+	Collision   string      `json:"collision,omitempty"`
+	ConnectedAt time.Time   `json:"connected_at"`
+	LastSeen    time.Time   `json:"last_seen"`
+	Packets     int         `json:"packets"`
+	Migrating   bool        `json:"migrating,omitempty"`
+}
+
+// Info returns the JSON form of the session
+func (s *Session) Info() SessionInfo {
+	return SessionInfo{
+		IMEI:        s.IMEI(),
+		Transport:   s.Transport(),
+		RemoteAddr:  s.RemoteAddr(),
+		Identity:    identityName(s.Identity()),
+		Geo:         geoVerdict(s.Geo()),
+		Collision:   s.Collision(),
+		ConnectedAt: s.ConnectedAt(),
+		LastSeen:    s.LastSeen(),
+		Packets:     s.PacketCount(),
+		Migrating:   s.isMigrating(),
+	}
+}
+
+// CommandRequest is the body of a command request
+type CommandRequest struct {
+	// Command is the command text (e.g. "STATUS#")
+	Command string `json:"command"`
+
+	// ServerFlag is echoed by the device in its response
+	ServerFlag uint32 `json:"server_flag"`
+}
+
+// BoostRequest is the body of POST /api/devices/{imei}/boost
+type BoostRequest struct {
+	// Interval is the upload interval during the boost, in seconds
+	Interval int `json:"interval"`
+
+	// Minutes is how long the boost lasts
+	Minutes int `json:"minutes"`
+
+	// Restore is the interval restored afterwards; 0 restores the last
+	// interval acknowledged by the device
+	Restore int `json:"restore,omitempty"`
+}
+
+// MigrationRequest is the body of POST /api/migration
+type MigrationRequest struct {
+	// IMEIs are the devices to migrate; empty migrates every TCP session
+	IMEIs []string `json:"imeis,omitempty"`
+
+	// BatchSize is the number of sessions closed per batch; 0 closes them
+	// all at once
+	BatchSize int `json:"batch_size,omitempty"`
+
+	// Interval is the pause between batches, in seconds
+	// (default DefaultMigrationInterval)
+	Interval int `json:"interval,omitempty"`
+
+	// Grace is how long each session keeps being served before it is
+	// closed, in seconds (default DefaultMigrationGrace)
+	Grace int `json:"grace,omitempty"`
+}
+
+// ShareRequest is the body of POST /api/devices/{imei}/share
+type ShareRequest struct {
+	// Minutes is how long the link is valid
+	Minutes int `json:"minutes"`
+}
+
+// ShareLink is a link to the live position of one device
+type ShareLink struct {
+	IMEI string `json:"imei"`
+
+	// Token grants access to the position without the API token
+	Token string `json:"token"`
+
+	// Path is the API path of the shared position
+	Path string `json:"path"`
+
+	// ExpiresAt is when the link stops working
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// API is an HTTP JSON API for a Server.
+//
+// Endpoints:
+//
+//	GET  /api/sessions                 active sessions
+//	GET  /api/sessions/{imei}          one logged-in session
+//	GET  /api/positions                last known position of every device
+//	GET  /api/positions/{imei}         last known position of one device
+//	POST /api/devices/{imei}/commands  send {"command": "STATUS#", "server_flag": 1}
+//	GET  /api/devices/{imei}/commands/{flag}  delivery outcome of a command (see WithCommandEscalation)
+//	GET  /api/configs                  config snapshot of every device
+//	GET  /api/devices/{imei}/config    config snapshot of one device
+//	POST /api/devices/{imei}/config    refresh the snapshot (sends PARAM# and VERSION#)
+//	POST /api/devices/{imei}/boost     boost reporting {"interval": 10, "minutes": 15}
+//	DELETE /api/devices/{imei}/boost   end the boost and restore the interval now
+//	GET  /api/offline                  devices reported offline (WithOfflineWatchdog)
+//	POST /api/migration                drop sessions for a deploy {"batch_size": 100, "interval": 30}
+//	GET  /api/migration                progress of the last migration
+//	DELETE /api/migration              stop the migration before its next batch
+//	GET  /api/stream                   WebSocket or SSE event feed, ?imei=a,b to filter, ?every=5s to thin
+//	GET  /api/events                   page of the event feed, ?after=cursor&limit=100&imei=a,b
+//	GET  /api/quarantine               unknown protocols (WithAPIQuarantine)
+//	GET  /api/parsers                  parse durations, slowest first, ?limit=10 (WithAPIParseStats)
+//	GET  /api/upload-modes             upload modes per device (WithAPIUploads)
+//	GET  /api/state                    last known state of every device (WithAPIState)
+//	GET  /api/state/{imei}             last known state of one device (WithAPIState)
+//	POST /api/devices/{imei}/share     create a share link {"minutes": 60} (WithAPIShares)
+//	GET  /api/shared/{token}           shared position, no API token needed (WithAPIShares)
+//	GET  /api/groups                   online/moving/alarming counts per group (WithAPIGroups)
+//	GET  /api/groups/{group}           counts and members of one group (WithAPIGroups)
+//	GET  /api/groups/{group}/stream    WebSocket or SSE event feed of a group (WithAPIGroups)
+//	GET  /api/driving                  driver scores, lowest first (WithAPIDriving)
+//	GET  /api/driving/{imei}           driver score of one device (WithAPIDriving)
+//	GET  /metrics                      Prometheus metrics (WithAPIMetrics)
+//
+// Event feeds are WebSockets, or server-sent events for requests accepting
+// text/event-stream. Events carry increasing IDs; a client reconnecting with
+// Last-Event-ID (or ?after=ID) first receives the events it missed, as far
+// as the server history goes (see WithEventHistory). Clients that poll
+// instead pass the "next" cursor of each /api/events page to the following
+// request; "gap" is set when events were lost in between (see Server.Events
+// and Server.PersistEvents). Feeds with ?every= (or WithAPIStreamInterval)
+// carry about one position per device per interval; logins, alarms and other
+// events always pass.
+//
+// Command responses arrive asynchronously as CommandResponsePacket through
+// the Server callbacks; GET /api/devices/{imei}/commands/{flag} reports the
+// delivery outcome, reconciled with the SMS status query of
+// WithCommandEscalation when the device did not respond.
+//
+// Example usage:
+//
+//	api := server.NewAPI(srv, server.WithAPIToken(os.Getenv("API_TOKEN")))
+//	log.Fatal(http.ListenAndServe(":8080", api))
+type API struct {
+	srv        *Server
+	token      string
+	quarantine *jimi.Quarantine
+	parses     *jimi.ParseStats
+	metrics    http.Handler
+	uploads    *uploads.Stats
+	state      *state.Tracker
+	shares     *ShareSigner
+	groups     *Groups
+	driving    *driving.Scorer
+	interval   time.Duration // WithAPIStreamInterval minimum (0 disables)
+	downsample Downsampler
+	mux        *http.ServeMux
+}
+
+// APIOption configures an API
+type APIOption func(*API)
+
+// WithAPIToken requires "Authorization: Bearer <token>" on every request
+func WithAPIToken(token string) APIOption {
+	return func(a *API) {
+		a.token = token
+	}
+}
+
+// WithAPIQuarantine exposes the unknown-protocol quarantine report
+func WithAPIQuarantine(q *jimi.Quarantine) APIOption {
+	return func(a *API) {
+		a.quarantine = q
+	}
+}
+
+// WithAPIParseStats exposes the parse durations per protocol, slowest first
+func WithAPIParseStats(s *jimi.ParseStats) APIOption {
+	return func(a *API) {
+		a.parses = s
+	}
+}
+
+// WithAPIMetrics serves h (e.g. a *metrics.Prometheus) at /metrics
+func WithAPIMetrics(h http.Handler) APIOption {
+	return func(a *API) {
+		a.metrics = h
+	}
+}
+
+// WithAPIUploads exposes upload mode statistics per device.
+// With ?suspect=1 only devices uploading mostly on the suspect labels are listed.
+func WithAPIUploads(u *uploads.Stats) APIOption {
+	return func(a *API) {
+		a.uploads = u
+	}
+}
+
+// WithAPIState exposes the device states of a tracker. Unlike positions,
+// states come from the tracker's store and can outlive server restarts.
+func WithAPIState(t *state.Tracker) APIOption {
+	return func(a *API) {
+		a.state = t
+	}
+}
+
+// WithAPIShares enables share links: expiring tokens signed by s that expose
+// the last known position of one device without the API token
+func WithAPIShares(s *ShareSigner) APIOption {
+	return func(a *API) {
+		a.shares = s
+	}
+}
+
+// WithAPIGroups exposes aggregate counts and event feeds per device group
+func WithAPIGroups(g *Groups) APIOption {
+	return func(a *API) {
+		a.groups = g
+	}
+}
+
+// WithAPIDriving exposes the driver scores of a scorer over its rolling window
+func WithAPIDriving(s *driving.Scorer) APIOption {
+	return func(a *API) {
+		a.driving = s
+	}
+}
+
+// WithAPIStreamInterval thins every event feed to about one position per
+// device per interval, so browsers showing fleets that report every second
+// are not flooded. Clients may ask for a longer interval with ?every=5s;
+// shorter ones are raised to it.
+func WithAPIStreamInterval(interval time.Duration) APIOption {
+	return func(a *API) {
+		a.interval = interval
+	}
+}
+
+// WithAPIDownsampler replaces Downsample as the way event feeds are thinned
+// for WithAPIStreamInterval and ?every=
+func WithAPIDownsampler(d Downsampler) APIOption {
+	return func(a *API) {
+		a.downsample = d
+	}
+}
+
+// NewAPI creates the HTTP API of srv
+func NewAPI(srv *Server, opts ...APIOption) *API {
+	a := &API{srv: srv, downsample: Downsample, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.mux.HandleFunc("GET /api/sessions", a.listSessions)
+	a.mux.HandleFunc("GET /api/sessions/{imei}", a.getSession)
+	a.mux.HandleFunc("GET /api/positions", a.listPositions)
+	a.mux.HandleFunc("GET /api/positions/{imei}", a.getPosition)
+	a.mux.HandleFunc("POST /api/devices/{imei}/commands", a.sendCommand)
+	a.mux.HandleFunc("GET /api/devices/{imei}/commands/{flag}", a.getCommand)
+	a.mux.HandleFunc("GET /api/configs", a.listConfigs)
+	a.mux.HandleFunc("GET /api/devices/{imei}/config", a.getConfig)
+	a.mux.HandleFunc("POST /api/devices/{imei}/config", a.refreshConfig)
+	a.mux.HandleFunc("POST /api/devices/{imei}/boost", a.startBoost)
+	a.mux.HandleFunc("DELETE /api/devices/{imei}/boost", a.endBoost)
+	a.mux.HandleFunc("GET /api/offline", a.listOffline)
+	a.mux.HandleFunc("POST /api/migration", a.startMigration)
+	a.mux.HandleFunc("GET /api/migration", a.getMigration)
+	a.mux.HandleFunc("DELETE /api/migration", a.cancelMigration)
+	a.mux.HandleFunc("GET /api/stream", a.streamDevices)
+	a.mux.HandleFunc("GET /api/events", a.listEvents)
+	if a.quarantine != nil {
+		a.mux.HandleFunc("GET /api/quarantine", a.getQuarantine)
+	}
+	if a.parses != nil {
+		a.mux.HandleFunc("GET /api/parsers", a.listParsers)
+	}
+	if a.uploads != nil {
+		a.mux.HandleFunc("GET /api/upload-modes", a.listUploadModes)
+	}
+	if a.state != nil {
+		a.mux.HandleFunc("GET /api/state", a.listStates)
+		a.mux.HandleFunc("GET /api/state/{imei}", a.getState)
+	}
+	if a.shares != nil {
+		a.mux.HandleFunc("POST /api/devices/{imei}/share", a.createShare)
+		a.mux.HandleFunc("GET /api/shared/{token}", a.getShared)
+	}
+	if a.groups != nil {
+		a.mux.HandleFunc("GET /api/groups", a.listGroups)
+		a.mux.HandleFunc("GET /api/groups/{group}", a.getGroup)
+		a.mux.HandleFunc("GET /api/groups/{group}/stream", a.streamGroup)
+	}
+	if a.driving != nil {
+		a.mux.HandleFunc("GET /api/driving", a.listDriving)
+		a.mux.HandleFunc("GET /api/driving/{imei}", a.getDriving)
+	}
+	if a.metrics != nil {
+		a.mux.Handle("GET /metrics", a.metrics)
+	}
+	return a
+}
+
+// ServeHTTP implements http.Handler
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.token != "" && !a.isShared(r) {
+		auth := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// isShared reports whether r reads a shared position, authorized by its share token
+func (a *API) isShared(r *http.Request) bool {
+	return a.shares != nil && r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/shared/")
+}
+
+func (a *API) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions := a.srv.Sessions()
+	out := make([]SessionInfo, len(sessions))
+	for i, s := range sessions {
+		out[i] = s.Info()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *API) getSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.srv.Session(pathIMEI(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, "device not connected")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Info())
+}
+
+func (a *API) listPositions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.srv.LastPositions())
+}
+
+func (a *API) getPosition(w http.ResponseWriter, r *http.Request) {
+	pos, ok := a.srv.LastPosition(pathIMEI(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no position for device")
+		return
+	}
+	writeJSON(w, http.StatusOK, pos)
+}
+
+func (a *API) sendCommand(w http.ResponseWriter, r *http.Request) {
+	var req CommandRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommandBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if req.Command == "" {
+		writeError(w, http.StatusBadRequest, "command is required")
+		return
+	}
+
+	imei := pathIMEI(r)
+	if err := a.srv.SendCommand(imei, req.ServerFlag, req.Command); err != nil {
+		writeSendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"imei":        imei,
+		"command":     req.Command,
+		"server_flag": req.ServerFlag,
+		"status":      "sent",
+	})
+}
+
+func (a *API) getCommand(w http.ResponseWriter, r *http.Request) {
+	flag, err := strconv.ParseUint(r.PathValue("flag"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid server flag")
+		return
+	}
+	status, ok := a.srv.CommandStatus(pathIMEI(r), uint32(flag))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no command with this server flag")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (a *API) listConfigs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.srv.Configs())
+}
+
+func (a *API) listOffline(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.srv.OfflineDevices())
+}
+
+func (a *API) getConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := a.srv.Config(pathIMEI(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no config for device")
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func (a *API) refreshConfig(w http.ResponseWriter, r *http.Request) {
+	imei := pathIMEI(r)
+	if err := a.srv.RefreshConfig(imei); err != nil {
+		writeSendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"imei":   imei,
+		"status": "refreshing",
+	})
+}
+
+func (a *API) startBoost(w http.ResponseWriter, r *http.Request) {
+	var req BoostRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommandBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if req.Interval <= 0 || req.Minutes <= 0 || req.Restore < 0 {
+		writeError(w, http.StatusBadRequest, "interval and minutes must be positive")
+		return
+	}
+
+	boost, err := a.srv.Boost(pathIMEI(r), req.Interval, time.Duration(req.Minutes)*time.Minute, req.Restore)
+	if errors.Is(err, ErrNoUploadInterval) {
+		writeError(w, http.StatusConflict, "upload interval to restore is unknown, set restore")
+		return
+	}
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, boost)
+}
+
+func (a *API) endBoost(w http.ResponseWriter, r *http.Request) {
+	imei := pathIMEI(r)
+	if cfg, _ := a.srv.Config(imei); cfg.Boost == nil {
+		writeError(w, http.StatusNotFound, "no boost for device")
+		return
+	}
+	if err := a.srv.EndBoost(imei); err != nil {
+		writeSendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"imei":   imei,
+		"status": "restoring",
+	})
+}
+
+func (a *API) startMigration(w http.ResponseWriter, r *http.Request) {
+	var req MigrationRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommandBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if req.BatchSize < 0 || req.Interval < 0 || req.Grace < 0 {
+		writeError(w, http.StatusBadRequest, "batch_size, interval and grace must not be negative")
+		return
+	}
+
+	m, err := a.srv.Migrate(MigrationPlan{
+		IMEIs:     req.IMEIs,
+		BatchSize: req.BatchSize,
+		Interval:  time.Duration(req.Interval) * time.Second,
+		Grace:     time.Duration(req.Grace) * time.Second,
+	})
+	switch {
+	case errors.Is(err, ErrMigrationRunning):
+		writeError(w, http.StatusConflict, "a migration is already running")
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, m)
+	}
+}
+
+func (a *API) getMigration(w http.ResponseWriter, r *http.Request) {
+	m, ok := a.srv.MigrationStatus()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no migration")
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (a *API) cancelMigration(w http.ResponseWriter, r *http.Request) {
+	if !a.srv.CancelMigration() {
+		writeError(w, http.StatusNotFound, "no migration running")
+		return
+	}
+	m, _ := a.srv.MigrationStatus()
+	writeJSON(w, http.StatusAccepted, m)
+}
+
+func (a *API) createShare(w http.ResponseWriter, r *http.Request) {
+	var req ShareRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommandBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if req.Minutes <= 0 || req.Minutes > maxShareMinutes {
+		writeError(w, http.StatusBadRequest, "minutes must be between 1 and 10080")
+		return
+	}
+
+	imei := pathIMEI(r)
+	expires := time.Now().Add(time.Duration(req.Minutes) * time.Minute).Truncate(time.Second)
+	token := a.shares.Sign(imei, expires)
+	writeJSON(w, http.StatusCreated, ShareLink{
+		IMEI:      imei,
+		Token:     token,
+		Path:      "/api/shared/" + token,
+		ExpiresAt: expires,
+	})
+}
+
+func (a *API) getShared(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	imei, _, err := a.shares.Verify(r.PathValue("token"), time.Now())
+	switch {
+	case errors.Is(err, ErrShareTokenExpired):
+		writeError(w, http.StatusGone, "share link expired")
+		return
+	case err != nil:
+		writeError(w, http.StatusForbidden, "invalid share link")
+		return
+	}
+
+	pos, ok := a.srv.LastPosition(imei)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no position for device")
+		return
+	}
+	writeJSON(w, http.StatusOK, pos)
+}
+
+func (a *API) getQuarantine(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.quarantine.Report())
+}
+
+func (a *API) listParsers(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, a.parses.Slowest(limit))
+}
+
+func (a *API) listUploadModes(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("suspect") != "" {
+		writeJSON(w, http.StatusOK, a.uploads.Suspects(uploads.DefaultMinUploads, uploads.DefaultSuspectShare))
+		return
+	}
+	writeJSON(w, http.StatusOK, a.uploads.Devices())
+}
+
+func (a *API) listStates(w http.ResponseWriter, r *http.Request) {
+	states, err := a.state.All(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (a *API) getState(w http.ResponseWriter, r *http.Request) {
+	st, found, err := a.state.Get(r.Context(), pathIMEI(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "no state for device")
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (a *API) listDriving(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.driving.Scores(time.Now()))
+}
+
+func (a *API) getDriving(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.driving.Score(pathIMEI(r), time.Now()))
+}
+
+func (a *API) listGroups(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	names := a.groups.Names()
+	out := make([]FleetSummary, 0, len(names))
+	for _, name := range names {
+		imeis, _ := a.groups.Members(name)
+		sum := a.srv.Summarize(imeis, now)
+		sum.Group = name
+		out = append(out, sum)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *API) getGroup(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("group")
+	imeis, ok := a.groups.Members(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown group")
+		return
+	}
+	sum := a.srv.Summarize(imeis, time.Now())
+	sum.Group, sum.IMEIs = name, imeis
+	writeJSON(w, http.StatusOK, sum)
+}
+
+func (a *API) streamDevices(w http.ResponseWriter, r *http.Request) {
+	a.stream(w, r, imeiFilter(r))
+}
+
+func (a *API) listEvents(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultEventPage
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	page, err := a.srv.Events(r.URL.Query().Get("after"), imeiFilter(r), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// pathIMEI returns the canonical device ID of the imei path value, so a
+// device is found however the client pads its ID
+func pathIMEI(r *http.Request) string {
+	return canonicalID(r.PathValue("imei"))
+}
+
+// imeiFilter matches the events of the devices in the imei parameter
+// (a comma-separated list), or all events without it
+func imeiFilter(r *http.Request) func(Event) bool {
+	list := r.URL.Query().Get("imei")
+	if list == "" {
+		return nil
+	}
+	imeis := make(map[string]bool)
+	for imei := range strings.SplitSeq(list, ",") {
+		imeis[canonicalID(imei)] = true
+	}
+	return func(e Event) bool { return imeis[e.IMEI] }
+}
+
+func (a *API) streamGroup(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("group")
+	if _, ok := a.groups.Members(name); !ok {
+		writeError(w, http.StatusNotFound, "unknown group")
+		return
+	}
+	// Membership is checked per event, so catalog changes apply to open streams
+	a.stream(w, r, func(e Event) bool { return a.groups.Contains(name, e.IMEI) })
+}
+
+// stream sends the events matching filter until the client disconnects, as
+// JSON text frames over a WebSocket or, where WebSockets are blocked, as
+// server-sent events. Both resume after the event ID of the Last-Event-ID
+// header or the after parameter.
+func (a *API) stream(w http.ResponseWriter, r *http.Request, filter func(Event) bool) {
+	cursor := r.Header.Get("Last-Event-ID")
+	if after := r.URL.Query().Get("after"); after != "" {
+		cursor = after
+	}
+	var after uint64
+	if cursor != "" {
+		var err error
+		if after, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid event ID")
+			return
+		}
+	}
+	interval := a.interval
+	if v := r.URL.Query().Get("every"); v != "" {
+		every, err := time.ParseDuration(v)
+		if err != nil || every < 0 {
+			writeError(w, http.StatusBadRequest, "invalid every")
+			return
+		}
+		interval = max(interval, every)
+	}
+	if interval > 0 {
+		filter = allOf(filter, a.downsample(interval))
+	}
+
+	switch {
+	case headerContains(r.Header, "Upgrade", "websocket"):
+		a.streamWebSocket(w, r, a.subscribe(cursor != "", after, filter))
+	case headerContains(r.Header, "Accept", "text/event-stream"):
+		a.streamSSE(w, r, a.subscribe(cursor != "", after, filter))
+	default:
+		writeError(w, http.StatusUpgradeRequired, "websocket upgrade or Accept: text/event-stream required")
+	}
+}
+
+// subscribe subscribes to the feed, from after if resume is set
+func (a *API) subscribe(resume bool, after uint64, filter func(Event) bool) *Subscription {
+	if resume {
+		return a.srv.SubscribeFrom(after, filter, DefaultStreamBuffer)
+	}
+	return a.srv.Subscribe(filter, DefaultStreamBuffer)
+}
+
+func (a *API) streamWebSocket(w http.ResponseWriter, r *http.Request, sub *Subscription) {
+	defer sub.Close()
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(done)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				conn.writeFrame(wsClose, []byte{0x03, 0xE9}) // 1001 going away
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			if conn.writeFrame(wsText, data) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.writeFrame(wsPing, nil) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (a *API) streamSSE(w http.ResponseWriter, r *http.Request, sub *Subscription) {
+	defer sub.Close()
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if rc.Flush() != nil {
+		return
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.ID, data)
+		case <-ping.C:
+			io.WriteString(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeJSON writes v with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes {"error": msg}
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeSendError writes the error of sending to a device
+func writeSendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownDevice):
+		writeError(w, http.StatusNotFound, "device not connected")
+	case errors.Is(err, ErrPassive):
+		writeError(w, http.StatusConflict, "server is passive")
+	default:
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+// geoVerdict returns the verdict, or nil when no lookup was made
+func geoVerdict(v GeoVerdict) *GeoVerdict {
+	if v == (GeoVerdict{}) {
+		return nil
+	}
+	return &v
+}
+
+// identityName returns the identity as a string, or "" when anonymous
+func identityName(id Identity) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.String()
+}
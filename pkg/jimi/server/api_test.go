@@ -0,0 +1,351 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/driving"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/state"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/uploads"
+)
+
+// call performs a request against h and decodes the JSON response
+func call(t *testing.T, h http.Handler, method, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAPI(t *testing.T) {
+	srv, addr, events := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	next(t, events, "location")
+
+	api := NewAPI(srv)
+
+	var sessions []SessionInfo
+	if code := call(t, api, "GET", "/api/sessions", "", &sessions); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(sessions) != 1 || sessions[0].IMEI != testIMEI || sessions[0].Packets != 2 {
+		t.Errorf("Unexpected sessions %+v", sessions)
+	}
+
+	if code := call(t, api, "GET", "/api/sessions/000000000000000", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", code)
+	}
+
+	var pos Position
+	if code := call(t, api, "GET", "/api/positions/"+testIMEI, "", &pos); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if pos.IMEI != testIMEI || pos.Latitude < 23 || pos.Latitude > 24 || pos.Time.IsZero() {
+		t.Errorf("Unexpected position %+v", pos)
+	}
+
+	// Device IDs are normalized, so a zero-padded IMEI finds the same device
+	if code := call(t, api, "GET", "/api/positions/0"+testIMEI, "", nil); code != http.StatusOK {
+		t.Errorf("Expected 200 for the zero-padded IMEI, got %d", code)
+	}
+
+	var positions []Position
+	call(t, api, "GET", "/api/positions", "", &positions)
+	if len(positions) != 1 {
+		t.Errorf("Expected 1 position, got %d", len(positions))
+	}
+
+	// Commands are written to the device connection
+	code := call(t, api, "POST", "/api/devices/"+testIMEI+"/commands", `{"command":"STATUS#","server_flag":7}`, nil)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if got, want := readTCP(t, conn), encoder.New().OnlineCommand(1, 7, "STATUS#"); !bytes.Equal(got, want) {
+		t.Errorf("Expected command %X, got %X", want, got)
+	}
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/devices/000000000000000/commands", `{"command":"STATUS#"}`, http.StatusNotFound},
+		{"/api/devices/" + testIMEI + "/commands", `{"command":""}`, http.StatusBadRequest},
+		{"/api/devices/" + testIMEI + "/commands", `{"cmd":"STATUS#"}`, http.StatusBadRequest},
+		{"/api/devices/" + testIMEI + "/commands", `not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := call(t, api, "POST", tt.path, tt.body, nil); code != tt.want {
+			t.Errorf("POST %s %s: expected %d, got %d", tt.path, tt.body, tt.want, code)
+		}
+	}
+
+	// Positions survive the disconnect
+	conn.Close()
+	next(t, events, "disconnect")
+	if code := call(t, api, "GET", "/api/positions/"+testIMEI, "", nil); code != http.StatusOK {
+		t.Errorf("Expected position after disconnect, got %d", code)
+	}
+}
+
+func TestAPI_Token(t *testing.T) {
+	api := NewAPI(New(), WithAPIToken("secret"))
+
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", rec.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with token, got %d", rec.Code)
+	}
+}
+
+func TestAPI_Quarantine(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/api/quarantine", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without quarantine, got %d", code)
+	}
+
+	q := jimi.NewQuarantine()
+	q.Record(0x99, []byte{0x01})
+
+	var report jimi.QuarantineReport
+	if code := call(t, NewAPI(New(), WithAPIQuarantine(q)), "GET", "/api/quarantine", "", &report); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(report.Entries) != 1 || report.Entries[0].Protocol != 0x99 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
+
+func TestAPI_Parsers(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/api/parsers", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without parse stats, got %d", code)
+	}
+
+	stats := jimi.NewParseStats()
+	stats.Record(0x13, "Heartbeat", time.Microsecond, false, nil)
+	stats.Record(0x22, "Location", time.Millisecond, false, nil)
+	api := NewAPI(New(), WithAPIParseStats(stats))
+
+	var got []jimi.ProtocolParseStats
+	if code := call(t, api, "GET", "/api/parsers?limit=1", "", &got); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(got) != 1 || got[0].Protocol != 0x22 || got[0].Max != time.Millisecond {
+		t.Errorf("Expected the location parser, got %+v", got)
+	}
+	if code := call(t, api, "GET", "/api/parsers?limit=x", "", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", code)
+	}
+}
+
+func TestAPI_Metrics(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/metrics", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without metrics, got %d", code)
+	}
+
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jimi_resyncs_total 0\n"))
+	})
+	rec := httptest.NewRecorder()
+	NewAPI(New(), WithAPIMetrics(metrics)).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "jimi_resyncs_total 0\n" {
+		t.Errorf("Unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPI_UploadModes(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/api/upload-modes", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without upload stats, got %d", code)
+	}
+
+	stats := uploads.NewStats()
+	for range uploads.DefaultMinUploads {
+		stats.Observe(testIMEI, &packet.LocationPacket{UploadMode: protocol.UploadModeTurningPoint})
+	}
+	stats.Observe("359339073930524", &packet.LocationPacket{UploadMode: protocol.UploadModeInterval})
+	api := NewAPI(New(), WithAPIUploads(stats))
+
+	var all []uploads.DeviceStats
+	if code := call(t, api, "GET", "/api/upload-modes", "", &all); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 devices, got %+v", all)
+	}
+
+	var suspects []uploads.DeviceStats
+	call(t, api, "GET", "/api/upload-modes?suspect=1", "", &suspects)
+	if len(suspects) != 1 || suspects[0].IMEI != testIMEI || suspects[0].Counts[uploads.LabelTurningPoint] != uploads.DefaultMinUploads {
+		t.Errorf("Unexpected suspects %+v", suspects)
+	}
+}
+
+func TestAPI_State(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/api/state", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without state tracker, got %d", code)
+	}
+
+	tracker := state.NewTracker(store.NewDeviceStore(store.NewMemory(), state.DefaultNamespace))
+	hb := &packet.HeartbeatPacket{VoltageLevel: protocol.VoltageLevel(4), GSMSignal: protocol.GSMSignalStrength(3)}
+	tracker.Observe(context.Background(), testIMEI, hb)
+	api := NewAPI(New(), WithAPIState(tracker))
+
+	var st state.DeviceState
+	if code := call(t, api, "GET", "/api/state/"+testIMEI, "", &st); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if st.IMEI != testIMEI || st.Status == nil || st.Status.VoltageLevel != 4 || st.LastHeartbeat.IsZero() {
+		t.Errorf("Unexpected state %+v", st)
+	}
+
+	var all []state.DeviceState
+	call(t, api, "GET", "/api/state", "", &all)
+	if len(all) != 1 {
+		t.Errorf("Expected 1 state, got %d", len(all))
+	}
+
+	if code := call(t, api, "GET", "/api/state/000000000000000", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown device, got %d", code)
+	}
+}
+
+func TestAPI_Driving(t *testing.T) {
+	if code := call(t, NewAPI(New()), "GET", "/api/driving", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without driver scorer, got %d", code)
+	}
+
+	scorer := driving.NewScorer()
+	now := types.NewDateTime(time.Now().Add(-time.Hour))
+	scorer.Observe(testIMEI, packet.NewAlarmPacket(now, types.Coordinates{}, protocol.AlarmHarshBraking))
+	scorer.Observe("359339073930524", packet.NewAlarmPacket(now, types.Coordinates{}, protocol.AlarmCollision))
+	api := NewAPI(New(), WithAPIDriving(scorer))
+
+	var all []driving.Score
+	if code := call(t, api, "GET", "/api/driving", "", &all); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(all) != 2 || all[0].IMEI != "359339073930524" || all[1].Score != 97 {
+		t.Errorf("Unexpected scores %+v", all)
+	}
+
+	var one driving.Score
+	call(t, api, "GET", "/api/driving/"+testIMEI, "", &one)
+	if one.IMEI != testIMEI || one.Counts[driving.KindBraking] != 1 {
+		t.Errorf("Unexpected score %+v", one)
+	}
+}
+
+func TestAPI_Config(t *testing.T) {
+	srv, addr, events := startServer(t)
+	srv.OnPacket(func(s *Session, p packet.Packet) {
+		if p.ProtocolNumber() != protocol.ProtocolLogin {
+			events <- event{"packet", s, p}
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+
+	api := NewAPI(srv)
+	if code := call(t, api, "GET", "/api/devices/"+testIMEI+"/config", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 before any config, got %d", code)
+	}
+
+	enc := encoder.New()
+	syncPkt, err := enc.InfoTransfer(&packet.InfoTransferPacket{
+		SubProtocol: protocol.InfoTypeTerminalSync,
+		Data:        []byte("ALM1=CC;STA1=C0;SOS=945538609,,;CENTER=+51974867548;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(syncPkt)
+	next(t, events, "packet")
+
+	// Refreshing sends PARAM# and VERSION#
+	if code := call(t, api, "POST", "/api/devices/"+testIMEI+"/config", "", nil); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	want := append(enc.OnlineCommand(1, configParamsFlag, "PARAM#"), enc.OnlineCommand(2, configVersionFlag, "VERSION#")...)
+	var got []byte
+	for len(got) < len(want) {
+		got = append(got, readTCP(t, conn)...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected commands %X, got %X", want, got)
+	}
+
+	// Responses are matched by server flag; unknown flags are ignored
+	for _, resp := range []*packet.CommandResponsePacket{
+		packet.NewCommandResponsePacket(configVersionFlag, "[VERSION]VL103M_20240101"),
+		packet.NewCommandResponsePacket(configParamsFlag, "TIMER:10,180;SENDS:5"),
+		packet.NewCommandResponsePacket(99, "OK"),
+	} {
+		conn.Write(enc.CommandResponse(resp))
+		next(t, events, "packet")
+	}
+
+	var cfg ConfigSnapshot
+	if code := call(t, api, "GET", "/api/devices/"+testIMEI+"/config", "", &cfg); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if cfg.IMEI != testIMEI || cfg.Params != "TIMER:10,180;SENDS:5" || cfg.Version != "[VERSION]VL103M_20240101" || cfg.VersionAt.IsZero() {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+	if cfg.TerminalSync == nil || cfg.TerminalSync.CenterNumber != "+51974867548" {
+		t.Errorf("Unexpected terminal sync %+v", cfg.TerminalSync)
+	}
+
+	var configs []ConfigSnapshot
+	call(t, api, "GET", "/api/configs", "", &configs)
+	if len(configs) != 1 {
+		t.Errorf("Expected 1 config, got %d", len(configs))
+	}
+
+	if code := call(t, api, "POST", "/api/devices/000000000000000/config", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown device, got %d", code)
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrLoginRejected is reported when a login's IMEI is not allowed for the
+// identity of its connection (see DeviceAuth)
+var ErrLoginRejected = errors.New("server: login rejected")
+
+// AuthMethod tells how a connection authenticated
+type AuthMethod string
+
+const (
+	// AuthNone is a connection without an authenticated identity
+	AuthNone AuthMethod = ""
+
+	// AuthTLS is a TLS connection with a verified client certificate
+	AuthTLS AuthMethod = "tls"
+
+	// AuthPSK is a connection authenticated with a pre-shared key
+	AuthPSK AuthMethod = "psk"
+)
+
+// Identity is the authenticated identity of a TCP connection.
+// The zero Identity is an anonymous connection.
+type Identity struct {
+	// Method is how the peer authenticated
+	Method AuthMethod
+
+	// Name is the client certificate's common name or the PSK identity
+	Name string
+}
+
+// IsZero reports whether the identity is anonymous
+func (i Identity) IsZero() bool {
+	return i == Identity{}
+}
+
+// String returns "method:name", or "anonymous"
+func (i Identity) String() string {
+	if i.IsZero() {
+		return "anonymous"
+	}
+	return string(i.Method) + ":" + i.Name
+}
+
+// PSKConn is implemented by connections authenticated with a pre-shared key.
+// crypto/tls has no PSK cipher suites, so gateways terminating TLS-PSK (or a
+// similar scheme) wrap their connections in a type with this method.
+type PSKConn interface {
+	PSKIdentity() string
+}
+
+// IdentityFunc resolves the identity of a new TCP connection.
+// An error closes the connection before OnConnect.
+type IdentityFunc func(ctx context.Context, conn net.Conn) (Identity, error)
+
+// ConnIdentity is the default IdentityFunc.
+// TLS connections are handshaken and identified by the common name of the
+// verified client certificate (anonymous without one); PSKConn connections by
+// their PSK identity. Other connections are anonymous.
+func ConnIdentity(ctx context.Context, conn net.Conn) (Identity, error) {
+	switch c := conn.(type) {
+	case PSKConn:
+		return Identity{Method: AuthPSK, Name: c.PSKIdentity()}, nil
+	case *tls.Conn:
+		if err := c.HandshakeContext(ctx); err != nil {
+			return Identity{}, fmt.Errorf("tls handshake: %w", err)
+		}
+		state := c.ConnectionState()
+		if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+			return Identity{}, nil
+		}
+		return Identity{Method: AuthTLS, Name: state.PeerCertificates[0].Subject.CommonName}, nil
+	}
+	return Identity{}, nil
+}
+
+// DeviceAuth maps connection identities to the IMEIs they may log in as.
+// Logins with any other IMEI are rejected: no response is sent, the error is
+// reported as ErrLoginRejected and the connection is closed. Packets of a
+// connection that has not logged in successfully are dropped.
+//
+// Anonymous connections (plain TCP, UDP) are rejected unless allowed with
+// the zero Identity.
+//
+// Example usage:
+//
+//	auth := server.NewDeviceAuth()
+//	auth.Allow(server.Identity{Method: server.AuthTLS, Name: "gateway-eu"}, "359339073930520")
+//	auth.AllowAny(server.Identity{Method: server.AuthPSK, Name: "lab"})
+//	srv := server.New(server.WithDeviceAuth(auth), server.WithTLSConfig(tlsConfig))
+//	srv.ListenAndServe(":5023")
+type DeviceAuth struct {
+	mu      sync.RWMutex
+	allowed map[Identity]map[string]bool
+	anyIMEI map[Identity]bool
+}
+
+// NewDeviceAuth creates a DeviceAuth that allows nothing
+func NewDeviceAuth() *DeviceAuth {
+	return &DeviceAuth{
+		allowed: make(map[Identity]map[string]bool),
+		anyIMEI: make(map[Identity]bool),
+	}
+}
+
+// Allow lets id log in as the given IMEIs (added to any already allowed)
+func (a *DeviceAuth) Allow(id Identity, imeis ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	set := a.allowed[id]
+	if set == nil {
+		set = make(map[string]bool, len(imeis))
+		a.allowed[id] = set
+	}
+	for _, imei := range imeis {
+		set[canonicalID(imei)] = true
+	}
+}
+
+// AllowAny lets id log in as any IMEI
+func (a *DeviceAuth) AllowAny(id Identity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.anyIMEI[id] = true
+}
+
+// Revoke removes everything allowed for id.
+// Connections already logged in are not closed.
+func (a *DeviceAuth) Revoke(id Identity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.allowed, id)
+	delete(a.anyIMEI, id)
+}
+
+// Authorize returns an error wrapping ErrLoginRejected unless id may log in as imei
+func (a *DeviceAuth) Authorize(id Identity, imei string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.anyIMEI[id] || a.allowed[id][canonicalID(imei)] {
+		return nil
+	}
+	return fmt.Errorf("%w: IMEI %s is not allowed for %s", ErrLoginRejected, imei, id)
+}
+
+// ParseDeviceAuth reads a DeviceAuth from a text file with one identity per line:
+//
+//	# method:name  IMEIs (or * for any)
+//	tls:gateway-eu 359339073930520 359339073930538
+//	psk:lab        *
+//	anonymous      868120303960873
+//
+// Blank lines and lines starting with '#' are ignored.
+func ParseDeviceAuth(r io.Reader) (*DeviceAuth, error) {
+	a := NewDeviceAuth()
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		id, err := parseIdentity(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("line %d: no IMEIs for %s", line, id)
+		}
+		for _, imei := range fields[1:] {
+			if imei == "*" {
+				a.AllowAny(id)
+			} else {
+				a.Allow(id, imei)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parseIdentity parses "method:name" or "anonymous"
+func parseIdentity(s string) (Identity, error) {
+	if s == "anonymous" {
+		return Identity{}, nil
+	}
+	method, name, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return Identity{}, fmt.Errorf("invalid identity %q (want method:name)", s)
+	}
+	switch m := AuthMethod(method); m {
+	case AuthTLS, AuthPSK:
+		return Identity{Method: m, Name: name}, nil
+	}
+	return Identity{}, fmt.Errorf("unknown auth method %q", method)
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+func TestDeviceAuth_Authorize(t *testing.T) {
+	gateway := Identity{Method: AuthTLS, Name: "gateway"}
+	lab := Identity{Method: AuthPSK, Name: "lab"}
+
+	auth := NewDeviceAuth()
+	auth.Allow(gateway, testIMEI)
+	auth.AllowAny(lab)
+
+	tests := []struct {
+		name  string
+		id    Identity
+		imei  string
+		allow bool
+	}{
+		{"allowed IMEI", gateway, testIMEI, true},
+		{"other IMEI", gateway, "868120303960873", false},
+		{"any IMEI", lab, "868120303960873", true},
+		{"same name, other method", Identity{Method: AuthPSK, Name: "gateway"}, testIMEI, false},
+		{"anonymous", Identity{}, testIMEI, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.Authorize(tt.id, tt.imei)
+			if tt.allow && err != nil {
+				t.Errorf("Expected login to be allowed, got %v", err)
+			}
+			if !tt.allow && !errors.Is(err, ErrLoginRejected) {
+				t.Errorf("Expected ErrLoginRejected, got %v", err)
+			}
+		})
+	}
+
+	auth.Revoke(lab)
+	if err := auth.Authorize(lab, testIMEI); err == nil {
+		t.Error("Expected revoked identity to be rejected")
+	}
+}
+
+func TestParseDeviceAuth(t *testing.T) {
+	auth, err := ParseDeviceAuth(strings.NewReader(`
+# gateways
+tls:gateway-eu 359339073930520 868120303960873
+psk:lab        *
+anonymous      359339073930538
+`))
+	if err != nil {
+		t.Fatalf("ParseDeviceAuth failed: %v", err)
+	}
+
+	for _, c := range []struct {
+		id   Identity
+		imei string
+	}{
+		{Identity{AuthTLS, "gateway-eu"}, "868120303960873"},
+		{Identity{AuthPSK, "lab"}, "000000000000000"},
+		{Identity{}, "359339073930538"},
+	} {
+		if err := auth.Authorize(c.id, c.imei); err != nil {
+			t.Errorf("Expected %s to be allowed %s: %v", c.id, c.imei, err)
+		}
+	}
+
+	for _, bad := range []string{"gateway 359339073930520", "ssh:x 359339073930520", "tls:x"} {
+		if _, err := ParseDeviceAuth(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+// pskConn is a connection authenticated out of band with a PSK identity
+type pskConn struct {
+	net.Conn
+	identity string
+}
+
+func (c pskConn) PSKIdentity() string { return c.identity }
+
+// pskListener wraps accepted connections as pskConn
+type pskListener struct {
+	net.Listener
+	identity string
+}
+
+func (l pskListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return pskConn{c, l.identity}, nil
+}
+
+// startAuthServer runs a TCP server on a wrapped listener, recording logins and errors
+func startAuthServer(t *testing.T, wrap func(net.Listener) net.Listener, opts ...Option) (string, chan event, chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+
+	opts = append([]Option{WithDecoderOptions(jimi.WithSkipCRC(), jimi.WithLenientMode())}, opts...)
+	srv := New(opts...)
+
+	events := make(chan event, 32)
+	errs := make(chan error, 32)
+	srv.OnLogin(func(s *Session, p *packet.LoginPacket) { events <- event{"login", s, p} })
+	srv.OnLocation(func(s *Session, p packet.Packet) { events <- event{"location", s, p} })
+	srv.OnDisconnect(func(s *Session) { events <- event{"disconnect", s, nil} })
+	srv.OnError(func(s *Session, err error) { errs <- err })
+
+	go srv.Serve(wrap(l))
+	t.Cleanup(func() { srv.Close() })
+
+	return l.Addr().String(), events, errs
+}
+
+func TestServer_DeviceAuthPSK(t *testing.T) {
+	auth := NewDeviceAuth()
+	auth.Allow(Identity{Method: AuthPSK, Name: "gateway"}, testIMEI)
+
+	tests := []struct {
+		name     string
+		identity string
+		allow    bool
+	}{
+		{"allowed", "gateway", true},
+		{"unknown identity", "intruder", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, events, errs := startAuthServer(t, func(l net.Listener) net.Listener {
+				return pskListener{l, tt.identity}
+			}, WithDeviceAuth(auth))
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if !tt.allow {
+				// Packets before a successful login are dropped
+				conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+			}
+			conn.Write(mustHex(t, loginHex))
+
+			if tt.allow {
+				readTCP(t, conn)
+				e := next(t, events, "login")
+				if id := e.sess.Identity(); id.Method != AuthPSK || id.Name != "gateway" {
+					t.Errorf("Expected psk:gateway identity, got %s", id)
+				}
+				return
+			}
+
+			select {
+			case err := <-errs:
+				if !errors.Is(err, ErrLoginRejected) {
+					t.Errorf("Expected ErrLoginRejected, got %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timed out waiting for rejection")
+			}
+			e := next(t, events, "disconnect")
+			if e.sess.IMEI() != "" {
+				t.Errorf("Expected rejected session not to be bound, got %s", e.sess.IMEI())
+			}
+			for len(events) > 0 {
+				if e := <-events; e.kind != "disconnect" {
+					t.Errorf("Unexpected %s callback for rejected connection", e.kind)
+				}
+			}
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if n, err := conn.Read(make([]byte, 64)); err == nil {
+				t.Errorf("Expected closed connection, read %d bytes", n)
+			}
+		})
+	}
+}
+
+func TestServer_DeviceAuthTLS(t *testing.T) {
+	caCert, caKey := newCert(t, "test-ca", nil, nil)
+	clientCert, clientKey := newCert(t, "gateway", caCert, caKey)
+	serverCert, serverKey := newCert(t, "127.0.0.1", caCert, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+
+	auth := NewDeviceAuth()
+	auth.Allow(Identity{Method: AuthTLS, Name: "gateway"}, testIMEI)
+
+	addr, events, _ := startAuthServer(t, func(l net.Listener) net.Listener {
+		return tls.NewListener(l, serverConfig)
+	}, WithDeviceAuth(auth))
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}},
+		RootCAs:      pool,
+		ServerName:   "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("TLS dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+
+	e := next(t, events, "login")
+	if id := e.sess.Identity(); id != (Identity{Method: AuthTLS, Name: "gateway"}) {
+		t.Errorf("Expected tls:gateway identity, got %s", id)
+	}
+	if info := e.sess.Info(); info.Identity != "tls:gateway" {
+		t.Errorf("Expected identity in session info, got %q", info.Identity)
+	}
+}
+
+func TestServer_WithTLSConfig(t *testing.T) {
+	caCert, caKey := newCert(t, "test-ca", nil, nil)
+	serverCert, serverKey := newCert(t, "127.0.0.1", caCert, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	addr, events, _ := startAuthServer(t, func(l net.Listener) net.Listener { return l },
+		WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		}))
+
+	t.Run("tls client", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"})
+		if err != nil {
+			t.Fatalf("TLS dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		conn.Write(mustHex(t, loginHex))
+		readTCP(t, conn)
+
+		e := next(t, events, "login")
+		if id := e.sess.Identity(); id != (Identity{}) {
+			t.Errorf("Expected no identity without a client certificate, got %s", id)
+		}
+	})
+
+	t.Run("plain client", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		conn.Write(mustHex(t, loginHex))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 64)
+		if n, err := conn.Read(buf); err == nil && n > 0 && buf[0] == 0x78 {
+			t.Errorf("Expected no protocol response to a plain connection, got %X", buf[:n])
+		}
+	})
+}
+
+// newCert creates a certificate signed by parent (self-signed when nil)
+func newCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
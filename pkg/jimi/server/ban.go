@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBanned is reported when a source is banned and its connections are
+// closed
+var ErrBanned = errors.New("server: source banned")
+
+// Ban is an entry of the ban list
+type Ban struct {
+	Prefix netip.Prefix `json:"prefix"`
+
+	// Until is when the ban expires (zero until Unban)
+	Until time.Time `json:"until,omitzero"`
+
+	// Auto is set for bans made by ScanGuard.BanAfter
+	Auto bool `json:"auto"`
+}
+
+// Ban refuses the TCP connections from a source IP or network for d (0 until
+// Unban) and closes its open connections. Banning a prefix again replaces
+// its ban. The ban list applies with or without a scan guard; refused
+// connections are counted in ScanStats.Banned.
+func (s *Server) Ban(prefix netip.Prefix, d time.Duration) {
+	s.ban(prefix, d, false, fmt.Errorf("%w: %s", ErrBanned, prefix.Masked()))
+}
+
+// Unban removes the ban of a prefix, reporting whether it was banned
+func (s *Server) Unban(prefix netip.Prefix) bool {
+	prefix = prefix.Masked()
+
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	if _, ok := s.scan.bans[prefix]; !ok {
+		return false
+	}
+	s.deleteBanLocked(prefix)
+	return true
+}
+
+// Bans returns the bans in force, by prefix
+func (s *Server) Bans() []Ban {
+	now := time.Now()
+
+	s.scan.mu.Lock()
+	out := make([]Ban, 0, len(s.scan.bans))
+	for _, b := range s.scan.bans {
+		if b.Until.IsZero() || now.Before(b.Until) {
+			out = append(out, b)
+		}
+	}
+	s.scan.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if c := out[i].Prefix.Addr().Compare(out[j].Prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return out[i].Prefix.Bits() < out[j].Prefix.Bits()
+	})
+	return out
+}
+
+// ParseBanList reads a ban list with one IP or CIDR network per line:
+//
+//	# scanners
+//	203.0.113.7
+//	198.51.100.0/24
+//
+// Blank lines and lines starting with '#' are ignored.
+func ParseBanList(r io.Reader) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("line %d: expected one IP or network, got %q", line, scanner.Text())
+		}
+		prefix, err := parsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		out = append(out, prefix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parsePrefix parses a CIDR network, or an IP as a network of one address
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ban adds a ban and closes the open connections of the prefix with err
+func (s *Server) ban(prefix netip.Prefix, d time.Duration, auto bool, err error) {
+	prefix = prefix.Masked()
+	b := Ban{Prefix: prefix, Auto: auto}
+	if d > 0 {
+		b.Until = time.Now().Add(d)
+	}
+
+	s.scan.mu.Lock()
+	if s.scan.bans == nil {
+		s.scan.bans = make(map[netip.Prefix]Ban)
+	}
+	if len(s.scan.bans) >= maxScanSources {
+		s.pruneBansLocked(time.Now())
+	}
+	s.deleteBanLocked(prefix)
+	s.scan.bans[prefix] = b
+	if prefix.Bits() < prefix.Addr().BitLen() {
+		s.scan.rangeBans++
+	}
+	s.scan.mu.Unlock()
+
+	s.mu.Lock()
+	var closing []*Session
+	for sess := range s.active {
+		if sess.conn == nil {
+			continue
+		}
+		if ip, perr := parseRemoteAddr(sess.RemoteAddr()); perr == nil && prefix.Contains(ip.WithZone("")) {
+			closing = append(closing, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sess := range closing {
+		s.reject(sess, err)
+	}
+}
+
+// bannedLocked reports whether ip is banned at now, forgetting the expired
+// bans it finds. Caller must hold s.scan.mu.
+func (s *Server) bannedLocked(ip netip.Addr, now time.Time) bool {
+	if !ip.IsValid() || len(s.scan.bans) == 0 {
+		return false
+	}
+	ip = ip.WithZone("")
+	if s.activeBanLocked(netip.PrefixFrom(ip, ip.BitLen()), now) {
+		return true
+	}
+	if s.scan.rangeBans == 0 {
+		return false
+	}
+	for prefix := range s.scan.bans {
+		if prefix.Bits() < ip.BitLen() && prefix.Contains(ip) && s.activeBanLocked(prefix, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeBanLocked reports whether prefix has a ban in force, forgetting it
+// if it expired. Caller must hold s.scan.mu.
+func (s *Server) activeBanLocked(prefix netip.Prefix, now time.Time) bool {
+	b, ok := s.scan.bans[prefix]
+	if !ok {
+		return false
+	}
+	if !b.Until.IsZero() && !now.Before(b.Until) {
+		s.deleteBanLocked(prefix)
+		return false
+	}
+	return true
+}
+
+// deleteBanLocked removes the ban of a prefix. Caller must hold s.scan.mu.
+func (s *Server) deleteBanLocked(prefix netip.Prefix) {
+	if _, ok := s.scan.bans[prefix]; !ok {
+		return
+	}
+	delete(s.scan.bans, prefix)
+	if prefix.Bits() < prefix.Addr().BitLen() {
+		s.scan.rangeBans--
+	}
+}
+
+// pruneBansLocked forgets the expired bans. Caller must hold s.scan.mu.
+func (s *Server) pruneBansLocked(now time.Time) {
+	for prefix, b := range s.scan.bans {
+		if !b.Until.IsZero() && !now.Before(b.Until) {
+			s.deleteBanLocked(prefix)
+		}
+	}
+}
+
+// decodeFailed counts a failure to decode what a connection sent and bans
+// its source after ScanGuard.BanAfter consecutive failures, closing the
+// connection. It reports whether the source was banned.
+func (s *Server) decodeFailed(sess *Session) bool {
+	g := s.scanGuard
+	if g == nil || g.BanAfter <= 0 {
+		return false
+	}
+	ip, err := parseRemoteAddr(sess.RemoteAddr())
+	if err != nil {
+		return false
+	}
+	ip = ip.WithZone("")
+
+	s.scan.mu.Lock()
+	if s.scan.failures == nil || len(s.scan.failures) >= maxScanSources {
+		s.scan.failures = make(map[netip.Addr]int)
+	}
+	s.scan.failures[ip]++
+	n := s.scan.failures[ip]
+	if n >= g.BanAfter {
+		delete(s.scan.failures, ip)
+		s.scan.stats.AutoBans++
+	}
+	s.scan.mu.Unlock()
+
+	if n < g.BanAfter {
+		return false
+	}
+	s.ban(netip.PrefixFrom(ip, ip.BitLen()), g.BanDuration, true,
+		fmt.Errorf("%w: %s after %d decode failures, for %v", ErrBanned, ip, n, g.BanDuration))
+	return true
+}
+
+// decodeSucceeded resets the decode failures of the source of a connection
+func (s *Server) decodeSucceeded(sess *Session) {
+	g := s.scanGuard
+	if g == nil || g.BanAfter <= 0 {
+		return
+	}
+	ip, err := parseRemoteAddr(sess.RemoteAddr())
+	if err != nil {
+		return
+	}
+
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	delete(s.scan.failures, ip.WithZone(""))
+}
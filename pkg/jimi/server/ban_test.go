@@ -0,0 +1,181 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_ConnLimits(t *testing.T) {
+	srv := New(WithScanGuard(ScanGuard{MaxConns: 3, MaxConnsPerIP: 2}))
+	a := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1000}
+	c := &net.TCPAddr{IP: net.ParseIP("10.0.0.3"), Port: 1000}
+
+	for i, want := range []bool{true, true, false} {
+		if got := srv.allowAccept(a); got != want {
+			t.Errorf("Accept %d from a: expected %v, got %v", i, want, got)
+		}
+	}
+	if !srv.allowAccept(b) {
+		t.Error("Expected another source accepted")
+	}
+	if srv.allowAccept(c) {
+		t.Error("Expected MaxConns to refuse a third source")
+	}
+
+	srv.releaseAccept(a)
+	if !srv.allowAccept(c) {
+		t.Error("Expected a source accepted after a connection ended")
+	}
+	if got := srv.ScanStats().Overloaded; got != 2 {
+		t.Errorf("Expected 2 overloaded, got %d", got)
+	}
+}
+
+func TestServer_BanList(t *testing.T) {
+	srv := New()
+	a := &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("203.0.113.9"), Port: 1000}
+	mapped := &net.TCPAddr{IP: net.ParseIP("::ffff:198.51.100.8"), Port: 1000}
+
+	srv.Ban(netip.MustParsePrefix("198.51.100.0/24"), 0)
+	srv.Ban(netip.MustParsePrefix("203.0.113.9/32"), time.Millisecond)
+
+	if srv.allowAccept(a) || srv.allowAccept(mapped) {
+		t.Error("Expected the banned network refused")
+	}
+	if got := len(srv.Bans()); got != 2 {
+		t.Errorf("Expected 2 bans, got %d", got)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !srv.allowAccept(b) {
+		t.Error("Expected the source accepted after its ban expired")
+	}
+	if bans := srv.Bans(); len(bans) != 1 || bans[0].Prefix.String() != "198.51.100.0/24" || !bans[0].Until.IsZero() {
+		t.Errorf("Unexpected bans %+v", bans)
+	}
+
+	if !srv.Unban(netip.MustParsePrefix("198.51.100.1/24")) {
+		t.Error("Expected the network unbanned")
+	}
+	if !srv.allowAccept(a) {
+		t.Error("Expected the source accepted after Unban")
+	}
+	if got := srv.ScanStats().Banned; got != 2 {
+		t.Errorf("Expected 2 banned, got %d", got)
+	}
+}
+
+func TestServer_BanClosesConnections(t *testing.T) {
+	srv, addr, events := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	readTCP(t, conn)
+
+	srv.Ban(netip.MustParsePrefix("127.0.0.1/32"), time.Minute)
+	next(t, events, "disconnect")
+
+	again, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	again.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if n, err := again.Read(make([]byte, 64)); err == nil {
+		t.Errorf("Expected the banned source refused, read %d bytes", n)
+	}
+}
+
+func TestServer_AutoBan(t *testing.T) {
+	srv, addr, events := startServer(t, WithScanGuard(ScanGuard{CloseNonProtocol: true, BanAfter: 2}))
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+
+	send := func(data []byte) net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.Write(data)
+		return conn
+	}
+
+	// A decoded packet resets the count
+	send([]byte("GET / HTTP/1.1\r\n\r\n"))
+	next(t, events, "disconnect")
+	device := send(mustHex(t, loginHex))
+	next(t, events, "login")
+	readTCP(t, device)
+	device.Close()
+	next(t, events, "disconnect")
+	if bans := srv.Bans(); len(bans) != 0 {
+		t.Fatalf("Expected no ban yet, got %+v", bans)
+	}
+
+	send([]byte("GET / HTTP/1.1\r\n\r\n"))
+	next(t, events, "disconnect")
+	send([]byte("\x16\x03\x01\x02\x00"))
+	next(t, events, "disconnect")
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrBanned) || !strings.Contains(err.Error(), "127.0.0.1") {
+			t.Errorf("Expected ErrBanned for 127.0.0.1, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the ban reported")
+	}
+	bans := srv.Bans()
+	if len(bans) != 1 || !bans[0].Auto || time.Until(bans[0].Until) <= DefaultBanDuration-time.Minute {
+		t.Fatalf("Expected an automatic ban of DefaultBanDuration, got %+v", bans)
+	}
+
+	refused := send(mustHex(t, loginHex))
+	refused.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if n, err := refused.Read(make([]byte, 64)); err == nil {
+		t.Errorf("Expected the banned source refused, read %d bytes", n)
+	}
+	if got := srv.ScanStats(); got.AutoBans != 1 || got.Banned != 1 || got.NonProtocol != 3 {
+		t.Errorf("Unexpected stats %+v", got)
+	}
+}
+
+func TestParseBanList(t *testing.T) {
+	list := `
+# scanners
+203.0.113.7
+198.51.100.0/24
+2001:db8::/32
+`
+	prefixes, err := ParseBanList(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("ParseBanList failed: %v", err)
+	}
+	want := []string{"203.0.113.7/32", "198.51.100.0/24", "2001:db8::/32"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("Expected %d prefixes, got %v", len(want), prefixes)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("Prefix %d: expected %s, got %s", i, want[i], p)
+		}
+	}
+
+	for _, bad := range []string{"203.0.113", "10.0.0.0/33", "10.0.0.1 10.0.0.2"} {
+		if _, err := ParseBanList(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
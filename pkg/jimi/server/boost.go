@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// boostFlag is the server flag of the TIMER commands sent by Boost ("BOOS")
+const boostFlag uint32 = 0x424F4F53
+
+// ErrNoUploadInterval is returned by Boost when the interval to restore
+// afterwards is neither given nor known from an acknowledged TIMER command
+var ErrNoUploadInterval = errors.New("server: upload interval to restore is unknown")
+
+// Boost is a temporary high-frequency reporting period of a device
+type Boost struct {
+	// Interval is the upload interval during the boost, in seconds
+	Interval int `json:"interval"`
+
+	// Restore is the upload interval restored afterwards, in seconds
+	Restore int `json:"restore"`
+
+	// Until is when the boost ends
+	Until time.Time `json:"until"`
+
+	// Restoring is set once the restoring TIMER command was sent; the boost
+	// is dropped when the device acknowledges it
+	Restoring bool `json:"restoring,omitempty"`
+}
+
+// boostState is a Boost with its end timer
+type boostState struct {
+	Boost
+	timer *time.Timer
+}
+
+// WithMotionBoost boosts a device to interval seconds for d whenever it
+// raises a vibration or tow/theft alarm, so a moving vehicle is tracked
+// closely. The interval to restore must be known (see Boost).
+func WithMotionBoost(interval int, d time.Duration) Option {
+	return func(s *Server) {
+		s.motionInterval = interval
+		s.motionDuration = d
+	}
+}
+
+// Boost puts a logged-in device into high-frequency reporting: it sends
+// TIMER,<interval># now and TIMER,<restore># after d. With restore 0 the
+// last interval acknowledged by the device is restored. Boosting a boosted
+// device extends the boost and keeps the interval to restore.
+//
+// If the device is offline when the boost ends, the interval is restored on
+// its next packet. The boost is tracked in the config snapshot until the
+// device acknowledges the restore.
+func (s *Server) Boost(imei string, interval int, d time.Duration, restore int) (Boost, error) {
+	if interval <= 0 || d <= 0 || restore < 0 {
+		return Boost{}, fmt.Errorf("server: invalid boost of %ds for %s", interval, d)
+	}
+
+	s.mu.Lock()
+	if b, ok := s.boosts[imei]; ok {
+		restore = b.Restore
+	} else if restore == 0 {
+		restore = s.configs[imei].UploadInterval
+	}
+	s.mu.Unlock()
+	if restore == 0 {
+		return Boost{}, ErrNoUploadInterval
+	}
+
+	if err := s.SendCommand(imei, boostFlag, timerCommand(interval)); err != nil {
+		return Boost{}, err
+	}
+
+	b := &boostState{Boost: Boost{Interval: interval, Restore: restore, Until: time.Now().Add(d)}}
+	s.mu.Lock()
+	if old, ok := s.boosts[imei]; ok {
+		old.timer.Stop()
+	}
+	b.timer = time.AfterFunc(d, func() { s.restoreBoost(imei, b) })
+	s.boosts[imei] = b
+	boost := b.Boost
+	s.mu.Unlock()
+	return boost, nil
+}
+
+// EndBoost ends the boost of a device now and restores its interval
+func (s *Server) EndBoost(imei string) error {
+	s.mu.Lock()
+	b, ok := s.boosts[imei]
+	if ok {
+		b.timer.Stop()
+		b.Until = time.Now()
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("server: no boost for %s", imei)
+	}
+	return s.restoreBoost(imei, b)
+}
+
+// restoreBoost sends the restoring TIMER command of an ended boost
+func (s *Server) restoreBoost(imei string, b *boostState) error {
+	s.mu.Lock()
+	if s.boosts[imei] != b {
+		// Replaced or already acknowledged
+		s.mu.Unlock()
+		return nil
+	}
+	restore := b.Restore
+	s.mu.Unlock()
+
+	err := s.SendCommand(imei, boostFlag, timerCommand(restore))
+	if err == nil {
+		s.mu.Lock()
+		b.Restoring = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// checkBoost starts a motion boost or retries a pending restore after a
+// packet was answered
+func (s *Server) checkBoost(sess *Session, p packet.Packet) {
+	imei := sess.IMEI()
+	if imei == "" {
+		return
+	}
+	if _, ok := p.(*packet.LoginPacket); ok {
+		// Wait for the next packet, the device is still handshaking
+		return
+	}
+
+	s.mu.Lock()
+	b, boosted := s.boosts[imei]
+	pending := boosted && !b.Restoring && time.Now().After(b.Until)
+	s.mu.Unlock()
+
+	switch {
+	case pending:
+		if err := s.restoreBoost(imei, b); err != nil {
+			s.report(sess, err)
+		}
+	case s.motionInterval > 0 && isMotionAlarm(p):
+		if _, err := s.Boost(imei, s.motionInterval, s.motionDuration, 0); err != nil {
+			s.report(sess, fmt.Errorf("motion boost: %w", err))
+		}
+	}
+}
+
+// trackUploadInterval records an acknowledged TIMER command and drops the
+// boost it restores. Caller must hold s.mu.
+func (s *Server) trackUploadInterval(imei string, cfg *ConfigSnapshot, interval int, at time.Time) {
+	cfg.UploadInterval, cfg.UploadIntervalAt = interval, at
+	if b, ok := s.boosts[imei]; ok && b.Restoring && b.Restore == interval {
+		delete(s.boosts, imei)
+	}
+}
+
+// timerCommand returns the TIMER command setting the upload interval
+func timerCommand(interval int) string {
+	return fmt.Sprintf("TIMER,%d#", interval)
+}
+
+// parseTimerCommand returns the upload interval set by a normalized TIMER
+// command ("TIMER,10#" or "TIMER,10,300#")
+func parseTimerCommand(cmd string) (int, bool) {
+	args, ok := strings.CutPrefix(cmd, "TIMER,")
+	if !ok {
+		return 0, false
+	}
+	first, _, _ := strings.Cut(strings.TrimSuffix(args, "#"), ",")
+	interval, err := strconv.Atoi(first)
+	if err != nil || interval <= 0 {
+		return 0, false
+	}
+	return interval, true
+}
+
+// isMotionAlarm reports whether p is a vibration or tow/theft alarm
+func isMotionAlarm(p packet.Packet) bool {
+	a, ok := p.(packet.PacketWithAlarm)
+	if !ok {
+		return false
+	}
+	switch a.GetAlarmType() {
+	case protocol.AlarmVibration, protocol.AlarmTowTheft:
+		return true
+	default:
+		return false
+	}
+}
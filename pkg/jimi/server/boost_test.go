@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// loginBoosted connects and logs in a device, reporting non-login packets as events
+func loginBoosted(t *testing.T, opts ...Option) (*Server, net.Conn, chan event) {
+	t.Helper()
+	srv, addr, events := startServer(t, opts...)
+	srv.OnPacket(func(s *Session, p packet.Packet) {
+		if p.ProtocolNumber() != protocol.ProtocolLogin {
+			events <- event{"packet", s, p}
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	return srv, conn, events
+}
+
+// expectTCP reads from conn until want has been received
+func expectTCP(t *testing.T, conn net.Conn, want []byte) {
+	t.Helper()
+	var got []byte
+	for len(got) < len(want) {
+		got = append(got, readTCP(t, conn)...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected %X, got %X", want, got)
+	}
+}
+
+func TestAPI_Boost(t *testing.T) {
+	srv, conn, events := loginBoosted(t)
+	api := NewAPI(srv)
+	enc := encoder.New()
+	path := "/api/devices/" + testIMEI + "/boost"
+
+	// Without a known interval the restore value is required
+	if code := call(t, api, "POST", path, `{"interval":10,"minutes":15}`, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 without restore, got %d", code)
+	}
+	if code := call(t, api, "POST", path, `{"interval":0,"minutes":15}`, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid interval, got %d", code)
+	}
+	if code := call(t, api, "DELETE", path, "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without boost, got %d", code)
+	}
+
+	var boost Boost
+	if code := call(t, api, "POST", path, `{"interval":10,"minutes":15,"restore":300}`, &boost); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if boost.Interval != 10 || boost.Restore != 300 || time.Until(boost.Until) < 14*time.Minute {
+		t.Errorf("Unexpected boost %+v", boost)
+	}
+	expectTCP(t, conn, enc.OnlineCommand(1, boostFlag, "TIMER,10#"))
+	conn.Write(enc.CommandResponse(packet.NewCommandResponsePacket(boostFlag, "TIMER OK")))
+	next(t, events, "packet")
+
+	var cfg ConfigSnapshot
+	call(t, api, "GET", "/api/devices/"+testIMEI+"/config", "", &cfg)
+	if cfg.UploadInterval != 10 || cfg.Boost == nil || cfg.Boost.Restoring {
+		t.Errorf("Unexpected config during boost %+v", cfg)
+	}
+
+	// Boosting again keeps the interval to restore
+	if code := call(t, api, "POST", path, `{"interval":5,"minutes":1}`, &boost); code != http.StatusAccepted || boost.Restore != 300 {
+		t.Errorf("Expected 202 with restore 300, got %d %+v", code, boost)
+	}
+	expectTCP(t, conn, enc.OnlineCommand(2, boostFlag, "TIMER,5#"))
+
+	// Ending restores the interval; the boost is kept until acknowledged
+	if code := call(t, api, "DELETE", path, "", nil); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	expectTCP(t, conn, enc.OnlineCommand(3, boostFlag, "TIMER,300#"))
+	call(t, api, "GET", "/api/devices/"+testIMEI+"/config", "", &cfg)
+	if cfg.Boost == nil || !cfg.Boost.Restoring {
+		t.Errorf("Expected a restoring boost, got %+v", cfg.Boost)
+	}
+
+	conn.Write(enc.CommandResponse(packet.NewCommandResponsePacket(boostFlag, "TIMER OK")))
+	next(t, events, "packet")
+	cfg = ConfigSnapshot{}
+	call(t, api, "GET", "/api/devices/"+testIMEI+"/config", "", &cfg)
+	if cfg.UploadInterval != 300 || cfg.Boost != nil {
+		t.Errorf("Unexpected config after boost %+v", cfg)
+	}
+}
+
+func TestServer_BoostExpires(t *testing.T) {
+	srv, conn, _ := loginBoosted(t)
+	enc := encoder.New()
+
+	if _, err := srv.Boost(testIMEI, 10, 50*time.Millisecond, 60); err != nil {
+		t.Fatal(err)
+	}
+	expectTCP(t, conn, enc.OnlineCommand(1, boostFlag, "TIMER,10#"))
+	expectTCP(t, conn, enc.OnlineCommand(2, boostFlag, "TIMER,60#"))
+
+	if _, err := srv.Boost("000000000000000", 10, time.Minute, 60); err == nil {
+		t.Error("Expected an error for an unknown device")
+	}
+}
+
+func TestServer_MotionBoost(t *testing.T) {
+	srv, conn, events := loginBoosted(t, WithMotionBoost(10, time.Minute))
+	enc := encoder.New()
+
+	coords, err := types.NewCoordinates(22.5, 113.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vibration := packet.NewAlarmPacket(types.DateTime{Time: time.Now().UTC().Truncate(time.Second)}, coords, protocol.AlarmVibration)
+
+	// The interval to restore is learned from an acknowledged TIMER command
+	if err := srv.SendCommand(testIMEI, 7, "TIMER,120#"); err != nil {
+		t.Fatal(err)
+	}
+	expectTCP(t, conn, enc.OnlineCommand(1, 7, "TIMER,120#"))
+	conn.Write(enc.CommandResponse(packet.NewCommandResponsePacket(7, "OK")))
+	next(t, events, "packet")
+
+	conn.Write(enc.Alarm(vibration))
+	next(t, events, "packet")
+	want := append(enc.AlarmResponse(0), enc.OnlineCommand(2, boostFlag, "TIMER,10#")...)
+	expectTCP(t, conn, want)
+
+	cfg, _ := srv.Config(testIMEI)
+	if cfg.Boost == nil || cfg.Boost.Interval != 10 || cfg.Boost.Restore != 120 {
+		t.Errorf("Unexpected boost %+v", cfg.Boost)
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// ErrLoginCollision is reported (as a *CollisionError) when a device logs
+// in while a session from another IP address is bound to the same IMEI,
+// e.g. a cloned device (see WithLoginCollisionPolicy)
+var ErrLoginCollision = errors.New("server: login collision")
+
+// CollisionAction is what the server does on a login collision
+type CollisionAction int
+
+const (
+	// CollisionReplace binds the IMEI to the new session, as without a
+	// policy, and only reports the collision
+	CollisionReplace CollisionAction = iota
+
+	// CollisionRejectNewest keeps the bound session and closes the new one
+	CollisionRejectNewest
+
+	// CollisionFlag binds the IMEI to the new session and flags both
+	// sessions (see Session.Collision)
+	CollisionFlag
+
+	// CollisionQuarantine closes both sessions and rejects every login of
+	// the IMEI until it is released (see Server.ReleaseIMEI)
+	CollisionQuarantine
+)
+
+// String returns the action name
+func (a CollisionAction) String() string {
+	switch a {
+	case CollisionReplace:
+		return "replace"
+	case CollisionRejectNewest:
+		return "reject-newest"
+	case CollisionFlag:
+		return "flag"
+	case CollisionQuarantine:
+		return "quarantine"
+	}
+	return fmt.Sprintf("CollisionAction(%d)", int(a))
+}
+
+// ParseCollisionAction parses an action name (see CollisionAction.String)
+func ParseCollisionAction(s string) (CollisionAction, error) {
+	for a := CollisionReplace; a <= CollisionQuarantine; a++ {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown login collision action %q", s)
+}
+
+// CollisionPolicy decides what happens when two connections from different
+// IP addresses log in with the same IMEI
+type CollisionPolicy struct {
+	Action CollisionAction
+
+	// IdleAfter treats a bound session that has been silent for longer as a
+	// connection the device dropped (e.g. after a network change), not as a
+	// clone; its IMEI is rebound without a collision. 0 treats every bound
+	// session as alive.
+	IdleAfter time.Duration
+}
+
+// CollisionError describes a login collision; it is the security event
+// reported through OnError and wraps ErrLoginCollision
+type CollisionError struct {
+	IMEI string
+
+	// Existing is the remote address of the session bound to the IMEI
+	Existing string
+
+	// New is the remote address of the colliding login
+	New string
+
+	// Action is what the server did
+	Action CollisionAction
+}
+
+// Error implements error
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("%v: IMEI %s is bound to %s, new login from %s (%s)", ErrLoginCollision, e.IMEI, e.Existing, e.New, e.Action)
+}
+
+// Unwrap returns ErrLoginCollision
+func (e *CollisionError) Unwrap() error {
+	return ErrLoginCollision
+}
+
+// WithLoginCollisionPolicy detects logins with the IMEI of a session from
+// another IP address, reports them as *CollisionError and applies the
+// policy. Without it the newest session silently wins.
+func WithLoginCollisionPolicy(p CollisionPolicy) Option {
+	return func(s *Server) {
+		s.collision = &p
+	}
+}
+
+// QuarantinedIMEIs returns the IMEIs quarantined after a login collision, sorted
+func (s *Server) QuarantinedIMEIs() []string {
+	s.mu.Lock()
+	out := make([]string, 0, len(s.quarantined))
+	for imei := range s.quarantined {
+		out = append(out, imei)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(out)
+	return out
+}
+
+// ReleaseIMEI lets a quarantined IMEI log in again
+func (s *Server) ReleaseIMEI(imei string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.quarantined, canonicalID(imei))
+}
+
+// checkCollision applies the login collision policy to a login of sess;
+// returns false if the login is rejected
+func (s *Server) checkCollision(sess *Session, imei string) bool {
+	if s.collision == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	if s.quarantined[imei] {
+		s.mu.Unlock()
+		s.reject(sess, fmt.Errorf("%w: IMEI %s is quarantined after a login collision", ErrLoginRejected, imei))
+		return false
+	}
+	prev, ok := s.sessions[imei]
+	if !ok || prev == sess || sameHost(prev.remoteAddr, sess.remoteAddr) ||
+		s.collision.IdleAfter > 0 && time.Since(prev.LastSeen()) > s.collision.IdleAfter {
+		s.mu.Unlock()
+		return true
+	}
+	action := s.collision.Action
+	if action == CollisionQuarantine {
+		s.quarantined[imei] = true
+		delete(s.sessions, imei)
+	}
+	s.mu.Unlock()
+
+	err := &CollisionError{IMEI: imei, Existing: prev.remoteAddr, New: sess.remoteAddr, Action: action}
+	switch action {
+	case CollisionRejectNewest:
+		s.reject(sess, err)
+		return false
+	case CollisionQuarantine:
+		s.reject(sess, err)
+		prev.mu.Lock()
+		prev.rejected = true
+		prev.mu.Unlock()
+		if prev.conn != nil {
+			prev.conn.Close()
+		}
+		return false
+	case CollisionFlag:
+		prev.setCollision(sess.remoteAddr)
+		sess.setCollision(prev.remoteAddr)
+	}
+	s.report(sess, err)
+	return true
+}
+
+// reject reports a rejected login and closes its TCP connection; packets
+// of the session still buffered are dropped
+func (s *Server) reject(sess *Session, err error) {
+	sess.mu.Lock()
+	sess.rejected = true
+	sess.mu.Unlock()
+
+	s.report(sess, err)
+	if sess.conn != nil {
+		sess.conn.Close()
+	}
+}
+
+// sameHost reports whether two remote addresses have the same IP
+func sameHost(a, b string) bool {
+	hostA, _, errA := net.SplitHostPort(a)
+	hostB, _, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return hostA == hostB
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// loginFrom handles a login of testIMEI from a new session at remoteAddr
+func loginFrom(t *testing.T, srv *Server, remoteAddr string) *Session {
+	t.Helper()
+	p, err := jimi.NewDecoder(jimi.WithSkipCRC()).Decode(mustHex(t, loginHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := srv.newSession("udp", remoteAddr, nil, func([]byte) error { return nil })
+	srv.handlePacket(sess, p)
+	return sess
+}
+
+func TestServer_LoginCollision(t *testing.T) {
+	tests := []struct {
+		action      CollisionAction
+		wantBound   string // remote address bound to the IMEI, "" for none
+		wantFlagged bool
+		wantBlocked bool
+	}{
+		{CollisionReplace, "10.0.0.2:7000", false, false},
+		{CollisionRejectNewest, "10.0.0.1:7001", false, false},
+		{CollisionFlag, "10.0.0.2:7000", true, false},
+		{CollisionQuarantine, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action.String(), func(t *testing.T) {
+			srv := New(WithLoginCollisionPolicy(CollisionPolicy{Action: tt.action}))
+			var reported []error
+			srv.OnError(func(_ *Session, err error) { reported = append(reported, err) })
+
+			first := loginFrom(t, srv, "10.0.0.1:7000")
+			loginFrom(t, srv, "10.0.0.1:7001") // reconnect from the same host
+			if len(reported) != 0 {
+				t.Fatalf("Expected no collision from the same host, got %v", reported)
+			}
+			second := loginFrom(t, srv, "10.0.0.2:7000")
+
+			var ce *CollisionError
+			if len(reported) != 1 || !errors.As(reported[0], &ce) || !errors.Is(ce, ErrLoginCollision) {
+				t.Fatalf("Expected one collision error, got %v", reported)
+			}
+			if ce.IMEI != testIMEI || ce.Existing != "10.0.0.1:7001" || ce.New != "10.0.0.2:7000" || ce.Action != tt.action {
+				t.Errorf("Unexpected collision %+v", ce)
+			}
+
+			bound := ""
+			if sess, ok := srv.Session(testIMEI); ok {
+				bound = sess.RemoteAddr()
+			}
+			if bound != tt.wantBound {
+				t.Errorf("Expected %q bound, got %q", tt.wantBound, bound)
+			}
+			if flagged := second.Collision() == "10.0.0.1:7001"; flagged != tt.wantFlagged {
+				t.Errorf("Expected flagged=%v, got collision %q", tt.wantFlagged, second.Collision())
+			}
+			if first.Collision() != "" {
+				t.Errorf("Expected the replaced session unflagged, got %q", first.Collision())
+			}
+
+			blocked := slices.Equal(srv.QuarantinedIMEIs(), []string{testIMEI})
+			if blocked != tt.wantBlocked {
+				t.Fatalf("Expected quarantined=%v, got %v", tt.wantBlocked, srv.QuarantinedIMEIs())
+			}
+			if !blocked {
+				return
+			}
+			loginFrom(t, srv, "10.0.0.1:7002")
+			if _, ok := srv.Session(testIMEI); ok || !errors.Is(reported[len(reported)-1], ErrLoginRejected) {
+				t.Errorf("Expected the quarantined IMEI rejected, got %v", reported[len(reported)-1])
+			}
+			srv.ReleaseIMEI(testIMEI)
+			loginFrom(t, srv, "10.0.0.1:7003")
+			if _, ok := srv.Session(testIMEI); !ok {
+				t.Error("Expected the released IMEI to log in")
+			}
+		})
+	}
+}
+
+func TestServer_LoginCollisionIdle(t *testing.T) {
+	srv := New(WithLoginCollisionPolicy(CollisionPolicy{Action: CollisionRejectNewest, IdleAfter: time.Minute}))
+	var reported []error
+	srv.OnError(func(_ *Session, err error) { reported = append(reported, err) })
+
+	first := loginFrom(t, srv, "10.0.0.1:7000")
+	first.mu.Lock()
+	first.lastSeen = time.Now().Add(-2 * time.Minute)
+	first.mu.Unlock()
+
+	// The device moved networks; the silent session is not a clone
+	loginFrom(t, srv, "10.0.0.2:7000")
+	if sess, _ := srv.Session(testIMEI); len(reported) != 0 || sess.RemoteAddr() != "10.0.0.2:7000" {
+		t.Errorf("Expected the new session bound without collision, got %v", reported)
+	}
+}
+
+func TestServer_LoginCollisionDropsPackets(t *testing.T) {
+	srv := New(WithLoginCollisionPolicy(CollisionPolicy{Action: CollisionRejectNewest}))
+	var handled int
+	srv.OnPacket(func(*Session, packet.Packet) { handled++ })
+
+	loginFrom(t, srv, "10.0.0.1:7000")
+	rejected := loginFrom(t, srv, "10.0.0.2:7000")
+	p, err := jimi.NewDecoder(jimi.WithSkipCRC()).Decode(mustHex(t, heartbeatHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.handlePacket(rejected, p)
+	if handled != 1 {
+		t.Errorf("Expected only the first login handled, got %d packets", handled)
+	}
+}
+
+func TestParseCollisionAction(t *testing.T) {
+	for a := CollisionReplace; a <= CollisionQuarantine; a++ {
+		if got, err := ParseCollisionAction(a.String()); err != nil || got != a {
+			t.Errorf("ParseCollisionAction(%q) = %v, %v", a.String(), got, err)
+		}
+	}
+	if _, err := ParseCollisionAction("ignore"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
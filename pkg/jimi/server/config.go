@@ -0,0 +1,146 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Server flags of the commands sent by RefreshConfig ("PARA" and "VERS")
+const (
+	configParamsFlag  uint32 = 0x50415241
+	configVersionFlag uint32 = 0x56455253
+)
+
+// maxPendingCommands bounds the commands awaiting a response per session
+const maxPendingCommands = 32
+
+// ConfigSnapshot is the latest known configuration of a device, assembled
+// from its terminal sync upload (0x94/0x04), its responses to PARAM#,
+// VERSION# and TIMER commands, and its current Boost. Each part is kept until
+// a newer one arrives.
+type ConfigSnapshot struct {
+	IMEI string `json:"imei"`
+
+	// TerminalSync is the last terminal sync upload
+	TerminalSync   *packet.TerminalSyncData `json:"terminal_sync,omitempty"`
+	TerminalSyncAt time.Time                `json:"terminal_sync_at,omitzero"`
+
+	// Params is the last PARAM# response
+	Params   string    `json:"params,omitempty"`
+	ParamsAt time.Time `json:"params_at,omitzero"`
+
+	// Version is the last VERSION# response
+	Version   string    `json:"version,omitempty"`
+	VersionAt time.Time `json:"version_at,omitzero"`
+
+	// UploadInterval is the interval (seconds) of the last acknowledged TIMER command
+	UploadInterval   int       `json:"upload_interval,omitempty"`
+	UploadIntervalAt time.Time `json:"upload_interval_at,omitzero"`
+
+	// Boost is the current boost, until the device acknowledges its end
+	Boost *Boost `json:"boost,omitempty"`
+}
+
+// Config returns the configuration snapshot of a device.
+// Snapshots are kept after the device disconnects.
+func (s *Server) Config(imei string) (ConfigSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg, ok := s.configs[imei]
+	if b, boosted := s.boosts[imei]; boosted {
+		boost := b.Boost
+		cfg.IMEI, cfg.Boost, ok = imei, &boost, true
+	}
+	return cfg, ok
+}
+
+// Configs returns the configuration snapshot of every device, sorted by IMEI
+func (s *Server) Configs() []ConfigSnapshot {
+	s.mu.Lock()
+	out := make([]ConfigSnapshot, 0, len(s.configs))
+	for imei, cfg := range s.configs {
+		if b, boosted := s.boosts[imei]; boosted {
+			boost := b.Boost
+			cfg.Boost = &boost
+		}
+		out = append(out, cfg)
+	}
+	for imei, b := range s.boosts {
+		if _, ok := s.configs[imei]; !ok {
+			boost := b.Boost
+			out = append(out, ConfigSnapshot{IMEI: imei, Boost: &boost})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].IMEI < out[j].IMEI })
+	return out
+}
+
+// RefreshConfig sends PARAM# and VERSION# to a logged-in device.
+// The snapshot is updated when the responses arrive.
+func (s *Server) RefreshConfig(imei string) error {
+	if err := s.SendCommand(imei, configParamsFlag, encoder.CmdGetParam); err != nil {
+		return err
+	}
+	return s.SendCommand(imei, configVersionFlag, encoder.CmdGetVersion)
+}
+
+// trackConfig records the configuration parts carried by p for a logged-in device
+func (s *Server) trackConfig(sess *Session, p packet.Packet) {
+	imei := sess.IMEI()
+	if imei == "" {
+		return
+	}
+
+	now := time.Now()
+	var update func(*ConfigSnapshot)
+	switch v := p.(type) {
+	case *packet.InfoTransferPacket:
+		if !v.HasTerminalSync() {
+			return
+		}
+		update = func(cfg *ConfigSnapshot) {
+			cfg.TerminalSync, cfg.TerminalSyncAt = v.TerminalSync, now
+		}
+	case *packet.CommandResponsePacket:
+		cmd := normalizeCommand(sess.takePending(v.ServerFlag))
+		if interval, ok := parseTimerCommand(cmd); ok {
+			update = func(cfg *ConfigSnapshot) {
+				s.trackUploadInterval(imei, cfg, interval, now)
+			}
+			break
+		}
+		switch cmd {
+		case encoder.CmdGetParam:
+			update = func(cfg *ConfigSnapshot) {
+				cfg.Params, cfg.ParamsAt = v.Response, now
+			}
+		case encoder.CmdGetVersion:
+			update = func(cfg *ConfigSnapshot) {
+				cfg.Version, cfg.VersionAt = v.Response, now
+			}
+		default:
+			return
+		}
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	cfg := s.configs[imei]
+	cfg.IMEI = imei
+	update(&cfg)
+	s.configs[imei] = cfg
+	s.mu.Unlock()
+	s.persistConfig(sess, imei)
+}
+
+// normalizeCommand returns a command in the form of the encoder constants
+func normalizeCommand(cmd string) string {
+	return strings.ToUpper(strings.TrimSpace(cmd))
+}
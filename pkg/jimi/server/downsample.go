@@ -0,0 +1,70 @@
+package server
+
+import (
+	"time"
+)
+
+// Downsampler returns a new filter thinning the feed of one client to about
+// one position per device per interval. The filter keeps per-device state:
+// a new one is made per client, and it runs serialized, after the client's
+// own filter. See Downsample and WithAPIDownsampler.
+type Downsampler func(interval time.Duration) func(Event) bool
+
+// Downsample is the default Downsampler: it keeps the first position of each
+// device, then the first one at least interval later, by device time (the
+// receive time for packets without one). Events without a position and
+// alarms always pass, so downsampling never hides a login or an alarm.
+//
+// Example usage:
+//
+//	sub := srv.Subscribe(server.Downsample(5*time.Second), server.DefaultStreamBuffer)
+func Downsample(interval time.Duration) func(Event) bool {
+	last := make(map[string]time.Time)
+	return func(e Event) bool {
+		if e.Position == nil || e.Fields["alarm_type"] != nil {
+			return true
+		}
+		at := eventTime(e)
+		if prev, ok := last[e.IMEI]; ok && at.After(prev.Add(-interval)) && at.Before(prev.Add(interval)) {
+			return false
+		}
+		last[e.IMEI] = at
+		return true
+	}
+}
+
+// eventTime returns the device time of an event, or its receive time
+func eventTime(e Event) time.Time {
+	switch {
+	case e.Time != nil:
+		return *e.Time
+	case e.ReceivedAt != nil:
+		return *e.ReceivedAt
+	}
+	return time.Now()
+}
+
+// allOf returns a filter matching the events matched by every filter, in
+// order; nil filters match all
+func allOf(filters ...func(Event) bool) func(Event) bool {
+	var set []func(Event) bool
+	for _, f := range filters {
+		if f != nil {
+			set = append(set, f)
+		}
+	}
+	switch len(set) {
+	case 0:
+		return nil
+	case 1:
+		return set[0]
+	}
+	return func(e Event) bool {
+		for _, f := range set {
+			if !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+func TestDownsample(t *testing.T) {
+	start := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	event := func(imei string, sec int, alarm bool) Event {
+		at := start.Add(time.Duration(sec) * time.Second)
+		rec := export.Record{IMEI: imei, Time: &at, Position: &export.Position{}}
+		if alarm {
+			rec.Fields = map[string]any{"alarm_type": "SOS"}
+		}
+		return Event{Record: rec}
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{"first position", event("a", 0, false), true},
+		{"within the interval", event("a", 4, false), false},
+		{"other device", event("b", 4, false), true},
+		{"alarm", event("a", 4, true), true},
+		{"no position", Event{Record: export.Record{IMEI: "a", Type: "Login"}}, true},
+		{"interval elapsed", event("a", 5, false), true},
+		{"reupload within the interval", event("a", 1, false), false},
+		{"older reupload", event("a", -10, false), true},
+	}
+	keep := Downsample(5 * time.Second)
+	for _, tt := range tests {
+		if got := keep(tt.event); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAPI_StreamDownsampled(t *testing.T) {
+	srv, addr, events := startServer(t)
+	hs := httptest.NewServer(NewAPI(srv, WithAPIStreamInterval(time.Second)))
+	t.Cleanup(hs.Close) // after the response bodies
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", hs.URL+path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	if resp := get("/api/stream?every=soon"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid interval, got %d", resp.StatusCode)
+	}
+
+	resp := get("/api/stream?every=1h")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	br := bufio.NewReader(resp.Body)
+	readData := func() Event {
+		t.Helper()
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "data: "); ok {
+				var e Event
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatal(err)
+				}
+				return e
+			}
+		}
+	}
+
+	// The second position of the hour is dropped, the alarm is not
+	for _, h := range []string{packets.LocationPackets[0].Hex, packets.LocationPackets[0].Hex} {
+		conn.Write(mustHex(t, h))
+		next(t, events, "location")
+	}
+	conn.Write(mustHex(t, packets.AlarmPackets[0].Hex))
+	next(t, events, "alarm")
+
+	if e := readData(); e.ID != 2 || e.Position == nil {
+		t.Errorf("Expected the first position, got %+v", e)
+	}
+	if e := readData(); e.ID != 4 || e.Fields["alarm_type"] == nil {
+		t.Errorf("Expected the alarm, got %+v", e)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+// DefaultPersistTimeout bounds a PersistFunc call of WithDurableAck
+const DefaultPersistTimeout = 5 * time.Second
+
+// OutboxNamespace is the store namespace of StoreOutbox
+const OutboxNamespace = "outbox"
+
+// ErrNotPersisted is reported when a packet is not acknowledged because it
+// could not be persisted (see WithDurableAck)
+var ErrNotPersisted = errors.New("server: packet not persisted, not acknowledged")
+
+// PersistFunc durably stores a packet before it is acknowledged. It must
+// return only once the packet survives a crash (e.g. a committed database
+// transaction, or a synced file).
+type PersistFunc func(ctx context.Context, sess *Session, p packet.Packet) error
+
+// WithDurableAck acknowledges logins and alarms only once persist has stored
+// them, so a crash between receiving and storing a packet loses nothing: the
+// device resends what was not acknowledged. When persist fails or exceeds
+// timeout (default DefaultPersistTimeout), the packet is not acknowledged and
+// ErrNotPersisted is reported. Acknowledgments wait for the store, and
+// resent packets reach the callbacks again, so persist should be idempotent
+// (see StoreOutbox). Locations are not acknowledged by the protocol, so this
+// mode cannot cover them.
+func WithDurableAck(persist PersistFunc, timeout time.Duration) Option {
+	return func(s *Server) {
+		if timeout <= 0 {
+			timeout = DefaultPersistTimeout
+		}
+		s.persist = persist
+		s.persistTimeout = timeout
+	}
+}
+
+// StoreOutbox returns a PersistFunc keeping packets as export.Record JSON in
+// kv under "<namespace>/<IMEI>/<protocol>-<serial>-<frame hash>" (namespace
+// defaults to OutboxNamespace), for a consumer to drain with kv.Keys and
+// kv.Delete. The key only depends on what the device sent, FNV-1a of the
+// raw frame, so a resent packet has the same key, even with a substituted
+// date-time (WithAcceptZeroTime), and replaces its first copy instead of
+// duplicating it. The durability is that of the KV backend.
+//
+// Example usage:
+//
+//	kv := store.NewBolt(boltAdapter{db, []byte("jimi")})
+//	srv := server.New(server.WithDurableAck(server.StoreOutbox(kv, ""), 0))
+func StoreOutbox(kv store.KV, namespace string) PersistFunc {
+	if namespace == "" {
+		namespace = OutboxNamespace
+	}
+	return func(ctx context.Context, sess *Session, p packet.Packet) error {
+		imei := sess.IMEI()
+		if imei == "" {
+			imei = sess.RemoteAddr()
+		}
+		rec := export.NewRecord(imei, p, time.Now())
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		h := fnv.New32a()
+		h.Write(p.Raw())
+		key := fmt.Sprintf("%s/%s/%02X-%05d-%08x", namespace, imei, p.ProtocolNumber(), p.SerialNumber(), h.Sum32())
+		return kv.Set(ctx, key, data)
+	}
+}
+
+// persisted persists a packet about to be acknowledged, reporting whether
+// the acknowledgment may be sent
+func (s *Server) persisted(sess *Session, p packet.Packet) bool {
+	if s.persist == nil || !needsDurableAck(p) {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.persistTimeout)
+	defer cancel()
+	if err := s.persist(ctx, sess, p); err != nil {
+		s.report(sess, fmt.Errorf("%w: %v", ErrNotPersisted, err))
+		return false
+	}
+	return true
+}
+
+// needsDurableAck reports whether the acknowledgment of p waits for
+// WithDurableAck: logins and alarms, which devices resend until acknowledged
+func needsDurableAck(p packet.Packet) bool {
+	switch p.ProtocolNumber() {
+	case protocol.ProtocolLogin, protocol.ProtocolAlarm, protocol.ProtocolAlarmMultiFence, protocol.ProtocolAlarmMultiFence4G:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+func TestServer_DurableAck(t *testing.T) {
+	var calls atomic.Int32
+	persist := func(ctx context.Context, sess *Session, p packet.Packet) error {
+		if calls.Add(1) == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	}
+	srv, addr, events := startServer(t, WithDurableAck(persist, 0))
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first login is not persisted, so not acknowledged
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrNotPersisted) {
+			t.Errorf("Expected ErrNotPersisted, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the persist failure reported")
+	}
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Fatalf("Expected no acknowledgment, got %d bytes", n)
+	}
+
+	// The device resends it
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	readTCP(t, conn)
+
+	// Heartbeats are acknowledged without persisting
+	conn.Write(mustHex(t, heartbeatHex))
+	readTCP(t, conn)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 persist calls, got %d", got)
+	}
+}
+
+func TestStoreOutbox(t *testing.T) {
+	kv := store.NewMemory()
+	_, addr, events := startServer(t, WithDurableAck(StoreOutbox(kv, ""), 0))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A resent login replaces its first copy
+	for range 2 {
+		conn.Write(mustHex(t, loginHex))
+		next(t, events, "login")
+		readTCP(t, conn)
+	}
+
+	ctx := context.Background()
+	keys, err := kv.Keys(ctx, OutboxNamespace+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "outbox/359339073930520/01-00001-5e202def"
+	if len(keys) != 1 || keys[0] != want {
+		t.Fatalf("Expected key %s, got %v", want, keys)
+	}
+	data, _ := kv.Get(ctx, keys[0])
+	var rec export.Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("Invalid record: %v", err)
+	}
+	if rec.IMEI != testIMEI || rec.Protocol != "0x01" {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+}
+
+func TestStoreOutbox_ZeroTimeResend(t *testing.T) {
+	// A device without a clock resends an alarm; each copy gets its own
+	// receive time, but the bytes it sent are the same
+	zero := types.DateTime{ZeroTime: true}
+	frame := encoder.New().Alarm(packet.NewAlarmPacket(zero, types.MustNewCoordinates(-33.868820, 151.209296), protocol.AlarmSOS))
+	kv := store.NewMemory()
+	persist := StoreOutbox(kv, "")
+	sess := &Session{imei: testIMEI}
+
+	for i := range 2 {
+		p, err := jimi.NewDecoder(jimi.WithAcceptZeroTime()).Decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.(*packet.AlarmPacket).DateTime = types.ReceivedDateTime(time.Now().Add(time.Duration(i) * time.Minute))
+		if err := persist(context.Background(), sess, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := kv.Keys(context.Background(), OutboxNamespace+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected the resend to replace the first copy, got %v", keys)
+	}
+}
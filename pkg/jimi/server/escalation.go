@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// DefaultCommandTimeout is how long a command waits for its response before
+// it times out or is escalated (see WithCommandEscalation)
+const DefaultCommandTimeout = time.Minute
+
+// DefaultSMSQueryTimeout bounds the wait for the reply to an SMS status query
+const DefaultSMSQueryTimeout = 2 * time.Minute
+
+// DefaultSMSQuery is the SMS text of the status query
+const DefaultSMSQuery = "STATUS#"
+
+// maxCommandStatuses bounds the command outcomes kept by a server
+const maxCommandStatuses = 1024
+
+// ErrNoCommandResponse is reported when a command got no response over the
+// connection within the command timeout, nor a reply to the SMS query
+var ErrNoCommandResponse = errors.New("server: no command response")
+
+// SMSGateway queries devices by SMS, the channel left when their data
+// connection is down. Implementations map the IMEI to the SIM number.
+type SMSGateway interface {
+	// Query sends text to the device and returns its reply, waiting until
+	// the reply arrives or ctx ends
+	Query(ctx context.Context, imei, text string) (string, error)
+}
+
+// SMSGatewayFunc adapts a function to SMSGateway
+type SMSGatewayFunc func(ctx context.Context, imei, text string) (string, error)
+
+// Query calls f
+func (f SMSGatewayFunc) Query(ctx context.Context, imei, text string) (string, error) {
+	return f(ctx, imei, text)
+}
+
+// CommandEscalation checks by SMS on devices that do not answer a command
+type CommandEscalation struct {
+	// Timeout is how long a command waits for its response over the
+	// connection (default DefaultCommandTimeout)
+	Timeout time.Duration
+
+	// Gateway sends the status query; nil only times commands out
+	Gateway SMSGateway
+
+	// Query is the text sent (default DefaultSMSQuery)
+	Query string
+
+	// QueryTimeout bounds the wait for the reply (default DefaultSMSQueryTimeout)
+	QueryTimeout time.Duration
+}
+
+// WithCommandEscalation queries a device through the SMS gateway when a
+// command gets no response within e.Timeout, and reconciles both channels
+// in the command outcome (see Server.CommandStatus): a device answering the
+// SMS but not the command is reachable while its data connection is broken.
+// Devices answering neither are reported as ErrNoCommandResponse. Without
+// this option, commands without response time out after
+// DefaultCommandTimeout without being reported.
+//
+// Example usage:
+//
+//	srv := server.New(server.WithCommandEscalation(server.CommandEscalation{
+//	    Timeout: 30 * time.Second,
+//	    Gateway: server.SMSGatewayFunc(twilioQuery),
+//	}))
+func WithCommandEscalation(e CommandEscalation) Option {
+	return func(s *Server) {
+		if e.Timeout <= 0 {
+			e.Timeout = DefaultCommandTimeout
+		}
+		if e.Query == "" {
+			e.Query = DefaultSMSQuery
+		}
+		if e.QueryTimeout <= 0 {
+			e.QueryTimeout = DefaultSMSQueryTimeout
+		}
+		s.escalation = e
+	}
+}
+
+// CommandOutcome is the delivery outcome of a command
+type CommandOutcome string
+
+// Command outcomes
+const (
+	CommandPending     CommandOutcome = "pending"     // sent, awaiting the response
+	CommandResponded   CommandOutcome = "responded"   // responded over the connection, possibly late
+	CommandQuerying    CommandOutcome = "querying"    // no response, SMS status query sent
+	CommandSMSReplied  CommandOutcome = "sms_replied" // no response, but the device replied by SMS
+	CommandTimedOut    CommandOutcome = "timed_out"   // no response, no SMS gateway
+	CommandUnreachable CommandOutcome = "unreachable" // no response, no SMS reply
+)
+
+// CommandStatus is the delivery outcome of a command sent to a device
+type CommandStatus struct {
+	IMEI        string         `json:"imei"`
+	ServerFlag  uint32         `json:"server_flag"`
+	Command     string         `json:"command"`
+	Outcome     CommandOutcome `json:"outcome"`
+	SentAt      time.Time      `json:"sent_at"`
+	Response    string         `json:"response,omitempty"`
+	RespondedAt time.Time      `json:"responded_at,omitzero"`
+
+	// SMSReply is the reply to the status query, SMSError why there is none
+	SMSReply  string    `json:"sms_reply,omitempty"`
+	SMSError  string    `json:"sms_error,omitempty"`
+	QueriedAt time.Time `json:"queried_at,omitzero"`
+}
+
+// commandKey identifies a command by device and server flag
+type commandKey struct {
+	imei string
+	flag uint32
+}
+
+// commandState is a tracked command, guarded by Server.mu
+type commandState struct {
+	CommandStatus
+	timer *time.Timer
+}
+
+// CommandStatus returns the outcome of the last command sent to a device
+// with serverFlag
+func (s *Server) CommandStatus(imei string, serverFlag uint32) (CommandStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.commands[commandKey{canonicalID(imei), serverFlag}]
+	if !ok {
+		return CommandStatus{}, false
+	}
+	return c.CommandStatus, true
+}
+
+// trackCommand starts the outcome of a command sent to a logged-in device
+func (s *Server) trackCommand(imei string, serverFlag uint32, command string) {
+	if imei == "" {
+		return
+	}
+	key := commandKey{imei, serverFlag}
+	c := &commandState{CommandStatus: CommandStatus{
+		IMEI:       imei,
+		ServerFlag: serverFlag,
+		Command:    command,
+		Outcome:    CommandPending,
+		SentAt:     time.Now(),
+	}}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	var evicted string
+	if old, ok := s.commands[key]; ok {
+		if old.timer != nil {
+			old.timer.Stop()
+		}
+	} else if len(s.commands) >= maxCommandStatuses {
+		evicted = s.evictOldestCommand()
+	}
+	if s.commands == nil {
+		s.commands = make(map[commandKey]*commandState)
+	}
+	s.commands[key] = c
+	c.timer = time.AfterFunc(s.commandTimeout(), func() { s.commandTimedOut(key, c) })
+	s.mu.Unlock()
+
+	sess, _ := s.Session(imei)
+	s.persistCommands(sess, imei)
+	if evicted != "" && evicted != imei {
+		s.persistCommands(nil, evicted)
+	}
+}
+
+// evictOldestCommand forgets the oldest command outcome and returns the IMEI
+// of its device. Caller must hold s.mu.
+func (s *Server) evictOldestCommand() string {
+	var oldest commandKey
+	var at time.Time
+	for key, c := range s.commands {
+		if at.IsZero() || c.SentAt.Before(at) {
+			oldest, at = key, c.SentAt
+		}
+	}
+	if c := s.commands[oldest]; c != nil && c.timer != nil {
+		c.timer.Stop()
+	}
+	delete(s.commands, oldest)
+	return oldest.imei
+}
+
+// commandTimeout returns the response timeout of commands
+func (s *Server) commandTimeout() time.Duration {
+	if s.escalation.Timeout > 0 {
+		return s.escalation.Timeout
+	}
+	return DefaultCommandTimeout
+}
+
+// trackCommandResponse records the response to a tracked command
+func (s *Server) trackCommandResponse(sess *Session, p packet.Packet) {
+	resp, ok := p.(*packet.CommandResponsePacket)
+	if !ok {
+		return
+	}
+	key := commandKey{sess.IMEI(), resp.ServerFlag}
+	s.mu.Lock()
+	c, ok := s.commands[key]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.Outcome = CommandResponded
+	c.Response = resp.Response
+	c.RespondedAt = time.Now()
+	s.mu.Unlock()
+	s.persistCommands(sess, key.imei)
+}
+
+// commandTimedOut escalates a command left without response
+func (s *Server) commandTimedOut(key commandKey, c *commandState) {
+	s.mu.Lock()
+	if s.commands[key] != c || c.Outcome != CommandPending {
+		s.mu.Unlock()
+		return
+	}
+	gateway, escalated := s.escalation.Gateway, s.escalation.Timeout > 0
+	if gateway == nil {
+		c.Outcome = CommandTimedOut
+		s.mu.Unlock()
+		s.persistCommands(nil, key.imei)
+		if escalated {
+			sess, _ := s.Session(key.imei)
+			s.report(sess, fmt.Errorf("%w: %s within %v", ErrNoCommandResponse, c.Command, s.commandTimeout()))
+		}
+		return
+	}
+	c.Outcome = CommandQuerying
+	c.QueriedAt = time.Now()
+	query, timeout := s.escalation.Query, s.escalation.QueryTimeout
+	s.mu.Unlock()
+	s.persistCommands(nil, key.imei)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	reply, err := gateway.Query(ctx, key.imei, query)
+	cancel()
+
+	s.mu.Lock()
+	if err != nil {
+		c.SMSError = err.Error()
+	} else {
+		c.SMSReply = reply
+	}
+	unreachable := false
+	if c.Outcome == CommandQuerying {
+		if err != nil {
+			c.Outcome = CommandUnreachable
+			unreachable = true
+		} else {
+			c.Outcome = CommandSMSReplied
+		}
+	}
+	s.mu.Unlock()
+	s.persistCommands(nil, key.imei)
+	if unreachable {
+		sess, _ := s.Session(key.imei)
+		s.report(sess, fmt.Errorf("%w: %s, SMS query failed: %v", ErrNoCommandResponse, c.Command, err))
+	}
+}
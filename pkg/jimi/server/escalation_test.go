@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// waitOutcome waits for the outcome of the command sent with flag
+func waitOutcome(t *testing.T, srv *Server, flag uint32, want CommandOutcome) CommandStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, ok := srv.CommandStatus(testIMEI, flag)
+		if ok && status.Outcome == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected outcome %s, got %+v", want, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// respond sends the device response to the command with flag
+func respond(t *testing.T, conn net.Conn, flag uint32, response string) {
+	t.Helper()
+	resp := &packet.CommandResponsePacket{ServerFlag: flag, Response: response}
+	conn.Write(encoder.New().CommandResponse(resp))
+}
+
+func TestServer_CommandEscalation(t *testing.T) {
+	replies := map[string]error{"STATUS#": nil}
+	var queried []string
+	gateway := SMSGatewayFunc(func(ctx context.Context, imei, text string) (string, error) {
+		queried = append(queried, imei+" "+text)
+		if err := replies[text]; err != nil {
+			return "", err
+		}
+		return "GPS:OFF GPRS:Link down", nil
+	})
+	srv, addr, events := startServer(t, WithCommandEscalation(CommandEscalation{
+		Timeout: 50 * time.Millisecond,
+		Gateway: gateway,
+	}))
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+	conn := dialLogin(t, addr, testIMEI, events)
+
+	// A response in time
+	if err := srv.SendCommand(testIMEI, 1, "WHERE#"); err != nil {
+		t.Fatal(err)
+	}
+	readTCP(t, conn)
+	respond(t, conn, 1, "Lat:N23.1")
+	if s := waitOutcome(t, srv, 1, CommandResponded); s.Response != "Lat:N23.1" || s.Command != "WHERE#" {
+		t.Errorf("Unexpected status %+v", s)
+	}
+
+	// No response, the device replies by SMS
+	srv.SendCommand(testIMEI, 2, "RESET#")
+	readTCP(t, conn)
+	if s := waitOutcome(t, srv, 2, CommandSMSReplied); s.SMSReply != "GPS:OFF GPRS:Link down" || s.QueriedAt.IsZero() {
+		t.Errorf("Unexpected status %+v", s)
+	}
+	if len(queried) != 1 || queried[0] != testIMEI+" STATUS#" {
+		t.Errorf("Unexpected queries %v", queried)
+	}
+
+	// Neither, until the response arrives late
+	replies["STATUS#"] = errors.New("no reply")
+	srv.SendCommand(testIMEI, 3, "RESET#")
+	readTCP(t, conn)
+	if s := waitOutcome(t, srv, 3, CommandUnreachable); s.SMSError != "no reply" {
+		t.Errorf("Unexpected status %+v", s)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrNoCommandResponse) {
+			t.Errorf("Expected ErrNoCommandResponse, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the unreachable device reported")
+	}
+	respond(t, conn, 3, "OK")
+	waitOutcome(t, srv, 3, CommandResponded)
+}
+
+func TestServer_CommandTimeout(t *testing.T) {
+	srv, addr, events := startServer(t, WithCommandEscalation(CommandEscalation{Timeout: 50 * time.Millisecond}))
+	conn := dialLogin(t, addr, testIMEI, events)
+
+	srv.SendCommand(testIMEI, 9, "STATUS#")
+	readTCP(t, conn)
+	waitOutcome(t, srv, 9, CommandTimedOut)
+
+	api := NewAPI(srv)
+	var status CommandStatus
+	if code := call(t, api, "GET", "/api/devices/"+testIMEI+"/commands/9", "", &status); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if status.Outcome != CommandTimedOut || status.Command != "STATUS#" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if code := call(t, api, "GET", "/api/devices/"+testIMEI+"/commands/8", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown flag, got %d", code)
+	}
+	if code := call(t, api, "GET", "/api/devices/"+testIMEI+"/commands/x", "", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid flag, got %d", code)
+	}
+}
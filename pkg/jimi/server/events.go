@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+// Event page sizes (see Server.Events)
+const (
+	// DefaultEventPage is the number of events per page when no limit is given
+	DefaultEventPage = 100
+
+	// MaxEventPage bounds the number of events per page
+	MaxEventPage = 1000
+)
+
+// EventNamespace is the store namespace of the persisted event feed (see
+// Server.PersistEvents)
+const EventNamespace = "events"
+
+// Store keys of the persisted event feed
+const (
+	eventFeedKey   = EventNamespace + "/feed"
+	eventLogPrefix = EventNamespace + "/log/"
+)
+
+// ErrInvalidCursor is returned by Events for malformed cursors
+var ErrInvalidCursor = errors.New("server: invalid event cursor")
+
+// EventPage is a page of the event feed (see Server.Events)
+type EventPage struct {
+	// Events are the matching events after the cursor, oldest first
+	Events []Event `json:"events"`
+
+	// Next is the cursor to pass to get the following page. It is set even
+	// when Events is empty, so polling clients always resume from it.
+	Next string `json:"next"`
+
+	// More reports whether matching events were left out by the limit
+	More bool `json:"more"`
+
+	// Gap reports whether events after the cursor are no longer kept (see
+	// WithEventHistory), or the cursor belongs to a feed that has since
+	// been reset, e.g. by a restart without PersistEvents
+	Gap bool `json:"gap"`
+}
+
+// feedState is the persisted position of the event feed
+type feedState struct {
+	Epoch  string `json:"epoch"`
+	LastID uint64 `json:"last_id"`
+}
+
+// Events returns up to limit kept events matching filter (nil matches all)
+// after a cursor, for clients polling the feed over HTTP. An empty cursor
+// starts at the oldest kept event. Cursors are opaque resume tokens of the
+// form "<epoch>-<event ID>"; the epoch identifies the feed, so a cursor
+// issued before the feed was reset reports a Gap instead of silently
+// skipping events.
+//
+// Example usage:
+//
+//	cursor := ""
+//	for range time.Tick(10 * time.Second) {
+//	    page, _ := srv.Events(cursor, nil, server.DefaultEventPage)
+//	    for _, e := range page.Events {
+//	        process(e)
+//	    }
+//	    cursor = page.Next
+//	}
+func (s *Server) Events(cursor string, filter func(Event) bool, limit int) (EventPage, error) {
+	if limit <= 0 {
+		limit = DefaultEventPage
+	}
+	limit = min(limit, MaxEventPage)
+
+	var epoch string
+	var after uint64
+	if cursor != "" {
+		var err error
+		if epoch, after, err = parseCursor(cursor); err != nil {
+			return EventPage{}, err
+		}
+	}
+
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	page := EventPage{Events: []Event{}}
+	if cursor != "" && (epoch != s.epoch || after > s.eventID) {
+		page.Gap, after = true, 0
+	}
+	// The history holds every event up to eventID without holes
+	if cursor != "" && after < s.eventID-uint64(len(s.history)) {
+		page.Gap = true
+	}
+
+	last := after
+	for _, e := range s.history {
+		if e.ID <= after {
+			continue
+		}
+		if filter != nil && !filter(e) {
+			last = e.ID
+			continue
+		}
+		if len(page.Events) == limit {
+			page.More = true
+			break
+		}
+		page.Events = append(page.Events, e)
+		last = e.ID
+	}
+	if !page.More {
+		last = s.eventID
+	}
+	page.Next = s.epoch + "-" + strconv.FormatUint(last, 10)
+	return page, nil
+}
+
+// parseCursor splits a cursor into its epoch and event ID
+func parseCursor(cursor string) (string, uint64, error) {
+	epoch, id, ok := strings.Cut(cursor, "-")
+	n, err := strconv.ParseUint(id, 10, 64)
+	if !ok || epoch == "" || err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return epoch, n, nil
+}
+
+// newEpoch returns a random feed epoch
+func newEpoch() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// PersistEvents makes the event feed survive restarts. It loads the event
+// ID, the cursor epoch and the kept history from kv (under EventNamespace),
+// then writes every event through to kv, so event IDs, Last-Event-ID and
+// Events cursors stay valid across restarts. Call it before serving; events
+// published earlier are discarded. Write errors are reported to OnError.
+//
+// Example usage:
+//
+//	kv := store.NewBolt(boltAdapter{db, []byte("jimi")})
+//	if err := srv.PersistEvents(ctx, kv); err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *Server) PersistEvents(ctx context.Context, kv store.KV) error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	feed := feedState{Epoch: newEpoch()}
+	data, err := kv.Get(ctx, eventFeedKey)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		data, _ := json.Marshal(feed)
+		if err := kv.Set(ctx, eventFeedKey, data); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return fmt.Errorf("event feed: %w", err)
+		}
+	}
+
+	keys, err := kv.Keys(ctx, eventLogPrefix)
+	if err != nil {
+		return err
+	}
+	drop := max(len(keys)-s.historySize, 0)
+	for _, key := range keys[:drop] {
+		if err := kv.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	history := make([]Event, 0, s.historySize)
+	for _, key := range keys[drop:] {
+		data, err := kv.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("event %s: %w", strings.TrimPrefix(key, eventLogPrefix), err)
+		}
+		history = append(history, e)
+	}
+
+	if n := len(history); n > 0 {
+		// The feed position is written after the event
+		feed.LastID = max(feed.LastID, history[n-1].ID)
+	}
+	s.epoch, s.eventID, s.history, s.eventKV = feed.Epoch, feed.LastID, history, kv
+	return nil
+}
+
+// persistEvent writes a published event and the feed position through to
+// the store, and deletes the event that left the history (0 for none)
+func (s *Server) persistEvent(e Event, evicted uint64) error {
+	ctx := context.Background()
+	if s.historySize > 0 {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := s.eventKV.Set(ctx, eventKey(e.ID), data); err != nil {
+			return err
+		}
+	}
+	if evicted > 0 {
+		if err := s.eventKV.Delete(ctx, eventKey(evicted)); err != nil {
+			return err
+		}
+	}
+	data, _ := json.Marshal(feedState{Epoch: s.epoch, LastID: s.eventID})
+	return s.eventKV.Set(ctx, eventFeedKey, data)
+}
+
+// eventKey returns the store key of an event; IDs are zero-padded so keys
+// sort in event order
+func eventKey(id uint64) string {
+	return fmt.Sprintf("%s%020d", eventLogPrefix, id)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+// publishN publishes n location events for each IMEI, in turn
+func publishN(t *testing.T, srv *Server, n int, imeis ...string) {
+	t.Helper()
+	p, err := jimi.NewDecoder().Decode(mustHex(t, packets.LocationPackets[0].Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range n {
+		for _, imei := range imeis {
+			srv.streamMu.Lock()
+			err := srv.publishLocked(imei, p)
+			srv.streamMu.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// eventIDs returns the IDs of a page
+func eventIDs(page EventPage) []uint64 {
+	var ids []uint64
+	for _, e := range page.Events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestEvents(t *testing.T) {
+	srv := New(WithEventHistory(4))
+	publishN(t, srv, 3, "a", "b") // 1..6, history 3..6
+	cursor := func(id string) string { return srv.epoch + "-" + id }
+	onlyA := func(e Event) bool { return e.IMEI == "a" }
+
+	tests := []struct {
+		name     string
+		cursor   string
+		filter   func(Event) bool
+		limit    int
+		want     []uint64
+		wantNext string
+		wantMore bool
+		wantGap  bool
+	}{
+		{"from start", "", nil, 0, []uint64{3, 4, 5, 6}, cursor("6"), false, false},
+		{"resume", cursor("4"), nil, 0, []uint64{5, 6}, cursor("6"), false, false},
+		{"limit", cursor("3"), nil, 2, []uint64{4, 5}, cursor("5"), true, false},
+		{"up to date", cursor("6"), nil, 0, []uint64{}, cursor("6"), false, false},
+		{"filter", cursor("3"), onlyA, 0, []uint64{5}, cursor("6"), false, false},
+		{"filter limit", "", onlyA, 1, []uint64{3}, cursor("4"), true, false},
+		{"evicted", cursor("1"), nil, 0, []uint64{3, 4, 5, 6}, cursor("6"), false, true},
+		{"other epoch", "feed-4", nil, 0, []uint64{3, 4, 5, 6}, cursor("6"), false, true},
+		{"ahead of feed", cursor("9"), nil, 0, []uint64{3, 4, 5, 6}, cursor("6"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := srv.Events(tt.cursor, tt.filter, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := eventIDs(page); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected events %v, got %v", tt.want, got)
+			}
+			if page.Next != tt.wantNext || page.More != tt.wantMore || page.Gap != tt.wantGap {
+				t.Errorf("Expected next=%s more=%v gap=%v, got next=%s more=%v gap=%v",
+					tt.wantNext, tt.wantMore, tt.wantGap, page.Next, page.More, page.Gap)
+			}
+		})
+	}
+
+	for _, bad := range []string{"nodash", "-5", "abc-x"} {
+		if _, err := srv.Events(bad, nil, 0); err != ErrInvalidCursor {
+			t.Errorf("Events(%q): expected ErrInvalidCursor, got %v", bad, err)
+		}
+	}
+}
+
+func TestPersistEvents(t *testing.T) {
+	ctx := context.Background()
+	kv := store.NewMemory()
+
+	first := New(WithEventHistory(3))
+	if err := first.PersistEvents(ctx, kv); err != nil {
+		t.Fatal(err)
+	}
+	publishN(t, first, 4, testIMEI)
+	page, _ := first.Events("", nil, 0)
+	cursor := page.Next
+
+	keys, _ := kv.Keys(ctx, eventLogPrefix)
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 stored events, got %v", keys)
+	}
+
+	// A restarted server continues the feed and accepts its cursors
+	restarted := New(WithEventHistory(2))
+	if err := restarted.PersistEvents(ctx, kv); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.LastEventID(); got != 4 {
+		t.Fatalf("Expected last event 4 after restart, got %d", got)
+	}
+	publishN(t, restarted, 1, testIMEI)
+	page, err := restarted.Events(cursor, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventIDs(page); !slices.Equal(got, []uint64{5}) || page.Gap {
+		t.Errorf("Expected event 5 without gap, got %v (gap=%v)", got, page.Gap)
+	}
+	if page.Events[0].IMEI != testIMEI {
+		t.Errorf("Expected IMEI %s, got %q", testIMEI, page.Events[0].IMEI)
+	}
+	keys, _ = kv.Keys(ctx, eventLogPrefix)
+	if len(keys) != 2 {
+		t.Errorf("Expected the history pruned to 2 events, got %v", keys)
+	}
+
+	// A server without the store starts a new feed
+	page, _ = New().Events(cursor, nil, 0)
+	if !page.Gap {
+		t.Error("Expected a gap for the cursor of another feed")
+	}
+}
+
+func TestAPI_Events(t *testing.T) {
+	srv := New()
+	publishN(t, srv, 2, testIMEI, "868120303960873")
+	api := NewAPI(srv)
+
+	var page EventPage
+	if code := call(t, api, "GET", "/api/events?limit=3", "", &page); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if got := eventIDs(page); !slices.Equal(got, []uint64{1, 2, 3}) || !page.More {
+		t.Fatalf("Expected events 1-3 and more, got %v (more=%v)", got, page.More)
+	}
+
+	page2 := EventPage{}
+	call(t, api, "GET", "/api/events?imei="+testIMEI+"&after="+page.Next, "", &page2)
+	if got := eventIDs(page2); len(got) != 0 || page2.More || !strings.HasSuffix(page2.Next, "-4") {
+		t.Errorf("Expected no more events of %s up to 4, got %v (next=%s)", testIMEI, got, page2.Next)
+	}
+
+	for _, path := range []string{"/api/events?limit=0", "/api/events?after=bogus"} {
+		if code := call(t, api, "GET", path, "", nil); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, code)
+		}
+	}
+}
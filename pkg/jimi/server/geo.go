@@ -0,0 +1,271 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Connection policy errors, reported through OnError
+var (
+	// ErrConnectionDenied is reported when the geo policy refuses a connection
+	ErrConnectionDenied = errors.New("server: connection denied")
+
+	// ErrConnectionFlagged is reported when the geo policy flags a connection
+	ErrConnectionFlagged = errors.New("server: connection flagged")
+)
+
+// IPInfo is what an IP lookup provider knows about an address
+type IPInfo struct {
+	// Country is the ISO 3166-1 alpha-2 code ("" when unknown)
+	Country string `json:"country,omitempty"`
+
+	// ASN is the autonomous system number (0 when unknown)
+	ASN uint32 `json:"asn,omitempty"`
+
+	// Org is the name of the network owner
+	Org string `json:"org,omitempty"`
+}
+
+// IPLookup resolves IP addresses, e.g. a wrapper around a GeoIP database
+type IPLookup interface {
+	LookupIP(addr netip.Addr) (IPInfo, error)
+}
+
+// IPLookupFunc adapts a function to IPLookup
+type IPLookupFunc func(addr netip.Addr) (IPInfo, error)
+
+// LookupIP calls f
+func (f IPLookupFunc) LookupIP(addr netip.Addr) (IPInfo, error) {
+	return f(addr)
+}
+
+// GeoRule restricts where connections may come from.
+// Empty allow lists allow everything not denied.
+type GeoRule struct {
+	AllowCountries []string
+	DenyCountries  []string
+	AllowASNs      []uint32
+	DenyASNs       []uint32
+
+	// FlagOnly accepts violating connections but flags them
+	FlagOnly bool
+}
+
+// check returns why info violates the rule, or ""
+func (r GeoRule) check(info IPInfo) string {
+	country := strings.ToUpper(info.Country)
+	switch {
+	case slices.ContainsFunc(r.DenyCountries, func(c string) bool { return strings.EqualFold(c, country) }):
+		return "country " + country + " is denied"
+	case slices.Contains(r.DenyASNs, info.ASN):
+		return fmt.Sprintf("AS%d is denied", info.ASN)
+	case len(r.AllowCountries) > 0 && !slices.ContainsFunc(r.AllowCountries, func(c string) bool { return strings.EqualFold(c, country) }):
+		if country == "" {
+			return "unknown country"
+		}
+		return "country " + country + " is not allowed"
+	case len(r.AllowASNs) > 0 && !slices.Contains(r.AllowASNs, info.ASN):
+		return fmt.Sprintf("AS%d is not allowed", info.ASN)
+	}
+	return ""
+}
+
+// GeoVerdict is the outcome of a geo policy check
+type GeoVerdict struct {
+	IPInfo
+
+	// Denied connections are closed (TCP) or ignored (UDP)
+	Denied bool `json:"denied,omitempty"`
+
+	// Flagged connections are accepted but violate a FlagOnly rule
+	Flagged bool `json:"flagged,omitempty"`
+
+	// Reason explains a denied or flagged verdict
+	Reason string `json:"reason,omitempty"`
+}
+
+// GeoPolicy denies or flags connections by the country and ASN of their
+// remote address, with a default rule and per-identity rules (e.g. one per
+// gateway or tenant, see DeviceAuth). Loopback, private and link-local
+// addresses are not checked. Lookup errors let the connection through.
+//
+// Example usage:
+//
+//	lookup, _ := server.ParseIPTable(f) // or a GeoIP database wrapper
+//	geo := server.NewGeoPolicy(lookup, server.GeoRule{AllowCountries: []string{"AU", "NZ"}})
+//	geo.SetRule(server.Identity{Method: server.AuthTLS, Name: "gateway-eu"}, server.GeoRule{DenyCountries: []string{"RU"}, FlagOnly: true})
+//	srv := server.New(server.WithGeoPolicy(geo))
+type GeoPolicy struct {
+	lookup IPLookup
+
+	mu         sync.RWMutex
+	def        GeoRule
+	byIdentity map[Identity]GeoRule
+}
+
+// NewGeoPolicy creates a geo policy applying def to every identity without its own rule
+func NewGeoPolicy(lookup IPLookup, def GeoRule) *GeoPolicy {
+	return &GeoPolicy{
+		lookup:     lookup,
+		def:        def,
+		byIdentity: make(map[Identity]GeoRule),
+	}
+}
+
+// SetRule sets the rule of one identity
+func (p *GeoPolicy) SetRule(id Identity, r GeoRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byIdentity[id] = r
+}
+
+// Check looks up the remote address and applies the rule of id.
+// The error is a lookup error; the verdict then allows the connection.
+func (p *GeoPolicy) Check(id Identity, remoteAddr string) (GeoVerdict, error) {
+	addr, err := parseRemoteAddr(remoteAddr)
+	if err != nil {
+		return GeoVerdict{}, err
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return GeoVerdict{}, nil
+	}
+
+	info, err := p.lookup.LookupIP(addr)
+	if err != nil {
+		return GeoVerdict{}, fmt.Errorf("geo lookup %s: %w", addr, err)
+	}
+
+	p.mu.RLock()
+	rule, ok := p.byIdentity[id]
+	if !ok {
+		rule = p.def
+	}
+	p.mu.RUnlock()
+
+	v := GeoVerdict{IPInfo: info}
+	if v.Reason = rule.check(info); v.Reason != "" {
+		v.Flagged = rule.FlagOnly
+		v.Denied = !rule.FlagOnly
+	}
+	return v, nil
+}
+
+// parseRemoteAddr parses "host:port" or a bare IP
+func parseRemoteAddr(s string) (netip.Addr, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q", s)
+	}
+	return addr.Unmap(), nil
+}
+
+// ipRange is one network of an IPTable
+type ipRange struct {
+	prefix netip.Prefix
+	info   IPInfo
+}
+
+// IPTable is an in-memory IPLookup over CIDR ranges; the most specific range wins
+type IPTable struct {
+	ranges []ipRange // most specific first
+}
+
+// ParseIPTable reads an IPTable from CSV lines "cidr,country[,asn[,org]]",
+// e.g. an export of a GeoIP database. Lines starting with '#' are ignored.
+func ParseIPTable(r io.Reader) (*IPTable, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	t := &IPTable{}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		prefix, err := netip.ParsePrefix(rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		info := IPInfo{}
+		if len(rec) > 1 {
+			info.Country = strings.ToUpper(rec[1])
+		}
+		if len(rec) > 2 && rec[2] != "" {
+			asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(rec[2]), "AS"), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid ASN %q", line, rec[2])
+			}
+			info.ASN = uint32(asn)
+		}
+		if len(rec) > 3 {
+			info.Org = rec[3]
+		}
+		t.ranges = append(t.ranges, ipRange{prefix.Masked(), info})
+	}
+	t.sort()
+	return t, nil
+}
+
+// Add adds a range
+func (t *IPTable) Add(prefix netip.Prefix, info IPInfo) {
+	t.ranges = append(t.ranges, ipRange{prefix.Masked(), info})
+	t.sort()
+}
+
+// sort orders the ranges from most to least specific
+func (t *IPTable) sort() {
+	sort.SliceStable(t.ranges, func(i, j int) bool {
+		return t.ranges[i].prefix.Bits() > t.ranges[j].prefix.Bits()
+	})
+}
+
+// LookupIP returns the info of the most specific range containing addr,
+// or an empty IPInfo when no range matches
+func (t *IPTable) LookupIP(addr netip.Addr) (IPInfo, error) {
+	for _, r := range t.ranges {
+		if r.prefix.Contains(addr) {
+			return r.info, nil
+		}
+	}
+	return IPInfo{}, nil
+}
+
+// checkGeo applies the geo policy to a new session; returns false if it is denied
+func (s *Server) checkGeo(sess *Session) bool {
+	if s.geo == nil {
+		return true
+	}
+
+	v, err := s.geo.Check(sess.identity, sess.remoteAddr)
+	if err != nil {
+		s.report(sess, err)
+	}
+	sess.geo = v
+
+	switch {
+	case v.Denied:
+		s.report(sess, fmt.Errorf("%w: %s (%s)", ErrConnectionDenied, sess.remoteAddr, v.Reason))
+		return false
+	case v.Flagged:
+		s.report(sess, fmt.Errorf("%w: %s (%s)", ErrConnectionFlagged, sess.remoteAddr, v.Reason))
+	}
+	return true
+}
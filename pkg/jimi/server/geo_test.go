@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIPTable maps documentation ranges to countries and ASNs
+func testIPTable(t *testing.T) *IPTable {
+	t.Helper()
+	table, err := ParseIPTable(strings.NewReader(`
+# cidr,country,asn,org
+203.0.113.0/24,AU,AS1221,Telstra
+203.0.113.128/25,NZ,9500
+198.51.100.0/24,ru,12389
+2001:db8::/32,DE,3320
+`))
+	if err != nil {
+		t.Fatalf("ParseIPTable failed: %v", err)
+	}
+	return table
+}
+
+func TestIPTable_Lookup(t *testing.T) {
+	table := testIPTable(t)
+
+	tests := []struct {
+		addr string
+		want IPInfo
+	}{
+		{"203.0.113.5", IPInfo{Country: "AU", ASN: 1221, Org: "Telstra"}},
+		{"203.0.113.200", IPInfo{Country: "NZ", ASN: 9500}}, // most specific range
+		{"198.51.100.1", IPInfo{Country: "RU", ASN: 12389}},
+		{"2001:db8::1", IPInfo{Country: "DE", ASN: 3320}},
+		{"192.0.2.1", IPInfo{}},
+	}
+
+	for _, tt := range tests {
+		got, err := table.LookupIP(netip.MustParseAddr(tt.addr))
+		if err != nil || got != tt.want {
+			t.Errorf("LookupIP(%s) = %+v, %v; want %+v", tt.addr, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseIPTable(strings.NewReader("not-a-cidr,AU")); err == nil {
+		t.Error("Expected error for invalid CIDR")
+	}
+}
+
+func TestGeoPolicy_Check(t *testing.T) {
+	gateway := Identity{Method: AuthTLS, Name: "gateway"}
+
+	geo := NewGeoPolicy(testIPTable(t), GeoRule{AllowCountries: []string{"AU", "NZ"}, DenyASNs: []uint32{9500}})
+	geo.SetRule(gateway, GeoRule{DenyCountries: []string{"RU"}, FlagOnly: true})
+
+	tests := []struct {
+		name    string
+		id      Identity
+		addr    string
+		denied  bool
+		flagged bool
+	}{
+		{"allowed country", Identity{}, "203.0.113.5:4000", false, false},
+		{"denied ASN", Identity{}, "203.0.113.200:4000", true, false},
+		{"country not allowed", Identity{}, "198.51.100.1:4000", true, false},
+		{"unknown country", Identity{}, "192.0.2.1:4000", true, false},
+		{"private address", Identity{}, "10.1.2.3:4000", false, false},
+		{"loopback IPv6", Identity{}, "[::1]:4000", false, false},
+		{"identity rule flags", gateway, "198.51.100.1:4000", false, true},
+		{"identity rule replaces default", gateway, "192.0.2.1:4000", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := geo.Check(tt.id, tt.addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if v.Denied != tt.denied || v.Flagged != tt.flagged {
+				t.Errorf("Expected denied=%v flagged=%v, got %+v", tt.denied, tt.flagged, v)
+			}
+			if (v.Denied || v.Flagged) && v.Reason == "" {
+				t.Error("Expected a reason")
+			}
+		})
+	}
+}
+
+func TestGeoPolicy_LookupErrorAllows(t *testing.T) {
+	geo := NewGeoPolicy(IPLookupFunc(func(netip.Addr) (IPInfo, error) {
+		return IPInfo{}, errors.New("database unavailable")
+	}), GeoRule{AllowCountries: []string{"AU"}})
+
+	v, err := geo.Check(Identity{}, "203.0.113.5:4000")
+	if err == nil {
+		t.Error("Expected lookup error")
+	}
+	if v.Denied {
+		t.Error("Expected lookup errors to let the connection through")
+	}
+}
+
+// remoteConn overrides the remote address of a connection
+type remoteConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr { return c.remote }
+
+// remoteListener makes accepted connections appear to come from remote
+type remoteListener struct {
+	net.Listener
+	remote net.Addr
+}
+
+func (l remoteListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return remoteConn{c, l.remote}, nil
+}
+
+func TestServer_GeoPolicy(t *testing.T) {
+	geo := NewGeoPolicy(testIPTable(t), GeoRule{AllowCountries: []string{"AU"}})
+
+	tests := []struct {
+		name    string
+		remote  string
+		wantErr error
+	}{
+		{"allowed", "203.0.113.5:4000", nil},
+		{"denied", "198.51.100.1:4000", ErrConnectionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := net.TCPAddrFromAddrPort(netip.MustParseAddrPort(tt.remote))
+			addr, events, errs := startAuthServer(t, func(l net.Listener) net.Listener {
+				return remoteListener{l, remote}
+			}, WithGeoPolicy(geo))
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.Write(mustHex(t, loginHex))
+
+			if tt.wantErr == nil {
+				readTCP(t, conn)
+				e := next(t, events, "login")
+				if g := e.sess.Geo(); g.Country != "AU" || g.Denied {
+					t.Errorf("Expected AU verdict, got %+v", g)
+				}
+				if info := e.sess.Info(); info.Geo == nil || info.Geo.ASN != 1221 {
+					t.Errorf("Expected geo in session info, got %+v", info.Geo)
+				}
+				return
+			}
+
+			select {
+			case err := <-errs:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timed out waiting for denial")
+			}
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if n, err := conn.Read(make([]byte, 64)); err == nil {
+				t.Errorf("Expected closed connection, read %d bytes", n)
+			}
+			if len(events) > 0 {
+				t.Errorf("Expected no callbacks for a denied connection, got %s", (<-events).kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fleet summary windows
+const (
+	// MovingWindow is how recent a fix with a non-zero speed must be for an
+	// online device to count as moving
+	MovingWindow = 5 * time.Minute
+
+	// AlarmWindow is how recent an alarm must be for a device to count as alarming
+	AlarmWindow = 15 * time.Minute
+)
+
+// Groups is a catalog of named device groups (e.g. per customer or depot).
+// A device may belong to several groups.
+//
+// Example usage:
+//
+//	groups := server.NewGroups()
+//	groups.Add("depot-north", "359339073930520", "359339073930538")
+//	api := server.NewAPI(srv, server.WithAPIGroups(groups))
+type Groups struct {
+	mu      sync.RWMutex
+	members map[string]map[string]bool
+}
+
+// NewGroups creates an empty catalog
+func NewGroups() *Groups {
+	return &Groups{members: make(map[string]map[string]bool)}
+}
+
+// Add adds IMEIs to a group, creating it if needed
+func (g *Groups) Add(group string, imeis ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	set := g.members[group]
+	if set == nil {
+		set = make(map[string]bool, len(imeis))
+		g.members[group] = set
+	}
+	for _, imei := range imeis {
+		set[canonicalID(imei)] = true
+	}
+}
+
+// Remove deletes a group
+func (g *Groups) Remove(group string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.members, group)
+}
+
+// Names returns the group names, sorted
+func (g *Groups) Names() []string {
+	g.mu.RLock()
+	out := make([]string, 0, len(g.members))
+	for name := range g.members {
+		out = append(out, name)
+	}
+	g.mu.RUnlock()
+
+	sort.Strings(out)
+	return out
+}
+
+// Members returns the IMEIs of a group, sorted, and whether it exists
+func (g *Groups) Members(group string) ([]string, bool) {
+	g.mu.RLock()
+	set, ok := g.members[group]
+	out := make([]string, 0, len(set))
+	for imei := range set {
+		out = append(out, imei)
+	}
+	g.mu.RUnlock()
+
+	sort.Strings(out)
+	return out, ok
+}
+
+// Contains reports whether imei belongs to group
+func (g *Groups) Contains(group, imei string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.members[group][imei]
+}
+
+// ParseGroups reads a catalog from a text file with one group per line:
+//
+//	# group  IMEIs
+//	depot-north 359339073930520 359339073930538
+//	vans        868120303960873
+//
+// A group may span several lines. Blank lines and lines starting with '#'
+// are ignored.
+func ParseGroups(r io.Reader) (*Groups, error) {
+	g := NewGroups()
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("line %d: no IMEIs for group %s", line, fields[0])
+		}
+		g.Add(fields[0], fields[1:]...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// FleetSummary counts the state of a set of devices
+type FleetSummary struct {
+	// Group is the group name, if the summary is of a group
+	Group string `json:"group,omitempty"`
+
+	// Devices is the number of devices
+	Devices int `json:"devices"`
+
+	// Online is the number of logged-in devices
+	Online int `json:"online"`
+
+	// Moving is the number of online devices with a recent fix with a
+	// non-zero speed (see MovingWindow)
+	Moving int `json:"moving"`
+
+	// Alarming is the number of devices with a recent alarm (see AlarmWindow)
+	Alarming int `json:"alarming"`
+
+	// IMEIs lists the devices, for the summary of a single group
+	IMEIs []string `json:"imeis,omitempty"`
+}
+
+// Summarize counts the online, moving and alarming devices among imeis at
+// the given time
+func (s *Server) Summarize(imeis []string, now time.Time) FleetSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := FleetSummary{Devices: len(imeis)}
+	for _, imei := range imeis {
+		_, online := s.sessions[imei]
+		if online {
+			sum.Online++
+			if pos, ok := s.positions[imei]; ok && pos.Speed > 0 && now.Sub(pos.ReceivedAt) < MovingWindow {
+				sum.Moving++
+			}
+		}
+		if at, ok := s.alarms[imei]; ok && now.Sub(at) < AlarmWindow {
+			sum.Alarming++
+		}
+	}
+	return sum
+}
+
+// trackAlarm records when a logged-in device last raised an alarm
+func (s *Server) trackAlarm(sess *Session) {
+	imei := sess.IMEI()
+	if imei == "" {
+		return
+	}
+	s.mu.Lock()
+	s.alarms[imei] = time.Now()
+	s.mu.Unlock()
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+func TestParseGroups(t *testing.T) {
+	in := `# group  IMEIs
+depot-north 359339073930520 359339073930538
+
+vans 868120303960873
+vans 359339073930520
+`
+	g, err := ParseGroups(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Names(); !slices.Equal(got, []string{"depot-north", "vans"}) {
+		t.Errorf("Unexpected groups %v", got)
+	}
+	if got, _ := g.Members("vans"); !slices.Equal(got, []string{"359339073930520", "868120303960873"}) {
+		t.Errorf("Unexpected members %v", got)
+	}
+	if !g.Contains("depot-north", "359339073930538") || g.Contains("vans", "359339073930538") {
+		t.Error("Unexpected membership")
+	}
+	if _, ok := g.Members("unknown"); ok {
+		t.Error("Expected unknown group")
+	}
+
+	g.Remove("vans")
+	if g.Contains("vans", "359339073930520") {
+		t.Error("Expected removed group")
+	}
+
+	if _, err := ParseGroups(strings.NewReader("lonely\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected error for a group without IMEIs, got %v", err)
+	}
+}
+
+func TestServer_Summarize(t *testing.T) {
+	now := time.Now()
+	srv := New()
+	srv.sessions["online-moving"] = &Session{}
+	srv.sessions["online-parked"] = &Session{}
+	srv.sessions["online-stale"] = &Session{}
+	srv.positions["online-moving"] = Position{Position: export.Position{Speed: 40}, ReceivedAt: now.Add(-time.Minute)}
+	srv.positions["online-parked"] = Position{ReceivedAt: now.Add(-time.Minute)}
+	srv.positions["online-stale"] = Position{Position: export.Position{Speed: 40}, ReceivedAt: now.Add(-MovingWindow)}
+	srv.positions["offline"] = Position{Position: export.Position{Speed: 40}, ReceivedAt: now}
+	srv.alarms["online-parked"] = now.Add(-time.Minute)
+	srv.alarms["offline"] = now.Add(-time.Minute)
+	srv.alarms["online-stale"] = now.Add(-AlarmWindow)
+
+	tests := []struct {
+		name  string
+		imeis []string
+		want  FleetSummary
+	}{
+		{"empty", nil, FleetSummary{}},
+		{"all", []string{"online-moving", "online-parked", "online-stale", "offline", "unknown"},
+			FleetSummary{Devices: 5, Online: 3, Moving: 1, Alarming: 2}},
+		{"offline only", []string{"offline"}, FleetSummary{Devices: 1, Alarming: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := srv.Summarize(tt.imeis, now); !equalSummary(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// equalSummary compares summaries, including their members
+func equalSummary(a, b FleetSummary) bool {
+	return a.Group == b.Group && a.Devices == b.Devices && a.Online == b.Online &&
+		a.Moving == b.Moving && a.Alarming == b.Alarming && slices.Equal(a.IMEIs, b.IMEIs)
+}
+
+func TestAPI_Groups(t *testing.T) {
+	srv := New()
+	srv.sessions[testIMEI] = &Session{}
+	groups := NewGroups()
+	groups.Add("depot-north", testIMEI, "359339073930538")
+	groups.Add("vans", "868120303960873")
+	api := NewAPI(srv, WithAPIGroups(groups))
+
+	var all []FleetSummary
+	if code := call(t, api, "GET", "/api/groups", "", &all); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	want := []FleetSummary{
+		{Group: "depot-north", Devices: 2, Online: 1},
+		{Group: "vans", Devices: 1},
+	}
+	if len(all) != len(want) || !equalSummary(all[0], want[0]) || !equalSummary(all[1], want[1]) {
+		t.Errorf("Expected %+v, got %+v", want, all)
+	}
+
+	var one FleetSummary
+	if code := call(t, api, "GET", "/api/groups/depot-north", "", &one); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if want := (FleetSummary{Group: "depot-north", Devices: 2, Online: 1, IMEIs: []string{testIMEI, "359339073930538"}}); !equalSummary(one, want) {
+		t.Errorf("Expected %+v, got %+v", want, one)
+	}
+
+	for _, path := range []string{"/api/groups/unknown", "/api/groups/unknown/stream"} {
+		if code := call(t, api, "GET", path, "", nil); code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, code)
+		}
+	}
+	if code := call(t, NewAPI(srv), "GET", "/api/groups", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without groups, got %d", code)
+	}
+}
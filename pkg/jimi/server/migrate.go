@@ -0,0 +1,228 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// DefaultMigrationGrace is how long a migrating session keeps reading and
+// acknowledging the packets already on their way before it is closed
+const DefaultMigrationGrace = 2 * time.Second
+
+// DefaultMigrationInterval is the pause between two batches of a migration
+const DefaultMigrationInterval = 10 * time.Second
+
+// ErrMigrationRunning is returned by Migrate while another migration runs
+var ErrMigrationRunning = errors.New("server: migration already running")
+
+// MigrationPlan selects the sessions of a migration and its pace
+type MigrationPlan struct {
+	// IMEIs are the devices to migrate; empty migrates every TCP session
+	// open when the migration starts
+	IMEIs []string
+
+	// BatchSize is the number of sessions closed per batch; 0 closes them
+	// all at once
+	BatchSize int
+
+	// Interval is the pause between batches (default DefaultMigrationInterval)
+	Interval time.Duration
+
+	// Grace is how long each session keeps being served before it is
+	// closed (default DefaultMigrationGrace)
+	Grace time.Duration
+}
+
+// Migration is the progress of a migration
+type Migration struct {
+	// Total is the number of sessions to migrate
+	Total int `json:"total"`
+
+	// Migrated is the number of sessions asked to close so far
+	Migrated int `json:"migrated"`
+
+	// NotConnected lists the requested devices without a TCP session
+	NotConnected []string `json:"not_connected,omitempty"`
+
+	BatchSize  int       `json:"batch_size"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Canceled   bool      `json:"canceled,omitempty"`
+}
+
+// Running reports whether the migration has batches left
+func (m Migration) Running() bool {
+	return m.FinishedAt.IsZero()
+}
+
+// migrationState is the migration of a Server, guarded by Server.mu
+type migrationState struct {
+	Migration
+	stop    chan struct{}
+	stopped bool
+}
+
+// snapshot returns a copy of the progress. Caller must hold s.mu.
+func (m *migrationState) snapshot() Migration {
+	out := m.Migration
+	out.NotConnected = slices.Clone(m.NotConnected)
+	return out
+}
+
+// cancel stops the batches left. Caller must hold s.mu.
+func (m *migrationState) cancel() {
+	if !m.stopped {
+		m.stopped = true
+		close(m.stop)
+	}
+}
+
+// Migrate drops sessions gracefully so their devices reconnect elsewhere,
+// e.g. to the new instances of a rolling deploy. Each session keeps reading
+// for the plan's grace, so packets already sent are still handled and
+// acknowledged, then it is closed without reporting an error; the devices
+// reconnect through the load balancer, which should no longer route to this
+// server. Sessions are closed in batches of plan.BatchSize every
+// plan.Interval, in the background; follow them with MigrationStatus.
+// UDP sessions have no connection to drop and are skipped.
+//
+// Example usage:
+//
+//	m, err := srv.Migrate(server.MigrationPlan{BatchSize: 100, Interval: 30 * time.Second})
+func (s *Server) Migrate(plan MigrationPlan) (Migration, error) {
+	if plan.Interval <= 0 {
+		plan.Interval = DefaultMigrationInterval
+	}
+	if plan.Grace <= 0 {
+		plan.Grace = DefaultMigrationGrace
+	}
+
+	var targets []*Session
+	var notConnected []string
+	if len(plan.IMEIs) == 0 {
+		for _, sess := range s.Sessions() {
+			if sess.conn != nil {
+				targets = append(targets, sess)
+			}
+		}
+	} else {
+		for _, imei := range plan.IMEIs {
+			imei = canonicalID(imei)
+			if sess, ok := s.Session(imei); ok && sess.conn != nil {
+				targets = append(targets, sess)
+			} else {
+				notConnected = append(notConnected, imei)
+			}
+		}
+	}
+	batch := plan.BatchSize
+	if batch <= 0 || batch > len(targets) {
+		batch = len(targets)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return Migration{}, ErrServerClosed
+	}
+	if s.migration != nil && s.migration.Running() {
+		return Migration{}, ErrMigrationRunning
+	}
+	m := &migrationState{
+		Migration: Migration{
+			Total:        len(targets),
+			NotConnected: notConnected,
+			BatchSize:    batch,
+			StartedAt:    time.Now(),
+		},
+		stop: make(chan struct{}),
+	}
+	s.migration = m
+	go s.runMigration(m, targets, plan)
+	return m.snapshot(), nil
+}
+
+// MigrationStatus returns the progress of the last migration, false if none
+// was started
+func (s *Server) MigrationStatus() (Migration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.migration == nil {
+		return Migration{}, false
+	}
+	return s.migration.snapshot(), true
+}
+
+// CancelMigration stops the running migration before its next batch;
+// returns false if none is running. Sessions already asked to close still
+// close after their grace.
+func (s *Server) CancelMigration() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.migration == nil || !s.migration.Running() || s.migration.stopped {
+		return false
+	}
+	s.migration.cancel()
+	return true
+}
+
+// runMigration closes the sessions of m batch by batch
+func (s *Server) runMigration(m *migrationState, targets []*Session, plan MigrationPlan) {
+	canceled := false
+	for start := 0; start < len(targets); start += m.BatchSize {
+		if start > 0 {
+			select {
+			case <-m.stop:
+				canceled = true
+			case <-time.After(plan.Interval):
+			}
+			if canceled {
+				break
+			}
+		}
+		end := min(start+m.BatchSize, len(targets))
+		for _, sess := range targets[start:end] {
+			sess.migrate(plan.Grace)
+		}
+		s.mu.Lock()
+		m.Migrated = end
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	m.FinishedAt = time.Now()
+	m.Canceled = canceled
+	s.mu.Unlock()
+}
+
+// migrate closes a TCP session once grace has elapsed, letting its read
+// loop handle the packets received until then
+func (s *Session) migrate(grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil || !s.migrateBy.IsZero() {
+		return
+	}
+	s.migrateBy = time.Now().Add(grace)
+	s.conn.SetReadDeadline(s.migrateBy)
+}
+
+// isMigrating reports whether the session is being closed by a migration
+func (s *Session) isMigrating() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.migrateBy.IsZero()
+}
+
+// setReadDeadline sets the read deadline of the connection, no later than
+// the end of a migration grace. The lock keeps a concurrent migrate from
+// being overridden.
+func (s *Session) setReadDeadline(deadline time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.migrateBy.IsZero() && (deadline.IsZero() || s.migrateBy.Before(deadline)) {
+		deadline = s.migrateBy
+	}
+	s.conn.SetReadDeadline(deadline)
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// dialLogin opens a TCP session logged in as imei
+func dialLogin(t *testing.T, addr, imei string, events chan event) net.Conn {
+	t.Helper()
+	id, err := types.NewIMEI(imei)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := encoder.New().Login(&packet.LoginPacket{IMEI: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.Write(login)
+	readTCP(t, conn)
+	next(t, events, "login")
+	return conn
+}
+
+func TestServer_Migrate(t *testing.T) {
+	srv, addr, events := startServer(t)
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+
+	first := dialLogin(t, addr, testIMEI, events)
+	dialLogin(t, addr, "359339073930538", events)
+
+	m, err := srv.Migrate(MigrationPlan{BatchSize: 1, Interval: 500 * time.Millisecond, Grace: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Total != 2 || m.BatchSize != 1 || !m.Running() {
+		t.Errorf("Unexpected migration %+v", m)
+	}
+	if _, err := srv.Migrate(MigrationPlan{}); err != ErrMigrationRunning {
+		t.Errorf("Expected ErrMigrationRunning, got %v", err)
+	}
+
+	// Packets sent during the grace are still acknowledged
+	first.Write(mustHex(t, heartbeatHex))
+	readTCP(t, first)
+	if sess, _ := srv.Session(testIMEI); !sess.Info().Migrating {
+		t.Error("Expected the session flagged as migrating")
+	}
+
+	// One session per batch, the first one by IMEI first
+	if e := next(t, events, "disconnect"); e.sess.IMEI() != testIMEI {
+		t.Errorf("Expected %s migrated first, got %s", testIMEI, e.sess.IMEI())
+	}
+	if m, _ := srv.MigrationStatus(); m.Migrated != 1 || !m.Running() {
+		t.Errorf("Expected 1 session migrated, got %+v", m)
+	}
+	next(t, events, "disconnect")
+	if m, _ := srv.MigrationStatus(); m.Migrated != 2 || m.Running() || m.Canceled {
+		t.Errorf("Expected the migration finished, got %+v", m)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("Expected no error reported, got %v", err)
+	default:
+	}
+}
+
+func TestServer_MigrateCancel(t *testing.T) {
+	srv, addr, events := startServer(t)
+	dialLogin(t, addr, testIMEI, events)
+	dialLogin(t, addr, "359339073930538", events)
+
+	// Devices are migrated in the order listed
+	plan := MigrationPlan{
+		IMEIs:     []string{"359339073930538", "862476051124146", testIMEI},
+		BatchSize: 1,
+		Interval:  time.Minute,
+		Grace:     100 * time.Millisecond,
+	}
+	if _, err := srv.Migrate(plan); err != nil {
+		t.Fatal(err)
+	}
+	if e := next(t, events, "disconnect"); e.sess.IMEI() != "359339073930538" {
+		t.Errorf("Expected 359339073930538 migrated first, got %s", e.sess.IMEI())
+	}
+	if !srv.CancelMigration() {
+		t.Fatal("Expected the migration canceled")
+	}
+	if srv.CancelMigration() {
+		t.Error("Expected nothing left to cancel")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	m, _ := srv.MigrationStatus()
+	for m.Running() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		m, _ = srv.MigrationStatus()
+	}
+	if !m.Canceled || m.Total != 2 || m.Migrated != 1 || len(m.NotConnected) != 1 || m.NotConnected[0] != "862476051124146" {
+		t.Errorf("Unexpected migration %+v", m)
+	}
+	if _, ok := srv.Session(testIMEI); !ok {
+		t.Error("Expected the session of the canceled batch kept")
+	}
+}
+
+func TestAPI_Migration(t *testing.T) {
+	srv, addr, events := startServer(t)
+	dialLogin(t, addr, testIMEI, events)
+	api := NewAPI(srv)
+
+	if code := call(t, api, "GET", "/api/migration", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 before any migration, got %d", code)
+	}
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"batch_size":-1}`, http.StatusBadRequest},
+		{`{"batch":1}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := call(t, api, "POST", "/api/migration", tt.body, nil); code != tt.want {
+			t.Errorf("POST %s: expected %d, got %d", tt.body, tt.want, code)
+		}
+	}
+
+	var m Migration
+	if code := call(t, api, "POST", "/api/migration", `{"imeis":["0`+testIMEI+`"],"grace":1}`, &m); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if m.Total != 1 || len(m.NotConnected) != 0 {
+		t.Errorf("Unexpected migration %+v", m)
+	}
+	next(t, events, "disconnect")
+	call(t, api, "GET", "/api/migration", "", &m)
+	if m.Migrated != 1 || m.FinishedAt.IsZero() {
+		t.Errorf("Expected the migration finished, got %+v", m)
+	}
+	if code := call(t, api, "DELETE", "/api/migration", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a running migration, got %d", code)
+	}
+}
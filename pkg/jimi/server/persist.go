@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+// Store namespaces of the device state persisted by PersistState
+const (
+	PositionNamespace = "positions"
+	ConfigNamespace   = "configs"
+	CommandNamespace  = "commands"
+)
+
+// persistStripes is the number of locks ordering the writes of device state
+const persistStripes = 64
+
+// stateStores are the PersistState stores
+type stateStores struct {
+	positions store.DeviceStore // Position per device
+	configs   store.DeviceStore // ConfigSnapshot per device, without the boost
+	commands  store.DeviceStore // []CommandStatus per device
+}
+
+// PersistState makes the last positions, configuration snapshots and command
+// outcomes survive restarts. It loads them from kv (under PositionNamespace,
+// ConfigNamespace and CommandNamespace), then writes every change through to
+// kv. Commands still waiting for a response get the rest of their timeout;
+// those whose SMS query was cut short by the restart are queried again. Call
+// it before serving. Write errors are reported to OnError.
+//
+// Example usage:
+//
+//	kv := store.NewBolt(boltAdapter{db, []byte("jimi")})
+//	if err := srv.PersistState(ctx, kv); err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *Server) PersistState(ctx context.Context, kv store.KV) error {
+	st := &stateStores{
+		positions: store.NewDeviceStore(kv, PositionNamespace),
+		configs:   store.NewDeviceStore(kv, ConfigNamespace),
+		commands:  store.NewDeviceStore(kv, CommandNamespace),
+	}
+
+	positions := make(map[string]Position)
+	if err := loadAll(ctx, st.positions, positions); err != nil {
+		return fmt.Errorf("positions: %w", err)
+	}
+	configs := make(map[string]ConfigSnapshot)
+	if err := loadAll(ctx, st.configs, configs); err != nil {
+		return fmt.Errorf("configs: %w", err)
+	}
+	commands := make(map[string][]CommandStatus)
+	if err := loadAll(ctx, st.commands, commands); err != nil {
+		return fmt.Errorf("commands: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	for imei, pos := range positions {
+		s.positions[imei] = pos
+	}
+	for imei, cfg := range configs {
+		s.configs[imei] = cfg
+	}
+	for _, statuses := range commands {
+		for _, status := range statuses {
+			s.restoreCommand(status)
+		}
+	}
+	s.state = st
+	return nil
+}
+
+// loadAll loads the document of every device of ds into out
+func loadAll[T any](ctx context.Context, ds store.DeviceStore, out map[string]T) error {
+	imeis, err := ds.IMEIs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, imei := range imeis {
+		var v T
+		if _, err := ds.Load(ctx, imei, &v); err != nil {
+			return fmt.Errorf("%s: %w", imei, err)
+		}
+		out[imei] = v
+	}
+	return nil
+}
+
+// restoreCommand tracks a loaded command outcome, resuming its timeout.
+// Caller must hold s.mu.
+func (s *Server) restoreCommand(status CommandStatus) {
+	key := commandKey{status.IMEI, status.ServerFlag}
+	if _, ok := s.commands[key]; ok {
+		return
+	}
+	if len(s.commands) >= maxCommandStatuses {
+		s.evictOldestCommand()
+	}
+	c := &commandState{CommandStatus: status}
+	if s.commands == nil {
+		s.commands = make(map[commandKey]*commandState)
+	}
+	s.commands[key] = c
+
+	switch c.Outcome {
+	case CommandQuerying:
+		c.Outcome = CommandPending
+		c.timer = time.AfterFunc(0, func() { s.commandTimedOut(key, c) })
+	case CommandPending:
+		wait := max(time.Until(c.SentAt.Add(s.commandTimeout())), 0)
+		c.timer = time.AfterFunc(wait, func() { s.commandTimedOut(key, c) })
+	}
+}
+
+// persistPosition writes the last position of a device through to the store
+func (s *Server) persistPosition(sess *Session, imei string) {
+	if s.state == nil {
+		return
+	}
+	s.persistDevice(sess, s.state.positions, imei, func() (any, bool) {
+		pos, ok := s.positions[imei]
+		return pos, ok
+	})
+}
+
+// persistConfig writes the configuration snapshot of a device through to the store
+func (s *Server) persistConfig(sess *Session, imei string) {
+	if s.state == nil {
+		return
+	}
+	s.persistDevice(sess, s.state.configs, imei, func() (any, bool) {
+		cfg, ok := s.configs[imei]
+		return cfg, ok
+	})
+}
+
+// persistCommands writes the command outcomes of a device through to the store
+func (s *Server) persistCommands(sess *Session, imei string) {
+	if s.state == nil {
+		return
+	}
+	s.persistDevice(sess, s.state.commands, imei, func() (any, bool) {
+		var statuses []CommandStatus
+		for key, c := range s.commands {
+			if key.imei == imei {
+				statuses = append(statuses, c.CommandStatus)
+			}
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].ServerFlag < statuses[j].ServerFlag })
+		return statuses, len(statuses) > 0
+	})
+}
+
+// persistDevice writes the state returned by snapshot (taken under s.mu)
+// through to ds, or deletes it when there is none. Writes of a device are
+// serialized and snapshot is taken in that order, so the last write always
+// carries the latest state.
+func (s *Server) persistDevice(sess *Session, ds store.DeviceStore, imei string, snapshot func() (any, bool)) {
+	h := fnv.New32a()
+	h.Write([]byte(imei))
+	mu := &s.persistMu[h.Sum32()%persistStripes]
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.mu.Lock()
+	v, ok := snapshot()
+	s.mu.Unlock()
+
+	ctx := context.Background()
+	var err error
+	if ok {
+		err = ds.Save(ctx, imei, v)
+	} else {
+		err = ds.Delete(ctx, imei)
+	}
+	if err != nil {
+		s.report(sess, fmt.Errorf("persist state of %s: %w", imei, err))
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+func TestPersistState(t *testing.T) {
+	ctx := context.Background()
+	kv := store.NewMemory()
+
+	srv, addr, events := startServer(t)
+	if err := srv.PersistState(ctx, kv); err != nil {
+		t.Fatal(err)
+	}
+	conn := dialLogin(t, addr, testIMEI, events)
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	next(t, events, "location")
+	if err := srv.SendCommand(testIMEI, 7, "WHERE#"); err != nil {
+		t.Fatal(err)
+	}
+	readTCP(t, conn)
+	want, ok := srv.LastPosition(testIMEI)
+	if !ok {
+		t.Fatal("Expected a position")
+	}
+
+	// A restarted server knows the position and still waits for the command
+	restarted := New()
+	t.Cleanup(func() { restarted.Close() })
+	if err := restarted.PersistState(ctx, kv); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := restarted.LastPosition(testIMEI)
+	if !ok || !got.Time.Equal(want.Time) || got.Latitude != want.Latitude || got.Longitude != want.Longitude {
+		t.Errorf("Expected position %+v, got %+v (ok=%v)", want, got, ok)
+	}
+	status, ok := restarted.CommandStatus(testIMEI, 7)
+	if !ok || status.Outcome != CommandPending || status.Command != "WHERE#" {
+		t.Errorf("Expected pending WHERE#, got %+v (ok=%v)", status, ok)
+	}
+
+	// Closed servers do not load state
+	closed := New()
+	closed.Close()
+	if err := closed.PersistState(ctx, kv); err != ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Position is the last known position of a device
+type Position struct {
+	IMEI string `json:"imei"`
+	export.Position
+
+	// Type is the type of the packet that carried the position
+	Type string `json:"type"`
+
+	// Time is the device (GPS) time of the position
+	Time time.Time `json:"time"`
+
+	// ReceivedAt is when the server received the packet
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// LastPosition returns the last known position of a device.
+// Positions are kept after the device disconnects.
+func (s *Server) LastPosition(imei string) (Position, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos, ok := s.positions[imei]
+	return pos, ok
+}
+
+// LastPositions returns the last known position of every device, sorted by IMEI
+func (s *Server) LastPositions() []Position {
+	s.mu.Lock()
+	out := make([]Position, 0, len(s.positions))
+	for _, pos := range s.positions {
+		out = append(out, pos)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].IMEI < out[j].IMEI })
+	return out
+}
+
+// trackPosition records the position carried by p for a logged-in device
+func (s *Server) trackPosition(sess *Session, p packet.Packet) {
+	lp, ok := p.(packet.PacketWithLocation)
+	if !ok || !lp.HasLocation() {
+		return
+	}
+	imei := sess.IMEI()
+	if imei == "" {
+		return
+	}
+
+	rec := export.FromPacket(p)
+	if rec.Position == nil {
+		return
+	}
+	pos := Position{
+		IMEI:       imei,
+		Position:   *rec.Position,
+		Type:       rec.Type,
+		ReceivedAt: time.Now(),
+	}
+	if rec.Time != nil {
+		pos.Time = *rec.Time
+	}
+
+	s.mu.Lock()
+	s.positions[imei] = pos
+	s.mu.Unlock()
+	s.persistPosition(sess, imei)
+}
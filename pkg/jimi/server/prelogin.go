@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// DefaultLoginWait is how long PreLoginWait waits for a login when the
+// policy sets no duration
+const DefaultLoginWait = 30 * time.Second
+
+// ErrNoLogin is reported when a TCP connection is closed because it did not
+// start with a valid login (see WithPreLoginPolicy)
+var ErrNoLogin = errors.New("server: no valid login")
+
+// PreLoginAction is what the server does with a connection whose first
+// packet is not a valid login
+type PreLoginAction int
+
+const (
+	// PreLoginAnonymous handles packets before the login like any other,
+	// as without a policy: the session stays anonymous until a login
+	PreLoginAnonymous PreLoginAction = iota
+
+	// PreLoginDrop closes the connection at the first undecodable data or
+	// packet other than a login
+	PreLoginDrop
+
+	// PreLoginWait discards everything before a login, without responses or
+	// callbacks, and closes the connection if no login arrives in time
+	PreLoginWait
+)
+
+// String returns the action name
+func (a PreLoginAction) String() string {
+	switch a {
+	case PreLoginAnonymous:
+		return "anonymous"
+	case PreLoginDrop:
+		return "drop"
+	case PreLoginWait:
+		return "wait"
+	}
+	return fmt.Sprintf("PreLoginAction(%d)", int(a))
+}
+
+// ParsePreLoginAction parses an action name (see PreLoginAction.String)
+func ParsePreLoginAction(s string) (PreLoginAction, error) {
+	for a := PreLoginAnonymous; a <= PreLoginWait; a++ {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown pre-login action %q", s)
+}
+
+// PreLoginPolicy decides what happens to connections that do not start
+// with a valid login
+type PreLoginPolicy struct {
+	Action PreLoginAction
+
+	// Wait is how long PreLoginWait waits for a login after the connection
+	// is accepted (0 means DefaultLoginWait)
+	Wait time.Duration
+}
+
+// WithPreLoginPolicy sets what happens to TCP connections whose first packet
+// is not a valid login, e.g. port scanners or devices of another protocol
+// sending data that happens to decode. UDP peers cannot be closed; their
+// packets before a login are discarded unless the action is PreLoginAnonymous.
+func WithPreLoginPolicy(p PreLoginPolicy) Option {
+	return func(s *Server) {
+		if p.Action == PreLoginWait && p.Wait <= 0 {
+			p.Wait = DefaultLoginWait
+		}
+		s.preLogin = p
+	}
+}
+
+// awaitingLogin reports whether the policy holds back the packets of sess
+func (s *Server) awaitingLogin(sess *Session) bool {
+	return s.preLogin.Action != PreLoginAnonymous && sess.IMEI() == ""
+}
+
+// checkPreLogin applies the pre-login policy to a packet other than a login;
+// returns false if the packet must not be handled
+func (s *Server) checkPreLogin(sess *Session, p packet.Packet) bool {
+	if !s.awaitingLogin(sess) {
+		return true
+	}
+	if s.preLogin.Action == PreLoginDrop && sess.conn != nil {
+		s.reject(sess, fmt.Errorf("%w: first packet is protocol 0x%02X", ErrNoLogin, p.ProtocolNumber()))
+	}
+	return false
+}
+
+// checkPreLoginData applies the pre-login policy to data that did not
+// decode; returns false if the connection was closed
+func (s *Server) checkPreLoginData(sess *Session, err error) bool {
+	if s.preLogin.Action != PreLoginDrop || !s.awaitingLogin(sess) {
+		return true
+	}
+	s.reject(sess, fmt.Errorf("%w: %w", ErrNoLogin, err))
+	return false
+}
+
+// readDeadline returns the read deadline of a TCP session: the read timeout,
+// shortened to the login wait before a login (zero for none)
+func (s *Server) readDeadline(sess *Session) time.Time {
+	var deadline time.Time
+	if s.readTimeout > 0 {
+		deadline = time.Now().Add(s.readTimeout)
+	}
+	if s.preLogin.Action == PreLoginWait && sess.IMEI() == "" {
+		wait := sess.connectedAt.Add(s.preLogin.Wait)
+		if deadline.IsZero() || wait.Before(deadline) {
+			deadline = wait
+		}
+	}
+	return deadline
+}
+
+// loginTimedOut reports whether a read error is the login wait expiring
+func (s *Server) loginTimedOut(sess *Session, err error) bool {
+	var ne net.Error
+	return s.preLogin.Action == PreLoginWait && sess.IMEI() == "" &&
+		errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+)
+
+// dialPreLogin starts a server with the pre-login policy and connects to it;
+// errors reported to OnError are sent to the returned channel
+func dialPreLogin(t *testing.T, p PreLoginPolicy, opts ...Option) (net.Conn, chan event, chan error) {
+	t.Helper()
+	srv, addr, events := startServer(t, append(opts, WithPreLoginPolicy(p))...)
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, events, errs
+}
+
+// expectNoLogin waits for the connection to be closed with ErrNoLogin
+func expectNoLogin(t *testing.T, conn net.Conn, events chan event, errs chan error) {
+	t.Helper()
+	next(t, events, "disconnect")
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrNoLogin) {
+			t.Errorf("Expected ErrNoLogin, got %v", err)
+		}
+	default:
+		t.Error("Expected ErrNoLogin reported")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Errorf("Expected the connection closed without response, got %d bytes", n)
+	}
+}
+
+func TestServer_PreLoginAnonymous(t *testing.T) {
+	conn, _, _ := dialPreLogin(t, PreLoginPolicy{})
+	conn.Write(mustHex(t, heartbeatHex))
+	readTCP(t, conn)
+}
+
+func TestServer_PreLoginDrop(t *testing.T) {
+	t.Run("packet", func(t *testing.T) {
+		conn, events, errs := dialPreLogin(t, PreLoginPolicy{Action: PreLoginDrop})
+		conn.Write(mustHex(t, heartbeatHex))
+		expectNoLogin(t, conn, events, errs)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		conn, events, errs := dialPreLogin(t, PreLoginPolicy{Action: PreLoginDrop},
+			WithDecoderOptions(jimi.WithStrictMode(true)))
+		conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		next(t, events, "disconnect")
+		var noLogin bool
+		for len(errs) > 0 {
+			noLogin = noLogin || errors.Is(<-errs, ErrNoLogin)
+		}
+		if !noLogin {
+			t.Error("Expected ErrNoLogin reported")
+		}
+	})
+
+	t.Run("login first", func(t *testing.T) {
+		conn, events, _ := dialPreLogin(t, PreLoginPolicy{Action: PreLoginDrop})
+		conn.Write(mustHex(t, loginHex))
+		next(t, events, "login")
+		readTCP(t, conn)
+		conn.Write(mustHex(t, heartbeatHex))
+		readTCP(t, conn)
+	})
+}
+
+func TestServer_PreLoginWait(t *testing.T) {
+	t.Run("retry", func(t *testing.T) {
+		conn, events, _ := dialPreLogin(t, PreLoginPolicy{Action: PreLoginWait, Wait: time.Second})
+		conn.Write(mustHex(t, heartbeatHex))
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if n, err := conn.Read(make([]byte, 64)); err == nil {
+			t.Fatalf("Expected no response before login, got %d bytes", n)
+		}
+		conn.Write(mustHex(t, loginHex))
+		next(t, events, "login")
+		readTCP(t, conn)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		conn, events, errs := dialPreLogin(t, PreLoginPolicy{Action: PreLoginWait, Wait: 100 * time.Millisecond})
+		conn.Write(mustHex(t, heartbeatHex))
+		expectNoLogin(t, conn, events, errs)
+	})
+}
+
+func TestParsePreLoginAction(t *testing.T) {
+	for a := PreLoginAnonymous; a <= PreLoginWait; a++ {
+		if got, err := ParsePreLoginAction(a.String()); err != nil || got != a {
+			t.Errorf("ParsePreLoginAction(%q) = %v, %v", a.String(), got, err)
+		}
+	}
+	if _, err := ParsePreLoginAction("ignore"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
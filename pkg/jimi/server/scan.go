@@ -0,0 +1,239 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// maxScanSources bounds the sources tracked by the accept rate limit and
+// the decode failure counts; beyond it, sources with a full bucket are
+// forgotten and the failure counts start over
+const maxScanSources = 4096
+
+// DefaultBanDuration is how long ScanGuard.BanAfter bans a source by default
+const DefaultBanDuration = time.Hour
+
+// ScanGuard protects the TCP port against port scanners and connection
+// floods. The server never sends anything before it decodes a packet, so
+// scanners get no banner to fingerprint; the guard also frees the
+// connections they leave open.
+type ScanGuard struct {
+	// SilentTimeout closes connections that send nothing for this long
+	// after being accepted (0 disables)
+	SilentTimeout time.Duration
+
+	// CloseNonProtocol closes connections whose first bytes are not a packet
+	// start bit (0x7878 or 0x7979), e.g. HTTP requests or TLS handshakes
+	CloseNonProtocol bool
+
+	// AcceptRate limits the connections accepted from each source IP to this
+	// many per second, in bursts of up to AcceptBurst (0 disables); others
+	// are closed at once
+	AcceptRate  float64
+	AcceptBurst int
+
+	// MaxConns limits the open TCP connections, and MaxConnsPerIP those
+	// from each source IP (0 disables); others are closed at once
+	MaxConns      int
+	MaxConnsPerIP int
+
+	// BanAfter bans a source IP for BanDuration (default DefaultBanDuration)
+	// after this many consecutive failures to decode what it sent, counted
+	// over all its connections (0 disables). A decoded packet resets the
+	// count; with CloseNonProtocol, a non-protocol connection is a failure.
+	BanAfter    int
+	BanDuration time.Duration
+}
+
+// ScanStats counts the connections closed by the scan guard
+type ScanStats struct {
+	Silent      uint64 `json:"silent"`
+	NonProtocol uint64 `json:"non_protocol"`
+	Throttled   uint64 `json:"throttled"`
+	Overloaded  uint64 `json:"overloaded"` // over MaxConns or MaxConnsPerIP
+	Banned      uint64 `json:"banned"`     // refused from banned sources
+	AutoBans    uint64 `json:"auto_bans"`  // sources banned by BanAfter
+}
+
+// scanState is the state of the scan guard and of the ban list
+type scanState struct {
+	mu        sync.Mutex
+	stats     ScanStats
+	buckets   map[string]*acceptBucket // by source IP
+	conns     int                      // open connections, with connection limits
+	connsByIP map[netip.Addr]int
+	failures  map[netip.Addr]int // consecutive decode failures by source IP
+	bans      map[netip.Prefix]Ban
+	rangeBans int // bans of more than one address
+}
+
+// acceptBucket is the token bucket of one source
+type acceptBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// WithScanGuard closes silent, non-protocol and flooding TCP connections.
+// They are not reported to OnError, so scanners do not flood the logs;
+// ScanStats counts them instead.
+func WithScanGuard(g ScanGuard) Option {
+	return func(s *Server) {
+		if g.AcceptRate > 0 && g.AcceptBurst < 1 {
+			g.AcceptBurst = 1
+		}
+		if g.BanAfter > 0 && g.BanDuration <= 0 {
+			g.BanDuration = DefaultBanDuration
+		}
+		s.scanGuard = &g
+	}
+}
+
+// ScanStats returns the connections closed by the scan guard so far
+func (s *Server) ScanStats() ScanStats {
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	return s.scan.stats
+}
+
+// allowAccept applies the ban list, the accept rate limit and the
+// connection limits to a new connection. An accepted connection must be
+// released with releaseAccept when it ends.
+func (s *Server) allowAccept(addr net.Addr) bool {
+	ip, _ := parseRemoteAddr(addr.String())
+	now := time.Now()
+
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	if s.bannedLocked(ip, now) {
+		s.scan.stats.Banned++
+		return false
+	}
+	g := s.scanGuard
+	if g == nil {
+		return true
+	}
+	if g.AcceptRate > 0 && !s.takeTokenLocked(addr, now) {
+		s.scan.stats.Throttled++
+		return false
+	}
+	if !s.limitsConns() {
+		return true
+	}
+	if g.MaxConns > 0 && s.scan.conns >= g.MaxConns || g.MaxConnsPerIP > 0 && s.scan.connsByIP[ip] >= g.MaxConnsPerIP {
+		s.scan.stats.Overloaded++
+		return false
+	}
+	if s.scan.connsByIP == nil {
+		s.scan.connsByIP = make(map[netip.Addr]int)
+	}
+	s.scan.conns++
+	s.scan.connsByIP[ip]++
+	return true
+}
+
+// releaseAccept counts the end of a connection accepted by allowAccept
+func (s *Server) releaseAccept(addr net.Addr) {
+	if !s.limitsConns() {
+		return
+	}
+	ip, _ := parseRemoteAddr(addr.String())
+
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	s.scan.conns--
+	if s.scan.connsByIP[ip]--; s.scan.connsByIP[ip] <= 0 {
+		delete(s.scan.connsByIP, ip)
+	}
+}
+
+// limitsConns reports whether the guard limits open connections
+func (s *Server) limitsConns() bool {
+	return s.scanGuard != nil && (s.scanGuard.MaxConns > 0 || s.scanGuard.MaxConnsPerIP > 0)
+}
+
+// takeTokenLocked takes a token from the bucket of the source of addr,
+// returning false if it is empty. Caller must hold s.scan.mu.
+func (s *Server) takeTokenLocked(addr net.Addr, now time.Time) bool {
+	g := s.scanGuard
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	if s.scan.buckets == nil {
+		s.scan.buckets = make(map[string]*acceptBucket)
+	}
+	b, ok := s.scan.buckets[host]
+	if !ok {
+		if len(s.scan.buckets) >= maxScanSources {
+			s.pruneBuckets(now)
+		}
+		b = &acceptBucket{tokens: float64(g.AcceptBurst), last: now}
+		s.scan.buckets[host] = b
+	}
+	b.tokens = min(float64(g.AcceptBurst), b.tokens+now.Sub(b.last).Seconds()*g.AcceptRate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pruneBuckets forgets the sources whose bucket has refilled
+func (s *Server) pruneBuckets(now time.Time) {
+	refill := time.Duration(float64(s.scanGuard.AcceptBurst) / s.scanGuard.AcceptRate * float64(time.Second))
+	for host, b := range s.scan.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(s.scan.buckets, host)
+		}
+	}
+}
+
+// silentDeadline shortens the read deadline of a connection that has not
+// sent anything yet to the silent timeout
+func (s *Server) silentDeadline(sess *Session, deadline time.Time) time.Time {
+	if s.scanGuard == nil || s.scanGuard.SilentTimeout <= 0 {
+		return deadline
+	}
+	silent := sess.connectedAt.Add(s.scanGuard.SilentTimeout)
+	if deadline.IsZero() || silent.Before(deadline) {
+		return silent
+	}
+	return deadline
+}
+
+// isSilent reports whether a read error of a connection that has not sent
+// anything is the silent timeout, and counts it
+func (s *Server) isSilent(err error) bool {
+	var ne net.Error
+	if s.scanGuard == nil || s.scanGuard.SilentTimeout <= 0 || !errors.As(err, &ne) || !ne.Timeout() {
+		return false
+	}
+	s.scan.mu.Lock()
+	s.scan.stats.Silent++
+	s.scan.mu.Unlock()
+	return true
+}
+
+// isNonProtocol reports whether the first bytes of a connection do not
+// start a packet, when the guard closes such connections, and counts it
+func (s *Server) isNonProtocol(data []byte) bool {
+	if s.scanGuard == nil || !s.scanGuard.CloseNonProtocol || isPacketStart(data) {
+		return false
+	}
+	s.scan.mu.Lock()
+	s.scan.stats.NonProtocol++
+	s.scan.mu.Unlock()
+	return true
+}
+
+// isPacketStart reports whether data begins with (a prefix of) a start bit
+func isPacketStart(data []byte) bool {
+	if len(data) == 0 || data[0] != 0x78 && data[0] != 0x79 {
+		return false
+	}
+	return len(data) == 1 || data[1] == data[0]
+}
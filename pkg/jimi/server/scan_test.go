@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_ScanGuard(t *testing.T) {
+	srv, addr, events := startServer(t, WithScanGuard(ScanGuard{
+		SilentTimeout:    50 * time.Millisecond,
+		CloseNonProtocol: true,
+	}))
+	errs := make(chan error, 8)
+	srv.OnError(func(_ *Session, err error) { errs <- err })
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	closed := func(conn net.Conn) {
+		t.Helper()
+		next(t, events, "disconnect")
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if n, err := conn.Read(make([]byte, 64)); err == nil {
+			t.Errorf("Expected the connection closed without response, got %d bytes", n)
+		}
+	}
+
+	closed(dial())
+
+	scanner := dial()
+	scanner.Write([]byte("GET / HTTP/1.1\r\nHost: tracker\r\n\r\n"))
+	closed(scanner)
+
+	device := dial()
+	device.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	readTCP(t, device)
+
+	if got := srv.ScanStats(); got != (ScanStats{Silent: 1, NonProtocol: 1}) {
+		t.Errorf("Unexpected stats %+v", got)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Expected scanners not reported, got %v", <-errs)
+	}
+}
+
+func TestServer_AcceptRate(t *testing.T) {
+	srv := New(WithScanGuard(ScanGuard{AcceptRate: 1000, AcceptBurst: 2}))
+	a := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1000}
+
+	for i, want := range []bool{true, true, false} {
+		if got := srv.allowAccept(a); got != want {
+			t.Errorf("Accept %d: expected %v, got %v", i, want, got)
+		}
+	}
+	if !srv.allowAccept(b) {
+		t.Error("Expected another source accepted")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !srv.allowAccept(a) {
+		t.Error("Expected the source accepted after the bucket refilled")
+	}
+	if got := srv.ScanStats().Throttled; got != 1 {
+		t.Errorf("Expected 1 throttled, got %d", got)
+	}
+
+	if !New().allowAccept(a) {
+		t.Error("Expected accepts unlimited without a guard")
+	}
+}
+
+func TestIsPacketStart(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{[]byte{0x78, 0x78, 0x11}, true},
+		{[]byte{0x79, 0x79}, true},
+		{[]byte{0x78}, true},
+		{[]byte{0x78, 0x79}, false},
+		{[]byte{0x16, 0x03, 0x01}, false}, // TLS handshake
+		{[]byte("SSH-2.0"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isPacketStart(tt.data); got != tt.want {
+			t.Errorf("isPacketStart(% X) = %v, expected %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,837 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/geocoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Default timeouts
+const (
+	// DefaultReadTimeout closes connections (and expires UDP sessions) idle for this long
+	DefaultReadTimeout = 5 * time.Minute
+
+	// DefaultWriteTimeout bounds each response write
+	DefaultWriteTimeout = 10 * time.Second
+
+	// DefaultHandshakeTimeout bounds identity resolution (e.g. the TLS handshake)
+	DefaultHandshakeTimeout = 10 * time.Second
+)
+
+// readBufferSize is the TCP read chunk size
+const readBufferSize = 1024
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("server: closed")
+
+// ErrPassive is returned when sending to a device of a passive server
+var ErrPassive = errors.New("server: passive mode, nothing is sent")
+
+// Direction tells whether raw data was received or sent
+type Direction string
+
+const (
+	// RX is data received from a device
+	RX Direction = "RX"
+
+	// TX is data sent to a device
+	TX Direction = "TX"
+)
+
+// ResponsePolicy selects which packets the server acknowledges automatically
+type ResponsePolicy struct {
+	// Login acknowledges login packets (0x01); devices retry until acknowledged
+	Login bool
+
+	// Heartbeat acknowledges heartbeat packets (0x13)
+	Heartbeat bool
+
+	// Alarm acknowledges alarm packets (0x26, 0x27, 0xA4)
+	Alarm bool
+
+	// TimeCalibration answers time requests (0x8A) with the current time, in
+	// UTC unless the encoder has a timezone (see encoder.WithTimezone)
+	TimeCalibration bool
+
+	// AckAlarm, if set, decides per alarm packet whether to acknowledge it
+	// (e.g. to skip ACC on/off pseudo-alarms). Only used when Alarm is true.
+	AckAlarm func(p packet.Packet) bool
+}
+
+// DefaultResponsePolicy acknowledges everything the protocol expects
+func DefaultResponsePolicy() ResponsePolicy {
+	return ResponsePolicy{
+		Login:           true,
+		Heartbeat:       true,
+		Alarm:           true,
+		TimeCalibration: true,
+	}
+}
+
+// Server is an embeddable tracker server for TCP and UDP devices.
+//
+// Example usage:
+//
+//	srv := server.New(server.WithReadTimeout(10 * time.Minute))
+//	srv.OnLogin(func(s *server.Session, p *packet.LoginPacket) {
+//	    log.Printf("%s logged in from %s", p.GetIMEI(), s.RemoteAddr())
+//	})
+//	srv.OnLocation(func(s *server.Session, p packet.Packet) {
+//	    store.SavePosition(s.IMEI(), p)
+//	})
+//	log.Fatal(srv.ListenAndServe(":5023"))
+//
+// Callbacks run in the goroutine of the connection (or of the UDP socket)
+// that received the packet, in the order OnPacket, then the typed callback,
+// then the automatic response. They must not block for long.
+type Server struct {
+	decoderOpts  []jimi.Option
+	encoder      *encoder.Encoder
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	policy       ResponsePolicy
+	identify     IdentityFunc
+	auth         *DeviceAuth
+	geo          *GeoPolicy
+	collision    *CollisionPolicy
+	preLogin     PreLoginPolicy
+	resolver     *geocoder.Resolver
+	scanGuard    *ScanGuard
+	scan         scanState
+	passive      bool
+	tlsConfig    *tls.Config
+
+	motionInterval int           // WithMotionBoost interval (0 disables)
+	motionDuration time.Duration // WithMotionBoost duration
+
+	offlineWindow time.Duration // WithOfflineWatchdog window (0 disables)
+
+	persist        PersistFunc   // WithDurableAck store (nil acknowledges at once)
+	persistTimeout time.Duration // WithDurableAck timeout
+
+	escalation CommandEscalation // WithCommandEscalation settings
+
+	cbMu         sync.RWMutex
+	onConnect    func(*Session)
+	onDisconnect func(*Session)
+	onLogin      func(*Session, *packet.LoginPacket)
+	onLocation   func(*Session, packet.Packet)
+	onAlarm      func(*Session, packet.Packet)
+	onPacket     func(*Session, packet.Packet)
+	onRaw        func(*Session, Direction, []byte)
+	onError      func(*Session, error)
+	onOffline    func(Presence)
+	onOnline     func(Presence)
+
+	mu          sync.Mutex
+	sessions    map[string]*Session          // by IMEI
+	positions   map[string]Position          // last position by IMEI, kept after disconnect
+	configs     map[string]ConfigSnapshot    // config snapshot by IMEI, kept after disconnect
+	boosts      map[string]*boostState       // boosts by IMEI, until the restore is acknowledged
+	alarms      map[string]time.Time         // last alarm by IMEI
+	quarantined map[string]bool              // IMEIs quarantined after a login collision
+	presence    map[string]*presenceState    // offline watchdog by IMEI, kept after disconnect
+	migration   *migrationState              // last migration, nil if none
+	commands    map[commandKey]*commandState // command outcomes by IMEI and server flag
+	active      map[*Session]bool
+	listeners   map[io.Closer]bool
+	closed      bool
+
+	streamMu    sync.Mutex
+	subs        map[*Subscription]bool
+	eventID     uint64  // ID of the last published event
+	history     []Event // recent events, oldest first
+	historySize int
+	epoch       string   // identifies the feed in Events cursors
+	eventKV     store.KV // PersistEvents store, nil if not persisted
+
+	state     *stateStores               // PersistState stores, nil if not persisted
+	persistMu [persistStripes]sync.Mutex // orders the state writes of each device
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithDecoderOptions sets the options of the per-session decoders
+func WithDecoderOptions(opts ...jimi.Option) Option {
+	return func(s *Server) {
+		s.decoderOpts = append(s.decoderOpts, opts...)
+	}
+}
+
+// WithEncoder sets the encoder used for responses. Without a CRC of its
+// own, it takes the CRC variant of the decoder options (jimi.WithCRCVariant).
+func WithEncoder(e *encoder.Encoder) Option {
+	return func(s *Server) {
+		s.encoder = e
+	}
+}
+
+// WithReadTimeout sets the idle timeout (0 disables)
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the per-write timeout for TCP (0 disables)
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = d
+	}
+}
+
+// WithResponsePolicy sets which packets are acknowledged automatically
+func WithResponsePolicy(p ResponsePolicy) Option {
+	return func(s *Server) {
+		s.policy = p
+	}
+}
+
+// WithIdentityFunc sets how TCP connections are identified (default ConnIdentity)
+func WithIdentityFunc(fn IdentityFunc) Option {
+	return func(s *Server) {
+		s.identify = fn
+	}
+}
+
+// WithDeviceAuth restricts logins to the IMEIs allowed for each connection's identity
+func WithDeviceAuth(a *DeviceAuth) Option {
+	return func(s *Server) {
+		s.auth = a
+	}
+}
+
+// WithGeoPolicy denies or flags connections by the country and ASN of their address
+func WithGeoPolicy(p *GeoPolicy) Option {
+	return func(s *Server) {
+		s.geo = p
+	}
+}
+
+// WithPassive makes the server never send anything: no automatic responses
+// and no commands. Packets are still decoded and passed to the callbacks, so
+// the server can record what devices do when they are not acknowledged
+// (e.g. for protocol research on a shared port). Connections are still closed
+// by the read timeout, device auth and geo policy.
+func WithPassive() Option {
+	return func(s *Server) {
+		s.passive = true
+	}
+}
+
+// WithTLSConfig makes Serve and ListenAndServe accept TCP connections over
+// TLS. With client certificates (tls.RequireAndVerifyClientCert), the
+// certificate common name is the connection identity (see ConnIdentity and
+// WithDeviceAuth). UDP is not affected.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = c
+	}
+}
+
+// New creates a server
+func New(opts ...Option) *Server {
+	s := &Server{
+		encoder:      encoder.New(),
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		policy:       DefaultResponsePolicy(),
+		identify:     ConnIdentity,
+		sessions:     make(map[string]*Session),
+		positions:    make(map[string]Position),
+		configs:      make(map[string]ConfigSnapshot),
+		boosts:       make(map[string]*boostState),
+		alarms:       make(map[string]time.Time),
+		quarantined:  make(map[string]bool),
+		presence:     make(map[string]*presenceState),
+		subs:         make(map[*Subscription]bool),
+		historySize:  DefaultEventHistory,
+		epoch:        newEpoch(),
+		active:       make(map[*Session]bool),
+		listeners:    make(map[io.Closer]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Devices with another CRC variant expect it in the responses too
+	if crc := jimi.NewDecoder(s.decoderOpts...).GetOptions().CRC; crc != nil && s.encoder.CRC == nil {
+		enc := *s.encoder
+		enc.CRC = crc
+		s.encoder = &enc
+	}
+	return s
+}
+
+// Passive reports whether the server was created with WithPassive
+func (s *Server) Passive() bool {
+	return s.passive
+}
+
+// OnConnect is called when a TCP connection is accepted or a UDP peer is first seen
+func (s *Server) OnConnect(fn func(*Session)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onConnect = fn
+}
+
+// OnDisconnect is called when a TCP connection closes or a UDP session expires
+func (s *Server) OnDisconnect(fn func(*Session)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onDisconnect = fn
+}
+
+// OnLogin is called for login packets, after the session is bound to the IMEI
+func (s *Server) OnLogin(fn func(*Session, *packet.LoginPacket)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onLogin = fn
+}
+
+// OnLocation is called for GPS location packets (2G and 4G)
+func (s *Server) OnLocation(fn func(*Session, packet.Packet)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onLocation = fn
+}
+
+// OnAlarm is called for alarm packets.
+// ACC status packets (see jimi.WithACCAlarmsAsStatus) are not alarms.
+func (s *Server) OnAlarm(fn func(*Session, packet.Packet)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onAlarm = fn
+}
+
+// OnPacket is called for every decoded packet
+func (s *Server) OnPacket(fn func(*Session, packet.Packet)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onPacket = fn
+}
+
+// OnRaw is called with raw data received from (RX) or sent to (TX) a device.
+// For UDP, RX data is reported per decoded packet.
+func (s *Server) OnRaw(fn func(*Session, Direction, []byte)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onRaw = fn
+}
+
+// OnError is called for decode, read and write errors
+func (s *Server) OnError(fn func(*Session, error)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onError = fn
+}
+
+// ListenAndServe listens on the TCP address and serves connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts TCP connections from l until Close is called. With
+// WithTLSConfig, l is wrapped in a TLS listener, so it must not be one already.
+func (s *Server) Serve(l net.Listener) error {
+	if s.tlsConfig != nil {
+		l = tls.NewListener(l, s.tlsConfig)
+	}
+	if !s.track(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !s.allowAccept(conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.releaseAccept(conn.RemoteAddr())
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// ServeUDP reads UDP datagrams from conn until Close is called.
+// Sessions are keyed by IMEI after login (see UDPServer).
+func (s *Server) ServeUDP(conn *net.UDPConn) error {
+	if !s.track(conn) {
+		conn.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(conn)
+
+	var mu sync.Mutex
+	bySession := make(map[*UDPSession]*Session)
+
+	lookup := func(us *UDPSession) *Session {
+		mu.Lock()
+		defer mu.Unlock()
+		if sess, ok := bySession[us]; ok {
+			return sess
+		}
+		sess := s.newSession("udp", us.Addr().String(), nil, us.Reply)
+		bySession[us] = sess
+		if s.checkGeo(sess) {
+			s.connect(sess)
+		}
+		return sess
+	}
+
+	udp := NewUDPServer(conn, jimi.NewDecoder(s.decoderOpts...),
+		func(us *UDPSession, p packet.Packet) {
+			sess := lookup(us)
+			if sess.geo.Denied {
+				return
+			}
+			s.raw(sess, RX, p.Raw())
+			s.handlePacket(sess, p)
+		},
+		WithUDPIdleTimeout(s.readTimeout),
+		WithUDPErrorHandler(func(addr *net.UDPAddr, err error) {
+			s.report(nil, fmt.Errorf("udp %s: %w", addr, err))
+		}),
+		WithUDPCloseHandler(func(us *UDPSession) {
+			mu.Lock()
+			sess, ok := bySession[us]
+			delete(bySession, us)
+			mu.Unlock()
+			if ok && !sess.geo.Denied {
+				s.disconnect(sess)
+			}
+		}),
+	)
+
+	err := udp.Serve()
+	if s.isClosed() {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// Close stops all listeners, closes all TCP connections and ends all
+// event subscriptions
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	listeners := make([]io.Closer, 0, len(s.listeners))
+	for l := range s.listeners {
+		listeners = append(listeners, l)
+	}
+	var conns []net.Conn
+	for sess := range s.active {
+		if sess.conn != nil {
+			conns = append(conns, sess.conn)
+		}
+	}
+	for _, b := range s.boosts {
+		b.timer.Stop()
+	}
+	for _, p := range s.presence {
+		p.timer.Stop()
+	}
+	if s.migration != nil {
+		s.migration.cancel()
+	}
+	for _, c := range s.commands {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, l := range listeners {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	s.closeSubscriptions()
+	return firstErr
+}
+
+// Session returns the session of a logged-in device
+func (s *Server) Session(imei string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[imei]
+	return sess, ok
+}
+
+// Sessions returns all active sessions sorted by identifier
+func (s *Server) Sessions() []*Session {
+	s.mu.Lock()
+	out := make([]*Session, 0, len(s.active))
+	for sess := range s.active {
+		out = append(out, sess)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Identifier() < out[j].Identifier()
+	})
+	return out
+}
+
+// Send writes raw data to a logged-in device
+func (s *Server) Send(imei string, data []byte) error {
+	sess, ok := s.Session(imei)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDevice, imei)
+	}
+	return sess.Send(data)
+}
+
+// SendCommand sends an online command (0x80) to a logged-in device
+func (s *Server) SendCommand(imei string, serverFlag uint32, command string) error {
+	sess, ok := s.Session(imei)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDevice, imei)
+	}
+	return sess.SendCommand(serverFlag, command)
+}
+
+// serveConn runs the read loop of one TCP connection
+func (s *Server) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultHandshakeTimeout)
+	identity, err := s.identify(ctx, conn)
+	cancel()
+	if err != nil {
+		if !s.isClosed() {
+			s.report(nil, fmt.Errorf("tcp %s: %w", conn.RemoteAddr(), err))
+		}
+		conn.Close()
+		return
+	}
+
+	sess := s.newSession("tcp", conn.RemoteAddr().String(), conn, nil)
+	sess.identity = identity
+	if !s.checkGeo(sess) {
+		conn.Close()
+		return
+	}
+	sess.write = func(data []byte) error {
+		if s.writeTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+		}
+		_, err := conn.Write(data)
+		return err
+	}
+
+	s.connect(sess)
+	defer func() {
+		conn.Close()
+		s.disconnect(sess)
+	}()
+
+	device := jimi.NewDecoder(s.decoderOpts...).NewDeviceSession()
+	buffer := make([]byte, 0, 4*readBufferSize)
+	readBuf := make([]byte, readBufferSize)
+
+	received := false
+	for {
+		deadline := s.readDeadline(sess)
+		if !received {
+			deadline = s.silentDeadline(sess, deadline)
+		}
+		sess.setReadDeadline(deadline)
+
+		n, err := conn.Read(readBuf)
+		if err != nil {
+			switch {
+			case !received && s.isSilent(err):
+			case sess.isRejected(), sess.isMigrating():
+			case s.loginTimedOut(sess, err):
+				s.report(sess, fmt.Errorf("%w: none within %v", ErrNoLogin, s.preLogin.Wait))
+			case err != io.EOF && !s.isClosed():
+				s.report(sess, err)
+			}
+			return
+		}
+		if n == 0 {
+			continue
+		}
+
+		data := readBuf[:n]
+		if !received && s.isNonProtocol(data) {
+			s.decodeFailed(sess)
+			return
+		}
+		received = true
+		s.raw(sess, RX, data)
+		buffer = append(buffer, data...)
+
+		packets, residue, err := device.DecodeStream(buffer)
+		if err != nil {
+			s.report(sess, err)
+			if s.decodeFailed(sess) {
+				return
+			}
+		} else if len(packets) > 0 {
+			s.decodeSucceeded(sess)
+		}
+		buffer = residue
+
+		for _, p := range packets {
+			s.handlePacket(sess, p)
+		}
+		if err != nil && !s.checkPreLoginData(sess, err) || sess.isRejected() {
+			return
+		}
+	}
+}
+
+// handlePacket runs callbacks and the automatic response for one packet
+func (s *Server) handlePacket(sess *Session, p packet.Packet) {
+	sess.mu.Lock()
+	sess.lastSeen = time.Now()
+	sess.packetCount++
+	sess.mu.Unlock()
+	if sess.isRejected() {
+		return
+	}
+
+	login, isLogin := p.(*packet.LoginPacket)
+	if isLogin {
+		if !s.authorize(sess, login.GetIMEI()) || !s.checkCollision(sess, login.GetIMEI()) {
+			return
+		}
+		s.bind(sess, login.GetIMEI())
+	} else if s.auth != nil && sess.IMEI() == "" || !s.checkPreLogin(sess, p) {
+		return
+	}
+	s.trackPresence(sess)
+	s.trackPosition(sess, p)
+	s.trackConfig(sess, p)
+	s.trackCommandResponse(sess, p)
+	if isAlarm(p) {
+		s.trackAlarm(sess)
+	}
+	s.publish(sess, p)
+
+	s.cbMu.RLock()
+	onPacket, onLogin, onLocation, onAlarm := s.onPacket, s.onLogin, s.onLocation, s.onAlarm
+	s.cbMu.RUnlock()
+
+	if onPacket != nil {
+		onPacket(sess, p)
+	}
+
+	switch {
+	case isLogin:
+		if onLogin != nil {
+			onLogin(sess, login)
+		}
+	case packet.IsLocationPacket(p):
+		if onLocation != nil {
+			onLocation(sess, p)
+		}
+	case isAlarm(p):
+		if onAlarm != nil {
+			onAlarm(sess, p)
+		}
+	}
+
+	if resp := s.response(p); resp != nil && s.persisted(sess, p) {
+		if err := sess.Send(resp); err != nil {
+			s.report(sess, err)
+		}
+	}
+	s.resolveAddress(sess, p)
+	s.checkBoost(sess, p)
+}
+
+// authorize checks a login against the device auth; a rejected TCP session is closed
+func (s *Server) authorize(sess *Session, imei string) bool {
+	if s.auth == nil {
+		return true
+	}
+	err := s.auth.Authorize(sess.Identity(), imei)
+	if err == nil {
+		return true
+	}
+	s.reject(sess, err)
+	return false
+}
+
+// isAlarm reports whether p is an alarm (ACC status packets are not)
+func isAlarm(p packet.Packet) bool {
+	if _, ok := p.(*packet.ACCStatusPacket); ok {
+		return false
+	}
+	return packet.IsAlarmPacket(p)
+}
+
+// canonicalID returns the canonical form of a device ID from a client or a
+// config file (see types.ParseDeviceID), or s unchanged if it is not one.
+// Sessions are keyed by the canonical ID of the login packet.
+func canonicalID(s string) string {
+	id, err := types.ParseDeviceID(s)
+	if err != nil {
+		return s
+	}
+	return id.String()
+}
+
+// response builds the automatic response for p, or nil
+func (s *Server) response(p packet.Packet) []byte {
+	if s.passive {
+		return nil
+	}
+	switch p.ProtocolNumber() {
+	case protocol.ProtocolLogin:
+		if s.policy.Login {
+			return s.encoder.LoginResponse(p.SerialNumber())
+		}
+	case protocol.ProtocolHeartbeat:
+		if s.policy.Heartbeat {
+			return s.encoder.HeartbeatResponse(p.SerialNumber())
+		}
+	case protocol.ProtocolAlarm, protocol.ProtocolAlarmMultiFence, protocol.ProtocolAlarmMultiFence4G:
+		if s.policy.Alarm && (s.policy.AckAlarm == nil || s.policy.AckAlarm(p)) {
+			return s.encoder.AlarmResponse(p.SerialNumber())
+		}
+	case protocol.ProtocolTimeCalibration:
+		if s.policy.TimeCalibration {
+			return s.encoder.TimeCalibrationResponseNow(p.SerialNumber())
+		}
+	}
+	return nil
+}
+
+// newSession creates a session (not yet registered)
+func (s *Server) newSession(transport, remoteAddr string, conn net.Conn, write func([]byte) error) *Session {
+	now := time.Now()
+	return &Session{
+		server:      s,
+		transport:   transport,
+		remoteAddr:  remoteAddr,
+		conn:        conn,
+		write:       write,
+		connectedAt: now,
+		lastSeen:    now,
+	}
+}
+
+// connect registers a session and calls OnConnect
+func (s *Server) connect(sess *Session) {
+	s.mu.Lock()
+	s.active[sess] = true
+	s.mu.Unlock()
+
+	s.cbMu.RLock()
+	fn := s.onConnect
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(sess)
+	}
+}
+
+// disconnect unregisters a session and calls OnDisconnect
+func (s *Server) disconnect(sess *Session) {
+	s.mu.Lock()
+	delete(s.active, sess)
+	if imei := sess.IMEI(); imei != "" && s.sessions[imei] == sess {
+		delete(s.sessions, imei)
+	}
+	s.mu.Unlock()
+
+	s.cbMu.RLock()
+	fn := s.onDisconnect
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(sess)
+	}
+}
+
+// bind keys a session by IMEI; a newer session for the same device wins
+func (s *Server) bind(sess *Session, imei string) {
+	sess.mu.Lock()
+	sess.imei = imei
+	sess.mu.Unlock()
+
+	s.mu.Lock()
+	s.sessions[imei] = sess
+	s.mu.Unlock()
+}
+
+// raw calls OnRaw
+func (s *Server) raw(sess *Session, dir Direction, data []byte) {
+	s.cbMu.RLock()
+	fn := s.onRaw
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(sess, dir, data)
+	}
+}
+
+// report calls OnError
+func (s *Server) report(sess *Session, err error) {
+	s.cbMu.RLock()
+	fn := s.onError
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(sess, err)
+	}
+}
+
+// track registers a listener; returns false if the server is closed
+func (s *Server) track(l io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = true
+	return true
+}
+
+// untrack removes a listener
+func (s *Server) untrack(l io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+}
+
+// isClosed reports whether Close was called
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
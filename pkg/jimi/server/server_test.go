@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// event is one callback invocation
+type event struct {
+	kind string
+	sess *Session
+	pkt  packet.Packet
+}
+
+// startServer runs a TCP server recording callbacks
+func startServer(t *testing.T, opts ...Option) (*Server, string, chan event) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+
+	opts = append([]Option{WithDecoderOptions(jimi.WithSkipCRC(), jimi.WithLenientMode())}, opts...)
+	srv := New(opts...)
+
+	events := make(chan event, 32)
+	srv.OnLogin(func(s *Session, p *packet.LoginPacket) { events <- event{"login", s, p} })
+	srv.OnLocation(func(s *Session, p packet.Packet) { events <- event{"location", s, p} })
+	srv.OnAlarm(func(s *Session, p packet.Packet) { events <- event{"alarm", s, p} })
+	srv.OnDisconnect(func(s *Session) { events <- event{"disconnect", s, nil} })
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	})
+
+	return srv, l.Addr().String(), events
+}
+
+// next waits for the next callback of the given kind
+func next(t *testing.T, events chan event, kind string) event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.kind == kind {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", kind)
+		}
+	}
+}
+
+// readTCP reads one response with a timeout
+func readTCP(t *testing.T, c net.Conn) []byte {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatalf("No response: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestServer_Callbacks(t *testing.T) {
+	srv, addr, events := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(mustHex(t, loginHex))
+	if got, want := readTCP(t, conn), encoder.New().LoginResponse(1); !bytes.Equal(got, want) {
+		t.Errorf("Expected login response %X, got %X", want, got)
+	}
+
+	login := next(t, events, "login")
+	if login.sess.IMEI() != testIMEI || login.sess.Transport() != "tcp" {
+		t.Errorf("Expected tcp session for %s, got %s %q", testIMEI, login.sess.Transport(), login.sess.IMEI())
+	}
+	if s, ok := srv.Session(testIMEI); !ok || s != login.sess {
+		t.Error("Expected session to be keyed by IMEI")
+	}
+
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	if e := next(t, events, "location"); e.sess != login.sess {
+		t.Error("Expected location on the login session")
+	}
+
+	conn.Write(mustHex(t, packets.AlarmPackets[0].Hex))
+	next(t, events, "alarm")
+	if got := readTCP(t, conn); len(got) == 0 {
+		t.Error("Expected alarm acknowledgement")
+	}
+
+	if err := srv.SendCommand(testIMEI, 1, "STATUS#"); err != nil {
+		t.Fatalf("SendCommand failed: %v", err)
+	}
+	if got, want := readTCP(t, conn), encoder.New().OnlineCommand(1, 1, "STATUS#"); !bytes.Equal(got, want) {
+		t.Errorf("Expected command %X, got %X", want, got)
+	}
+
+	conn.Close()
+	next(t, events, "disconnect")
+	if _, ok := srv.Session(testIMEI); ok {
+		t.Error("Expected session to be removed after disconnect")
+	}
+	if err := srv.Send(testIMEI, nil); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Expected ErrUnknownDevice, got %v", err)
+	}
+}
+
+func TestServer_ReadTimeout(t *testing.T) {
+	_, addr, events := startServer(t, WithReadTimeout(50*time.Millisecond))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	next(t, events, "disconnect")
+}
+
+func TestServer_Passive(t *testing.T) {
+	srv, addr, events := startServer(t, WithPassive())
+
+	var mu sync.Mutex
+	var sent int
+	srv.OnRaw(func(_ *Session, dir Direction, _ []byte) {
+		if dir == TX {
+			mu.Lock()
+			sent++
+			mu.Unlock()
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+	conn.Write(mustHex(t, packets.AlarmPackets[0].Hex))
+	next(t, events, "alarm")
+
+	if err := srv.SendCommand(testIMEI, 1, "STATUS#"); !errors.Is(err, ErrPassive) {
+		t.Errorf("Expected ErrPassive, got %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Errorf("Expected no response, got %d bytes", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sent != 0 {
+		t.Errorf("Expected nothing sent, got %d writes", sent)
+	}
+}
+
+func TestServer_ResponsePolicy(t *testing.T) {
+	decode := func(hexStr string) packet.Packet {
+		p, err := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode()).DecodeHex(hexStr)
+		if err != nil {
+			t.Fatalf("Decode %s: %v", hexStr, err)
+		}
+		return p
+	}
+	noACC := func(p packet.Packet) bool { return !packet.IsACCAlarm(p) }
+
+	tests := []struct {
+		name   string
+		policy ResponsePolicy
+		hex    string
+		want   bool
+	}{
+		{"login default", DefaultResponsePolicy(), loginHex, true},
+		{"login disabled", ResponsePolicy{}, loginHex, false},
+		{"heartbeat default", DefaultResponsePolicy(), heartbeatHex, true},
+		{"heartbeat disabled", ResponsePolicy{Login: true}, heartbeatHex, false},
+		{"alarm default", DefaultResponsePolicy(), packets.AlarmPackets[0].Hex, true},
+		{"alarm filtered", ResponsePolicy{Alarm: true, AckAlarm: noACC}, packets.AlarmPackets[0].Hex, true},
+		{"acc alarm filtered", ResponsePolicy{Alarm: true, AckAlarm: noACC}, packets.AlarmPackets[5].Hex, false},
+		{"location", DefaultResponsePolicy(), packets.LocationPackets[0].Hex, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(WithResponsePolicy(tt.policy))
+			if got := srv.response(decode(tt.hex)) != nil; got != tt.want {
+				t.Errorf("Expected response %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServer_CRCVariant(t *testing.T) {
+	login, err := jimi.NewDecoder(jimi.WithSkipCRC()).DecodeHex(loginHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses use the CRC variant of the decoder options
+	srv := New(WithDecoderOptions(jimi.WithCRCVariant(jimi.CRCITU)))
+	itu := jimi.NewDecoder(jimi.WithCRCVariant(jimi.CRCITU))
+	if err := itu.ValidateCRC(srv.response(login)); err != nil {
+		t.Errorf("Expected a CRC-ITU response: %v", err)
+	}
+
+	// An encoder passed with WithEncoder is not modified
+	enc := encoder.New()
+	New(WithEncoder(enc), WithDecoderOptions(jimi.WithCRCVariant(jimi.CRCITU)))
+	if enc.CRC != nil {
+		t.Error("Expected the encoder to be left unchanged")
+	}
+}
+
+func TestServer_ServeUDP(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+
+	srv := New()
+	logins := make(chan *Session, 1)
+	srv.OnLogin(func(s *Session, p *packet.LoginPacket) { logins <- s })
+
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeUDP(conn) }()
+	defer func() {
+		srv.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	}()
+
+	device := dial(t, conn.LocalAddr().(*net.UDPAddr))
+	device.Write(mustHex(t, loginHex))
+	if got, want := readReply(t, device), encoder.New().LoginResponse(1); !bytes.Equal(got, want) {
+		t.Errorf("Expected login response %X, got %X", want, got)
+	}
+
+	select {
+	case s := <-logins:
+		if s.Transport() != "udp" || s.IMEI() != testIMEI {
+			t.Errorf("Expected udp session for %s, got %s %q", testIMEI, s.Transport(), s.IMEI())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for login")
+	}
+}
+
+func TestServer_ServeAfterClose(t *testing.T) {
+	srv := New()
+	srv.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	if err := srv.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+)
+
+// Session is one device connection (TCP) or UDP peer
+type Session struct {
+	server      *Server
+	transport   string
+	remoteAddr  string
+	conn        net.Conn
+	write       func([]byte) error
+	connectedAt time.Time
+	identity    Identity
+	geo         GeoVerdict
+
+	// writeMu serializes writes from callbacks and SendCommand
+	writeMu sync.Mutex
+
+	// serials numbers the commands sent to the device
+	serials encoder.SerialCounter
+
+	mu          sync.Mutex
+	imei        string
+	lastSeen    time.Time
+	packetCount int
+	value       any
+	pending     map[uint32]string // commands awaiting a response, by server flag
+	collision   string            // remote address of a colliding login (CollisionFlag)
+	rejected    bool              // closed by a login policy; later packets are dropped
+	migrateBy   time.Time         // end of the migration grace, zero if not migrating
+}
+
+// IMEI returns the device IMEI ("" before login)
+func (s *Session) IMEI() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.imei
+}
+
+// Identifier returns the IMEI, or the remote address before login
+func (s *Session) Identifier() string {
+	if imei := s.IMEI(); imei != "" {
+		return imei
+	}
+	return s.remoteAddr
+}
+
+// Transport returns "tcp" or "udp"
+func (s *Session) Transport() string {
+	return s.transport
+}
+
+// RemoteAddr returns the remote address at connect time
+func (s *Session) RemoteAddr() string {
+	return s.remoteAddr
+}
+
+// Identity returns the authenticated identity of a TCP connection
+// (anonymous for plain TCP and UDP)
+func (s *Session) Identity() Identity {
+	return s.identity
+}
+
+// Geo returns the geo policy verdict of the session (zero without WithGeoPolicy)
+func (s *Session) Geo() GeoVerdict {
+	return s.geo
+}
+
+// Collision returns the remote address of the other session that logged in
+// with the same IMEI, when flagged by the login collision policy ("" if none)
+func (s *Session) Collision() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collision
+}
+
+// setCollision flags the session as colliding with a login from remoteAddr
+func (s *Session) setCollision(remoteAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collision = remoteAddr
+}
+
+// isRejected reports whether a login policy closed the session
+func (s *Session) isRejected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejected
+}
+
+// ConnectedAt returns when the session started
+func (s *Session) ConnectedAt() time.Time {
+	return s.connectedAt
+}
+
+// LastSeen returns when the last packet was decoded
+func (s *Session) LastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeen
+}
+
+// PacketCount returns the number of decoded packets
+func (s *Session) PacketCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.packetCount
+}
+
+// Value returns the application value attached with SetValue
+func (s *Session) Value() any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// SetValue attaches an application value (e.g. per-device state) to the session
+func (s *Session) SetValue(v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = v
+}
+
+// Send writes raw data to the device.
+// It returns ErrPassive if the server is passive.
+func (s *Session) Send(data []byte) error {
+	if s.server.passive {
+		return ErrPassive
+	}
+
+	s.writeMu.Lock()
+	err := s.write(data)
+	s.writeMu.Unlock()
+
+	if err == nil {
+		s.server.raw(s, TX, data)
+	}
+	return err
+}
+
+// SendCommand sends an online command (0x80) with the next serial number of
+// the session. The command is remembered until the response with the same
+// server flag arrives; its outcome is tracked in Server.CommandStatus.
+func (s *Session) SendCommand(serverFlag uint32, command string) error {
+	s.addPending(serverFlag, command)
+	err := s.Send(s.server.encoder.OnlineCommand(s.serials.Next(), serverFlag, command))
+	if err != nil {
+		s.takePending(serverFlag)
+		return err
+	}
+	s.server.trackCommand(s.IMEI(), serverFlag, command)
+	return nil
+}
+
+// addPending remembers a command awaiting its response.
+// When too many are pending, an arbitrary one is forgotten.
+func (s *Session) addPending(serverFlag uint32, command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[uint32]string)
+	}
+	if len(s.pending) >= maxPendingCommands {
+		for flag := range s.pending {
+			delete(s.pending, flag)
+			break
+		}
+	}
+	s.pending[serverFlag] = command
+}
+
+// takePending returns and forgets the command sent with serverFlag ("" if unknown)
+func (s *Session) takePending(serverFlag uint32) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	command := s.pending[serverFlag]
+	delete(s.pending, serverFlag)
+	return command
+}
+
+// Close closes a TCP connection; UDP sessions expire on their own
+func (s *Session) Close() error {
+	if s.conn == nil {
+		return errors.New("server: UDP sessions cannot be closed")
+	}
+	return s.conn.Close()
+}
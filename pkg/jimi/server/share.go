@@ -0,0 +1,72 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Share token errors
+var (
+	// ErrInvalidShareToken is returned for malformed or forged share tokens
+	ErrInvalidShareToken = errors.New("server: invalid share token")
+
+	// ErrShareTokenExpired is returned for share tokens past their expiry
+	ErrShareTokenExpired = errors.New("server: share token expired")
+)
+
+// ShareSigner issues and verifies share tokens: expiring, HMAC-signed tokens
+// that grant read access to the live position of one device, e.g. for a
+// customer-facing tracking link. Tokens are stateless, so they cannot be
+// revoked one by one; changing the key revokes all of them.
+type ShareSigner struct {
+	key []byte
+}
+
+// NewShareSigner creates a signer. The key should be at least 32 random bytes.
+func NewShareSigner(key []byte) *ShareSigner {
+	return &ShareSigner{key: append([]byte(nil), key...)}
+}
+
+// Sign returns a token for the position of imei, valid until expires
+func (s *ShareSigner) Sign(imei string, expires time.Time) string {
+	payload := imei + "." + strconv.FormatInt(expires.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// Verify checks a token at the given time and returns the IMEI it grants
+// access to and its expiry
+func (s *ShareSigner) Verify(token string, now time.Time) (string, time.Time, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(encPayload)
+	mac, err2 := base64.RawURLEncoding.DecodeString(encMAC)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, s.mac(string(payload))) {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+
+	imei, unix, ok := strings.Cut(string(payload), ".")
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if !ok || imei == "" || err != nil {
+		return "", time.Time{}, ErrInvalidShareToken
+	}
+	expires := time.Unix(seconds, 0)
+	if !now.Before(expires) {
+		return "", time.Time{}, ErrShareTokenExpired
+	}
+	return imei, expires, nil
+}
+
+// mac returns the HMAC-SHA256 of a payload
+func (s *ShareSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+)
+
+func TestShareSigner(t *testing.T) {
+	signer := NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	now := time.Unix(1718461845, 0)
+	token := signer.Sign(testIMEI, now.Add(time.Hour))
+
+	imei, expires, err := signer.Verify(token, now)
+	if err != nil || imei != testIMEI || !expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Verify() = %q, %v, %v", imei, expires, err)
+	}
+
+	payload, mac, _ := strings.Cut(token, ".")
+	forged := NewShareSigner([]byte("another key")).Sign(testIMEI, now.Add(time.Hour))
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		want  error
+	}{
+		{"expired", token, now.Add(time.Hour), ErrShareTokenExpired},
+		{"other key", forged, now, ErrInvalidShareToken},
+		{"tampered payload", "x" + payload + "." + mac, now, ErrInvalidShareToken},
+		{"tampered mac", payload + "." + mac[1:], now, ErrInvalidShareToken},
+		{"no mac", payload, now, ErrInvalidShareToken},
+		{"empty", "", now, ErrInvalidShareToken},
+		{"not base64", "!!.!!", now, ErrInvalidShareToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := signer.Verify(tt.token, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAPI_Shares(t *testing.T) {
+	srv, addr, events := startServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	next(t, events, "location")
+
+	signer := NewShareSigner([]byte("0123456789abcdef0123456789abcdef"))
+	api := NewAPI(srv, WithAPIToken("secret"), WithAPIShares(signer))
+	authorized := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Creating links requires the API token
+	path := "/api/devices/" + testIMEI + "/share"
+	if code := call(t, api, "POST", path, `{"minutes":60}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+	for _, body := range []string{`{"minutes":0}`, `{"minutes":10081}`, `{"hours":1}`} {
+		if rec := authorized("POST", path, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+
+	rec := authorized("POST", path, `{"minutes":60}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var link ShareLink
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+	if link.IMEI != testIMEI || link.Path != "/api/shared/"+link.Token || time.Until(link.ExpiresAt) < 59*time.Minute {
+		t.Errorf("Unexpected link %+v", link)
+	}
+
+	// The link itself needs no API token
+	var pos Position
+	if code := call(t, api, "GET", link.Path, "", &pos); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if pos.IMEI != testIMEI || pos.Time.IsZero() {
+		t.Errorf("Unexpected position %+v", pos)
+	}
+
+	expired := signer.Sign(testIMEI, time.Now().Add(-time.Minute))
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/shared/" + expired, http.StatusGone},
+		{"/api/shared/" + link.Token + "x", http.StatusForbidden},
+		{"/api/shared/" + signer.Sign("000000000000000", time.Now().Add(time.Hour)), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := call(t, api, "GET", tt.path, "", nil); code != tt.want {
+			t.Errorf("GET %s: expected %d, got %d", tt.path, tt.want, code)
+		}
+	}
+
+	// The bypass covers shared positions only
+	if code := call(t, NewAPI(srv, WithAPIToken("secret")), "GET", link.Path, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without shares enabled, got %d", code)
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// DefaultStreamBuffer is the default number of events buffered per subscription
+const DefaultStreamBuffer = 64
+
+// DefaultEventHistory is the default number of recent events kept for
+// subscribers resuming from a cursor (see SubscribeFrom)
+const DefaultEventHistory = 256
+
+// Event is a packet of a logged-in device on the event feed
+type Event struct {
+	// ID increases by one per event; a gap means events were dropped for a
+	// slow subscriber
+	ID uint64 `json:"id"`
+
+	export.Record
+}
+
+// Subscription receives events of the feed (see Server.Subscribe)
+type Subscription struct {
+	server  *Server
+	filter  func(Event) bool
+	events  chan Event
+	dropped uint64 // guarded by server.streamMu
+}
+
+// Events returns the channel of matching events. It is closed by Close.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (sub *Subscription) Dropped() uint64 {
+	sub.server.streamMu.Lock()
+	defer sub.server.streamMu.Unlock()
+	return sub.dropped
+}
+
+// Close unsubscribes and closes the event channel
+func (sub *Subscription) Close() {
+	s := sub.server
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	if s.subs[sub] {
+		delete(s.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribe subscribes to the decoded packets of logged-in devices, as
+// events matching filter (nil matches all). Up to buffer events are queued;
+// events are dropped, never blocking the server, when the subscriber falls
+// behind. The filter runs in the goroutine of the packet and must be fast.
+// The subscription must be closed.
+//
+// Example usage:
+//
+//	sub := srv.Subscribe(func(e server.Event) bool { return e.IMEI == imei }, server.DefaultStreamBuffer)
+//	defer sub.Close()
+//	for e := range sub.Events() {
+//	    log.Printf("#%d %s %s", e.ID, e.IMEI, e.Type)
+//	}
+func (s *Server) Subscribe(filter func(Event) bool, buffer int) *Subscription {
+	sub := &Subscription{
+		server: s,
+		filter: filter,
+		events: make(chan Event, max(buffer, 1)),
+	}
+	s.streamMu.Lock()
+	s.subs[sub] = true
+	s.streamMu.Unlock()
+	return sub
+}
+
+// SubscribeFrom is like Subscribe but first delivers the kept events with
+// an ID greater than after (see WithEventHistory), so a client reconnecting
+// with the ID of the last event it received resumes without a gap. Events
+// older than the history are lost; the gap in IDs shows it.
+func (s *Server) SubscribeFrom(after uint64, filter func(Event) bool, buffer int) *Subscription {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	var replay []Event
+	for _, e := range s.history {
+		if e.ID > after && (filter == nil || filter(e)) {
+			replay = append(replay, e)
+		}
+	}
+	sub := &Subscription{
+		server: s,
+		filter: filter,
+		events: make(chan Event, len(replay)+max(buffer, 1)),
+	}
+	for _, e := range replay {
+		sub.events <- e
+	}
+	s.subs[sub] = true
+	return sub
+}
+
+// LastEventID returns the ID of the last published event
+func (s *Server) LastEventID() uint64 {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	return s.eventID
+}
+
+// WithEventHistory sets the number of recent events kept for SubscribeFrom
+// (0 disables resuming)
+func WithEventHistory(n int) Option {
+	return func(s *Server) {
+		s.historySize = max(n, 0)
+	}
+}
+
+// publish sends a packet of a logged-in device to the subscribers
+func (s *Server) publish(sess *Session, p packet.Packet) {
+	imei := sess.IMEI()
+	if imei == "" {
+		return
+	}
+
+	s.streamMu.Lock()
+	err := s.publishLocked(imei, p)
+	s.streamMu.Unlock()
+	if err != nil {
+		s.report(sess, fmt.Errorf("persist event: %w", err))
+	}
+}
+
+// publishLocked assigns the next event ID, keeps the event in the history
+// and the store and sends it to the subscribers. streamMu must be held.
+func (s *Server) publishLocked(imei string, p packet.Packet) error {
+	s.eventID++
+	if len(s.subs) == 0 && s.historySize == 0 && s.eventKV == nil {
+		return nil
+	}
+
+	e := Event{ID: s.eventID, Record: export.NewRecord(imei, p, time.Now())}
+	var evicted uint64
+	if s.historySize > 0 {
+		if len(s.history) == s.historySize {
+			evicted = s.history[0].ID
+			copy(s.history, s.history[1:])
+			s.history = s.history[:len(s.history)-1]
+		}
+		s.history = append(s.history, e)
+	}
+	for sub := range s.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped++
+		}
+	}
+	if s.eventKV != nil {
+		return s.persistEvent(e, evicted)
+	}
+	return nil
+}
+
+// closeSubscriptions closes all subscriptions
+func (s *Server) closeSubscriptions() {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	for sub := range s.subs {
+		delete(s.subs, sub)
+		close(sub.events)
+	}
+}
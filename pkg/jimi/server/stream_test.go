@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+)
+
+func TestSubscribe(t *testing.T) {
+	srv, addr, events := startServer(t)
+	all := srv.Subscribe(nil, 1)
+	defer all.Close()
+	none := srv.Subscribe(func(e Event) bool { return e.IMEI != testIMEI }, DefaultStreamBuffer)
+	defer none.Close()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	next(t, events, "location")
+
+	// The login fills the buffer of one, the location is dropped
+	e := <-all.Events()
+	if e.ID != 1 || e.IMEI != testIMEI || e.Type != "Login" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if got := all.Dropped(); got != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", got)
+	}
+	if len(none.Events()) != 0 {
+		t.Error("Expected no events for the filtered subscription")
+	}
+
+	all.Close()
+	all.Close()
+	if _, ok := <-all.Events(); ok {
+		t.Error("Expected closed channel")
+	}
+}
+
+// dialWebSocket opens a WebSocket to url (http://host/path)
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	host, path, _ := strings.Cut(strings.TrimPrefix(url, "http://"), "/")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	io.WriteString(conn, "GET /"+path+" HTTP/1.1\r\nHost: "+host+"\r\n"+
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	// Example key and accept value of RFC 6455
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept %q", got)
+	}
+	return conn, br
+}
+
+// readFrame reads one unmasked server frame
+func readFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	size := int(head[1] & 0x7F)
+	if size == 126 {
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// writeFrame writes one masked client frame
+func writeFrame(conn net.Conn, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func TestAPI_GroupStream(t *testing.T) {
+	srv, addr, events := startServer(t)
+	groups := NewGroups()
+	groups.Add("depot-north", testIMEI)
+	groups.Add("vans", "868120303960873")
+	hs := httptest.NewServer(NewAPI(srv, WithAPIGroups(groups)))
+	defer hs.Close()
+
+	if code := call(t, NewAPI(srv, WithAPIGroups(groups)), "GET", "/api/groups/vans/stream", "", nil); code != http.StatusUpgradeRequired {
+		t.Errorf("Expected 426 without upgrade, got %d", code)
+	}
+
+	ws, br := dialWebSocket(t, hs.URL+"/api/groups/depot-north/stream")
+	vans, vansBr := dialWebSocket(t, hs.URL+"/api/groups/vans/stream")
+	all, allBr := dialWebSocket(t, hs.URL+"/api/stream?imei="+testIMEI)
+
+	// Wait for the three subscriptions
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		srv.streamMu.Lock()
+		n := len(srv.subs)
+		srv.streamMu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 subscriptions, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+
+	for _, r := range []*bufio.Reader{br, allBr} {
+		op, data := readFrame(t, r)
+		var e Event
+		if err := json.Unmarshal(data, &e); op != wsText || err != nil {
+			t.Fatalf("Expected text frame with an event, got %#x %q", op, data)
+		}
+		if e.ID == 0 || e.IMEI != testIMEI || e.Type != "Login" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+
+	// Pings are answered, closes echoed
+	writeFrame(ws, wsPing, []byte("hi"))
+	if op, data := readFrame(t, br); op != wsPong || string(data) != "hi" {
+		t.Errorf("Expected pong, got %#x %q", op, data)
+	}
+	writeFrame(ws, wsClose, []byte{0x03, 0xE8})
+	if op, _ := readFrame(t, br); op != wsClose {
+		t.Errorf("Expected close, got %#x", op)
+	}
+
+	// Other groups see nothing; closing the server ends their streams
+	srv.Close()
+	if op, _ := readFrame(t, vansBr); op != wsClose {
+		t.Errorf("Expected close of the other group, got %#x", op)
+	}
+	vans.Close()
+	all.Close()
+}
+
+func TestSubscribeFrom(t *testing.T) {
+	srv, addr, events := startServer(t, WithEventHistory(2))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	for i := range 2 {
+		conn.Write(mustHex(t, packets.LocationPackets[i].Hex))
+		next(t, events, "location")
+	}
+	if got := srv.LastEventID(); got != 3 {
+		t.Fatalf("Expected last event 3, got %d", got)
+	}
+
+	tests := []struct {
+		name  string
+		after uint64
+		want  []uint64
+	}{
+		{"resume", 2, []uint64{3}},
+		{"beyond history", 0, []uint64{2, 3}},
+		{"up to date", 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := srv.SubscribeFrom(tt.after, nil, 1)
+			defer sub.Close()
+			var got []uint64
+			for len(sub.Events()) > 0 {
+				got = append(got, (<-sub.Events()).ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAPI_StreamSSE(t *testing.T) {
+	srv, addr, events := startServer(t)
+	hs := httptest.NewServer(NewAPI(srv))
+	t.Cleanup(hs.Close) // after the response bodies
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	readTCP(t, conn)
+	next(t, events, "login")
+	conn.Write(mustHex(t, packets.LocationPackets[0].Hex))
+	next(t, events, "location")
+
+	get := func(path, lastID string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", hs.URL+path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := get("/api/stream?after=x", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid cursor, got %d", resp.StatusCode)
+	}
+
+	// Reconnecting after the login replays the location
+	resp := get("/api/stream?imei="+testIMEI, "1")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q", resp.StatusCode, ct)
+	}
+	br := bufio.NewReader(resp.Body)
+	readEvent := func() (string, Event) {
+		t.Helper()
+		var id string
+		var e Event
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return id, e
+			case strings.HasPrefix(line, "id: "):
+				id = line[4:]
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(line[6:]), &e); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if id, e := readEvent(); id != "2" || e.ID != 2 || e.IMEI != testIMEI || e.Position == nil {
+		t.Errorf("Unexpected replayed event %s %+v", id, e)
+	}
+
+	// Live events follow
+	conn.Write(mustHex(t, packets.LocationPackets[1].Hex))
+	next(t, events, "location")
+	if id, e := readEvent(); id != "3" || e.ID != 3 {
+		t.Errorf("Unexpected live event %s %+v", id, e)
+	}
+}
@@ -0,0 +1,353 @@
+// Package server provides embeddable network front-ends for VL103M trackers.
+//
+// Server accepts TCP connections (and optionally UDP datagrams), decodes
+// packets, tracks sessions by IMEI, sends the responses the protocol expects
+// (see ResponsePolicy) and calls application callbacks such as OnLogin,
+// OnLocation and OnAlarm.
+//
+// UDPServer receives packets sent in UDP upload mode. UDP has no connection,
+// so sessions are keyed by device IMEI once the device has logged in (and by
+// remote address until then). A device that logs in again from a new address
+// keeps its session; responses go to the address it last sent from.
+// Server.ServeUDP uses it with the callbacks of the Server; it can also be
+// used on its own:
+//
+//	conn, _ := net.ListenUDP("udp", &net.UDPAddr{Port: 5023})
+//	srv := server.NewUDPServer(conn, jimi.NewDecoder(),
+//	    func(s *server.UDPSession, p packet.Packet) {
+//	        if p.ProtocolNumber() == protocol.ProtocolLogin {
+//	            s.Reply(enc.LoginResponse(p.SerialNumber()))
+//	        }
+//	    })
+//	log.Fatal(srv.Serve())
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// DefaultUDPIdleTimeout is how long a UDP session is kept without traffic
+const DefaultUDPIdleTimeout = 5 * time.Minute
+
+// maxDatagramSize is the largest UDP payload
+const maxDatagramSize = 65535
+
+// ErrUnknownDevice is returned when sending to a device without a session
+var ErrUnknownDevice = errors.New("server: unknown device")
+
+// UDPHandler is called for every decoded packet
+type UDPHandler func(s *UDPSession, p packet.Packet)
+
+// UDPSession is the state of one device talking over UDP.
+// A logged-in device keeps the same session value across address changes,
+// so it can be used as a map key by the application.
+type UDPSession struct {
+	server *UDPServer
+
+	mu          sync.Mutex
+	imei        string
+	addr        *net.UDPAddr
+	buffer      []byte
+	device      *jimi.DeviceSession // decodes with the login timezone of this peer
+	firstSeen   time.Time
+	lastSeen    time.Time
+	packetCount int
+}
+
+// IMEI returns the device IMEI ("" before login)
+func (s *UDPSession) IMEI() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.imei
+}
+
+// Addr returns the address the device last sent from
+func (s *UDPSession) Addr() *net.UDPAddr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// FirstSeen returns the time of the first datagram
+func (s *UDPSession) FirstSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.firstSeen
+}
+
+// LastSeen returns the time of the last datagram
+func (s *UDPSession) LastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeen
+}
+
+// PacketCount returns the number of packets decoded for this session
+func (s *UDPSession) PacketCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.packetCount
+}
+
+// Identifier returns the IMEI, or the remote address before login
+func (s *UDPSession) Identifier() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.imei != "" {
+		return s.imei
+	}
+	return s.addr.String()
+}
+
+// Reply sends data to the device's current address
+func (s *UDPSession) Reply(data []byte) error {
+	_, err := s.server.conn.WriteToUDP(data, s.Addr())
+	return err
+}
+
+// UDPServer decodes packets from a UDP socket
+type UDPServer struct {
+	conn    *net.UDPConn
+	decoder *jimi.Decoder
+	handler UDPHandler
+
+	idleTimeout time.Duration
+	onError     func(addr *net.UDPAddr, err error)
+	onClose     func(s *UDPSession)
+	now         func() time.Time
+
+	mu     sync.Mutex
+	byIMEI map[string]*UDPSession
+	byAddr map[string]*UDPSession
+}
+
+// UDPOption configures a UDPServer
+type UDPOption func(*UDPServer)
+
+// WithUDPIdleTimeout sets how long sessions are kept without traffic
+func WithUDPIdleTimeout(d time.Duration) UDPOption {
+	return func(s *UDPServer) {
+		s.idleTimeout = d
+	}
+}
+
+// WithUDPErrorHandler is called for datagrams that fail to decode
+func WithUDPErrorHandler(fn func(addr *net.UDPAddr, err error)) UDPOption {
+	return func(s *UDPServer) {
+		s.onError = fn
+	}
+}
+
+// WithUDPCloseHandler is called when an idle session is expired
+func WithUDPCloseHandler(fn func(s *UDPSession)) UDPOption {
+	return func(s *UDPServer) {
+		s.onClose = fn
+	}
+}
+
+// NewUDPServer creates a server reading from conn
+func NewUDPServer(conn *net.UDPConn, decoder *jimi.Decoder, handler UDPHandler, opts ...UDPOption) *UDPServer {
+	s := &UDPServer{
+		conn:        conn,
+		decoder:     decoder,
+		handler:     handler,
+		idleTimeout: DefaultUDPIdleTimeout,
+		now:         time.Now,
+		byIMEI:      make(map[string]*UDPSession),
+		byAddr:      make(map[string]*UDPSession),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Serve reads datagrams until the socket is closed.
+// Packets are handled in the reading goroutine, in arrival order.
+func (s *UDPServer) Serve() error {
+	buf := make([]byte, maxDatagramSize)
+	lastSweep := s.now()
+
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if now := s.now(); s.idleTimeout > 0 && now.Sub(lastSweep) >= s.idleTimeout/2 {
+			s.expire(now)
+			lastSweep = now
+		}
+
+		s.HandleDatagram(addr, append([]byte(nil), buf[:n]...))
+	}
+}
+
+// HandleDatagram decodes one datagram received from addr.
+// Serve calls it for every datagram; it is exported for custom read loops.
+func (s *UDPServer) HandleDatagram(addr *net.UDPAddr, data []byte) {
+	sess := s.session(addr)
+
+	sess.mu.Lock()
+	sess.lastSeen = s.now()
+	sess.buffer = append(sess.buffer, data...)
+	packets, residue, err := sess.device.DecodeStream(sess.buffer)
+	sess.buffer = residue
+	sess.packetCount += len(packets)
+	sess.mu.Unlock()
+
+	if err != nil && s.onError != nil {
+		s.onError(addr, err)
+	}
+
+	for _, p := range packets {
+		if login, ok := p.(*packet.LoginPacket); ok {
+			sess = s.bind(sess, login.GetIMEI())
+		}
+		if s.handler != nil {
+			s.handler(sess, p)
+		}
+	}
+}
+
+// Session returns the session of a logged-in device
+func (s *UDPServer) Session(imei string) (*UDPSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byIMEI[imei]
+	return sess, ok
+}
+
+// Sessions returns all sessions, logged-in devices first, sorted by identifier
+func (s *UDPServer) Sessions() []*UDPSession {
+	s.mu.Lock()
+	seen := make(map[*UDPSession]bool)
+	var out []*UDPSession
+	for _, sess := range s.byAddr {
+		if !seen[sess] {
+			seen[sess] = true
+			out = append(out, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		ii, ij := out[i].IMEI(), out[j].IMEI()
+		if (ii == "") != (ij == "") {
+			return ii != ""
+		}
+		return out[i].Identifier() < out[j].Identifier()
+	})
+	return out
+}
+
+// Send sends data to a logged-in device
+func (s *UDPServer) Send(imei string, data []byte) error {
+	sess, ok := s.Session(imei)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDevice, imei)
+	}
+	return sess.Reply(data)
+}
+
+// session returns the session for addr, creating it if needed
+func (s *UDPServer) session(addr *net.UDPAddr) *UDPSession {
+	key := addr.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.byAddr[key]; ok {
+		return sess
+	}
+
+	now := s.now()
+	sess := &UDPSession{server: s, addr: addr, device: s.decoder.NewDeviceSession(), firstSeen: now, lastSeen: now}
+	s.byAddr[key] = sess
+	return sess
+}
+
+// bind keys a session by IMEI after login and returns the session to use.
+// If the device already has a session (it logged in again from a new
+// address), that session is kept: it takes over the new address and the
+// temporary per-address session is merged into it.
+func (s *UDPServer) bind(sess *UDPSession, imei string) *UDPSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.byIMEI[imei]
+	if !ok || old == sess {
+		s.byIMEI[imei] = sess
+		sess.mu.Lock()
+		sess.imei = imei
+		sess.mu.Unlock()
+		return sess
+	}
+
+	sess.mu.Lock()
+	addr, buffer, device, count := sess.addr, sess.buffer, sess.device, sess.packetCount
+	sess.mu.Unlock()
+
+	s.dropLocked(sess)
+	for k, v := range s.byAddr {
+		if v == old {
+			delete(s.byAddr, k)
+		}
+	}
+	s.byAddr[addr.String()] = old
+
+	old.mu.Lock()
+	old.addr = addr
+	old.buffer = append(old.buffer, buffer...)
+	old.device = device // decoded the new login
+	old.packetCount += count
+	old.lastSeen = s.now()
+	old.mu.Unlock()
+
+	return old
+}
+
+// expire drops sessions idle for longer than the idle timeout
+func (s *UDPServer) expire(now time.Time) {
+	var expired []*UDPSession
+
+	s.mu.Lock()
+	for _, sess := range s.byAddr {
+		if now.Sub(sess.LastSeen()) > s.idleTimeout {
+			expired = append(expired, sess)
+		}
+	}
+	for _, sess := range expired {
+		s.dropLocked(sess)
+	}
+	s.mu.Unlock()
+
+	if s.onClose != nil {
+		for _, sess := range expired {
+			s.onClose(sess)
+		}
+	}
+}
+
+// dropLocked removes a session from both indexes; s.mu must be held
+func (s *UDPServer) dropLocked(sess *UDPSession) {
+	for k, v := range s.byAddr {
+		if v == sess {
+			delete(s.byAddr, k)
+		}
+	}
+	if imei := sess.IMEI(); imei != "" && s.byIMEI[imei] == sess {
+		delete(s.byIMEI, imei)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/encoder"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const (
+	testIMEI     = "359339073930520"
+	loginHex     = "787811010359339073930520044D01E00001EB830D0A"
+	heartbeatHex = "787808132404020001870D0D0A"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// startUDP runs a UDP server that acknowledges logins and records packets
+func startUDP(t *testing.T, opts ...UDPOption) (*UDPServer, *net.UDPAddr, chan *UDPSession) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+
+	enc := encoder.New()
+	seen := make(chan *UDPSession, 16)
+	srv := NewUDPServer(conn, jimi.NewDecoder(), func(s *UDPSession, p packet.Packet) {
+		if p.ProtocolNumber() == protocol.ProtocolLogin {
+			s.Reply(enc.LoginResponse(p.SerialNumber()))
+		}
+		seen <- s
+	}, opts...)
+
+	done := make(chan struct{})
+	go func() {
+		srv.Serve()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+
+	return srv, conn.LocalAddr().(*net.UDPAddr), seen
+}
+
+// dial opens a device socket
+func dial(t *testing.T, addr *net.UDPAddr) *net.UDPConn {
+	t.Helper()
+	c, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// readReply reads one datagram with a timeout
+func readReply(t *testing.T, c *net.UDPConn) []byte {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatalf("No reply: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestUDPServer_LoginAndReply(t *testing.T) {
+	srv, addr, seen := startUDP(t)
+	device := dial(t, addr)
+
+	device.Write(mustHex(t, loginHex))
+	want := encoder.New().LoginResponse(1)
+	if got := readReply(t, device); hex.EncodeToString(got) != hex.EncodeToString(want) {
+		t.Errorf("Expected login response %X, got %X", want, got)
+	}
+
+	sess := <-seen
+	if sess.IMEI() != testIMEI {
+		t.Errorf("Expected session IMEI %s, got %q", testIMEI, sess.IMEI())
+	}
+	if got, ok := srv.Session(testIMEI); !ok || got != sess {
+		t.Error("Expected session to be keyed by IMEI")
+	}
+
+	// Heartbeat from the same address belongs to the same session
+	device.Write(mustHex(t, heartbeatHex))
+	if s := <-seen; s != sess || s.PacketCount() != 2 {
+		t.Errorf("Expected heartbeat on the login session, got %v (%d packets)", s.Identifier(), s.PacketCount())
+	}
+
+	// Server-initiated send goes to the device address
+	if err := srv.Send(testIMEI, []byte("cmd")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := readReply(t, device); string(got) != "cmd" {
+		t.Errorf("Expected command, got %q", got)
+	}
+
+	if err := srv.Send("000000000000000", nil); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Expected ErrUnknownDevice, got %v", err)
+	}
+}
+
+func TestUDPServer_SessionFollowsDeviceAcrossAddresses(t *testing.T) {
+	srv, addr, seen := startUDP(t)
+
+	first := dial(t, addr)
+	first.Write(mustHex(t, loginHex))
+	readReply(t, first)
+	sess := <-seen
+
+	// Same device logs in again from a new source port (NAT rebinding)
+	second := dial(t, addr)
+	second.Write(mustHex(t, loginHex))
+	readReply(t, second)
+
+	if s := <-seen; s != sess {
+		t.Error("Expected the existing session to be reused after re-login")
+	}
+	if sess.Addr().String() != second.LocalAddr().String() {
+		t.Errorf("Expected session address %s, got %s", second.LocalAddr(), sess.Addr())
+	}
+	if n := len(srv.Sessions()); n != 1 {
+		t.Errorf("Expected 1 session, got %d", n)
+	}
+}
+
+func TestUDPServer_ExpiresIdleSessions(t *testing.T) {
+	now := time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	closed := make(chan *UDPSession, 1)
+	srv := NewUDPServer(nil, jimi.NewDecoder(), nil,
+		WithUDPIdleTimeout(time.Minute),
+		WithUDPCloseHandler(func(s *UDPSession) { closed <- s }),
+	)
+	srv.now = clock
+
+	srv.HandleDatagram(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}, mustHex(t, loginHex))
+	if _, ok := srv.Session(testIMEI); !ok {
+		t.Fatal("Expected session after login")
+	}
+
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+	srv.expire(clock())
+
+	select {
+	case s := <-closed:
+		if s.IMEI() != testIMEI {
+			t.Errorf("Expected %s to be expired, got %s", testIMEI, s.Identifier())
+		}
+	default:
+		t.Fatal("Expected close handler to be called")
+	}
+	if _, ok := srv.Session(testIMEI); ok {
+		t.Error("Expected session to be removed")
+	}
+}
+
+func TestUDPServer_DeviceTimezonePerPeer(t *testing.T) {
+	enc := encoder.New()
+	timezones := map[string]*types.Timezone{}
+	var mu sync.Mutex
+	srv := NewUDPServer(nil, jimi.NewDecoder(jimi.WithDeviceTimezone()), func(s *UDPSession, p packet.Packet) {
+		if loc, ok := p.(*packet.LocationPacket); ok {
+			mu.Lock()
+			timezones[s.IMEI()] = loc.DateTime.Timezone
+			mu.Unlock()
+		}
+	})
+
+	devices := []struct {
+		imei   string
+		offset int
+		addr   *net.UDPAddr
+	}{
+		{testIMEI, 480, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}},
+		{"356307042441013", -300, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}},
+	}
+	for _, d := range devices {
+		login, err := enc.Login(packet.NewLoginPacket(types.MustNewIMEI(d.imei), 0x4D01, types.Timezone{OffsetMinutes: d.offset}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.HandleDatagram(d.addr, login)
+	}
+	location := enc.Location(packet.NewLocationPacket(types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)),
+		types.MustNewCoordinates(-33.868820, 151.209296), 0, types.CourseStatus{}))
+	for _, d := range devices {
+		srv.HandleDatagram(d.addr, location)
+	}
+
+	for _, d := range devices {
+		if tz := timezones[d.imei]; tz == nil || tz.OffsetMinutes != d.offset {
+			t.Errorf("%s: expected timezone %d, got %v", d.imei, d.offset, tz)
+		}
+	}
+}
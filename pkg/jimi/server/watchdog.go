@@ -0,0 +1,154 @@
+package server
+
+import (
+	"sort"
+	"time"
+)
+
+// Presence is the online state of a device tracked by the offline watchdog
+// (see WithOfflineWatchdog)
+type Presence struct {
+	IMEI string `json:"imei"`
+
+	// Online is false once the device sent nothing for the watchdog window
+	Online bool `json:"online"`
+
+	// LastSeen is when the last packet of the device was received
+	LastSeen time.Time `json:"last_seen"`
+
+	// Since is when the device went online or offline
+	Since time.Time `json:"since"`
+
+	// Connected reports whether a session of the device is open. An offline
+	// device that is still connected has a stalled or half-open connection.
+	Connected bool `json:"connected"`
+}
+
+// presenceState is a Presence with its watchdog timer
+type presenceState struct {
+	Presence
+	timer *time.Timer
+}
+
+// WithOfflineWatchdog reports a logged-in device offline (see OnOffline)
+// when no packet (heartbeat, location or any other) arrives for window, and
+// online again (see OnOnline) with its next packet. Sessions only end when
+// the connection drops or the read timeout expires; the watchdog notices
+// silent devices whatever their connection does, and keeps tracking them
+// after they disconnect.
+func WithOfflineWatchdog(window time.Duration) Option {
+	return func(s *Server) {
+		s.offlineWindow = window
+	}
+}
+
+// OnOffline is called when a device is reported offline by the watchdog. It
+// runs in a goroutine of its own.
+func (s *Server) OnOffline(fn func(Presence)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onOffline = fn
+}
+
+// OnOnline is called when a device reported offline sends a packet again,
+// before the callbacks of the packet. LastSeen is the last packet before the
+// device went offline.
+func (s *Server) OnOnline(fn func(Presence)) {
+	s.cbMu.Lock()
+	defer s.cbMu.Unlock()
+	s.onOnline = fn
+}
+
+// Presence returns the watchdog state of a device
+func (s *Server) Presence(imei string) (Presence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.presence[imei]
+	if !ok {
+		return Presence{}, false
+	}
+	return s.presenceLocked(p), true
+}
+
+// OfflineDevices returns the devices reported offline, sorted by IMEI
+func (s *Server) OfflineDevices() []Presence {
+	s.mu.Lock()
+	out := []Presence{}
+	for _, p := range s.presence {
+		if !p.Online {
+			out = append(out, s.presenceLocked(p))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].IMEI < out[j].IMEI })
+	return out
+}
+
+// presenceLocked returns the Presence of a device. s.mu must be held.
+func (s *Server) presenceLocked(p *presenceState) Presence {
+	out := p.Presence
+	_, out.Connected = s.sessions[p.IMEI]
+	return out
+}
+
+// trackPresence restarts the watchdog of the device of a session that sent
+// a packet and reports it online if it was offline
+func (s *Server) trackPresence(sess *Session) {
+	imei := sess.IMEI()
+	if s.offlineWindow <= 0 || imei == "" {
+		return
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	p, ok := s.presence[imei]
+	if !ok {
+		p = &presenceState{Presence: Presence{IMEI: imei, Online: true, Since: now}}
+		p.timer = time.AfterFunc(s.offlineWindow, func() { s.expirePresence(p) })
+		s.presence[imei] = p
+	} else {
+		p.timer.Reset(s.offlineWindow)
+	}
+	back := !p.Online
+	if back {
+		p.Online, p.Since = true, now
+	}
+	event := s.presenceLocked(p)
+	p.LastSeen = now
+	s.mu.Unlock()
+
+	if !back {
+		return
+	}
+	s.cbMu.RLock()
+	fn := s.onOnline
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(event)
+	}
+}
+
+// expirePresence reports a device offline when its watchdog fires
+func (s *Server) expirePresence(p *presenceState) {
+	s.mu.Lock()
+	// A packet may have restarted the watchdog while it fired
+	if s.closed || !p.Online || time.Since(p.LastSeen) < s.offlineWindow {
+		s.mu.Unlock()
+		return
+	}
+	p.Online, p.Since = false, time.Now()
+	event := s.presenceLocked(p)
+	s.mu.Unlock()
+
+	s.cbMu.RLock()
+	fn := s.onOffline
+	s.cbMu.RUnlock()
+	if fn != nil {
+		fn(event)
+	}
+}
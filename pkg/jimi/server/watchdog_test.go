@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_OfflineWatchdog(t *testing.T) {
+	const window = 50 * time.Millisecond
+	srv, addr, events := startServer(t, WithOfflineWatchdog(window))
+	presence := make(chan Presence, 4)
+	srv.OnOffline(func(p Presence) { presence <- p })
+	srv.OnOnline(func(p Presence) { presence <- p })
+
+	nextPresence := func(online bool) Presence {
+		t.Helper()
+		select {
+		case p := <-presence:
+			if p.Online != online || p.IMEI != testIMEI {
+				t.Fatalf("Expected online=%v for %s, got %+v", online, testIMEI, p)
+			}
+			return p
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for online=%v", online)
+		}
+		return Presence{}
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(mustHex(t, loginHex))
+	next(t, events, "login")
+
+	// The connection stays open while the device is silent
+	p := nextPresence(false)
+	if !p.Connected || p.Since.Sub(p.LastSeen) < window {
+		t.Errorf("Unexpected offline presence %+v", p)
+	}
+	var offline []Presence
+	call(t, NewAPI(srv), "GET", "/api/offline", "", &offline)
+	if len(offline) != 1 || offline[0].IMEI != testIMEI {
+		t.Errorf("Expected the device offline, got %+v", offline)
+	}
+
+	conn.Write(mustHex(t, heartbeatHex))
+	if p := nextPresence(true); p.Since.Sub(p.LastSeen) < window {
+		t.Errorf("Expected the login as last packet, got %+v", p)
+	}
+	if p, ok := srv.Presence(testIMEI); !ok || !p.Online {
+		t.Errorf("Expected the device online, got %+v", p)
+	}
+
+	// Devices are still tracked after they disconnect
+	conn.Close()
+	next(t, events, "disconnect")
+	if p := nextPresence(false); p.Connected {
+		t.Errorf("Unexpected offline presence %+v", p)
+	}
+	if _, ok := srv.Presence("868020041234567"); ok {
+		t.Error("Expected no presence of an unknown device")
+	}
+}
@@ -0,0 +1,261 @@
+// Package sim detects SIM swaps: a device reporting other SIM identifiers
+// (ICCID, IMSI) than it reported before, possibly in an earlier session.
+//
+// The VL103M reports its SIM identifiers in information transfer packets
+// (0x94): ICCID packets (type 0x0A) carry the IMSI and the ICCID, terminal
+// synchronization packets (type 0x04) may carry them too. The
+// AlarmSIMChanged alarm (0x10) only tells that the SIM changed. A Tracker
+// keeps the last identifiers of each device in a Store and reports every
+// change with the old and new identifiers, which the store keeps as an
+// audit trail.
+//
+// Example usage:
+//
+//	tracker := sim.NewTracker(sim.WithHandler(func(c sim.Change) {
+//	    log.Printf("%s: SIM %s replaced by %s", c.IMEI, c.Old.ICCID, c.New.ICCID)
+//	}))
+//
+//	srv.OnPacket(func(s *server.Session, p packet.Packet) {
+//	    tracker.Observe(s.IMEI(), p)
+//	})
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// DefaultHistory is the number of changes a MemoryStore keeps per device
+const DefaultHistory = 32
+
+// Identity is the SIM identifiers reported by a device. Either may be empty
+// when the device reported only one of them.
+type Identity struct {
+	ICCID string `json:"iccid,omitempty"`
+	IMSI  string `json:"imsi,omitempty"`
+
+	// Time is when the identifiers were last reported
+	Time time.Time `json:"time"`
+}
+
+// IsZero reports whether no identifier is set
+func (id Identity) IsZero() bool {
+	return id.ICCID == "" && id.IMSI == ""
+}
+
+// differs reports whether an identifier set in both identities differs
+func (id Identity) differs(other Identity) bool {
+	return (id.ICCID != "" && other.ICCID != "" && id.ICCID != other.ICCID) ||
+		(id.IMSI != "" && other.IMSI != "" && id.IMSI != other.IMSI)
+}
+
+// merge returns the identity with the identifiers set in other replaced
+func (id Identity) merge(other Identity) Identity {
+	if other.ICCID != "" {
+		id.ICCID = other.ICCID
+	}
+	if other.IMSI != "" {
+		id.IMSI = other.IMSI
+	}
+	id.Time = other.Time
+	return id
+}
+
+// Change is a SIM swap of a device
+type Change struct {
+	IMEI string   `json:"imei"`
+	Old  Identity `json:"old"`
+	New  Identity `json:"new"`
+
+	// Alarmed reports whether the device raised AlarmSIMChanged since it
+	// reported the old identifiers
+	Alarmed bool `json:"alarmed"`
+}
+
+// String returns a human-readable representation
+func (c Change) String() string {
+	return fmt.Sprintf("SIMChange{IMEI: %s, ICCID: %s -> %s, IMSI: %s -> %s, Time: %s}",
+		c.IMEI, c.Old.ICCID, c.New.ICCID, c.Old.IMSI, c.New.IMSI, c.New.Time.Format(time.RFC3339))
+}
+
+// Store keeps the last identifiers and the changes of each device. Its
+// methods must be safe for concurrent use.
+type Store interface {
+	// Identity returns the last identifiers of a device
+	Identity(imei string) (Identity, bool)
+
+	// SetIdentity replaces the identifiers of a device
+	SetIdentity(imei string, id Identity)
+
+	// AddChange records a change
+	AddChange(c Change)
+
+	// Changes returns the recorded changes of a device, oldest first
+	Changes(imei string) []Change
+}
+
+// MemoryStore is a Store in memory keeping the last DefaultHistory changes
+// of each device
+type MemoryStore struct {
+	mu         sync.Mutex
+	identities map[string]Identity
+	changes    map[string][]Change
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		identities: make(map[string]Identity),
+		changes:    make(map[string][]Change),
+	}
+}
+
+// Identity implements Store
+func (s *MemoryStore) Identity(imei string) (Identity, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.identities[imei]
+	return id, ok
+}
+
+// SetIdentity implements Store
+func (s *MemoryStore) SetIdentity(imei string, id Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identities[imei] = id
+}
+
+// AddChange implements Store
+func (s *MemoryStore) AddChange(c Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := append(s.changes[c.IMEI], c)
+	if len(changes) > DefaultHistory {
+		changes = changes[len(changes)-DefaultHistory:]
+	}
+	s.changes[c.IMEI] = changes
+}
+
+// Changes implements Store
+func (s *MemoryStore) Changes(imei string) []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Change(nil), s.changes[imei]...)
+}
+
+// Handler is called for every change
+type Handler func(Change)
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithStore keeps the identifiers and changes in s (default a MemoryStore)
+func WithStore(s Store) Option {
+	return func(t *Tracker) {
+		t.store = s
+	}
+}
+
+// WithHandler sets the callback invoked for every change
+func WithHandler(h Handler) Option {
+	return func(t *Tracker) {
+		t.handler = h
+	}
+}
+
+// Tracker compares the SIM identifiers reported by each device with the
+// last ones in its store. It is safe for concurrent use.
+type Tracker struct {
+	store   Store
+	handler Handler
+
+	mu      sync.Mutex
+	alarmed map[string]bool // AlarmSIMChanged raised since the last identifiers
+}
+
+// NewTracker creates a SIM change tracker
+func NewTracker(opts ...Option) *Tracker {
+	t := &Tracker{alarmed: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.store == nil {
+		t.store = NewMemoryStore()
+	}
+	return t
+}
+
+// Observe feeds a decoded packet of a device into the tracker and returns
+// the change it reports, if any. The first identifiers of a device only
+// seed the store. Packets without SIM identifiers are ignored, except
+// AlarmSIMChanged alarms, which are recorded for the next change.
+func (t *Tracker) Observe(imei string, p packet.Packet) (Change, bool) {
+	if imei == "" {
+		return Change{}, false
+	}
+	if a, ok := p.(packet.PacketWithAlarm); ok && a.GetAlarmType() == protocol.AlarmSIMChanged {
+		t.mu.Lock()
+		t.alarmed[imei] = true
+		t.mu.Unlock()
+		return Change{}, false
+	}
+	id, ok := identity(p)
+	if !ok {
+		return Change{}, false
+	}
+
+	t.mu.Lock()
+	old, known := t.store.Identity(imei)
+	t.store.SetIdentity(imei, old.merge(id))
+	if !known || !old.differs(id) {
+		t.mu.Unlock()
+		return Change{}, false
+	}
+	c := Change{IMEI: imei, Old: old, New: old.merge(id), Alarmed: t.alarmed[imei]}
+	delete(t.alarmed, imei)
+	t.store.AddChange(c)
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler != nil {
+		handler(c)
+	}
+	return c, true
+}
+
+// Identity returns the last identifiers reported by a device
+func (t *Tracker) Identity(imei string) (Identity, bool) {
+	return t.store.Identity(imei)
+}
+
+// Changes returns the recorded changes of a device, oldest first
+func (t *Tracker) Changes(imei string) []Change {
+	return t.store.Changes(imei)
+}
+
+// identity returns the SIM identifiers carried by a packet
+func identity(p packet.Packet) (Identity, bool) {
+	v, ok := p.(*packet.InfoTransferPacket)
+	if !ok {
+		return Identity{}, false
+	}
+	var id Identity
+	switch {
+	case v.SubProtocol == protocol.InfoTypeICCID:
+		id = Identity{ICCID: v.ICCID, IMSI: v.IMSI}
+	case v.SubProtocol == protocol.InfoTypeTerminalSync && v.TerminalSync != nil:
+		id = Identity{ICCID: v.TerminalSync.ICCID, IMSI: v.TerminalSync.IMSI}
+	}
+	if id.IsZero() {
+		return Identity{}, false
+	}
+	id.Time = v.Timestamp()
+	if id.Time.IsZero() {
+		id.Time = time.Now()
+	}
+	return id, true
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+var baseTime = time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+
+// iccid builds an ICCID information transfer packet
+func iccid(imsi, iccid string, offset time.Duration) *packet.InfoTransferPacket {
+	p := packet.NewInfoTransferPacket(protocol.InfoTypeICCID, nil)
+	p.IMEI, p.IMSI, p.ICCID = testIMEI, imsi, iccid
+	p.ParsedAt = baseTime.Add(offset)
+	return p
+}
+
+// terminalSync builds a terminal synchronization packet reporting an ICCID
+func terminalSync(iccid string, offset time.Duration) *packet.InfoTransferPacket {
+	p := packet.NewInfoTransferPacket(protocol.InfoTypeTerminalSync, nil)
+	p.TerminalSync = &packet.TerminalSyncData{ICCID: iccid}
+	p.ParsedAt = baseTime.Add(offset)
+	return p
+}
+
+func simAlarm() *packet.AlarmPacket {
+	return packet.NewAlarmPacket(types.NewDateTime(baseTime), types.Coordinates{}, protocol.AlarmSIMChanged)
+}
+
+func TestTracker_Observe(t *testing.T) {
+	const (
+		imsi1, iccid1 = "460011234567890", "89860012345678901234"
+		imsi2, iccid2 = "460029876543210", "89860098765432109876"
+	)
+	tests := []struct {
+		name        string
+		packet      packet.Packet
+		wantChange  bool
+		wantOld     Identity
+		wantNew     Identity
+		wantAlarmed bool
+	}{
+		{"first identity seeds", iccid(imsi1, iccid1, 0), false, Identity{}, Identity{}, false},
+		{"same identity", iccid(imsi1, iccid1, time.Minute), false, Identity{}, Identity{}, false},
+		{"same iccid in sync", terminalSync(iccid1, 2*time.Minute), false, Identity{}, Identity{}, false},
+		{"unrelated packet", packet.NewInfoTransferPacket(protocol.InfoTypeExternalVoltage, []byte{4, 0x9F}), false, Identity{}, Identity{}, false},
+		{"sim changed alarm", simAlarm(), false, Identity{}, Identity{}, false},
+		{"swap", iccid(imsi2, iccid2, time.Hour), true,
+			Identity{ICCID: iccid1, IMSI: imsi1, Time: baseTime.Add(2 * time.Minute)},
+			Identity{ICCID: iccid2, IMSI: imsi2, Time: baseTime.Add(time.Hour)}, true},
+		{"swap back in sync keeps the imsi", terminalSync(iccid1, 2*time.Hour), true,
+			Identity{ICCID: iccid2, IMSI: imsi2, Time: baseTime.Add(time.Hour)},
+			Identity{ICCID: iccid1, IMSI: imsi2, Time: baseTime.Add(2 * time.Hour)}, false},
+	}
+
+	var handled []Change
+	tracker := NewTracker(WithHandler(func(c Change) { handled = append(handled, c) }))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := tracker.Observe(testIMEI, tt.packet)
+			if ok != tt.wantChange {
+				t.Fatalf("Expected change %v, got %v (%s)", tt.wantChange, ok, c)
+			}
+			if !ok {
+				return
+			}
+			if c.IMEI != testIMEI || c.Old != tt.wantOld || c.New != tt.wantNew || c.Alarmed != tt.wantAlarmed {
+				t.Errorf("Unexpected change %+v", c)
+			}
+		})
+	}
+
+	changes := tracker.Changes(testIMEI)
+	if len(changes) != 2 || len(handled) != 2 || changes[1] != handled[1] {
+		t.Errorf("Expected 2 recorded changes, got %+v (handled %+v)", changes, handled)
+	}
+	if id, ok := tracker.Identity(testIMEI); !ok || id.ICCID != iccid1 {
+		t.Errorf("Unexpected identity %+v", id)
+	}
+	if _, ok := tracker.Observe("", iccid(imsi2, iccid2, 0)); ok {
+		t.Error("Expected no change without IMEI")
+	}
+}
+
+func TestTracker_StoreAcrossTrackers(t *testing.T) {
+	// A store shared by trackers keeps identities across restarts
+	store := NewMemoryStore()
+	NewTracker(WithStore(store)).Observe(testIMEI, iccid("1", "2", 0))
+
+	c, ok := NewTracker(WithStore(store)).Observe(testIMEI, iccid("1", "3", time.Minute))
+	if !ok || c.Old.ICCID != "2" || c.New.ICCID != "3" {
+		t.Errorf("Unexpected change %+v", c)
+	}
+}
+
+func TestMemoryStore_History(t *testing.T) {
+	store := NewMemoryStore()
+	for i := range DefaultHistory + 5 {
+		store.AddChange(Change{IMEI: testIMEI, New: Identity{ICCID: string(rune('a' + i%26))}})
+	}
+	changes := store.Changes(testIMEI)
+	if len(changes) != DefaultHistory || changes[0].New.ICCID != string(rune('a'+5)) {
+		t.Errorf("Expected the last %d changes, got %d starting with %+v", DefaultHistory, len(changes), changes[0])
+	}
+	if len(store.Changes("868020041234567")) != 0 {
+		t.Error("Expected no changes of an unknown device")
+	}
+}
@@ -0,0 +1,287 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Default rotation settings
+const (
+	// DefaultMaxSize is the file size that triggers rotation (64 MiB)
+	DefaultMaxSize int64 = 64 << 20
+
+	// DefaultMaxBackups is the number of rotated files kept
+	DefaultMaxBackups = 7
+)
+
+// rotatedTimeFormat is the timestamp inserted into rotated file names
+const rotatedTimeFormat = "20060102_150405"
+
+// ErrClosed is returned when writing to a closed sink
+var ErrClosed = errors.New("sink closed")
+
+// FileSink writes records as NDJSON to a file and rotates it by size and age.
+//
+// The active file always has the configured name, which is what log shippers
+// tail. On rotation it is renamed to "<name>-<YYYYMMDD_HHMMSS><ext>" and a new
+// file is opened. FileSink is safe for concurrent use.
+type FileSink struct {
+	mu sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// FileOption configures a FileSink
+type FileOption func(*FileSink)
+
+// WithMaxSize sets the size in bytes that triggers rotation (0 disables)
+func WithMaxSize(bytes int64) FileOption {
+	return func(s *FileSink) {
+		s.maxSize = bytes
+	}
+}
+
+// WithMaxAge rotates the file once it has been open for d (0 disables)
+func WithMaxAge(d time.Duration) FileOption {
+	return func(s *FileSink) {
+		s.maxAge = d
+	}
+}
+
+// WithMaxBackups sets the number of rotated files kept (0 keeps all)
+func WithMaxBackups(n int) FileOption {
+	return func(s *FileSink) {
+		s.maxBackups = n
+	}
+}
+
+// WithFileClock sets the time source used for rotation (for testing)
+func WithFileClock(now func() time.Time) FileOption {
+	return func(s *FileSink) {
+		s.now = now
+	}
+}
+
+// NewFileSink opens (or appends to) the NDJSON file at path
+func NewFileSink(path string, opts ...FileOption) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    DefaultMaxSize,
+		maxBackups: DefaultMaxBackups,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create sink directory: %w", err)
+		}
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the active file path
+func (s *FileSink) Path() string {
+	return s.path
+}
+
+// Write appends one record as a single line, rotating first if needed
+func (s *FileSink) Write(rec export.Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if s.shouldRotate(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// WritePacket converts a packet with export.NewRecord and writes it
+func (s *FileSink) WritePacket(imei string, p packet.Packet, receivedAt time.Time) error {
+	return s.Write(export.NewRecord(imei, p, receivedAt))
+}
+
+// Rotate forces a rotation of the active file
+func (s *FileSink) Rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	return s.rotate()
+}
+
+// Close closes the active file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation.
+// A non-empty file is never left to exceed the size limit, but a single
+// record larger than the limit is still written.
+func (s *FileSink) shouldRotate(n int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.maxSize > 0 && s.size+n > s.maxSize {
+		return true
+	}
+	return s.maxAge > 0 && s.now().Sub(s.openedAt) >= s.maxAge
+}
+
+// open opens the active file in append mode
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open sink file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat sink file: %w", err)
+	}
+
+	s.file = f
+	s.size = info.Size()
+	s.openedAt = s.now()
+	return nil
+}
+
+// rotate renames the active file and opens a new one
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(s.path, s.backupName(s.now())); err != nil {
+		return fmt.Errorf("rotate sink file: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// backupName returns an unused rotated file name for t
+func (s *FileSink) backupName(t time.Time) string {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, t.Format(rotatedTimeFormat), ext)
+
+	// Several rotations within the same second get a numeric suffix
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(rotatedTimeFormat), i, ext)
+	}
+}
+
+// Backups returns the rotated files, oldest first
+func (s *FileSink) Backups() ([]string, error) {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext)
+
+	matches, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Order by the name timestamp, then by the same-second suffix
+	type backup struct {
+		name  string
+		stamp string
+		seq   int
+	}
+	backups := make([]backup, 0, len(matches))
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, base+"-"), ext)
+		seq := 0
+		if dot := strings.IndexByte(stamp, '.'); dot >= 0 {
+			seq, _ = strconv.Atoi(stamp[dot+1:])
+			stamp = stamp[:dot]
+		}
+		if _, err := time.Parse(rotatedTimeFormat, stamp); err != nil {
+			continue // not a rotated file
+		}
+		backups = append(backups, backup{name: m, stamp: stamp, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].seq < backups[j].seq
+	})
+
+	names := make([]string, len(backups))
+	for i, b := range backups {
+		names[i] = b.name
+	}
+	return names, nil
+}
+
+// prune removes the oldest rotated files beyond maxBackups
+func (s *FileSink) prune() error {
+	if s.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+const testIMEI = "359339073930520"
+
+func readRecords(t *testing.T, path string) []export.Record {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	var recs []export.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec export.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestFileSink_WritesVersionedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "events.ndjson")
+	s, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+
+	received := time.Date(2024, 6, 15, 14, 30, 45, 0, time.UTC)
+	if err := s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, received); err != nil {
+		t.Fatalf("WritePacket failed: %v", err)
+	}
+	s.Close()
+
+	recs := readRecords(t, path)
+	if len(recs) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(recs))
+	}
+	if recs[0].Schema != export.SchemaVersion {
+		t.Errorf("Expected schema %d, got %d", export.SchemaVersion, recs[0].Schema)
+	}
+	if recs[0].IMEI != testIMEI || recs[0].Type != "Heartbeat" {
+		t.Errorf("Unexpected record: %+v", recs[0])
+	}
+	if recs[0].ReceivedAt == nil || !recs[0].ReceivedAt.Equal(received) {
+		t.Errorf("Unexpected receive time: %v", recs[0].ReceivedAt)
+	}
+
+	if err := s.Write(export.Record{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	now := time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+
+	// Each heartbeat record is well over 100 bytes, so every write rotates
+	s, err := NewFileSink(path,
+		WithMaxSize(100),
+		WithMaxBackups(2),
+		WithFileClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 4; i++ {
+		hb := &packet.HeartbeatPacket{}
+		hb.SerialNum = uint16(i)
+		if err := s.WritePacket(testIMEI, hb, time.Time{}); err != nil {
+			t.Fatalf("WritePacket %d failed: %v", i, err)
+		}
+	}
+
+	backups, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Backups failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups after pruning, got %v", backups)
+	}
+
+	// Oldest kept backup holds serial 1 (serial 0 was pruned)
+	if recs := readRecords(t, backups[0]); len(recs) != 1 || recs[0].Serial != 1 {
+		t.Errorf("Unexpected oldest backup contents: %+v", recs)
+	}
+	if recs := readRecords(t, path); len(recs) != 1 || recs[0].Serial != 3 {
+		t.Errorf("Unexpected active file contents: %+v", recs)
+	}
+}
+
+func TestFileSink_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	now := time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+
+	s, err := NewFileSink(path,
+		WithMaxAge(time.Hour),
+		WithFileClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer s.Close()
+
+	s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Time{})
+	now = now.Add(30 * time.Minute)
+	s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Time{})
+
+	if backups, _ := s.Backups(); len(backups) != 0 {
+		t.Fatalf("Expected no rotation within max age, got %v", backups)
+	}
+
+	now = now.Add(time.Hour)
+	s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Time{})
+
+	backups, _ := s.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+	if want := filepath.Join(filepath.Dir(path), "events-20240615_153000.ndjson"); backups[0] != want {
+		t.Errorf("Expected backup %s, got %s", want, backups[0])
+	}
+	if n := len(readRecords(t, backups[0])); n != 2 {
+		t.Errorf("Expected 2 records in backup, got %d", n)
+	}
+}
+
+func TestFileSink_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	for i := 0; i < 2; i++ {
+		s, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("NewFileSink failed: %v", err)
+		}
+		s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Time{})
+		s.Close()
+	}
+
+	if n := len(readRecords(t, path)); n != 2 {
+		t.Errorf("Expected 2 records after reopen, got %d", n)
+	}
+}
@@ -0,0 +1,321 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// Default Kafka sink settings
+const (
+	// DefaultBatchSize is the number of messages produced in one batch
+	DefaultBatchSize = 100
+
+	// DefaultBatchTimeout is how long a partial batch waits for more messages
+	DefaultBatchTimeout = time.Second
+
+	// DefaultQueueSize is the number of messages buffered ahead of the producer
+	DefaultQueueSize = 10000
+
+	// DefaultMaxRetries is the number of times a failed batch is retried
+	DefaultMaxRetries = 3
+
+	// DefaultProduceTimeout bounds each produce call
+	DefaultProduceTimeout = 10 * time.Second
+)
+
+// ErrQueueFull is returned when a record cannot be queued before the
+// enqueue timeout because the producer is falling behind
+var ErrQueueFull = errors.New("sink queue full")
+
+// KafkaMessage is one message produced to Kafka
+type KafkaMessage struct {
+	Topic string
+	Key   []byte // the IMEI, so the records of a device stay ordered in one partition
+	Value []byte
+	Time  time.Time
+}
+
+// KafkaProducer sends batches of messages to Kafka. It must not retain msgs
+// after returning. A segmentio/kafka-go adapter is a few lines:
+//
+//	type kafkaAdapter struct{ w *kafka.Writer }
+//
+//	func (a kafkaAdapter) Produce(ctx context.Context, msgs []sink.KafkaMessage) error {
+//	    out := make([]kafka.Message, len(msgs))
+//	    for i, m := range msgs {
+//	        out[i] = kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time}
+//	    }
+//	    return a.w.WriteMessages(ctx, out...)
+//	}
+//
+// With a kafka.Writer, leave its Topic empty (the messages carry theirs) and
+// use kafka.Hash as Balancer so messages are partitioned by key.
+type KafkaProducer interface {
+	// Produce writes msgs, returning once they are acknowledged
+	Produce(ctx context.Context, msgs []KafkaMessage) error
+}
+
+// KafkaStats counts the messages handled by a KafkaSink
+type KafkaStats struct {
+	Produced uint64 `json:"produced"`
+	Dropped  uint64 `json:"dropped"` // rejected with ErrQueueFull
+	Failed   uint64 `json:"failed"`  // lost after the retries
+	Queued   int    `json:"queued"`
+}
+
+// KafkaSink writes records as JSON messages keyed by IMEI to a Kafka topic.
+//
+// Writes are queued and produced in batches by a background goroutine. When
+// the producer falls behind (e.g. during a broker outage) the queue fills up
+// and Write blocks, up to the enqueue timeout, pushing the backpressure back
+// to the caller instead of growing memory without bound. KafkaSink is safe
+// for concurrent use.
+type KafkaSink struct {
+	producer       KafkaProducer
+	topic          string
+	rawTopic       string
+	batchSize      int
+	batchTimeout   time.Duration
+	queueSize      int
+	enqueueTimeout time.Duration
+	maxRetries     int
+	retryBackoff   time.Duration
+	onError        func(error)
+
+	mu     sync.RWMutex // held for reading while queueing, for writing to close
+	closed bool
+	queue  chan KafkaMessage
+	done   chan struct{}
+
+	produced atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+var _ Sink = (*KafkaSink)(nil)
+
+// KafkaOption configures a KafkaSink
+type KafkaOption func(*KafkaSink)
+
+// WithBatchSize sets the number of messages produced in one batch
+// (default DefaultBatchSize)
+func WithBatchSize(n int) KafkaOption {
+	return func(s *KafkaSink) {
+		s.batchSize = n
+	}
+}
+
+// WithBatchTimeout sets how long a partial batch waits for more messages
+// (default DefaultBatchTimeout)
+func WithBatchTimeout(d time.Duration) KafkaOption {
+	return func(s *KafkaSink) {
+		s.batchTimeout = d
+	}
+}
+
+// WithQueueSize sets the number of messages buffered ahead of the producer
+// (default DefaultQueueSize)
+func WithQueueSize(n int) KafkaOption {
+	return func(s *KafkaSink) {
+		s.queueSize = n
+	}
+}
+
+// WithEnqueueTimeout makes Write give up with ErrQueueFull after waiting d
+// for room in a full queue (default 0, wait until there is room)
+func WithEnqueueTimeout(d time.Duration) KafkaOption {
+	return func(s *KafkaSink) {
+		s.enqueueTimeout = d
+	}
+}
+
+// WithMaxRetries sets the number of times a failed batch is retried before
+// it is dropped (default DefaultMaxRetries)
+func WithMaxRetries(n int) KafkaOption {
+	return func(s *KafkaSink) {
+		s.maxRetries = n
+	}
+}
+
+// WithRawTopic also produces the raw hex frame of every record to topic,
+// keyed by IMEI. The decoded messages never carry the raw frame.
+func WithRawTopic(topic string) KafkaOption {
+	return func(s *KafkaSink) {
+		s.rawTopic = topic
+	}
+}
+
+// WithKafkaErrorHandler sets the function called with the errors of batches
+// dropped after the retries (default none)
+func WithKafkaErrorHandler(fn func(error)) KafkaOption {
+	return func(s *KafkaSink) {
+		s.onError = fn
+	}
+}
+
+// NewKafkaSink creates a sink producing to topic through producer and starts
+// its batching goroutine
+func NewKafkaSink(producer KafkaProducer, topic string, opts ...KafkaOption) *KafkaSink {
+	s := &KafkaSink{
+		producer:     producer,
+		topic:        topic,
+		batchSize:    DefaultBatchSize,
+		batchTimeout: DefaultBatchTimeout,
+		queueSize:    DefaultQueueSize,
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: 100 * time.Millisecond,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.batchSize = max(s.batchSize, 1)
+	s.queue = make(chan KafkaMessage, max(s.queueSize, s.batchSize))
+
+	go s.run()
+	return s
+}
+
+// Write queues one record, blocking while the queue is full
+func (s *KafkaSink) Write(rec export.Record) error {
+	raw := rec.Raw
+	rec.Raw = ""
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	at := time.Now()
+	if rec.ReceivedAt != nil {
+		at = *rec.ReceivedAt
+	}
+	key := []byte(rec.IMEI)
+
+	if err := s.enqueue(KafkaMessage{Topic: s.topic, Key: key, Value: value, Time: at}); err != nil {
+		return err
+	}
+	if s.rawTopic != "" && raw != "" {
+		return s.enqueue(KafkaMessage{Topic: s.rawTopic, Key: key, Value: []byte(raw), Time: at})
+	}
+	return nil
+}
+
+// WritePacket converts a packet with export.NewRecord and writes it
+func (s *KafkaSink) WritePacket(imei string, p packet.Packet, receivedAt time.Time) error {
+	return s.Write(export.NewRecord(imei, p, receivedAt))
+}
+
+// Stats returns the message counters
+func (s *KafkaSink) Stats() KafkaStats {
+	return KafkaStats{
+		Produced: s.produced.Load(),
+		Dropped:  s.dropped.Load(),
+		Failed:   s.failed.Load(),
+		Queued:   len(s.queue),
+	}
+}
+
+// Close produces the queued messages and stops the sink
+func (s *KafkaSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+// enqueue adds a message to the queue
+func (s *KafkaSink) enqueue(m KafkaMessage) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	select {
+	case s.queue <- m:
+		return nil
+	default:
+	}
+	if s.enqueueTimeout <= 0 {
+		s.queue <- m
+		return nil
+	}
+	timer := time.NewTimer(s.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.queue <- m:
+		return nil
+	case <-timer.C:
+		s.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// run collects queued messages into batches until the queue is closed
+func (s *KafkaSink) run() {
+	defer close(s.done)
+
+	batch := make([]KafkaMessage, 0, s.batchSize)
+	timer := time.NewTimer(s.batchTimeout)
+	timer.Stop()
+	for {
+		select {
+		case m, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(s.batchTimeout)
+			}
+			batch = append(batch, m)
+			if len(batch) < s.batchSize {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+		s.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush produces a batch, retrying with a growing backoff
+func (s *KafkaSink) flush(batch []KafkaMessage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * s.retryBackoff)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultProduceTimeout)
+		err = s.producer.Produce(ctx, batch)
+		cancel()
+		if err == nil {
+			s.produced.Add(uint64(len(batch)))
+			return
+		}
+	}
+
+	s.failed.Add(uint64(len(batch)))
+	if s.onError != nil {
+		s.onError(fmt.Errorf("kafka: produce %d messages: %w", len(batch), err))
+	}
+}
@@ -0,0 +1,157 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// producer is a KafkaProducer recording batches
+type producer struct {
+	mu      sync.Mutex
+	batches [][]KafkaMessage
+	fail    int           // number of calls failing before success
+	block   chan struct{} // blocks Produce until closed, if set
+}
+
+func (p *producer) Produce(_ context.Context, msgs []KafkaMessage) error {
+	if p.block != nil {
+		<-p.block
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail > 0 {
+		p.fail--
+		return errors.New("broker unavailable")
+	}
+	p.batches = append(p.batches, append([]KafkaMessage(nil), msgs...))
+	return nil
+}
+
+func (p *producer) sizes() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var sizes []int
+	for _, b := range p.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestKafkaSink_Batches(t *testing.T) {
+	p := &producer{}
+	s := NewKafkaSink(p, "jimi.packets", WithBatchSize(2), WithBatchTimeout(time.Hour), WithRawTopic("jimi.raw"))
+
+	received := time.Date(2024, 6, 15, 14, 30, 45, 0, time.UTC)
+	rec := export.NewRecord(testIMEI, &packet.HeartbeatPacket{}, received)
+	rec.Raw = "78780A13"
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, received); err != nil {
+		t.Fatalf("WritePacket failed: %v", err)
+	}
+	s.Close()
+
+	// The first record and its raw frame fill a batch; Close flushes the rest
+	if got := p.sizes(); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Fatalf("Expected batches of 2 and 1, got %v", got)
+	}
+	decoded, raw := p.batches[0][0], p.batches[0][1]
+	if decoded.Topic != "jimi.packets" || string(decoded.Key) != testIMEI || !decoded.Time.Equal(received) {
+		t.Errorf("Unexpected decoded message %+v", decoded)
+	}
+	var got export.Record
+	if err := json.Unmarshal(decoded.Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.IMEI != testIMEI || got.Type != "Heartbeat" || got.Raw != "" {
+		t.Errorf("Unexpected record %+v", got)
+	}
+	if raw.Topic != "jimi.raw" || string(raw.Key) != testIMEI || string(raw.Value) != "78780A13" {
+		t.Errorf("Unexpected raw message %+v", raw)
+	}
+
+	if stats := s.Stats(); stats.Produced != 3 || stats.Queued != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if err := s.Write(rec); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestKafkaSink_BatchTimeout(t *testing.T) {
+	p := &producer{}
+	s := NewKafkaSink(p, "t", WithBatchTimeout(10*time.Millisecond))
+	defer s.Close()
+
+	s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Now())
+	deadline := time.Now().Add(2 * time.Second)
+	for len(p.sizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the partial batch produced after the timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKafkaSink_Backpressure(t *testing.T) {
+	p := &producer{block: make(chan struct{})}
+	s := NewKafkaSink(p, "t", WithBatchSize(1), WithQueueSize(1), WithEnqueueTimeout(20*time.Millisecond))
+
+	// One message is held by the blocked producer, one fills the queue
+	var err error
+	for range 3 {
+		if err = s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Now()); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	close(p.block)
+	s.Close()
+
+	if stats := s.Stats(); stats.Produced != 2 || stats.Dropped != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestKafkaSink_Retries(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       int
+		wantFailed uint64
+	}{
+		{"recovers", 2, 0},
+		{"gives up", 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &producer{fail: tt.fail}
+			var errs []error
+			s := NewKafkaSink(p, "t", WithMaxRetries(2), WithKafkaErrorHandler(func(err error) {
+				errs = append(errs, err)
+			}))
+			s.retryBackoff = time.Millisecond
+
+			s.WritePacket(testIMEI, &packet.HeartbeatPacket{}, time.Now())
+			s.Close()
+
+			stats := s.Stats()
+			if stats.Failed != tt.wantFailed || stats.Produced != 1-tt.wantFailed {
+				t.Errorf("Unexpected stats %+v", stats)
+			}
+			if uint64(len(errs)) != tt.wantFailed {
+				t.Errorf("Expected %d errors, got %v", tt.wantFailed, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package sink
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Default priority sink settings
+const (
+	// DefaultLatencyBudget is how long an urgent record may take from Write
+	// to the sink
+	DefaultLatencyBudget = time.Second
+
+	// DefaultUrgentQueueSize is the number of urgent records buffered
+	DefaultUrgentQueueSize = 1024
+)
+
+// IsAlarm reports whether a record is an alarm (0x26, 0x27, 0xA4), the
+// records a PrioritySink writes through its urgent lane by default
+func IsAlarm(rec export.Record) bool {
+	n, err := strconv.ParseUint(rec.Protocol, 0, 8)
+	if err != nil {
+		return false
+	}
+	switch n {
+	case protocol.ProtocolAlarm, protocol.ProtocolAlarmMultiFence, protocol.ProtocolAlarmMultiFence4G:
+		return true
+	}
+	return false
+}
+
+// PriorityStats counts the records handled by a PrioritySink
+type PriorityStats struct {
+	Urgent       uint64        `json:"urgent"`        // urgent records written
+	Bulk         uint64        `json:"bulk"`          // bulk records written
+	Dropped      uint64        `json:"dropped"`       // bulk records rejected with ErrQueueFull
+	Failed       uint64        `json:"failed"`        // records the sink failed to write
+	BudgetMisses uint64        `json:"budget_misses"` // urgent records written after the budget
+	MaxLatency   time.Duration `json:"max_latency"`   // slowest urgent record, Write to written
+	UrgentQueued int           `json:"urgent_queued"`
+	BulkQueued   int           `json:"bulk_queued"`
+}
+
+// PrioritySink queues records for another sink in two lanes, so alarms stay
+// fast when location traffic saturates the sink.
+//
+// Records selected by the priority function (IsAlarm by default) go through
+// the urgent lane, the others through the bulk lane. Each lane has its own
+// writer, and the bulk writer yields whenever urgent records are pending, so
+// an SOS waits at most for the bulk write in progress instead of for the
+// whole backlog. Urgent records are never dropped: Write blocks while the
+// urgent lane is full. A full bulk lane blocks Write up to the enqueue
+// timeout, then drops the record with ErrQueueFull. Urgent records written
+// later than the latency budget are counted and reported to the budget
+// handler. PrioritySink is safe for concurrent use when the sinks are.
+type PrioritySink struct {
+	next           Sink
+	urgentNext     Sink
+	priority       func(export.Record) bool
+	budget         time.Duration
+	urgentSize     int
+	bulkSize       int
+	enqueueTimeout time.Duration
+	onError        func(error)
+	onBudgetMiss   func(export.Record, time.Duration)
+
+	mu     sync.RWMutex // held for reading while queueing, for writing to close
+	closed bool
+	urgent chan queuedRecord
+	bulk   chan queuedRecord
+	wg     sync.WaitGroup
+
+	laneMu  sync.Mutex
+	idle    sync.Cond // broadcast when no urgent record is pending
+	pending int       // urgent records queued or being written
+
+	urgentWritten atomic.Uint64
+	bulkWritten   atomic.Uint64
+	dropped       atomic.Uint64
+	failed        atomic.Uint64
+	budgetMisses  atomic.Uint64
+	maxLatency    atomic.Int64
+}
+
+var _ Sink = (*PrioritySink)(nil)
+
+// queuedRecord is a record waiting in a lane
+type queuedRecord struct {
+	rec export.Record
+	at  time.Time
+}
+
+// PriorityOption configures a PrioritySink
+type PriorityOption func(*PrioritySink)
+
+// WithPriority sets the function selecting the records of the urgent lane
+// (default IsAlarm)
+func WithPriority(fn func(export.Record) bool) PriorityOption {
+	return func(s *PrioritySink) {
+		s.priority = fn
+	}
+}
+
+// WithLatencyBudget sets how long an urgent record may take from Write to
+// the sink before it counts as a budget miss (default DefaultLatencyBudget)
+func WithLatencyBudget(d time.Duration) PriorityOption {
+	return func(s *PrioritySink) {
+		if d > 0 {
+			s.budget = d
+		}
+	}
+}
+
+// WithUrgentQueueSize sets the number of urgent records buffered (default
+// DefaultUrgentQueueSize)
+func WithUrgentQueueSize(n int) PriorityOption {
+	return func(s *PrioritySink) {
+		s.urgentSize = n
+	}
+}
+
+// WithBulkQueueSize sets the number of bulk records buffered (default
+// DefaultQueueSize)
+func WithBulkQueueSize(n int) PriorityOption {
+	return func(s *PrioritySink) {
+		s.bulkSize = n
+	}
+}
+
+// WithBulkEnqueueTimeout makes Write drop a bulk record with ErrQueueFull
+// after waiting d for room in a full bulk lane (default 0, wait until there
+// is room)
+func WithBulkEnqueueTimeout(d time.Duration) PriorityOption {
+	return func(s *PrioritySink) {
+		s.enqueueTimeout = d
+	}
+}
+
+// WithUrgentSink writes the urgent records to another sink, e.g. a second
+// Kafka producer whose queue the bulk traffic cannot fill (default the
+// sink of the bulk lane)
+func WithUrgentSink(next Sink) PriorityOption {
+	return func(s *PrioritySink) {
+		s.urgentNext = next
+	}
+}
+
+// WithPriorityErrorHandler sets the function called with the errors of the
+// records the sinks failed to write (default none)
+func WithPriorityErrorHandler(fn func(error)) PriorityOption {
+	return func(s *PrioritySink) {
+		s.onError = fn
+	}
+}
+
+// WithBudgetMissHandler sets the function called for every urgent record
+// written later than the latency budget, with its latency (default none)
+func WithBudgetMissHandler(fn func(rec export.Record, latency time.Duration)) PriorityOption {
+	return func(s *PrioritySink) {
+		s.onBudgetMiss = fn
+	}
+}
+
+// NewPrioritySink creates a sink queueing records for next and starts the
+// writers of its lanes
+func NewPrioritySink(next Sink, opts ...PriorityOption) *PrioritySink {
+	s := &PrioritySink{
+		next:       next,
+		priority:   IsAlarm,
+		budget:     DefaultLatencyBudget,
+		urgentSize: DefaultUrgentQueueSize,
+		bulkSize:   DefaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.urgentNext == nil {
+		s.urgentNext = next
+	}
+	s.idle.L = &s.laneMu
+	s.urgent = make(chan queuedRecord, max(s.urgentSize, 1))
+	s.bulk = make(chan queuedRecord, max(s.bulkSize, 1))
+
+	s.wg.Add(2)
+	go s.runUrgent()
+	go s.runBulk()
+	return s
+}
+
+// Write queues one record in its lane
+func (s *PrioritySink) Write(rec export.Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	q := queuedRecord{rec: rec, at: time.Now()}
+	if s.priority(rec) {
+		s.laneMu.Lock()
+		s.pending++
+		s.laneMu.Unlock()
+		s.urgent <- q
+		return nil
+	}
+
+	select {
+	case s.bulk <- q:
+		return nil
+	default:
+	}
+	if s.enqueueTimeout <= 0 {
+		s.bulk <- q
+		return nil
+	}
+	timer := time.NewTimer(s.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.bulk <- q:
+		return nil
+	case <-timer.C:
+		s.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// WritePacket converts a packet with export.NewRecord and writes it
+func (s *PrioritySink) WritePacket(imei string, p packet.Packet, receivedAt time.Time) error {
+	return s.Write(export.NewRecord(imei, p, receivedAt))
+}
+
+// Stats returns the record counters
+func (s *PrioritySink) Stats() PriorityStats {
+	return PriorityStats{
+		Urgent:       s.urgentWritten.Load(),
+		Bulk:         s.bulkWritten.Load(),
+		Dropped:      s.dropped.Load(),
+		Failed:       s.failed.Load(),
+		BudgetMisses: s.budgetMisses.Load(),
+		MaxLatency:   time.Duration(s.maxLatency.Load()),
+		UrgentQueued: len(s.urgent),
+		BulkQueued:   len(s.bulk),
+	}
+}
+
+// Close writes the queued records, then closes the sinks
+func (s *PrioritySink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.urgent)
+	close(s.bulk)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	err := s.next.Close()
+	if s.urgentNext != s.next {
+		if uerr := s.urgentNext.Close(); err == nil {
+			err = uerr
+		}
+	}
+	return err
+}
+
+// runUrgent writes the urgent records until the lane is closed
+func (s *PrioritySink) runUrgent() {
+	defer s.wg.Done()
+	for q := range s.urgent {
+		if err := s.urgentNext.Write(q.rec); err != nil {
+			s.fail(err)
+		} else {
+			s.urgentWritten.Add(1)
+		}
+
+		latency := time.Since(q.at)
+		for {
+			cur := s.maxLatency.Load()
+			if int64(latency) <= cur || s.maxLatency.CompareAndSwap(cur, int64(latency)) {
+				break
+			}
+		}
+		if latency > s.budget {
+			s.budgetMisses.Add(1)
+			if s.onBudgetMiss != nil {
+				s.onBudgetMiss(q.rec, latency)
+			}
+		}
+
+		s.laneMu.Lock()
+		s.pending--
+		if s.pending == 0 {
+			s.idle.Broadcast()
+		}
+		s.laneMu.Unlock()
+	}
+}
+
+// runBulk writes the bulk records until the lane is closed, yielding to
+// pending urgent records
+func (s *PrioritySink) runBulk() {
+	defer s.wg.Done()
+	for q := range s.bulk {
+		s.laneMu.Lock()
+		for s.pending > 0 {
+			s.idle.Wait()
+		}
+		s.laneMu.Unlock()
+
+		if err := s.next.Write(q.rec); err != nil {
+			s.fail(err)
+			continue
+		}
+		s.bulkWritten.Add(1)
+	}
+}
+
+// fail counts a record the sink failed to write and reports the error
+func (s *PrioritySink) fail(err error) {
+	s.failed.Add(1)
+	if s.onError != nil {
+		s.onError(fmt.Errorf("priority sink: %w", err))
+	}
+}
@@ -0,0 +1,137 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// gatedSink records the records written, each write waiting for gate to close
+type gatedSink struct {
+	gate chan struct{}
+
+	mu      sync.Mutex
+	written []export.Record
+	closed  bool
+}
+
+func (s *gatedSink) Write(rec export.Record) error {
+	<-s.gate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, rec)
+	return nil
+}
+
+func (s *gatedSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestIsAlarm(t *testing.T) {
+	at := types.NewDateTime(time.Date(2024, 6, 15, 14, 30, 45, 0, time.UTC))
+	tests := []struct {
+		name string
+		p    packet.Packet
+		want bool
+	}{
+		{"alarm", packet.NewAlarmPacket(at, types.Coordinates{}, protocol.AlarmSOS), true},
+		{"location", packet.NewLocationPacket(at, types.Coordinates{}, 0, types.CourseStatus{}), false},
+		{"heartbeat", &packet.HeartbeatPacket{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAlarm(export.NewRecord(testIMEI, tt.p, time.Time{})); got != tt.want {
+				t.Errorf("IsAlarm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrioritySink_AlarmsSkipTheBacklog(t *testing.T) {
+	next := &gatedSink{gate: make(chan struct{})}
+	s := NewPrioritySink(next)
+
+	for i := range 20 {
+		if err := s.Write(export.Record{Protocol: "0x22", Serial: uint16(i)}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := s.Write(export.Record{Protocol: "0x26", Serial: 100}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	close(next.gate)
+	s.Close()
+
+	if len(next.written) != 21 || !next.closed {
+		t.Fatalf("Expected 21 records written and the sink closed, got %d (closed %v)", len(next.written), next.closed)
+	}
+	// At most the location being written when the alarm arrived goes first
+	for i, rec := range next.written {
+		if rec.Serial == 100 && i > 1 {
+			t.Errorf("Alarm written at position %d", i)
+		}
+	}
+	if st := s.Stats(); st.Urgent != 1 || st.Bulk != 20 || st.BudgetMisses != 0 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+	if err := s.Write(export.Record{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestPrioritySink_BulkBackpressure(t *testing.T) {
+	next := &gatedSink{gate: make(chan struct{})}
+	s := NewPrioritySink(next, WithBulkQueueSize(1), WithBulkEnqueueTimeout(10*time.Millisecond))
+
+	// One record in the writer, one queued, the third is dropped
+	var err error
+	for i := range 3 {
+		if err = s.Write(export.Record{Protocol: "0x22"}); err != nil {
+			break
+		}
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+
+	// Alarms are still queued
+	if err := s.Write(export.Record{Protocol: "0xA4"}); err != nil {
+		t.Fatalf("Expected the alarm to be queued, got %v", err)
+	}
+	close(next.gate)
+	s.Close()
+	if st := s.Stats(); st.Dropped != 1 || st.Urgent != 1 || st.Bulk != 2 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+}
+
+func TestPrioritySink_BudgetMiss(t *testing.T) {
+	next := &gatedSink{gate: make(chan struct{})}
+	var missed []time.Duration
+	s := NewPrioritySink(next, WithLatencyBudget(time.Millisecond),
+		WithBudgetMissHandler(func(rec export.Record, latency time.Duration) {
+			missed = append(missed, latency)
+		}))
+
+	s.Write(export.Record{Protocol: "0x26"})
+	time.Sleep(5 * time.Millisecond)
+	close(next.gate)
+	s.Close()
+
+	st := s.Stats()
+	if len(missed) != 1 || missed[0] < 5*time.Millisecond || st.BudgetMisses != 1 || st.MaxLatency != missed[0] {
+		t.Errorf("Expected one budget miss of at least 5ms, got %v (stats %+v)", missed, st)
+	}
+}
@@ -0,0 +1,55 @@
+// Package sink writes decoded events to durable outputs.
+//
+// Sinks receive export.Record values (the decoded, structured form of a
+// packet), as opposed to the raw hex capture written by cmd/tcp-server.
+// Every record carries its schema version, so log shippers such as Vector
+// or Fluent Bit can route on it.
+//
+// Example usage:
+//
+//	fs, err := sink.NewFileSink("events/events.ndjson",
+//	    sink.WithMaxSize(64<<20),
+//	    sink.WithMaxBackups(10),
+//	)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer fs.Close()
+//
+//	fs.WritePacket(imei, pkt, time.Now())
+//
+// KafkaSink produces the same records to a Kafka topic, keyed by IMEI, in
+// batches through a KafkaProducer adapter:
+//
+//	ks := sink.NewKafkaSink(kafkaAdapter{w}, "jimi.packets",
+//	    sink.WithBatchSize(500),
+//	    sink.WithRawTopic("jimi.raw"),
+//	)
+//	defer ks.Close()
+//
+// PrioritySink puts an urgent lane for alarms in front of any sink, so they
+// are not held up behind location traffic:
+//
+//	ps := sink.NewPrioritySink(ks, sink.WithLatencyBudget(500*time.Millisecond))
+//	defer ps.Close()
+//
+// SpillSink keeps the records in local spill files while all its sinks fail,
+// and backfills them once the sinks recover:
+//
+//	ss, err := sink.NewSpillSink("spool/spill.ndjson", []sink.Sink{ks},
+//	    sink.WithDegradedHandler(func(err error) { log.Printf("ALERT: %v", err) }),
+//	)
+package sink
+
+import (
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+// Sink receives decoded records
+type Sink interface {
+	// Write stores one record
+	Write(rec export.Record) error
+
+	// Close flushes and releases the sink
+	Close() error
+}
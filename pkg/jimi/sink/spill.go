@@ -0,0 +1,392 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// DefaultBackfillInterval is how often a degraded SpillSink retries its sinks
+const DefaultBackfillInterval = 5 * time.Second
+
+// maxSpillLine bounds the length of a spilled record read back
+const maxSpillLine = 1 << 20
+
+// SpillStats counts the records handled by a SpillSink
+type SpillStats struct {
+	Degraded   bool      `json:"degraded"`
+	Since      time.Time `json:"since,omitzero"` // start of the degradation
+	Written    uint64    `json:"written"`        // records written to the sinks directly
+	Spilled    uint64    `json:"spilled"`        // records written to the spill files
+	Backfilled uint64    `json:"backfilled"`     // spilled records written to the sinks
+	Failed     uint64    `json:"failed"`         // records lost: the spill file failed too, or unreadable
+}
+
+// SpillSink writes records to one or more sinks and keeps them in local
+// spill files while all of them fail, instead of dropping or blocking.
+//
+// A record written to at least one sink is done; the errors of the others go
+// to the error handler. When every sink fails, the sink turns degraded: the
+// record and all the following ones are appended to the spill file (a
+// FileSink keeping every rotated file), and the degraded handler is called
+// once to alert an operator. Every backfill interval the spilled records are
+// replayed to the sinks, oldest first; once the spill files are drained the
+// sink recovers, calls the recovered handler and writes to the sinks again.
+// Records are delivered at least once: a record replayed when the sink was
+// closed mid-file is replayed again on the next start.
+//
+// Spill files left by a previous run are backfilled at start. Remote sinks
+// must fail fast for SpillSink to take over, e.g. a KafkaSink with
+// WithEnqueueTimeout. SpillSink is safe for concurrent use when the sinks
+// are.
+type SpillSink struct {
+	sinks       []Sink
+	interval    time.Duration
+	onError     func(error)
+	onDegraded  func(error)
+	onRecovered func(backfilled uint64, downtime time.Duration)
+
+	spill *FileSink
+
+	mu       sync.RWMutex // held for reading while writing, for writing to change state
+	degraded bool
+	since    time.Time
+	closed   bool
+	resume   spillPosition // replay progress in the oldest spill file
+
+	stop chan struct{}
+	done chan struct{}
+
+	written    atomic.Uint64
+	spilled    atomic.Uint64
+	backfilled atomic.Uint64
+	failed     atomic.Uint64
+	episode    atomic.Uint64 // records backfilled since the degradation
+}
+
+var _ Sink = (*SpillSink)(nil)
+
+// spillPosition is the number of records of a spill file already replayed
+type spillPosition struct {
+	file  string
+	lines int
+}
+
+// SpillOption configures a SpillSink
+type SpillOption func(*SpillSink)
+
+// WithBackfillInterval sets how often a degraded sink retries its sinks
+// (default DefaultBackfillInterval)
+func WithBackfillInterval(d time.Duration) SpillOption {
+	return func(s *SpillSink) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithDegradedHandler sets the function called when the sink turns
+// degraded, with the errors of the sinks (default none)
+func WithDegradedHandler(fn func(err error)) SpillOption {
+	return func(s *SpillSink) {
+		s.onDegraded = fn
+	}
+}
+
+// WithRecoveredHandler sets the function called when the spill files are
+// drained, with the number of records backfilled and how long the sink was
+// degraded (default none)
+func WithRecoveredHandler(fn func(backfilled uint64, downtime time.Duration)) SpillOption {
+	return func(s *SpillSink) {
+		s.onRecovered = fn
+	}
+}
+
+// WithSpillErrorHandler sets the function called with the errors of the
+// sinks and of the spill files (default none)
+func WithSpillErrorHandler(fn func(error)) SpillOption {
+	return func(s *SpillSink) {
+		s.onError = fn
+	}
+}
+
+// NewSpillSink creates a sink writing to sinks, spilling to the NDJSON file
+// at spillPath (rotated at DefaultMaxSize, all rotated files kept) while all
+// of them fail, and starts its backfill loop
+func NewSpillSink(spillPath string, sinks []Sink, opts ...SpillOption) (*SpillSink, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("spill sink: no sinks")
+	}
+	s := &SpillSink{
+		sinks:    sinks,
+		interval: DefaultBackfillInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	spill, err := NewFileSink(spillPath, WithMaxBackups(0))
+	if err != nil {
+		return nil, err
+	}
+	s.spill = spill
+
+	backups, err := spill.Backups()
+	if err != nil {
+		spill.Close()
+		return nil, err
+	}
+	if info, err := os.Stat(spillPath); len(backups) > 0 || err == nil && info.Size() > 0 {
+		s.degrade(fmt.Errorf("spill sink: records left in %s by a previous run", spillPath))
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Write writes one record to the sinks, or to the spill file while they fail
+func (s *SpillSink) Write(rec export.Record) error {
+	for {
+		s.mu.RLock()
+		if s.closed {
+			s.mu.RUnlock()
+			return ErrClosed
+		}
+		if s.degraded {
+			break
+		}
+		err := s.writeSinks(rec)
+		s.mu.RUnlock()
+		if err == nil {
+			s.written.Add(1)
+			return nil
+		}
+		s.degrade(err)
+	}
+	defer s.mu.RUnlock()
+
+	if err := s.spill.Write(rec); err != nil {
+		s.failed.Add(1)
+		err = fmt.Errorf("spill sink: %w", err)
+		s.report(err)
+		return err
+	}
+	s.spilled.Add(1)
+	return nil
+}
+
+// WritePacket converts a packet with export.NewRecord and writes it
+func (s *SpillSink) WritePacket(imei string, p packet.Packet, receivedAt time.Time) error {
+	return s.Write(export.NewRecord(imei, p, receivedAt))
+}
+
+// Stats returns the record counters and the state of the sink
+func (s *SpillSink) Stats() SpillStats {
+	s.mu.RLock()
+	degraded, since := s.degraded, s.since
+	s.mu.RUnlock()
+	return SpillStats{
+		Degraded:   degraded,
+		Since:      since,
+		Written:    s.written.Load(),
+		Spilled:    s.spilled.Load(),
+		Backfilled: s.backfilled.Load(),
+		Failed:     s.failed.Load(),
+	}
+}
+
+// Close stops the backfill and closes the sinks and the spill file. Spilled
+// records not backfilled yet stay in the spill files for the next start.
+func (s *SpillSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	var errs []error
+	for _, next := range s.sinks {
+		errs = append(errs, next.Close())
+	}
+	errs = append(errs, s.spill.Close())
+	return errors.Join(errs...)
+}
+
+// writeSinks writes a record to every sink. It fails only when all of them
+// do; the errors of the others are reported.
+func (s *SpillSink) writeSinks(rec export.Record) error {
+	var errs []error
+	for _, next := range s.sinks {
+		if err := next.Write(rec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("spill sink: %w", errors.Join(errs...))
+	if len(errs) == len(s.sinks) {
+		return err
+	}
+	s.report(err)
+	return nil
+}
+
+// degrade switches to the spill files, alerting once
+func (s *SpillSink) degrade(err error) {
+	s.mu.Lock()
+	if s.degraded {
+		s.mu.Unlock()
+		return
+	}
+	s.degraded = true
+	s.since = time.Now()
+	s.episode.Store(0)
+	s.mu.Unlock()
+
+	if s.onDegraded != nil {
+		s.onDegraded(err)
+	}
+}
+
+// run backfills the spill files every interval until Close
+func (s *SpillSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			degraded := s.degraded
+			s.mu.RUnlock()
+			if degraded {
+				s.backfill()
+			}
+		}
+	}
+}
+
+// backfill replays the spill files to the sinks, oldest first, and recovers
+// once they are drained. It stops at the first record the sinks refuse.
+func (s *SpillSink) backfill() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		backups, err := s.spill.Backups()
+		if err != nil {
+			s.report(fmt.Errorf("spill sink: %w", err))
+			return
+		}
+		if len(backups) > 0 {
+			if !s.replay(backups[0]) {
+				return
+			}
+			continue
+		}
+
+		// Move the records spilled meanwhile to a rotated file, or recover
+		// if there are none. No record is spilled while the lock is held.
+		s.mu.Lock()
+		info, err := os.Stat(s.spill.Path())
+		if err == nil && info.Size() > 0 {
+			err = s.spill.Rotate()
+			s.mu.Unlock()
+			if err != nil {
+				s.report(fmt.Errorf("spill sink: %w", err))
+				return
+			}
+			continue
+		}
+		s.degraded = false
+		downtime := time.Since(s.since)
+		s.since = time.Time{}
+		s.mu.Unlock()
+
+		if s.onRecovered != nil {
+			s.onRecovered(s.episode.Load(), downtime)
+		}
+		return
+	}
+}
+
+// replay writes the records of a spill file to the sinks and removes it,
+// reporting whether the whole file was replayed
+func (s *SpillSink) replay(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		s.report(fmt.Errorf("spill sink: %w", err))
+		return false
+	}
+	defer f.Close()
+
+	skip := 0
+	if s.resume.file == name {
+		skip = s.resume.lines
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxSpillLine)
+	for line := 0; scanner.Scan(); line++ {
+		if line < skip {
+			continue
+		}
+		select {
+		case <-s.stop:
+			s.resume = spillPosition{file: name, lines: line}
+			return false
+		default:
+		}
+
+		var rec export.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			s.failed.Add(1)
+			s.report(fmt.Errorf("spill sink: %s line %d: %w", name, line+1, err))
+			continue
+		}
+		if err := s.writeSinks(rec); err != nil {
+			s.resume = spillPosition{file: name, lines: line}
+			return false
+		}
+		s.backfilled.Add(1)
+		s.episode.Add(1)
+	}
+	if err := scanner.Err(); err != nil {
+		s.report(fmt.Errorf("spill sink: %s: %w", name, err))
+		return false
+	}
+
+	s.resume = spillPosition{}
+	if err := os.Remove(name); err != nil {
+		s.report(fmt.Errorf("spill sink: %w", err))
+		return false
+	}
+	return true
+}
+
+// report passes an error to the error handler
+func (s *SpillSink) report(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
@@ -0,0 +1,169 @@
+package sink
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+)
+
+// flakySink records the records written, failing while down is set
+type flakySink struct {
+	mu      sync.Mutex
+	down    bool
+	written []export.Record
+	closed  bool
+}
+
+func (s *flakySink) Write(rec export.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("broker unavailable")
+	}
+	s.written = append(s.written, rec)
+	return nil
+}
+
+func (s *flakySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *flakySink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *flakySink) serials() []uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]uint16, len(s.written))
+	for i, rec := range s.written {
+		out[i] = rec.Serial
+	}
+	return out
+}
+
+func TestSpillSink_DegradeAndBackfill(t *testing.T) {
+	spillPath := filepath.Join(t.TempDir(), "spill.ndjson")
+	next := &flakySink{}
+	degraded := make(chan error, 4)
+	recovered := make(chan uint64, 4)
+	s, err := NewSpillSink(spillPath, []Sink{next},
+		WithBackfillInterval(10*time.Millisecond),
+		WithDegradedHandler(func(err error) { degraded <- err }),
+		WithRecoveredHandler(func(n uint64, _ time.Duration) { recovered <- n }),
+	)
+	if err != nil {
+		t.Fatalf("NewSpillSink failed: %v", err)
+	}
+
+	s.Write(export.Record{Serial: 1})
+	next.setDown(true)
+	for serial := uint16(2); serial <= 5; serial++ {
+		if err := s.Write(export.Record{Serial: serial}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if len(degraded) != 1 {
+		t.Fatalf("Expected one degraded alert, got %d", len(degraded))
+	}
+	if st := s.Stats(); !st.Degraded || st.Written != 1 || st.Spilled != 4 {
+		t.Errorf("Unexpected stats while degraded %+v", st)
+	}
+
+	next.setDown(false)
+	select {
+	case n := <-recovered:
+		if n != 4 {
+			t.Errorf("Expected 4 records backfilled, got %d", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the recovery")
+	}
+	s.Write(export.Record{Serial: 6})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := []uint16{1, 2, 3, 4, 5, 6}
+	got := next.serials()
+	if len(got) != len(want) {
+		t.Fatalf("Expected serials %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected serials %v in order, got %v", want, got)
+		}
+	}
+	if st := s.Stats(); st.Degraded || st.Written != 2 || st.Backfilled != 4 {
+		t.Errorf("Unexpected stats after the recovery %+v", st)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(filepath.Dir(spillPath), "spill-*")); len(backups) != 0 {
+		t.Errorf("Expected the spill files removed, got %v", backups)
+	}
+	if !next.closed {
+		t.Error("Expected the sink closed")
+	}
+}
+
+func TestSpillSink_PartialFailure(t *testing.T) {
+	up, down := &flakySink{}, &flakySink{down: true}
+	var errs []error
+	s, err := NewSpillSink(filepath.Join(t.TempDir(), "spill.ndjson"), []Sink{up, down},
+		WithSpillErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	if err != nil {
+		t.Fatalf("NewSpillSink failed: %v", err)
+	}
+	defer s.Close()
+
+	s.Write(export.Record{Serial: 1})
+	if st := s.Stats(); st.Degraded || st.Written != 1 || st.Spilled != 0 {
+		t.Errorf("Expected the record written to the healthy sink, got %+v", st)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected the failing sink reported, got %v", errs)
+	}
+}
+
+func TestSpillSink_BackfillsPreviousRun(t *testing.T) {
+	spillPath := filepath.Join(t.TempDir(), "spill.ndjson")
+	leftover := "{\"schema\":1,\"serial\":7}\n{\"schema\":1,\"serial\":8}\n"
+	if err := os.WriteFile(spillPath, []byte(leftover), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	next := &flakySink{}
+	recovered := make(chan uint64, 1)
+	s, err := NewSpillSink(spillPath, []Sink{next},
+		WithBackfillInterval(10*time.Millisecond),
+		WithRecoveredHandler(func(n uint64, _ time.Duration) { recovered <- n }),
+	)
+	if err != nil {
+		t.Fatalf("NewSpillSink failed: %v", err)
+	}
+	defer s.Close()
+
+	if !s.Stats().Degraded {
+		t.Error("Expected the sink degraded until the leftover is backfilled")
+	}
+	s.Write(export.Record{Serial: 9})
+
+	select {
+	case <-recovered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the backfill")
+	}
+	if got := next.serials(); len(got) != 3 || got[0] != 7 || got[2] != 9 {
+		t.Errorf("Expected serials [7 8 9], got %v", got)
+	}
+}
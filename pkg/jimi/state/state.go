@@ -0,0 +1,354 @@
+// Package state keeps the last known state of every device.
+//
+// A Tracker folds decoded packets into one DeviceState per IMEI (last fix,
+// last heartbeat, battery and GSM levels, ACC, recent alarms) and writes it
+// through to a store.DeviceStore, so the state survives restarts when the
+// store has a persistent backend (Bolt, Redis, SQL):
+//
+//	kv := store.NewBolt(boltAdapter{db, []byte("jimi")})
+//	tracker := state.NewTracker(store.NewDeviceStore(kv, state.DefaultNamespace))
+//
+//	for _, pkt := range packets {
+//	    tracker.Observe(ctx, imei, pkt)
+//	}
+//
+//	st, found, err := tracker.Get(ctx, "359339073930520")
+//	if found && st.Location != nil {
+//	    log.Printf("last seen at %.6f, %.6f", st.Location.Latitude, st.Location.Longitude)
+//	}
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// DefaultNamespace is the store namespace of device states
+const DefaultNamespace = "state"
+
+// DefaultMaxAlarms is the default number of recent alarms kept per device
+const DefaultMaxAlarms = 10
+
+// DeviceState is the last known state of a device
+type DeviceState struct {
+	IMEI string `json:"imei"`
+
+	// Location is the last positioned fix
+	Location *Location `json:"location,omitempty"`
+
+	// LastHeartbeat is when the last heartbeat was received
+	LastHeartbeat time.Time `json:"last_heartbeat,omitzero"`
+
+	// Status is the last reported terminal status
+	Status *Status `json:"status,omitempty"`
+
+	// ACC is the last reported ignition state, from the terminal status,
+	// the dedicated byte of location packets or ACC status packets
+	ACC *bool `json:"acc,omitempty"`
+
+	// Alarms are the most recent alarms, newest first
+	Alarms []Alarm `json:"alarms,omitempty"`
+
+	// LastSeen is when the last packet was received
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Location is a positioned fix
+type Location struct {
+	export.Position
+
+	// Type is the type of the packet that carried the fix
+	Type string `json:"type"`
+
+	// Time is the device (GPS) time of the fix
+	Time time.Time `json:"time,omitzero"`
+
+	// ReceivedAt is when the packet was received
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Status is the terminal status reported by heartbeat, alarm and LBS packets
+type Status struct {
+	// Terminal holds the ACC, charging, armed and oil cut flags
+	Terminal types.TerminalInfo `json:"terminal"`
+
+	// VoltageLevel is the battery level (0-6)
+	VoltageLevel protocol.VoltageLevel `json:"voltage_level"`
+
+	// GSMSignal is the signal strength (0-4)
+	GSMSignal protocol.GSMSignalStrength `json:"gsm_signal"`
+
+	// ReceivedAt is when the status was received
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Alarm is a received alarm
+type Alarm struct {
+	// Type is the alarm name (e.g. "SOS")
+	Type string `json:"type"`
+
+	// Code is the alarm byte
+	Code protocol.AlarmType `json:"code"`
+
+	// Time is the device time of the alarm
+	Time time.Time `json:"time,omitzero"`
+
+	// Position is where the alarm was raised, if positioned
+	Position *export.Position `json:"position,omitempty"`
+
+	// ReceivedAt is when the alarm was received
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithMaxAlarms sets the number of recent alarms kept per device
+func WithMaxAlarms(n int) Option {
+	return func(t *Tracker) {
+		if n >= 0 {
+			t.maxAlarms = n
+		}
+	}
+}
+
+// Tracker maintains device states and writes them through to a store.
+// It is safe for concurrent use.
+type Tracker struct {
+	store     store.DeviceStore
+	maxAlarms int
+
+	mu     sync.Mutex
+	states map[string]*DeviceState // loaded states
+}
+
+// NewTracker creates a tracker persisting to ds
+func NewTracker(ds store.DeviceStore, opts ...Option) *Tracker {
+	t := &Tracker{
+		store:     ds,
+		maxAlarms: DefaultMaxAlarms,
+		states:    make(map[string]*DeviceState),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Observe folds a packet received now into the state of a device.
+// See ObserveAt.
+func (t *Tracker) Observe(ctx context.Context, imei string, p packet.Packet) (DeviceState, error) {
+	return t.ObserveAt(ctx, imei, p, time.Now())
+}
+
+// ObserveAt folds a packet received at the given time into the state of a
+// device and saves it. The stored state is loaded on the first packet of a
+// device, so state from before a restart is kept.
+func (t *Tracker) ObserveAt(ctx context.Context, imei string, p packet.Packet, at time.Time) (DeviceState, error) {
+	t.mu.Lock()
+	st, err := t.load(ctx, imei)
+	if err != nil {
+		t.mu.Unlock()
+		return DeviceState{}, err
+	}
+	t.apply(st, p, at.UTC())
+	snapshot := st.clone()
+	t.mu.Unlock()
+
+	return snapshot, t.store.Save(ctx, imei, snapshot)
+}
+
+// Get returns the state of a device.
+// Returns false if nothing is known about it.
+func (t *Tracker) Get(ctx context.Context, imei string) (DeviceState, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if st, ok := t.states[imei]; ok {
+		return st.clone(), true, nil
+	}
+	var st DeviceState
+	found, err := t.store.Load(ctx, imei, &st)
+	if err != nil || !found {
+		return DeviceState{}, false, err
+	}
+	t.states[imei] = &st
+	return st.clone(), true, nil
+}
+
+// All returns the state of every stored device, sorted by IMEI
+func (t *Tracker) All(ctx context.Context) ([]DeviceState, error) {
+	imeis, err := t.store.IMEIs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DeviceState, 0, len(imeis))
+	for _, imei := range imeis {
+		st, found, err := t.Get(ctx, imei)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+// Forget removes the state of a device, in memory and in the store
+func (t *Tracker) Forget(ctx context.Context, imei string) error {
+	t.mu.Lock()
+	delete(t.states, imei)
+	t.mu.Unlock()
+
+	return t.store.Delete(ctx, imei)
+}
+
+// load returns the state of a device, loading it from the store or creating
+// it. Caller must hold t.mu.
+func (t *Tracker) load(ctx context.Context, imei string) (*DeviceState, error) {
+	if st, ok := t.states[imei]; ok {
+		return st, nil
+	}
+	st := &DeviceState{IMEI: imei}
+	if _, err := t.store.Load(ctx, imei, st); err != nil {
+		return nil, err
+	}
+	st.IMEI = imei
+	t.states[imei] = st
+	return st, nil
+}
+
+// apply folds one packet into a state. Caller must hold t.mu.
+func (t *Tracker) apply(st *DeviceState, p packet.Packet, at time.Time) {
+	st.LastSeen = at
+
+	rec := export.FromPacket(p)
+	var deviceTime time.Time
+	if rec.Time != nil {
+		deviceTime = *rec.Time
+	}
+	fix := rec.Position
+	if fix != nil && !fix.Positioned {
+		fix = nil
+	}
+	if fix != nil {
+		st.Location = &Location{
+			Position:   *fix,
+			Type:       rec.Type,
+			Time:       deviceTime,
+			ReceivedAt: at,
+		}
+	}
+
+	if _, ok := p.(*packet.HeartbeatPacket); ok {
+		st.LastHeartbeat = at
+	}
+	if status, ok := statusOf(p); ok {
+		status.ReceivedAt = at
+		st.Status = &status
+		st.setACC(status.Terminal.ACCOn())
+	}
+
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		st.setACC(v.ACC)
+	case *packet.Location4GPacket:
+		st.setACC(v.ACC)
+	case *packet.ACCStatusPacket:
+		st.setACC(v.ACCOn())
+	}
+
+	if alarm, ok := alarmOf(p); ok && t.maxAlarms > 0 {
+		st.Alarms = append([]Alarm{{
+			Type:       alarm.AlarmType.String(),
+			Code:       alarm.AlarmType,
+			Time:       deviceTime,
+			Position:   fix,
+			ReceivedAt: at,
+		}}, st.Alarms...)
+		if len(st.Alarms) > t.maxAlarms {
+			st.Alarms = st.Alarms[:t.maxAlarms]
+		}
+	}
+}
+
+// setACC updates the ACC state
+func (st *DeviceState) setACC(on bool) {
+	st.ACC = &on
+}
+
+// clone returns a copy that shares nothing with st
+func (st *DeviceState) clone() DeviceState {
+	c := *st
+	if st.Location != nil {
+		loc := *st.Location
+		c.Location = &loc
+	}
+	if st.Status != nil {
+		status := *st.Status
+		c.Status = &status
+	}
+	if st.ACC != nil {
+		acc := *st.ACC
+		c.ACC = &acc
+	}
+	c.Alarms = append([]Alarm(nil), st.Alarms...)
+	return c
+}
+
+// statusOf returns the terminal status carried by a packet
+func statusOf(p packet.Packet) (Status, bool) {
+	if a, ok := alarmPacket(p); ok {
+		return Status{Terminal: a.TerminalInfo, VoltageLevel: a.VoltageLevel, GSMSignal: a.GSMSignal}, true
+	}
+	switch v := p.(type) {
+	case *packet.HeartbeatPacket:
+		return Status{Terminal: v.TerminalInfo, VoltageLevel: v.VoltageLevel, GSMSignal: v.GSMSignal}, true
+	case *packet.LBSPacket:
+		if v.HasStatus {
+			return Status{Terminal: v.TerminalInfo, VoltageLevel: v.VoltageLevel, GSMSignal: v.GSMSignal}, true
+		}
+	case *packet.LBS4GPacket:
+		return Status{Terminal: v.TerminalInfo, VoltageLevel: v.VoltageLevel, GSMSignal: v.GSMSignal}, true
+	}
+	return Status{}, false
+}
+
+// alarmOf returns the alarm raised by a packet. ACC status packets and
+// normal status uploads are not alarms.
+func alarmOf(p packet.Packet) (*packet.AlarmPacket, bool) {
+	if _, ok := p.(*packet.ACCStatusPacket); ok {
+		return nil, false
+	}
+	a, ok := alarmPacket(p)
+	if !ok || a.AlarmType == protocol.AlarmNormal {
+		return nil, false
+	}
+	return a, true
+}
+
+// alarmPacket returns the alarm layout embedded in a packet
+func alarmPacket(p packet.Packet) (*packet.AlarmPacket, bool) {
+	switch v := p.(type) {
+	case *packet.AlarmPacket:
+		return v, true
+	case *packet.AlarmMultiFencePacket:
+		return &v.AlarmPacket, true
+	case *packet.Alarm4GPacket:
+		return &v.AlarmPacket, true
+	case *packet.GPSLBSStatusPacket:
+		return &v.AlarmPacket, true
+	case *packet.ACCStatusPacket:
+		return &v.AlarmPacket, true
+	default:
+		return nil, false
+	}
+}
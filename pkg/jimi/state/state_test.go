@@ -0,0 +1,124 @@
+package state
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/internal/testdata/packets"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/store"
+)
+
+const testIMEI = "359339073930520"
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// decode decodes a sample packet
+func decode(t *testing.T, s string) packet.Packet {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := jimi.NewDecoder(jimi.WithSkipCRC(), jimi.WithLenientMode()).Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	return p
+}
+
+func TestTracker_Observe(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(store.NewDeviceStore(store.NewMemory(), DefaultNamespace))
+
+	heartbeat := decode(t, packets.HeartbeatPackets[1].Hex)
+	location := decode(t, packets.LocationPackets[0].Hex)
+	noFix := decode(t, packets.LocationPackets[2].Hex)
+	sos := decode(t, packets.AlarmPackets[0].Hex)
+
+	tracker.ObserveAt(ctx, testIMEI, heartbeat, start)
+	tracker.ObserveAt(ctx, testIMEI, location, start.Add(time.Minute))
+	tracker.ObserveAt(ctx, testIMEI, sos, start.Add(2*time.Minute))
+	st, err := tracker.ObserveAt(ctx, testIMEI, noFix, start.Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+
+	if st.IMEI != testIMEI || !st.LastSeen.Equal(start.Add(3*time.Minute)) || !st.LastHeartbeat.Equal(start) {
+		t.Errorf("Unexpected times %+v", st)
+	}
+
+	// Fixes without GPS (the alarm and the last location) do not replace the
+	// last positioned one
+	if st.Location == nil || !st.Location.ReceivedAt.Equal(start.Add(time.Minute)) || st.Location.Latitude < 23 || st.Location.Latitude > 24 {
+		t.Errorf("Unexpected location %+v", st.Location)
+	}
+
+	if st.Status == nil || !st.Status.ReceivedAt.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected the status of the alarm, got %+v", st.Status)
+	}
+	if st.ACC == nil || *st.ACC != noFix.(*packet.LocationPacket).ACC {
+		t.Errorf("Expected ACC from the last location packet, got %v", st.ACC)
+	}
+
+	if len(st.Alarms) != 1 || st.Alarms[0].Code != protocol.AlarmSOS || st.Alarms[0].Time.IsZero() {
+		t.Errorf("Unexpected alarms %+v", st.Alarms)
+	}
+}
+
+func TestTracker_MaxAlarms(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(store.NewDeviceStore(store.NewMemory(), DefaultNamespace), WithMaxAlarms(2))
+
+	for i, tp := range packets.AlarmPackets[:3] {
+		tracker.ObserveAt(ctx, testIMEI, decode(t, tp.Hex), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	st, _, _ := tracker.Get(ctx, testIMEI)
+	if len(st.Alarms) != 2 {
+		t.Fatalf("Expected 2 alarms, got %+v", st.Alarms)
+	}
+	if st.Alarms[0].Code != protocol.AlarmVibration || st.Alarms[1].Code != protocol.AlarmPowerCut {
+		t.Errorf("Expected the newest alarms first, got %s, %s", st.Alarms[0].Type, st.Alarms[1].Type)
+	}
+}
+
+func TestTracker_Restart(t *testing.T) {
+	ctx := context.Background()
+	kv := store.NewMemory()
+
+	before := NewTracker(store.NewDeviceStore(kv, DefaultNamespace))
+	before.ObserveAt(ctx, testIMEI, decode(t, packets.LocationPackets[0].Hex), start)
+
+	// A new tracker on the same store answers from the stored state
+	after := NewTracker(store.NewDeviceStore(kv, DefaultNamespace))
+	st, found, err := after.Get(ctx, testIMEI)
+	if err != nil || !found || st.Location == nil {
+		t.Fatalf("Expected the stored location, got %+v, %v (%v)", st, found, err)
+	}
+	if _, found, _ := after.Get(ctx, "000000000000000"); found {
+		t.Error("Expected no state for an unknown device")
+	}
+
+	// New packets build on the stored state
+	st, _ = after.ObserveAt(ctx, testIMEI, decode(t, packets.HeartbeatPackets[0].Hex), start.Add(time.Minute))
+	if st.Location == nil || st.LastHeartbeat.IsZero() {
+		t.Errorf("Expected location and heartbeat, got %+v", st)
+	}
+
+	all, err := after.All(ctx)
+	if err != nil || len(all) != 1 {
+		t.Errorf("Expected 1 state, got %d (%v)", len(all), err)
+	}
+
+	if err := after.Forget(ctx, testIMEI); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := NewTracker(store.NewDeviceStore(kv, DefaultNamespace)).Get(ctx, testIMEI); found {
+		t.Error("Expected the state to be deleted from the store")
+	}
+}
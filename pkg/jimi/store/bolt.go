@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+// BoltBucket is the subset of *bbolt.Bucket used by the Bolt backend.
+// *bbolt.Bucket (go.etcd.io/bbolt) and *bolt.Bucket (github.com/boltdb/bolt)
+// satisfy it as-is.
+type BoltBucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	ForEach(fn func(k, v []byte) error) error
+}
+
+// BoltDB runs read and write transactions against one bucket.
+// A bbolt adapter is a few lines:
+//
+//	type boltAdapter struct {
+//	    db     *bbolt.DB
+//	    bucket []byte
+//	}
+//
+//	func (a boltAdapter) Update(fn func(store.BoltBucket) error) error {
+//	    return a.db.Update(func(tx *bbolt.Tx) error {
+//	        b, err := tx.CreateBucketIfNotExists(a.bucket)
+//	        if err != nil {
+//	            return err
+//	        }
+//	        return fn(b)
+//	    })
+//	}
+//
+//	func (a boltAdapter) View(fn func(store.BoltBucket) error) error {
+//	    return a.db.View(func(tx *bbolt.Tx) error {
+//	        b := tx.Bucket(a.bucket)
+//	        if b == nil {
+//	            return store.ErrNotFound
+//	        }
+//	        return fn(b)
+//	    })
+//	}
+//
+// View may return ErrNotFound when the bucket does not exist yet.
+type BoltDB interface {
+	Update(fn func(BoltBucket) error) error
+	View(fn func(BoltBucket) error) error
+}
+
+// Bolt is a KV backend on an embedded Bolt database
+type Bolt struct {
+	db BoltDB
+}
+
+var _ KV = (*Bolt)(nil)
+
+// NewBolt creates a backend on db
+func NewBolt(db BoltDB) *Bolt {
+	return &Bolt{db: db}
+}
+
+// Get implements KV
+func (b *Bolt) Get(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(bucket BoltBucket) error {
+		v := bucket.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// Bolt values are only valid inside the transaction
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	return value, err
+}
+
+// Set implements KV
+func (b *Bolt) Set(_ context.Context, key string, value []byte) error {
+	return b.db.Update(func(bucket BoltBucket) error {
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// Delete implements KV
+func (b *Bolt) Delete(_ context.Context, key string) error {
+	return b.db.Update(func(bucket BoltBucket) error {
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// Keys implements KV
+func (b *Bolt) Keys(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := b.db.View(func(bucket BoltBucket) error {
+		// Bolt iterates in byte order, so keys come out sorted
+		return bucket.ForEach(func(k, _ []byte) error {
+			if bytes.HasPrefix(k, []byte(prefix)) {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return keys, err
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// RedisClient is the subset of Redis commands used by the Redis backend.
+// A go-redis adapter is a few lines:
+//
+//	type redisAdapter struct{ c *redis.Client }
+//
+//	func (a redisAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+//	    v, err := a.c.Get(ctx, key).Bytes()
+//	    if errors.Is(err, redis.Nil) {
+//	        return nil, store.ErrNotFound
+//	    }
+//	    return v, err
+//	}
+//
+//	func (a redisAdapter) Set(ctx context.Context, key string, value []byte) error {
+//	    return a.c.Set(ctx, key, value, 0).Err()
+//	}
+//
+//	func (a redisAdapter) Del(ctx context.Context, key string) error {
+//	    return a.c.Del(ctx, key).Err()
+//	}
+//
+//	func (a redisAdapter) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+//	    return a.c.Scan(ctx, cursor, match, count).Result()
+//	}
+type RedisClient interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores value under key without expiry
+	Set(ctx context.Context, key string, value []byte) error
+
+	// Del removes key
+	Del(ctx context.Context, key string) error
+
+	// Scan runs one SCAN iteration and returns the keys and next cursor
+	Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error)
+}
+
+// DefaultRedisPrefix is prepended to all keys written by the Redis backend
+const DefaultRedisPrefix = "jimi:"
+
+// redisScanCount is the SCAN COUNT hint
+const redisScanCount = 100
+
+// Redis is a KV backend on a shared Redis server
+type Redis struct {
+	client RedisClient
+	prefix string
+}
+
+var _ KV = (*Redis)(nil)
+
+// NewRedis creates a backend on client. All keys are stored under prefix
+// (DefaultRedisPrefix when empty) so several applications can share a server.
+func NewRedis(client RedisClient, prefix string) *Redis {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Get implements KV
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	return r.client.Get(ctx, r.prefix+key)
+}
+
+// Set implements KV
+func (r *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return r.client.Set(ctx, r.prefix+key, value)
+}
+
+// Delete implements KV
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key)
+}
+
+// Keys implements KV
+func (r *Redis) Keys(ctx context.Context, prefix string) ([]string, error) {
+	match := escapeRedisPattern(r.prefix+prefix) + "*"
+
+	// SCAN may return a key more than once
+	seen := make(map[string]bool)
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, match, redisScanCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			seen[strings.TrimPrefix(k, r.prefix)] = true
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	out := make([]string, 0, len(seen))
+	for k := range seen {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// escapeRedisPattern escapes glob metacharacters in a SCAN MATCH pattern
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultSQLTable is the table used by the SQL backend
+const DefaultSQLTable = "jimi_kv"
+
+// SQL is a KV backend on any database/sql database.
+//
+// The table must exist with a text primary key "k" and a binary column "v":
+//
+//	CREATE TABLE jimi_kv (k VARCHAR(255) PRIMARY KEY, v BLOB NOT NULL)  -- SQLite, MySQL
+//	CREATE TABLE jimi_kv (k TEXT PRIMARY KEY, v BYTEA NOT NULL)         -- PostgreSQL
+//
+// Writes are single upserts, so concurrent writers of a key never conflict:
+// INSERT ... ON CONFLICT (SQLite 3.24+, PostgreSQL 9.5+) by default, or
+// INSERT ... ON DUPLICATE KEY UPDATE with WithMySQL.
+type SQL struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+	mysql  bool
+}
+
+var _ KV = (*SQL)(nil)
+
+// SQLOption configures the SQL backend
+type SQLOption func(*SQL)
+
+// WithTable sets the table name (default DefaultSQLTable)
+func WithTable(name string) SQLOption {
+	return func(s *SQL) {
+		s.table = name
+	}
+}
+
+// WithDollarPlaceholders uses $1, $2 placeholders (PostgreSQL) instead of ?
+func WithDollarPlaceholders() SQLOption {
+	return func(s *SQL) {
+		s.dollar = true
+	}
+}
+
+// WithMySQL uses the MySQL upsert syntax (ON DUPLICATE KEY UPDATE)
+func WithMySQL() SQLOption {
+	return func(s *SQL) {
+		s.mysql = true
+	}
+}
+
+// validTable restricts table names, which cannot be passed as parameters
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// NewSQL creates a backend on db
+func NewSQL(db *sql.DB, opts ...SQLOption) (*SQL, error) {
+	s := &SQL{db: db, table: DefaultSQLTable}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if !validTable.MatchString(s.table) {
+		return nil, fmt.Errorf("store: invalid table name %q", s.table)
+	}
+	return s, nil
+}
+
+// query formats a statement with the table name and dialect placeholders
+func (s *SQL) query(format string) string {
+	q := fmt.Sprintf(format, s.table)
+	if !s.dollar {
+		return q
+	}
+	n := 0
+	var b strings.Builder
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Get implements KV
+func (s *SQL) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx, s.query("SELECT v FROM %s WHERE k = ?"), key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Set implements KV
+func (s *SQL) Set(ctx context.Context, key string, value []byte) error {
+	upsert := "INSERT INTO %s (k, v) VALUES (?, ?) ON CONFLICT (k) DO UPDATE SET v = excluded.v"
+	if s.mysql {
+		upsert = "INSERT INTO %s (k, v) VALUES (?, ?) ON DUPLICATE KEY UPDATE v = VALUES(v)"
+	}
+	_, err := s.db.ExecContext(ctx, s.query(upsert), key, value)
+	return err
+}
+
+// Delete implements KV
+func (s *SQL) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE k = ?"), key)
+	return err
+}
+
+// likeEscaper escapes the LIKE wildcards with '!', an escape character
+// that needs no quoting in any dialect, unlike the backslash in MySQL
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Keys implements KV
+func (s *SQL) Keys(ctx context.Context, prefix string) ([]string, error) {
+	// LIKE narrows the scan to the prefix (and uses the primary key index
+	// where the database can); as it is case-insensitive on some databases,
+	// the prefix is checked again here
+	rows, err := s.db.QueryContext(ctx, s.query("SELECT k FROM %s WHERE k LIKE ? ESCAPE '!'"), likeEscaper.Replace(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Collations differ between databases; return byte order like the other backends
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,122 @@
+// Package store provides a pluggable persistence layer for device state.
+//
+// Stateful subsystems (sessions, pending commands, odometers, fence slots)
+// persist through a DeviceStore, which keeps one JSON document per device in
+// a namespace on top of any KV backend:
+//
+//   - NewMemory: in-process map (default, tests)
+//   - NewBolt:   embedded bbolt/BoltDB file, through BoltDB
+//   - NewRedis:  shared Redis, through RedisClient
+//   - NewSQL:    any database/sql database
+//
+// The Bolt and Redis backends are defined against small adapter interfaces
+// so this module does not depend on their client libraries.
+//
+// Example usage:
+//
+//	kv := store.NewMemory()
+//	odometers := store.NewDeviceStore(kv, "odometer")
+//
+//	odometers.Save(ctx, imei, Odometer{Meters: 1200})
+//
+//	var odo Odometer
+//	found, err := odometers.Load(ctx, imei, &odo)
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by KV.Get when the key does not exist
+var ErrNotFound = errors.New("store: key not found")
+
+// KV is a minimal key-value backend.
+// Implementations must be safe for concurrent use.
+type KV interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set stores value under key, replacing any previous value
+	Set(ctx context.Context, key string, value []byte) error
+
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+
+	// Keys returns all keys starting with prefix, sorted
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// DeviceStore persists one state document per device IMEI
+type DeviceStore interface {
+	// Load decodes the state of imei into v.
+	// Returns false (and leaves v untouched) if no state is stored.
+	Load(ctx context.Context, imei string, v any) (bool, error)
+
+	// Save stores v as the state of imei
+	Save(ctx context.Context, imei string, v any) error
+
+	// Delete removes the state of imei
+	Delete(ctx context.Context, imei string) error
+
+	// IMEIs returns the devices with stored state, sorted
+	IMEIs(ctx context.Context) ([]string, error)
+}
+
+// NewDeviceStore creates a DeviceStore that keeps JSON documents in kv under
+// "<namespace>/<imei>". Each subsystem should use its own namespace.
+func NewDeviceStore(kv KV, namespace string) DeviceStore {
+	return &deviceStore{kv: kv, prefix: namespace + "/"}
+}
+
+// deviceStore is the KV-backed DeviceStore
+type deviceStore struct {
+	kv     KV
+	prefix string
+}
+
+// Load implements DeviceStore
+func (s *deviceStore) Load(ctx context.Context, imei string, v any) (bool, error) {
+	data, err := s.kv.Get(ctx, s.prefix+imei)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Save implements DeviceStore
+func (s *deviceStore) Save(ctx context.Context, imei string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(ctx, s.prefix+imei, data)
+}
+
+// Delete implements DeviceStore
+func (s *deviceStore) Delete(ctx context.Context, imei string) error {
+	return s.kv.Delete(ctx, s.prefix+imei)
+}
+
+// IMEIs implements DeviceStore
+func (s *deviceStore) IMEIs(ctx context.Context) ([]string, error) {
+	keys, err := s.kv.Keys(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	imeis := make([]string, 0, len(keys))
+	for _, k := range keys {
+		imeis = append(imeis, strings.TrimPrefix(k, s.prefix))
+	}
+	sort.Strings(imeis)
+	return imeis, nil
+}
@@ -0,0 +1,395 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testIMEI = "359339073930520"
+
+// testKV runs the KV contract against a backend
+func testKV(t *testing.T, kv KV) {
+	ctx := context.Background()
+
+	if _, err := kv.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if keys, err := kv.Keys(ctx, ""); err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys, got %v (%v)", keys, err)
+	}
+
+	kv.Set(ctx, "a/2", []byte("two"))
+	kv.Set(ctx, "a/1", []byte("one"))
+	kv.Set(ctx, "b/1", []byte("other"))
+	if err := kv.Set(ctx, "a/1", []byte("uno")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	v, err := kv.Get(ctx, "a/1")
+	if err != nil || string(v) != "uno" {
+		t.Errorf("Expected overwritten value 'uno', got %q (%v)", v, err)
+	}
+
+	keys, err := kv.Keys(ctx, "a/")
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if want := []string{"a/1", "a/2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+
+	if err := kv.Delete(ctx, "a/1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := kv.Delete(ctx, "a/1"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	if _, err := kv.Get(ctx, "a/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) KV{
+		"memory": func(*testing.T) KV { return NewMemory() },
+		"bolt":   func(*testing.T) KV { return NewBolt(&fakeBolt{data: map[string][]byte{}}) },
+		"redis":  func(*testing.T) KV { return NewRedis(&fakeRedis{data: map[string][]byte{}}, "") },
+		"sql": func(t *testing.T) KV {
+			kv, err := NewSQL(openFakeSQL(t))
+			if err != nil {
+				t.Fatalf("NewSQL failed: %v", err)
+			}
+			return kv
+		},
+	}
+
+	for name, newKV := range backends {
+		t.Run(name, func(t *testing.T) {
+			testKV(t, newKV(t))
+		})
+	}
+}
+
+func TestDeviceStore(t *testing.T) {
+	ctx := context.Background()
+	kv := NewMemory()
+	odometers := NewDeviceStore(kv, "odometer")
+	sessions := NewDeviceStore(kv, "session")
+
+	type odometer struct {
+		Meters uint32 `json:"meters"`
+	}
+
+	var odo odometer
+	if found, err := odometers.Load(ctx, testIMEI, &odo); found || err != nil {
+		t.Errorf("Expected no state, got found=%v err=%v", found, err)
+	}
+
+	if err := odometers.Save(ctx, testIMEI, odometer{Meters: 1200}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	sessions.Save(ctx, "359339073930538", map[string]string{"addr": "10.0.0.1"})
+
+	if found, err := odometers.Load(ctx, testIMEI, &odo); !found || err != nil || odo.Meters != 1200 {
+		t.Errorf("Expected 1200 meters, got %+v found=%v err=%v", odo, found, err)
+	}
+
+	imeis, _ := odometers.IMEIs(ctx)
+	if !reflect.DeepEqual(imeis, []string{testIMEI}) {
+		t.Errorf("Expected namespaces to be isolated, got %v", imeis)
+	}
+
+	odometers.Delete(ctx, testIMEI)
+	if found, _ := odometers.Load(ctx, testIMEI, &odo); found {
+		t.Error("Expected state to be deleted")
+	}
+}
+
+func TestRedis_EscapesPattern(t *testing.T) {
+	r := &fakeRedis{data: map[string][]byte{}}
+	kv := NewRedis(r, "app:")
+	ctx := context.Background()
+
+	kv.Set(ctx, "x*/1", []byte("1"))
+	kv.Set(ctx, "xy/1", []byte("2"))
+
+	keys, _ := kv.Keys(ctx, "x*/")
+	if !reflect.DeepEqual(keys, []string{"x*/1"}) {
+		t.Errorf("Expected literal '*' match, got %v", keys)
+	}
+	if _, ok := r.data["app:xy/1"]; !ok {
+		t.Error("Expected keys to be stored under the prefix")
+	}
+}
+
+func TestSQL_Placeholders(t *testing.T) {
+	s, _ := NewSQL(nil, WithTable("devices"), WithDollarPlaceholders())
+	if got := s.query("INSERT INTO %s (k, v) VALUES (?, ?)"); got != "INSERT INTO devices (k, v) VALUES ($1, $2)" {
+		t.Errorf("Unexpected query: %s", got)
+	}
+
+	if _, err := NewSQL(nil, WithTable("x; DROP TABLE y")); err == nil {
+		t.Error("Expected invalid table name to be rejected")
+	}
+}
+
+func TestSQL_Dialects(t *testing.T) {
+	for _, opts := range [][]SQLOption{nil, {WithMySQL()}} {
+		kv, _ := NewSQL(openFakeSQL(t), opts...)
+		testKV(t, kv)
+	}
+}
+
+func TestSQL_EscapesPrefix(t *testing.T) {
+	kv, _ := NewSQL(openFakeSQL(t))
+	ctx := context.Background()
+
+	for _, k := range []string{"a%/1", "ab/1", "a_/1", "a!/1"} {
+		kv.Set(ctx, k, []byte("1"))
+	}
+	for _, prefix := range []string{"a%/", "a_/", "a!/"} {
+		keys, err := kv.Keys(ctx, prefix)
+		if want := []string{prefix + "1"}; err != nil || !reflect.DeepEqual(keys, want) {
+			t.Errorf("Keys(%q): expected %v, got %v (%v)", prefix, want, keys, err)
+		}
+	}
+}
+
+// fakeBolt is an in-memory BoltDB
+type fakeBolt struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (f *fakeBolt) Update(fn func(BoltBucket) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return fn(fakeBucket(f.data))
+}
+
+func (f *fakeBolt) View(fn func(BoltBucket) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return fn(fakeBucket(f.data))
+}
+
+type fakeBucket map[string][]byte
+
+func (b fakeBucket) Get(key []byte) []byte       { return b[string(key)] }
+func (b fakeBucket) Delete(key []byte) error     { delete(b, string(key)); return nil }
+func (b fakeBucket) Put(key, value []byte) error { b[string(key)] = bytes.Clone(value); return nil }
+
+func (b fakeBucket) ForEach(fn func(k, v []byte) error) error {
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn([]byte(k), b[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeRedis is an in-memory RedisClient returning SCAN results in pages of one
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (f *fakeRedis) Get(_ context.Context, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (f *fakeRedis) Set(_ context.Context, key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[key] = bytes.Clone(value)
+	return nil
+}
+
+func (f *fakeRedis) Del(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.data, key)
+	return nil
+}
+
+func (f *fakeRedis) Scan(_ context.Context, cursor uint64, match string, _ int64) ([]string, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.data))
+	for k := range f.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if cursor >= uint64(len(keys)) {
+		return nil, 0, nil
+	}
+	next := cursor + 1
+	if next == uint64(len(keys)) {
+		next = 0
+	}
+	// Redis glob escaping matches path.Match for the characters used here
+	if ok, _ := path.Match(match, keys[cursor]); ok {
+		return []string{keys[cursor]}, next, nil
+	}
+	return nil, next, nil
+}
+
+// fakeSQLDriver understands exactly the statements issued by the SQL backend
+type fakeSQLDriver struct {
+	mu   sync.Mutex
+	dbs  map[string]map[string][]byte
+	next int
+}
+
+var sqlDriver = &fakeSQLDriver{dbs: map[string]map[string][]byte{}}
+
+func init() {
+	sql.Register("storetest", sqlDriver)
+}
+
+func openFakeSQL(t *testing.T) *sql.DB {
+	sqlDriver.mu.Lock()
+	sqlDriver.next++
+	name := fmt.Sprintf("db%d", sqlDriver.next)
+	sqlDriver.dbs[name] = map[string][]byte{}
+	sqlDriver.mu.Unlock()
+
+	db, err := sql.Open("storetest", name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d, name: name}, nil
+}
+
+type fakeConn struct {
+	d    *fakeSQLDriver
+	name string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	table := s.c.d.dbs[s.c.name]
+
+	switch s.query {
+	case "DELETE FROM jimi_kv WHERE k = ?":
+		delete(table, args[0].(string))
+	case "INSERT INTO jimi_kv (k, v) VALUES (?, ?) ON CONFLICT (k) DO UPDATE SET v = excluded.v",
+		"INSERT INTO jimi_kv (k, v) VALUES (?, ?) ON DUPLICATE KEY UPDATE v = VALUES(v)":
+		table[args[0].(string)] = bytes.Clone(args[1].([]byte))
+	default:
+		return nil, fmt.Errorf("unexpected exec: %s", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	table := s.c.d.dbs[s.c.name]
+
+	switch s.query {
+	case "SELECT v FROM jimi_kv WHERE k = ?":
+		rows := &fakeRows{cols: []string{"v"}}
+		if v, ok := table[args[0].(string)]; ok {
+			rows.values = [][]driver.Value{{bytes.Clone(v)}}
+		}
+		return rows, nil
+	case "SELECT k FROM jimi_kv WHERE k LIKE ? ESCAPE '!'":
+		rows := &fakeRows{cols: []string{"k"}}
+		for k := range table {
+			if fakeLike(args[0].(string), k) {
+				rows.values = append(rows.values, []driver.Value{k})
+			}
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+// fakeLike matches s against a LIKE pattern escaped with '!'
+func fakeLike(pattern, s string) bool {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '!' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '%':
+			re.WriteString(".*")
+		case c == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String()).MatchString(s)
+}
+
+type fakeRows struct {
+	cols   []string
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,226 @@
+package jimi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+)
+
+// streamReadSize is the size of each read from the underlying reader
+const streamReadSize = 4096
+
+// deadlineSetter is implemented by readers whose blocking reads can be
+// interrupted (net.Conn, os.File pipes)
+type deadlineSetter interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// StreamDecoder decodes packets from an io.Reader such as a TCP connection.
+// It buffers reads internally and reassembles fragmented and concatenated
+// packets, so callers only loop over Next.
+//
+// Example:
+//
+//	stream := jimi.NewStreamDecoder(conn)
+//	for {
+//	    pkt, err := stream.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        log.Printf("Decode error: %v", err)
+//	        if stream.Err() != nil {
+//	            break
+//	        }
+//	        continue
+//	    }
+//	    processPacket(pkt)
+//	}
+//
+// Errors decoding one packet are returned in strict mode and skipped in
+// lenient mode (like DecodeStream); decoding resumes with the next packet.
+// With a ResyncPolicy, exceeding its discard limit returns ErrResyncLimit
+// once, after the packets read with it; the data after the limit is
+// dropped. Stream limits
+// (WithMaxDeclaredLength, WithMaxResidueSize) return a *StreamLimitError once
+// and decoding resumes at the next start bit.
+// Read errors are sticky and reported by Err. A StreamDecoder is not safe for
+// concurrent use.
+type StreamDecoder struct {
+	decoder *Decoder
+	session *DeviceSession // the device read, for WithDeviceTimezone
+	r       io.Reader
+
+	buf      []byte   // bytes read but not yet split
+	pending  [][]byte // split frames not yet decoded
+	readBuf  []byte
+	err      error // sticky read error
+	splitErr error // ErrResyncLimit or stream limit, returned once after the frames split with it
+}
+
+// NewStreamDecoder creates a stream decoder reading from r, decoding with a
+// decoder created with the given options
+func NewStreamDecoder(r io.Reader, opts ...Option) *StreamDecoder {
+	return NewDecoder(opts...).NewStreamDecoder(r)
+}
+
+// NewStreamDecoder creates a stream decoder reading from r with this decoder
+// (and its custom parsers)
+func (d *Decoder) NewStreamDecoder(r io.Reader) *StreamDecoder {
+	return &StreamDecoder{
+		decoder: d,
+		session: d.NewDeviceSession(),
+		r:       r,
+		readBuf: make([]byte, streamReadSize),
+	}
+}
+
+// Next returns the next decoded packet.
+// It returns io.EOF when the reader ends on a packet boundary and
+// io.ErrUnexpectedEOF when it ends inside a packet.
+func (s *StreamDecoder) Next() (packet.Packet, error) {
+	return s.NextContext(context.Background())
+}
+
+// NextContext is like Next but gives up when ctx is done, returning ctx.Err().
+// Blocking reads are interrupted for readers with SetReadDeadline (net.Conn);
+// the read deadline is cleared afterwards. Other readers are checked between
+// reads. Buffered data is kept, so Next can be called again.
+func (s *StreamDecoder) NextContext(ctx context.Context) (packet.Packet, error) {
+	if ds, ok := s.r.(deadlineSetter); ok && ctx.Done() != nil {
+		defer interruptOnDone(ctx, ds)()
+	}
+
+	for {
+		if len(s.pending) > 0 {
+			raw := s.pending[0]
+			s.pending = s.pending[1:]
+
+			pkt, err := s.session.Decode(raw)
+			if err != nil {
+				if s.decoder.opts.StrictMode {
+					return nil, err
+				}
+				if log := s.decoder.opts.logger(); log != nil {
+					log.Debug("packet skipped", append(packetAttrs(raw), "err", err)...)
+				}
+				continue
+			}
+			return pkt, nil
+		}
+
+		if s.split() {
+			continue
+		}
+		if err := s.splitErr; err != nil {
+			s.splitErr = nil
+			return nil, err
+		}
+
+		if s.err != nil {
+			if s.err == io.EOF && len(s.buf) > 0 {
+				s.buf = nil
+				s.err = io.ErrUnexpectedEOF
+			}
+			return nil, s.err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := s.fill(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Buffered returns the number of bytes read but not yet returned as packets
+func (s *StreamDecoder) Buffered() int {
+	n := len(s.buf)
+	for _, raw := range s.pending {
+		n += len(raw)
+	}
+	return n
+}
+
+// Err returns the sticky read error (io.EOF at a clean end), or nil
+func (s *StreamDecoder) Err() error {
+	return s.err
+}
+
+// split moves complete frames from buf to pending; reports whether any were found
+func (s *StreamDecoder) split() bool {
+	if len(s.buf) == 0 {
+		return false
+	}
+
+	frames, residue, err := s.decoder.opts.split(s.buf)
+	if errors.Is(err, ErrResyncLimit) || IsStreamLimitError(err) {
+		s.splitErr = err
+	} else if err != nil && len(frames) == 0 {
+		// Only garbage without a start bit: drop it
+		s.buf = s.buf[:0]
+		return false
+	}
+
+	if len(frames) == 0 {
+		s.buf = residue
+		return false
+	}
+
+	// Frames alias buf; keep them and start a fresh buffer for the residue
+	s.pending = frames
+	s.buf = append(make([]byte, 0, max(len(residue), streamReadSize)), residue...)
+	return true
+}
+
+// fill reads once from the underlying reader
+func (s *StreamDecoder) fill(ctx context.Context) error {
+	if limit := s.decoder.opts.MaxPacketSize; len(s.buf) > limit {
+		n := len(s.buf)
+		s.buf = s.buf[:0]
+		return fmt.Errorf("%w: %d buffered bytes without a complete packet (max %d)", ErrBufferOverflow, n, limit)
+	}
+
+	n, err := s.r.Read(s.readBuf)
+	s.buf = append(s.buf, s.readBuf[:n]...)
+	if err == nil {
+		return nil
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return err
+	}
+
+	// Packets still buffered are returned before the error
+	s.err = err
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// interruptOnDone sets a past read deadline when ctx is done; the returned
+// function stops watching and clears the deadline if it was set
+func interruptOnDone(ctx context.Context, ds deadlineSetter) func() {
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		ds.SetReadDeadline(time.Unix(1, 0))
+		close(interrupted)
+	})
+
+	return func() {
+		if stop() {
+			return
+		}
+		<-interrupted
+		ds.SetReadDeadline(time.Time{})
+	}
+}
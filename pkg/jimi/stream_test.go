@@ -0,0 +1,195 @@
+package jimi
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+const (
+	streamLoginHex     = "787811010359339073930520044D01E00001EB830D0A"
+	streamHeartbeatHex = "787808132404020001870D0D0A"
+)
+
+// streamOf concatenates hex strings into a reader
+func streamOf(t *testing.T, hexes ...string) *bytes.Reader {
+	t.Helper()
+	var buf []byte
+	for _, h := range hexes {
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			t.Fatalf("Invalid hex %q: %v", h, err)
+		}
+		buf = append(buf, b...)
+	}
+	return bytes.NewReader(buf)
+}
+
+// readAll calls Next until it fails
+func readAll(s *StreamDecoder) ([]packet.Packet, error) {
+	var out []packet.Packet
+	for {
+		p, err := s.Next()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, p)
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		reader  func(*testing.T) io.Reader
+		opts    []Option
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "concatenated",
+			reader: func(t *testing.T) io.Reader {
+				return streamOf(t, streamLoginHex, streamHeartbeatHex, streamHeartbeatHex)
+			},
+			want:    []byte{protocol.ProtocolLogin, protocol.ProtocolHeartbeat, protocol.ProtocolHeartbeat},
+			wantErr: io.EOF,
+		},
+		{
+			name: "one byte per read",
+			reader: func(t *testing.T) io.Reader {
+				return iotest.OneByteReader(streamOf(t, streamLoginHex, streamHeartbeatHex))
+			},
+			want:    []byte{protocol.ProtocolLogin, protocol.ProtocolHeartbeat},
+			wantErr: io.EOF,
+		},
+		{
+			name: "data with EOF",
+			reader: func(t *testing.T) io.Reader {
+				return iotest.DataErrReader(streamOf(t, streamLoginHex, streamHeartbeatHex))
+			},
+			want:    []byte{protocol.ProtocolLogin, protocol.ProtocolHeartbeat},
+			wantErr: io.EOF,
+		},
+		{
+			name: "garbage between packets",
+			reader: func(t *testing.T) io.Reader {
+				return streamOf(t, "DEADBEEF", streamLoginHex, "0102", streamHeartbeatHex)
+			},
+			want:    []byte{protocol.ProtocolLogin, protocol.ProtocolHeartbeat},
+			wantErr: io.EOF,
+		},
+		{
+			name: "truncated",
+			reader: func(t *testing.T) io.Reader {
+				return streamOf(t, streamLoginHex, streamHeartbeatHex[:10])
+			},
+			want:    []byte{protocol.ProtocolLogin},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "lenient skips bad CRC",
+			reader: func(t *testing.T) io.Reader {
+				return streamOf(t, "787808132404020001FFFF0D0A", streamLoginHex)
+			},
+			opts:    []Option{WithStrictMode(false)},
+			want:    []byte{protocol.ProtocolLogin},
+			wantErr: io.EOF,
+		},
+		{
+			name: "read error",
+			reader: func(t *testing.T) io.Reader {
+				return io.MultiReader(streamOf(t, streamLoginHex), iotest.ErrReader(errors.New("reset")))
+			},
+			want: []byte{protocol.ProtocolLogin},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStreamDecoder(tt.reader(t), tt.opts...)
+			got, err := readAll(s)
+
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && (err == nil || err == io.EOF) {
+				t.Errorf("Expected read error, got %v", err)
+			}
+			if s.Err() != err {
+				t.Errorf("Expected sticky error %v, got %v", err, s.Err())
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d packets, got %d", len(tt.want), len(got))
+			}
+			for i, p := range got {
+				if p.ProtocolNumber() != tt.want[i] {
+					t.Errorf("Packet %d: expected protocol 0x%02X, got 0x%02X", i, tt.want[i], p.ProtocolNumber())
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDecoder_StrictErrorResumes(t *testing.T) {
+	s := NewStreamDecoder(streamOf(t, "787808132404020001FFFF0D0A", streamLoginHex))
+
+	if _, err := s.Next(); err == nil {
+		t.Fatal("Expected CRC error in strict mode")
+	}
+	if s.Err() != nil {
+		t.Errorf("Decode errors must not be sticky, got %v", s.Err())
+	}
+	if p, err := s.Next(); err != nil || p.ProtocolNumber() != protocol.ProtocolLogin {
+		t.Errorf("Expected login after the bad packet, got %v, %v", p, err)
+	}
+}
+
+func TestStreamDecoder_Overflow(t *testing.T) {
+	// A long frame header announcing more than MaxPacketSize
+	r := io.MultiReader(streamOf(t, "7979FFFF"), bytes.NewReader(make([]byte, 200)))
+	s := NewStreamDecoder(r, WithMaxPacketSize(64))
+
+	if _, err := s.Next(); !errors.Is(err, ErrBufferOverflow) {
+		t.Errorf("Expected ErrBufferOverflow, got %v", err)
+	}
+}
+
+func TestStreamDecoder_ContextCancel(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	s := NewStreamDecoder(server)
+
+	// Half a packet, then nothing: NextContext blocks in Read
+	login, _ := hex.DecodeString(streamLoginHex)
+	go client.Write(login[:10])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.NextContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if s.Err() != nil {
+		t.Errorf("Cancellation must not be sticky, got %v", s.Err())
+	}
+
+	// The deadline is cleared and the buffered half is kept
+	go client.Write(login[10:])
+	p, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next after cancel failed: %v", err)
+	}
+	if p.ProtocolNumber() != protocol.ProtocolLogin {
+		t.Errorf("Expected login, got 0x%02X", p.ProtocolNumber())
+	}
+}
@@ -0,0 +1,368 @@
+// Package trips segments the location stream of every device into trips.
+//
+// A trip opens when the ignition (ACC) of a device turns on, as reported by
+// any packet (see package acc), and closes when it turns off or when no fix
+// arrives for longer than the maximum gap. The positioned location packets
+// (0x22, 0xA0) in between add up the trip: its distance from the fixes
+// (haversine), the device mileage delta, the maximum and average speed and
+// the time spent idling with the ignition on.
+//
+// Example usage:
+//
+//	tracker := trips.NewTracker(trips.WithHandler(func(t trips.Trip) {
+//	    log.Printf("%s drove %.1f km in %s", t.IMEI, t.Distance/1000, t.Duration())
+//	}))
+//
+//	srv.OnPacket(func(s *server.Session, p packet.Packet) {
+//	    tracker.Observe(s.IMEI(), p)
+//	})
+package trips
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/acc"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+// Tracker defaults
+const (
+	// DefaultIdleSpeed is the speed in km/h below which a device with the
+	// ignition on is idling
+	DefaultIdleSpeed = 3
+
+	// DefaultMaxGap is how long a trip may go without fixes before it is
+	// closed
+	DefaultMaxGap = 30 * time.Minute
+)
+
+// EndReason is why a trip was closed
+type EndReason string
+
+// End reasons
+const (
+	EndACCOff EndReason = "acc_off" // the ignition turned off
+	EndGap    EndReason = "gap"     // no fix for longer than the maximum gap
+)
+
+// Trip is the journey of a device between ignition on and off
+type Trip struct {
+	IMEI string `json:"imei"`
+
+	// Start and End are device times: the ignition on and off (or the last
+	// fix of a trip closed by a gap)
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// StartPosition and EndPosition are the first and last fixes of the trip,
+	// set when HasPosition is true
+	StartPosition types.Coordinates `json:"start_position"`
+	EndPosition   types.Coordinates `json:"end_position"`
+	HasPosition   bool              `json:"has_position"`
+
+	// Distance is the sum of the distances between the fixes in meters
+	Distance float64 `json:"distance"`
+
+	// MileageStart and MileageEnd are the first and last device mileage of
+	// the trip in meters, set when HasMileage is true
+	MileageStart uint32 `json:"mileage_start"`
+	MileageEnd   uint32 `json:"mileage_end"`
+	HasMileage   bool   `json:"has_mileage"`
+
+	// MaxSpeed is the highest speed reported in km/h
+	MaxSpeed uint16 `json:"max_speed"`
+
+	// IdleTime is the time spent below the idle speed with the ignition on
+	IdleTime time.Duration `json:"idle_time"`
+
+	// Fixes is the number of fixes in the trip
+	Fixes int `json:"fixes"`
+
+	// Reason is why the trip was closed (empty while it is open)
+	Reason EndReason `json:"reason,omitempty"`
+}
+
+// Duration returns the time between the start and the end of the trip
+func (t Trip) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// MileageDelta returns the distance in meters reported by the device
+// mileage. The second return value is false without mileage or when the
+// mileage went backwards (e.g. reset).
+func (t Trip) MileageDelta() (uint32, bool) {
+	if !t.HasMileage || t.MileageEnd < t.MileageStart {
+		return 0, false
+	}
+	return t.MileageEnd - t.MileageStart, true
+}
+
+// AvgSpeed returns the average speed in km/h while not idling
+func (t Trip) AvgSpeed() float64 {
+	moving := t.Duration() - t.IdleTime
+	if moving <= 0 {
+		return 0
+	}
+	return t.Distance / 1000 / moving.Hours()
+}
+
+// String returns a human-readable representation
+func (t Trip) String() string {
+	return fmt.Sprintf("Trip{IMEI: %s, Start: %s, Duration: %s, Distance: %.0fm, MaxSpeed: %d km/h, Idle: %s}",
+		t.IMEI, t.Start.Format(time.RFC3339), t.Duration(), t.Distance, t.MaxSpeed, t.IdleTime)
+}
+
+// Handler is called for every closed trip
+type Handler func(Trip)
+
+// Option configures a Tracker
+type Option func(*Tracker)
+
+// WithHandler sets the callback invoked for every closed trip
+func WithHandler(h Handler) Option {
+	return func(t *Tracker) {
+		t.handler = h
+	}
+}
+
+// WithIdleSpeed sets the speed in km/h below which a device is idling
+// (default DefaultIdleSpeed)
+func WithIdleSpeed(kmh uint16) Option {
+	return func(t *Tracker) {
+		t.idleSpeed = kmh
+	}
+}
+
+// WithMaxGap sets how long a trip may go without fixes before it is closed
+// (default DefaultMaxGap)
+func WithMaxGap(d time.Duration) Option {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.maxGap = d
+		}
+	}
+}
+
+// WithMinDistance drops trips shorter than meters (e.g. moving the car in
+// the driveway) without calling the handler
+func WithMinDistance(meters float64) Option {
+	return func(t *Tracker) {
+		t.minDistance = meters
+	}
+}
+
+// WithDebounce sets how long a new ignition state must persist before it
+// opens or closes a trip (see acc.WithDebounce)
+func WithDebounce(d time.Duration) Option {
+	return func(t *Tracker) {
+		t.debounce = d
+	}
+}
+
+// fix is a positioned location
+type fix struct {
+	time    time.Time
+	coords  types.Coordinates
+	speed   uint16
+	mileage uint32
+}
+
+// deviceState is the open trip of a device
+type deviceState struct {
+	trip *Trip
+	last fix // last fix of the trip, valid when trip.Fixes > 0
+}
+
+// Tracker segments the packets of every device into trips. It is safe for
+// concurrent use.
+type Tracker struct {
+	idleSpeed   uint16
+	maxGap      time.Duration
+	minDistance float64
+	debounce    time.Duration
+	handler     Handler
+	ignition    *acc.Tracker
+
+	mu      sync.Mutex
+	devices map[string]*deviceState
+}
+
+// NewTracker creates a new trip tracker
+func NewTracker(opts ...Option) *Tracker {
+	t := &Tracker{
+		idleSpeed: DefaultIdleSpeed,
+		maxGap:    DefaultMaxGap,
+		devices:   make(map[string]*deviceState),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.ignition = acc.NewTracker(acc.WithDebounce(t.debounce))
+	return t
+}
+
+// Observe feeds a decoded packet of a device into the tracker and returns
+// the trip it closed, if any. Packets without ACC information or a fix are
+// ignored.
+func (t *Tracker) Observe(imei string, p packet.Packet) (Trip, bool) {
+	if imei == "" {
+		return Trip{}, false
+	}
+	ev, changed := t.ignition.Observe(imei, p)
+	on, _ := t.ignition.State(imei)
+	f, isFix := fixOf(p)
+
+	t.mu.Lock()
+	st, ok := t.devices[imei]
+	if !ok {
+		st = &deviceState{}
+		t.devices[imei] = st
+	}
+
+	var closed *Trip
+	if st.trip != nil && isFix && st.trip.Fixes > 0 && f.time.Sub(st.last.time) > t.maxGap {
+		closed = t.close(st, st.trip.End, EndGap)
+	}
+	if st.trip == nil && on {
+		start := p.Timestamp()
+		if changed {
+			start = ev.Time
+		} else if start.IsZero() {
+			start = time.Now()
+		}
+		st.trip = &Trip{IMEI: imei, Start: start, End: start}
+	}
+	if st.trip != nil && isFix {
+		t.add(st, f)
+	}
+	if st.trip != nil && changed && !ev.On {
+		closed = t.close(st, ev.Time, EndACCOff)
+	}
+	handler := t.handler
+	t.mu.Unlock()
+
+	if closed == nil {
+		return Trip{}, false
+	}
+	if handler != nil {
+		handler(*closed)
+	}
+	return *closed, true
+}
+
+// Flush closes the trips of devices without a fix for longer than the
+// maximum gap as of now. Call it periodically to close the trips of devices
+// that went silent.
+func (t *Tracker) Flush(now time.Time) []Trip {
+	t.mu.Lock()
+	var closed []Trip
+	for _, st := range t.devices {
+		if st.trip != nil && now.Sub(st.trip.End) > t.maxGap {
+			if trip := t.close(st, st.trip.End, EndGap); trip != nil {
+				closed = append(closed, *trip)
+			}
+		}
+	}
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler != nil {
+		for _, trip := range closed {
+			handler(trip)
+		}
+	}
+	return closed
+}
+
+// Open returns the trip a device is on, up to its last fix
+func (t *Tracker) Open(imei string) (Trip, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.devices[imei]
+	if !ok || st.trip == nil {
+		return Trip{}, false
+	}
+	return *st.trip, true
+}
+
+// Forget removes all state for a device, dropping its open trip
+func (t *Tracker) Forget(imei string) {
+	t.ignition.Forget(imei)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.devices, imei)
+}
+
+// add adds a fix to the open trip. Fixes older than the last one (e.g.
+// re-uploaded late) are ignored. Caller must hold t.mu.
+func (t *Tracker) add(st *deviceState, f fix) {
+	trip := st.trip
+	if trip.Fixes > 0 {
+		if f.time.Before(st.last.time) {
+			return
+		}
+		trip.Distance += st.last.coords.DistanceTo(f.coords)
+		if st.last.speed < t.idleSpeed {
+			trip.IdleTime += f.time.Sub(st.last.time)
+		}
+	}
+	if !trip.HasPosition {
+		trip.StartPosition = f.coords
+		trip.HasPosition = true
+	}
+	trip.EndPosition = f.coords
+	if f.mileage != 0 {
+		if !trip.HasMileage {
+			trip.MileageStart = f.mileage
+			trip.HasMileage = true
+		}
+		trip.MileageEnd = f.mileage
+	}
+	trip.MaxSpeed = max(trip.MaxSpeed, f.speed)
+	trip.End = maxTime(trip.End, f.time)
+	trip.Fixes++
+	st.last = f
+}
+
+// close ends the open trip at end and returns it, or nil if it is shorter
+// than the minimum distance. Caller must hold t.mu.
+func (t *Tracker) close(st *deviceState, end time.Time, reason EndReason) *Trip {
+	trip := st.trip
+	st.trip = nil
+	trip.End = maxTime(trip.End, end)
+	trip.Reason = reason
+	if trip.Distance < t.minDistance {
+		return nil
+	}
+	return trip
+}
+
+// fixOf returns the fix of a positioned location packet
+func fixOf(p packet.Packet) (fix, bool) {
+	var loc *packet.LocationPacket
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		loc = v
+	case *packet.Location4GPacket:
+		loc = &v.LocationPacket
+	default:
+		return fix{}, false
+	}
+	if !loc.IsPositioned() || loc.Coordinates.IsZero() {
+		return fix{}, false
+	}
+	return fix{time: loc.DateTime.Time, coords: loc.Coordinates, speed: loc.Speed, mileage: loc.Mileage}, true
+}
+
+// maxTime returns the later of a and b
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
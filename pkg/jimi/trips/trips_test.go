@@ -0,0 +1,139 @@
+package trips
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const testIMEI = "359339073930523"
+
+var (
+	baseTime = time.Date(2024, 6, 15, 14, 0, 0, 0, time.UTC)
+	depot    = types.MustNewCoordinates(-12.046400, -77.042800)
+)
+
+// location builds a positioned fix meters north of depot
+func location(minute int, acc bool, meters float64, speed uint16, mileage uint32) *packet.LocationPacket {
+	c := types.MustNewCoordinates(depot.SignedLatitude()+meters/111195, depot.SignedLongitude())
+	p := packet.NewLocationPacket(types.NewDateTime(baseTime.Add(time.Duration(minute)*time.Minute)), c, speed,
+		types.NewCourseStatus(0, true, true, c.IsEast, c.IsNorth))
+	p.ACC = acc
+	p.Mileage = mileage
+	return p
+}
+
+func TestTracker_Trip(t *testing.T) {
+	var handled []Trip
+	tracker := NewTracker(WithHandler(func(trip Trip) { handled = append(handled, trip) }))
+
+	steps := []*packet.LocationPacket{
+		location(0, false, 0, 0, 10000), // parked
+		location(1, true, 0, 0, 10000),  // ignition on
+		location(3, true, 0, 0, 10000),  // warming up
+		location(5, true, 1000, 60, 11020),
+		location(7, true, 3000, 90, 13050),
+		location(8, false, 3000, 0, 13050), // ignition off
+	}
+	var closed Trip
+	for i, p := range steps {
+		trip, ok := tracker.Observe(testIMEI, p)
+		if ok != (i == len(steps)-1) {
+			t.Fatalf("step %d: closed = %v", i, ok)
+		}
+		if ok {
+			closed = trip
+		}
+		if i == 3 {
+			if open, ok := tracker.Open(testIMEI); !ok || open.Fixes != 3 {
+				t.Errorf("Unexpected open trip %+v", open)
+			}
+		}
+	}
+
+	if len(handled) != 1 || handled[0].Start != closed.Start {
+		t.Fatalf("Expected the closed trip to be handled, got %v", handled)
+	}
+	if !closed.Start.Equal(baseTime.Add(time.Minute)) || closed.Duration() != 7*time.Minute || closed.Reason != EndACCOff {
+		t.Errorf("Unexpected trip bounds %s", closed)
+	}
+	if math.Abs(closed.Distance-3000) > 1 || closed.MaxSpeed != 90 || closed.Fixes != 5 {
+		t.Errorf("Unexpected trip %s", closed)
+	}
+	if delta, ok := closed.MileageDelta(); !ok || delta != 3050 {
+		t.Errorf("Expected a mileage delta of 3050, got %d %v", delta, ok)
+	}
+	// Idle from minute 1 to 5, moving 3 km in 3 minutes (the last fix is stopped)
+	if closed.IdleTime != 4*time.Minute || math.Abs(closed.AvgSpeed()-60) > 0.1 {
+		t.Errorf("Unexpected idle %s / average %.1f km/h", closed.IdleTime, closed.AvgSpeed())
+	}
+	if _, ok := tracker.Open(testIMEI); ok {
+		t.Error("Expected no open trip")
+	}
+}
+
+func TestTracker_ACCAlarmAndHeartbeat(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Observe(testIMEI, location(0, false, 0, 0, 0))
+
+	// Ignition on by alarm, off by alarm: positions come from the fixes only
+	tracker.Observe(testIMEI, packet.NewAlarmPacket(types.NewDateTime(baseTime.Add(time.Minute)), types.Coordinates{}, protocol.AlarmACCOn))
+	tracker.Observe(testIMEI, location(2, true, 0, 30, 0))
+	tracker.Observe(testIMEI, location(3, true, 500, 30, 0))
+	trip, ok := tracker.Observe(testIMEI, packet.NewAlarmPacket(types.NewDateTime(baseTime.Add(4*time.Minute)), types.Coordinates{}, protocol.AlarmACCOff))
+	if !ok || !trip.Start.Equal(baseTime.Add(time.Minute)) || !trip.End.Equal(baseTime.Add(4*time.Minute)) {
+		t.Fatalf("Unexpected trip %s (%v)", trip, ok)
+	}
+	if !trip.HasPosition || trip.StartPosition.DistanceTo(depot) > 1 || math.Abs(trip.Distance-500) > 1 {
+		t.Errorf("Unexpected positions %+v", trip)
+	}
+	if _, ok := trip.MileageDelta(); ok {
+		t.Error("Expected no mileage")
+	}
+}
+
+func TestTracker_Gap(t *testing.T) {
+	tracker := NewTracker(WithMaxGap(10 * time.Minute))
+	tracker.Observe(testIMEI, location(0, false, 0, 0, 0))
+	tracker.Observe(testIMEI, location(1, true, 0, 40, 0))
+	tracker.Observe(testIMEI, location(2, true, 800, 40, 0))
+
+	// Back after a gap with the ignition still on: the first trip ends at its
+	// last fix and a new one starts
+	trip, ok := tracker.Observe(testIMEI, location(30, true, 5000, 40, 0))
+	if !ok || trip.Reason != EndGap || !trip.End.Equal(baseTime.Add(2*time.Minute)) {
+		t.Fatalf("Unexpected trip %s (%v)", trip, ok)
+	}
+	open, ok := tracker.Open(testIMEI)
+	if !ok || !open.Start.Equal(baseTime.Add(30*time.Minute)) || open.Distance != 0 {
+		t.Errorf("Unexpected open trip %+v", open)
+	}
+
+	// A device gone silent is closed by Flush
+	if closed := tracker.Flush(baseTime.Add(35 * time.Minute)); len(closed) != 0 {
+		t.Errorf("Expected no trip closed yet, got %v", closed)
+	}
+	if closed := tracker.Flush(baseTime.Add(45 * time.Minute)); len(closed) != 1 || closed[0].Reason != EndGap {
+		t.Errorf("Expected the open trip to be flushed, got %v", closed)
+	}
+}
+
+func TestTracker_MinDistance(t *testing.T) {
+	var handled int
+	tracker := NewTracker(WithMinDistance(100), WithHandler(func(Trip) { handled++ }))
+	tracker.Observe(testIMEI, location(0, false, 0, 0, 0))
+	tracker.Observe(testIMEI, location(1, true, 0, 5, 0))
+	tracker.Observe(testIMEI, location(2, true, 20, 5, 0))
+	if _, ok := tracker.Observe(testIMEI, location(3, false, 20, 0, 0)); ok || handled != 0 {
+		t.Error("Expected a short trip to be dropped")
+	}
+
+	// Packets without device are ignored
+	if _, ok := tracker.Observe("", location(4, true, 0, 0, 0)); ok {
+		t.Error("Expected no trip")
+	}
+}
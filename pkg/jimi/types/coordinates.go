@@ -110,7 +110,7 @@ func (c Coordinates) SignedLongitude() float64 {
 // LatitudeBytes returns the latitude as 4 bytes (big-endian)
 // for encoding back to VL103M protocol format
 func (c Coordinates) LatitudeBytes() []byte {
-	value := uint32(c.Latitude * CoordinatesDivisor)
+	value := uint32(math.Round(c.Latitude * CoordinatesDivisor))
 	return []byte{
 		byte(value >> 24),
 		byte(value >> 16),
@@ -122,7 +122,7 @@ func (c Coordinates) LatitudeBytes() []byte {
 // LongitudeBytes returns the longitude as 4 bytes (big-endian)
 // for encoding back to VL103M protocol format
 func (c Coordinates) LongitudeBytes() []byte {
-	value := uint32(c.Longitude * CoordinatesDivisor)
+	value := uint32(math.Round(c.Longitude * CoordinatesDivisor))
 	return []byte{
 		byte(value >> 24),
 		byte(value >> 16),
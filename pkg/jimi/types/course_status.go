@@ -3,7 +3,7 @@ package types
 import "fmt"
 
 // CourseStatus represents the course (direction) and GPS status from 2 bytes
-// BYTE_1: bit7-bit6(0), bit5(GPS Real-time/Differential), bit4(Positioned),
+// BYTE_1: bit7-bit6(Reserved, 0), bit5(GPS Real-time/Differential), bit4(Positioned),
 //
 //	bit3(East/West), bit2(North/South), bit1-bit0(Course high bits)
 //
@@ -14,8 +14,19 @@ type CourseStatus struct {
 	IsPositioned    bool   // true if GPS has valid fix
 	IsEastLongitude bool   // true if East longitude
 	IsNorthLatitude bool   // true if North latitude
+
+	// Reserved holds the reserved bits 7-6 of BYTE_1 as sent (bit 7 is
+	// CourseReservedHigh, bit 6 CourseReservedLow). The protocol sends them
+	// as 0; some firmware sets them, with no documented meaning.
+	Reserved uint8
 }
 
+// Reserved bits of CourseStatus.Reserved
+const (
+	CourseReservedLow  uint8 = 0x01 // bit 6 of BYTE_1
+	CourseReservedHigh uint8 = 0x02 // bit 7 of BYTE_1
+)
+
 // NewCourseStatusFromBytes creates CourseStatus from 2 bytes
 func NewCourseStatusFromBytes(data []byte) (CourseStatus, error) {
 	if len(data) < 2 {
@@ -42,6 +53,7 @@ func NewCourseStatusFromBytes(data []byte) (CourseStatus, error) {
 		IsPositioned:    (byte1 & 0x10) != 0, // bit4
 		IsEastLongitude: (byte1 & 0x08) == 0, // bit3: 0=East, 1=West
 		IsNorthLatitude: (byte1 & 0x04) != 0, // bit2: 1=North, 0=South
+		Reserved:        byte1 >> 6,          // bit7-bit6
 	}, nil
 }
 
@@ -98,6 +110,9 @@ func (c CourseStatus) Bytes() []byte {
 	courseHigh := byte((c.Course >> 8) & 0x03)
 	byte1 |= courseHigh
 
+	// Keep the reserved bits (bit7-bit6) as received
+	byte1 |= (c.Reserved & 0x03) << 6
+
 	// Course low bits (all 8 bits of byte2)
 	byte2 := byte(c.Course & 0xFF)
 
@@ -170,6 +185,17 @@ func (c CourseStatus) GetIsPositioned() bool {
 	return c.IsPositioned
 }
 
+// HasReservedBits returns true if any reserved bit is set
+func (c CourseStatus) HasReservedBits() bool {
+	return c.Reserved&0x03 != 0
+}
+
+// ReservedBit returns true if a reserved bit (CourseReservedLow or
+// CourseReservedHigh) is set
+func (c CourseStatus) ReservedBit(bit uint8) bool {
+	return c.Reserved&bit != 0
+}
+
 // GetIsGPSRealtime returns true if using real-time GPS positioning
 func (c CourseStatus) GetIsGPSRealtime() bool {
 	return c.IsGPSRealtime
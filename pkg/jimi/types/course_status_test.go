@@ -0,0 +1,50 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCourseStatus_ReservedBits(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		reserved uint8
+		high     bool
+		low      bool
+	}{
+		{"protocol", []byte{0x15, 0x4C}, 0, false, false},
+		{"bit 6", []byte{0x55, 0x4C}, 0x01, false, true},
+		{"bit 7", []byte{0x95, 0x4C}, 0x02, true, false},
+		{"both", []byte{0xD5, 0x4C}, 0x03, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCourseStatusFromBytes(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Reserved != tt.reserved || c.HasReservedBits() != (tt.reserved != 0) {
+				t.Errorf("Expected reserved %02b, got %02b", tt.reserved, c.Reserved)
+			}
+			if c.ReservedBit(CourseReservedHigh) != tt.high || c.ReservedBit(CourseReservedLow) != tt.low {
+				t.Errorf("Unexpected reserved bits of %02b", c.Reserved)
+			}
+			// The other fields do not change
+			if c.Course != 332 || !c.IsPositioned || !c.IsNorthLatitude || !c.IsEastLongitude {
+				t.Errorf("Unexpected course status %s", c)
+			}
+			if got := c.Bytes(); !bytes.Equal(got, tt.data) {
+				t.Errorf("Bytes() = %X, want %X", got, tt.data)
+			}
+		})
+	}
+
+	// Omitted from JSON when clear, as before
+	out, _ := json.Marshal(NewCourseStatus(90, true, true, true, true))
+	if strings.Contains(string(out), "Reserved") {
+		t.Errorf("Unexpected reserved bits in %s", out)
+	}
+}
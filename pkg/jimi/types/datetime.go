@@ -1,14 +1,33 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
+// ErrZeroDateTime is returned by DateTimeFromBytes for an all-zero date-time,
+// which devices send before their clock is set (e.g. right after a cold boot)
+var ErrZeroDateTime = errors.New("datetime is all zeros (device clock not set)")
+
 // DateTime represents a timestamp from a VL103M device
 // The protocol uses 6 bytes: YY MM DD HH MM SS
 type DateTime struct {
 	Time time.Time
+
+	// ZeroTime reports that the device sent an all-zero date-time and Time
+	// is the server receive time substituted for it
+	ZeroTime bool
+
+	// Timezone is the timezone of the device, when known from its login
+	// packet (see Local). Time stays in UTC.
+	Timezone *Timezone
+}
+
+// ReceivedDateTime returns the substitute for an all-zero date-time: the
+// receive time t flagged with ZeroTime
+func ReceivedDateTime(t time.Time) DateTime {
+	return DateTime{Time: t.UTC().Truncate(time.Second), ZeroTime: true}
 }
 
 // NewDateTime creates a DateTime from a time.Time value
@@ -27,6 +46,9 @@ func DateTimeFromBytes(data []byte) (DateTime, error) {
 	if len(data) < 6 {
 		return DateTime{}, fmt.Errorf("datetime requires 6 bytes, got %d", len(data))
 	}
+	if isZeroDateTime(data[:6]) {
+		return DateTime{}, ErrZeroDateTime
+	}
 
 	year := 2000 + int(data[0])
 	month := int(data[1])
@@ -56,8 +78,22 @@ func DateTimeFromBytes(data []byte) (DateTime, error) {
 	return DateTime{Time: t}, nil
 }
 
+// isZeroDateTime reports whether the 6 date-time bytes are all zero
+func isZeroDateTime(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // ToBytes encodes the DateTime to 6 protocol bytes
+// A substituted ZeroTime date-time encodes as the all-zero bytes it replaced
 func (dt DateTime) ToBytes() []byte {
+	if dt.ZeroTime {
+		return make([]byte, 6)
+	}
 	t := dt.Time.UTC()
 
 	year := t.Year() - 2000
@@ -109,7 +145,8 @@ func (dt DateTime) After(other DateTime) bool {
 
 // Add returns a new DateTime with the given duration added
 func (dt DateTime) Add(d time.Duration) DateTime {
-	return DateTime{Time: dt.Time.Add(d)}
+	dt.Time = dt.Time.Add(d)
+	return dt
 }
 
 // Sub returns the duration between two DateTimes
@@ -119,13 +156,33 @@ func (dt DateTime) Sub(other DateTime) time.Duration {
 
 // InLocation returns the DateTime in the specified timezone
 func (dt DateTime) InLocation(loc *time.Location) DateTime {
-	return DateTime{Time: dt.Time.In(loc)}
+	dt.Time = dt.Time.In(loc)
+	return dt
 }
 
 // WithTimezoneOffset applies a timezone offset in minutes
 func (dt DateTime) WithTimezoneOffset(offsetMinutes int) DateTime {
-	loc := time.FixedZone("", offsetMinutes*60)
-	return DateTime{Time: dt.Time.In(loc)}
+	dt.Time = dt.Time.In(time.FixedZone("", offsetMinutes*60))
+	return dt
+}
+
+// Local returns the time in the device timezone, or Time if the timezone is
+// not known
+func (dt DateTime) Local() time.Time {
+	if dt.Timezone == nil {
+		return dt.Time
+	}
+	return dt.Time.In(dt.Timezone.Location())
+}
+
+// FromDeviceLocal reinterprets Time, decoded as UTC from a device sending
+// its local wall clock, as the wall clock in tz, and sets Timezone
+func (dt DateTime) FromDeviceLocal(tz Timezone) DateTime {
+	if !dt.ZeroTime {
+		dt.Time = dt.Time.Add(-time.Duration(tz.OffsetMinutes) * time.Minute)
+	}
+	dt.Timezone = &tz
+	return dt
 }
 
 // Timezone represents the timezone/language field from the protocol
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceID is the canonical identifier of a device: its 15-digit IMEI, or
+// the 16-digit identifier some devices report in the IMEI field instead.
+// Sessions, stores and APIs key devices by its string form, so the same
+// device always maps to the same key whichever way it was written.
+type DeviceID string
+
+// IDMapper maps the 8 BCD bytes of the login packet IMEI field to a
+// DeviceID. DeviceIDFromBCD is the default; firmware with other quirks can
+// plug in its own mapping (see jimi.WithIDMapper).
+type IDMapper func(bcd []byte) (DeviceID, error)
+
+// ParseDeviceID normalizes a textual device identifier: 15 digits are an
+// IMEI, 16 digits with a leading zero padding are the same IMEI, and other
+// 16 digits are a 16-digit identifier. Surrounding whitespace is ignored.
+func ParseDeviceID(s string) (DeviceID, error) {
+	s = strings.TrimSpace(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return "", fmt.Errorf("invalid device ID: must be digits, got: %s", s)
+		}
+	}
+
+	switch {
+	case len(s) == 15:
+		return DeviceID(s), nil
+	case len(s) == 16 && s[0] == '0':
+		return DeviceID(s[1:]), nil
+	case len(s) == 16:
+		return DeviceID(s), nil
+	}
+	return "", fmt.Errorf("invalid device ID: must be 15 or 16 digits, got: %s", s)
+}
+
+// DeviceIDFromBCD is the default IDMapper. The 16 BCD digits hold:
+//
+//   - a zero (or 0xF) padding nibble and a 15-digit IMEI, as the protocol
+//     specifies: "0359339073930520" → "359339073930520"
+//   - a 15-digit IMEI and a trailing zero (or 0xF) padding nibble, as some
+//     firmware writes it, when the first 15 digits pass the IMEI checksum
+//   - otherwise a 16-digit identifier, kept whole
+func DeviceIDFromBCD(data []byte) (DeviceID, error) {
+	if len(data) != 8 {
+		return "", fmt.Errorf("device ID bytes must be exactly 8 bytes, got %d", len(data))
+	}
+
+	digits := make([]byte, 0, 16)
+	for i, b := range data {
+		for j, nibble := range [2]byte{b >> 4, b & 0x0F} {
+			// 0xF only pads the first or the last digit
+			if nibble == 0x0F && (i == 0 && j == 0 || i == 7 && j == 1) {
+				nibble = 0
+			}
+			if nibble > 9 {
+				return "", fmt.Errorf("invalid BCD encoding in device ID bytes: 0x%02X", b)
+			}
+			digits = append(digits, '0'+nibble)
+		}
+	}
+
+	switch {
+	case digits[0] == '0':
+		return DeviceID(digits[1:]), nil
+	case digits[15] == '0' && validateIMEIChecksum(string(digits[:15])):
+		return DeviceID(digits[:15]), nil
+	}
+	return DeviceID(digits), nil
+}
+
+// String returns the identifier digits
+func (id DeviceID) String() string {
+	return string(id)
+}
+
+// IsValid returns true if the identifier is set
+func (id DeviceID) IsValid() bool {
+	return id != ""
+}
+
+// IsIMEI returns true if the identifier is a 15-digit IMEI
+func (id DeviceID) IsIMEI() bool {
+	return len(id) == 15
+}
+
+// IMEI returns the identifier as an IMEI, without checksum validation.
+// It fails for 16-digit identifiers.
+func (id DeviceID) IMEI() (IMEI, error) {
+	return NewIMEIUnchecked(string(id))
+}
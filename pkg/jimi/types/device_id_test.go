@@ -0,0 +1,73 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseDeviceID(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    DeviceID
+		wantErr bool
+	}{
+		{"359339073930520", "359339073930520", false},
+		{"0359339073930520", "359339073930520", false},
+		{" 359339073930520\n", "359339073930520", false},
+		{"8612345678901234", "8612345678901234", false},
+		{"35933907393052", "", true},
+		{"35933907393052A", "", true},
+		{"03593390739305201", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDeviceID(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDeviceID(%q) = %q, %v; want %q (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDeviceIDFromBCD(t *testing.T) {
+	tests := []struct {
+		name     string
+		hex      string
+		want     DeviceID
+		wantIMEI bool
+		wantErr  bool
+	}{
+		{"leading zero", "0359339073930520", "359339073930520", true, false},
+		{"leading F", "F359339073930520", "359339073930520", true, false},
+		{"trailing zero", "3593390739305200", "359339073930520", true, false},
+		{"trailing F", "359339073930520F", "359339073930520", true, false},
+		{"16 digits", "8612345678901234", "8612345678901234", false, false},
+		// The first 15 digits fail the IMEI checksum, so the zero is a digit
+		{"16 digits ending in zero", "8612345678901230", "8612345678901230", false, false},
+		{"invalid nibble", "03593390A3930520", "", false, true},
+		{"F inside", "03593390F3930520", "", false, true},
+		{"wrong length", "03593390739305", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DeviceIDFromBCD(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want || got.IsIMEI() != tt.wantIMEI {
+				t.Errorf("Expected %q (IMEI %v), got %q (IMEI %v)", tt.want, tt.wantIMEI, got, got.IsIMEI())
+			}
+		})
+	}
+}
+
+func TestDeviceIDIMEI(t *testing.T) {
+	if imei, err := DeviceID("359339073930520").IMEI(); err != nil || imei.String() != "359339073930520" {
+		t.Errorf("Expected IMEI 359339073930520, got %q (%v)", imei, err)
+	}
+	if _, err := DeviceID("8612345678901234").IMEI(); err == nil {
+		t.Error("Expected an error for a 16-digit ID")
+	}
+}
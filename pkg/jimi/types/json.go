@@ -0,0 +1,248 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// JSON encoding of the value types.
+//
+// Field names are snake_case and stable; decoding accepts what encoding
+// produces. Derived values (TerminalInfo flags) are informational and
+// ignored when decoding.
+//
+//	DateTime      "2024-06-15T14:30:00Z" (RFC 3339, null when zero; ZeroTime is
+//	              encoded by the packets, as "zero_time")
+//	IMEI          "359339073930520"
+//	Coordinates   {"latitude": -33.86882, "longitude": 151.209296} (signed degrees)
+//	CourseStatus  {"course": 90, "realtime": true, "positioned": true, "east": true, "north": false}
+//	              (and "reserved": 2 when the reserved bits are set)
+//	LBSInfo       {"mcc": 460, "mnc": 0, "lac": 10173, "cell_id": 7864}
+//	WiFiAccessPoint {"mac": "a4:5e:60:e3:1c:02", "rssi": -75}
+//	TerminalInfo  {"raw": "0x46", "acc": true, "charging": true, "gps": true,
+//	               "armed": false, "oil_cut": false, "alarm_bits": 0}
+//	Timezone      {"offset_minutes": 480, "language": 2}
+
+// MarshalJSON encodes the date-time as an RFC 3339 string
+func (dt DateTime) MarshalJSON() ([]byte, error) {
+	if dt.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(dt.Time.Format(time.RFC3339))
+}
+
+// UnmarshalJSON decodes an RFC 3339 string or null
+func (dt *DateTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*dt = DateTime{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("datetime: %w", err)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("datetime: %w", err)
+	}
+	dt.Time = t
+	return nil
+}
+
+// MarshalJSON encodes the IMEI as a string ("" when unset)
+func (i IMEI) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.value)
+}
+
+// UnmarshalJSON decodes a 15-digit string; the check digit is not verified
+// because devices with invalid check digits exist
+func (i *IMEI) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("imei: %w", err)
+	}
+	if s == "" {
+		*i = IMEI{}
+		return nil
+	}
+	imei, err := NewIMEIUnchecked(s)
+	if err != nil {
+		return err
+	}
+	*i = imei
+	return nil
+}
+
+// coordinatesJSON is the JSON form of Coordinates
+type coordinatesJSON struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// MarshalJSON encodes signed decimal degrees
+func (c Coordinates) MarshalJSON() ([]byte, error) {
+	return json.Marshal(coordinatesJSON{c.SignedLatitude(), c.SignedLongitude()})
+}
+
+// UnmarshalJSON decodes signed decimal degrees.
+// A negative zero (-0) keeps the South/West hemisphere.
+func (c *Coordinates) UnmarshalJSON(data []byte) error {
+	var v coordinatesJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("coordinates: %w", err)
+	}
+	coords, err := NewCoordinates(v.Latitude, v.Longitude)
+	if err != nil {
+		return err
+	}
+	coords.IsNorth = !math.Signbit(v.Latitude)
+	coords.IsEast = !math.Signbit(v.Longitude)
+	*c = coords
+	return nil
+}
+
+// courseStatusJSON is the JSON form of CourseStatus
+type courseStatusJSON struct {
+	Course     uint16 `json:"course"`
+	Realtime   bool   `json:"realtime"`
+	Positioned bool   `json:"positioned"`
+	East       bool   `json:"east"`
+	North      bool   `json:"north"`
+	Reserved   uint8  `json:"reserved,omitempty"`
+}
+
+// MarshalJSON encodes the course, the GPS flags and the reserved bits when set
+func (c CourseStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(courseStatusJSON{
+		Course:     c.Course,
+		Realtime:   c.IsGPSRealtime,
+		Positioned: c.IsPositioned,
+		East:       c.IsEastLongitude,
+		North:      c.IsNorthLatitude,
+		Reserved:   c.Reserved,
+	})
+}
+
+// UnmarshalJSON decodes the course, the GPS flags and the reserved bits
+func (c *CourseStatus) UnmarshalJSON(data []byte) error {
+	var v courseStatusJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("course status: %w", err)
+	}
+	if v.Reserved > CourseReservedHigh|CourseReservedLow {
+		return fmt.Errorf("course status: reserved bits 0x%02X out of range", v.Reserved)
+	}
+	*c = NewCourseStatus(v.Course, v.Realtime, v.Positioned, v.East, v.North)
+	c.Reserved = v.Reserved
+	return nil
+}
+
+// lbsInfoJSON is the JSON form of LBSInfo
+type lbsInfoJSON struct {
+	MCC    uint16 `json:"mcc"`
+	MNC    uint16 `json:"mnc"`
+	LAC    uint32 `json:"lac"`
+	CellID uint64 `json:"cell_id"`
+}
+
+// MarshalJSON encodes the cell
+func (l LBSInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(lbsInfoJSON(l))
+}
+
+// UnmarshalJSON decodes the cell
+func (l *LBSInfo) UnmarshalJSON(data []byte) error {
+	var v lbsInfoJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("lbs info: %w", err)
+	}
+	*l = LBSInfo(v)
+	return nil
+}
+
+// wifiAccessPointJSON is the JSON form of WiFiAccessPoint
+type wifiAccessPointJSON struct {
+	MAC  string `json:"mac"`
+	RSSI int8   `json:"rssi"`
+}
+
+// MarshalJSON encodes the access point with its MAC as "aa:bb:cc:dd:ee:ff"
+func (w WiFiAccessPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(wifiAccessPointJSON{w.MACString(), w.RSSI})
+}
+
+// UnmarshalJSON decodes the access point
+func (w *WiFiAccessPoint) UnmarshalJSON(data []byte) error {
+	var v wifiAccessPointJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("wifi access point: %w", err)
+	}
+	mac, err := ParseMAC(v.MAC)
+	if err != nil {
+		return fmt.Errorf("wifi access point: %w", err)
+	}
+	*w = WiFiAccessPoint{MAC: mac, RSSI: v.RSSI}
+	return nil
+}
+
+// terminalInfoJSON is the JSON form of TerminalInfo
+type terminalInfoJSON struct {
+	Raw       string `json:"raw"`
+	ACC       bool   `json:"acc"`
+	Charging  bool   `json:"charging"`
+	GPS       bool   `json:"gps"`
+	Armed     bool   `json:"armed"`
+	OilCut    bool   `json:"oil_cut"`
+	AlarmBits byte   `json:"alarm_bits"`
+}
+
+// MarshalJSON encodes the raw byte ("0xNN") and its decoded flags
+func (t TerminalInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(terminalInfoJSON{
+		Raw:       fmt.Sprintf("0x%02X", t.raw),
+		ACC:       t.ACCOn(),
+		Charging:  t.IsCharging(),
+		GPS:       t.GPSTrackingEnabled(),
+		Armed:     t.IsArmed(),
+		OilCut:    t.OilElectricityDisconnected(),
+		AlarmBits: t.AlarmTypeBits(),
+	})
+}
+
+// UnmarshalJSON decodes the raw byte; the flags are derived from it
+func (t *TerminalInfo) UnmarshalJSON(data []byte) error {
+	var v terminalInfoJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("terminal info: %w", err)
+	}
+	raw, err := strconv.ParseUint(v.Raw, 0, 8)
+	if err != nil {
+		return fmt.Errorf("terminal info: invalid raw %q", v.Raw)
+	}
+	t.raw = byte(raw)
+	return nil
+}
+
+// timezoneJSON is the JSON form of Timezone
+type timezoneJSON struct {
+	OffsetMinutes int  `json:"offset_minutes"`
+	Language      byte `json:"language"`
+}
+
+// MarshalJSON encodes the offset and language code
+func (tz Timezone) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timezoneJSON(tz))
+}
+
+// UnmarshalJSON decodes the offset and language code
+func (tz *Timezone) UnmarshalJSON(data []byte) error {
+	var v timezoneJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	*tz = Timezone(v)
+	return nil
+}
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSON_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{
+			name:  "datetime",
+			value: NewDateTime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC)),
+			want:  `"2024-06-15T14:30:00Z"`,
+		},
+		{
+			name:  "zero datetime",
+			value: DateTime{},
+			want:  `null`,
+		},
+		{
+			name:  "imei",
+			value: MustNewIMEI("359339073930520"),
+			want:  `"359339073930520"`,
+		},
+		{
+			name:  "coordinates",
+			value: MustNewCoordinates(-33.8688, 151.2093),
+			want:  `{"latitude":-33.8688,"longitude":151.2093}`,
+		},
+		{
+			name:  "coordinates on the equator, southern flag",
+			value: Coordinates{Latitude: 0, Longitude: 10, IsNorth: false, IsEast: true},
+			want:  `{"latitude":-0,"longitude":10}`,
+		},
+		{
+			name:  "course status",
+			value: NewCourseStatus(90, true, true, true, false),
+			want:  `{"course":90,"realtime":true,"positioned":true,"east":true,"north":false}`,
+		},
+		{
+			name: "course status with reserved bits",
+			value: CourseStatus{Course: 270, IsGPSRealtime: true, IsPositioned: true, IsNorthLatitude: true,
+				Reserved: CourseReservedHigh},
+			want: `{"course":270,"realtime":true,"positioned":true,"east":false,"north":true,"reserved":2}`,
+		},
+		{
+			name:  "lbs info",
+			value: LBSInfo{MCC: 460, MNC: 0, LAC: 10173, CellID: 7864},
+			want:  `{"mcc":460,"mnc":0,"lac":10173,"cell_id":7864}`,
+		},
+		{
+			name:  "wifi access point",
+			value: WiFiAccessPoint{MAC: [6]byte{0xA4, 0x5E, 0x60, 0xE3, 0x1C, 0x02}, RSSI: -75},
+			want:  `{"mac":"a4:5e:60:e3:1c:02","rssi":-75}`,
+		},
+		{
+			name:  "terminal info",
+			value: NewTerminalInfo(0x46),
+			want:  `{"raw":"0x46","acc":true,"charging":true,"gps":true,"armed":false,"oil_cut":false,"alarm_bits":0}`,
+		},
+		{
+			name:  "timezone",
+			value: Timezone{OffsetMinutes: 480, Language: 2},
+			want:  `{"offset_minutes":480,"language":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal = %s, want %s", data, tt.want)
+			}
+
+			got := reflect.New(reflect.TypeOf(tt.value))
+			if err := json.Unmarshal(data, got.Interface()); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), tt.value) {
+				t.Errorf("Unmarshal = %+v, want %+v", got.Elem().Interface(), tt.value)
+			}
+		})
+	}
+}
+
+func TestJSON_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		value any
+	}{
+		{"datetime format", `"15/06/2024"`, &DateTime{}},
+		{"imei length", `"12345"`, &IMEI{}},
+		{"latitude range", `{"latitude":91,"longitude":0}`, &Coordinates{}},
+		{"terminal raw", `{"raw":"0x1FF"}`, &TerminalInfo{}},
+		{"course reserved bits", `{"course":90,"reserved":4}`, &CourseStatus{}},
+		{"wifi mac", `{"mac":"a4:5e:60:e3:1c","rssi":-75}`, &WiFiAccessPoint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(tt.data), tt.value); err == nil {
+				t.Errorf("Expected error for %s", tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package types
+
+import "fmt"
+
+// WiFiAccessPointSize is the encoded size of an access point: MAC(6) + RSSI(1)
+const WiFiAccessPointSize = 7
+
+// WiFiAccessPoint is a WiFi access point seen by the device, as reported in
+// WiFi packets for indoor positioning
+type WiFiAccessPoint struct {
+	MAC  [6]byte // BSSID
+	RSSI int8    // Signal strength in dBm (negative)
+}
+
+// NewWiFiAccessPointFromBytes creates a WiFiAccessPoint from MAC(6) + RSSI(1).
+// Devices send the RSSI as the magnitude of the dBm value (0x4B = -75 dBm);
+// bytes with the high bit set are already negative two's complement values.
+func NewWiFiAccessPointFromBytes(data []byte) (WiFiAccessPoint, error) {
+	if len(data) < WiFiAccessPointSize {
+		return WiFiAccessPoint{}, fmt.Errorf("WiFi access point requires %d bytes, got %d", WiFiAccessPointSize, len(data))
+	}
+
+	var ap WiFiAccessPoint
+	copy(ap.MAC[:], data[:6])
+	ap.RSSI = int8(data[6])
+	if ap.RSSI > 0 {
+		ap.RSSI = -ap.RSSI
+	}
+	return ap, nil
+}
+
+// ParseMAC parses a MAC address written as "aa:bb:cc:dd:ee:ff" (or with '-')
+func ParseMAC(s string) ([6]byte, error) {
+	var mac [6]byte
+	if len(s) != 17 {
+		return mac, fmt.Errorf("invalid MAC address %q", s)
+	}
+	for i := range mac {
+		if i > 0 && s[i*3-1] != ':' && s[i*3-1] != '-' {
+			return mac, fmt.Errorf("invalid MAC address %q", s)
+		}
+		var b byte
+		if _, err := fmt.Sscanf(s[i*3:i*3+2], "%02x", &b); err != nil {
+			return mac, fmt.Errorf("invalid MAC address %q", s)
+		}
+		mac[i] = b
+	}
+	return mac, nil
+}
+
+// MACString returns the MAC address as "aa:bb:cc:dd:ee:ff"
+func (w WiFiAccessPoint) MACString() string {
+	m := w.MAC
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", m[0], m[1], m[2], m[3], m[4], m[5])
+}
+
+// IsValid returns true if the MAC address is set
+func (w WiFiAccessPoint) IsValid() bool {
+	return w.MAC != [6]byte{}
+}
+
+// Bytes returns the access point encoded as MAC(6) + RSSI magnitude(1)
+func (w WiFiAccessPoint) Bytes() []byte {
+	rssi := int(w.RSSI)
+	if rssi < 0 {
+		rssi = -rssi
+	}
+	return append(w.MAC[:], byte(rssi))
+}
+
+// String returns a human-readable representation of the access point
+func (w WiFiAccessPoint) String() string {
+	return fmt.Sprintf("MAC:%s RSSI:%d", w.MACString(), w.RSSI)
+}
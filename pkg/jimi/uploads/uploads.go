@@ -0,0 +1,268 @@
+// Package uploads aggregates why devices upload their positions.
+//
+// Location and LBS packets carry an UploadMode byte (fixed interval, fixed
+// distance, turning point, ACC change, ...). Stats counts the modes per
+// device under analytics labels, so a fleet dashboard can spot devices that
+// are misconfigured to upload only on corner turns or still intervals and
+// therefore go silent while driving straight.
+//
+// Labels come from a Labeler; DefaultLabel maps every mode to a stable
+// snake_case label and WithLabeler plugs in a custom mapping (e.g. to merge
+// all "still" modes into one bucket).
+//
+// Example usage:
+//
+//	stats := uploads.NewStats()
+//
+//	for _, pkt := range packets {
+//	    stats.Observe(imei, pkt)
+//	}
+//	for _, d := range stats.Suspects(uploads.DefaultMinUploads, uploads.DefaultSuspectShare) {
+//	    log.Printf("%s: %d uploads, %v", d.IMEI, d.Total, d.Counts)
+//	}
+package uploads
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+)
+
+// Default labels of the modes suspected of misconfiguration
+const (
+	LabelTurningPoint  = "turning_point"
+	LabelStillInterval = "still_interval"
+)
+
+// Default Suspects thresholds: enough uploads to judge a device, and the
+// share of them under the suspect labels
+const (
+	DefaultMinUploads   = 20
+	DefaultSuspectShare = 0.8
+)
+
+// Labeler maps an upload mode to an analytics label
+type Labeler func(protocol.UploadMode) string
+
+// DefaultLabel returns the stable snake_case label of an upload mode
+func DefaultLabel(m protocol.UploadMode) string {
+	switch m {
+	case protocol.UploadModeInterval:
+		return "interval"
+	case protocol.UploadModeDistance:
+		return "distance"
+	case protocol.UploadModeTurningPoint:
+		return LabelTurningPoint
+	case protocol.UploadModeACCChange:
+		return "acc_change"
+	case protocol.UploadModeStopToStill:
+		return "stop_to_still"
+	case protocol.UploadModeNetworkReconnect:
+		return "network_reconnect"
+	case protocol.UploadModeRefreshEphem:
+		return "refresh_ephemeris"
+	case protocol.UploadModeKeyPress:
+		return "key_press"
+	case protocol.UploadModePowerOn:
+		return "power_on"
+	case protocol.UploadModeAfterStill:
+		return "after_still"
+	case protocol.UploadModeWiFi:
+		return "wifi"
+	case protocol.UploadModeImmediate:
+		return "immediate"
+	case protocol.UploadModeLastStill:
+		return "last_still"
+	case protocol.UploadModeGPSDUP:
+		return LabelStillInterval
+	case protocol.UploadModeExitTracking:
+		return "exit_tracking"
+	default:
+		return "unknown"
+	}
+}
+
+// Mode returns the upload mode carried by a packet.
+// Returns false for packets without one (LBS packets report it only with
+// their status block).
+func Mode(p packet.Packet) (protocol.UploadMode, bool) {
+	switch v := p.(type) {
+	case *packet.LocationPacket:
+		return v.UploadMode, true
+	case *packet.Location4GPacket:
+		return v.UploadMode, true
+	case *packet.LBSPacket:
+		if !v.HasStatus {
+			return 0, false
+		}
+		return v.UploadMode, true
+	case *packet.LBS4GPacket:
+		return v.UploadMode, true
+	default:
+		return 0, false
+	}
+}
+
+// DeviceStats is the upload mode breakdown of one device
+type DeviceStats struct {
+	// IMEI identifies the device
+	IMEI string `json:"imei"`
+
+	// Total is the number of uploads observed
+	Total int `json:"total"`
+
+	// Counts is the number of uploads per label
+	Counts map[string]int `json:"counts"`
+}
+
+// Share returns the fraction of uploads under any of the labels
+func (d DeviceStats) Share(labels ...string) float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	n := 0
+	for _, l := range labels {
+		n += d.Counts[l]
+	}
+	return float64(n) / float64(d.Total)
+}
+
+// Option configures Stats
+type Option func(*Stats)
+
+// WithLabeler sets the mapping from upload modes to labels
+func WithLabeler(l Labeler) Option {
+	return func(s *Stats) {
+		if l != nil {
+			s.labeler = l
+		}
+	}
+}
+
+// WithSuspectLabels sets the labels Suspects looks for.
+// Default: LabelTurningPoint and LabelStillInterval.
+func WithSuspectLabels(labels ...string) Option {
+	return func(s *Stats) {
+		s.suspect = labels
+	}
+}
+
+// Stats counts upload modes per device. It is safe for concurrent use.
+type Stats struct {
+	mu      sync.Mutex
+	labeler Labeler
+	suspect []string
+	devices map[string]map[string]int
+}
+
+// NewStats creates empty upload mode statistics
+func NewStats(opts ...Option) *Stats {
+	s := &Stats{
+		labeler: DefaultLabel,
+		suspect: []string{LabelTurningPoint, LabelStillInterval},
+		devices: make(map[string]map[string]int),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Label returns the label of the upload mode carried by a packet
+func (s *Stats) Label(p packet.Packet) (string, bool) {
+	m, ok := Mode(p)
+	if !ok {
+		return "", false
+	}
+	return s.labeler(m), true
+}
+
+// Observe counts the upload mode of a packet for a device.
+// Returns the label, or false if the packet carries no upload mode.
+func (s *Stats) Observe(imei string, p packet.Packet) (string, bool) {
+	label, ok := s.Label(p)
+	if !ok {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts, ok := s.devices[imei]
+	if !ok {
+		counts = make(map[string]int)
+		s.devices[imei] = counts
+	}
+	counts[label]++
+	return label, true
+}
+
+// Enrich adds the upload label of p to a record as "upload_label"
+func (s *Stats) Enrich(rec *export.Record, p packet.Packet) {
+	label, ok := s.Label(p)
+	if !ok {
+		return
+	}
+	if rec.Fields == nil {
+		rec.Fields = make(map[string]any)
+	}
+	rec.Fields["upload_label"] = label
+}
+
+// Device returns the breakdown of one device
+func (s *Stats) Device(imei string) (DeviceStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts, ok := s.devices[imei]
+	if !ok {
+		return DeviceStats{}, false
+	}
+	return deviceStats(imei, counts), true
+}
+
+// Devices returns the breakdown of every device, sorted by IMEI
+func (s *Stats) Devices() []DeviceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]DeviceStats, 0, len(s.devices))
+	for imei, counts := range s.devices {
+		result = append(result, deviceStats(imei, counts))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IMEI < result[j].IMEI })
+	return result
+}
+
+// Suspects returns the devices with at least minUploads uploads of which at
+// least share (0-1) fall under the suspect labels, sorted by IMEI
+func (s *Stats) Suspects(minUploads int, share float64) []DeviceStats {
+	var result []DeviceStats
+	for _, d := range s.Devices() {
+		if d.Total >= minUploads && d.Total > 0 && d.Share(s.suspect...) >= share {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Forget removes the counts of a device
+func (s *Stats) Forget(imei string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.devices, imei)
+}
+
+// deviceStats copies the counts of a device. Caller must hold s.mu.
+func deviceStats(imei string, counts map[string]int) DeviceStats {
+	d := DeviceStats{IMEI: imei, Counts: make(map[string]int, len(counts))}
+	for label, n := range counts {
+		d.Counts[label] = n
+		d.Total += n
+	}
+	return d
+}
@@ -0,0 +1,172 @@
+package uploads
+
+import (
+	"testing"
+
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/export"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/packet"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/protocol"
+	"github.com/fcode09/jimi-vl103m/pkg/jimi/types"
+)
+
+const (
+	testIMEI  = "359339073930523"
+	otherIMEI = "359339073930524"
+)
+
+func location(mode protocol.UploadMode) *packet.LocationPacket {
+	p := packet.NewLocationPacket(
+		types.Now(),
+		types.MustNewCoordinates(-12.0464, -77.0428),
+		0,
+		types.NewCourseStatus(0, true, true, false, false),
+	)
+	p.UploadMode = mode
+	return p
+}
+
+func TestMode(t *testing.T) {
+	lbs := packet.NewLBSPacket(types.Now(), types.LBSInfo{})
+	lbs.UploadMode = protocol.UploadModeDistance
+
+	lbsStatus := packet.NewLBSPacket(types.Now(), types.LBSInfo{})
+	lbsStatus.UploadMode = protocol.UploadModeDistance
+	lbsStatus.HasStatus = true
+
+	tests := []struct {
+		name     string
+		packet   packet.Packet
+		wantMode protocol.UploadMode
+		wantOK   bool
+	}{
+		{"location", location(protocol.UploadModeTurningPoint), protocol.UploadModeTurningPoint, true},
+		{"location 4G", &packet.Location4GPacket{LocationPacket: *location(protocol.UploadModeGPSDUP)}, protocol.UploadModeGPSDUP, true},
+		{"LBS without status", lbs, 0, false},
+		{"LBS with status", lbsStatus, protocol.UploadModeDistance, true},
+		{"heartbeat", &packet.HeartbeatPacket{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, ok := Mode(tt.packet)
+			if ok != tt.wantOK || mode != tt.wantMode {
+				t.Errorf("Expected (%s, %v), got (%s, %v)", tt.wantMode, tt.wantOK, mode, ok)
+			}
+		})
+	}
+}
+
+func TestStats_Observe(t *testing.T) {
+	stats := NewStats()
+
+	for range 3 {
+		stats.Observe(testIMEI, location(protocol.UploadModeInterval))
+	}
+	label, ok := stats.Observe(testIMEI, location(protocol.UploadModeTurningPoint))
+	if !ok || label != LabelTurningPoint {
+		t.Errorf("Expected label %q, got %q (%v)", LabelTurningPoint, label, ok)
+	}
+	if _, ok := stats.Observe(testIMEI, &packet.HeartbeatPacket{}); ok {
+		t.Error("Expected heartbeat to carry no upload mode")
+	}
+
+	d, ok := stats.Device(testIMEI)
+	if !ok {
+		t.Fatal("Expected device stats")
+	}
+	if d.Total != 4 || d.Counts["interval"] != 3 || d.Counts[LabelTurningPoint] != 1 {
+		t.Errorf("Unexpected stats: %+v", d)
+	}
+	if share := d.Share(LabelTurningPoint); share != 0.25 {
+		t.Errorf("Expected turning point share 0.25, got %v", share)
+	}
+
+	stats.Forget(testIMEI)
+	if _, ok := stats.Device(testIMEI); ok {
+		t.Error("Expected device to be forgotten")
+	}
+}
+
+func TestStats_Suspects(t *testing.T) {
+	stats := NewStats()
+
+	// Uploads only on corner turns and while still
+	for range 6 {
+		stats.Observe(testIMEI, location(protocol.UploadModeTurningPoint))
+	}
+	for range 4 {
+		stats.Observe(testIMEI, location(protocol.UploadModeGPSDUP))
+	}
+
+	// Healthy interval uploads
+	for range 9 {
+		stats.Observe(otherIMEI, location(protocol.UploadModeInterval))
+	}
+	stats.Observe(otherIMEI, location(protocol.UploadModeTurningPoint))
+
+	tests := []struct {
+		name       string
+		minUploads int
+		share      float64
+		want       []string
+	}{
+		{"misconfigured device", 10, 0.8, []string{testIMEI}},
+		{"too few uploads", 11, 0.8, nil},
+		{"low threshold", 1, 0.1, []string{testIMEI, otherIMEI}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stats.Suspects(tt.minUploads, tt.share)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %+v", tt.want, got)
+			}
+			for i, d := range got {
+				if d.IMEI != tt.want[i] {
+					t.Errorf("Suspect %d: expected %s, got %s", i, tt.want[i], d.IMEI)
+				}
+			}
+		})
+	}
+}
+
+func TestStats_CustomLabeler(t *testing.T) {
+	still := func(m protocol.UploadMode) string {
+		switch m {
+		case protocol.UploadModeStopToStill, protocol.UploadModeAfterStill,
+			protocol.UploadModeLastStill, protocol.UploadModeGPSDUP:
+			return "still"
+		}
+		return DefaultLabel(m)
+	}
+	stats := NewStats(WithLabeler(still), WithSuspectLabels("still"))
+
+	stats.Observe(testIMEI, location(protocol.UploadModeAfterStill))
+	stats.Observe(testIMEI, location(protocol.UploadModeGPSDUP))
+
+	d, _ := stats.Device(testIMEI)
+	if d.Counts["still"] != 2 {
+		t.Errorf("Expected 2 still uploads, got %+v", d.Counts)
+	}
+	if got := stats.Suspects(1, 1); len(got) != 1 {
+		t.Errorf("Expected the device as suspect, got %+v", got)
+	}
+}
+
+func TestStats_Enrich(t *testing.T) {
+	stats := NewStats()
+
+	p := location(protocol.UploadModeGPSDUP)
+	rec := export.FromPacket(p)
+	stats.Enrich(&rec, p)
+	if rec.Fields["upload_label"] != LabelStillInterval {
+		t.Errorf("Expected upload_label %q, got %v", LabelStillInterval, rec.Fields["upload_label"])
+	}
+
+	hb := &packet.HeartbeatPacket{}
+	rec = export.FromPacket(hb)
+	stats.Enrich(&rec, hb)
+	if _, ok := rec.Fields["upload_label"]; ok {
+		t.Error("Expected no upload_label on a heartbeat record")
+	}
+}